@@ -3,19 +3,29 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
 
 	"github.com/iannil/geofence-updater-lite/internal/version"
 	"github.com/iannil/geofence-updater-lite/pkg/config"
 	"github.com/iannil/geofence-updater-lite/pkg/crypto"
 	"github.com/iannil/geofence-updater-lite/pkg/geofence"
+	"github.com/iannil/geofence-updater-lite/pkg/geojson"
 	"github.com/iannil/geofence-updater-lite/pkg/publisher"
+	"github.com/iannil/geofence-updater-lite/pkg/validation"
 )
 
 var (
@@ -24,8 +34,24 @@ var (
 	outputDir   = flag.String("output", "./output", "output directory for generated files")
 	dbPath      = flag.String("db", "./geofence.db", "path to fence database")
 	keyFile     = flag.String("key", "", "path to private key file (hex encoded)")
+	encKeyFile  = flag.String("key-file", "", "path to passphrase-encrypted private key file (default private.key.enc if present)")
+	passFD      = flag.Int("passphrase-fd", -1, "read the key file passphrase from this file descriptor instead of "+passphraseEnv+" or a prompt")
+	signerSock  = flag.String("signer-socket", "", "Unix socket of an external signing service holding the key")
+	signerCmd   = flag.String("signer-command", "", "external signing command speaking the signer protocol on stdin/stdout")
 	keyID       = flag.String("key-id", "", "key identifier")
 	cdnBase     = flag.String("cdn", "", "CDN base URL")
+	formatVer   = flag.Uint("format-version", 0, "signing and hashing format version (0 = newest, 1 = for clients predating format versions)")
+	requiredSig = flag.Int("required-signatures", 0, "key holders that must sign a manifest before release (default 1)")
+)
+
+const (
+	// passphraseEnv names the environment variable holding the key file
+	// passphrase.
+	passphraseEnv = "GUL_KEY_PASSPHRASE"
+
+	// defaultKeyFile is the encrypted key file written by keys and used if
+	// no key is configured.
+	defaultKeyFile = "private.key.enc"
 )
 
 func main() {
@@ -47,7 +73,23 @@ func main() {
 
 	// Handle commands that don't need config
 	if cmd == "keys" {
-		runKeys()
+		outFile := defaultKeyFile
+		if len(args) >= 2 {
+			outFile = args[1]
+		}
+		runKeys(outFile)
+		return
+	}
+	if cmd == "validate" && len(args) >= 2 {
+		runValidate(nil, args[1])
+		return
+	}
+	if cmd == "cosign" {
+		// Co-signers only need their key, not the publisher setup
+		if len(args) < 2 {
+			log.Fatal("Usage: cosign <manifest.pending.json>")
+		}
+		runCosign(readConfig(), args[1])
 		return
 	}
 
@@ -76,6 +118,8 @@ func main() {
 		runAdd(cfg, args[1])
 	case "publish":
 		runPublish(cfg)
+	case "release":
+		runRelease(cfg)
 	case "list":
 		runList(cfg)
 	case "remove":
@@ -83,6 +127,33 @@ func main() {
 			log.Fatal("Usage: remove <fence-id>")
 		}
 		runRemove(cfg, args[1])
+	case "import-geojson":
+		if len(args) < 2 {
+			log.Fatal("Usage: import-geojson <fences.geojson>")
+		}
+		runImportGeoJSON(cfg, args[1])
+	case "validate":
+		runValidate(cfg, "")
+	case "export-geojson":
+		outFile := ""
+		if len(args) >= 2 {
+			outFile = args[1]
+		}
+		runExportGeoJSON(cfg, outFile)
+	case "issue-unlock":
+		if len(args) < 2 {
+			log.Fatal("Usage: issue-unlock <token.json> [out.json]")
+		}
+		outFile := ""
+		if len(args) >= 3 {
+			outFile = args[2]
+		}
+		runIssueUnlock(cfg, args[1], outFile)
+	case "rotate-keys":
+		if len(args) < 2 {
+			log.Fatal("Usage: rotate-keys <rotation.json>")
+		}
+		runRotateKeys(cfg, args[1])
 	default:
 		log.Fatalf("Unknown command: %s", cmd)
 	}
@@ -101,12 +172,48 @@ func loadConfig() (*config.PublisherConfig, error) {
 		}
 	}
 
+	applyFlags(cfg)
+	return cfg, cfg.Validate()
+}
+
+// readConfig is like loadConfig but does not validate the configuration,
+// for commands that only need the signing key. It exits if the config file
+// cannot be read.
+func readConfig() *config.PublisherConfig {
+	cfg := config.DefaultPublisherConfig()
+
+	if *configFile != "" {
+		data, err := os.ReadFile(*configFile)
+		if err != nil {
+			log.Fatalf("Failed to read config: %v", err)
+		}
+		var mainCfg config.Config
+		if err := json.Unmarshal(data, &mainCfg); err != nil {
+			log.Fatalf("Failed to parse config: %v", err)
+		}
+		if mainCfg.Publisher != nil {
+			cfg = mainCfg.Publisher
+		}
+	}
+
+	applyFlags(cfg)
+	return cfg
+}
+
+// applyFlags overrides the configuration with CLI flags and falls back to
+// private.key.enc or private.key if no private key is set.
+func applyFlags(cfg *config.PublisherConfig) {
+
 	// Override with CLI flags
 	if *outputDir != "./output" {
 		cfg.OutputDir = *outputDir
 	}
-	if *keyFile != "" {
+	if *keyFile != "" || *encKeyFile != "" || *signerSock != "" || *signerCmd != "" {
+		// A key given on the command line replaces any in the config
 		cfg.PrivateKeyHex = *keyFile
+		cfg.PrivateKeyFile = *encKeyFile
+		cfg.SignerSocket = *signerSock
+		cfg.SignerCommand = strings.Fields(*signerCmd)
 	}
 	if *keyID != "" {
 		cfg.KeyID = *keyID
@@ -114,15 +221,64 @@ func loadConfig() (*config.PublisherConfig, error) {
 	if *cdnBase != "" {
 		cfg.CDNBaseURL = *cdnBase
 	}
+	if *formatVer != 0 {
+		cfg.FormatVersion = uint32(*formatVer)
+	}
+	if *requiredSig != 0 {
+		cfg.RequiredSignatures = *requiredSig
+	}
 
-	// If no private key provided, try to read from file
-	if cfg.PrivateKeyHex == "" {
-		if keyData, err := os.ReadFile("private.key"); err == nil {
+	// If no private key provided, try the default key files
+	if cfg.PrivateKeyHex == "" && cfg.PrivateKeyFile == "" && cfg.SignerSocket == "" && len(cfg.SignerCommand) == 0 {
+		if _, err := os.Stat(defaultKeyFile); err == nil {
+			cfg.PrivateKeyFile = defaultKeyFile
+		} else if keyData, err := os.ReadFile("private.key"); err == nil {
 			cfg.PrivateKeyHex = string(keyData)
 		}
 	}
+	cfg.Passphrase = func() ([]byte, error) { return readPassphrase(false) }
+}
 
-	return cfg, cfg.Validate()
+// readPassphrase reads the key file passphrase from -passphrase-fd, the
+// GUL_KEY_PASSPHRASE environment variable or, on a terminal, a prompt
+// without echo. With confirm set, a prompted passphrase is asked for twice.
+func readPassphrase(confirm bool) ([]byte, error) {
+	if *passFD >= 0 {
+		f := os.NewFile(uintptr(*passFD), "passphrase")
+		line, err := bufio.NewReader(f).ReadBytes('\n')
+		if err != nil && !(errors.Is(err, io.EOF) && len(line) > 0) {
+			return nil, fmt.Errorf("failed to read passphrase from fd %d: %w", *passFD, err)
+		}
+		return bytes.TrimRight(line, "\r\n"), nil
+	}
+	if passphrase := os.Getenv(passphraseEnv); passphrase != "" {
+		return []byte(passphrase), nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, fmt.Errorf("no passphrase: set %s, use -passphrase-fd or run in a terminal", passphraseEnv)
+	}
+	fmt.Fprint(os.Stderr, "Key passphrase: ")
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	if confirm {
+		fmt.Fprint(os.Stderr, "Repeat passphrase: ")
+		repeated, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return nil, err
+		}
+		defer clear(repeated)
+		if !bytes.Equal(passphrase, repeated) {
+			clear(passphrase)
+			return nil, fmt.Errorf("passphrases do not match")
+		}
+	}
+	return passphrase, nil
 }
 
 // getStorePath returns the database path.
@@ -143,8 +299,15 @@ func printUsage() {
 	fmt.Println("  add         Add a new fence to the database")
 	fmt.Println("  remove      Remove a fence from the database")
 	fmt.Println("  list        List all fences in the database")
+	fmt.Println("  import-geojson  Import fences from a GeoJSON file (adds or updates)")
+	fmt.Println("  export-geojson  Export all fences to a GeoJSON file (default: stdout)")
+	fmt.Println("  validate    Validate fences in the database or a fence/GeoJSON file")
 	fmt.Println("  publish     Publish an update to the CDN")
-	fmt.Println("  keys        Generate a new key pair")
+	fmt.Println("  cosign      Add a co-signature to a pending manifest")
+	fmt.Println("  release     Release a pending manifest once it has enough signatures")
+	fmt.Println("  issue-unlock  Sign an unlock token for a device (default: stdout)")
+	fmt.Println("  rotate-keys  Sign and publish a key rotation announcement")
+	fmt.Println("  keys        Generate a new key pair into an encrypted key file (default: private.key.enc)")
 	fmt.Println("\nFlags:")
 	flag.PrintDefaults()
 }
@@ -207,6 +370,141 @@ func runRemove(cfg *config.PublisherConfig, fenceID string) {
 	log.Printf("Removed fence %s", fenceID)
 }
 
+func runImportGeoJSON(cfg *config.PublisherConfig, geojsonFile string) {
+	log.Printf("Importing fences from %s...", geojsonFile)
+
+	data, err := os.ReadFile(geojsonFile)
+	if err != nil {
+		log.Fatalf("Failed to read GeoJSON file: %v", err)
+	}
+
+	fences, err := geojson.Decode(data)
+	if err != nil {
+		log.Fatalf("Failed to parse GeoJSON: %v", err)
+	}
+
+	ctx := context.Background()
+	pub, err := publisher.NewPublisher(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to create publisher: %v", err)
+	}
+	defer pub.Close()
+
+	existing, err := pub.ListFences(ctx)
+	if err != nil {
+		log.Fatalf("Failed to list fences: %v", err)
+	}
+	known := make(map[string]bool, len(existing))
+	for _, f := range existing {
+		known[f.ID] = true
+	}
+
+	var added, updated int
+	for i := range fences {
+		fence := &fences[i]
+		if known[fence.ID] {
+			if err := pub.SignAndUpdate(ctx, fence); err != nil {
+				log.Fatalf("Failed to update fence %s: %v", fence.ID, err)
+			}
+			updated++
+		} else {
+			if err := pub.SignAndAdd(ctx, fence); err != nil {
+				log.Fatalf("Failed to add fence %s: %v", fence.ID, err)
+			}
+			known[fence.ID] = true
+			added++
+		}
+	}
+
+	log.Printf("Imported %d fences (%d added, %d updated)", len(fences), added, updated)
+}
+
+func runExportGeoJSON(cfg *config.PublisherConfig, outFile string) {
+	ctx := context.Background()
+	pub, err := publisher.NewPublisher(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to create publisher: %v", err)
+	}
+	defer pub.Close()
+
+	fences, err := pub.ListFences(ctx)
+	if err != nil {
+		log.Fatalf("Failed to list fences: %v", err)
+	}
+
+	fenceValues := make([]geofence.FenceItem, len(fences))
+	for i, f := range fences {
+		fenceValues[i] = *f
+	}
+
+	data, err := geojson.Encode(fenceValues)
+	if err != nil {
+		log.Fatalf("Failed to encode GeoJSON: %v", err)
+	}
+
+	if outFile == "" {
+		os.Stdout.Write(append(data, '\n'))
+		return
+	}
+	if err := os.WriteFile(outFile, data, 0644); err != nil {
+		log.Fatalf("Failed to write GeoJSON file: %v", err)
+	}
+	log.Printf("Exported %d fences to %s", len(fences), outFile)
+}
+
+// runValidate validates the fences of a fence JSON or GeoJSON file, or of
+// the database if fenceFile is empty.
+func runValidate(cfg *config.PublisherConfig, fenceFile string) {
+	var fences []geofence.FenceItem
+
+	if fenceFile == "" {
+		ctx := context.Background()
+		pub, err := publisher.NewPublisher(ctx, cfg)
+		if err != nil {
+			log.Fatalf("Failed to create publisher: %v", err)
+		}
+		defer pub.Close()
+
+		stored, err := pub.ListFences(ctx)
+		if err != nil {
+			log.Fatalf("Failed to list fences: %v", err)
+		}
+		for _, f := range stored {
+			fences = append(fences, *f)
+		}
+	} else {
+		data, err := os.ReadFile(fenceFile)
+		if err != nil {
+			log.Fatalf("Failed to read fence file: %v", err)
+		}
+		if strings.HasSuffix(fenceFile, ".geojson") {
+			if fences, err = geojson.Decode(data); err != nil {
+				log.Fatalf("Failed to parse GeoJSON: %v", err)
+			}
+		} else {
+			var fence geofence.FenceItem
+			if err := json.Unmarshal(data, &fence); err != nil {
+				log.Fatalf("Failed to parse fence: %v", err)
+			}
+			fences = append(fences, fence)
+		}
+	}
+
+	report := validation.ValidateFences(fences)
+
+	fmt.Printf("\n=== Validation Report ===\n")
+	fmt.Printf("Fences: %d, errors: %d, warnings: %d\n\n",
+		report.Fences, len(report.Errors()), len(report.Warnings()))
+	for _, issue := range report.Issues {
+		fmt.Printf("  %-7s %s %s: %s\n", strings.ToUpper(string(issue.Severity)), issue.FenceID, issue.Field, issue.Message)
+	}
+	fmt.Println("=========================")
+
+	if report.HasErrors() {
+		os.Exit(1)
+	}
+}
+
 func runList(cfg *config.PublisherConfig) {
 	ctx := context.Background()
 	pub, err := publisher.NewPublisher(ctx, cfg)
@@ -272,24 +570,132 @@ func runPublish(cfg *config.PublisherConfig) {
 		log.Printf("  Delta: %s (%d bytes)", filepath.Base(result.DeltaPath), result.DeltaSize)
 	}
 	log.Printf("  Manifest: %s", result.ManifestPath)
+	if result.Pending {
+		log.Printf("  Pending %d signatures: run cosign on the manifest, then release", cfg.RequiredSignatures-1)
+	}
+}
+
+func runCosign(cfg *config.PublisherConfig, manifestFile string) {
+	log.Printf("Co-signing manifest %s...", manifestFile)
+
+	manifest, err := publisher.CosignManifest(cfg, manifestFile)
+	if err != nil {
+		log.Fatalf("Failed to co-sign manifest: %v", err)
+	}
+
+	log.Printf("Co-signed manifest version %d (%d signatures)", manifest.Version, len(manifest.AllSignatures()))
+}
+
+func runRelease(cfg *config.PublisherConfig) {
+	log.Printf("Releasing pending manifest in %s...", cfg.OutputDir)
+
+	ctx := context.Background()
+	path, err := publisher.ReleaseManifest(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to release manifest: %v", err)
+	}
+
+	log.Printf("Released manifest: %s", path)
+}
+
+func runIssueUnlock(cfg *config.PublisherConfig, tokenFile, outFile string) {
+	log.Printf("Issuing unlock token from %s...", tokenFile)
+
+	data, err := os.ReadFile(tokenFile)
+	if err != nil {
+		log.Fatalf("Failed to read token file: %v", err)
+	}
+
+	var token geofence.UnlockToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		log.Fatalf("Failed to parse token: %v", err)
+	}
+
+	ctx := context.Background()
+	pub, err := publisher.NewPublisher(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to create publisher: %v", err)
+	}
+	defer pub.Close()
+
+	if err := pub.IssueUnlock(ctx, &token); err != nil {
+		log.Fatalf("Failed to issue unlock token: %v", err)
+	}
+
+	out, err := json.MarshalIndent(&token, "", "  ")
+	if err != nil {
+		log.Fatalf("Failed to encode token: %v", err)
+	}
+	if outFile == "" {
+		os.Stdout.Write(append(out, '\n'))
+		return
+	}
+	if err := os.WriteFile(outFile, out, 0644); err != nil {
+		log.Fatalf("Failed to write token: %v", err)
+	}
+
+	log.Printf("Issued unlock token %s for device %s, valid %s to %s",
+		token.ID, token.DeviceID,
+		time.Unix(token.NotBefore, 0).UTC().Format(time.RFC3339),
+		time.Unix(token.NotAfter, 0).UTC().Format(time.RFC3339))
 }
 
-func runKeys() {
+func runRotateKeys(cfg *config.PublisherConfig, rotationFile string) {
+	log.Printf("Announcing key rotation from %s...", rotationFile)
+
+	data, err := os.ReadFile(rotationFile)
+	if err != nil {
+		log.Fatalf("Failed to read rotation file: %v", err)
+	}
+
+	var rot crypto.KeyRotation
+	if err := json.Unmarshal(data, &rot); err != nil {
+		log.Fatalf("Failed to parse rotation: %v", err)
+	}
+
+	ctx := context.Background()
+	pub, err := publisher.NewPublisher(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to create publisher: %v", err)
+	}
+	defer pub.Close()
+
+	path, err := pub.AnnounceKeyRotation(&rot)
+	if err != nil {
+		log.Fatalf("Failed to announce key rotation: %v", err)
+	}
+
+	log.Printf("Key rotation %d: %d keys added, %d revoked", rot.Sequence, len(rot.Add), len(rot.Revoke))
+	log.Printf("  Announcement: %s", path)
+}
+
+func runKeys(outFile string) {
 	log.Println("Generating new Ed25519 key pair...")
 
 	keyPair, err := crypto.GenerateKeyPair()
 	if err != nil {
 		log.Fatalf("Failed to generate key pair: %v", err)
 	}
+	defer clear(keyPair.PrivateKey)
+
+	passphrase, err := readPassphrase(true)
+	if err != nil {
+		log.Fatalf("Failed to read passphrase: %v", err)
+	}
+	defer clear(passphrase)
+
+	// Only the encrypted key is written; the plaintext stays in memory
+	if _, err := crypto.WriteEncryptedKeyFile(outFile, keyPair.PrivateKey, passphrase); err != nil {
+		log.Fatalf("Failed to write key file: %v", err)
+	}
 
 	fmt.Println("\nGenerated key pair:")
 	fmt.Printf("Key ID: %s\n", keyPair.KeyID)
 	fmt.Printf("Public Key: %s\n", crypto.MarshalPublicKeyHex(keyPair.PublicKey))
-	fmt.Printf("Private Key: %s\n", crypto.MarshalPrivateKeyHex(keyPair.PrivateKey))
+	fmt.Printf("Encrypted Private Key: %s\n", outFile)
 
-	fmt.Println("\nIMPORTANT: Store the private key securely!")
-	fmt.Println("Never share the private key.")
-	fmt.Println("\nTo save keys to files:")
+	fmt.Println("\nIMPORTANT: Back up the key file and keep the passphrase secret!")
+	fmt.Println("Without both, the key cannot be recovered.")
+	fmt.Println("\nTo save the public key to a file:")
 	fmt.Printf("  echo '%s' > public.key\n", crypto.MarshalPublicKeyHex(keyPair.PublicKey))
-	fmt.Printf("  echo '%s' > private.key\n", crypto.MarshalPrivateKeyHex(keyPair.PrivateKey))
 }
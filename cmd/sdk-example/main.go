@@ -138,12 +138,12 @@ func (u *Updater) StartAutoSync(stop chan struct{}) {
 // Check checks if a location is allowed for flight.
 func (u *Updater) Check(lat, lon float64) (bool, *geofence.FenceItem) {
 	ctx := context.Background()
-	allowed, restriction, err := u.syncer.Check(ctx, lat, lon)
+	ev, err := u.syncer.Check(ctx, lat, lon)
 	if err != nil {
 		log.Printf("Check error: %v", err)
 		return true, nil
 	}
-	return allowed, restriction
+	return ev.Allowed, ev.Restriction
 }
 
 // LoadFences loads fences from a JSON file (for testing).
@@ -177,20 +177,32 @@ func (u *Updater) PrintMatchingFences(lat, lon float64) {
 
 	fmt.Printf("\n=== Fence Check for (%.6f, %.6f) ===\n", lat, lon)
 
-	allowed, restriction, err := u.syncer.Check(ctx, lat, lon)
+	ev, err := u.syncer.Check(ctx, lat, lon)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		return
 	}
 
-	if allowed {
+	if ev.Allowed {
 		fmt.Println("Status: ALLOWED")
 	} else {
 		fmt.Println("Status: NOT ALLOWED")
-		if restriction != nil {
-			fmt.Printf("Reason: %s - %s\n", restriction.Name, restriction.Description)
+		if ev.Restriction != nil {
+			fmt.Printf("Reason: %s - %s\n", ev.Restriction.Name, ev.Restriction.Description)
 		}
 	}
+	for _, c := range ev.Ceilings {
+		fmt.Printf("Ceiling: %.0f m %s\n", c.Meters, c.Reference)
+	}
+	for _, f := range ev.Floors {
+		fmt.Printf("Floor: %.0f m %s\n", f.Meters, f.Reference)
+	}
+	if ev.SpeedLimit > 0 {
+		fmt.Printf("Speed limit: %d m/s\n", ev.SpeedLimit)
+	}
+	for _, c := range ev.Constraints {
+		fmt.Printf("  [%s] %s (priority %d)\n", c.Reason, c.Fence.Name, c.Fence.Priority)
+	}
 
 	fmt.Println("================================")
 }
@@ -3,6 +3,9 @@ module github.com/iannil/geofence-updater-lite
 go 1.24.0
 
 require (
+	github.com/klauspost/compress v1.18.0
+	golang.org/x/crypto v0.43.0
+	golang.org/x/term v0.36.0
 	google.golang.org/protobuf v1.36.11
 	modernc.org/sqlite v1.44.1
 )
@@ -0,0 +1,51 @@
+package testutil
+
+import (
+	"sync"
+
+	"github.com/iannil/geofence-updater-lite/pkg/crypto"
+)
+
+// Signer is a crypto.Signer for tests. It signs with a generated key,
+// records what it signed, and fails with Err if set.
+type Signer struct {
+	// Err, if set, is returned by Sign instead of a signature.
+	Err error
+
+	key      *crypto.KeyPair
+	mu       sync.Mutex
+	messages [][]byte
+}
+
+// NewSigner creates a test signer with a new key, or panics.
+func NewSigner() *Signer {
+	kp, err := crypto.GenerateKeyPair()
+	if err != nil {
+		panic(err)
+	}
+	return &Signer{key: kp}
+}
+
+// Sign records message and signs it, or returns s.Err.
+func (s *Signer) Sign(message []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	s.messages = append(s.messages, append([]byte(nil), message...))
+	return s.key.Sign(message)
+}
+
+// PublicKey returns the signer's public key.
+func (s *Signer) PublicKey() []byte { return s.key.PublicKey }
+
+// KeyID returns the signer's key ID.
+func (s *Signer) KeyID() string { return s.key.KeyID }
+
+// Messages returns the messages signed so far.
+func (s *Signer) Messages() [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]byte(nil), s.messages...)
+}
@@ -8,6 +8,8 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"slices"
+	"strings"
 
 	"github.com/iannil/geofence-updater-lite/pkg/converter"
 	"github.com/iannil/geofence-updater-lite/pkg/geofence"
@@ -25,11 +27,13 @@ type DeltaFile struct {
 	DiffHash    []byte // SHA-256 of diff data
 }
 
-// Diff creates a binary diff between two fence collections using Protobuf serialization.
+// Diff creates a binary diff between two fence collections using Protobuf
+// serialization. Both collections are diffed in order of fence ID, so the
+// order they are listed in does not matter.
 func Diff(oldFences, newFences []geofence.FenceItem) (*DeltaFile, error) {
 	// Convert to Protobuf and serialize
-	oldCollection := &geofence.FenceCollection{Items: oldFences}
-	newCollection := &geofence.FenceCollection{Items: newFences}
+	oldCollection := &geofence.FenceCollection{Items: sortedByID(oldFences)}
+	newCollection := &geofence.FenceCollection{Items: sortedByID(newFences)}
 
 	oldData, err := proto.Marshal(converter.FenceCollectionToProto(oldCollection))
 	if err != nil {
@@ -60,44 +64,6 @@ func Diff(oldFences, newFences []geofence.FenceItem) (*DeltaFile, error) {
 	}, nil
 }
 
-// computeDiff computes a simple binary diff between two byte slices.
-// For a production system, this would use bsdiff for better compression.
-func computeDiff(oldData, newData []byte) ([]byte, error) {
-	// For now, use a simple diff format:
-	// - If data is similar (small change), encode as operations
-	// - Otherwise, just return the new data
-
-	// Simple heuristic: if size difference is small, use operations
-	const threshold = 0.5 // If new data is >50% different, just return it
-
-	if len(newData) < len(oldData) {
-		// New data is smaller, just return it
-		return newData, nil
-	}
-
-	if float64(len(newData)-len(oldData))/float64(len(oldData)) > threshold {
-		// Too different, just return new data
-		return newData, nil
-	}
-
-	// For similar data, compute a simple delta
-	// Find common prefix and suffix
-	prefixLen := commonPrefixLen(oldData, newData)
-	suffixLen := commonSuffixLen(oldData[prefixLen:], newData[prefixLen:])
-
-	// Build delta: [prefixLen:4][suffixLen:4][middle_data]
-	var delta bytes.Buffer
-	binary.Write(&delta, binary.LittleEndian, uint32(prefixLen))
-	binary.Write(&delta, binary.LittleEndian, uint32(suffixLen))
-
-	// Write the changed middle portion
-	middleStart := prefixLen
-	middleEnd := len(newData) - suffixLen
-	delta.Write(newData[middleStart:middleEnd])
-
-	return delta.Bytes(), nil
-}
-
 // commonPrefixLen returns the length of the common prefix of two byte slices.
 func commonPrefixLen(a, b []byte) int {
 	maxLen := len(a)
@@ -130,45 +96,11 @@ func commonSuffixLen(a, b []byte) int {
 	return maxLen
 }
 
-// Patch applies a binary diff to old data to produce new data.
-func Patch(oldData []byte, diffData []byte) ([]byte, error) {
-	// Check if this is our delta format or just raw data
-	if len(diffData) < 8 {
-		// Too small for delta format, assume it's raw data
-		return diffData, nil
-	}
-
-	// Read prefix and suffix lengths
-	prefixLen := binary.LittleEndian.Uint32(diffData[0:4])
-	suffixLen := binary.LittleEndian.Uint32(diffData[4:8])
-
-	// Validate lengths
-	if prefixLen > uint32(len(oldData)) || suffixLen > uint32(len(oldData)) {
-		// Invalid delta, return as-is
-		return diffData, nil
-	}
-
-	// Apply patch
-	totalLen := int(prefixLen) + len(diffData[8:]) + int(suffixLen)
-	if totalLen > 10*1024*1024 { // Sanity check: max 10MB
-		return diffData, nil
-	}
-
-	// Build result: prefix + new_middle + suffix
-	result := make([]byte, 0, totalLen)
-	result = append(result, oldData[:prefixLen]...)
-	result = append(result, diffData[8:]...)
-	if suffixLen > 0 && int(prefixLen)+int(suffixLen) <= len(oldData) {
-		result = append(result, oldData[len(oldData)-int(suffixLen):]...)
-	}
-
-	return result, nil
-}
-
-// PatchFences applies a binary diff to old fences to produce new fences.
+// PatchFences applies a binary diff to old fences to produce new fences,
+// in order of fence ID.
 func PatchFences(oldFences []geofence.FenceItem, delta *DeltaFile) ([]geofence.FenceItem, error) {
 	// Serialize old fences using Protobuf
-	oldCollection := &geofence.FenceCollection{Items: oldFences}
+	oldCollection := &geofence.FenceCollection{Items: sortedByID(oldFences)}
 	oldData, err := proto.Marshal(converter.FenceCollectionToProto(oldCollection))
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal old fences: %w", err)
@@ -194,7 +126,10 @@ func PatchFences(oldFences []geofence.FenceItem, delta *DeltaFile) ([]geofence.F
 		return nil, fmt.Errorf("failed to unmarshal new fences: %w", err)
 	}
 
-	collection := converter.FenceCollectionFromProto(&pbCollection)
+	collection, err := converter.FenceCollectionFromProto(&pbCollection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert new fences: %w", err)
+	}
 	if collection == nil {
 		return nil, fmt.Errorf("failed to convert protobuf collection")
 	}
@@ -202,6 +137,13 @@ func PatchFences(oldFences []geofence.FenceItem, delta *DeltaFile) ([]geofence.F
 	return collection.Items, nil
 }
 
+// sortedByID returns a copy of fences sorted by ID.
+func sortedByID(fences []geofence.FenceItem) []geofence.FenceItem {
+	sorted := slices.Clone(fences)
+	slices.SortFunc(sorted, func(a, b geofence.FenceItem) int { return strings.Compare(a.ID, b.ID) })
+	return sorted
+}
+
 // ComputeDeltaSize estimates the size of a delta between two fence collections.
 func ComputeDeltaSize(oldFences, newFences []geofence.FenceItem) (int, error) {
 	added, removed, updated := computeDeltaStats(oldFences, newFences)
@@ -0,0 +1,446 @@
+package binarydiff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+// Diff data is a versioned container of copy and insert instructions that
+// rebuild the new data from the old:
+//
+//	magic       "GULB"
+//	version     1 byte, diffFormatVersion
+//	old size    uvarint
+//	new size    uvarint
+//	old CRC     4 bytes, CRC-32C of the old data, little endian
+//	new CRC     4 bytes, CRC-32C of the new data, little endian
+//	instructions, each starting with an opcode byte:
+//	  opCopy    varint offset relative to the end of the previous copy,
+//	            uvarint length: copy bytes from the old data
+//	  opInsert  uvarint length, then the bytes: insert literal bytes
+//	  opEnd     end of the instructions; nothing may follow
+//
+// Copies are found by matching the new data against a suffix array of the
+// old data, as bsdiff does, so a change anywhere in the data costs only the
+// changed bytes and a few bytes of instructions.
+const (
+	diffMagic         = "GULB"
+	diffFormatVersion = 1
+
+	opEnd    = 0
+	opCopy   = 1
+	opInsert = 2
+
+	// minCopyLen is the shortest match worth a copy instruction over
+	// inserting the bytes.
+	minCopyLen = 8
+
+	// maxPatchSize bounds the data Patch produces, so a corrupt size
+	// cannot make it allocate without limit.
+	maxPatchSize = 64 << 20
+)
+
+var (
+	// ErrCorruptDiff is returned by Patch when diff data is malformed.
+	ErrCorruptDiff = errors.New("corrupt diff data")
+
+	// ErrBaseMismatch is returned by Patch when diff data was computed
+	// from different old data than it is applied to.
+	ErrBaseMismatch = errors.New("diff does not apply to this data")
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// computeDiff computes the diff data that rebuilds newData from oldData.
+func computeDiff(oldData, newData []byte) ([]byte, error) {
+	if len(newData) > maxPatchSize {
+		return nil, fmt.Errorf("new data is %d bytes, more than the %d supported", len(newData), maxPatchSize)
+	}
+
+	var out bytes.Buffer
+	out.WriteString(diffMagic)
+	out.WriteByte(diffFormatVersion)
+	out.Write(binary.AppendUvarint(nil, uint64(len(oldData))))
+	out.Write(binary.AppendUvarint(nil, uint64(len(newData))))
+	out.Write(binary.LittleEndian.AppendUint32(nil, crc32.Checksum(oldData, crcTable)))
+	out.Write(binary.LittleEndian.AppendUint32(nil, crc32.Checksum(newData, crcTable)))
+
+	e := &encoder{out: &out}
+
+	// The unchanged head and tail need no searching
+	prefixLen := commonPrefixLen(oldData, newData)
+	suffixLen := commonSuffixLen(oldData[prefixLen:], newData[prefixLen:])
+	e.copy(0, prefixLen)
+
+	middle := newData[prefixLen : len(newData)-suffixLen]
+	if len(middle) > 0 {
+		sa := suffixArray(oldData)
+		for pos := 0; pos < len(middle); {
+			offset, n := longestMatch(sa, oldData, middle[pos:])
+			if n < minCopyLen {
+				e.insert(middle[pos])
+				pos++
+				continue
+			}
+			e.copy(offset, n)
+			pos += n
+		}
+	}
+
+	e.copy(len(oldData)-suffixLen, suffixLen)
+	e.flush()
+	out.WriteByte(opEnd)
+	return out.Bytes(), nil
+}
+
+// encoder writes instructions, merging adjacent copies and inserts.
+type encoder struct {
+	out       *bytes.Buffer
+	lastEnd   int // End of the previous copy written
+	copyStart int // Pending copy, of copyLen bytes
+	copyLen   int
+	pending   []byte // Pending insert
+}
+
+func (e *encoder) copy(offset, n int) {
+	if n == 0 {
+		return
+	}
+	if len(e.pending) > 0 {
+		e.flush()
+	}
+	if e.copyLen > 0 && e.copyStart+e.copyLen == offset {
+		e.copyLen += n
+		return
+	}
+	e.flush()
+	e.copyStart, e.copyLen = offset, n
+}
+
+func (e *encoder) insert(b byte) {
+	if e.copyLen > 0 {
+		e.flush()
+	}
+	e.pending = append(e.pending, b)
+}
+
+func (e *encoder) flush() {
+	if e.copyLen > 0 {
+		e.out.WriteByte(opCopy)
+		e.out.Write(binary.AppendVarint(nil, int64(e.copyStart-e.lastEnd)))
+		e.out.Write(binary.AppendUvarint(nil, uint64(e.copyLen)))
+		e.lastEnd = e.copyStart + e.copyLen
+		e.copyLen = 0
+	}
+	if len(e.pending) > 0 {
+		e.out.WriteByte(opInsert)
+		e.out.Write(binary.AppendUvarint(nil, uint64(len(e.pending))))
+		e.out.Write(e.pending)
+		e.pending = e.pending[:0]
+	}
+}
+
+// Patch applies diff data computed by Diff to old data to produce new data.
+// It returns an error wrapping ErrCorruptDiff if the diff data is malformed
+// and ErrBaseMismatch if it was computed from different old data.
+func Patch(oldData []byte, diffData []byte) ([]byte, error) {
+	r := &diffReader{data: diffData}
+
+	if !bytes.HasPrefix(diffData, []byte(diffMagic)) {
+		return nil, fmt.Errorf("%w: bad magic", ErrCorruptDiff)
+	}
+	r.pos = len(diffMagic)
+	version, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	if version != diffFormatVersion {
+		return nil, fmt.Errorf("unsupported diff format version %d", version)
+	}
+	oldSize, err := r.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	newSize, err := r.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	oldCRC, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+	newCRC, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+
+	if oldSize != uint64(len(oldData)) || oldCRC != crc32.Checksum(oldData, crcTable) {
+		return nil, ErrBaseMismatch
+	}
+	if newSize > maxPatchSize {
+		return nil, fmt.Errorf("%w: new size %d exceeds %d", ErrCorruptDiff, newSize, maxPatchSize)
+	}
+
+	result := make([]byte, 0, newSize)
+	lastEnd := int64(0)
+	for {
+		op, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case opEnd:
+			if r.pos != len(diffData) {
+				return nil, fmt.Errorf("%w: %d bytes after the end", ErrCorruptDiff, len(diffData)-r.pos)
+			}
+			if uint64(len(result)) != newSize || crc32.Checksum(result, crcTable) != newCRC {
+				return nil, fmt.Errorf("%w: result does not match the new data", ErrCorruptDiff)
+			}
+			return result, nil
+
+		case opCopy:
+			rel, err := r.varint()
+			if err != nil {
+				return nil, err
+			}
+			n, err := r.uvarint()
+			if err != nil {
+				return nil, err
+			}
+			start := lastEnd + rel
+			if start < 0 || start > int64(len(oldData)) || n > uint64(int64(len(oldData))-start) {
+				return nil, fmt.Errorf("%w: copy outside the old data", ErrCorruptDiff)
+			}
+			if n > newSize-uint64(len(result)) {
+				return nil, fmt.Errorf("%w: copy past the new size", ErrCorruptDiff)
+			}
+			result = append(result, oldData[start:start+int64(n)]...)
+			lastEnd = start + int64(n)
+
+		case opInsert:
+			n, err := r.uvarint()
+			if err != nil {
+				return nil, err
+			}
+			if n > newSize-uint64(len(result)) || n > uint64(len(diffData)-r.pos) {
+				return nil, fmt.Errorf("%w: insert past the new size", ErrCorruptDiff)
+			}
+			result = append(result, diffData[r.pos:r.pos+int(n)]...)
+			r.pos += int(n)
+
+		default:
+			return nil, fmt.Errorf("%w: unknown opcode %d", ErrCorruptDiff, op)
+		}
+	}
+}
+
+// diffReader reads the fields of diff data, failing with ErrCorruptDiff
+// when it is truncated.
+type diffReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *diffReader) byte() (byte, error) {
+	if r.pos >= len(r.data) {
+		return 0, fmt.Errorf("%w: truncated", ErrCorruptDiff)
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b, nil
+}
+
+func (r *diffReader) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		return 0, fmt.Errorf("%w: bad varint", ErrCorruptDiff)
+	}
+	r.pos += n
+	return v, nil
+}
+
+func (r *diffReader) varint() (int64, error) {
+	v, n := binary.Varint(r.data[r.pos:])
+	if n <= 0 {
+		return 0, fmt.Errorf("%w: bad varint", ErrCorruptDiff)
+	}
+	r.pos += n
+	return v, nil
+}
+
+func (r *diffReader) uint32() (uint32, error) {
+	if len(r.data)-r.pos < 4 {
+		return 0, fmt.Errorf("%w: truncated", ErrCorruptDiff)
+	}
+	v := binary.LittleEndian.Uint32(r.data[r.pos:])
+	r.pos += 4
+	return v, nil
+}
+
+// longestMatch returns the offset and length of the longest prefix of
+// target found in data, using sa, the suffix array of data.
+func longestMatch(sa []int, data, target []byte) (offset, n int) {
+	// Binary search for the suffixes target sorts between; the longest
+	// match is with one of them
+	lo, hi := 0, len(sa)-1
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		if bytes.Compare(data[sa[mid]:], target) < 0 {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	for _, i := range []int{lo, hi} {
+		if l := commonPrefixLen(data[sa[i]:], target); l > n {
+			offset, n = sa[i], l
+		}
+	}
+	return offset, n
+}
+
+// suffixArray returns the start offsets of the suffixes of data, including
+// the empty one, in sorted order. It uses the Larsson-Sadakane qsufsort
+// algorithm bsdiff uses, in O(n log n) time.
+func suffixArray(data []byte) []int {
+	n := len(data)
+	sa := make([]int, n+1) // Suffixes; negative entries skip sorted runs
+	rank := make([]int, n+1)
+
+	// Bucket the suffixes by first byte
+	var buckets [256]int
+	for _, c := range data {
+		buckets[c]++
+	}
+	for i := 1; i < 256; i++ {
+		buckets[i] += buckets[i-1]
+	}
+	for i := 255; i > 0; i-- {
+		buckets[i] = buckets[i-1]
+	}
+	buckets[0] = 0
+	for i, c := range data {
+		buckets[c]++
+		sa[buckets[c]] = i
+	}
+	sa[0] = n
+	for i, c := range data {
+		rank[i] = buckets[c]
+	}
+	rank[n] = 0
+	for i := 1; i < 256; i++ {
+		if buckets[i] == buckets[i-1]+1 {
+			sa[buckets[i]] = -1
+		}
+	}
+	sa[0] = -1
+
+	// Refine the groups by the ranks h bytes on, doubling h each pass
+	for h := 1; sa[0] != -(n + 1); h += h {
+		runLen := 0
+		i := 0
+		for i < n+1 {
+			if sa[i] < 0 {
+				runLen -= sa[i]
+				i -= sa[i]
+				continue
+			}
+			if runLen != 0 {
+				sa[i-runLen] = -runLen
+			}
+			groupLen := rank[sa[i]] + 1 - i
+			split(sa, rank, i, groupLen, h)
+			i += groupLen
+			runLen = 0
+		}
+		if runLen != 0 {
+			sa[i-runLen] = -runLen
+		}
+	}
+
+	for i := 0; i < n+1; i++ {
+		sa[rank[i]] = i
+	}
+	return sa
+}
+
+// split sorts the group of suffixes sa[start:start+n] by the rank of the
+// suffix h bytes on, a ternary quicksort splitting it into new groups.
+func split(sa, rank []int, start, n, h int) {
+	if n < 16 {
+		// Selection sort for small groups
+		for k := start; k < start+n; {
+			j := 1
+			x := rank[sa[k]+h]
+			for i := 1; k+i < start+n; i++ {
+				if rank[sa[k+i]+h] < x {
+					x = rank[sa[k+i]+h]
+					j = 0
+				}
+				if rank[sa[k+i]+h] == x {
+					sa[k+j], sa[k+i] = sa[k+i], sa[k+j]
+					j++
+				}
+			}
+			for i := 0; i < j; i++ {
+				rank[sa[k+i]] = k + j - 1
+			}
+			if j == 1 {
+				sa[k] = -1
+			}
+			k += j
+		}
+		return
+	}
+
+	x := rank[sa[start+n/2]+h]
+	jj, kk := 0, 0
+	for i := start; i < start+n; i++ {
+		if rank[sa[i]+h] < x {
+			jj++
+		}
+		if rank[sa[i]+h] == x {
+			kk++
+		}
+	}
+	jj += start
+	kk += jj
+
+	i, j, k := start, 0, 0
+	for i < jj {
+		switch r := rank[sa[i]+h]; {
+		case r < x:
+			i++
+		case r == x:
+			sa[i], sa[jj+j] = sa[jj+j], sa[i]
+			j++
+		default:
+			sa[i], sa[kk+k] = sa[kk+k], sa[i]
+			k++
+		}
+	}
+	for jj+j < kk {
+		if rank[sa[jj+j]+h] == x {
+			j++
+		} else {
+			sa[jj+j], sa[kk+k] = sa[kk+k], sa[jj+j]
+			k++
+		}
+	}
+
+	if jj > start {
+		split(sa, rank, start, jj-start, h)
+	}
+	for i := 0; i < kk-jj; i++ {
+		rank[sa[jj+i]] = kk - 1
+	}
+	if jj == kk-1 {
+		sa[jj] = -1
+	}
+	if start+n > kk {
+		split(sa, rank, kk, start+n-kk, h)
+	}
+}
@@ -0,0 +1,282 @@
+package binarydiff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/iannil/geofence-updater-lite/pkg/converter"
+	"github.com/iannil/geofence-updater-lite/pkg/geofence"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestSuffixArray(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	inputs := [][]byte{
+		{},
+		{7},
+		[]byte("banana"),
+		[]byte("mississippi"),
+		bytes.Repeat([]byte("a"), 100),
+		bytes.Repeat([]byte("abcab"), 40),
+	}
+	for i := 0; i < 20; i++ {
+		data := make([]byte, rng.Intn(300))
+		for j := range data {
+			data[j] = byte(rng.Intn(4)) // Small alphabet, many repeats
+		}
+		inputs = append(inputs, data)
+	}
+
+	for _, data := range inputs {
+		want := make([]int, len(data)+1)
+		for i := range want {
+			want[i] = i
+		}
+		slices.SortFunc(want, func(a, b int) int { return bytes.Compare(data[a:], data[b:]) })
+
+		if got := suffixArray(data); !slices.Equal(got, want) {
+			t.Errorf("suffixArray(%q) = %v, want %v", data, got, want)
+		}
+	}
+}
+
+func TestPatch_RoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	random := func(n int) []byte {
+		b := make([]byte, n)
+		rng.Read(b)
+		return b
+	}
+	base := random(4096)
+	join := func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+
+	tests := []struct {
+		name     string
+		old, new []byte
+	}{
+		{"empty", nil, nil},
+		{"from empty", nil, base},
+		{"to empty", base, nil},
+		{"identical", base, base},
+		{"middle change", base, join(base[:2000], []byte("changed"), base[2007:])},
+		{"insert", base, join(base[:1000], random(100), base[1000:])},
+		{"delete", base, base[:3000]},
+		{"moved blocks", base, join(base[3000:], base[:1000], base[1000:3000])},
+		{"unrelated", base, random(2048)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff, err := computeDiff(tt.old, tt.new)
+			if err != nil {
+				t.Fatalf("computeDiff failed: %v", err)
+			}
+			got, err := Patch(tt.old, diff)
+			if err != nil {
+				t.Fatalf("Patch failed: %v", err)
+			}
+			if !bytes.Equal(got, tt.new) {
+				t.Fatalf("Patch produced %d bytes differing from the new data", len(got))
+			}
+		})
+	}
+
+	// Changes in the middle cost about the changed bytes
+	changed := join(base[:2000], []byte("changed"), base[2007:])
+	diff, err := computeDiff(base, changed)
+	if err != nil {
+		t.Fatalf("computeDiff failed: %v", err)
+	}
+	if len(diff) > 64 {
+		t.Errorf("diff of a 7-byte change is %d bytes", len(diff))
+	}
+	moved := join(base[3000:], base[:1000], base[1000:3000])
+	diff, err = computeDiff(base, moved)
+	if err != nil {
+		t.Fatalf("computeDiff failed: %v", err)
+	}
+	if len(diff) > 64 {
+		t.Errorf("diff of moved blocks is %d bytes", len(diff))
+	}
+}
+
+func TestPatch_Corrupt(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	oldData := make([]byte, 1024)
+	rng.Read(oldData)
+	newData := bytes.Clone(oldData)
+	copy(newData[500:], "changed bytes")
+	diff, err := computeDiff(oldData, newData)
+	if err != nil {
+		t.Fatalf("computeDiff failed: %v", err)
+	}
+
+	otherBase := bytes.Clone(oldData)
+	otherBase[10]++
+	if _, err := Patch(otherBase, diff); !errors.Is(err, ErrBaseMismatch) {
+		t.Errorf("Patch() on another base error = %v, want %v", err, ErrBaseMismatch)
+	}
+
+	// Magic, version, sizes and CRCs
+	header := len(diffMagic) + 1 + 2*len(binary.AppendUvarint(nil, 1024)) + 8
+	tests := []struct {
+		name string
+		diff []byte
+	}{
+		{"empty", nil},
+		{"raw data", newData},
+		{"bad magic", append([]byte("XXXX"), diff[4:]...)},
+		{"truncated header", diff[:header-1]},
+		{"truncated", diff[:len(diff)-1]},
+		{"trailing data", append(bytes.Clone(diff), 0)},
+		{"flipped insert byte", func() []byte {
+			d := bytes.Clone(diff)
+			i := bytes.Index(d, []byte("changed bytes"))
+			d[i] ^= 0xFF
+			return d
+		}()},
+		{"copy outside old data", func() []byte {
+			d := append(bytes.Clone(diff[:header]), opCopy)
+			d = binary.AppendVarint(d, 2000)
+			return append(d, 10, opEnd)
+		}()},
+		{"unknown opcode", func() []byte {
+			return append(bytes.Clone(diff[:header]), 9)
+		}()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Patch(oldData, tt.diff); !errors.Is(err, ErrCorruptDiff) {
+				t.Errorf("Patch() error = %v, want %v", err, ErrCorruptDiff)
+			}
+		})
+	}
+
+	bad := bytes.Clone(diff)
+	bad[len(diffMagic)] = diffFormatVersion + 1
+	if _, err := Patch(oldData, bad); err == nil {
+		t.Error("expected error for an unsupported format version")
+	}
+}
+
+// realisticFences returns n polygon fences like those of a city's no-fly
+// zones.
+func realisticFences(n int, rng *rand.Rand) []geofence.FenceItem {
+	fences := make([]geofence.FenceItem, n)
+	for i := range fences {
+		lat, lon := 30+rng.Float64(), 110+rng.Float64()
+		var polygon []geofence.Point
+		for j := 0; j < 8+rng.Intn(16); j++ {
+			polygon = append(polygon, geofence.Point{Latitude: lat + rng.Float64()/100, Longitude: lon + rng.Float64()/100})
+		}
+		signature := make([]byte, 64)
+		rng.Read(signature)
+		fences[i] = geofence.FenceItem{
+			ID:          fmt.Sprintf("zone-%05d", i),
+			Type:        geofence.FenceType(1 + rng.Intn(4)),
+			Priority:    uint32(rng.Intn(100)),
+			MaxAltitude: uint32(rng.Intn(500)),
+			Name:        fmt.Sprintf("Restricted zone %d", i),
+			Geometry:    geofence.Geometry{Polygon: polygon},
+			Signature:   signature,
+			KeyID:       "publisher-key",
+		}
+	}
+	return fences
+}
+
+// changeFences returns a copy of fences with every 100th one updated.
+func changeFences(fences []geofence.FenceItem, rng *rand.Rand) []geofence.FenceItem {
+	changed := slices.Clone(fences)
+	for i := 50; i < len(changed); i += 100 {
+		changed[i].Priority++
+		changed[i].Geometry.Polygon = slices.Clone(changed[i].Geometry.Polygon)
+		changed[i].Geometry.Polygon[0].Latitude += 0.001
+		sig := make([]byte, 64)
+		rng.Read(sig)
+		changed[i].Signature = sig
+	}
+	return changed
+}
+
+func marshalFences(t testing.TB, fences []geofence.FenceItem) []byte {
+	t.Helper()
+	data, err := proto.Marshal(converter.FenceCollectionToProto(&geofence.FenceCollection{Items: sortedByID(fences)}))
+	if err != nil {
+		t.Fatalf("failed to marshal fences: %v", err)
+	}
+	return data
+}
+
+func TestDiff_RealisticFences(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	oldFences := realisticFences(1000, rng)
+	newFences := changeFences(oldFences, rng)
+
+	delta, err := Diff(oldFences, newFences)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	patched, err := PatchFences(oldFences, delta)
+	if err != nil {
+		t.Fatalf("PatchFences failed: %v", err)
+	}
+	if !bytes.Equal(marshalFences(t, patched), marshalFences(t, newFences)) {
+		t.Fatal("PatchFences did not produce the new fences")
+	}
+
+	// 10 of 1000 fences changed: the diff is a small fraction of the data
+	if delta.ToSize == 0 || len(delta.DiffData) > int(delta.ToSize)/20 {
+		t.Errorf("diff is %d bytes for %d bytes of fences", len(delta.DiffData), delta.ToSize)
+	}
+	t.Logf("Diff of %d bytes for %d bytes of fences", len(delta.DiffData), delta.ToSize)
+}
+
+func benchmarkData(b *testing.B, n int) (oldData, newData []byte) {
+	rng := rand.New(rand.NewSource(5))
+	oldFences := realisticFences(n, rng)
+	return marshalFences(b, oldFences), marshalFences(b, changeFences(oldFences, rng))
+}
+
+func BenchmarkDiff(b *testing.B) {
+	for _, n := range []int{100, 1000, 10000} {
+		b.Run(fmt.Sprintf("fences=%d", n), func(b *testing.B) {
+			oldData, newData := benchmarkData(b, n)
+			b.SetBytes(int64(len(newData)))
+			b.ResetTimer()
+			var diff []byte
+			for i := 0; i < b.N; i++ {
+				var err error
+				if diff, err = computeDiff(oldData, newData); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(len(diff)), "diff-bytes")
+		})
+	}
+}
+
+func BenchmarkPatch(b *testing.B) {
+	for _, n := range []int{100, 1000, 10000} {
+		b.Run(fmt.Sprintf("fences=%d", n), func(b *testing.B) {
+			oldData, newData := benchmarkData(b, n)
+			diff, err := computeDiff(oldData, newData)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.SetBytes(int64(len(newData)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := Patch(oldData, diff); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/iannil/geofence-updater-lite/pkg/compression"
+	"github.com/iannil/geofence-updater-lite/pkg/crypto"
+	"github.com/iannil/geofence-updater-lite/pkg/geofence"
+)
+
+// File describes a published file: the size and hash of its contents, and
+// the compressed copies it is also published in.
+type File struct {
+	URL       string
+	Size      uint64
+	Hash      []byte // SHA-256 of the uncompressed file
+	Encodings []geofence.FileEncoding
+}
+
+// ChooseEncoding returns the compressed copy of f in the codec the client
+// prefers most, or nil if it accepts none of them.
+func (c *Client) ChooseEncoding(f File) *geofence.FileEncoding {
+	var best *geofence.FileEncoding
+	bestRank := len(c.acceptEncodings)
+	for i := range f.Encodings {
+		rank := slices.Index(c.acceptEncodings, f.Encodings[i].Codec)
+		if rank >= 0 && rank < bestRank {
+			best, bestRank = &f.Encodings[i], rank
+		}
+	}
+	return best
+}
+
+// TransferSize returns the number of bytes FetchFile downloads for f.
+func (c *Client) TransferSize(f File) uint64 {
+	if enc := c.ChooseEncoding(f); enc != nil {
+		return enc.Size
+	}
+	return f.Size
+}
+
+// FetchFile downloads a published file, as the compressed copy chosen by
+// ChooseEncoding if there is one, and returns its uncompressed contents. A
+// compressed copy is checked against its own hash and decompressed to at
+// most f.Size bytes; the contents are checked against f.Hash.
+func (c *Client) FetchFile(ctx context.Context, f File, fileType string) ([]byte, error) {
+	if f.URL == "" {
+		return nil, fmt.Errorf("empty %s URL", fileType)
+	}
+
+	enc := c.ChooseEncoding(f)
+	if enc == nil {
+		data, err := c.fetchBinary(ctx, resolveURL(c.cdnBaseURL, f.URL), fileType)
+		if err != nil {
+			return nil, err
+		}
+		if len(f.Hash) > 0 && !crypto.VerifyHash(data, f.Hash) {
+			return nil, fmt.Errorf("%s hash verification failed", fileType)
+		}
+		return data, nil
+	}
+
+	maxSize := c.maxDownloadSize
+	if f.Size > 0 {
+		if f.Size > uint64(maxSize) {
+			return nil, fmt.Errorf("%s too large: %d bytes", fileType, f.Size)
+		}
+		maxSize = int64(f.Size)
+	}
+
+	compressed, err := c.fetchBinary(ctx, resolveURL(c.cdnBaseURL, enc.URL), fileType)
+	if err != nil {
+		return nil, err
+	}
+	if len(enc.Hash) > 0 && !crypto.VerifyHash(compressed, enc.Hash) {
+		return nil, fmt.Errorf("compressed %s hash verification failed", fileType)
+	}
+	data, err := compression.Decompress(enc.Codec, compressed, maxSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress %s: %w", fileType, err)
+	}
+	if len(f.Hash) > 0 && !crypto.VerifyHash(data, f.Hash) {
+		return nil, fmt.Errorf("%s hash verification failed", fileType)
+	}
+	return data, nil
+}
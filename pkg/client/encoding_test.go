@@ -0,0 +1,108 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/iannil/geofence-updater-lite/pkg/compression"
+	"github.com/iannil/geofence-updater-lite/pkg/crypto"
+	"github.com/iannil/geofence-updater-lite/pkg/geofence"
+)
+
+func TestFetchFile(t *testing.T) {
+	data := bytes.Repeat([]byte("snapshot "), 500)
+	files := map[string][]byte{"/v1.bin": data}
+	file := File{URL: "/v1.bin", Size: uint64(len(data)), Hash: crypto.ComputeSHA256(data)}
+	for _, codec := range compression.Codecs {
+		compressed, err := compression.Compress(codec, data)
+		if err != nil {
+			t.Fatalf("Compress failed: %v", err)
+		}
+		url := "/v1.bin" + compression.Extension(codec)
+		files[url] = compressed
+		file.Encodings = append(file.Encodings, geofence.FileEncoding{
+			Codec: codec,
+			URL:   url,
+			Size:  uint64(len(compressed)),
+			Hash:  crypto.ComputeSHA256(compressed),
+		})
+	}
+
+	var fetched []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched = append(fetched, r.URL.Path)
+		if files[r.URL.Path] == nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(files[r.URL.Path])
+	}))
+	defer server.Close()
+
+	newClient := func(accept []string) *Client {
+		cfg := testClientConfig(t, server.URL)
+		cfg.AcceptEncodings = accept
+		c, err := NewClient(cfg)
+		if err != nil {
+			t.Fatalf("NewClient failed: %v", err)
+		}
+		return c
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		accept []string
+		want   string
+	}{
+		{"default", nil, "/v1.bin.zst"},
+		{"preference", []string{compression.Gzip, compression.Zstd}, "/v1.bin.gz"},
+		{"uncompressed only", []string{}, "/v1.bin"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newClient(tt.accept)
+			fetched = nil
+			got, err := c.FetchFile(ctx, file, "snapshot")
+			if err != nil {
+				t.Fatalf("FetchFile failed: %v", err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Error("FetchFile did not return the file contents")
+			}
+			if !slices.Equal(fetched, []string{tt.want}) {
+				t.Errorf("fetched %v, want %v", fetched, tt.want)
+			}
+			if size := c.TransferSize(file); size >= file.Size && tt.want != "/v1.bin" {
+				t.Errorf("TransferSize() = %d, want the compressed size", size)
+			}
+		})
+	}
+
+	c := newClient(nil)
+
+	// The compressed file and its contents are both verified
+	tampered := file
+	tampered.Encodings = slices.Clone(file.Encodings)
+	tampered.Encodings[0].Hash = crypto.ComputeSHA256([]byte("other"))
+	if _, err := c.FetchFile(ctx, tampered, "snapshot"); err == nil {
+		t.Error("expected error for a compressed file not matching its hash")
+	}
+	tampered = file
+	tampered.Hash = crypto.ComputeSHA256([]byte("other"))
+	if _, err := c.FetchFile(ctx, tampered, "snapshot"); err == nil {
+		t.Error("expected error for contents not matching the file hash")
+	}
+
+	// Decompression stops at the file size
+	tampered = file
+	tampered.Size = file.Size - 1
+	if _, err := c.FetchFile(ctx, tampered, "snapshot"); !errors.Is(err, compression.ErrTooLarge) {
+		t.Errorf("FetchFile() error = %v, want %v", err, compression.ErrTooLarge)
+	}
+}
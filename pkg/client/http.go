@@ -6,6 +6,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -13,9 +14,11 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"sync"
 	"time"
 
 	"github.com/iannil/geofence-updater-lite/pkg/config"
+	"github.com/iannil/geofence-updater-lite/pkg/converter"
 	"github.com/iannil/geofence-updater-lite/pkg/crypto"
 	"github.com/iannil/geofence-updater-lite/pkg/geofence"
 )
@@ -24,9 +27,16 @@ import (
 type Client struct {
 	httpClient         *http.Client
 	userAgent          string
-	publicKey          []byte
+	keyring            *crypto.Keyring
+	primaryKeyID       string // Key ID of PublicKeyHex, for data without a key ID
 	cdnBaseURL         string
 	insecureSkipVerify bool
+	keyringPath        string
+	sigThreshold       int        // Trusted keys that must sign a manifest
+	rotationMu         sync.Mutex // serializes key rotations and protects rotations
+	rotations          []storedRotation
+	maxDownloadSize    int64
+	acceptEncodings    []string // Codecs of compressed files to download, preferred first
 }
 
 // NewClient creates a new HTTP client for geofence updates.
@@ -35,14 +45,9 @@ func NewClient(cfg *config.ClientConfig) (*Client, error) {
 		return nil, fmt.Errorf("invalid config: %w", err)
 	}
 
-	// Decode public key from hex
-	var publicKey []byte
-	if cfg.PublicKeyHex != "" {
-		var err error
-		publicKey, err = crypto.UnmarshalPublicKeyHex(cfg.PublicKeyHex)
-		if err != nil {
-			return nil, fmt.Errorf("invalid public key: %w", err)
-		}
+	keyring, primaryKeyID, err := newKeyring(cfg)
+	if err != nil {
+		return nil, err
 	}
 
 	// Warn if signature verification is disabled
@@ -50,7 +55,7 @@ func NewClient(cfg *config.ClientConfig) (*Client, error) {
 		log.Printf("[SECURITY WARNING] Signature verification is DISABLED! This is DANGEROUS and should NEVER be used in production.")
 	}
 
-	return &Client{
+	c := &Client{
 		httpClient: &http.Client{
 			Timeout: cfg.HTTPTimeout,
 			Transport: &http.Transport{
@@ -60,10 +65,22 @@ func NewClient(cfg *config.ClientConfig) (*Client, error) {
 			},
 		},
 		userAgent:          cfg.UserAgent,
-		publicKey:          publicKey,
+		keyring:            keyring,
+		primaryKeyID:       primaryKeyID,
 		cdnBaseURL:         cfg.ManifestURL,
 		insecureSkipVerify: cfg.InsecureSkipVerify,
-	}, nil
+		keyringPath:        cfg.KeyringPath,
+		sigThreshold:       cfg.ManifestSignatureThreshold,
+		maxDownloadSize:    cfg.MaxDownloadSize,
+		acceptEncodings:    cfg.AcceptEncodings,
+	}
+
+	// Apply the key rotations accepted in earlier runs
+	if err := c.loadRotations(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
 }
 
 // FetchManifest downloads and verifies the manifest from the remote server.
@@ -115,8 +132,22 @@ func (c *Client) FetchManifest(ctx context.Context) (*geofence.Manifest, error)
 		return nil, fmt.Errorf("failed to parse manifest: %w", err)
 	}
 
-	// Verify manifest signature
-	if err := c.verifyManifestSignature(&manifest, manifestData); err != nil {
+	// Refuse data this client cannot hash or verify
+	if err := converter.CheckFormatVersion(manifest.FormatVersion); err != nil {
+		return nil, err
+	}
+
+	// Verify manifest signature. A key not trusted yet may have been
+	// announced in a key rotation since the last one applied.
+	err = c.verifyManifestSignature(&manifest, manifestData)
+	if errors.Is(err, crypto.ErrUnknownKey) && manifest.KeyID != "" {
+		if refreshErr := c.RefreshKeys(ctx); refreshErr != nil {
+			log.Printf("[Client] Failed to refresh trusted keys: %v", refreshErr)
+		} else {
+			err = c.verifyManifestSignature(&manifest, manifestData)
+		}
+	}
+	if err != nil {
 		return nil, fmt.Errorf("manifest signature verification failed: %w", err)
 	}
 
@@ -131,35 +162,58 @@ func (c *Client) verifyManifestSignature(manifest *geofence.Manifest, _ []byte)
 		return nil
 	}
 
-	// Public key is required for verification
-	if len(c.publicKey) == 0 {
-		return fmt.Errorf("public key not configured: signature verification is required")
+	// A trusted key is required for verification
+	if len(c.keyring.Keys()) == 0 {
+		return fmt.Errorf("no trusted keys configured: signature verification is required")
 	}
 
-	if len(manifest.Signature) == 0 {
+	sigs := manifest.AllSignatures()
+	if len(sigs) == 0 {
 		return fmt.Errorf("manifest has no signature")
 	}
 
 	// Get the canonical data for signing (without signature field)
-	signingData, err := manifest.MarshalBinaryForSigning()
+	signingData, err := converter.ManifestSigningData(manifest)
 	if err != nil {
 		return fmt.Errorf("failed to marshal manifest for verification: %w", err)
 	}
 
-	// Verify the signature
-	if !crypto.Verify(c.publicKey, signingData, manifest.Signature) {
-		return fmt.Errorf("invalid signature")
+	// Verify the signatures against the keys they name; enough distinct
+	// trusted keys must have signed
+	keySigs := make([]crypto.KeySignature, len(sigs))
+	for i, sig := range sigs {
+		keySigs[i] = crypto.KeySignature{KeyID: c.signingKeyID(sig.KeyID), Signature: sig.Signature}
 	}
+	return c.keyring.VerifyThreshold(signingData, keySigs, c.sigThreshold, crypto.RoleSigning, time.Now())
+}
 
-	// Verify KeyID matches if specified
-	if manifest.KeyID != "" {
-		expectedKeyID, err := crypto.PublicKeyToKeyID(c.publicKey)
-		if err != nil {
-			return fmt.Errorf("failed to compute key ID: %w", err)
-		}
-		if expectedKeyID != manifest.KeyID {
-			return fmt.Errorf("key ID mismatch: expected %s, got %s", expectedKeyID, manifest.KeyID)
-		}
+// signingKeyID returns the ID of the key to verify data with: the key ID
+// the data names, or that of the configured public key for data from
+// publishers that do not set key IDs.
+func (c *Client) signingKeyID(keyID string) string {
+	if keyID == "" {
+		return c.primaryKeyID
+	}
+	return keyID
+}
+
+// VerifyUnlockToken verifies the signature of an unlock token against the
+// trusted keys and checks that the token is well-formed. Unlock
+// tokens lift restrictions, so they are never accepted unverified, even
+// with InsecureSkipVerify set.
+func (c *Client) VerifyUnlockToken(token *geofence.UnlockToken) error {
+	if len(c.keyring.Keys()) == 0 {
+		return fmt.Errorf("no trusted keys configured: cannot verify unlock token %s", token.ID)
+	}
+	if len(token.Signature) == 0 {
+		return fmt.Errorf("unlock token %s has no signature", token.ID)
+	}
+	if err := token.Validate(); err != nil {
+		return fmt.Errorf("invalid unlock token %s: %w", token.ID, err)
+	}
+
+	if err := c.keyring.VerifySigned(token, token.Signature, c.signingKeyID(token.KeyID), crypto.RoleSigning, time.Now()); err != nil {
+		return fmt.Errorf("unlock token %s: %w", token.ID, err)
 	}
 
 	return nil
@@ -189,6 +243,32 @@ func (c *Client) FetchDelta(ctx context.Context, deltaURL string) ([]byte, error
 	return c.fetchBinary(ctx, fullURL, "delta")
 }
 
+// FetchPatchIndex downloads and verifies the patch index published next to
+// the manifest. It returns nil if none is published.
+func (c *Client) FetchPatchIndex(ctx context.Context) (*geofence.PatchIndex, error) {
+	data, err := c.fetchBinary(ctx, resolveURL(c.cdnBaseURL, geofence.PatchIndexFile), "patch index")
+	if errors.Is(err, errNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var index geofence.PatchIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse patch index: %w", err)
+	}
+
+	if c.insecureSkipVerify {
+		log.Printf("[SECURITY WARNING] Skipping signature verification for patch index (version=%d)", index.Version)
+		return &index, nil
+	}
+	if err := c.keyring.VerifySigned(&index, index.Signature, c.signingKeyID(index.KeyID), crypto.RoleSigning, time.Now()); err != nil {
+		return nil, fmt.Errorf("patch index signature verification failed: %w", err)
+	}
+	return &index, nil
+}
+
 // fetchBinary downloads a binary file with size limit.
 func (c *Client) fetchBinary(ctx context.Context, urlStr, fileType string) ([]byte, error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", urlStr, nil)
@@ -204,17 +284,20 @@ func (c *Client) fetchBinary(ctx context.Context, urlStr, fileType string) ([]by
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", errNotFound, fileType)
+	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("unexpected status code for %s: %d", fileType, resp.StatusCode)
 	}
 
 	// Check content length
-	if resp.ContentLength > config.DefaultMaxDownloadSize {
+	if resp.ContentLength > c.maxDownloadSize {
 		return nil, fmt.Errorf("%s too large: %d bytes", fileType, resp.ContentLength)
 	}
 
 	// Read response body
-	data, err := io.ReadAll(io.LimitReader(resp.Body, config.DefaultMaxDownloadSize))
+	data, err := io.ReadAll(io.LimitReader(resp.Body, c.maxDownloadSize))
 	if err != nil {
 		return nil, fmt.Errorf("failed to read %s: %w", fileType, err)
 	}
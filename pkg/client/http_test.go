@@ -3,6 +3,7 @@ package client
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"path/filepath"
@@ -10,6 +11,7 @@ import (
 	"time"
 
 	"github.com/iannil/geofence-updater-lite/pkg/config"
+	"github.com/iannil/geofence-updater-lite/pkg/converter"
 	"github.com/iannil/geofence-updater-lite/pkg/crypto"
 	"github.com/iannil/geofence-updater-lite/pkg/geofence"
 )
@@ -66,8 +68,12 @@ func TestNewClient_WithPublicKey(t *testing.T) {
 		t.Fatalf("NewClient failed: %v", err)
 	}
 
-	if len(client.publicKey) != crypto.PublicKeySize {
-		t.Errorf("publicKey size = %d, want %d", len(client.publicKey), crypto.PublicKeySize)
+	key, ok := client.Keyring().Key(kp.KeyID)
+	if !ok {
+		t.Fatal("configured public key should be trusted")
+	}
+	if !key.HasRole(crypto.RoleSigning) || !key.HasRole(crypto.RoleRotation) {
+		t.Errorf("configured public key roles = %v, want signing and rotation", key.Roles)
 	}
 }
 
@@ -184,6 +190,73 @@ func TestFetchManifest_SignatureVerification(t *testing.T) {
 	}
 }
 
+func TestFetchManifest_SignatureThreshold(t *testing.T) {
+	var keys []*crypto.KeyPair
+	for i := 0; i < 3; i++ {
+		kp, err := crypto.GenerateKeyPair()
+		if err != nil {
+			t.Fatalf("GenerateKeyPair failed: %v", err)
+		}
+		keys = append(keys, kp)
+	}
+
+	manifest := &geofence.Manifest{
+		Version:       4,
+		Timestamp:     time.Now().Unix(),
+		SnapshotURL:   "/snapshot.bin",
+		FormatVersion: geofence.CurrentFormatVersion,
+	}
+	manifestData, err := converter.ManifestSigningData(manifest)
+	if err != nil {
+		t.Fatalf("ManifestSigningData failed: %v", err)
+	}
+	sign := func(kp *crypto.KeyPair) []byte {
+		sig, err := kp.Sign(manifestData)
+		if err != nil {
+			t.Fatalf("Sign failed: %v", err)
+		}
+		return sig
+	}
+	manifest.SetSignature(sign(keys[0]), keys[0].KeyID)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/manifest.json" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(manifest)
+	}))
+	defer server.Close()
+
+	cfg := testClientConfig(t, server.URL)
+	cfg.InsecureSkipVerify = false
+	cfg.ManifestSignatureThreshold = 2
+	for _, kp := range keys {
+		cfg.TrustedKeys = append(cfg.TrustedKeys, crypto.TrustedKey{PublicKeyHex: crypto.MarshalPublicKeyHex(kp.PublicKey)})
+	}
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	ctx := context.Background()
+
+	if _, err := client.FetchManifest(ctx); !errors.Is(err, crypto.ErrThreshold) {
+		t.Errorf("FetchManifest() error = %v, want %v", err, crypto.ErrThreshold)
+	}
+
+	// A second signature by the same key does not count
+	manifest.AddSignature(sign(keys[0]), keys[0].KeyID)
+	if _, err := client.FetchManifest(ctx); !errors.Is(err, crypto.ErrThreshold) {
+		t.Errorf("FetchManifest() error = %v, want %v", err, crypto.ErrThreshold)
+	}
+
+	manifest.Signatures = nil
+	manifest.AddSignature(sign(keys[2]), keys[2].KeyID)
+	if _, err := client.FetchManifest(ctx); err != nil {
+		t.Errorf("FetchManifest with two of three signatures failed: %v", err)
+	}
+}
+
 func TestFetchManifest_NoSignature(t *testing.T) {
 	kp, err := crypto.GenerateKeyPair()
 	if err != nil {
@@ -386,6 +459,68 @@ func TestFetchDelta_EmptyURL(t *testing.T) {
 	}
 }
 
+func TestFetchPatchIndex(t *testing.T) {
+	kp, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair failed: %v", err)
+	}
+
+	index := &geofence.PatchIndex{Version: 3, Patches: []geofence.PatchInfo{
+		{FromVersion: 2, ToVersion: 3, URL: "/patches/v2_to_v3.bin", Size: 100},
+	}}
+	data, err := index.MarshalBinaryForSigning()
+	if err != nil {
+		t.Fatalf("MarshalBinaryForSigning failed: %v", err)
+	}
+	sig, err := kp.Sign(data)
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	index.SetSignature(sig, kp.KeyID)
+
+	var served *geofence.PatchIndex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/"+geofence.PatchIndexFile || served == nil {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(served)
+	}))
+	defer server.Close()
+
+	cfg := testClientConfig(t, server.URL)
+	cfg.InsecureSkipVerify = false
+	cfg.PublicKeyHex = crypto.MarshalPublicKeyHex(kp.PublicKey)
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	ctx := context.Background()
+
+	// No index published
+	got, err := client.FetchPatchIndex(ctx)
+	if err != nil || got != nil {
+		t.Errorf("FetchPatchIndex() = %v, %v, want nil, nil", got, err)
+	}
+
+	served = index
+	got, err = client.FetchPatchIndex(ctx)
+	if err != nil {
+		t.Fatalf("FetchPatchIndex failed: %v", err)
+	}
+	if got.Version != 3 || len(got.Patches) != 1 {
+		t.Errorf("FetchPatchIndex() = %+v, want the published index", got)
+	}
+
+	tampered := *index
+	tampered.Patches = []geofence.PatchInfo{index.Patches[0]}
+	tampered.Patches[0].URL = "/patches/evil.bin"
+	served = &tampered
+	if _, err := client.FetchPatchIndex(ctx); !errors.Is(err, crypto.ErrInvalidSignature) {
+		t.Errorf("FetchPatchIndex() error = %v, want %v", err, crypto.ErrInvalidSignature)
+	}
+}
+
 func TestFetchWithProgress(t *testing.T) {
 	data := []byte("test data for progress tracking")
 
@@ -594,6 +729,77 @@ func TestGetLastModified_NoHeader(t *testing.T) {
 	}
 }
 
+func TestVerifyUnlockToken(t *testing.T) {
+	kp, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair failed: %v", err)
+	}
+	other, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair failed: %v", err)
+	}
+
+	sign := func(kp *crypto.KeyPair, token geofence.UnlockToken) geofence.UnlockToken {
+		data, err := token.MarshalBinaryForSigning()
+		if err != nil {
+			t.Fatalf("MarshalBinaryForSigning failed: %v", err)
+		}
+		sig, err := kp.Sign(data)
+		if err != nil {
+			t.Fatalf("Sign failed: %v", err)
+		}
+		token.SetSignature(sig, kp.KeyID)
+		return token
+	}
+	token := geofence.UnlockToken{
+		ID:        "waiver-1",
+		FenceIDs:  []string{"stadium"},
+		NotBefore: 1700000000,
+		NotAfter:  1700086400,
+		DeviceID:  "SN-1234",
+	}
+	signed := sign(kp, token)
+	tampered := signed
+	tampered.NotAfter += 86400
+
+	cfg := testClientConfig(t, "https://example.com")
+	cfg.PublicKeyHex = crypto.MarshalPublicKeyHex(kp.PublicKey)
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		token   geofence.UnlockToken
+		wantErr bool
+	}{
+		{"valid", signed, false},
+		{"unsigned", token, true},
+		{"tampered", tampered, true},
+		{"other key", sign(other, token), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := client.VerifyUnlockToken(&tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifyUnlockToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("no public key", func(t *testing.T) {
+		insecure, err := NewClient(testClientConfig(t, "https://example.com"))
+		if err != nil {
+			t.Fatalf("NewClient failed: %v", err)
+		}
+		if err := insecure.VerifyUnlockToken(&signed); err == nil {
+			t.Error("expected unlock tokens to be rejected without a public key")
+		}
+	})
+}
+
 func TestVerifyDeltaHash(t *testing.T) {
 	data := []byte("test delta data")
 	hash := crypto.ComputeSHA256(data)
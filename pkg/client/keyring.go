@@ -0,0 +1,170 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/iannil/geofence-updater-lite/pkg/config"
+	"github.com/iannil/geofence-updater-lite/pkg/crypto"
+)
+
+// KeyRotationFile is the name of the key rotation announcement publishers
+// write next to the manifest.
+const KeyRotationFile = "keys.json"
+
+// errNotFound is returned by fetchBinary when the server has no such file.
+var errNotFound = errors.New("not found")
+
+// storedRotation is a key rotation announcement accepted by the client, as
+// kept in the keyring file.
+type storedRotation struct {
+	AcceptedAt int64               `json:"accepted_at"`
+	Rotation   *crypto.KeyRotation `json:"rotation"`
+}
+
+// keyringFile is the content of ClientConfig.KeyringPath.
+type keyringFile struct {
+	Rotations []storedRotation `json:"rotations"`
+}
+
+// newKeyring creates the keyring of the configured trusted keys. The key in
+// PublicKeyHex is trusted for signing and key rotation without expiry; its
+// key ID is returned as well, or "" if it is not set.
+func newKeyring(cfg *config.ClientConfig) (*crypto.Keyring, string, error) {
+	keys := cfg.TrustedKeys
+	var primaryKeyID string
+	if cfg.PublicKeyHex != "" {
+		publicKey, err := crypto.UnmarshalPublicKeyHex(cfg.PublicKeyHex)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid public key: %w", err)
+		}
+		primaryKeyID, err = crypto.PublicKeyToKeyID(publicKey)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid public key: %w", err)
+		}
+		keys = append([]crypto.TrustedKey{{
+			PublicKeyHex: cfg.PublicKeyHex,
+			Roles:        []crypto.KeyRole{crypto.RoleSigning, crypto.RoleRotation},
+		}}, keys...)
+	}
+
+	keyring, err := crypto.NewKeyring(keys...)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid trusted key: %w", err)
+	}
+	return keyring, primaryKeyID, nil
+}
+
+// Keyring returns the keys the client trusts. It changes as key rotation
+// announcements are applied.
+func (c *Client) Keyring() *crypto.Keyring {
+	return c.keyring
+}
+
+// ApplyKeyRotation verifies a key rotation announcement against the keys
+// trusted for rotation and applies it to the keyring. Accepted
+// announcements are kept in ClientConfig.KeyringPath, if set, and applied
+// again when a client is created.
+func (c *Client) ApplyKeyRotation(rot *crypto.KeyRotation) error {
+	c.rotationMu.Lock()
+	defer c.rotationMu.Unlock()
+
+	now := time.Now()
+	if err := c.keyring.ApplyRotation(rot, now); err != nil {
+		return err
+	}
+	log.Printf("[Client] Applied key rotation %d signed by %s", rot.Sequence, rot.KeyID)
+
+	c.rotations = append(c.rotations, storedRotation{AcceptedAt: now.Unix(), Rotation: rot})
+	return c.saveRotations()
+}
+
+// RefreshKeys fetches the key rotation announcement published next to the
+// manifest and applies it if it is newer than the last one applied. It is
+// not an error if none is published.
+func (c *Client) RefreshKeys(ctx context.Context) error {
+	data, err := c.fetchBinary(ctx, resolveURL(c.cdnBaseURL, KeyRotationFile), "key rotation")
+	if errors.Is(err, errNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var rot crypto.KeyRotation
+	if err := json.Unmarshal(data, &rot); err != nil {
+		return fmt.Errorf("failed to parse key rotation: %w", err)
+	}
+	if rot.Sequence <= c.keyring.Sequence() {
+		return nil // Already applied
+	}
+	return c.ApplyKeyRotation(&rot)
+}
+
+// loadRotations applies the key rotations kept in the keyring file, each
+// verified as of when it was accepted. Rotations that no longer verify
+// against the configured keys are dropped.
+func (c *Client) loadRotations() error {
+	if c.keyringPath == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(c.keyringPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read keyring: %w", err)
+	}
+
+	var file keyringFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse keyring: %w", err)
+	}
+
+	c.rotationMu.Lock()
+	defer c.rotationMu.Unlock()
+	for _, stored := range file.Rotations {
+		if stored.Rotation == nil {
+			continue
+		}
+		if err := c.keyring.ApplyRotation(stored.Rotation, time.Unix(stored.AcceptedAt, 0)); err != nil {
+			log.Printf("[Client] Dropping stored key rotation: %v", err)
+			continue
+		}
+		c.rotations = append(c.rotations, stored)
+	}
+	return nil
+}
+
+// saveRotations writes the accepted key rotations to the keyring file.
+// The caller must hold rotationMu.
+func (c *Client) saveRotations() error {
+	if c.keyringPath == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(keyringFile{Rotations: c.rotations}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal keyring: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.keyringPath), 0755); err != nil {
+		return fmt.Errorf("failed to create keyring directory: %w", err)
+	}
+
+	// Write atomically so that a crash cannot lose earlier rotations
+	tmp := c.keyringPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write keyring: %w", err)
+	}
+	if err := os.Rename(tmp, c.keyringPath); err != nil {
+		return fmt.Errorf("failed to write keyring: %w", err)
+	}
+	return nil
+}
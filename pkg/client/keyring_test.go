@@ -0,0 +1,170 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/iannil/geofence-updater-lite/pkg/config"
+	"github.com/iannil/geofence-updater-lite/pkg/crypto"
+	"github.com/iannil/geofence-updater-lite/pkg/geofence"
+)
+
+func TestFetchManifest_KeyRotation(t *testing.T) {
+	oldKey, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair failed: %v", err)
+	}
+	newKey, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair failed: %v", err)
+	}
+
+	// The new key signs the manifest; the old one announces it
+	manifest := &geofence.Manifest{Version: 3, Timestamp: time.Now().Unix(), SnapshotURL: "/snapshot.bin"}
+	manifestData, err := manifest.MarshalBinaryForSigning()
+	if err != nil {
+		t.Fatalf("MarshalBinaryForSigning failed: %v", err)
+	}
+	sig, err := newKey.Sign(manifestData)
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	manifest.SetSignature(sig, newKey.KeyID)
+
+	rot := &crypto.KeyRotation{
+		Sequence:  1,
+		Timestamp: time.Now().Unix(),
+		Add: []crypto.TrustedKey{{
+			PublicKeyHex: crypto.MarshalPublicKeyHex(newKey.PublicKey),
+			Roles:        []crypto.KeyRole{crypto.RoleSigning, crypto.RoleRotation},
+		}},
+		Revoke: []string{oldKey.KeyID},
+	}
+	rotData, err := rot.MarshalBinaryForSigning()
+	if err != nil {
+		t.Fatalf("MarshalBinaryForSigning failed: %v", err)
+	}
+	sig, err = oldKey.Sign(rotData)
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	rot.SetSignature(sig, oldKey.KeyID)
+
+	publishRotation := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/manifest.json":
+			json.NewEncoder(w).Encode(manifest)
+		case "/" + KeyRotationFile:
+			if !publishRotation {
+				http.NotFound(w, r)
+				return
+			}
+			json.NewEncoder(w).Encode(rot)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &config.ClientConfig{
+		ManifestURL:  server.URL + "/manifest.json",
+		HTTPTimeout:  5 * time.Second,
+		UserAgent:    "test/1.0",
+		StorePath:    filepath.Join(t.TempDir(), "client.db"),
+		PublicKeyHex: crypto.MarshalPublicKeyHex(oldKey.PublicKey),
+		KeyringPath:  filepath.Join(t.TempDir(), "keyring.json"),
+	}
+
+	ctx := context.Background()
+
+	t.Run("unknown key without announcement", func(t *testing.T) {
+		publishRotation = false
+		defer func() { publishRotation = true }()
+
+		client, err := NewClient(cfg)
+		if err != nil {
+			t.Fatalf("NewClient failed: %v", err)
+		}
+		if _, err := client.FetchManifest(ctx); !errors.Is(err, crypto.ErrUnknownKey) {
+			t.Errorf("FetchManifest() error = %v, want %v", err, crypto.ErrUnknownKey)
+		}
+	})
+
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	if _, err := client.FetchManifest(ctx); err != nil {
+		t.Fatalf("FetchManifest failed: %v", err)
+	}
+	if _, ok := client.Keyring().Key(oldKey.KeyID); ok {
+		t.Error("revoked key should no longer be trusted")
+	}
+
+	// A new client picks the rotation up from the keyring file
+	publishRotation = false
+	restarted, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	if restarted.Keyring().Sequence() != 1 {
+		t.Errorf("Sequence() = %d, want 1", restarted.Keyring().Sequence())
+	}
+	if _, err := restarted.FetchManifest(ctx); err != nil {
+		t.Errorf("FetchManifest after restart failed: %v", err)
+	}
+
+	// Replaying the announcement changes nothing
+	if err := restarted.ApplyKeyRotation(rot); err == nil {
+		t.Error("expected error for a replayed key rotation")
+	}
+}
+
+func TestNewClient_TrustedKeys(t *testing.T) {
+	kp, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair failed: %v", err)
+	}
+
+	cfg := &config.ClientConfig{
+		ManifestURL: "https://example.com/manifest.json",
+		StorePath:   filepath.Join(t.TempDir(), "client.db"),
+		TrustedKeys: []crypto.TrustedKey{{
+			PublicKeyHex: crypto.MarshalPublicKeyHex(kp.PublicKey),
+			NotAfter:     time.Now().Add(-time.Hour).Unix(),
+		}},
+	}
+
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	// The key has expired, so nothing it signs is accepted
+	token := &geofence.UnlockToken{
+		ID:       "unlock-1",
+		DeviceID: "SN-1",
+		FenceIDs: []string{"fence-1"},
+		NotAfter: time.Now().Add(time.Hour).Unix(),
+	}
+	data, err := token.MarshalBinaryForSigning()
+	if err != nil {
+		t.Fatalf("MarshalBinaryForSigning failed: %v", err)
+	}
+	sig, err := kp.Sign(data)
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	token.SetSignature(sig, kp.KeyID)
+
+	if err := client.VerifyUnlockToken(token); !errors.Is(err, crypto.ErrKeyNotValid) {
+		t.Errorf("VerifyUnlockToken() error = %v, want %v", err, crypto.ErrKeyNotValid)
+	}
+}
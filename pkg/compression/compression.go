@@ -0,0 +1,115 @@
+// Package compression compresses published snapshot and delta files and
+// decompresses them on clients within a size limit.
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codecs a file may be compressed with.
+const (
+	Gzip = "gzip"
+	Zstd = "zstd"
+)
+
+// Codecs lists the supported codecs, in the order clients prefer them by
+// default.
+var Codecs = []string{Zstd, Gzip}
+
+// ErrTooLarge is returned by Decompress when the decompressed data would
+// exceed the size limit.
+var ErrTooLarge = errors.New("decompressed data exceeds size limit")
+
+// Supported reports whether codec is a supported codec.
+func Supported(codec string) bool {
+	return codec == Gzip || codec == Zstd
+}
+
+// Extension returns the file name extension of files compressed with codec.
+func Extension(codec string) string {
+	switch codec {
+	case Gzip:
+		return ".gz"
+	case Zstd:
+		return ".zst"
+	}
+	return ""
+}
+
+// Compress compresses data with codec at its best compression, as files are
+// compressed once and downloaded by many clients.
+func Compress(codec string, data []byte) ([]byte, error) {
+	switch codec {
+	case Gzip:
+		var buf bytes.Buffer
+		w, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(data); err != nil {
+			return nil, fmt.Errorf("failed to compress: %w", err)
+		}
+		if err := w.Close(); err != nil {
+			return nil, fmt.Errorf("failed to compress: %w", err)
+		}
+		return buf.Bytes(), nil
+
+	case Zstd:
+		enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedBestCompression))
+		if err != nil {
+			return nil, err
+		}
+		defer enc.Close()
+		return enc.EncodeAll(data, nil), nil
+	}
+	return nil, fmt.Errorf("unsupported codec %q", codec)
+}
+
+// Decompress decompresses data compressed with codec. It fails with
+// ErrTooLarge rather than produce more than maxSize bytes.
+func Decompress(codec string, data []byte, maxSize int64) ([]byte, error) {
+	var r io.Reader
+	switch codec {
+	case Gzip:
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress: %w", err)
+		}
+		defer zr.Close()
+		r = zr
+
+	case Zstd:
+		zr, err := zstd.NewReader(bytes.NewReader(data),
+			zstd.WithDecoderConcurrency(1),
+			zstd.WithDecoderMaxMemory(uint64(maxSize)+1),
+			zstd.WithDecoderMaxWindow(uint64(max(maxSize, zstd.MinWindowSize))))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress: %w", err)
+		}
+		defer zr.Close()
+		r = zr
+
+	default:
+		return nil, fmt.Errorf("unsupported codec %q", codec)
+	}
+
+	// Read one byte past the limit to tell a file of exactly maxSize bytes
+	// from a larger one
+	out, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		if errors.Is(err, zstd.ErrDecoderSizeExceeded) || errors.Is(err, zstd.ErrWindowSizeExceeded) {
+			return nil, ErrTooLarge
+		}
+		return nil, fmt.Errorf("failed to decompress: %w", err)
+	}
+	if int64(len(out)) > maxSize {
+		return nil, ErrTooLarge
+	}
+	return out, nil
+}
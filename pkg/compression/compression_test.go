@@ -0,0 +1,70 @@
+package compression
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestCompress_RoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte("geofence snapshot data "), 1000)
+
+	for _, codec := range Codecs {
+		t.Run(codec, func(t *testing.T) {
+			compressed, err := Compress(codec, data)
+			if err != nil {
+				t.Fatalf("Compress failed: %v", err)
+			}
+			if len(compressed) >= len(data) {
+				t.Errorf("compressed %d bytes to %d", len(data), len(compressed))
+			}
+
+			got, err := Decompress(codec, compressed, int64(len(data)))
+			if err != nil {
+				t.Fatalf("Decompress failed: %v", err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Error("Decompress did not return the original data")
+			}
+
+			empty, err := Compress(codec, nil)
+			if err != nil {
+				t.Fatalf("Compress failed: %v", err)
+			}
+			if got, err := Decompress(codec, empty, 0); err != nil || len(got) != 0 {
+				t.Errorf("Decompress() of empty data = %d bytes, %v", len(got), err)
+			}
+		})
+	}
+}
+
+func TestDecompress_Limits(t *testing.T) {
+	// A small file that expands far beyond the limit
+	bomb := make([]byte, 1<<20)
+
+	for _, codec := range Codecs {
+		t.Run(codec, func(t *testing.T) {
+			compressed, err := Compress(codec, bomb)
+			if err != nil {
+				t.Fatalf("Compress failed: %v", err)
+			}
+			if _, err := Decompress(codec, compressed, 1<<20-1); !errors.Is(err, ErrTooLarge) {
+				t.Errorf("Decompress() error = %v, want %v", err, ErrTooLarge)
+			}
+
+			corrupt := bytes.Clone(compressed)
+			corrupt[len(corrupt)/2] ^= 0xFF
+			corrupt = corrupt[:len(corrupt)-2]
+			if _, err := Decompress(codec, corrupt, 1<<20); err == nil {
+				t.Error("expected error for corrupt data")
+			}
+		})
+	}
+
+	if _, err := Compress("lz4", bomb); err == nil {
+		t.Error("expected error for an unsupported codec")
+	}
+	if _, err := Decompress("lz4", bomb, 1<<20); err == nil {
+		t.Error("expected error for an unsupported codec")
+	}
+}
@@ -6,7 +6,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"time"
+
+	"github.com/iannil/geofence-updater-lite/pkg/compression"
+	"github.com/iannil/geofence-updater-lite/pkg/crypto"
+	"github.com/iannil/geofence-updater-lite/pkg/geofence"
 )
 
 // Default values
@@ -33,9 +38,24 @@ type ClientConfig struct {
 	// ManifestURL is the URL to poll for updates
 	ManifestURL string `json:"manifest_url"`
 
-	// PublicKeyHex is the Ed25519 public key in hex format
+	// PublicKeyHex is the Ed25519 public key in hex format. It is trusted
+	// for signing and key rotation, without expiry.
 	PublicKeyHex string `json:"public_key_hex"`
 
+	// TrustedKeys are trusted in addition to PublicKeyHex, each for its
+	// roles within its validity window
+	TrustedKeys []crypto.TrustedKey `json:"trusted_keys,omitempty"`
+
+	// KeyringPath is the file accepted key rotation announcements are kept
+	// in, so that they still apply after a restart. If empty, they only
+	// last until the client exits.
+	KeyringPath string `json:"keyring_path,omitempty"`
+
+	// ManifestSignatureThreshold is the number of distinct trusted keys
+	// that must have signed a manifest for it to be accepted (M of the N
+	// trusted signing keys). Defaults to 1.
+	ManifestSignatureThreshold int `json:"manifest_signature_threshold,omitempty"`
+
 	// StorePath is where to store the local geofence database
 	StorePath string `json:"store_path"`
 
@@ -55,6 +75,18 @@ type ClientConfig struct {
 	// When true, manifests will be accepted without signature verification.
 	// This should NEVER be used in production environments.
 	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+
+	// QuarantineInvalidFences keeps a sync going when individual fences fail
+	// signature verification: they are left out of the local database and
+	// reported in the sync result. By default such a sync fails and nothing
+	// is applied.
+	QuarantineInvalidFences bool `json:"quarantine_invalid_fences,omitempty"`
+
+	// AcceptEncodings lists the compression codecs ("zstd", "gzip") of
+	// published files to download, in order of preference. If nil, every
+	// supported codec is accepted; if empty, only uncompressed files are
+	// downloaded.
+	AcceptEncodings []string `json:"accept_encodings,omitempty"`
 }
 
 // PublisherConfig contains configuration for the publisher tool.
@@ -62,6 +94,29 @@ type PublisherConfig struct {
 	// PrivateKeyHex is the Ed25519 private key in hex format
 	PrivateKeyHex string `json:"private_key_hex"`
 
+	// PrivateKeyFile is the path of a passphrase-encrypted private key
+	// file, used instead of PrivateKeyHex
+	PrivateKeyFile string `json:"private_key_file,omitempty"`
+
+	// Passphrase returns the passphrase of PrivateKeyFile. It is called
+	// each time the key is loaded and the returned slice is cleared after
+	// use. It is never read from the config file.
+	Passphrase func() ([]byte, error) `json:"-"`
+
+	// SignerSocket is the Unix socket of an external signing service
+	// holding the key, used instead of a private key. KeyID selects the
+	// key if the service holds several.
+	SignerSocket string `json:"signer_socket,omitempty"`
+
+	// SignerCommand starts an external signing process, with arguments,
+	// that speaks the signer protocol on its stdin and stdout.
+	SignerCommand []string `json:"signer_command,omitempty"`
+
+	// Signer signs with a key held elsewhere, taking precedence over the
+	// other key settings. It is never read from the config file and is not
+	// closed by the publisher.
+	Signer crypto.Signer `json:"-"`
+
 	// KeyID identifies which key to use
 	KeyID string `json:"key_id"`
 
@@ -76,6 +131,36 @@ type PublisherConfig struct {
 
 	// PreviousDir contains data from previous version (for delta generation)
 	PreviousDir string `json:"previous_dir"`
+
+	// FormatVersion is the encoding signatures and Merkle leaves are
+	// computed over (0 = the newest). Set it to 1 while clients that predate
+	// format versions are still in service.
+	FormatVersion uint32 `json:"format_version,omitempty"`
+
+	// RequiredSignatures is the number of key holders, including the
+	// publisher, that must sign a manifest before it is released. With more
+	// than 1, publish writes a pending manifest to be co-signed. Defaults
+	// to 1.
+	RequiredSignatures int `json:"required_signatures,omitempty"`
+
+	// TrustedKeys are the other key holders' public keys. Every signature
+	// on a manifest must verify against one of them, or the publisher's own
+	// key, before it is co-signed or released.
+	TrustedKeys []crypto.TrustedKey `json:"trusted_keys,omitempty"`
+
+	// PatchHistory is how many versions back deltas are kept in the patch
+	// index, so clients up to that far behind can catch up through a chain
+	// of deltas. Defaults to 10.
+	PatchHistory int `json:"patch_history,omitempty"`
+
+	// SkipDeltaInterval, if greater than 1, adds a delta spanning that many
+	// versions to each publish, shortening the chains of clients far
+	// behind.
+	SkipDeltaInterval int `json:"skip_delta_interval,omitempty"`
+
+	// Compression lists the codecs ("zstd", "gzip") to publish compressed
+	// copies of snapshots and deltas in, next to the uncompressed files.
+	Compression []string `json:"compression,omitempty"`
 }
 
 // Load loads configuration from a file.
@@ -141,9 +226,14 @@ func (c *ClientConfig) Validate() error {
 	if c.ManifestURL == "" {
 		return fmt.Errorf("manifest_url is required")
 	}
-	// PublicKeyHex is required unless InsecureSkipVerify is explicitly set
-	if c.PublicKeyHex == "" && !c.InsecureSkipVerify {
-		return fmt.Errorf("public_key_hex is required (set insecure_skip_verify=true to disable verification, NOT recommended for production)")
+	// A trusted key is required unless InsecureSkipVerify is explicitly set
+	if c.PublicKeyHex == "" && len(c.TrustedKeys) == 0 && !c.InsecureSkipVerify {
+		return fmt.Errorf("public_key_hex or trusted_keys is required (set insecure_skip_verify=true to disable verification, NOT recommended for production)")
+	}
+	for i := range c.TrustedKeys {
+		if err := c.TrustedKeys[i].Validate(); err != nil {
+			return fmt.Errorf("trusted_keys[%d]: %w", i, err)
+		}
 	}
 	if c.StorePath == "" {
 		return fmt.Errorf("store_path is required")
@@ -160,13 +250,38 @@ func (c *ClientConfig) Validate() error {
 	if c.UserAgent == "" {
 		c.UserAgent = "GUL-Client/1.0"
 	}
+	if c.ManifestSignatureThreshold < 0 {
+		return fmt.Errorf("manifest_signature_threshold must not be negative")
+	}
+	if c.ManifestSignatureThreshold == 0 {
+		c.ManifestSignatureThreshold = 1
+	}
+	if c.AcceptEncodings == nil {
+		c.AcceptEncodings = slices.Clone(compression.Codecs)
+	}
+	for _, codec := range c.AcceptEncodings {
+		if !compression.Supported(codec) {
+			return fmt.Errorf("unsupported codec %q in accept_encodings", codec)
+		}
+	}
 	return nil
 }
 
 // Validate validates the publisher configuration.
 func (c *PublisherConfig) Validate() error {
-	if c.PrivateKeyHex == "" {
-		return fmt.Errorf("private_key_hex is required")
+	if c.Signer == nil {
+		keySources := 0
+		for _, set := range []bool{c.PrivateKeyHex != "", c.PrivateKeyFile != "", c.SignerSocket != "", len(c.SignerCommand) > 0} {
+			if set {
+				keySources++
+			}
+		}
+		if keySources == 0 {
+			return fmt.Errorf("one of private_key_hex, private_key_file, signer_socket or signer_command is required")
+		}
+		if keySources > 1 {
+			return fmt.Errorf("only one of private_key_hex, private_key_file, signer_socket and signer_command may be set")
+		}
 	}
 	if c.OutputDir == "" {
 		return fmt.Errorf("output_dir is required")
@@ -177,6 +292,34 @@ func (c *PublisherConfig) Validate() error {
 	if c.CurrentVersion == 0 {
 		c.CurrentVersion = 1
 	}
+	if c.FormatVersion == 0 {
+		c.FormatVersion = geofence.CurrentFormatVersion
+	}
+	if c.FormatVersion > geofence.CurrentFormatVersion {
+		return fmt.Errorf("unsupported format_version %d (newest is %d)", c.FormatVersion, geofence.CurrentFormatVersion)
+	}
+	if c.RequiredSignatures < 0 {
+		return fmt.Errorf("required_signatures must not be negative")
+	}
+	if c.RequiredSignatures == 0 {
+		c.RequiredSignatures = 1
+	}
+	for i := range c.TrustedKeys {
+		if err := c.TrustedKeys[i].Validate(); err != nil {
+			return fmt.Errorf("trusted_keys[%d]: %w", i, err)
+		}
+	}
+	if c.PatchHistory < 0 || c.SkipDeltaInterval < 0 {
+		return fmt.Errorf("patch_history and skip_delta_interval must not be negative")
+	}
+	if c.PatchHistory == 0 {
+		c.PatchHistory = 10
+	}
+	for _, codec := range c.Compression {
+		if !compression.Supported(codec) {
+			return fmt.Errorf("unsupported codec %q in compression", codec)
+		}
+	}
 	return nil
 }
 
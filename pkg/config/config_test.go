@@ -4,6 +4,8 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/iannil/geofence-updater-lite/pkg/crypto"
 )
 
 func TestClientConfig_Validate(t *testing.T) {
@@ -37,6 +39,39 @@ func TestClientConfig_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "trusted keys instead of public key",
+			cfg: &ClientConfig{
+				ManifestURL: "https://example.com/manifest.json",
+				StorePath:   "/data/geofence.db",
+				TrustedKeys: []crypto.TrustedKey{{
+					PublicKeyHex: "0000000000000000000000000000000000000000000000000000000000000000",
+					Roles:        []crypto.KeyRole{crypto.RoleSigning},
+					NotAfter:     1900000000,
+				}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid trusted key",
+			cfg: &ClientConfig{
+				ManifestURL:  "https://example.com/manifest.json",
+				PublicKeyHex: "0000000000000000000000000000000000000000000000000000000000000000",
+				StorePath:    "/data/geofence.db",
+				TrustedKeys:  []crypto.TrustedKey{{PublicKeyHex: "00", Roles: []crypto.KeyRole{"admin"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unsupported accepted encoding",
+			cfg: &ClientConfig{
+				ManifestURL:     "https://example.com/manifest.json",
+				PublicKeyHex:    "0000000000000000000000000000000000000000000000000000000000000000",
+				StorePath:       "/data/geofence.db",
+				AcceptEncodings: []string{"zstd", "br"},
+			},
+			wantErr: true,
+		},
 		{
 			name: "missing store path",
 			cfg: &ClientConfig{
@@ -106,6 +141,34 @@ func TestPublisherConfig_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "encrypted key file",
+			cfg: &PublisherConfig{
+				PrivateKeyFile: "./private.key.enc",
+				OutputDir:      "./output",
+				CDNBaseURL:     "https://cdn.example.com",
+			},
+			wantErr: false,
+		},
+		{
+			name: "external signer",
+			cfg: &PublisherConfig{
+				SignerSocket: "/run/gul-signer.sock",
+				OutputDir:    "./output",
+				CDNBaseURL:   "https://cdn.example.com",
+			},
+			wantErr: false,
+		},
+		{
+			name: "both private key and key file",
+			cfg: &PublisherConfig{
+				PrivateKeyHex:  "00000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000",
+				PrivateKeyFile: "./private.key.enc",
+				OutputDir:      "./output",
+				CDNBaseURL:     "https://cdn.example.com",
+			},
+			wantErr: true,
+		},
 		{
 			name: "missing output dir",
 			cfg: &PublisherConfig{
@@ -114,6 +177,36 @@ func TestPublisherConfig_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "compression",
+			cfg: &PublisherConfig{
+				SignerSocket: "/run/gul-signer.sock",
+				OutputDir:    "./output",
+				CDNBaseURL:   "https://cdn.example.com",
+				Compression:  []string{"zstd", "gzip"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "unsupported compression",
+			cfg: &PublisherConfig{
+				SignerSocket: "/run/gul-signer.sock",
+				OutputDir:    "./output",
+				CDNBaseURL:   "https://cdn.example.com",
+				Compression:  []string{"lz4"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid trusted key",
+			cfg: &PublisherConfig{
+				SignerSocket: "/run/gul-signer.sock",
+				OutputDir:    "./output",
+				CDNBaseURL:   "https://cdn.example.com",
+				TrustedKeys:  []crypto.TrustedKey{{PublicKeyHex: "00"}},
+			},
+			wantErr: true,
+		},
 		{
 			name: "missing CDN base URL",
 			cfg: &PublisherConfig{
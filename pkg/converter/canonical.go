@@ -0,0 +1,164 @@
+package converter
+
+import (
+	"fmt"
+
+	"github.com/iannil/geofence-updater-lite/pkg/geofence"
+	pb "github.com/iannil/geofence-updater-lite/pkg/protocol/protobuf"
+	"google.golang.org/protobuf/proto"
+)
+
+// Signing data prefixes for FormatVersionProto. They separate fence and
+// manifest signatures made with the same key and name the format version.
+const (
+	FenceSigningPrefixV2    = "GUL-FENCE-V2\n"
+	ManifestSigningPrefixV2 = "GUL-MANIFEST-V2\n"
+)
+
+// FenceDeltaSigningPrefix starts the signing data of fence delta files,
+// which are always in the canonical encoding.
+const FenceDeltaSigningPrefix = "GUL-FENCEDELTA-V1\n"
+
+// canonicalOptions encodes messages in field number order. None of the
+// messages have map fields, so the output only depends on the field values.
+var canonicalOptions = proto.MarshalOptions{Deterministic: true}
+
+// MarshalCanonicalFence returns the canonical encoding of a fence: the
+// deterministic protobuf encoding of pb.FenceItem with signature and key_id
+// cleared. Fields are written in field number order, fields holding their
+// default value are left out and repeated scalars are packed.
+func MarshalCanonicalFence(f *geofence.FenceItem) ([]byte, error) {
+	pbItem := FenceItemToProto(f)
+	pbItem.Signature = nil
+	pbItem.KeyId = ""
+
+	data, err := canonicalOptions.Marshal(pbItem)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal fence %s: %w", f.ID, err)
+	}
+	return data, nil
+}
+
+// MarshalCanonicalManifest returns the canonical encoding of a manifest:
+// the deterministic protobuf encoding of pb.Manifest with signature, key_id
+// and signatures cleared.
+func MarshalCanonicalManifest(m *geofence.Manifest) ([]byte, error) {
+	pbManifest := ManifestToProto(m)
+	pbManifest.Signature = nil
+	pbManifest.KeyId = ""
+	pbManifest.Signatures = nil
+
+	data, err := canonicalOptions.Marshal(pbManifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	return data, nil
+}
+
+// CheckFormatVersion returns an error if data in the given format version
+// cannot be hashed or verified by this build. 0 means FormatVersionJSON.
+func CheckFormatVersion(version uint32) error {
+	if version > geofence.CurrentFormatVersion {
+		return fmt.Errorf("unsupported format version %d (newest supported is %d)", version, geofence.CurrentFormatVersion)
+	}
+	return nil
+}
+
+// FenceSigningData returns the bytes a fence signature is computed over in
+// the given format version: FenceItem.MarshalBinaryForSigning for
+// FormatVersionJSON, and FenceSigningPrefixV2 followed by the canonical
+// encoding for FormatVersionProto.
+func FenceSigningData(f *geofence.FenceItem, version uint32) ([]byte, error) {
+	if err := CheckFormatVersion(version); err != nil {
+		return nil, err
+	}
+	if version < geofence.FormatVersionProto {
+		return f.MarshalBinaryForSigning()
+	}
+
+	data, err := MarshalCanonicalFence(f)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(FenceSigningPrefixV2), data...), nil
+}
+
+// ManifestSigningData returns the bytes a manifest signature is computed
+// over in the manifest's FormatVersion: Manifest.MarshalBinaryForSigning
+// for FormatVersionJSON, and ManifestSigningPrefixV2 followed by the
+// canonical encoding for FormatVersionProto.
+func ManifestSigningData(m *geofence.Manifest) ([]byte, error) {
+	if err := CheckFormatVersion(m.FormatVersion); err != nil {
+		return nil, err
+	}
+	if m.FormatVersion < geofence.FormatVersionProto {
+		return m.MarshalBinaryForSigning()
+	}
+
+	data, err := MarshalCanonicalManifest(m)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(ManifestSigningPrefixV2), data...), nil
+}
+
+// SignableFence pairs a fence with the format version its signature is
+// computed in, for use with crypto.Keyring.VerifySigned.
+type SignableFence struct {
+	Fence         *geofence.FenceItem
+	FormatVersion uint32
+}
+
+// MarshalBinaryForSigning returns the fence's signing data.
+func (s SignableFence) MarshalBinaryForSigning() ([]byte, error) {
+	return FenceSigningData(s.Fence, s.FormatVersion)
+}
+
+// MarshalFenceDeltaFile returns the encoding of a fence delta file as
+// published: the deterministic protobuf encoding of pb.FenceDeltaFile.
+func MarshalFenceDeltaFile(d *geofence.FenceDeltaFile) ([]byte, error) {
+	data, err := canonicalOptions.Marshal(FenceDeltaFileToProto(d))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal fence delta: %w", err)
+	}
+	return data, nil
+}
+
+// UnmarshalFenceDeltaFile parses a fence delta file.
+func UnmarshalFenceDeltaFile(data []byte) (*geofence.FenceDeltaFile, error) {
+	var pbFile pb.FenceDeltaFile
+	if err := proto.Unmarshal(data, &pbFile); err != nil {
+		return nil, fmt.Errorf("failed to parse fence delta: %w", err)
+	}
+	d, err := FenceDeltaFileFromProto(&pbFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse fence delta: %w", err)
+	}
+	return d, nil
+}
+
+// FenceDeltaSigningData returns the bytes a fence delta file's signature is
+// computed over: FenceDeltaSigningPrefix followed by the file's encoding
+// with signature and key_id cleared. The fences keep their own signatures.
+func FenceDeltaSigningData(d *geofence.FenceDeltaFile) ([]byte, error) {
+	pbFile := FenceDeltaFileToProto(d)
+	pbFile.Signature = nil
+	pbFile.KeyId = ""
+
+	data, err := canonicalOptions.Marshal(pbFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal fence delta: %w", err)
+	}
+	return append([]byte(FenceDeltaSigningPrefix), data...), nil
+}
+
+// SignableFenceDelta wraps a fence delta file for use with
+// crypto.Keyring.VerifySigned.
+type SignableFenceDelta struct {
+	File *geofence.FenceDeltaFile
+}
+
+// MarshalBinaryForSigning returns the delta file's signing data.
+func (s SignableFenceDelta) MarshalBinaryForSigning() ([]byte, error) {
+	return FenceDeltaSigningData(s.File)
+}
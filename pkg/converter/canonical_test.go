@@ -0,0 +1,238 @@
+package converter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iannil/geofence-updater-lite/pkg/geofence"
+	pb "github.com/iannil/geofence-updater-lite/pkg/protocol/protobuf"
+	"google.golang.org/protobuf/proto"
+)
+
+func canonicalTestFence() *geofence.FenceItem {
+	return &geofence.FenceItem{
+		ID:       "stadium",
+		Type:     geofence.FenceTypeTempRestriction,
+		Priority: 50,
+		Geometry: geofence.Geometry{
+			CircleCenter: &geofence.Point{Latitude: 30.123456789, Longitude: 110.5},
+			CircleRadius: 1000,
+		},
+		Schedule: &geofence.Schedule{TimeZone: "UTC", Rules: []geofence.WeeklyRule{
+			{Days: []time.Weekday{time.Saturday, time.Sunday}, StartMinute: 600, EndMinute: 1080},
+		}},
+		Name: "Stadium",
+	}
+}
+
+func TestMarshalCanonicalFence(t *testing.T) {
+	fence := canonicalTestFence()
+	data, err := MarshalCanonicalFence(fence)
+	if err != nil {
+		t.Fatalf("MarshalCanonicalFence failed: %v", err)
+	}
+
+	again, err := MarshalCanonicalFence(fence)
+	if err != nil {
+		t.Fatalf("MarshalCanonicalFence failed: %v", err)
+	}
+	if !bytes.Equal(data, again) {
+		t.Error("canonical encoding should be deterministic")
+	}
+
+	fence.SetSignature([]byte("signature"), "key")
+	signed, err := MarshalCanonicalFence(fence)
+	if err != nil {
+		t.Fatalf("MarshalCanonicalFence failed: %v", err)
+	}
+	if !bytes.Equal(data, signed) {
+		t.Error("canonical encoding should not include the signature or key ID")
+	}
+
+	var decoded pb.FenceItem
+	if err := proto.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("canonical encoding is not a FenceItem message: %v", err)
+	}
+	if got := decoded.Geometry.GetCircle().GetCenter().GetLatitude(); got != 30.123456789 {
+		t.Errorf("Latitude = %v, want full precision 30.123456789", got)
+	}
+
+	fence.Geometry.CircleCenter.Latitude += 1e-12
+	moved, err := MarshalCanonicalFence(fence)
+	if err != nil {
+		t.Fatalf("MarshalCanonicalFence failed: %v", err)
+	}
+	if bytes.Equal(data, moved) {
+		t.Error("canonical encoding should cover coordinates at full precision")
+	}
+}
+
+func TestFenceSigningData(t *testing.T) {
+	fence := canonicalTestFence()
+
+	legacy, err := FenceSigningData(fence, 0)
+	if err != nil {
+		t.Fatalf("FenceSigningData failed: %v", err)
+	}
+	want, err := fence.MarshalBinaryForSigning()
+	if err != nil {
+		t.Fatalf("MarshalBinaryForSigning failed: %v", err)
+	}
+	if !bytes.Equal(legacy, want) {
+		t.Error("format version 0 should sign the JSON encoding")
+	}
+
+	current, err := FenceSigningData(fence, geofence.FormatVersionProto)
+	if err != nil {
+		t.Fatalf("FenceSigningData failed: %v", err)
+	}
+	canonical, err := MarshalCanonicalFence(fence)
+	if err != nil {
+		t.Fatalf("MarshalCanonicalFence failed: %v", err)
+	}
+	if !strings.HasPrefix(string(current), FenceSigningPrefixV2) || !bytes.Equal(current[len(FenceSigningPrefixV2):], canonical) {
+		t.Error("format version 2 should sign the prefixed canonical encoding")
+	}
+
+	if _, err := FenceSigningData(fence, geofence.CurrentFormatVersion+1); err == nil {
+		t.Error("expected error for an unsupported format version")
+	}
+}
+
+func TestManifestSigningData(t *testing.T) {
+	manifest := &geofence.Manifest{
+		Version:      7,
+		Timestamp:    1700000000,
+		RootHash:     []byte{1, 2, 3},
+		SnapshotURL:  "/snapshots/v7.bin",
+		SnapshotHash: []byte{4, 5, 6},
+		Signature:    []byte("signature"),
+		KeyID:        "key",
+	}
+
+	legacy, err := ManifestSigningData(manifest)
+	if err != nil {
+		t.Fatalf("ManifestSigningData failed: %v", err)
+	}
+	want, err := manifest.MarshalBinaryForSigning()
+	if err != nil {
+		t.Fatalf("MarshalBinaryForSigning failed: %v", err)
+	}
+	if !bytes.Equal(legacy, want) {
+		t.Error("manifests without a format version should sign the JSON encoding")
+	}
+
+	manifest.FormatVersion = geofence.FormatVersionProto
+	current, err := ManifestSigningData(manifest)
+	if err != nil {
+		t.Fatalf("ManifestSigningData failed: %v", err)
+	}
+	if !strings.HasPrefix(string(current), ManifestSigningPrefixV2) {
+		t.Errorf("signing data should start with %q", ManifestSigningPrefixV2)
+	}
+
+	var decoded pb.Manifest
+	if err := proto.Unmarshal(current[len(ManifestSigningPrefixV2):], &decoded); err != nil {
+		t.Fatalf("canonical encoding is not a Manifest message: %v", err)
+	}
+	if decoded.Version != 7 || decoded.FormatVersion != geofence.FormatVersionProto {
+		t.Errorf("Version = %d, FormatVersion = %d, want 7 and 2", decoded.Version, decoded.FormatVersion)
+	}
+	if len(decoded.Signature) != 0 || decoded.KeyId != "" {
+		t.Error("canonical encoding should not include the signature or key ID")
+	}
+
+	// Co-signing does not change what is signed
+	manifest.AddSignature([]byte("cosignature"), "cosigner")
+	cosigned, err := ManifestSigningData(manifest)
+	if err != nil {
+		t.Fatalf("ManifestSigningData failed: %v", err)
+	}
+	if !bytes.Equal(current, cosigned) {
+		t.Error("signing data should not include co-signatures")
+	}
+
+	// The format version is signed, so it cannot be downgraded unnoticed
+	manifest.FormatVersion = geofence.FormatVersionJSON
+	downgraded, err := ManifestSigningData(manifest)
+	if err != nil {
+		t.Fatalf("ManifestSigningData failed: %v", err)
+	}
+	if bytes.Equal(current, downgraded) {
+		t.Error("signing data should depend on the format version")
+	}
+
+	manifest.FormatVersion = geofence.CurrentFormatVersion + 1
+	if _, err := ManifestSigningData(manifest); err == nil {
+		t.Error("expected error for an unsupported format version")
+	}
+}
+
+func TestFenceDeltaFile_RoundTrip(t *testing.T) {
+	delta := &geofence.FenceDeltaFile{
+		FromVersion:   3,
+		ToVersion:     4,
+		FromRootHash:  []byte{1, 2, 3},
+		ToRootHash:    []byte{4, 5, 6},
+		FormatVersion: geofence.FormatVersionProto,
+		Delta: geofence.FenceDelta{
+			Added:      []geofence.FenceItem{*canonicalTestFence()},
+			RemovedIDs: []string{"old"},
+		},
+	}
+
+	unsigned, err := FenceDeltaSigningData(delta)
+	if err != nil {
+		t.Fatalf("FenceDeltaSigningData failed: %v", err)
+	}
+	if !strings.HasPrefix(string(unsigned), FenceDeltaSigningPrefix) {
+		t.Errorf("signing data should start with %q", FenceDeltaSigningPrefix)
+	}
+
+	delta.SetSignature([]byte("signature"), "key")
+	signed, err := SignableFenceDelta{File: delta}.MarshalBinaryForSigning()
+	if err != nil {
+		t.Fatalf("MarshalBinaryForSigning failed: %v", err)
+	}
+	if !bytes.Equal(signed, unsigned) {
+		t.Error("signing data should not include the signature or key ID")
+	}
+
+	data, err := MarshalFenceDeltaFile(delta)
+	if err != nil {
+		t.Fatalf("MarshalFenceDeltaFile failed: %v", err)
+	}
+	decoded, err := UnmarshalFenceDeltaFile(data)
+	if err != nil {
+		t.Fatalf("UnmarshalFenceDeltaFile failed: %v", err)
+	}
+	if decoded.FromVersion != 3 || decoded.ToVersion != 4 || decoded.KeyID != "key" {
+		t.Errorf("decoded delta = %+v, want versions 3 to 4 signed by key", decoded)
+	}
+	if len(decoded.Delta.Added) != 1 || decoded.Delta.Added[0].ID != "stadium" || len(decoded.Delta.RemovedIDs) != 1 {
+		t.Errorf("decoded changes = %+v, want stadium added and one removed", decoded.Delta)
+	}
+	resigned, err := FenceDeltaSigningData(decoded)
+	if err != nil {
+		t.Fatalf("FenceDeltaSigningData failed: %v", err)
+	}
+	if !bytes.Equal(resigned, unsigned) {
+		t.Error("signing data should survive a round trip")
+	}
+
+	// The root hashes are signed, so a delta cannot be moved to another base
+	decoded.FromRootHash = []byte{9}
+	moved, err := FenceDeltaSigningData(decoded)
+	if err != nil {
+		t.Fatalf("FenceDeltaSigningData failed: %v", err)
+	}
+	if bytes.Equal(moved, unsigned) {
+		t.Error("signing data should depend on the root hashes")
+	}
+
+	if _, err := UnmarshalFenceDeltaFile([]byte("corrupt")); err == nil {
+		t.Error("expected error for a corrupt delta")
+	}
+}
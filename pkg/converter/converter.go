@@ -2,14 +2,18 @@
 package converter
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/iannil/geofence-updater-lite/pkg/geofence"
 	pb "github.com/iannil/geofence-updater-lite/pkg/protocol/protobuf"
 )
 
-// FenceItemFromProto converts a Protobuf FenceItem to a Go FenceItem.
-func FenceItemFromProto(pbItem *pb.FenceItem) *geofence.FenceItem {
+// FenceItemFromProto converts a Protobuf FenceItem to a Go FenceItem. It
+// fails if the fence's corridor path cannot be decoded.
+func FenceItemFromProto(pbItem *pb.FenceItem) (*geofence.FenceItem, error) {
 	if pbItem == nil {
-		return nil
+		return nil, nil
 	}
 
 	item := &geofence.FenceItem{
@@ -26,19 +30,74 @@ func FenceItemFromProto(pbItem *pb.FenceItem) *geofence.FenceItem {
 		KeyID:       pbItem.KeyId,
 	}
 
+	if band := pbItem.AltitudeBand; band != nil {
+		item.Altitude = &geofence.AltitudeBand{
+			Floor:     band.FloorMeters,
+			Ceiling:   band.CeilingMeters,
+			Reference: geofence.AltitudeReference(band.Reference),
+		}
+	}
+
+	if sch := pbItem.Schedule; sch != nil {
+		item.Schedule = &geofence.Schedule{TimeZone: sch.TimeZone}
+		for _, r := range sch.Rules {
+			rule := geofence.WeeklyRule{
+				StartMinute: int(r.StartMinute),
+				EndMinute:   int(r.EndMinute),
+			}
+			for _, d := range r.Days {
+				rule.Days = append(rule.Days, time.Weekday(d))
+			}
+			item.Schedule.Rules = append(item.Schedule.Rules, rule)
+		}
+	}
+
+	if c := pbItem.Conditions; c != nil {
+		item.Conditions = &geofence.Conditions{
+			MinMassKg:       c.MinMassKg,
+			MaxMassKg:       c.MaxMassKg,
+			ExemptionTokens: c.ExemptionTokens,
+		}
+		for _, cat := range c.Categories {
+			item.Conditions.Categories = append(item.Conditions.Categories, geofence.AircraftCategory(cat))
+		}
+		for _, op := range c.Operations {
+			item.Conditions.Operations = append(item.Conditions.Operations, geofence.OperationType(op))
+		}
+		for _, op := range c.ExemptOperations {
+			item.Conditions.ExemptOperations = append(item.Conditions.ExemptOperations, geofence.OperationType(op))
+		}
+	}
+
 	// Convert geometry
 	if pbGeom := pbItem.Geometry; pbGeom != nil {
-		item.Geometry = geofence.Geometry{}
+		item.Geometry = geofence.Geometry{Edges: geofence.EdgeType(pbGeom.EdgeType)}
 
 		if poly := pbGeom.GetPolygon(); poly != nil {
-			for _, coord := range poly.Coordinates {
-				item.Geometry.Polygon = append(item.Geometry.Polygon, geofence.Point{
-					Latitude:  coord.Latitude,
-					Longitude: coord.Longitude,
+			item.Geometry.Polygon = pointsFromProto(poly.Coordinates)
+			item.Geometry.Holes = ringsFromProto(poly.Holes)
+		}
+
+		if multi := pbGeom.GetMultiPolygon(); multi != nil {
+			for _, poly := range multi.Polygons {
+				item.Geometry.MultiPolygon = append(item.Geometry.MultiPolygon, geofence.PolygonPart{
+					Outer: pointsFromProto(poly.Coordinates),
+					Holes: ringsFromProto(poly.Holes),
 				})
 			}
 		}
 
+		if corridor := pbGeom.GetCorridor(); corridor != nil {
+			path, err := geofence.DecodePolyline(corridor.EncodedPath)
+			if err != nil {
+				return nil, fmt.Errorf("fence %s: invalid corridor path: %w", pbItem.Id, err)
+			}
+			item.Geometry.Corridor = &geofence.Corridor{
+				Path:      path,
+				HalfWidth: corridor.HalfWidthMeters,
+			}
+		}
+
 		if circle := pbGeom.GetCircle(); circle != nil && circle.Center != nil {
 			p := geofence.Point{
 				Latitude:  circle.Center.Latitude,
@@ -58,7 +117,7 @@ func FenceItemFromProto(pbItem *pb.FenceItem) *geofence.FenceItem {
 		}
 	}
 
-	return item
+	return item, nil
 }
 
 // FenceItemToProto converts a Go FenceItem to a Protobuf FenceItem.
@@ -81,18 +140,75 @@ func FenceItemToProto(item *geofence.FenceItem) *pb.FenceItem {
 		KeyId:           item.KeyID,
 	}
 
+	if band := item.Altitude; band != nil {
+		pbItem.AltitudeBand = &pb.AltitudeBand{
+			FloorMeters:   band.Floor,
+			CeilingMeters: band.Ceiling,
+			Reference:     pb.AltitudeReference(band.Reference),
+		}
+	}
+
+	if sch := item.Schedule; sch != nil {
+		pbItem.Schedule = &pb.Schedule{TimeZone: sch.TimeZone}
+		for _, r := range sch.Rules {
+			rule := &pb.WeeklyRule{
+				StartMinute: uint32(r.StartMinute),
+				EndMinute:   uint32(r.EndMinute),
+			}
+			for _, d := range r.Days {
+				rule.Days = append(rule.Days, uint32(d))
+			}
+			pbItem.Schedule.Rules = append(pbItem.Schedule.Rules, rule)
+		}
+	}
+
+	if c := item.Conditions; c != nil {
+		pbItem.Conditions = &pb.Conditions{
+			MinMassKg:       c.MinMassKg,
+			MaxMassKg:       c.MaxMassKg,
+			ExemptionTokens: c.ExemptionTokens,
+		}
+		for _, cat := range c.Categories {
+			pbItem.Conditions.Categories = append(pbItem.Conditions.Categories, pb.AircraftCategory(cat))
+		}
+		for _, op := range c.Operations {
+			pbItem.Conditions.Operations = append(pbItem.Conditions.Operations, pb.OperationType(op))
+		}
+		for _, op := range c.ExemptOperations {
+			pbItem.Conditions.ExemptOperations = append(pbItem.Conditions.ExemptOperations, pb.OperationType(op))
+		}
+	}
+
 	// Convert geometry - create the Geometry message with appropriate shape
 	if len(item.Geometry.Polygon) > 0 {
-		coords := make([]*pb.Point, len(item.Geometry.Polygon))
-		for i, p := range item.Geometry.Polygon {
-			coords[i] = &pb.Point{
-				Latitude:  p.Latitude,
-				Longitude: p.Longitude,
+		pbItem.Geometry = &pb.Geometry{
+			Shape: &pb.Geometry_Polygon{
+				Polygon: &pb.Polygon{
+					Coordinates: pointsToProto(item.Geometry.Polygon),
+					Holes:       ringsToProto(item.Geometry.Holes),
+				},
+			},
+		}
+	} else if len(item.Geometry.MultiPolygon) > 0 {
+		polygons := make([]*pb.Polygon, len(item.Geometry.MultiPolygon))
+		for i, part := range item.Geometry.MultiPolygon {
+			polygons[i] = &pb.Polygon{
+				Coordinates: pointsToProto(part.Outer),
+				Holes:       ringsToProto(part.Holes),
 			}
 		}
 		pbItem.Geometry = &pb.Geometry{
-			Shape: &pb.Geometry_Polygon{
-				Polygon: &pb.Polygon{Coordinates: coords},
+			Shape: &pb.Geometry_MultiPolygon{
+				MultiPolygon: &pb.MultiPolygon{Polygons: polygons},
+			},
+		}
+	} else if c := item.Geometry.Corridor; c != nil {
+		pbItem.Geometry = &pb.Geometry{
+			Shape: &pb.Geometry_Corridor{
+				Corridor: &pb.Corridor{
+					EncodedPath:     geofence.EncodePolyline(c.Path),
+					HalfWidthMeters: c.HalfWidth,
+				},
 			},
 		}
 	} else if item.Geometry.CircleCenter != nil {
@@ -120,13 +236,68 @@ func FenceItemToProto(item *geofence.FenceItem) *pb.FenceItem {
 		}
 	}
 
+	if pbItem.Geometry != nil {
+		pbItem.Geometry.EdgeType = pb.EdgeType(item.Geometry.Edges)
+	}
+
 	return pbItem
 }
 
+// pointsFromProto converts a list of Protobuf points to Go points.
+func pointsFromProto(coords []*pb.Point) []geofence.Point {
+	if len(coords) == 0 {
+		return nil
+	}
+	points := make([]geofence.Point, len(coords))
+	for i, coord := range coords {
+		points[i] = geofence.Point{
+			Latitude:  coord.Latitude,
+			Longitude: coord.Longitude,
+		}
+	}
+	return points
+}
+
+// pointsToProto converts a list of Go points to Protobuf points.
+func pointsToProto(points []geofence.Point) []*pb.Point {
+	coords := make([]*pb.Point, len(points))
+	for i, p := range points {
+		coords[i] = &pb.Point{
+			Latitude:  p.Latitude,
+			Longitude: p.Longitude,
+		}
+	}
+	return coords
+}
+
+// ringsFromProto converts Protobuf rings to Go rings.
+func ringsFromProto(rings []*pb.Ring) [][]geofence.Point {
+	if len(rings) == 0 {
+		return nil
+	}
+	result := make([][]geofence.Point, len(rings))
+	for i, ring := range rings {
+		result[i] = pointsFromProto(ring.Coordinates)
+	}
+	return result
+}
+
+// ringsToProto converts Go rings to Protobuf rings.
+func ringsToProto(rings [][]geofence.Point) []*pb.Ring {
+	if len(rings) == 0 {
+		return nil
+	}
+	result := make([]*pb.Ring, len(rings))
+	for i, ring := range rings {
+		result[i] = &pb.Ring{Coordinates: pointsToProto(ring)}
+	}
+	return result
+}
+
 // FenceCollectionFromProto converts a Protobuf FenceCollection to Go.
-func FenceCollectionFromProto(pbCol *pb.FenceCollection) *geofence.FenceCollection {
+func FenceCollectionFromProto(pbCol *pb.FenceCollection) (*geofence.FenceCollection, error) {
 	if pbCol == nil {
-		return nil
+		return nil, nil
 	}
 
 	col := &geofence.FenceCollection{
@@ -135,12 +306,16 @@ func FenceCollectionFromProto(pbCol *pb.FenceCollection) *geofence.FenceCollecti
 	}
 
 	for _, pbItem := range pbCol.Items {
-		if item := FenceItemFromProto(pbItem); item != nil {
+		item, err := FenceItemFromProto(pbItem)
+		if err != nil {
+			return nil, err
+		}
+		if item != nil {
 			col.Items = append(col.Items, *item)
 		}
 	}
 
-	return col
+	return col, nil
 }
 
 // FenceCollectionToProto converts a Go FenceCollection to Protobuf.
@@ -163,9 +338,9 @@ func FenceCollectionToProto(col *geofence.FenceCollection) *pb.FenceCollection {
 }
 
 // FenceDeltaFromProto converts a Protobuf FenceDelta to Go.
-func FenceDeltaFromProto(pbDelta *pb.FenceDelta) *geofence.FenceDelta {
+func FenceDeltaFromProto(pbDelta *pb.FenceDelta) (*geofence.FenceDelta, error) {
 	if pbDelta == nil {
-		return nil
+		return nil, nil
 	}
 
 	delta := &geofence.FenceDelta{
@@ -173,18 +348,26 @@ func FenceDeltaFromProto(pbDelta *pb.FenceDelta) *geofence.FenceDelta {
 	}
 
 	for _, pbItem := range pbDelta.Added {
-		if item := FenceItemFromProto(pbItem); item != nil {
+		item, err := FenceItemFromProto(pbItem)
+		if err != nil {
+			return nil, err
+		}
+		if item != nil {
 			delta.Added = append(delta.Added, *item)
 		}
 	}
 
 	for _, pbItem := range pbDelta.Updated {
-		if item := FenceItemFromProto(pbItem); item != nil {
+		item, err := FenceItemFromProto(pbItem)
+		if err != nil {
+			return nil, err
+		}
+		if item != nil {
 			delta.Updated = append(delta.Updated, *item)
 		}
 	}
 
-	return delta
+	return delta, nil
 }
 
 // FenceDeltaToProto converts a Go FenceDelta to Protobuf.
@@ -217,19 +400,24 @@ func ManifestFromProto(pbManifest *pb.Manifest) *geofence.Manifest {
 	}
 
 	return &geofence.Manifest{
-		Version:      pbManifest.Version,
-		Timestamp:    pbManifest.Timestamp,
-		RootHash:     pbManifest.RootHash,
-		DeltaURL:     pbManifest.DeltaUrl,
-		SnapshotURL:  pbManifest.SnapshotUrl,
-		DeltaSize:    pbManifest.DeltaSize,
-		SnapshotSize: pbManifest.SnapshotSize,
-		DeltaHash:    pbManifest.DeltaHash,
-		SnapshotHash: pbManifest.SnapshotHash,
-		MinClientV:   pbManifest.MinClientVersion,
-		Message:      pbManifest.Message,
-		Signature:    pbManifest.Signature,
-		KeyID:        pbManifest.KeyId,
+		Version:       pbManifest.Version,
+		Timestamp:     pbManifest.Timestamp,
+		RootHash:      pbManifest.RootHash,
+		DeltaURL:      pbManifest.DeltaUrl,
+		SnapshotURL:   pbManifest.SnapshotUrl,
+		DeltaSize:     pbManifest.DeltaSize,
+		SnapshotSize:  pbManifest.SnapshotSize,
+		DeltaHash:     pbManifest.DeltaHash,
+		SnapshotHash:  pbManifest.SnapshotHash,
+		MinClientV:    pbManifest.MinClientVersion,
+		Message:       pbManifest.Message,
+		FormatVersion: pbManifest.FormatVersion,
+		Signature:     pbManifest.Signature,
+		KeyID:         pbManifest.KeyId,
+		Signatures:    signaturesFromProto(pbManifest.Signatures),
+
+		SnapshotEncodings: encodingsFromProto(pbManifest.SnapshotEncodings),
+		DeltaEncodings:    encodingsFromProto(pbManifest.DeltaEncodings),
 	}
 }
 
@@ -251,7 +439,103 @@ func ManifestToProto(manifest *geofence.Manifest) *pb.Manifest {
 		SnapshotHash:     manifest.SnapshotHash,
 		MinClientVersion: manifest.MinClientV,
 		Message:          manifest.Message,
+		FormatVersion:    manifest.FormatVersion,
 		Signature:        manifest.Signature,
 		KeyId:            manifest.KeyID,
+		Signatures:       signaturesToProto(manifest.Signatures),
+
+		SnapshotEncodings: encodingsToProto(manifest.SnapshotEncodings),
+		DeltaEncodings:    encodingsToProto(manifest.DeltaEncodings),
+	}
+}
+
+// encodingsFromProto converts Protobuf file encodings to Go.
+func encodingsFromProto(pbEncs []*pb.FileEncoding) []geofence.FileEncoding {
+	if len(pbEncs) == 0 {
+		return nil
+	}
+	encs := make([]geofence.FileEncoding, len(pbEncs))
+	for i, e := range pbEncs {
+		encs[i] = geofence.FileEncoding{Codec: e.Codec, URL: e.Url, Size: e.Size, Hash: e.Hash}
+	}
+	return encs
+}
+
+// encodingsToProto converts Go file encodings to Protobuf.
+func encodingsToProto(encs []geofence.FileEncoding) []*pb.FileEncoding {
+	if len(encs) == 0 {
+		return nil
+	}
+	pbEncs := make([]*pb.FileEncoding, len(encs))
+	for i, e := range encs {
+		pbEncs[i] = &pb.FileEncoding{Codec: e.Codec, Url: e.URL, Size: e.Size, Hash: e.Hash}
+	}
+	return pbEncs
+}
+
+// signaturesFromProto converts Protobuf manifest co-signatures to Go.
+func signaturesFromProto(pbSigs []*pb.ManifestSignature) []geofence.ManifestSignature {
+	if len(pbSigs) == 0 {
+		return nil
+	}
+	sigs := make([]geofence.ManifestSignature, len(pbSigs))
+	for i, s := range pbSigs {
+		sigs[i] = geofence.ManifestSignature{Signature: s.Signature, KeyID: s.KeyId}
+	}
+	return sigs
+}
+
+// signaturesToProto converts Go manifest co-signatures to Protobuf.
+func signaturesToProto(sigs []geofence.ManifestSignature) []*pb.ManifestSignature {
+	if len(sigs) == 0 {
+		return nil
+	}
+	pbSigs := make([]*pb.ManifestSignature, len(sigs))
+	for i, s := range sigs {
+		pbSigs[i] = &pb.ManifestSignature{Signature: s.Signature, KeyId: s.KeyID}
+	}
+	return pbSigs
+}
+
+// FenceDeltaFileFromProto converts a Protobuf FenceDeltaFile to Go.
+func FenceDeltaFileFromProto(pbFile *pb.FenceDeltaFile) (*geofence.FenceDeltaFile, error) {
+	if pbFile == nil {
+		return nil, nil
+	}
+
+	file := &geofence.FenceDeltaFile{
+		FromVersion:   pbFile.FromVersion,
+		ToVersion:     pbFile.ToVersion,
+		FromRootHash:  pbFile.FromRootHash,
+		ToRootHash:    pbFile.ToRootHash,
+		FormatVersion: pbFile.FormatVersion,
+		Signature:     pbFile.Signature,
+		KeyID:         pbFile.KeyId,
+	}
+	delta, err := FenceDeltaFromProto(pbFile.Delta)
+	if err != nil {
+		return nil, err
+	}
+	if delta != nil {
+		file.Delta = *delta
+	}
+	return file, nil
+}
+
+// FenceDeltaFileToProto converts a Go FenceDeltaFile to Protobuf.
+func FenceDeltaFileToProto(file *geofence.FenceDeltaFile) *pb.FenceDeltaFile {
+	if file == nil {
+		return nil
+	}
+
+	return &pb.FenceDeltaFile{
+		FromVersion:   file.FromVersion,
+		ToVersion:     file.ToVersion,
+		FromRootHash:  file.FromRootHash,
+		ToRootHash:    file.ToRootHash,
+		FormatVersion: file.FormatVersion,
+		Delta:         FenceDeltaToProto(&file.Delta),
+		Signature:     file.Signature,
+		KeyId:         file.KeyID,
 	}
 }
@@ -1,14 +1,20 @@
 package converter
 
 import (
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/iannil/geofence-updater-lite/pkg/geofence"
 	pb "github.com/iannil/geofence-updater-lite/pkg/protocol/protobuf"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestFenceItemFromProto_Nil(t *testing.T) {
-	result := FenceItemFromProto(nil)
+	result, err := FenceItemFromProto(nil)
+	if err != nil {
+		t.Fatalf("FenceItemFromProto failed: %v", err)
+	}
 	if result != nil {
 		t.Errorf("expected nil, got %v", result)
 	}
@@ -29,7 +35,10 @@ func TestFenceItemFromProto_Basic(t *testing.T) {
 		KeyId:             "key123",
 	}
 
-	result := FenceItemFromProto(pbItem)
+	result, err := FenceItemFromProto(pbItem)
+	if err != nil {
+		t.Fatalf("FenceItemFromProto failed: %v", err)
+	}
 
 	if result == nil {
 		t.Fatal("expected non-nil result")
@@ -80,7 +89,10 @@ func TestFenceItemFromProto_Polygon(t *testing.T) {
 		},
 	}
 
-	result := FenceItemFromProto(pbItem)
+	result, err := FenceItemFromProto(pbItem)
+	if err != nil {
+		t.Fatalf("FenceItemFromProto failed: %v", err)
+	}
 
 	if result == nil {
 		t.Fatal("expected non-nil result")
@@ -109,7 +121,10 @@ func TestFenceItemFromProto_Circle(t *testing.T) {
 		},
 	}
 
-	result := FenceItemFromProto(pbItem)
+	result, err := FenceItemFromProto(pbItem)
+	if err != nil {
+		t.Fatalf("FenceItemFromProto failed: %v", err)
+	}
 
 	if result == nil {
 		t.Fatal("expected non-nil result")
@@ -140,7 +155,10 @@ func TestFenceItemFromProto_BBox(t *testing.T) {
 		},
 	}
 
-	result := FenceItemFromProto(pbItem)
+	result, err := FenceItemFromProto(pbItem)
+	if err != nil {
+		t.Fatalf("FenceItemFromProto failed: %v", err)
+	}
 
 	if result == nil {
 		t.Fatal("expected non-nil result")
@@ -298,7 +316,10 @@ func TestFenceItemRoundTrip(t *testing.T) {
 
 	// Convert to proto and back
 	pbItem := FenceItemToProto(original)
-	result := FenceItemFromProto(pbItem)
+	result, err := FenceItemFromProto(pbItem)
+	if err != nil {
+		t.Fatalf("FenceItemFromProto failed: %v", err)
+	}
 
 	if result.ID != original.ID {
 		t.Errorf("ID = %s, want %s", result.ID, original.ID)
@@ -314,8 +335,348 @@ func TestFenceItemRoundTrip(t *testing.T) {
 	}
 }
 
+func TestFenceItemRoundTrip_PolygonWithHoles(t *testing.T) {
+	original := &geofence.FenceItem{
+		ID: "donut-fence",
+		Geometry: geofence.Geometry{
+			Polygon: []geofence.Point{
+				{Latitude: 39.0, Longitude: 116.0},
+				{Latitude: 39.0, Longitude: 117.0},
+				{Latitude: 40.0, Longitude: 117.0},
+				{Latitude: 40.0, Longitude: 116.0},
+			},
+			Holes: [][]geofence.Point{
+				{
+					{Latitude: 39.4, Longitude: 116.4},
+					{Latitude: 39.4, Longitude: 116.6},
+					{Latitude: 39.6, Longitude: 116.6},
+				},
+			},
+		},
+	}
+
+	pbItem := FenceItemToProto(original)
+	if len(pbItem.Geometry.GetPolygon().GetHoles()) != 1 {
+		t.Fatalf("proto holes = %d, want 1", len(pbItem.Geometry.GetPolygon().GetHoles()))
+	}
+
+	result, err := FenceItemFromProto(pbItem)
+	if err != nil {
+		t.Fatalf("FenceItemFromProto failed: %v", err)
+	}
+	if !reflect.DeepEqual(result.Geometry, original.Geometry) {
+		t.Errorf("Geometry = %+v, want %+v", result.Geometry, original.Geometry)
+	}
+}
+
+func TestFenceItemRoundTrip_EdgeType(t *testing.T) {
+	original := &geofence.FenceItem{
+		ID: "regional-fence",
+		Geometry: geofence.Geometry{
+			Polygon: []geofence.Point{
+				{Latitude: 50, Longitude: 0},
+				{Latitude: 50, Longitude: 60},
+				{Latitude: 60, Longitude: 60},
+			},
+			Edges: geofence.EdgeTypeGreatCircle,
+		},
+	}
+
+	pbItem := FenceItemToProto(original)
+	if pbItem.Geometry.EdgeType != pb.EdgeType_EDGE_TYPE_GREAT_CIRCLE {
+		t.Errorf("proto EdgeType = %v, want EDGE_TYPE_GREAT_CIRCLE", pbItem.Geometry.EdgeType)
+	}
+
+	result, err := FenceItemFromProto(pbItem)
+	if err != nil {
+		t.Fatalf("FenceItemFromProto failed: %v", err)
+	}
+	if result.Geometry.Edges != geofence.EdgeTypeGreatCircle {
+		t.Errorf("Edges = %v, want GREAT_CIRCLE", result.Geometry.Edges)
+	}
+}
+
+func TestFenceItemRoundTrip_MultiPolygon(t *testing.T) {
+	original := &geofence.FenceItem{
+		ID: "multi-fence",
+		Geometry: geofence.Geometry{
+			MultiPolygon: []geofence.PolygonPart{
+				{
+					Outer: []geofence.Point{
+						{Latitude: 0, Longitude: 0},
+						{Latitude: 0, Longitude: 1},
+						{Latitude: 1, Longitude: 1},
+					},
+				},
+				{
+					Outer: []geofence.Point{
+						{Latitude: 10, Longitude: 10},
+						{Latitude: 10, Longitude: 12},
+						{Latitude: 12, Longitude: 12},
+						{Latitude: 12, Longitude: 10},
+					},
+					Holes: [][]geofence.Point{
+						{
+							{Latitude: 10.5, Longitude: 10.5},
+							{Latitude: 10.5, Longitude: 11.5},
+							{Latitude: 11.5, Longitude: 11.5},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	pbItem := FenceItemToProto(original)
+	if pbItem.Geometry.GetMultiPolygon() == nil {
+		t.Fatal("expected multi_polygon shape")
+	}
+
+	result, err := FenceItemFromProto(pbItem)
+	if err != nil {
+		t.Fatalf("FenceItemFromProto failed: %v", err)
+	}
+	if !reflect.DeepEqual(result.Geometry, original.Geometry) {
+		t.Errorf("Geometry = %+v, want %+v", result.Geometry, original.Geometry)
+	}
+}
+
+func TestFenceItemRoundTrip_Corridor(t *testing.T) {
+	original := &geofence.FenceItem{
+		ID:   "route-fence",
+		Type: geofence.FenceTypeTempRestriction,
+		Geometry: geofence.Geometry{
+			Corridor: &geofence.Corridor{
+				Path: []geofence.Point{
+					{Latitude: 39.9042, Longitude: 116.4074},
+					{Latitude: 39.9142, Longitude: 116.4174},
+					{Latitude: 39.9242, Longitude: 116.4074},
+				},
+				HalfWidth: 500,
+			},
+		},
+	}
+
+	pbItem := FenceItemToProto(original)
+	corridor := pbItem.Geometry.GetCorridor()
+	if corridor == nil {
+		t.Fatal("expected corridor geometry in proto")
+	}
+	if corridor.EncodedPath == "" {
+		t.Error("expected encoded path")
+	}
+
+	result, err := FenceItemFromProto(pbItem)
+	if err != nil {
+		t.Fatalf("FenceItemFromProto failed: %v", err)
+	}
+	if !reflect.DeepEqual(result.Geometry, original.Geometry) {
+		t.Errorf("Geometry = %+v, want %+v", result.Geometry, original.Geometry)
+	}
+
+	// A truncated path fails the fence and anything holding it rather
+	// than decoding to an empty corridor
+	corridor.EncodedPath = corridor.EncodedPath[:len(corridor.EncodedPath)-1]
+	if _, err := FenceItemFromProto(pbItem); err == nil {
+		t.Error("expected error for a malformed corridor path")
+	}
+	if _, err := FenceCollectionFromProto(&pb.FenceCollection{Items: []*pb.FenceItem{pbItem}}); err == nil {
+		t.Error("expected error for a collection with a malformed corridor path")
+	}
+	if _, err := FenceDeltaFromProto(&pb.FenceDelta{Updated: []*pb.FenceItem{pbItem}}); err == nil {
+		t.Error("expected error for a delta with a malformed corridor path")
+	}
+}
+
+func TestFenceItemRoundTrip_AltitudeBand(t *testing.T) {
+	original := &geofence.FenceItem{
+		ID:   "band-fence",
+		Type: geofence.FenceTypeTempRestriction,
+		Altitude: &geofence.AltitudeBand{
+			Floor:     120,
+			Ceiling:   1500,
+			Reference: geofence.AltitudeReferenceMSL,
+		},
+	}
+
+	pbItem := FenceItemToProto(original)
+	if pbItem.AltitudeBand == nil {
+		t.Fatal("expected altitude band in proto")
+	}
+	if pbItem.AltitudeBand.Reference != pb.AltitudeReference_ALTITUDE_REFERENCE_MSL {
+		t.Errorf("Reference = %v, want MSL", pbItem.AltitudeBand.Reference)
+	}
+
+	result, err := FenceItemFromProto(pbItem)
+	if err != nil {
+		t.Fatalf("FenceItemFromProto failed: %v", err)
+	}
+	if result.Altitude == nil || *result.Altitude != *original.Altitude {
+		t.Errorf("Altitude = %+v, want %+v", result.Altitude, original.Altitude)
+	}
+
+	// Fences without a band stay without one
+	plain, err := FenceItemFromProto(FenceItemToProto(&geofence.FenceItem{ID: "plain"}))
+	if err != nil {
+		t.Fatalf("FenceItemFromProto failed: %v", err)
+	}
+	if plain.Altitude != nil {
+		t.Errorf("Altitude = %+v, want nil", plain.Altitude)
+	}
+}
+
+func TestFenceItemRoundTrip_Schedule(t *testing.T) {
+	original := &geofence.FenceItem{
+		ID:   "stadium",
+		Type: geofence.FenceTypeTempRestriction,
+		Schedule: &geofence.Schedule{
+			TimeZone: "Europe/Berlin",
+			Rules: []geofence.WeeklyRule{
+				{Days: []time.Weekday{time.Saturday}, StartMinute: 14 * 60, EndMinute: 18 * 60},
+				{StartMinute: 22 * 60, EndMinute: 6 * 60},
+			},
+		},
+	}
+
+	pbItem := FenceItemToProto(original)
+	if pbItem.Schedule == nil || len(pbItem.Schedule.Rules) != 2 {
+		t.Fatalf("Schedule = %v, want 2 rules", pbItem.Schedule)
+	}
+	if days := pbItem.Schedule.Rules[0].Days; len(days) != 1 || days[0] != 6 {
+		t.Errorf("Days = %v, want [6]", days)
+	}
+
+	result, err := FenceItemFromProto(pbItem)
+	if err != nil {
+		t.Fatalf("FenceItemFromProto failed: %v", err)
+	}
+	if !reflect.DeepEqual(result.Schedule, original.Schedule) {
+		t.Errorf("Schedule = %+v, want %+v", result.Schedule, original.Schedule)
+	}
+}
+
+func TestFenceItemRoundTrip_Conditions(t *testing.T) {
+	original := &geofence.FenceItem{
+		ID:   "no-recreational",
+		Type: geofence.FenceTypeTempRestriction,
+		Conditions: &geofence.Conditions{
+			Categories:       []geofence.AircraftCategory{geofence.AircraftCategoryMultirotor, geofence.AircraftCategoryFixedWing},
+			Operations:       []geofence.OperationType{geofence.OperationTypeRecreational},
+			ExemptOperations: []geofence.OperationType{geofence.OperationTypeEmergency},
+			MinMassKg:        0.25,
+			MaxMassKg:        25,
+			ExemptionTokens:  []string{"stadium-media"},
+		},
+	}
+
+	pbItem := FenceItemToProto(original)
+	if pbItem.Conditions == nil || len(pbItem.Conditions.Categories) != 2 {
+		t.Fatalf("Conditions = %v, want 2 categories", pbItem.Conditions)
+	}
+	if ops := pbItem.Conditions.ExemptOperations; len(ops) != 1 || ops[0] != pb.OperationType_OPERATION_TYPE_EMERGENCY {
+		t.Errorf("ExemptOperations = %v, want [OPERATION_TYPE_EMERGENCY]", ops)
+	}
+
+	result, err := FenceItemFromProto(pbItem)
+	if err != nil {
+		t.Fatalf("FenceItemFromProto failed: %v", err)
+	}
+	if !reflect.DeepEqual(result.Conditions, original.Conditions) {
+		t.Errorf("Conditions = %+v, want %+v", result.Conditions, original.Conditions)
+	}
+}
+
+func TestFenceItemRoundTrip_SigningData(t *testing.T) {
+	fences := []*geofence.FenceItem{
+		{
+			ID:          "everything",
+			Type:        geofence.FenceTypeAltitudeLimit,
+			StartTS:     1700000000,
+			EndTS:       1800000000,
+			Priority:    10,
+			MaxAltitude: 120,
+			Name:        "Every field",
+			Description: "Signed <fence> & friends",
+			Geometry: geofence.Geometry{
+				Polygon: []geofence.Point{
+					{Latitude: 39.1234567, Longitude: 116.1},
+					{Latitude: 39.1, Longitude: 116.9876543},
+					{Latitude: 39.9, Longitude: 116.5},
+				},
+				Holes: [][]geofence.Point{{
+					{Latitude: 39.4, Longitude: 116.4},
+					{Latitude: 39.5, Longitude: 116.5},
+					{Latitude: 39.4, Longitude: 116.6},
+				}},
+				Edges: geofence.EdgeTypeGreatCircle,
+			},
+			Altitude: &geofence.AltitudeBand{Floor: 30.5, Ceiling: 120, Reference: geofence.AltitudeReferenceMSL},
+			Schedule: &geofence.Schedule{TimeZone: "Europe/Berlin", Rules: []geofence.WeeklyRule{
+				{Days: []time.Weekday{time.Saturday}, StartMinute: 600, EndMinute: 1080},
+			}},
+			Conditions: &geofence.Conditions{
+				Operations:      []geofence.OperationType{geofence.OperationTypeRecreational},
+				MaxMassKg:       0.25,
+				ExemptionTokens: []string{"media"},
+			},
+		},
+		{
+			ID:   "circle",
+			Type: geofence.FenceTypePermanentNoFly,
+			Geometry: geofence.Geometry{
+				CircleCenter: &geofence.Point{Latitude: -33.9461, Longitude: 151.1772},
+				CircleRadius: 5000,
+			},
+			Schedule: &geofence.Schedule{},
+		},
+		{
+			ID:   "corridor",
+			Type: geofence.FenceTypeTempRestriction,
+			Geometry: geofence.Geometry{
+				Corridor: &geofence.Corridor{
+					Path:      geofence.QuantizePolyline([]geofence.Point{{Latitude: 1, Longitude: 2}, {Latitude: 1.5, Longitude: 2.5}}),
+					HalfWidth: 250,
+				},
+			},
+		},
+	}
+
+	for _, original := range fences {
+		t.Run(original.ID, func(t *testing.T) {
+			want, err := original.MarshalBinaryForSigning()
+			if err != nil {
+				t.Fatalf("MarshalBinaryForSigning failed: %v", err)
+			}
+
+			data, err := proto.Marshal(FenceItemToProto(original))
+			if err != nil {
+				t.Fatalf("proto.Marshal failed: %v", err)
+			}
+			var pbItem pb.FenceItem
+			if err := proto.Unmarshal(data, &pbItem); err != nil {
+				t.Fatalf("proto.Unmarshal failed: %v", err)
+			}
+
+			item, err := FenceItemFromProto(&pbItem)
+			if err != nil {
+				t.Fatalf("FenceItemFromProto failed: %v", err)
+			}
+			got, err := item.MarshalBinaryForSigning()
+			if err != nil {
+				t.Fatalf("MarshalBinaryForSigning failed: %v", err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("signing data changed in transit:\n got %s\nwant %s", got, want)
+			}
+		})
+	}
+}
+
 func TestFenceCollectionFromProto_Nil(t *testing.T) {
-	result := FenceCollectionFromProto(nil)
+	result, err := FenceCollectionFromProto(nil)
+	if err != nil {
+		t.Fatalf("FenceCollectionFromProto failed: %v", err)
+	}
 	if result != nil {
 		t.Errorf("expected nil, got %v", result)
 	}
@@ -331,7 +692,10 @@ func TestFenceCollectionFromProto_Basic(t *testing.T) {
 		},
 	}
 
-	result := FenceCollectionFromProto(pbCol)
+	result, err := FenceCollectionFromProto(pbCol)
+	if err != nil {
+		t.Fatalf("FenceCollectionFromProto failed: %v", err)
+	}
 
 	if result == nil {
 		t.Fatal("expected non-nil result")
@@ -379,7 +743,10 @@ func TestFenceCollectionToProto_Basic(t *testing.T) {
 }
 
 func TestFenceDeltaFromProto_Nil(t *testing.T) {
-	result := FenceDeltaFromProto(nil)
+	result, err := FenceDeltaFromProto(nil)
+	if err != nil {
+		t.Fatalf("FenceDeltaFromProto failed: %v", err)
+	}
 	if result != nil {
 		t.Errorf("expected nil, got %v", result)
 	}
@@ -397,7 +764,10 @@ func TestFenceDeltaFromProto_Basic(t *testing.T) {
 		},
 	}
 
-	result := FenceDeltaFromProto(pbDelta)
+	result, err := FenceDeltaFromProto(pbDelta)
+	if err != nil {
+		t.Fatalf("FenceDeltaFromProto failed: %v", err)
+	}
 
 	if result == nil {
 		t.Fatal("expected non-nil result")
@@ -527,19 +897,21 @@ func TestManifestToProto_Basic(t *testing.T) {
 
 func TestManifestRoundTrip(t *testing.T) {
 	original := &geofence.Manifest{
-		Version:      50,
-		Timestamp:    1111111111,
-		RootHash:     []byte("original-root"),
-		DeltaURL:     "/patches/delta.bin",
-		SnapshotURL:  "/snapshots/snap.bin",
-		DeltaSize:    500,
-		SnapshotSize: 2500,
-		DeltaHash:    []byte("delta-hash"),
-		SnapshotHash: []byte("snap-hash"),
-		MinClientV:   150,
-		Message:      "Roundtrip manifest",
-		Signature:    []byte("roundtrip-sig"),
-		KeyID:        "roundtrip-key",
+		Version:       50,
+		Timestamp:     1111111111,
+		RootHash:      []byte("original-root"),
+		DeltaURL:      "/patches/delta.bin",
+		SnapshotURL:   "/snapshots/snap.bin",
+		DeltaSize:     500,
+		SnapshotSize:  2500,
+		DeltaHash:     []byte("delta-hash"),
+		SnapshotHash:  []byte("snap-hash"),
+		MinClientV:    150,
+		Message:       "Roundtrip manifest",
+		FormatVersion: geofence.FormatVersionProto,
+		Signature:     []byte("roundtrip-sig"),
+		KeyID:         "roundtrip-key",
+		Signatures:    []geofence.ManifestSignature{{Signature: []byte("cosig"), KeyID: "cosign-key"}},
 	}
 
 	pbManifest := ManifestToProto(original)
@@ -557,7 +929,13 @@ func TestManifestRoundTrip(t *testing.T) {
 	if result.Message != original.Message {
 		t.Errorf("Message = %s, want %s", result.Message, original.Message)
 	}
+	if result.FormatVersion != original.FormatVersion {
+		t.Errorf("FormatVersion = %d, want %d", result.FormatVersion, original.FormatVersion)
+	}
 	if result.KeyID != original.KeyID {
 		t.Errorf("KeyID = %s, want %s", result.KeyID, original.KeyID)
 	}
+	if len(result.Signatures) != 1 || result.Signatures[0].KeyID != "cosign-key" || string(result.Signatures[0].Signature) != "cosig" {
+		t.Errorf("Signatures = %+v, want the co-signature", result.Signatures)
+	}
 }
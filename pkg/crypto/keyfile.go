@@ -0,0 +1,221 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// ErrWrongPassphrase is returned when an encrypted key does not decrypt,
+// either because the passphrase is wrong or the file was modified.
+var ErrWrongPassphrase = errors.New("wrong passphrase or corrupted key file")
+
+const (
+	// EncryptedKeyVersion is the version of the encrypted key file format.
+	EncryptedKeyVersion = 1
+
+	// keyFileAADPrefix starts the additional data authenticated with the
+	// encrypted key, binding the public key in the file to it.
+	keyFileAADPrefix = "GUL-KEYFILE-V1\n"
+
+	kdfScrypt     = "scrypt"
+	cipherAES256  = "aes-256-gcm"
+	saltSize      = 32
+	derivedKeyLen = 32
+
+	// maxScryptMemory bounds the memory a key file can make scrypt use
+	// (128 * N * r bytes), so a crafted file cannot exhaust the publisher.
+	maxScryptMemory = 1 << 30
+)
+
+// ScryptParams are the scrypt cost parameters used to derive the key
+// encryption key from a passphrase.
+type ScryptParams struct {
+	N int `json:"n"`
+	R int `json:"r"`
+	P int `json:"p"`
+}
+
+// DefaultScryptParams are the parameters new key files are encrypted with,
+// taking well under a second and 32 MiB on current hardware.
+var DefaultScryptParams = ScryptParams{N: 1 << 15, R: 8, P: 1}
+
+func (p ScryptParams) validate() error {
+	if p.N <= 1 || p.N&(p.N-1) != 0 {
+		return fmt.Errorf("scrypt N must be a power of 2 greater than 1, got %d", p.N)
+	}
+	if p.R <= 0 || p.P <= 0 {
+		return fmt.Errorf("scrypt r and p must be positive")
+	}
+	if int64(p.N)*int64(p.R) > maxScryptMemory/128 {
+		return fmt.Errorf("scrypt parameters N=%d r=%d exceed the memory limit", p.N, p.R)
+	}
+	return nil
+}
+
+// EncryptedKey is a private key encrypted with a passphrase, as stored in
+// an encrypted key file. The public key and key ID are kept in the clear
+// so the file can be identified without the passphrase.
+type EncryptedKey struct {
+	Version      int          `json:"version"`
+	KeyID        string       `json:"key_id"`
+	PublicKeyHex string       `json:"public_key_hex"`
+	KDF          string       `json:"kdf"`
+	KDFParams    ScryptParams `json:"kdf_params"`
+	Salt         []byte       `json:"salt"`
+	Cipher       string       `json:"cipher"`
+	Nonce        []byte       `json:"nonce"`
+	Ciphertext   []byte       `json:"ciphertext"`
+}
+
+// EncryptKey encrypts an Ed25519 private key with a key derived from the
+// passphrase with scrypt, using AES-256-GCM.
+func EncryptKey(privateKey, passphrase []byte) (*EncryptedKey, error) {
+	return encryptKey(privateKey, passphrase, DefaultScryptParams)
+}
+
+func encryptKey(privateKey, passphrase []byte, params ScryptParams) (*EncryptedKey, error) {
+	if len(privateKey) != PrivateKeySize {
+		return nil, fmt.Errorf("invalid private key size: %d", len(privateKey))
+	}
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("passphrase must not be empty")
+	}
+	publicKey := ed25519.PrivateKey(privateKey).Public().(ed25519.PublicKey)
+
+	e := &EncryptedKey{
+		Version:      EncryptedKeyVersion,
+		KeyID:        computeKeyID(publicKey),
+		PublicKeyHex: MarshalPublicKeyHex(publicKey),
+		KDF:          kdfScrypt,
+		KDFParams:    params,
+		Salt:         make([]byte, saltSize),
+		Cipher:       cipherAES256,
+	}
+	if _, err := rand.Read(e.Salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	aead, err := e.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	e.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(e.Nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	// Only the seed is stored; the rest of the key is derived from it
+	seed := ed25519.PrivateKey(privateKey).Seed()
+	e.Ciphertext = aead.Seal(nil, e.Nonce, seed, e.additionalData(publicKey))
+	clear(seed)
+	return e, nil
+}
+
+// Decrypt decrypts the private key with the passphrase and returns its key
+// pair. It returns ErrWrongPassphrase if the key does not decrypt.
+func (e *EncryptedKey) Decrypt(passphrase []byte) (*KeyPair, error) {
+	if e.Version != EncryptedKeyVersion {
+		return nil, fmt.Errorf("unsupported key file version %d", e.Version)
+	}
+	if e.KDF != kdfScrypt || e.Cipher != cipherAES256 {
+		return nil, fmt.Errorf("unsupported key file encryption %s/%s", e.KDF, e.Cipher)
+	}
+	publicKey, err := UnmarshalPublicKeyHex(e.PublicKeyHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key file public key: %w", err)
+	}
+
+	aead, err := e.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if len(e.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("invalid key file nonce size: %d", len(e.Nonce))
+	}
+	seed, err := aead.Open(nil, e.Nonce, e.Ciphertext, e.additionalData(publicKey))
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid key file seed size: %d", len(seed))
+	}
+	privateKey := ed25519.NewKeyFromSeed(seed)
+	clear(seed)
+
+	return DeriveKeyPair(publicKey, privateKey)
+}
+
+// aead derives the key encryption key from the passphrase.
+func (e *EncryptedKey) aead(passphrase []byte) (cipher.AEAD, error) {
+	if err := e.KDFParams.validate(); err != nil {
+		return nil, err
+	}
+	if len(e.Salt) != saltSize {
+		return nil, fmt.Errorf("invalid key file salt size: %d", len(e.Salt))
+	}
+	key, err := scrypt.Key(passphrase, e.Salt, e.KDFParams.N, e.KDFParams.R, e.KDFParams.P, derivedKeyLen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	defer clear(key)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+func (e *EncryptedKey) additionalData(publicKey []byte) []byte {
+	return append([]byte(keyFileAADPrefix), publicKey...)
+}
+
+// WriteEncryptedKeyFile encrypts a private key with the passphrase and
+// writes it to a new file at path, readable only by the owner. It fails if
+// the file exists, so an existing key is never overwritten.
+func WriteEncryptedKeyFile(path string, privateKey, passphrase []byte) (*EncryptedKey, error) {
+	e, err := EncryptKey(privateKey, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode key file: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create key file: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to write key file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to write key file: %w", err)
+	}
+	return e, nil
+}
+
+// ReadEncryptedKeyFile reads an encrypted key file without decrypting it.
+func ReadEncryptedKeyFile(path string) (*EncryptedKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	var e EncryptedKey
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, fmt.Errorf("failed to parse key file: %w", err)
+	}
+	return &e, nil
+}
@@ -0,0 +1,120 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testScryptParams keep the tests fast; real key files use DefaultScryptParams.
+var testScryptParams = ScryptParams{N: 1 << 10, R: 8, P: 1}
+
+func TestEncryptedKey_Decrypt(t *testing.T) {
+	kp, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair failed: %v", err)
+	}
+	passphrase := []byte("correct horse battery staple")
+
+	encrypted, err := encryptKey(kp.PrivateKey, passphrase, testScryptParams)
+	if err != nil {
+		t.Fatalf("encryptKey failed: %v", err)
+	}
+	if encrypted.KeyID != kp.KeyID {
+		t.Errorf("KeyID = %s, want %s", encrypted.KeyID, kp.KeyID)
+	}
+	if bytes.Contains(encrypted.Ciphertext, kp.PrivateKey[:32]) {
+		t.Error("ciphertext contains the private key seed")
+	}
+
+	decrypted, err := encrypted.Decrypt(passphrase)
+	if err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+	if !bytes.Equal(decrypted.PrivateKey, kp.PrivateKey) || decrypted.KeyID != kp.KeyID {
+		t.Error("decrypted key pair does not match the original")
+	}
+
+	other, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair failed: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		passphrase string
+		modify     func(e *EncryptedKey)
+		wantErr    error
+	}{
+		{"wrong passphrase", "wrong", func(e *EncryptedKey) {}, ErrWrongPassphrase},
+		{"tampered ciphertext", string(passphrase), func(e *EncryptedKey) { e.Ciphertext[0] ^= 1 }, ErrWrongPassphrase},
+		{"swapped public key", string(passphrase), func(e *EncryptedKey) { e.PublicKeyHex = MarshalPublicKeyHex(other.PublicKey) }, ErrWrongPassphrase},
+		{"unsupported version", string(passphrase), func(e *EncryptedKey) { e.Version = 2 }, nil},
+		{"excessive scrypt cost", string(passphrase), func(e *EncryptedKey) { e.KDFParams.N = 1 << 30 }, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := *encrypted
+			e.Ciphertext = bytes.Clone(encrypted.Ciphertext)
+			tt.modify(&e)
+			_, err := e.Decrypt([]byte(tt.passphrase))
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Decrypt() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := encryptKey(kp.PrivateKey, nil, testScryptParams); err == nil {
+		t.Error("expected error for an empty passphrase")
+	}
+}
+
+func TestWriteEncryptedKeyFile(t *testing.T) {
+	kp, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair failed: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "private.key.enc")
+	passphrase := []byte("passphrase")
+
+	if _, err := WriteEncryptedKeyFile(path, kp.PrivateKey, passphrase); err != nil {
+		t.Fatalf("WriteEncryptedKeyFile failed: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("key file mode = %v, want 0600", info.Mode().Perm())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if bytes.Contains(data, []byte(MarshalPrivateKeyHex(kp.PrivateKey)[:64])) {
+		t.Error("key file contains the plaintext private key")
+	}
+
+	if _, err := WriteEncryptedKeyFile(path, kp.PrivateKey, passphrase); err == nil {
+		t.Error("expected error overwriting an existing key file")
+	}
+
+	encrypted, err := ReadEncryptedKeyFile(path)
+	if err != nil {
+		t.Fatalf("ReadEncryptedKeyFile failed: %v", err)
+	}
+	decrypted, err := encrypted.Decrypt(passphrase)
+	if err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+	if decrypted.KeyID != kp.KeyID {
+		t.Errorf("KeyID = %s, want %s", decrypted.KeyID, kp.KeyID)
+	}
+}
@@ -0,0 +1,360 @@
+package crypto
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrUnknownKey is returned when a signature names a key that is not trusted.
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// ErrInvalidSignature is returned when a signature does not verify.
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// ErrKeyNotValid is returned when a signature is made with a trusted key
+// outside its validity window.
+var ErrKeyNotValid = errors.New("key not valid at this time")
+
+// ErrKeyRole is returned when a signature is made with a trusted key that
+// does not hold the role required for the signed data.
+var ErrKeyRole = errors.New("key not trusted for this role")
+
+// ErrStaleRotation is returned for a key rotation announcement whose
+// sequence number is not newer than the last one applied.
+var ErrStaleRotation = errors.New("stale key rotation")
+
+// ErrThreshold is returned when fewer trusted keys than required made valid
+// signatures.
+var ErrThreshold = errors.New("not enough valid signatures")
+
+// Signable is implemented by data that carries a detached signature, such as
+// fences, manifests and unlock tokens.
+type Signable interface {
+	// MarshalBinaryForSigning returns the bytes the signature is computed
+	// over, excluding the signature itself.
+	MarshalBinaryForSigning() ([]byte, error)
+}
+
+// KeyRole names what a trusted key may sign.
+type KeyRole string
+
+const (
+	// RoleSigning keys sign manifests, fences and unlock tokens.
+	RoleSigning KeyRole = "signing"
+
+	// RoleRotation keys sign key rotation announcements.
+	RoleRotation KeyRole = "rotation"
+)
+
+// KeyRotationSigningPrefix starts the signing data of every key rotation
+// announcement, separating its signatures from others made with the same
+// key.
+const KeyRotationSigningPrefix = "GUL-KEYROTATION-V1\n"
+
+// TrustedKey is a public key trusted for some roles within a validity
+// window.
+type TrustedKey struct {
+	// KeyID is derived from the public key if empty; if set it must match.
+	KeyID        string    `json:"key_id,omitempty"`
+	PublicKeyHex string    `json:"public_key_hex"`
+	Roles        []KeyRole `json:"roles,omitempty"`      // Empty means RoleSigning
+	NotBefore    int64     `json:"not_before,omitempty"` // Unix seconds, 0 = no start
+	NotAfter     int64     `json:"not_after,omitempty"`  // Unix seconds, 0 = never expires
+}
+
+// Validate checks that the key decodes, that its key ID matches the
+// public key, that its roles are known and that its window is not empty.
+func (k *TrustedKey) Validate() error {
+	pk, err := UnmarshalPublicKeyHex(k.PublicKeyHex)
+	if err != nil {
+		return fmt.Errorf("invalid public key: %w", err)
+	}
+	keyID, err := PublicKeyToKeyID(pk)
+	if err != nil {
+		return err
+	}
+	if k.KeyID != "" && k.KeyID != keyID {
+		return fmt.Errorf("key ID %s does not match public key (%s)", k.KeyID, keyID)
+	}
+	for _, role := range k.Roles {
+		if role != RoleSigning && role != RoleRotation {
+			return fmt.Errorf("unknown key role %q", role)
+		}
+	}
+	if k.NotAfter != 0 && k.NotAfter <= k.NotBefore {
+		return fmt.Errorf("key %s expires at %d, not after its start %d", keyID, k.NotAfter, k.NotBefore)
+	}
+	return nil
+}
+
+// HasRole checks if the key is trusted for the role.
+func (k *TrustedKey) HasRole(role KeyRole) bool {
+	if len(k.Roles) == 0 {
+		return role == RoleSigning
+	}
+	return slices.Contains(k.Roles, role)
+}
+
+// ValidAt checks if the time is within the key's validity window.
+func (k *TrustedKey) ValidAt(t time.Time) bool {
+	ts := t.Unix()
+	if k.NotBefore != 0 && ts < k.NotBefore {
+		return false
+	}
+	return k.NotAfter == 0 || ts < k.NotAfter
+}
+
+// KeyRotation is a signed announcement that changes the set of trusted
+// keys. Clients accept it if it is signed by a key they trust for
+// RoleRotation and its Sequence is newer than the last one they applied.
+type KeyRotation struct {
+	Sequence  uint64       `json:"sequence"`
+	Timestamp int64        `json:"timestamp"`
+	Add       []TrustedKey `json:"add,omitempty"`    // Added, or replacing a key with the same ID
+	Revoke    []string     `json:"revoke,omitempty"` // IDs of keys no longer trusted
+	Signature []byte       `json:"signature"`
+	KeyID     string       `json:"key_id"`
+}
+
+// MarshalBinaryForSigning returns the bytes the announcement's signature
+// is computed over: KeyRotationSigningPrefix followed by the announcement
+// as JSON, without the Signature and KeyID fields.
+func (r *KeyRotation) MarshalBinaryForSigning() ([]byte, error) {
+	copy := *r
+	copy.Signature = nil
+	copy.KeyID = ""
+
+	data, err := json.Marshal(copy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal key rotation: %w", err)
+	}
+	return append([]byte(KeyRotationSigningPrefix), data...), nil
+}
+
+// SetSignature sets the signature on the announcement.
+func (r *KeyRotation) SetSignature(sig []byte, keyID string) {
+	r.Signature = sig
+	r.KeyID = keyID
+}
+
+// KeySignature is a detached signature and the ID of the key that made it.
+type KeySignature struct {
+	KeyID     string
+	Signature []byte
+}
+
+// trustedEntry is a trusted key with its decoded public key.
+type trustedEntry struct {
+	key       TrustedKey
+	publicKey []byte
+}
+
+// Keyring is a set of trusted keys, looked up by key ID, that changes
+// through signed key rotation announcements. It is safe for concurrent use.
+type Keyring struct {
+	mu       sync.RWMutex
+	keys     map[string]trustedEntry
+	sequence uint64
+}
+
+// NewKeyring creates a keyring trusting the given keys.
+func NewKeyring(keys ...TrustedKey) (*Keyring, error) {
+	r := &Keyring{keys: make(map[string]trustedEntry, len(keys))}
+	for _, k := range keys {
+		if err := addKey(r.keys, k); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// addKey validates a key and adds it to the map, replacing a key with the
+// same ID.
+func addKey(keys map[string]trustedEntry, k TrustedKey) error {
+	if err := k.Validate(); err != nil {
+		return err
+	}
+	pk, _ := UnmarshalPublicKeyHex(k.PublicKeyHex)
+	k.KeyID, _ = PublicKeyToKeyID(pk)
+	k.Roles = slices.Clone(k.Roles)
+	keys[k.KeyID] = trustedEntry{key: k, publicKey: pk}
+	return nil
+}
+
+// Add trusts a key, replacing a key with the same ID.
+func (r *Keyring) Add(k TrustedKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return addKey(r.keys, k)
+}
+
+// Remove stops trusting the key with the given ID.
+func (r *Keyring) Remove(keyID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.keys, keyID)
+}
+
+// Key returns the trusted key with the given ID.
+func (r *Keyring) Key(keyID string) (TrustedKey, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.keys[keyID]
+	return e.key, ok
+}
+
+// Keys returns the trusted keys, sorted by key ID.
+func (r *Keyring) Keys() []TrustedKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	keys := make([]TrustedKey, 0, len(r.keys))
+	for _, e := range r.keys {
+		keys = append(keys, e.key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].KeyID < keys[j].KeyID })
+	return keys
+}
+
+// Sequence returns the sequence number of the last key rotation applied,
+// or 0 if none was.
+func (r *Keyring) Sequence() uint64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.sequence
+}
+
+// Verify checks that signature is a valid signature of message by the
+// trusted key with the given ID, and that the key holds the role and is
+// valid at the given time. It returns an error wrapping ErrUnknownKey,
+// ErrKeyRole, ErrKeyNotValid or ErrInvalidSignature.
+func (r *Keyring) Verify(message, signature []byte, keyID string, role KeyRole, at time.Time) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return verifyWith(r.keys, message, signature, keyID, role, at)
+}
+
+// verifyWith is Verify against the given keys.
+func verifyWith(keys map[string]trustedEntry, message, signature []byte, keyID string, role KeyRole, at time.Time) error {
+	if keyID == "" {
+		return fmt.Errorf("%w: missing key ID", ErrUnknownKey)
+	}
+	e, ok := keys[keyID]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+	if !e.key.HasRole(role) {
+		return fmt.Errorf("%w: %s is not a %s key", ErrKeyRole, keyID, role)
+	}
+	if !e.key.ValidAt(at) {
+		return fmt.Errorf("%w: %s at %s", ErrKeyNotValid, keyID, at.UTC().Format(time.RFC3339))
+	}
+	if len(signature) == 0 {
+		return fmt.Errorf("%w: missing signature", ErrInvalidSignature)
+	}
+	if !Verify(e.publicKey, message, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// VerifySigned is like Verify but computes the message from s.
+func (r *Keyring) VerifySigned(s Signable, signature []byte, keyID string, role KeyRole, at time.Time) error {
+	message, err := s.MarshalBinaryForSigning()
+	if err != nil {
+		return err
+	}
+	return r.Verify(message, signature, keyID, role, at)
+}
+
+// VerifyThreshold checks that at least threshold distinct trusted keys,
+// each holding the role and valid at the given time, made valid signatures
+// of message. Signatures that do not verify are ignored as long as enough
+// others do; otherwise the error wraps ErrThreshold and their errors.
+func (r *Keyring) VerifyThreshold(message []byte, sigs []KeySignature, threshold int, role KeyRole, at time.Time) error {
+	threshold = max(threshold, 1)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	signers := make(map[string]bool, len(sigs))
+	var errs []error
+	for _, sig := range sigs {
+		if signers[sig.KeyID] {
+			continue
+		}
+		if err := verifyWith(r.keys, message, sig.Signature, sig.KeyID, role, at); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		signers[sig.KeyID] = true
+	}
+	if len(signers) >= threshold {
+		return nil
+	}
+	if len(errs) == 0 {
+		return fmt.Errorf("%w: %d of %d required", ErrThreshold, len(signers), threshold)
+	}
+	return fmt.Errorf("%w: %d of %d required: %w", ErrThreshold, len(signers), threshold, errors.Join(errs...))
+}
+
+// ApplyRotation verifies a key rotation announcement against the keys
+// trusted for RoleRotation at the given time and applies it: revoked keys
+// are removed, then added keys are trusted. It returns an error wrapping
+// ErrStaleRotation if the announcement is not newer than the last one
+// applied. Nothing changes if the announcement is rejected, including when
+// it would leave no key trusted for RoleRotation.
+func (r *Keyring) ApplyRotation(rot *KeyRotation, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := verifyRotation(r.keys, rot, at); err != nil {
+		return fmt.Errorf("key rotation %d: %w", rot.Sequence, err)
+	}
+	if rot.Sequence <= r.sequence {
+		return fmt.Errorf("%w: sequence %d, last applied %d", ErrStaleRotation, rot.Sequence, r.sequence)
+	}
+
+	keys := make(map[string]trustedEntry, len(r.keys)+len(rot.Add))
+	for id, e := range r.keys {
+		keys[id] = e
+	}
+	for _, id := range rot.Revoke {
+		delete(keys, id)
+	}
+	for _, k := range rot.Add {
+		if err := addKey(keys, k); err != nil {
+			return fmt.Errorf("key rotation %d: %w", rot.Sequence, err)
+		}
+	}
+	if !hasRole(keys, RoleRotation) {
+		return fmt.Errorf("key rotation %d leaves no %s key", rot.Sequence, RoleRotation)
+	}
+
+	r.keys = keys
+	r.sequence = rot.Sequence
+	return nil
+}
+
+// verifyRotation checks the signature of a key rotation announcement.
+func verifyRotation(keys map[string]trustedEntry, rot *KeyRotation, at time.Time) error {
+	message, err := rot.MarshalBinaryForSigning()
+	if err != nil {
+		return err
+	}
+	return verifyWith(keys, message, rot.Signature, rot.KeyID, RoleRotation, at)
+}
+
+// hasRole checks if any of the keys holds the role.
+func hasRole(keys map[string]trustedEntry, role KeyRole) bool {
+	for _, e := range keys {
+		if e.key.HasRole(role) {
+			return true
+		}
+	}
+	return false
+}
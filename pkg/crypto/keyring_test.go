@@ -0,0 +1,269 @@
+package crypto
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type signableString string
+
+func (s signableString) MarshalBinaryForSigning() ([]byte, error) {
+	return []byte(s), nil
+}
+
+func trustedKey(t *testing.T, roles ...KeyRole) (*KeyPair, TrustedKey) {
+	t.Helper()
+	kp, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair failed: %v", err)
+	}
+	return kp, TrustedKey{PublicKeyHex: MarshalPublicKeyHex(kp.PublicKey), Roles: roles}
+}
+
+func signRotation(t *testing.T, rot *KeyRotation, kp *KeyPair) {
+	t.Helper()
+	data, err := rot.MarshalBinaryForSigning()
+	if err != nil {
+		t.Fatalf("MarshalBinaryForSigning failed: %v", err)
+	}
+	sig, err := kp.Sign(data)
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	rot.SetSignature(sig, kp.KeyID)
+}
+
+func TestTrustedKey_Validate(t *testing.T) {
+	kp, key := trustedKey(t)
+
+	tests := []struct {
+		name    string
+		modify  func(k *TrustedKey)
+		wantErr bool
+	}{
+		{"valid", func(k *TrustedKey) {}, false},
+		{"matching key ID", func(k *TrustedKey) { k.KeyID = kp.KeyID }, false},
+		{"mismatched key ID", func(k *TrustedKey) { k.KeyID = "0123" }, true},
+		{"invalid public key", func(k *TrustedKey) { k.PublicKeyHex = "zz" }, true},
+		{"unknown role", func(k *TrustedKey) { k.Roles = []KeyRole{"admin"} }, true},
+		{"empty window", func(k *TrustedKey) { k.NotBefore, k.NotAfter = 200, 100 }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := key
+			tt.modify(&k)
+			if err := k.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeyring_Verify(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+
+	signer, signerKey := trustedKey(t)
+	expired, expiredKey := trustedKey(t, RoleSigning)
+	expiredKey.NotAfter = now.Add(-time.Hour).Unix()
+	future, futureKey := trustedKey(t, RoleSigning)
+	futureKey.NotBefore = now.Add(time.Hour).Unix()
+	rotator, rotatorKey := trustedKey(t, RoleRotation)
+	untrusted, _ := trustedKey(t)
+
+	r, err := NewKeyring(signerKey, expiredKey, futureKey, rotatorKey)
+	if err != nil {
+		t.Fatalf("NewKeyring failed: %v", err)
+	}
+	if got := len(r.Keys()); got != 4 {
+		t.Errorf("len(Keys()) = %d, want 4", got)
+	}
+	if k, ok := r.Key(signer.KeyID); !ok || k.KeyID != signer.KeyID {
+		t.Error("Key should return the key with its derived key ID")
+	}
+
+	message := []byte("manifest")
+	sign := func(kp *KeyPair) []byte {
+		sig, err := kp.Sign(message)
+		if err != nil {
+			t.Fatalf("Sign failed: %v", err)
+		}
+		return sig
+	}
+
+	tests := []struct {
+		name    string
+		sig     []byte
+		keyID   string
+		role    KeyRole
+		wantErr error
+	}{
+		{"valid", sign(signer), signer.KeyID, RoleSigning, nil},
+		{"rotation key for rotation", sign(rotator), rotator.KeyID, RoleRotation, nil},
+		{"signing key for rotation", sign(signer), signer.KeyID, RoleRotation, ErrKeyRole},
+		{"rotation key for signing", sign(rotator), rotator.KeyID, RoleSigning, ErrKeyRole},
+		{"expired key", sign(expired), expired.KeyID, RoleSigning, ErrKeyNotValid},
+		{"key not valid yet", sign(future), future.KeyID, RoleSigning, ErrKeyNotValid},
+		{"untrusted key", sign(untrusted), untrusted.KeyID, RoleSigning, ErrUnknownKey},
+		{"missing key ID", sign(signer), "", RoleSigning, ErrUnknownKey},
+		{"key ID of another signer", sign(untrusted), signer.KeyID, RoleSigning, ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := r.Verify(message, tt.sig, tt.keyID, tt.role, now)
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := r.VerifySigned(signableString("manifest"), sign(future), future.KeyID, RoleSigning, now.Add(2*time.Hour)); err != nil {
+		t.Errorf("VerifySigned within the key's window failed: %v", err)
+	}
+}
+
+func TestKeyring_ApplyRotation(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+
+	root, rootKey := trustedKey(t, RoleSigning, RoleRotation)
+	_, newKey := trustedKey(t, RoleSigning, RoleRotation)
+	signer, signerKey := trustedKey(t)
+
+	r, err := NewKeyring(rootKey, signerKey)
+	if err != nil {
+		t.Fatalf("NewKeyring failed: %v", err)
+	}
+
+	t.Run("signed by a signing key", func(t *testing.T) {
+		rot := &KeyRotation{Sequence: 1, Add: []TrustedKey{newKey}}
+		signRotation(t, rot, signer)
+		if err := r.ApplyRotation(rot, now); !errors.Is(err, ErrKeyRole) {
+			t.Errorf("ApplyRotation() error = %v, want %v", err, ErrKeyRole)
+		}
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		rot := &KeyRotation{Sequence: 1, Revoke: []string{signer.KeyID}}
+		signRotation(t, rot, root)
+		rot.Revoke = []string{root.KeyID}
+		if err := r.ApplyRotation(rot, now); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("ApplyRotation() error = %v, want %v", err, ErrInvalidSignature)
+		}
+	})
+
+	t.Run("leaves no rotation key", func(t *testing.T) {
+		rot := &KeyRotation{Sequence: 1, Revoke: []string{root.KeyID}}
+		signRotation(t, rot, root)
+		if err := r.ApplyRotation(rot, now); err == nil {
+			t.Error("expected error for a rotation revoking the last rotation key")
+		}
+		if _, ok := r.Key(root.KeyID); !ok {
+			t.Error("a rejected rotation should not change the keyring")
+		}
+	})
+
+	rot := &KeyRotation{Sequence: 1, Add: []TrustedKey{newKey}, Revoke: []string{root.KeyID, signer.KeyID}}
+	signRotation(t, rot, root)
+	if err := r.ApplyRotation(rot, now); err != nil {
+		t.Fatalf("ApplyRotation failed: %v", err)
+	}
+	if r.Sequence() != 1 {
+		t.Errorf("Sequence() = %d, want 1", r.Sequence())
+	}
+	if _, ok := r.Key(root.KeyID); ok {
+		t.Error("revoked key should no longer be trusted")
+	}
+	if _, ok := r.Key(signer.KeyID); ok {
+		t.Error("revoked key should no longer be trusted")
+	}
+	if len(r.Keys()) != 1 {
+		t.Errorf("len(Keys()) = %d, want 1", len(r.Keys()))
+	}
+
+	t.Run("replayed", func(t *testing.T) {
+		if err := r.ApplyRotation(rot, now); err == nil {
+			t.Error("expected error for a rotation signed by a revoked key")
+		}
+	})
+}
+
+func TestKeyring_ApplyRotation_Stale(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	root, rootKey := trustedKey(t, RoleRotation)
+	_, newKey := trustedKey(t)
+
+	r, err := NewKeyring(rootKey)
+	if err != nil {
+		t.Fatalf("NewKeyring failed: %v", err)
+	}
+
+	rot := &KeyRotation{Sequence: 2, Add: []TrustedKey{newKey}}
+	signRotation(t, rot, root)
+	if err := r.ApplyRotation(rot, now); err != nil {
+		t.Fatalf("ApplyRotation failed: %v", err)
+	}
+
+	old := &KeyRotation{Sequence: 1}
+	signRotation(t, old, root)
+	if err := r.ApplyRotation(old, now); !errors.Is(err, ErrStaleRotation) {
+		t.Errorf("ApplyRotation() error = %v, want %v", err, ErrStaleRotation)
+	}
+}
+
+func TestKeyring_VerifyThreshold(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+
+	a, aKey := trustedKey(t)
+	b, bKey := trustedKey(t)
+	c, cKey := trustedKey(t)
+	rotator, rotatorKey := trustedKey(t, RoleRotation)
+	outsider, _ := trustedKey(t)
+
+	r, err := NewKeyring(aKey, bKey, cKey, rotatorKey)
+	if err != nil {
+		t.Fatalf("NewKeyring failed: %v", err)
+	}
+
+	message := []byte("manifest")
+	sign := func(kp *KeyPair) KeySignature {
+		sig, err := kp.Sign(message)
+		if err != nil {
+			t.Fatalf("Sign failed: %v", err)
+		}
+		return KeySignature{KeyID: kp.KeyID, Signature: sig}
+	}
+	forged := sign(outsider)
+	forged.KeyID = c.KeyID
+
+	tests := []struct {
+		name      string
+		sigs      []KeySignature
+		threshold int
+		wantErr   error
+	}{
+		{"one of three", []KeySignature{sign(a)}, 1, nil},
+		{"two of three", []KeySignature{sign(a), sign(b)}, 2, nil},
+		{"zero threshold means one", []KeySignature{sign(b)}, 0, nil},
+		{"invalid signatures ignored", []KeySignature{sign(outsider), forged, sign(a), sign(c)}, 2, nil},
+		{"too few", []KeySignature{sign(a)}, 2, ErrThreshold},
+		{"same key twice", []KeySignature{sign(a), sign(a)}, 2, ErrThreshold},
+		{"untrusted key does not count", []KeySignature{sign(a), sign(outsider)}, 2, ErrUnknownKey},
+		{"forged signature does not count", []KeySignature{sign(a), forged}, 2, ErrInvalidSignature},
+		{"rotation key does not count", []KeySignature{sign(a), sign(rotator)}, 2, ErrKeyRole},
+		{"none", nil, 1, ErrThreshold},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := r.VerifyThreshold(message, tt.sigs, tt.threshold, RoleSigning, now)
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Errorf("VerifyThreshold() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrThreshold) {
+				t.Errorf("VerifyThreshold() error = %v, should wrap %v", err, ErrThreshold)
+			}
+		})
+	}
+}
@@ -0,0 +1,326 @@
+package crypto
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Signer signs data with an Ed25519 key that may be held outside the
+// process, such as in a hardened signing service or an HSM.
+type Signer interface {
+	// Sign returns the signature of message.
+	Sign(message []byte) ([]byte, error)
+
+	// PublicKey returns the public key signatures verify with.
+	PublicKey() []byte
+
+	// KeyID returns the identifier recorded with signatures.
+	KeyID() string
+}
+
+// memorySigner signs with a key pair held in memory.
+type memorySigner struct {
+	kp *KeyPair
+}
+
+// NewMemorySigner returns a Signer for a key pair held in memory.
+func NewMemorySigner(kp *KeyPair) Signer {
+	return memorySigner{kp: kp}
+}
+
+func (s memorySigner) Sign(message []byte) ([]byte, error) { return s.kp.Sign(message) }
+func (s memorySigner) PublicKey() []byte                   { return s.kp.PublicKey }
+func (s memorySigner) KeyID() string                       { return s.kp.KeyID }
+
+// External signer protocol: the publisher and the signing service exchange
+// one JSON object per line. Each request gets exactly one response.
+//
+//	-> {"op":"public_key","key_id":"..."}
+//	<- {"public_key":"<hex>","key_id":"..."}
+//	-> {"op":"sign","key_id":"...","message":"<base64>"}
+//	<- {"signature":"<base64>"}
+//
+// key_id selects the key for services holding several and may be empty for
+// the default key. A failed request gets {"error":"..."}.
+const (
+	signerOpPublicKey = "public_key"
+	signerOpSign      = "sign"
+
+	// maxSignerLine bounds a request or response line.
+	maxSignerLine = 16 << 20
+)
+
+// signerTimeout bounds how long a request may wait for the service.
+var signerTimeout = 30 * time.Second
+
+type signerRequest struct {
+	Op      string `json:"op"`
+	KeyID   string `json:"key_id,omitempty"`
+	Message []byte `json:"message,omitempty"`
+}
+
+type signerResponse struct {
+	PublicKey string `json:"public_key,omitempty"`
+	KeyID     string `json:"key_id,omitempty"`
+	Signature []byte `json:"signature,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ExternalSigner is a Signer backed by a separate signing service, reached
+// over a Unix socket or the stdin and stdout of a child process. Requests
+// are serialized; every signature is verified before it is returned.
+type ExternalSigner struct {
+	mu        sync.Mutex
+	w         io.Writer
+	r         *bufio.Reader
+	deadline  func(time.Time) error
+	abort     func() // ends a service that does not answer in time
+	closeFn   func() error
+	err       error  // set once the connection is out of step
+	request   string // key ID sent with requests
+	keyID     string
+	publicKey []byte
+}
+
+// NewExternalSigner speaks the signer protocol over r and w, using the key
+// selected by keyID (empty for the service's default key). closeFn, if
+// non-nil, is called by Close. Requests time out if r supports read
+// deadlines, such as a network connection.
+func NewExternalSigner(r io.Reader, w io.Writer, keyID string, closeFn func() error) (*ExternalSigner, error) {
+	return newExternalSigner(r, w, keyID, closeFn, nil)
+}
+
+// newExternalSigner is like NewExternalSigner, but if abort is non-nil it
+// is called instead of setting a read deadline when a request times out,
+// and must unblock the pending read and write.
+func newExternalSigner(r io.Reader, w io.Writer, keyID string, closeFn func() error, abort func()) (*ExternalSigner, error) {
+	s := &ExternalSigner{
+		w:       w,
+		r:       bufio.NewReader(r),
+		abort:   abort,
+		closeFn: closeFn,
+		request: keyID,
+	}
+	if d, ok := r.(interface{ SetReadDeadline(time.Time) error }); ok && abort == nil {
+		s.deadline = d.SetReadDeadline
+	}
+
+	resp, err := s.roundTrip(&signerRequest{Op: signerOpPublicKey, KeyID: keyID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get public key from signer: %w", err)
+	}
+	publicKey, err := UnmarshalPublicKeyHex(resp.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("signer returned an invalid public key: %w", err)
+	}
+	computedID := computeKeyID(publicKey)
+	if resp.KeyID != "" && resp.KeyID != computedID {
+		return nil, fmt.Errorf("signer key ID %s does not match its public key (%s)", resp.KeyID, computedID)
+	}
+	s.publicKey = publicKey
+	s.keyID = computedID
+	return s, nil
+}
+
+// DialSigner connects to a signing service listening on a Unix socket.
+func DialSigner(socketPath, keyID string) (*ExternalSigner, error) {
+	conn, err := net.DialTimeout("unix", socketPath, signerTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to signer: %w", err)
+	}
+	s, err := NewExternalSigner(conn, conn, keyID, conn.Close)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// StartSigner starts a signing process that speaks the signer protocol on
+// its stdin and stdout. Its stderr is passed through. The process is killed
+// if it does not answer a request in time. Close ends its input and waits
+// for it to exit.
+func StartSigner(keyID, command string, args ...string) (*ExternalSigner, error) {
+	cmd := exec.Command(command, args...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start signer: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start signer: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start signer: %w", err)
+	}
+
+	closeFn := func() error {
+		stdin.Close()
+		return cmd.Wait()
+	}
+	abort := func() { cmd.Process.Kill() }
+	s, err := newExternalSigner(stdout, stdin, keyID, closeFn, abort)
+	if err != nil {
+		cmd.Process.Kill()
+		closeFn()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Sign asks the service to sign message and verifies the signature.
+func (s *ExternalSigner) Sign(message []byte) ([]byte, error) {
+	resp, err := s.roundTrip(&signerRequest{Op: signerOpSign, KeyID: s.request, Message: message})
+	if err != nil {
+		return nil, fmt.Errorf("signer failed: %w", err)
+	}
+	if !Verify(s.publicKey, message, resp.Signature) {
+		return nil, fmt.Errorf("signer returned a bad signature: %w", ErrInvalidSignature)
+	}
+	return resp.Signature, nil
+}
+
+// PublicKey returns the public key of the service's key.
+func (s *ExternalSigner) PublicKey() []byte { return s.publicKey }
+
+// KeyID returns the key ID of the service's key.
+func (s *ExternalSigner) KeyID() string { return s.keyID }
+
+// Close closes the connection to the service.
+func (s *ExternalSigner) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil {
+		s.err = errors.New("signer closed")
+	}
+	if s.closeFn == nil {
+		return nil
+	}
+	closeFn := s.closeFn
+	s.closeFn = nil
+	return closeFn()
+}
+
+func (s *ExternalSigner) roundTrip(req *signerRequest) (*signerResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	resp, err := s.exchange(req)
+	if err != nil {
+		// A request may be half written or a response half read
+		s.err = err
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	return resp, nil
+}
+
+func (s *ExternalSigner) exchange(req *signerRequest) (*signerResponse, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	var timedOut atomic.Bool
+	if s.abort != nil {
+		timer := time.AfterFunc(signerTimeout, func() {
+			timedOut.Store(true)
+			s.abort()
+		})
+		defer timer.Stop()
+	} else if s.deadline != nil {
+		s.deadline(time.Now().Add(signerTimeout))
+	}
+	if _, err := s.w.Write(append(data, '\n')); err != nil {
+		if timedOut.Load() {
+			return nil, fmt.Errorf("signer did not accept the request within %s", signerTimeout)
+		}
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	line, err := readLine(s.r, maxSignerLine)
+	if err != nil {
+		if timedOut.Load() {
+			return nil, fmt.Errorf("signer did not respond within %s", signerTimeout)
+		}
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	var resp signerResponse
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	return &resp, nil
+}
+
+// readLine reads a newline-terminated line of at most max bytes.
+func readLine(r *bufio.Reader, max int) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, isPrefix, err := r.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, chunk...)
+		if len(line) > max {
+			return nil, fmt.Errorf("line exceeds %d bytes", max)
+		}
+		if !isPrefix {
+			return line, nil
+		}
+	}
+}
+
+// ServeSigner answers signer protocol requests read from r with signer,
+// writing responses to w, until r is exhausted. It is the service side of
+// ExternalSigner, for signing services written in Go. Requests naming a key
+// other than signer's get an error response.
+func ServeSigner(r io.Reader, w io.Writer, signer Signer) error {
+	br := bufio.NewReader(r)
+	enc := json.NewEncoder(w)
+	for {
+		line, err := readLine(br, maxSignerLine)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req signerRequest
+		resp := &signerResponse{}
+		switch {
+		case json.Unmarshal(line, &req) != nil:
+			resp.Error = "malformed request"
+		case req.KeyID != "" && req.KeyID != signer.KeyID():
+			resp.Error = fmt.Sprintf("unknown key %s", req.KeyID)
+		case req.Op == signerOpPublicKey:
+			resp.PublicKey = MarshalPublicKeyHex(signer.PublicKey())
+			resp.KeyID = signer.KeyID()
+		case req.Op == signerOpSign:
+			sig, err := signer.Sign(req.Message)
+			if err != nil {
+				resp.Error = err.Error()
+			}
+			resp.Signature = sig
+		default:
+			resp.Error = fmt.Sprintf("unknown op %q", req.Op)
+		}
+		if err := enc.Encode(resp); err != nil {
+			return err
+		}
+	}
+}
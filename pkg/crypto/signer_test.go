@@ -0,0 +1,215 @@
+package crypto
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signerKeyEnv makes the test binary act as a signing process, serving the
+// hex-encoded private key in it on stdin and stdout.
+const signerKeyEnv = "GUL_TEST_SIGNER_KEY"
+
+// signerHangEnv makes the test binary act as a signing process that never
+// answers.
+const signerHangEnv = "GUL_TEST_SIGNER_HANG"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(signerHangEnv) != "" {
+		time.Sleep(time.Hour)
+		os.Exit(1)
+	}
+	if keyHex := os.Getenv(signerKeyEnv); keyHex != "" {
+		privateKey, err := UnmarshalPrivateKeyHex(keyHex)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		kp, err := DeriveKeyPair(nil, privateKey)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if err := ServeSigner(os.Stdin, os.Stdout, NewMemorySigner(kp)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// pipeSigner connects an ExternalSigner to a service run by serve.
+func pipeSigner(t *testing.T, keyID string, serve func(r io.Reader, w io.Writer)) (*ExternalSigner, error) {
+	t.Helper()
+	client, server := net.Pipe()
+	go func() {
+		serve(server, server)
+		server.Close()
+	}()
+	s, err := NewExternalSigner(client, client, keyID, client.Close)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	t.Cleanup(func() { s.Close() })
+	return s, nil
+}
+
+func TestExternalSigner(t *testing.T) {
+	kp, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair failed: %v", err)
+	}
+	serve := func(r io.Reader, w io.Writer) { ServeSigner(r, w, NewMemorySigner(kp)) }
+
+	s, err := pipeSigner(t, "", serve)
+	if err != nil {
+		t.Fatalf("NewExternalSigner failed: %v", err)
+	}
+	if s.KeyID() != kp.KeyID {
+		t.Errorf("KeyID() = %s, want %s", s.KeyID(), kp.KeyID)
+	}
+
+	message := []byte("manifest")
+	sig, err := s.Sign(message)
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if !Verify(kp.PublicKey, message, sig) {
+		t.Error("signature does not verify")
+	}
+
+	if _, err := pipeSigner(t, kp.KeyID, serve); err != nil {
+		t.Errorf("NewExternalSigner with the key's ID failed: %v", err)
+	}
+	if _, err := pipeSigner(t, "other-key", serve); err == nil {
+		t.Error("expected error for a key the service does not hold")
+	}
+
+	if err := s.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	if _, err := s.Sign(message); err == nil {
+		t.Error("expected error signing after Close")
+	}
+}
+
+func TestExternalSigner_BadService(t *testing.T) {
+	kp, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair failed: %v", err)
+	}
+	other, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair failed: %v", err)
+	}
+
+	// The service claims kp's public key but signs with another key
+	s, err := pipeSigner(t, "", func(r io.Reader, w io.Writer) {
+		br := bufio.NewReader(r)
+		br.ReadString('\n')
+		fmt.Fprintf(w, "{\"public_key\":%q}\n", MarshalPublicKeyHex(kp.PublicKey))
+		br.ReadString('\n')
+		sig, _ := other.Sign([]byte("manifest"))
+		fmt.Fprintf(w, "{\"signature\":%q}\n", base64.StdEncoding.EncodeToString(sig))
+	})
+	if err != nil {
+		t.Fatalf("NewExternalSigner failed: %v", err)
+	}
+	if _, err := s.Sign([]byte("manifest")); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Sign() error = %v, want %v", err, ErrInvalidSignature)
+	}
+
+	// A service that hangs up fails the handshake
+	if _, err := pipeSigner(t, "", func(r io.Reader, w io.Writer) {}); err == nil {
+		t.Error("expected error for a service that closes the connection")
+	}
+}
+
+func TestDialSigner(t *testing.T) {
+	kp, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair failed: %v", err)
+	}
+
+	socketPath := filepath.Join(t.TempDir(), "signer.sock")
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				ServeSigner(conn, conn, NewMemorySigner(kp))
+			}()
+		}
+	}()
+
+	s, err := DialSigner(socketPath, "")
+	if err != nil {
+		t.Fatalf("DialSigner failed: %v", err)
+	}
+	defer s.Close()
+
+	sig, err := s.Sign([]byte("fence"))
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if !Verify(kp.PublicKey, []byte("fence"), sig) {
+		t.Error("signature does not verify")
+	}
+}
+
+func TestStartSigner(t *testing.T) {
+	kp, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair failed: %v", err)
+	}
+	t.Setenv(signerKeyEnv, MarshalPrivateKeyHex(kp.PrivateKey))
+
+	s, err := StartSigner("", os.Args[0])
+	if err != nil {
+		t.Fatalf("StartSigner failed: %v", err)
+	}
+
+	sig, err := s.Sign([]byte("token"))
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if !Verify(kp.PublicKey, []byte("token"), sig) {
+		t.Error("signature does not verify")
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+}
+
+func TestStartSigner_Timeout(t *testing.T) {
+	defer func(d time.Duration) { signerTimeout = d }(signerTimeout)
+	signerTimeout = 100 * time.Millisecond
+	t.Setenv(signerHangEnv, "1")
+
+	start := time.Now()
+	_, err := StartSigner("", os.Args[0])
+	if err == nil || !strings.Contains(err.Error(), "did not respond") {
+		t.Fatalf("StartSigner() error = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("StartSigner took %s to give up on a hung signer", elapsed)
+	}
+}
@@ -0,0 +1,120 @@
+package geofence
+
+import "time"
+
+// Contains checks if an altitude lies within the band.
+//
+// Bands are only comparable with altitudes in the same reference; converting
+// between AGL, MSL and the WGS84 ellipsoid needs terrain or geoid data that
+// the client does not have. A band in a different reference is therefore
+// treated as containing the altitude, which errs on the side of restriction.
+func (b *AltitudeBand) Contains(alt Altitude) bool {
+	if b == nil || b.Reference != alt.Reference {
+		return true
+	}
+	if alt.Meters < b.Floor {
+		return false
+	}
+	if b.Ceiling > 0 && alt.Meters > b.Ceiling {
+		return false
+	}
+	return true
+}
+
+// ContainsAltitude checks if an altitude lies within the fence's vertical
+// extent. Fences without an altitude band cover every altitude.
+func (f *FenceItem) ContainsAltitude(alt Altitude) bool {
+	return f.Altitude.Contains(alt)
+}
+
+// ContainsPoint3D checks if the fence volume contains a point at an altitude.
+func (f *FenceItem) ContainsPoint3D(p Point, alt Altitude) bool {
+	return f.ContainsPoint(p) && f.ContainsAltitude(alt)
+}
+
+// CeilingAltitude returns the upper altitude limit of an ALTITUDE_LIMIT fence
+// in meters and its reference. The band ceiling takes precedence over
+// MaxAltitude, which is interpreted as AGL. Returns 0 if there is no limit.
+func (f *FenceItem) CeilingAltitude() (float64, AltitudeReference) {
+	if f.Altitude != nil && f.Altitude.Ceiling > 0 {
+		return f.Altitude.Ceiling, f.Altitude.Reference
+	}
+	return float64(f.MaxAltitude), AltitudeReferenceAGL
+}
+
+// FloorAltitude returns the lower altitude limit of an ALTITUDE_MINIMUM fence
+// in meters and its reference. Returns 0 if there is no minimum.
+func (f *FenceItem) FloorAltitude() (float64, AltitudeReference) {
+	if f.Altitude == nil {
+		return 0, AltitudeReferenceAGL
+	}
+	return f.Altitude.Floor, f.Altitude.Reference
+}
+
+// ForbidsAltitude checks if flying at an altitude inside the fence's
+// horizontal footprint violates the fence. It does not check the footprint
+// or the time window.
+func (f *FenceItem) ForbidsAltitude(alt Altitude) bool {
+	switch f.Type {
+	case FenceTypePermanentNoFly, FenceTypeTempRestriction:
+		return f.ContainsAltitude(alt)
+	case FenceTypeAltitudeLimit:
+		ceiling, ref := f.CeilingAltitude()
+		if ceiling == 0 {
+			return false
+		}
+		// Unknown relation between datums: assume the limit is exceeded
+		return ref != alt.Reference || alt.Meters > ceiling
+	case FenceTypeAltitudeMinimum:
+		floor, ref := f.FloorAltitude()
+		if floor == 0 {
+			return false
+		}
+		return ref != alt.Reference || alt.Meters < floor
+	default:
+		return false
+	}
+}
+
+// CheckFences3D checks multiple fences at a point and altitude.
+//
+// Unlike CheckFences, every active fence whose footprint contains the point
+// is evaluated against the altitude: no-fly volumes forbid flight only within
+// their altitude band, altitude limits forbid flight above their ceiling and
+// altitude minimums forbid flight below their floor. The location is not
+// allowed if any fence forbids it; Restriction is the highest-priority
+// fence that does.
+func CheckFences3D(fences []FenceItem, p Point, alt Altitude) CheckResult {
+	return CheckFences3DAt(fences, p, alt, time.Now())
+}
+
+// CheckFences3DAt is like CheckFences3D but evaluates fences as of time t.
+func CheckFences3DAt(fences []FenceItem, p Point, alt Altitude, t time.Time) CheckResult {
+	var restriction *FenceItem
+	var matchingFences []FenceItem
+
+	for i := range fences {
+		f := &fences[i]
+		if !f.ContainsPoint(p) || !f.IsActiveAt(t) {
+			continue
+		}
+
+		forbids := f.ForbidsAltitude(alt)
+		// No-fly and speed limit volumes only apply within their band;
+		// altitude limits apply to the whole column above their footprint.
+		if forbids || f.Type == FenceTypeAltitudeLimit ||
+			f.Type == FenceTypeAltitudeMinimum || f.ContainsAltitude(alt) {
+			matchingFences = append(matchingFences, *f)
+		}
+
+		if forbids && (restriction == nil || f.Priority > restriction.Priority) {
+			restriction = f
+		}
+	}
+
+	return CheckResult{
+		Allowed:        restriction == nil,
+		Restriction:    restriction,
+		MatchingFences: matchingFences,
+	}
+}
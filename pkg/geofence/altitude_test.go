@@ -0,0 +1,169 @@
+package geofence
+
+import "testing"
+
+func testSquare() Geometry {
+	return Geometry{
+		Polygon: []Point{
+			{Latitude: 39.0, Longitude: 116.0},
+			{Latitude: 39.0, Longitude: 117.0},
+			{Latitude: 40.0, Longitude: 117.0},
+			{Latitude: 40.0, Longitude: 116.0},
+		},
+	}
+}
+
+func TestAltitudeBand_Contains(t *testing.T) {
+	band := &AltitudeBand{Floor: 120, Ceiling: 1500, Reference: AltitudeReferenceMSL}
+
+	tests := []struct {
+		name string
+		band *AltitudeBand
+		alt  Altitude
+		want bool
+	}{
+		{"nil band", nil, Altitude{Meters: 10000}, true},
+		{"below floor", band, Altitude{Meters: 100, Reference: AltitudeReferenceMSL}, false},
+		{"on floor", band, Altitude{Meters: 120, Reference: AltitudeReferenceMSL}, true},
+		{"inside", band, Altitude{Meters: 800, Reference: AltitudeReferenceMSL}, true},
+		{"above ceiling", band, Altitude{Meters: 1600, Reference: AltitudeReferenceMSL}, false},
+		{"different reference", band, Altitude{Meters: 10, Reference: AltitudeReferenceAGL}, true},
+		{"unlimited ceiling", &AltitudeBand{Floor: 50}, Altitude{Meters: 99999}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.band.Contains(tt.alt); got != tt.want {
+				t.Errorf("Contains() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFenceItem_ForbidsAltitude(t *testing.T) {
+	tests := []struct {
+		name  string
+		fence FenceItem
+		alt   Altitude
+		want  bool
+	}{
+		{
+			name:  "no-fly without band",
+			fence: FenceItem{Type: FenceTypePermanentNoFly},
+			alt:   Altitude{Meters: 5000},
+			want:  true,
+		},
+		{
+			name:  "no-fly above band",
+			fence: FenceItem{Type: FenceTypeTempRestriction, Altitude: &AltitudeBand{Ceiling: 122}},
+			alt:   Altitude{Meters: 150},
+			want:  false,
+		},
+		{
+			name:  "altitude limit below MaxAltitude",
+			fence: FenceItem{Type: FenceTypeAltitudeLimit, MaxAltitude: 120},
+			alt:   Altitude{Meters: 100},
+			want:  false,
+		},
+		{
+			name:  "altitude limit above MaxAltitude",
+			fence: FenceItem{Type: FenceTypeAltitudeLimit, MaxAltitude: 120},
+			alt:   Altitude{Meters: 130},
+			want:  true,
+		},
+		{
+			name: "altitude limit band ceiling overrides MaxAltitude",
+			fence: FenceItem{Type: FenceTypeAltitudeLimit, MaxAltitude: 120,
+				Altitude: &AltitudeBand{Ceiling: 60}},
+			alt:  Altitude{Meters: 100},
+			want: true,
+		},
+		{
+			name:  "altitude minimum below floor",
+			fence: FenceItem{Type: FenceTypeAltitudeMinimum, Altitude: &AltitudeBand{Floor: 50}},
+			alt:   Altitude{Meters: 30},
+			want:  true,
+		},
+		{
+			name:  "altitude minimum above floor",
+			fence: FenceItem{Type: FenceTypeAltitudeMinimum, Altitude: &AltitudeBand{Floor: 50}},
+			alt:   Altitude{Meters: 80},
+			want:  false,
+		},
+		{
+			name:  "speed limit never forbids",
+			fence: FenceItem{Type: FenceTypeSpeedLimit, MaxSpeed: 10},
+			alt:   Altitude{Meters: 80},
+			want:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.fence.ForbidsAltitude(tt.alt); got != tt.want {
+				t.Errorf("ForbidsAltitude() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckFences3D(t *testing.T) {
+	fences := []FenceItem{
+		{
+			ID:       "tfr",
+			Type:     FenceTypeTempRestriction,
+			Priority: 80,
+			Geometry: testSquare(),
+			Altitude: &AltitudeBand{Floor: 0, Ceiling: 122},
+		},
+		{
+			ID:          "limit",
+			Type:        FenceTypeAltitudeLimit,
+			Priority:    90,
+			MaxAltitude: 300,
+			Geometry:    testSquare(),
+		},
+	}
+	p := Point{Latitude: 39.5, Longitude: 116.5}
+
+	t.Run("inside band", func(t *testing.T) {
+		result := CheckFences3D(fences, p, Altitude{Meters: 50})
+		if result.Allowed {
+			t.Error("expected not allowed inside restriction band")
+		}
+		// The higher-priority altitude limit must not mask the no-fly band
+		if result.Restriction == nil || result.Restriction.ID != "tfr" {
+			t.Errorf("Restriction = %v, want tfr", result.Restriction)
+		}
+		if len(result.MatchingFences) != 2 {
+			t.Errorf("MatchingFences = %d, want 2", len(result.MatchingFences))
+		}
+	})
+
+	t.Run("above band", func(t *testing.T) {
+		result := CheckFences3D(fences, p, Altitude{Meters: 200})
+		if !result.Allowed {
+			t.Errorf("expected allowed above band ceiling, restricted by %v", result.Restriction)
+		}
+		if len(result.MatchingFences) != 1 {
+			t.Errorf("MatchingFences = %d, want 1", len(result.MatchingFences))
+		}
+	})
+
+	t.Run("above altitude limit", func(t *testing.T) {
+		result := CheckFences3D(fences, p, Altitude{Meters: 400})
+		if result.Allowed {
+			t.Error("expected not allowed above altitude limit")
+		}
+		if result.Restriction == nil || result.Restriction.ID != "limit" {
+			t.Errorf("Restriction = %v, want limit", result.Restriction)
+		}
+	})
+
+	t.Run("outside footprint", func(t *testing.T) {
+		result := CheckFences3D(fences, Point{Latitude: 0, Longitude: 0}, Altitude{Meters: 50})
+		if !result.Allowed || len(result.MatchingFences) != 0 {
+			t.Errorf("expected allowed with no matches, got %+v", result)
+		}
+	})
+}
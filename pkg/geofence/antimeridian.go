@@ -0,0 +1,247 @@
+package geofence
+
+import (
+	"math"
+	"sort"
+)
+
+// Geometries are allowed to cross the antimeridian and to enclose a pole.
+// Every edge of a ring or path takes the short way around in longitude, so
+// an edge from 179° to -179° spans 2°, not 358°. A ring whose edges wind
+// once around the globe encloses the pole of the hemisphere it lies in; a
+// bounding box with MinLon > MaxLon wraps across the antimeridian from
+// MinLon eastward to MaxLon.
+
+// NormalizeLongitude wraps a longitude into [-180, 180).
+func NormalizeLongitude(lon float64) float64 {
+	lon = math.Mod(lon+180, 360)
+	if lon < 0 {
+		lon += 360
+	}
+	return lon - 180
+}
+
+// UnwrapLongitudes returns a copy of a path with longitudes shifted by
+// multiples of 360° so that consecutive points differ by at most 180°. The
+// first point is unchanged; later longitudes may lie outside [-180, 180].
+func UnwrapLongitudes(points []Point) []Point {
+	out := make([]Point, len(points))
+	copy(out, points)
+	for i := 1; i < len(out); i++ {
+		out[i].Longitude = out[i-1].Longitude + shortLonDelta(out[i-1].Longitude, points[i].Longitude)
+	}
+	return out
+}
+
+// EnclosedPole returns the latitude of the pole a ring winds around, 90 or
+// -90, and false if the ring does not enclose a pole. Of the two regions
+// such a ring separates, the one containing the pole of the hemisphere the
+// ring mostly lies in is taken as its interior.
+func EnclosedPole(ring []Point) (float64, bool) {
+	if !crossesAntimeridian(ring) {
+		return 0, false
+	}
+	var winding, sumLat float64
+	for i := range ring {
+		winding += shortLonDelta(ring[i].Longitude, ring[(i+1)%len(ring)].Longitude)
+		sumLat += ring[i].Latitude
+	}
+	if math.Abs(winding) < 180 {
+		return 0, false
+	}
+	if sumLat < 0 {
+		return -90, true
+	}
+	return 90, true
+}
+
+// RingOrientation returns 1 if a ring runs counterclockwise, -1 if it runs
+// clockwise, and 0 if it has zero area or encloses a pole, around which it
+// has no meaningful orientation. It is computed in unwrapped longitudes, so
+// rings crossing the antimeridian are handled.
+func RingOrientation(ring []Point) int {
+	if _, pole := EnclosedPole(ring); pole {
+		return 0
+	}
+	u := UnwrapLongitudes(ring)
+	var area float64
+	for i := range u {
+		a, b := u[i], u[(i+1)%len(u)]
+		area += a.Longitude*b.Latitude - b.Longitude*a.Latitude
+	}
+	switch {
+	case area > 0:
+		return 1
+	case area < 0:
+		return -1
+	}
+	return 0
+}
+
+// OrientRing returns a copy of a ring running counterclockwise, or
+// clockwise if counterclockwise is false. A ring is reversed in place of its
+// first vertex, so it keeps its start. Rings without an orientation, see
+// RingOrientation, are returned unchanged.
+func OrientRing(ring []Point, counterclockwise bool) []Point {
+	out := make([]Point, len(ring))
+	copy(out, ring)
+	if o := RingOrientation(ring); o != 0 && (o > 0) != counterclockwise {
+		for i, j := 1, len(out)-1; i < j; i, j = i+1, j-1 {
+			out[i], out[j] = out[j], out[i]
+		}
+	}
+	return out
+}
+
+// shortLonDelta returns the eastward change in longitude from a to b taken
+// the short way around, in [-180, 180].
+func shortLonDelta(a, b float64) float64 {
+	d := b - a
+	if d > 180 {
+		d -= 360
+	} else if d < -180 {
+		d += 360
+	}
+	return d
+}
+
+// crossesAntimeridian checks if any edge of a ring, including the closing
+// edge, crosses the antimeridian when taken the short way around.
+func crossesAntimeridian(ring []Point) bool {
+	for i := range ring {
+		if math.Abs(ring[(i+1)%len(ring)].Longitude-ring[i].Longitude) > 180 {
+			return true
+		}
+	}
+	return false
+}
+
+// planarRing returns a ring in unwrapped longitudes together with the range
+// of longitudes it spans, so that a planar point-in-polygon test gives the
+// right answer for points shifted into that range. A pole-enclosing ring is
+// closed along the pole, turning it into a band spanning 360°.
+func planarRing(ring []Point) ([]Point, float64, float64) {
+	if !crossesAntimeridian(ring) {
+		b := boundsFromPoints(ring)
+		return ring, b.MinLon, b.MaxLon
+	}
+
+	u := UnwrapLongitudes(ring)
+	if pole, ok := EnclosedPole(ring); ok {
+		first, last := u[0], u[len(u)-1]
+		end := last.Longitude + shortLonDelta(last.Longitude, first.Longitude)
+		u = append(u,
+			Point{Latitude: first.Latitude, Longitude: end},
+			Point{Latitude: pole, Longitude: end},
+			Point{Latitude: pole, Longitude: first.Longitude},
+		)
+	}
+	b := boundsFromPoints(u)
+	return u, b.MinLon, b.MaxLon
+}
+
+// ringBounds returns the bounding box of a ring whose edges are of the
+// given type.
+func ringBounds(ring []Point, edges EdgeType) BoundingBox {
+	if len(ring) == 0 {
+		return BoundingBox{}
+	}
+	if pole, ok := EnclosedPole(ring); ok {
+		b := boundsFromPoints(ring)
+		if pole > 0 {
+			b.MaxLat = 90
+		} else {
+			b.MinLat = -90
+		}
+		b.MinLon, b.MaxLon = -180, 180
+		return b
+	}
+	b := boundsFromPoints(UnwrapLongitudes(ring))
+
+	// Rhumb lines keep between the latitudes of their ends; great circles
+	// bulge poleward
+	if edges == EdgeTypeGreatCircle {
+		for i := range ring {
+			a, c := ring[i], ring[(i+1)%len(ring)]
+			if lat, ok := segmentExtremeLatitude(a, c, 1); ok && lat > b.MaxLat {
+				b.MaxLat = lat
+			}
+			if lat, ok := segmentExtremeLatitude(a, c, -1); ok && lat < b.MinLat {
+				b.MinLat = lat
+			}
+		}
+	}
+	return wrapBounds(b)
+}
+
+// wrapBounds brings a bounding box in unwrapped longitudes back into
+// [-180, 180], wrapping it across the antimeridian if needed. Boxes
+// spanning 360° or more cover all longitudes.
+func wrapBounds(b BoundingBox) BoundingBox {
+	if b.MaxLon-b.MinLon >= 360 {
+		b.MinLon, b.MaxLon = -180, 180
+		return b
+	}
+	shift := NormalizeLongitude(b.MinLon) - b.MinLon
+	b.MinLon += shift
+	b.MaxLon += shift
+	if b.MaxLon > 180 {
+		b.MaxLon -= 360
+	}
+	return b
+}
+
+// unionBounds returns the smallest bounding box enclosing all boxes,
+// wrapping across the antimeridian if that is narrower.
+func unionBounds(boxes []BoundingBox) BoundingBox {
+	if len(boxes) == 0 {
+		return BoundingBox{}
+	}
+	u := BoundingBox{MinLat: boxes[0].MinLat, MaxLat: boxes[0].MaxLat}
+	var pieces []BoundingBox
+	for i := range boxes {
+		u.MinLat = math.Min(u.MinLat, boxes[i].MinLat)
+		u.MaxLat = math.Max(u.MaxLat, boxes[i].MaxLat)
+		pieces = append(pieces, boxes[i].Split()...)
+	}
+
+	// Merge the longitude ranges, then leave out the widest gap between
+	// them, counting the one across the antimeridian
+	sort.Slice(pieces, func(i, j int) bool { return pieces[i].MinLon < pieces[j].MinLon })
+	merged := []BoundingBox{pieces[0]}
+	for _, p := range pieces[1:] {
+		last := &merged[len(merged)-1]
+		if p.MinLon <= last.MaxLon {
+			last.MaxLon = math.Max(last.MaxLon, p.MaxLon)
+		} else {
+			merged = append(merged, p)
+		}
+	}
+
+	first, last := merged[0], merged[len(merged)-1]
+	u.MinLon, u.MaxLon = first.MinLon, last.MaxLon
+	gap := first.MinLon + 360 - last.MaxLon
+	for i := 1; i < len(merged); i++ {
+		if g := merged[i].MinLon - merged[i-1].MaxLon; g > gap {
+			gap = g
+			u.MinLon, u.MaxLon = merged[i].MinLon, merged[i-1].MaxLon
+		}
+	}
+	return u
+}
+
+// lonInRange checks if a longitude lies within [minLon, maxLon], which
+// wraps across the antimeridian if minLon > maxLon. Longitudes -180 and 180
+// are the same meridian.
+func lonInRange(lon, minLon, maxLon float64) bool {
+	in := func(lon float64) bool {
+		if minLon <= maxLon {
+			return lon >= minLon && lon <= maxLon
+		}
+		return lon >= minLon || lon <= maxLon
+	}
+	if lon == 180 || lon == -180 {
+		return in(180) || in(-180)
+	}
+	return in(lon)
+}
@@ -0,0 +1,268 @@
+package geofence
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// pacificPolygon spans 170°E to 170°W across the antimeridian.
+var pacificPolygon = []Point{
+	{Latitude: -10, Longitude: 170},
+	{Latitude: -10, Longitude: -170},
+	{Latitude: 10, Longitude: -170},
+	{Latitude: 10, Longitude: 170},
+}
+
+// antarcticRing circles the south pole at 70°S.
+var antarcticRing = []Point{
+	{Latitude: -70, Longitude: 0},
+	{Latitude: -70, Longitude: 90},
+	{Latitude: -70, Longitude: 180},
+	{Latitude: -70, Longitude: -90},
+}
+
+func TestBoundingBox_Antimeridian(t *testing.T) {
+	b := BoundingBox{MinLat: -10, MinLon: 170, MaxLat: 10, MaxLon: -170}
+
+	if !b.CrossesAntimeridian() {
+		t.Error("expected box to cross the antimeridian")
+	}
+
+	contains := []struct {
+		name  string
+		point Point
+		want  bool
+	}{
+		{"east of antimeridian", Point{Latitude: 0, Longitude: 175}, true},
+		{"west of antimeridian", Point{Latitude: 0, Longitude: -175}, true},
+		{"on antimeridian", Point{Latitude: 0, Longitude: 180}, true},
+		{"on antimeridian as -180", Point{Latitude: 0, Longitude: -180}, true},
+		{"prime meridian", Point{Latitude: 0, Longitude: 0}, false},
+		{"north of box", Point{Latitude: 20, Longitude: 175}, false},
+	}
+	for _, tt := range contains {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := b.Contains(tt.point); got != tt.want {
+				t.Errorf("Contains(%v) = %v, want %v", tt.point, got, tt.want)
+			}
+		})
+	}
+
+	parts := b.Split()
+	if len(parts) != 2 {
+		t.Fatalf("Split() returned %d boxes, want 2", len(parts))
+	}
+	if parts[0].MinLon != 170 || parts[0].MaxLon != 180 || parts[1].MinLon != -180 || parts[1].MaxLon != -170 {
+		t.Errorf("Split() = %+v", parts)
+	}
+	plain := BoundingBox{MinLat: 0, MinLon: 10, MaxLat: 1, MaxLon: 11}
+	if parts := plain.Split(); len(parts) != 1 || parts[0] != plain {
+		t.Errorf("Split() of plain box = %+v", parts)
+	}
+
+	intersects := []struct {
+		name  string
+		other BoundingBox
+		want  bool
+	}{
+		{"west part", BoundingBox{MinLat: -1, MinLon: -175, MaxLat: 1, MaxLon: -160}, true},
+		{"east part", BoundingBox{MinLat: -1, MinLon: 160, MaxLat: 1, MaxLon: 172}, true},
+		{"other wrapping box", BoundingBox{MinLat: -1, MinLon: 179, MaxLat: 1, MaxLon: -179}, true},
+		{"touching at -180", BoundingBox{MinLat: -1, MinLon: -180, MaxLat: 1, MaxLon: -180}, true},
+		{"prime meridian", BoundingBox{MinLat: -1, MinLon: -10, MaxLat: 1, MaxLon: 10}, false},
+		{"same longitudes, other latitudes", BoundingBox{MinLat: 20, MinLon: 175, MaxLat: 30, MaxLon: 176}, false},
+	}
+	for _, tt := range intersects {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := b.Intersects(tt.other); got != tt.want {
+				t.Errorf("Intersects(%+v) = %v, want %v", tt.other, got, tt.want)
+			}
+			if got := tt.other.Intersects(b); got != tt.want {
+				t.Errorf("reverse Intersects(%+v) = %v, want %v", tt.other, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPointInPolygon_Antimeridian(t *testing.T) {
+	holes := [][]Point{{
+		{Latitude: -1, Longitude: 179},
+		{Latitude: 1, Longitude: 179},
+		{Latitude: 1, Longitude: -179},
+		{Latitude: -1, Longitude: -179},
+	}}
+
+	tests := []struct {
+		name  string
+		point Point
+		want  bool
+	}{
+		{"east of antimeridian", Point{Latitude: 5, Longitude: 175}, true},
+		{"west of antimeridian", Point{Latitude: 5, Longitude: -175}, true},
+		{"on antimeridian", Point{Latitude: 5, Longitude: 180}, true},
+		{"on antimeridian as -180", Point{Latitude: 5, Longitude: -180}, true},
+		{"in hole across antimeridian", Point{Latitude: 0, Longitude: -179.5}, false},
+		{"prime meridian", Point{Latitude: 5, Longitude: 0}, false},
+		{"just outside", Point{Latitude: 5, Longitude: -169}, false},
+	}
+	for _, edges := range []EdgeType{EdgeTypeRhumb, EdgeTypeGreatCircle} {
+		for _, tt := range tests {
+			t.Run(edges.String()+"/"+tt.name, func(t *testing.T) {
+				if got := pointInPolygonWithHoles(tt.point, pacificPolygon, holes, edges); got != tt.want {
+					t.Errorf("pointInPolygonWithHoles(%v) = %v, want %v", tt.point, got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestPointInPolygon_Pole(t *testing.T) {
+	arctic := []Point{
+		{Latitude: 80, Longitude: -180},
+		{Latitude: 80, Longitude: -60},
+		{Latitude: 80, Longitude: 60},
+	}
+
+	tests := []struct {
+		name  string
+		ring  []Point
+		point Point
+		want  bool
+	}{
+		{"south pole", antarcticRing, Point{Latitude: -90, Longitude: 0}, true},
+		{"inside ring", antarcticRing, Point{Latitude: -80, Longitude: 45}, true},
+		{"inside ring on antimeridian", antarcticRing, Point{Latitude: -80, Longitude: -180}, true},
+		{"outside ring", antarcticRing, Point{Latitude: -60, Longitude: 45}, false},
+		{"other hemisphere", antarcticRing, Point{Latitude: 80, Longitude: 45}, false},
+		{"north pole", arctic, Point{Latitude: 89, Longitude: 123}, true},
+		{"below arctic ring", arctic, Point{Latitude: 70, Longitude: 123}, false},
+	}
+	for _, edges := range []EdgeType{EdgeTypeRhumb, EdgeTypeGreatCircle} {
+		for _, tt := range tests {
+			t.Run(edges.String()+"/"+tt.name, func(t *testing.T) {
+				if got := pointInPolygon(tt.point, tt.ring, edges); got != tt.want {
+					t.Errorf("pointInPolygon(%v) = %v, want %v", tt.point, got, tt.want)
+				}
+			})
+		}
+
+		reversed := []Point{antarcticRing[0], antarcticRing[3], antarcticRing[2], antarcticRing[1]}
+		if !pointInPolygon(Point{Latitude: -80, Longitude: 45}, reversed, edges) {
+			t.Errorf("%s: expected containment to be independent of ring orientation", edges)
+		}
+	}
+}
+
+func TestEnclosedPole(t *testing.T) {
+	if pole, ok := EnclosedPole(antarcticRing); !ok || pole != -90 {
+		t.Errorf("EnclosedPole(antarctic) = %v, %v, want -90, true", pole, ok)
+	}
+	if _, ok := EnclosedPole(pacificPolygon); ok {
+		t.Error("expected antimeridian polygon not to enclose a pole")
+	}
+}
+
+func TestOrientRing(t *testing.T) {
+	ccw := []Point{
+		{Latitude: 0, Longitude: 179},
+		{Latitude: 0, Longitude: -179},
+		{Latitude: 1, Longitude: -179},
+		{Latitude: 1, Longitude: 179},
+	}
+	if o := RingOrientation(ccw); o != 1 {
+		t.Errorf("RingOrientation(ccw) = %d, want 1", o)
+	}
+
+	cw := OrientRing(ccw, false)
+	want := []Point{ccw[0], ccw[3], ccw[2], ccw[1]}
+	if !reflect.DeepEqual(cw, want) {
+		t.Errorf("OrientRing(ccw, false) = %v, want %v", cw, want)
+	}
+	if o := RingOrientation(cw); o != -1 {
+		t.Errorf("RingOrientation(cw) = %d, want -1", o)
+	}
+	if got := OrientRing(ccw, true); !reflect.DeepEqual(got, ccw) {
+		t.Errorf("OrientRing(ccw, true) = %v, want it unchanged", got)
+	}
+
+	if o := RingOrientation(antarcticRing); o != 0 {
+		t.Errorf("RingOrientation(antarctic) = %d, want 0", o)
+	}
+	flat := []Point{{Latitude: 0, Longitude: 0}, {Latitude: 0, Longitude: 1}, {Latitude: 0, Longitude: 2}}
+	if o := RingOrientation(flat); o != 0 {
+		t.Errorf("RingOrientation(flat) = %d, want 0", o)
+	}
+}
+
+func TestGetBounds_Antimeridian(t *testing.T) {
+	const metersPerDegree = earthRadiusMeters * math.Pi / 180
+
+	tests := []struct {
+		name string
+		geom Geometry
+		want BoundingBox
+	}{
+		{
+			name: "polygon across antimeridian",
+			geom: Geometry{Polygon: pacificPolygon},
+			want: BoundingBox{MinLat: -10, MinLon: 170, MaxLat: 10, MaxLon: -170},
+		},
+		{
+			name: "ring around south pole",
+			geom: Geometry{Polygon: antarcticRing},
+			want: BoundingBox{MinLat: -90, MinLon: -180, MaxLat: -70, MaxLon: 180},
+		},
+		{
+			name: "multi-polygon on both sides of antimeridian",
+			geom: Geometry{MultiPolygon: []PolygonPart{
+				{Outer: []Point{{Latitude: 0, Longitude: 170}, {Latitude: 0, Longitude: 175}, {Latitude: 5, Longitude: 175}}},
+				{Outer: []Point{{Latitude: -5, Longitude: -175}, {Latitude: -5, Longitude: -170}, {Latitude: 0, Longitude: -170}}},
+			}},
+			want: BoundingBox{MinLat: -5, MinLon: 170, MaxLat: 5, MaxLon: -170},
+		},
+		{
+			name: "circle on antimeridian",
+			geom: Geometry{CircleCenter: &Point{Latitude: 0, Longitude: 180}, CircleRadius: metersPerDegree},
+			want: BoundingBox{MinLat: -1, MinLon: 179, MaxLat: 1, MaxLon: -179},
+		},
+		{
+			name: "circle over north pole",
+			geom: Geometry{CircleCenter: &Point{Latitude: 89.5, Longitude: 0}, CircleRadius: metersPerDegree},
+			want: BoundingBox{MinLat: 88.5, MinLon: -180, MaxLat: 90, MaxLon: 180},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &FenceItem{Geometry: tt.geom}
+			got := f.GetBounds()
+			if !boundsNear(got, tt.want) {
+				t.Errorf("GetBounds() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCorridor_BoundsAntimeridian(t *testing.T) {
+	c := &Corridor{
+		Path:      []Point{{Latitude: 0, Longitude: 179}, {Latitude: 0, Longitude: -179}},
+		HalfWidth: 1000,
+	}
+	b := c.Bounds()
+	if !b.CrossesAntimeridian() {
+		t.Fatalf("Bounds() = %+v, want box crossing the antimeridian", b)
+	}
+	if b.MinLon >= 179 || b.MinLon < 178.9 || b.MaxLon <= -179 || b.MaxLon > -178.9 {
+		t.Errorf("Bounds() = %+v", b)
+	}
+	if !c.Contains(Point{Latitude: 0, Longitude: 180}) {
+		t.Error("expected corridor to contain point on antimeridian")
+	}
+}
+
+func boundsNear(a, b BoundingBox) bool {
+	const eps = 1e-3
+	return math.Abs(a.MinLat-b.MinLat) < eps && math.Abs(a.MaxLat-b.MaxLat) < eps &&
+		math.Abs(a.MinLon-b.MinLon) < eps && math.Abs(a.MaxLon-b.MaxLon) < eps
+}
@@ -0,0 +1,26 @@
+package geofence
+
+import "time"
+
+// Clock supplies the current time to time-dependent checks, so that they
+// can be evaluated as of any instant: tomorrow's mission during pre-flight
+// planning, or last week's flight when replaying an incident.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is a Clock that reports the system time.
+type SystemClock struct{}
+
+// Now returns time.Now().
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// FixedClock is a Clock that always reports the same instant.
+type FixedClock time.Time
+
+// Now returns the fixed instant.
+func (c FixedClock) Now() time.Time {
+	return time.Time(c)
+}
@@ -0,0 +1,25 @@
+package geofence
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFixedClock(t *testing.T) {
+	at := time.Date(2026, 3, 14, 9, 30, 0, 0, time.UTC)
+	var clock Clock = FixedClock(at)
+
+	if got := clock.Now(); !got.Equal(at) {
+		t.Errorf("Now() = %v, want %v", got, at)
+	}
+}
+
+func TestSystemClock(t *testing.T) {
+	before := time.Now()
+	got := SystemClock{}.Now()
+	after := time.Now()
+
+	if got.Before(before) || got.After(after) {
+		t.Errorf("Now() = %v, want between %v and %v", got, before, after)
+	}
+}
@@ -0,0 +1,119 @@
+package geofence
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// AppliesTo checks if an aircraft is subject to the conditions. A nil
+// Conditions applies to every aircraft, and so does every condition on an
+// attribute the profile leaves unknown: a fence for recreational flights
+// applies to an aircraft of unknown operation, which errs on the side of
+// restriction. Exemptions are only granted for known attributes. A nil
+// aircraft is subject to every fence.
+func (c *Conditions) AppliesTo(a *Aircraft) bool {
+	if c == nil || a == nil {
+		return true
+	}
+
+	if a.Category != AircraftCategoryUnknown && len(c.Categories) > 0 &&
+		!slices.Contains(c.Categories, a.Category) {
+		return false
+	}
+	if a.Operation != OperationTypeUnknown {
+		if len(c.Operations) > 0 && !slices.Contains(c.Operations, a.Operation) {
+			return false
+		}
+		if slices.Contains(c.ExemptOperations, a.Operation) {
+			return false
+		}
+	}
+	if a.MassKg > 0 {
+		if c.MinMassKg > 0 && a.MassKg <= c.MinMassKg {
+			return false
+		}
+		if c.MaxMassKg > 0 && a.MassKg > c.MaxMassKg {
+			return false
+		}
+	}
+	for _, token := range a.Tokens {
+		if slices.Contains(c.ExemptionTokens, token) {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate checks that the conditions refer to known categories and
+// operations and that the mass range is not empty.
+func (c *Conditions) Validate() error {
+	for _, cat := range c.Categories {
+		if cat.String() == "UNKNOWN" {
+			return fmt.Errorf("unknown aircraft category: %d", cat)
+		}
+	}
+	for _, op := range slices.Concat(c.Operations, c.ExemptOperations) {
+		if op.String() == "UNKNOWN" {
+			return fmt.Errorf("unknown operation type: %d", op)
+		}
+	}
+	if c.MinMassKg < 0 || c.MaxMassKg < 0 {
+		return fmt.Errorf("negative mass limit: %v to %v kg", c.MinMassKg, c.MaxMassKg)
+	}
+	if c.MaxMassKg > 0 && c.MaxMassKg <= c.MinMassKg {
+		return fmt.Errorf("maximum mass %v kg is not above minimum mass %v kg", c.MaxMassKg, c.MinMassKg)
+	}
+	for i, token := range c.ExemptionTokens {
+		if token == "" {
+			return fmt.Errorf("exemption token %d is empty", i)
+		}
+	}
+	return nil
+}
+
+// Equal reports whether two sets of conditions are identical. Nil
+// conditions are only equal to each other.
+func (c *Conditions) Equal(o *Conditions) bool {
+	if c == nil || o == nil {
+		return c == o
+	}
+	return slices.Equal(c.Categories, o.Categories) &&
+		slices.Equal(c.Operations, o.Operations) &&
+		slices.Equal(c.ExemptOperations, o.ExemptOperations) &&
+		c.MinMassKg == o.MinMassKg && c.MaxMassKg == o.MaxMassKg &&
+		slices.Equal(c.ExemptionTokens, o.ExemptionTokens)
+}
+
+// AppliesTo checks if the fence applies to an aircraft. See
+// Conditions.AppliesTo.
+func (f *FenceItem) AppliesTo(a *Aircraft) bool {
+	return f.Conditions.AppliesTo(a)
+}
+
+// ApplicableFences returns the fences that apply to an aircraft, for use
+// with any of the evaluation functions. A nil aircraft keeps every fence.
+func ApplicableFences(fences []FenceItem, a *Aircraft) []FenceItem {
+	if a == nil {
+		return fences
+	}
+	applicable := make([]FenceItem, 0, len(fences))
+	for i := range fences {
+		if fences[i].AppliesTo(a) {
+			applicable = append(applicable, fences[i])
+		}
+	}
+	return applicable
+}
+
+// CheckFencesFor is like CheckFences but only considers the fences that
+// apply to an aircraft.
+func CheckFencesFor(fences []FenceItem, p Point, a *Aircraft) CheckResult {
+	return CheckFencesAt(ApplicableFences(fences, a), p, time.Now())
+}
+
+// EvaluateFencesFor is like EvaluateFences but only considers the fences
+// that apply to an aircraft.
+func EvaluateFencesFor(fences []FenceItem, p Point, a *Aircraft) Evaluation {
+	return EvaluateFencesAt(ApplicableFences(fences, a), p, time.Now())
+}
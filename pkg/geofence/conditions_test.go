@@ -0,0 +1,145 @@
+package geofence
+
+import "testing"
+
+func TestConditions_AppliesTo(t *testing.T) {
+	noRecreational := &Conditions{Operations: []OperationType{OperationTypeRecreational}}
+	exemptEmergency := &Conditions{ExemptOperations: []OperationType{OperationTypeEmergency}}
+	heavy := &Conditions{MinMassKg: 25}
+	light := &Conditions{MaxMassKg: 0.25}
+	fixedWing := &Conditions{Categories: []AircraftCategory{AircraftCategoryFixedWing}}
+	token := &Conditions{ExemptionTokens: []string{"stadium-media"}}
+
+	tests := []struct {
+		name       string
+		conditions *Conditions
+		aircraft   *Aircraft
+		want       bool
+	}{
+		{"no conditions", nil, &Aircraft{Operation: OperationTypeRecreational}, true},
+		{"no profile", noRecreational, nil, true},
+		{"restricted operation", noRecreational, &Aircraft{Operation: OperationTypeRecreational}, true},
+		{"other operation", noRecreational, &Aircraft{Operation: OperationTypeCommercial}, false},
+		{"unknown operation", noRecreational, &Aircraft{Category: AircraftCategoryMultirotor}, true},
+		{"exempt operation", exemptEmergency, &Aircraft{Operation: OperationTypeEmergency}, false},
+		{"not exempt", exemptEmergency, &Aircraft{Operation: OperationTypeGovernment}, true},
+		{"unknown operation not exempt", exemptEmergency, &Aircraft{}, true},
+		{"above minimum mass", heavy, &Aircraft{MassKg: 30}, true},
+		{"at minimum mass", heavy, &Aircraft{MassKg: 25}, false},
+		{"below minimum mass", heavy, &Aircraft{MassKg: 2}, false},
+		{"unknown mass", heavy, &Aircraft{Operation: OperationTypeCommercial}, true},
+		{"at maximum mass", light, &Aircraft{MassKg: 0.25}, true},
+		{"above maximum mass", light, &Aircraft{MassKg: 0.9}, false},
+		{"matching category", fixedWing, &Aircraft{Category: AircraftCategoryFixedWing}, true},
+		{"other category", fixedWing, &Aircraft{Category: AircraftCategoryMultirotor}, false},
+		{"unknown category", fixedWing, &Aircraft{}, true},
+		{"holds exemption token", token, &Aircraft{Tokens: []string{"other", "stadium-media"}}, false},
+		{"other token", token, &Aircraft{Tokens: []string{"other"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.conditions.AppliesTo(tt.aircraft); got != tt.want {
+				t.Errorf("AppliesTo(%+v) = %v, want %v", tt.aircraft, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConditions_Validate(t *testing.T) {
+	tests := []struct {
+		name       string
+		conditions Conditions
+		wantErr    bool
+	}{
+		{"empty", Conditions{}, false},
+		{"full", Conditions{
+			Categories:       []AircraftCategory{AircraftCategoryMultirotor},
+			Operations:       []OperationType{OperationTypeRecreational},
+			ExemptOperations: []OperationType{OperationTypeEmergency},
+			MinMassKg:        0.25,
+			MaxMassKg:        25,
+			ExemptionTokens:  []string{"media"},
+		}, false},
+		{"unknown category", Conditions{Categories: []AircraftCategory{AircraftCategoryUnknown}}, true},
+		{"unknown operation", Conditions{Operations: []OperationType{OperationType(99)}}, true},
+		{"unknown exempt operation", Conditions{ExemptOperations: []OperationType{OperationTypeUnknown}}, true},
+		{"negative mass", Conditions{MinMassKg: -1}, true},
+		{"empty mass range", Conditions{MinMassKg: 25, MaxMassKg: 25}, true},
+		{"empty token", Conditions{ExemptionTokens: []string{""}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.conditions.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConditions_Equal(t *testing.T) {
+	a := &Conditions{Operations: []OperationType{OperationTypeRecreational}, MinMassKg: 25}
+	b := &Conditions{Operations: []OperationType{OperationTypeRecreational}, MinMassKg: 25}
+	if !a.Equal(b) {
+		t.Error("expected identical conditions to be equal")
+	}
+	b.ExemptionTokens = []string{"media"}
+	if a.Equal(b) {
+		t.Error("expected conditions with different tokens to differ")
+	}
+	if a.Equal(nil) || !(*Conditions)(nil).Equal(nil) {
+		t.Error("nil conditions must only equal nil")
+	}
+}
+
+func TestCheckFencesFor(t *testing.T) {
+	square := Geometry{BBox: &BoundingBox{MinLat: 0, MinLon: 0, MaxLat: 1, MaxLon: 1}}
+	fences := []FenceItem{
+		{
+			ID:         "no-recreational",
+			Type:       FenceTypeTempRestriction,
+			Geometry:   square,
+			Priority:   10,
+			Conditions: &Conditions{Operations: []OperationType{OperationTypeRecreational}},
+		},
+		{
+			ID:          "heavy-ceiling",
+			Type:        FenceTypeAltitudeLimit,
+			Geometry:    square,
+			MaxAltitude: 50,
+			Conditions:  &Conditions{MinMassKg: 25},
+		},
+	}
+	p := Point{Latitude: 0.5, Longitude: 0.5}
+
+	tests := []struct {
+		name     string
+		aircraft *Aircraft
+		allowed  bool
+		matching int
+	}{
+		{"no profile", nil, false, 2},
+		{"recreational", &Aircraft{Operation: OperationTypeRecreational, MassKg: 1}, false, 1},
+		{"light commercial", &Aircraft{Operation: OperationTypeCommercial, MassKg: 1}, true, 0},
+		{"heavy commercial", &Aircraft{Operation: OperationTypeCommercial, MassKg: 30}, true, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := CheckFencesFor(fences, p, tt.aircraft)
+			if result.Allowed != tt.allowed {
+				t.Errorf("Allowed = %v, want %v", result.Allowed, tt.allowed)
+			}
+			if len(result.MatchingFences) != tt.matching {
+				t.Errorf("MatchingFences = %d, want %d", len(result.MatchingFences), tt.matching)
+			}
+		})
+	}
+
+	ev := EvaluateFencesFor(fences, p, &Aircraft{Operation: OperationTypeCommercial, MassKg: 30})
+	if ceiling, ok := ev.Ceiling(AltitudeReferenceAGL); !ev.Allowed || !ok || ceiling != 50 {
+		t.Errorf("EvaluateFencesFor() = allowed %v, ceiling %v %v, want allowed with ceiling 50", ev.Allowed, ceiling, ok)
+	}
+}
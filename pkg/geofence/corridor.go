@@ -0,0 +1,141 @@
+package geofence
+
+import "math"
+
+const earthRadiusMeters = 6371000.0
+
+// Contains checks if a point lies within HalfWidth meters of the path.
+// Distances are measured along the sphere to the nearest great-circle
+// segment, so long legs and high latitudes are handled correctly.
+func (c *Corridor) Contains(p Point) bool {
+	if c == nil || len(c.Path) == 0 {
+		return false
+	}
+	return c.DistanceTo(p) <= c.HalfWidth
+}
+
+// DistanceTo returns the great-circle distance in meters from a point to
+// the nearest point on the corridor's center line.
+func (c *Corridor) DistanceTo(p Point) float64 {
+	if len(c.Path) == 1 {
+		return haversineDistance(p, c.Path[0])
+	}
+	best := math.Inf(1)
+	for i := 0; i+1 < len(c.Path); i++ {
+		if d := distanceToSegment(p, c.Path[i], c.Path[i+1]); d < best {
+			best = d
+		}
+	}
+	return best
+}
+
+// Bounds returns a bounding box enclosing the whole buffered corridor. The
+// box crosses the antimeridian if the corridor does.
+func (c *Corridor) Bounds() BoundingBox {
+	if len(c.Path) == 0 {
+		return BoundingBox{}
+	}
+	b := boundsFromPoints(UnwrapLongitudes(c.Path))
+
+	// A great-circle leg bulges poleward between its endpoints
+	for i := 0; i+1 < len(c.Path); i++ {
+		if lat, ok := segmentExtremeLatitude(c.Path[i], c.Path[i+1], 1); ok && lat > b.MaxLat {
+			b.MaxLat = lat
+		}
+		if lat, ok := segmentExtremeLatitude(c.Path[i], c.Path[i+1], -1); ok && lat < b.MinLat {
+			b.MinLat = lat
+		}
+	}
+
+	angle := c.HalfWidth / earthRadiusMeters
+	latDelta := angle * 180 / math.Pi
+	maxAbsLat := math.Max(math.Abs(b.MinLat), math.Abs(b.MaxLat))
+	b.MinLat = math.Max(b.MinLat-latDelta, -90)
+	b.MaxLat = math.Min(b.MaxLat+latDelta, 90)
+
+	// Longitude span of a spherical cap of the given radius at the
+	// highest latitude reached by the center line
+	sinLon := math.Sin(angle) / math.Cos(degToRad(maxAbsLat))
+	if b.MinLat <= -90 || b.MaxLat >= 90 || sinLon >= 1 {
+		b.MinLon, b.MaxLon = -180, 180
+		return b
+	}
+	lonDelta := math.Asin(sinLon) * 180 / math.Pi
+	b.MinLon -= lonDelta
+	b.MaxLon += lonDelta
+	return wrapBounds(b)
+}
+
+// vec3 is a point on the unit sphere in Earth-centered coordinates.
+type vec3 struct{ x, y, z float64 }
+
+func toVec3(p Point) vec3 {
+	lat, lon := degToRad(p.Latitude), degToRad(p.Longitude)
+	return vec3{math.Cos(lat) * math.Cos(lon), math.Cos(lat) * math.Sin(lon), math.Sin(lat)}
+}
+
+func (v vec3) latitude() float64 {
+	return math.Atan2(v.z, math.Hypot(v.x, v.y)) * 180 / math.Pi
+}
+
+func (v vec3) dot(w vec3) float64 { return v.x*w.x + v.y*w.y + v.z*w.z }
+
+func (v vec3) cross(w vec3) vec3 {
+	return vec3{v.y*w.z - v.z*w.y, v.z*w.x - v.x*w.z, v.x*w.y - v.y*w.x}
+}
+
+func (v vec3) scale(s float64) vec3 { return vec3{v.x * s, v.y * s, v.z * s} }
+
+func (v vec3) add(w vec3) vec3 { return vec3{v.x + w.x, v.y + w.y, v.z + w.z} }
+
+func (v vec3) sub(w vec3) vec3 { return vec3{v.x - w.x, v.y - w.y, v.z - w.z} }
+
+func (v vec3) norm() float64 { return math.Sqrt(v.dot(v)) }
+
+// onArc checks if c, a point on the great circle through a and b with
+// normal n = a×b, lies on the minor arc from a to b.
+func onArc(a, b, c, n vec3) bool {
+	return a.cross(c).dot(n) >= 0 && c.cross(b).dot(n) >= 0
+}
+
+// distanceToSegment returns the great-circle distance in meters from p to
+// the minor arc between a and b.
+func distanceToSegment(p, a, b Point) float64 {
+	va, vb, vp := toVec3(a), toVec3(b), toVec3(p)
+	n := va.cross(vb)
+	nLen := n.norm()
+	if nLen < 1e-12 {
+		// Degenerate leg: both ends coincide
+		return haversineDistance(p, a)
+	}
+	n = n.scale(1 / nLen)
+
+	// Project p onto the segment's great circle
+	sinXTrack := vp.dot(n)
+	proj := vp.sub(n.scale(sinXTrack))
+	if proj.norm() > 1e-12 && onArc(va, vb, proj, n) {
+		return math.Abs(math.Asin(math.Max(-1, math.Min(1, sinXTrack)))) * earthRadiusMeters
+	}
+	return math.Min(haversineDistance(p, a), haversineDistance(p, b))
+}
+
+// segmentExtremeLatitude returns the northernmost (dir = 1) or southernmost
+// (dir = -1) latitude of the minor arc between a and b if it lies strictly
+// inside the arc rather than at an endpoint.
+func segmentExtremeLatitude(a, b Point, dir float64) (float64, bool) {
+	va, vb := toVec3(a), toVec3(b)
+	n := va.cross(vb)
+	nLen := n.norm()
+	if nLen < 1e-12 {
+		return 0, false
+	}
+	n = n.scale(1 / nLen)
+
+	// The vertex is the pole direction projected onto the great circle
+	pole := vec3{0, 0, dir}
+	vertex := pole.sub(n.scale(pole.dot(n)))
+	if vertex.norm() < 1e-12 || !onArc(va, vb, vertex, n) {
+		return 0, false
+	}
+	return vertex.latitude(), true
+}
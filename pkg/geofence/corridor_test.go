@@ -0,0 +1,145 @@
+package geofence
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCorridor_Contains(t *testing.T) {
+	// East-west road along the equator with a 500 m buffer on each side
+	corridor := &Corridor{
+		Path: []Point{
+			{Latitude: 0, Longitude: 10.0},
+			{Latitude: 0, Longitude: 10.1},
+			{Latitude: 0.1, Longitude: 10.1},
+		},
+		HalfWidth: 500,
+	}
+
+	// One degree of latitude is about 111.2 km on the haversine sphere
+	const metersPerDegree = earthRadiusMeters * math.Pi / 180
+
+	tests := []struct {
+		name  string
+		point Point
+		want  bool
+	}{
+		{"on center line", Point{Latitude: 0, Longitude: 10.05}, true},
+		{"inside buffer", Point{Latitude: 400 / metersPerDegree, Longitude: 10.05}, true},
+		{"outside buffer", Point{Latitude: 600 / metersPerDegree, Longitude: 10.05}, false},
+		{"second leg", Point{Latitude: 0.05, Longitude: 10.1 + 300/metersPerDegree}, true},
+		{"beyond start cap", Point{Latitude: 0, Longitude: 10.0 - 600/metersPerDegree}, false},
+		{"inside start cap", Point{Latitude: 0, Longitude: 10.0 - 400/metersPerDegree}, true},
+		{"inside the bend", Point{Latitude: 0.05, Longitude: 10.05}, false},
+		{"far away", Point{Latitude: 45, Longitude: 10.05}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := corridor.Contains(tt.point); got != tt.want {
+				t.Errorf("Contains(%v) = %v, want %v (distance %.1f m)",
+					tt.point, got, tt.want, corridor.DistanceTo(tt.point))
+			}
+		})
+	}
+}
+
+func TestCorridor_DistanceTo(t *testing.T) {
+	// A meridian leg: cross-track distance equals the longitude offset
+	// times cos(latitude)
+	corridor := &Corridor{Path: []Point{{Latitude: 59, Longitude: 10}, {Latitude: 61, Longitude: 10}}}
+	p := Point{Latitude: 60, Longitude: 10.01}
+
+	got := corridor.DistanceTo(p)
+	want := earthRadiusMeters * math.Asin(math.Cos(degToRad(60))*math.Sin(degToRad(0.01)))
+	if math.Abs(got-want) > 0.01 {
+		t.Errorf("DistanceTo() = %f, want %f", got, want)
+	}
+
+	single := &Corridor{Path: []Point{{Latitude: 0, Longitude: 0}}, HalfWidth: 100}
+	if d := single.DistanceTo(Point{Latitude: 0, Longitude: 0.001}); math.Abs(d-haversineDistance(Point{}, Point{Longitude: 0.001})) > 1e-6 {
+		t.Errorf("single-point DistanceTo() = %f", d)
+	}
+	if (&Corridor{}).Contains(Point{}) {
+		t.Error("empty corridor should not contain any point")
+	}
+}
+
+func TestCorridor_Bounds(t *testing.T) {
+	t.Run("contains buffered path", func(t *testing.T) {
+		corridor := &Corridor{
+			Path:      []Point{{Latitude: 39.9, Longitude: 116.3}, {Latitude: 40.0, Longitude: 116.5}},
+			HalfWidth: 1000,
+		}
+		b := corridor.Bounds()
+		if b.MinLat >= 39.9 || b.MaxLat <= 40.0 || b.MinLon >= 116.3 || b.MaxLon <= 116.5 {
+			t.Errorf("Bounds() = %+v does not enclose the path", b)
+		}
+		// Points one half-width away in every direction must be inside
+		for _, p := range []Point{
+			{Latitude: 40.0 + 900/111195.0, Longitude: 116.5},
+			{Latitude: 39.9 - 900/111195.0, Longitude: 116.3},
+			{Latitude: 40.0, Longitude: 116.5 + 900/(111195.0*math.Cos(degToRad(40)))},
+			{Latitude: 39.9, Longitude: 116.3 - 900/(111195.0*math.Cos(degToRad(40)))},
+		} {
+			if !corridor.Contains(p) {
+				t.Fatalf("test point %v not in corridor", p)
+			}
+			if !b.Contains(p) {
+				t.Errorf("Bounds() = %+v, does not contain %v", b, p)
+			}
+		}
+	})
+
+	t.Run("great-circle bulge", func(t *testing.T) {
+		// A long east-west leg at 60N arcs well north of its endpoints
+		corridor := &Corridor{
+			Path:      []Point{{Latitude: 60, Longitude: -30}, {Latitude: 60, Longitude: 30}},
+			HalfWidth: 100,
+		}
+		midpoint := Point{Latitude: 60, Longitude: 0}
+		if corridor.Contains(midpoint) {
+			t.Fatal("rhumb-line midpoint should lie south of the great-circle leg")
+		}
+		b := corridor.Bounds()
+		apex := math.Atan(math.Tan(degToRad(60))/math.Cos(degToRad(30))) * 180 / math.Pi
+		if b.MaxLat < apex {
+			t.Errorf("MaxLat = %f, want >= %f", b.MaxLat, apex)
+		}
+		if !b.Contains(Point{Latitude: apex, Longitude: 0}) || !corridor.Contains(Point{Latitude: apex, Longitude: 0}) {
+			t.Errorf("apex not covered by corridor or bounds %+v", b)
+		}
+	})
+
+	t.Run("near pole", func(t *testing.T) {
+		corridor := &Corridor{Path: []Point{{Latitude: 89.99, Longitude: 0}}, HalfWidth: 5000}
+		b := corridor.Bounds()
+		if b.MaxLat != 90 || b.MinLon != -180 || b.MaxLon != 180 {
+			t.Errorf("Bounds() = %+v, want full longitude range up to the pole", b)
+		}
+	})
+}
+
+func TestFenceItem_Corridor(t *testing.T) {
+	fence := FenceItem{
+		ID:   "motorcade",
+		Type: FenceTypeTempRestriction,
+		Geometry: Geometry{
+			Corridor: &Corridor{
+				Path:      []Point{{Latitude: 39.90, Longitude: 116.39}, {Latitude: 39.90, Longitude: 116.45}},
+				HalfWidth: 500,
+			},
+		},
+	}
+
+	if !fence.ContainsPoint(Point{Latitude: 39.902, Longitude: 116.42}) {
+		t.Error("expected point 220 m from the route to be restricted")
+	}
+	if fence.ContainsPoint(Point{Latitude: 39.91, Longitude: 116.42}) {
+		t.Error("expected point 1.1 km from the route to be allowed")
+	}
+	b := fence.GetBounds()
+	if b != fence.Geometry.Corridor.Bounds() {
+		t.Errorf("GetBounds() = %+v, want corridor bounds", b)
+	}
+}
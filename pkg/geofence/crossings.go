@@ -0,0 +1,339 @@
+package geofence
+
+import (
+	"math"
+	"sort"
+)
+
+// arc is a great-circle arc prepared for intersection with fence
+// boundaries. Points on it are parameterized by the angle theta travelled
+// from its start, in [0, omega].
+type arc struct {
+	from, to Point
+	a, u     vec3    // Start, and the unit tangent at the start
+	n        vec3    // Unit normal of the arc's great circle
+	omega    float64 // Length in radians
+}
+
+// newArc returns the minor arc from one point to another, and false if the
+// points coincide.
+func newArc(from, to Point) (arc, bool) {
+	a, b := toVec3(from), toVec3(to)
+	n := a.cross(b)
+	nLen := n.norm()
+	if nLen < 1e-12 {
+		return arc{}, false
+	}
+	n = n.scale(1 / nLen)
+	return arc{
+		from:  from,
+		to:    to,
+		a:     a,
+		u:     n.cross(a),
+		n:     n,
+		omega: math.Acos(math.Max(-1, math.Min(1, a.dot(b)))),
+	}, true
+}
+
+// point returns the point at angle theta along the arc's great circle.
+func (c *arc) point(theta float64) vec3 {
+	return c.a.scale(math.Cos(theta)).add(c.u.scale(math.Sin(theta)))
+}
+
+// theta returns the angle of a point on the arc's great circle, in
+// [0, 2π).
+func (c *arc) theta(v vec3) float64 {
+	t := math.Atan2(v.dot(c.u), v.dot(c.a))
+	if t < 0 {
+		t += 2 * math.Pi
+	}
+	return t
+}
+
+// appendTheta appends theta if it lies on the arc.
+func (c *arc) appendTheta(thetas []float64, theta float64) []float64 {
+	if theta <= c.omega {
+		return append(thetas, theta)
+	}
+	return thetas
+}
+
+// circleCrossings returns the angles along the arc at which it meets the
+// circle of points v with v·axis = d: a parallel for the polar axis, a
+// great circle for d = 0, or the edge of a spherical cap around axis.
+func (c *arc) circleCrossings(axis vec3, d float64) []float64 {
+	// v(θ)·axis = A cos θ + B sin θ = R cos(θ - φ)
+	A, B := c.a.dot(axis), c.u.dot(axis)
+	R := math.Hypot(A, B)
+	if R < 1e-15 || math.Abs(d) > R {
+		return nil
+	}
+	phi := math.Atan2(B, A)
+	delta := math.Acos(d / R)
+
+	var thetas []float64
+	for _, t := range [2]float64{phi - delta, phi + delta} {
+		t = math.Mod(t, 2*math.Pi)
+		if t < 0 {
+			t += 2 * math.Pi
+		}
+		thetas = c.appendTheta(thetas, t)
+	}
+	return thetas
+}
+
+// crossings returns the fractions of the arc, in ascending order, at which
+// it may cross the boundary of a geometry. Containment of points on the
+// arc does not change between two consecutive fractions. Extra fractions
+// where it does not change may be included.
+func (c *arc) crossings(g *Geometry) []float64 {
+	var thetas []float64
+	switch {
+	case g.BBox != nil:
+		thetas = c.bboxCrossings(g.BBox)
+	case len(g.Polygon) > 0:
+		thetas = c.ringCrossings(thetas, g.Polygon, g.Edges)
+		for _, hole := range g.Holes {
+			thetas = c.ringCrossings(thetas, hole, g.Edges)
+		}
+	case len(g.MultiPolygon) > 0:
+		for _, part := range g.MultiPolygon {
+			thetas = c.ringCrossings(thetas, part.Outer, g.Edges)
+			for _, hole := range part.Holes {
+				thetas = c.ringCrossings(thetas, hole, g.Edges)
+			}
+		}
+	case g.Corridor != nil:
+		thetas = c.corridorCrossings(g.Corridor)
+	case g.CircleCenter != nil:
+		thetas = c.circleCrossings(toVec3(*g.CircleCenter), math.Cos(g.CircleRadius/earthRadiusMeters))
+	}
+
+	fracs := make([]float64, len(thetas))
+	for i, t := range thetas {
+		fracs[i] = math.Min(t/c.omega, 1)
+	}
+	sort.Float64s(fracs)
+	return fracs
+}
+
+// bboxCrossings returns where the arc meets the parallels and meridians
+// bounding a box.
+func (c *arc) bboxCrossings(b *BoundingBox) []float64 {
+	var thetas []float64
+	for _, lat := range [2]float64{b.MinLat, b.MaxLat} {
+		thetas = append(thetas, c.circleCrossings(vec3{0, 0, 1}, math.Sin(degToRad(lat)))...)
+	}
+	for _, lon := range [2]float64{b.MinLon, b.MaxLon} {
+		thetas = append(thetas, c.circleCrossings(meridianNormal(lon), 0)...)
+	}
+	return thetas
+}
+
+// corridorCrossings returns where the arc meets the boundary of a corridor:
+// the circles around its vertices, and the lines at HalfWidth on either
+// side of its segments.
+func (c *arc) corridorCrossings(cor *Corridor) []float64 {
+	angle := cor.HalfWidth / earthRadiusMeters
+	var thetas []float64
+	for _, p := range cor.Path {
+		thetas = append(thetas, c.circleCrossings(toVec3(p), math.Cos(angle))...)
+	}
+	for i := 0; i+1 < len(cor.Path); i++ {
+		va, vb := toVec3(cor.Path[i]), toVec3(cor.Path[i+1])
+		n := va.cross(vb)
+		nLen := n.norm()
+		if nLen < 1e-12 {
+			continue
+		}
+		n = n.scale(1 / nLen)
+		for _, d := range [2]float64{math.Sin(angle), -math.Sin(angle)} {
+			for _, t := range c.circleCrossings(n, d) {
+				// Beyond the ends of the segment the vertex circles apply
+				v := c.point(t)
+				if onArc(va, vb, v.sub(n.scale(d)), n) {
+					thetas = append(thetas, t)
+				}
+			}
+		}
+	}
+	return thetas
+}
+
+// ringCrossings appends where the arc crosses the edges of a ring.
+func (c *arc) ringCrossings(thetas []float64, ring []Point, edges EdgeType) []float64 {
+	if edges == EdgeTypeGreatCircle {
+		for i := range ring {
+			va, vb := toVec3(ring[i]), toVec3(ring[(i+1)%len(ring)])
+			n := va.cross(vb)
+			if n.norm() < 1e-12 {
+				continue
+			}
+			for _, t := range c.circleCrossings(n.scale(1/n.norm()), 0) {
+				if onArc(va, vb, c.point(t), n) {
+					thetas = append(thetas, t)
+				}
+			}
+		}
+		return thetas
+	}
+
+	// Rhumb rings are tested in unwrapped longitudes, see pointInPolygon
+	ring, _, _ = planarRing(ring)
+	j := len(ring) - 1
+	for i := range ring {
+		thetas = c.rhumbCrossings(thetas, ring[j], ring[i])
+		j = i
+	}
+	return thetas
+}
+
+// rhumbCrossings appends where the arc crosses the rhumb line from p to q,
+// given in unwrapped longitudes.
+func (c *arc) rhumbCrossings(thetas []float64, p, q Point) []float64 {
+	minLon, maxLon := math.Min(p.Longitude, q.Longitude), math.Max(p.Longitude, q.Longitude)
+	minLat, maxLat := math.Min(p.Latitude, q.Latitude), math.Max(p.Latitude, q.Latitude)
+
+	switch {
+	case p.Latitude == q.Latitude:
+		// Along a parallel
+		for _, t := range c.circleCrossings(vec3{0, 0, 1}, math.Sin(degToRad(p.Latitude))) {
+			if _, ok := unwrapInto(vecLongitude(c.point(t)), minLon, maxLon); ok {
+				thetas = append(thetas, t)
+			}
+		}
+		return thetas
+
+	case p.Longitude == q.Longitude:
+		// Along a meridian
+		m := meridianNormal(p.Longitude)
+		east := vec3{math.Cos(degToRad(p.Longitude)), math.Sin(degToRad(p.Longitude)), 0}
+		for _, t := range c.circleCrossings(m, 0) {
+			v := c.point(t)
+			if lat := v.latitude(); v.dot(east) >= 0 && lat >= minLat && lat <= maxLat {
+				thetas = append(thetas, t)
+			}
+		}
+		return thetas
+	}
+
+	// The rhumb line is straight in Mercator coordinates: y = y1 + k(λ-λ1)
+	lon1 := degToRad(p.Longitude)
+	y1 := mercatorY(p.Latitude)
+	k := (mercatorY(q.Latitude) - y1) / (degToRad(q.Longitude) - lon1)
+	latAt := func(lonDeg float64) float64 {
+		return math.Atan(math.Sinh(y1 + k*(degToRad(lonDeg)-lon1)))
+	}
+
+	rho := math.Hypot(c.n.x, c.n.y)
+	if math.Abs(c.n.z) < 1e-12 {
+		// The arc runs along a meridian, or over a pole onto the
+		// opposite one
+		for _, lon := range [2]float64{
+			math.Atan2(c.n.x, -c.n.y) * 180 / math.Pi,
+			math.Atan2(-c.n.x, c.n.y) * 180 / math.Pi,
+		} {
+			if lon, ok := unwrapInto(lon, minLon, maxLon); ok {
+				lat := latAt(lon)
+				v := vec3{math.Cos(lat) * math.Cos(degToRad(lon)), math.Cos(lat) * math.Sin(degToRad(lon)), math.Sin(lat)}
+				thetas = c.appendTheta(thetas, c.theta(v))
+			}
+		}
+		return thetas
+	}
+
+	// On the arc's great circle tan(lat) = C cos(λ - λ0), so its Mercator
+	// y is asinh(C cos(λ - λ0)): concave north of the equator and convex
+	// south of it. The difference to the rhumb line therefore changes
+	// monotonically between the equator crossings and the points where
+	// the slopes are equal, and has at most one root on each piece.
+	C := -rho / c.n.z
+	lon0 := math.Atan2(c.n.y, c.n.x)
+	g := func(lon float64) float64 {
+		return math.Asinh(C*math.Cos(lon-lon0)) - y1 - k*(lon-lon1)
+	}
+	bases := []float64{math.Pi / 2, -math.Pi / 2}
+	if C != 0 {
+		if s2 := k * k * (1 + C*C) / (C * C * (1 + k*k)); s2 <= 1 {
+			s := math.Asin(math.Sqrt(s2))
+			bases = append(bases, s, math.Pi-s, -s, math.Pi+s)
+		}
+	}
+
+	// The longitudes of a minor arc not passing over a pole run the short
+	// way from one end to the other
+	start := c.from.Longitude
+	end := start + shortLonDelta(start, c.to.Longitude)
+	legMin, legMax := math.Min(start, end), math.Max(start, end)
+	shift := 360 * math.Ceil((minLon-legMax)/360)
+	for ; legMin+shift <= maxLon; shift += 360 {
+		lo := degToRad(math.Max(minLon, legMin+shift))
+		hi := degToRad(math.Min(maxLon, legMax+shift))
+		if lo > hi {
+			continue
+		}
+
+		splits := []float64{lo, hi}
+		for _, b := range bases {
+			for lon := b + lon0 + 2*math.Pi*math.Ceil((lo-b-lon0)/(2*math.Pi)); lon < hi; lon += 2 * math.Pi {
+				splits = append(splits, lon)
+			}
+		}
+		sort.Float64s(splits)
+
+		for i := 0; i+1 < len(splits); i++ {
+			lon, ok := bisectRoot(g, splits[i], splits[i+1])
+			if !ok {
+				continue
+			}
+			lat := math.Atan(C * math.Cos(lon-lon0))
+			v := vec3{math.Cos(lat) * math.Cos(lon), math.Cos(lat) * math.Sin(lon), math.Sin(lat)}
+			thetas = c.appendTheta(thetas, c.theta(v))
+		}
+	}
+	return thetas
+}
+
+// bisectRoot returns the root of a function monotonic on [lo, hi], and
+// false if it has none there.
+func bisectRoot(g func(float64) float64, lo, hi float64) (float64, bool) {
+	glo, ghi := g(lo), g(hi)
+	switch {
+	case glo == 0:
+		return lo, true
+	case ghi == 0:
+		return hi, true
+	case (glo > 0) == (ghi > 0):
+		return 0, false
+	}
+	for i := 0; i < 100 && hi-lo > 1e-15; i++ {
+		mid := (lo + hi) / 2
+		if gm := g(mid); gm == 0 {
+			return mid, true
+		} else if (gm > 0) == (glo > 0) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2, true
+}
+
+// meridianNormal returns the normal of the great circle through the poles
+// and longitude lon.
+func meridianNormal(lon float64) vec3 {
+	rad := degToRad(lon)
+	return vec3{-math.Sin(rad), math.Cos(rad), 0}
+}
+
+// vecLongitude returns the longitude of a point on the unit sphere.
+func vecLongitude(v vec3) float64 {
+	return math.Atan2(v.y, v.x) * 180 / math.Pi
+}
+
+// unwrapInto shifts a longitude by a multiple of 360° into [minLon, maxLon]
+// and returns false if no equivalent longitude lies in the range.
+func unwrapInto(lon, minLon, maxLon float64) (float64, bool) {
+	lon += 360 * math.Ceil((minLon-lon)/360)
+	return lon, lon <= maxLon
+}
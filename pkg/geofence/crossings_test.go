@@ -0,0 +1,121 @@
+package geofence
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestArcCrossings(t *testing.T) {
+	geometries := map[string]Geometry{
+		"rhumb polygon with hole": {
+			Polygon: []Point{
+				{Latitude: 39.4, Longitude: 116.4},
+				{Latitude: 39.45, Longitude: 116.7},
+				{Latitude: 39.65, Longitude: 116.6},
+				{Latitude: 39.6, Longitude: 116.35},
+			},
+			Holes: [][]Point{{
+				{Latitude: 39.48, Longitude: 116.48},
+				{Latitude: 39.5, Longitude: 116.55},
+				{Latitude: 39.55, Longitude: 116.5},
+			}},
+		},
+		"great-circle polygon": {
+			Polygon: []Point{
+				{Latitude: 60, Longitude: 10},
+				{Latitude: 60, Longitude: 30},
+				{Latitude: 65, Longitude: 20},
+			},
+			Edges: EdgeTypeGreatCircle,
+		},
+		"long rhumb edges": {
+			Polygon: []Point{
+				{Latitude: 60, Longitude: 10},
+				{Latitude: 62, Longitude: 30},
+				{Latitude: 65, Longitude: 20},
+			},
+		},
+		"rhumb polygon across the antimeridian": {
+			Polygon: []Point{
+				{Latitude: -10, Longitude: 178},
+				{Latitude: -10, Longitude: -178},
+				{Latitude: -5, Longitude: -179},
+				{Latitude: -6, Longitude: 179},
+			},
+		},
+		"rhumb ring around the pole": {
+			Polygon: []Point{
+				{Latitude: 80, Longitude: 0},
+				{Latitude: 82, Longitude: 90},
+				{Latitude: 80, Longitude: 180},
+				{Latitude: 81, Longitude: -90},
+			},
+		},
+		"multi-polygon": {
+			MultiPolygon: []PolygonPart{
+				{Outer: []Point{{Latitude: 0, Longitude: 0}, {Latitude: 0, Longitude: 1}, {Latitude: 1, Longitude: 1}}},
+				{Outer: []Point{{Latitude: 2, Longitude: 0}, {Latitude: 2, Longitude: 1}, {Latitude: 3, Longitude: 0}}},
+			},
+			Edges: EdgeTypeGreatCircle,
+		},
+		"bbox across the antimeridian": {
+			BBox: &BoundingBox{MinLat: -1, MinLon: 179, MaxLat: 1, MaxLon: -179},
+		},
+		"circle": {
+			CircleCenter: &Point{Latitude: 45, Longitude: 7},
+			CircleRadius: 20000,
+		},
+		"corridor": {
+			Corridor: &Corridor{
+				Path:      []Point{{Latitude: 45, Longitude: 7}, {Latitude: 45.2, Longitude: 7.3}, {Latitude: 45.1, Longitude: 7.5}},
+				HalfWidth: 300,
+			},
+		},
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for name, g := range geometries {
+		t.Run(name, func(t *testing.T) {
+			b := (&FenceItem{Geometry: g}).GetBounds()
+			for i := 0; i < 100; i++ {
+				// Legs starting and ending around the geometry
+				random := func() Point {
+					span := b.MaxLon - b.MinLon
+					if span < 0 {
+						span += 360
+					}
+					return Point{
+						Latitude:  math.Max(-89.9, math.Min(89.9, b.MinLat-0.2+rng.Float64()*(b.MaxLat-b.MinLat+0.4))),
+						Longitude: NormalizeLongitude(b.MinLon - 0.2 + rng.Float64()*(span+0.4)),
+					}
+				}
+				from, to := random(), random()
+				c, ok := newArc(from, to)
+				if !ok {
+					continue
+				}
+				fracs := c.crossings(&g)
+
+				// Every change of containment between two samples must be
+				// bracketed by a crossing
+				const samples = 1000
+				prev := g.ContainsPoint(from)
+				for k := 1; k <= samples; k++ {
+					lo, hi := float64(k-1)/samples, float64(k)/samples
+					inside := g.ContainsPoint(interpolateGreatCircle(from, to, hi))
+					if inside == prev {
+						continue
+					}
+					prev = inside
+					j := sort.SearchFloat64s(fracs, lo-1e-9)
+					if j == len(fracs) || fracs[j] > hi+1e-9 {
+						t.Fatalf("leg %v to %v: containment changes between %.4f and %.4f without a crossing in %v",
+							from, to, lo, hi, fracs)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package geofence
+
+import "math"
+
+// maxMercatorLatitude bounds the latitudes projected onto the Mercator
+// chart, whose y coordinate diverges at the poles.
+const maxMercatorLatitude = 89.999999
+
+// rhumbTolerance is how closely, in meters, great-circle chords must
+// follow a rhumb line to stand in for it when measuring distances.
+const rhumbTolerance = 0.01
+
+// pointInRhumbPolygon casts a ray north from the point and counts the rhumb
+// line edges it crosses. A rhumb line is straight in Mercator coordinates,
+// so where it crosses the point's meridian is interpolated in Mercator y.
+// Longitudes must be unwrapped into the range of the ring.
+func pointInRhumbPolygon(p Point, polygon []Point) bool {
+	inside := false
+	y := mercatorY(p.Latitude)
+	j := len(polygon) - 1
+
+	for i := 0; i < len(polygon); i++ {
+		vi := polygon[i]
+		vj := polygon[j]
+		j = i
+
+		if (vi.Longitude > p.Longitude) == (vj.Longitude > p.Longitude) {
+			continue
+		}
+		if vi.Latitude == vj.Latitude {
+			if p.Latitude < vi.Latitude {
+				inside = !inside
+			}
+			continue
+		}
+		yi, yj := mercatorY(vi.Latitude), mercatorY(vj.Latitude)
+		if y < yi+(yj-yi)*(p.Longitude-vi.Longitude)/(vj.Longitude-vi.Longitude) {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
+
+// mercatorY returns the Mercator projection of a latitude.
+func mercatorY(lat float64) float64 {
+	lat = math.Max(-maxMercatorLatitude, math.Min(maxMercatorLatitude, lat))
+	return math.Atanh(math.Sin(degToRad(lat)))
+}
+
+// distanceToRhumbSegment returns the great-circle distance in meters from p
+// to the rhumb line between a and b, taken the short way around in
+// longitude. The line is split into great-circle chords until each follows
+// it within rhumbTolerance; parts farther from p than best are not split.
+func distanceToRhumbSegment(p, a, b Point, best float64) float64 {
+	b.Longitude = a.Longitude + shortLonDelta(a.Longitude, b.Longitude)
+	return distanceToRhumbChords(p, a, b, best, 0)
+}
+
+func distanceToRhumbChords(p, a, b Point, best float64, depth int) float64 {
+	d := distanceToSegment(p, a, b)
+	if a.Longitude == b.Longitude || depth >= 40 {
+		// Meridians are great circles
+		return d
+	}
+
+	// The rhumb line is straight in Mercator coordinates
+	y := (mercatorY(a.Latitude) + mercatorY(b.Latitude)) / 2
+	mid := Point{
+		Latitude:  math.Atan(math.Sinh(y)) * 180 / math.Pi,
+		Longitude: (a.Longitude + b.Longitude) / 2,
+	}
+	dev := haversineDistance(mid, interpolateGreatCircle(a, b, 0.5))
+	if dev <= rhumbTolerance || d-2*dev > best {
+		return d
+	}
+
+	d = distanceToRhumbChords(p, a, mid, best, depth+1)
+	return math.Min(d, distanceToRhumbChords(p, mid, b, math.Min(best, d), depth+1))
+}
+
+// pointInSphericalPolygon checks if a point is inside a ring of great-circle
+// edges. It follows the point's meridian north to the pole, whose
+// containment is known, and counts the edges crossed on the way.
+func pointInSphericalPolygon(p Point, polygon []Point) bool {
+	pole, enclosesPole := EnclosedPole(polygon)
+	inside := enclosesPole && pole > 0
+
+	for i := range polygon {
+		a, b := polygon[i], polygon[(i+1)%len(polygon)]
+
+		// Longitudes relative to the point's meridian
+		da := shortLonDelta(p.Longitude, a.Longitude)
+		db := shortLonDelta(p.Longitude, b.Longitude)
+		if (da > 0) == (db > 0) || math.Abs(db-da) > 180 {
+			// The edge stays on one side, or crosses the opposite meridian
+			continue
+		}
+		if lat, ok := meridianCrossing(a, b, p.Longitude); ok && lat > p.Latitude {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
+
+// meridianCrossing returns the latitude at which the great circle through
+// a and b crosses the meridian at lon, on the meridian's side of the globe
+// rather than the opposite one.
+func meridianCrossing(a, b Point, lon float64) (float64, bool) {
+	n := toVec3(a).cross(toVec3(b))
+	rad := degToRad(lon)
+	meridian := vec3{-math.Sin(rad), math.Cos(rad), 0}
+
+	d := n.cross(meridian)
+	if d.norm() < 1e-12 {
+		return 0, false
+	}
+	if d.x*math.Cos(rad)+d.y*math.Sin(rad) < 0 {
+		d = d.scale(-1)
+	}
+	return d.latitude(), true
+}
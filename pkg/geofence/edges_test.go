@@ -0,0 +1,137 @@
+package geofence
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEdgeType_String(t *testing.T) {
+	tests := []struct {
+		edges EdgeType
+		want  string
+	}{
+		{EdgeTypeRhumb, "RHUMB"},
+		{EdgeTypeGreatCircle, "GREAT_CIRCLE"},
+		{EdgeType(99), "UNKNOWN"},
+	}
+	for _, tt := range tests {
+		if got := tt.edges.String(); got != tt.want {
+			t.Errorf("String() = %v, want %v", got, tt.want)
+		}
+	}
+}
+
+func TestContainsPoint_EdgeTypes(t *testing.T) {
+	// A 10° by 60° region in the northern hemisphere: great-circle edges
+	// along its north and south sides bulge poleward, to 63.4°N and 54.0°N
+	// at 30°E
+	region := []Point{
+		{Latitude: 50, Longitude: 0},
+		{Latitude: 50, Longitude: 60},
+		{Latitude: 60, Longitude: 60},
+		{Latitude: 60, Longitude: 0},
+	}
+	// A triangle whose diagonal crosses 30°E at 35.3°N as a rhumb line, at
+	// 45°N as a great circle and at 30°N as a straight line in latitude and
+	// longitude
+	triangle := []Point{
+		{Latitude: 0, Longitude: 0},
+		{Latitude: 60, Longitude: 60},
+		{Latitude: 0, Longitude: 60},
+	}
+
+	tests := []struct {
+		name        string
+		ring        []Point
+		point       Point
+		rhumb       bool
+		greatCircle bool
+	}{
+		{"center", region, Point{Latitude: 56, Longitude: 30}, true, true},
+		{"north of rhumb edge", region, Point{Latitude: 61, Longitude: 30}, false, true},
+		{"north of great circle", region, Point{Latitude: 64, Longitude: 30}, false, false},
+		{"south of great circle", region, Point{Latitude: 52, Longitude: 30}, true, false},
+		{"near a vertex", region, Point{Latitude: 50.5, Longitude: 0.5}, true, true},
+		{"below rhumb diagonal", triangle, Point{Latitude: 33, Longitude: 30}, true, true},
+		{"above rhumb diagonal", triangle, Point{Latitude: 36, Longitude: 30}, false, true},
+		{"above great-circle diagonal", triangle, Point{Latitude: 46, Longitude: 30}, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rhumb := &Geometry{Polygon: tt.ring}
+			if got := rhumb.ContainsPoint(tt.point); got != tt.rhumb {
+				t.Errorf("rhumb ContainsPoint(%v) = %v, want %v", tt.point, got, tt.rhumb)
+			}
+			gc := &Geometry{Polygon: tt.ring, Edges: EdgeTypeGreatCircle}
+			if got := gc.ContainsPoint(tt.point); got != tt.greatCircle {
+				t.Errorf("great-circle ContainsPoint(%v) = %v, want %v", tt.point, got, tt.greatCircle)
+			}
+		})
+	}
+}
+
+func TestMeridianCrossing(t *testing.T) {
+	a := Point{Latitude: 60, Longitude: 0}
+	b := Point{Latitude: 60, Longitude: 60}
+
+	lat, ok := meridianCrossing(a, b, 30)
+	want := math.Atan(math.Tan(degToRad(60))/math.Cos(degToRad(30))) * 180 / math.Pi
+	if !ok || math.Abs(lat-want) > 1e-9 {
+		t.Errorf("meridianCrossing() = %v, %v, want %v", lat, ok, want)
+	}
+	// Edges crossing the point's meridian must not report the crossing on
+	// the opposite side of the globe
+	if lat, ok := meridianCrossing(a, b, -150); !ok || math.Abs(lat+want) > 1e-9 {
+		t.Errorf("meridianCrossing() at opposite meridian = %v, %v, want %v", lat, ok, -want)
+	}
+}
+
+func TestGetBounds_GreatCircleEdges(t *testing.T) {
+	f := &FenceItem{Geometry: Geometry{
+		Polygon: []Point{
+			{Latitude: 50, Longitude: 0},
+			{Latitude: 50, Longitude: 60},
+			{Latitude: 60, Longitude: 60},
+			{Latitude: 60, Longitude: 0},
+		},
+		Edges: EdgeTypeGreatCircle,
+	}}
+
+	b := f.GetBounds()
+	wantMax := math.Atan(2) * 180 / math.Pi // tan 60° / cos 30° = 2
+	if math.Abs(b.MaxLat-wantMax) > 1e-6 || b.MinLat != 50 {
+		t.Errorf("GetBounds() = %+v, want latitudes 50 to %v", b, wantMax)
+	}
+
+	f.Geometry.Edges = EdgeTypeRhumb
+	if b := f.GetBounds(); b.MaxLat != 60 {
+		t.Errorf("rhumb GetBounds() MaxLat = %v, want 60", b.MaxLat)
+	}
+}
+
+func TestGetBounds_CircleExact(t *testing.T) {
+	for _, lat := range []float64{0, 45, 70, 85, 89} {
+		center := Point{Latitude: lat, Longitude: 10}
+		const radius = 50000.0
+		f := &FenceItem{Geometry: Geometry{CircleCenter: &center, CircleRadius: radius}}
+		b := f.GetBounds()
+
+		// Every point on the circle lies within the bounds, and the widest
+		// one touches them
+		widest := 0.0
+		for deg := 0; deg < 360; deg++ {
+			p := destinationPoint(center, degToRad(float64(deg)), radius)
+			const eps = 1e-9
+			padded := BoundingBox{MinLat: b.MinLat - eps, MinLon: b.MinLon - eps, MaxLat: b.MaxLat + eps, MaxLon: b.MaxLon + eps}
+			if !padded.Contains(p) {
+				t.Errorf("lat %v: bounds %+v do not contain %v", lat, b, p)
+				break
+			}
+			widest = math.Max(widest, math.Abs(shortLonDelta(center.Longitude, p.Longitude)))
+		}
+		if got := b.MaxLon - center.Longitude; b.MaxLon != 180 && got-widest > 0.01*got {
+			t.Errorf("lat %v: bounds extend %v° east, circle only %v°", lat, got, widest)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package geofence
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// EvaluateFences combines every fence that applies at a point into a single
+// Evaluation.
+//
+// Fences that do not contain the point or are not active are ignored. The
+// remaining fences are combined as follows, independent of the order they
+// are given in:
+//
+//   - Prohibitions win. A PERMANENT_NO_FLY or TEMP_RESTRICTION fence
+//     without an altitude band, or with a band from the ground up and no
+//     ceiling, forbids flight whatever its priority relative to the other
+//     fences. One with a narrower band forbids flight only within the band,
+//     which is listed in Prohibited; AllowsAltitude tests an altitude
+//     against it. Priority only decides which of several prohibiting fences
+//     is reported as the Restriction.
+//   - Limits combine to the most restrictive value: the lowest ceiling, the
+//     highest floor and the lowest speed limit.
+//   - Ceilings and floors are only compared within the same altitude
+//     reference, since converting between datums needs terrain or geoid data.
+//     Each reference therefore has its own effective ceiling and floor.
+//   - A floor above the ceiling in the same reference leaves no altitude at
+//     which flight is legal, so flight is forbidden and the higher-priority
+//     of the two fences is reported as the Restriction. The same holds when
+//     the prohibited bands of a reference cover every altitude between its
+//     floor, or the ground, and its ceiling; the highest-priority of the
+//     fences involved is reported. Altitudes below zero are not considered.
+//
+// Constraints lists every fence that contributed, highest priority first;
+// Binding marks the fences that set an effective limit or forbid flight.
+func EvaluateFences(fences []FenceItem, p Point) Evaluation {
+	return EvaluateFencesAt(fences, p, time.Now())
+}
+
+// EvaluateFencesAt is like EvaluateFences but evaluates fences as of time t.
+func EvaluateFencesAt(fences []FenceItem, p Point, t time.Time) Evaluation {
+	return evaluateFences(fences, p, t, func(*FenceItem) string { return "" })
+}
+
+// evaluateFences implements EvaluateFencesAt, lifting the fences for which
+// unlockedBy returns a token ID.
+func evaluateFences(fences []FenceItem, p Point, t time.Time, unlockedBy func(*FenceItem) string) Evaluation {
+	ev := Evaluation{Allowed: true}
+
+	// Index into ev.Constraints of the fence setting each effective limit
+	ceilings := make(map[AltitudeReference]int)
+	floors := make(map[AltitudeReference]int)
+	speed := -1
+	// Indexes into ev.Constraints of the banded no-fly fences
+	banded := make(map[AltitudeReference][]int)
+
+	for i := range fences {
+		f := &fences[i]
+		if !f.ContainsPoint(p) || !f.IsActiveAt(t) {
+			continue
+		}
+		if token := unlockedBy(f); token != "" {
+			if reason, ok := constraintReason(f); ok {
+				ev.Constraints = append(ev.Constraints, Constraint{Fence: *f, Reason: reason, UnlockedBy: token})
+				ev.Authorized = true
+			}
+			continue
+		}
+
+		switch f.Type {
+		case FenceTypePermanentNoFly, FenceTypeTempRestriction:
+			ev.Constraints = append(ev.Constraints, Constraint{Fence: *f, Reason: ConstraintNoFly, Binding: true})
+			if b := f.Altitude; b != nil && (b.Floor > 0 || b.Ceiling > 0) {
+				banded[b.Reference] = append(banded[b.Reference], len(ev.Constraints)-1)
+				ev.Prohibited = append(ev.Prohibited, *b)
+				continue
+			}
+			if ev.Restriction == nil || f.Priority > ev.Restriction.Priority {
+				ev.Restriction = f
+			}
+		case FenceTypeAltitudeLimit:
+			ceiling, ref := f.CeilingAltitude()
+			if ceiling == 0 {
+				continue
+			}
+			ev.Constraints = append(ev.Constraints, Constraint{Fence: *f, Reason: ConstraintCeiling})
+			if j, ok := ceilings[ref]; !ok || tighterLimit(f, ceiling, &ev.Constraints[j].Fence, ceilingOf(&ev.Constraints[j].Fence), true) {
+				ceilings[ref] = len(ev.Constraints) - 1
+			}
+		case FenceTypeAltitudeMinimum:
+			floor, ref := f.FloorAltitude()
+			if floor == 0 {
+				continue
+			}
+			ev.Constraints = append(ev.Constraints, Constraint{Fence: *f, Reason: ConstraintFloor})
+			if j, ok := floors[ref]; !ok || tighterLimit(f, floor, &ev.Constraints[j].Fence, floorOf(&ev.Constraints[j].Fence), false) {
+				floors[ref] = len(ev.Constraints) - 1
+			}
+		case FenceTypeSpeedLimit:
+			if f.MaxSpeed == 0 {
+				continue
+			}
+			ev.Constraints = append(ev.Constraints, Constraint{Fence: *f, Reason: ConstraintSpeedLimit})
+			if speed < 0 || tighterLimit(f, float64(f.MaxSpeed), &ev.Constraints[speed].Fence, float64(ev.Constraints[speed].Fence.MaxSpeed), true) {
+				speed = len(ev.Constraints) - 1
+			}
+		}
+	}
+
+	if ev.Restriction != nil {
+		ev.Allowed = false
+	}
+
+	for ref, i := range ceilings {
+		ev.Constraints[i].Binding = true
+		ev.Ceilings = append(ev.Ceilings, Altitude{Meters: ceilingOf(&ev.Constraints[i].Fence), Reference: ref})
+	}
+	for ref, i := range floors {
+		ev.Constraints[i].Binding = true
+		floor := floorOf(&ev.Constraints[i].Fence)
+		ev.Floors = append(ev.Floors, Altitude{Meters: floor, Reference: ref})
+
+		j, ok := ceilings[ref]
+		if !ok || floor <= ceilingOf(&ev.Constraints[j].Fence) {
+			continue
+		}
+		// No legal altitude between floor and ceiling
+		ev.Allowed = false
+		if ev.Restriction == nil {
+			r := ev.Constraints[i].Fence
+			if c := ev.Constraints[j].Fence; c.Priority > r.Priority {
+				r = c
+			}
+			ev.Restriction = &r
+		}
+	}
+
+	// Banded prohibitions that leave no altitude between floor and ceiling
+	var blocking []int
+	for ref, idx := range banded {
+		lo, hi := 0.0, math.Inf(1)
+		involved := append([]int(nil), idx...)
+		if i, ok := floors[ref]; ok {
+			lo = floorOf(&ev.Constraints[i].Fence)
+			involved = append(involved, i)
+		}
+		if j, ok := ceilings[ref]; ok {
+			hi = ceilingOf(&ev.Constraints[j].Fence)
+			involved = append(involved, j)
+		}
+		if lo > hi {
+			continue // Already forbidden by the limits alone
+		}
+		bands := make([]AltitudeBand, len(idx))
+		for k, i := range idx {
+			bands[k] = *ev.Constraints[i].Fence.Altitude
+		}
+		if bandsCover(bands, lo, hi) {
+			ev.Allowed = false
+			blocking = append(blocking, involved...)
+		}
+	}
+	if ev.Restriction == nil && len(blocking) > 0 {
+		r := ev.Constraints[blocking[0]].Fence
+		for _, i := range blocking[1:] {
+			if c := ev.Constraints[i].Fence; c.Priority > r.Priority {
+				r = c
+			}
+		}
+		ev.Restriction = &r
+	}
+
+	if speed >= 0 {
+		ev.Constraints[speed].Binding = true
+		ev.SpeedLimit = ev.Constraints[speed].Fence.MaxSpeed
+	}
+
+	sort.Slice(ev.Ceilings, func(i, j int) bool { return ev.Ceilings[i].Reference < ev.Ceilings[j].Reference })
+	sort.Slice(ev.Floors, func(i, j int) bool { return ev.Floors[i].Reference < ev.Floors[j].Reference })
+	sort.Slice(ev.Prohibited, func(i, j int) bool {
+		a, b := ev.Prohibited[i], ev.Prohibited[j]
+		if a.Reference != b.Reference {
+			return a.Reference < b.Reference
+		}
+		return a.Floor < b.Floor
+	})
+	sort.SliceStable(ev.Constraints, func(i, j int) bool {
+		return ev.Constraints[i].Fence.Priority > ev.Constraints[j].Fence.Priority
+	})
+
+	return ev
+}
+
+// tighterLimit reports whether the limit of fence f is more restrictive than
+// the current one; lower is tighter for ceilings and speed limits, higher
+// for floors. Equal limits are broken by priority.
+func tighterLimit(f *FenceItem, limit float64, current *FenceItem, currentLimit float64, lowerIsTighter bool) bool {
+	if limit == currentLimit {
+		return f.Priority > current.Priority
+	}
+	return (limit < currentLimit) == lowerIsTighter
+}
+
+// bandsCover reports whether altitude bands, in the same reference, leave
+// no altitude between lo and hi uncovered.
+func bandsCover(bands []AltitudeBand, lo, hi float64) bool {
+	sort.Slice(bands, func(i, j int) bool { return bands[i].Floor < bands[j].Floor })
+	reach := lo
+	for _, b := range bands {
+		top := b.Ceiling
+		if top == 0 {
+			top = math.Inf(1)
+		}
+		if top < lo || b.Floor > hi {
+			continue
+		}
+		if b.Floor > reach {
+			return false // Gap below this band
+		}
+		reach = math.Max(reach, top)
+		if reach >= hi {
+			return true
+		}
+	}
+	return false
+}
+
+func ceilingOf(f *FenceItem) float64 {
+	ceiling, _ := f.CeilingAltitude()
+	return ceiling
+}
+
+func floorOf(f *FenceItem) float64 {
+	floor, _ := f.FloorAltitude()
+	return floor
+}
+
+// Ceiling returns the effective ceiling in the given altitude reference.
+func (e *Evaluation) Ceiling(ref AltitudeReference) (float64, bool) {
+	for _, c := range e.Ceilings {
+		if c.Reference == ref {
+			return c.Meters, true
+		}
+	}
+	return 0, false
+}
+
+// Floor returns the effective floor in the given altitude reference.
+func (e *Evaluation) Floor(ref AltitudeReference) (float64, bool) {
+	for _, f := range e.Floors {
+		if f.Reference == ref {
+			return f.Meters, true
+		}
+	}
+	return 0, false
+}
+
+// AllowsAltitude checks if flying at an altitude is allowed by the
+// evaluation: below every ceiling, above every floor and outside every
+// prohibited band. Limits and bands in another reference than the altitude
+// cannot be compared with it and are assumed to be violated.
+func (e *Evaluation) AllowsAltitude(alt Altitude) bool {
+	if !e.Allowed {
+		return false
+	}
+	for _, c := range e.Ceilings {
+		if c.Reference != alt.Reference || alt.Meters > c.Meters {
+			return false
+		}
+	}
+	for _, f := range e.Floors {
+		if f.Reference != alt.Reference || alt.Meters < f.Meters {
+			return false
+		}
+	}
+	for i := range e.Prohibited {
+		if e.Prohibited[i].Contains(alt) {
+			return false
+		}
+	}
+	return true
+}
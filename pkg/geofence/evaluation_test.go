@@ -0,0 +1,208 @@
+package geofence
+
+import (
+	"testing"
+	"time"
+)
+
+func evaluationSquare() Geometry {
+	return Geometry{
+		Polygon: []Point{
+			{Latitude: 39.9, Longitude: 116.4},
+			{Latitude: 39.9, Longitude: 116.5},
+			{Latitude: 40.0, Longitude: 116.5},
+			{Latitude: 40.0, Longitude: 116.4},
+		},
+	}
+}
+
+func TestEvaluateFences(t *testing.T) {
+	inside := Point{Latitude: 39.95, Longitude: 116.45}
+
+	noFly := FenceItem{ID: "no-fly", Type: FenceTypeTempRestriction, Priority: 10, Geometry: evaluationSquare()}
+	ceiling120 := FenceItem{ID: "ceiling-120", Type: FenceTypeAltitudeLimit, Priority: 90, MaxAltitude: 120, Geometry: evaluationSquare()}
+	ceiling60 := FenceItem{ID: "ceiling-60", Type: FenceTypeAltitudeLimit, Priority: 20, MaxAltitude: 60, Geometry: evaluationSquare()}
+	ceilingMSL := FenceItem{
+		ID: "ceiling-msl", Type: FenceTypeAltitudeLimit, Priority: 30, Geometry: evaluationSquare(),
+		Altitude: &AltitudeBand{Ceiling: 500, Reference: AltitudeReferenceMSL},
+	}
+	floor30 := FenceItem{
+		ID: "floor-30", Type: FenceTypeAltitudeMinimum, Priority: 40, Geometry: evaluationSquare(),
+		Altitude: &AltitudeBand{Floor: 30},
+	}
+	floor80 := FenceItem{
+		ID: "floor-80", Type: FenceTypeAltitudeMinimum, Priority: 70, Geometry: evaluationSquare(),
+		Altitude: &AltitudeBand{Floor: 80},
+	}
+	speed15 := FenceItem{ID: "speed-15", Type: FenceTypeSpeedLimit, Priority: 50, MaxSpeed: 15, Geometry: evaluationSquare()}
+	speed10 := FenceItem{ID: "speed-10", Type: FenceTypeSpeedLimit, Priority: 5, MaxSpeed: 10, Geometry: evaluationSquare()}
+
+	t.Run("no fences", func(t *testing.T) {
+		ev := EvaluateFences(nil, inside)
+		if !ev.Allowed {
+			t.Error("should be allowed without fences")
+		}
+		if len(ev.Constraints) != 0 || len(ev.Ceilings) != 0 || ev.SpeedLimit != 0 {
+			t.Errorf("evaluation = %+v, want no constraints", ev)
+		}
+	})
+
+	t.Run("no-fly not masked by higher priority limit", func(t *testing.T) {
+		ev := EvaluateFences([]FenceItem{ceiling120, noFly, speed15}, inside)
+		if ev.Allowed {
+			t.Error("should not be allowed inside a no-fly zone")
+		}
+		if ev.Restriction == nil || ev.Restriction.ID != "no-fly" {
+			t.Errorf("Restriction = %v, want no-fly", ev.Restriction)
+		}
+		if len(ev.Constraints) != 3 {
+			t.Fatalf("constraints = %d, want 3", len(ev.Constraints))
+		}
+		// Highest priority first
+		want := []string{"ceiling-120", "speed-15", "no-fly"}
+		for i, c := range ev.Constraints {
+			if c.Fence.ID != want[i] {
+				t.Errorf("Constraints[%d] = %s, want %s", i, c.Fence.ID, want[i])
+			}
+		}
+	})
+
+	t.Run("most restrictive limits", func(t *testing.T) {
+		ev := EvaluateFences([]FenceItem{ceiling120, ceiling60, floor30, floor80, speed15, speed10, ceilingMSL}, inside)
+
+		if ev.Allowed {
+			t.Error("floor 80 above ceiling 60 should leave no legal altitude")
+		}
+		if ev.Restriction == nil || ev.Restriction.ID != "floor-80" {
+			t.Errorf("Restriction = %v, want floor-80", ev.Restriction)
+		}
+		if c, ok := ev.Ceiling(AltitudeReferenceAGL); !ok || c != 60 {
+			t.Errorf("AGL ceiling = %v, %v, want 60", c, ok)
+		}
+		if c, ok := ev.Ceiling(AltitudeReferenceMSL); !ok || c != 500 {
+			t.Errorf("MSL ceiling = %v, %v, want 500", c, ok)
+		}
+		if f, ok := ev.Floor(AltitudeReferenceAGL); !ok || f != 80 {
+			t.Errorf("AGL floor = %v, %v, want 80", f, ok)
+		}
+		if ev.SpeedLimit != 10 {
+			t.Errorf("SpeedLimit = %d, want 10", ev.SpeedLimit)
+		}
+
+		binding := map[string]bool{}
+		for _, c := range ev.Constraints {
+			binding[c.Fence.ID] = c.Binding
+		}
+		want := map[string]bool{
+			"ceiling-120": false, "ceiling-60": true, "ceiling-msl": true,
+			"floor-30": false, "floor-80": true, "speed-15": false, "speed-10": true,
+		}
+		for id, b := range want {
+			if binding[id] != b {
+				t.Errorf("%s Binding = %v, want %v", id, binding[id], b)
+			}
+		}
+	})
+
+	t.Run("compatible floor and ceiling", func(t *testing.T) {
+		ev := EvaluateFences([]FenceItem{ceiling120, floor30}, inside)
+		if !ev.Allowed {
+			t.Error("should be allowed between floor and ceiling")
+		}
+		if ev.Restriction != nil {
+			t.Errorf("Restriction = %v, want nil", ev.Restriction)
+		}
+		if !ev.AllowsAltitude(Altitude{Meters: 100}) {
+			t.Error("100 m AGL should be allowed")
+		}
+		if ev.AllowsAltitude(Altitude{Meters: 130}) {
+			t.Error("130 m AGL should be above the ceiling")
+		}
+		if ev.AllowsAltitude(Altitude{Meters: 20}) {
+			t.Error("20 m AGL should be below the floor")
+		}
+		if ev.AllowsAltitude(Altitude{Meters: 100, Reference: AltitudeReferenceMSL}) {
+			t.Error("MSL altitude cannot be compared with AGL limits")
+		}
+	})
+
+	t.Run("banded no-fly", func(t *testing.T) {
+		lowNoFly := FenceItem{
+			ID: "low-no-fly", Type: FenceTypePermanentNoFly, Priority: 60, Geometry: evaluationSquare(),
+			Altitude: &AltitudeBand{Ceiling: 120},
+		}
+		ev := EvaluateFences([]FenceItem{lowNoFly}, inside)
+		if !ev.Allowed || ev.Restriction != nil {
+			t.Errorf("Allowed = %v, Restriction = %v, want flight above the band allowed", ev.Allowed, ev.Restriction)
+		}
+		if len(ev.Prohibited) != 1 || ev.Prohibited[0] != *lowNoFly.Altitude {
+			t.Errorf("Prohibited = %v, want the band", ev.Prohibited)
+		}
+		if ev.AllowsAltitude(Altitude{Meters: 50}) {
+			t.Error("50 m AGL should be inside the no-fly band")
+		}
+		if !ev.AllowsAltitude(Altitude{Meters: 500}) {
+			t.Error("500 m AGL should be above the no-fly band")
+		}
+		if ev.AllowsAltitude(Altitude{Meters: 500, Reference: AltitudeReferenceMSL}) {
+			t.Error("MSL altitude cannot be compared with an AGL band")
+		}
+		if got := CheckFences3D([]FenceItem{lowNoFly}, inside, Altitude{Meters: 500}); !got.Allowed {
+			t.Error("CheckFences3D should agree that 500 m AGL is allowed")
+		}
+
+		// Under a ceiling of 120 m the band leaves no altitude
+		ev = EvaluateFences([]FenceItem{lowNoFly, ceiling120}, inside)
+		if ev.Allowed {
+			t.Error("the band up to the ceiling should leave no legal altitude")
+		}
+		if ev.Restriction == nil || ev.Restriction.ID != "ceiling-120" {
+			t.Errorf("Restriction = %v, want ceiling-120", ev.Restriction)
+		}
+
+		// Bands that together cover the column above the ground
+		highNoFly := FenceItem{
+			ID: "high-no-fly", Type: FenceTypeTempRestriction, Priority: 5, Geometry: evaluationSquare(),
+			Altitude: &AltitudeBand{Floor: 100},
+		}
+		ev = EvaluateFences([]FenceItem{lowNoFly, highNoFly}, inside)
+		if ev.Allowed || ev.Restriction == nil || ev.Restriction.ID != "low-no-fly" {
+			t.Errorf("Allowed = %v, Restriction = %v, want forbidden by low-no-fly", ev.Allowed, ev.Restriction)
+		}
+
+		// A band from the ground without a ceiling covers every altitude
+		groundUp := lowNoFly
+		groundUp.Altitude = &AltitudeBand{}
+		if ev := EvaluateFences([]FenceItem{groundUp}, inside); ev.Allowed || len(ev.Prohibited) != 0 {
+			t.Errorf("Allowed = %v, Prohibited = %v, want forbidden outright", ev.Allowed, ev.Prohibited)
+		}
+	})
+
+	t.Run("order independent", func(t *testing.T) {
+		a := EvaluateFences([]FenceItem{speed15, ceiling60, ceiling120, noFly}, inside)
+		b := EvaluateFences([]FenceItem{noFly, ceiling120, ceiling60, speed15}, inside)
+		if a.Allowed != b.Allowed || a.SpeedLimit != b.SpeedLimit || a.Restriction.ID != b.Restriction.ID {
+			t.Errorf("evaluations differ: %+v vs %+v", a, b)
+		}
+		ca, _ := a.Ceiling(AltitudeReferenceAGL)
+		cb, _ := b.Ceiling(AltitudeReferenceAGL)
+		if ca != cb {
+			t.Errorf("ceilings differ: %v vs %v", ca, cb)
+		}
+	})
+
+	t.Run("inactive and outside fences ignored", func(t *testing.T) {
+		expired := noFly
+		expired.EndTS = time.Now().Add(-time.Hour).Unix()
+
+		ev := EvaluateFences([]FenceItem{expired, ceiling60}, Point{Latitude: 0, Longitude: 0})
+		if !ev.Allowed || len(ev.Constraints) != 0 {
+			t.Errorf("evaluation = %+v, want allowed without constraints", ev)
+		}
+
+		ev = EvaluateFences([]FenceItem{expired}, inside)
+		if !ev.Allowed {
+			t.Error("expired no-fly zone should not forbid flight")
+		}
+	})
+}
@@ -2,6 +2,7 @@ package geofence
 
 import (
 	"math"
+	"time"
 )
 
 // ContainsPoint checks if the fence geometry contains a point.
@@ -10,7 +11,18 @@ func (g *Geometry) ContainsPoint(p Point) bool {
 		return b.Contains(p)
 	}
 	if len(g.Polygon) > 0 {
-		return pointInPolygon(p, g.Polygon)
+		return pointInPolygonWithHoles(p, g.Polygon, g.Holes, g.Edges)
+	}
+	if len(g.MultiPolygon) > 0 {
+		for _, part := range g.MultiPolygon {
+			if pointInPolygonWithHoles(p, part.Outer, part.Holes, g.Edges) {
+				return true
+			}
+		}
+		return false
+	}
+	if g.Corridor != nil {
+		return g.Corridor.Contains(p)
 	}
 	if g.CircleCenter != nil {
 		return pointInCircle(p, *g.CircleCenter, g.CircleRadius)
@@ -18,28 +30,40 @@ func (g *Geometry) ContainsPoint(p Point) bool {
 	return false
 }
 
-// pointInPolygon implements the ray-casting algorithm to check if a point
-// is inside a polygon.
-func pointInPolygon(p Point, polygon []Point) bool {
-	if len(polygon) < 3 {
+// pointInPolygonWithHoles checks if a point is inside the outer ring and
+// outside every interior ring.
+func pointInPolygonWithHoles(p Point, outer []Point, holes [][]Point, edges EdgeType) bool {
+	if !pointInPolygon(p, outer, edges) {
 		return false
 	}
+	for _, hole := range holes {
+		if pointInPolygon(p, hole, edges) {
+			return false
+		}
+	}
+	return true
+}
 
-	inside := false
-	j := len(polygon) - 1
+// pointInPolygon checks if a point is inside a ring, which may cross the
+// antimeridian or enclose a pole.
+func pointInPolygon(p Point, polygon []Point, edges EdgeType) bool {
+	if len(polygon) < 3 {
+		return false
+	}
+	if edges == EdgeTypeGreatCircle {
+		return pointInSphericalPolygon(p, polygon)
+	}
 
-	for i := 0; i < len(polygon); i++ {
-		vi := polygon[i]
-		vj := polygon[j]
+	ring, minLon, maxLon := planarRing(polygon)
 
-		if ((vi.Longitude > p.Longitude) != (vj.Longitude > p.Longitude)) &&
-			p.Latitude < (vj.Latitude-vi.Latitude)*(p.Longitude-vi.Longitude)/(vj.Longitude-vi.Longitude+1e-10)+vi.Latitude {
-			inside = !inside
+	// Try every longitude equivalent to the point's within the ring's range
+	lon := p.Longitude + 360*math.Ceil((minLon-p.Longitude)/360)
+	for ; lon <= maxLon; lon += 360 {
+		if pointInRhumbPolygon(Point{Latitude: p.Latitude, Longitude: lon}, ring) {
+			return true
 		}
-		j = i
 	}
-
-	return inside
+	return false
 }
 
 // pointInCircle checks if a point is within a circle using Haversine distance.
@@ -77,10 +101,15 @@ func degToRad(deg float64) float64 {
 // RestrictionLevel returns the restriction severity for a fence at a location.
 // Returns 0 if no restriction, higher values indicate more severe restrictions.
 func (f *FenceItem) RestrictionLevel(at Point) int32 {
+	return f.RestrictionLevelAt(at, time.Now())
+}
+
+// RestrictionLevelAt is like RestrictionLevel but evaluated at time t.
+func (f *FenceItem) RestrictionLevelAt(at Point, t time.Time) int32 {
 	if !f.ContainsPoint(at) {
 		return 0
 	}
-	if !f.IsActiveNow() {
+	if !f.IsActiveAt(t) {
 		return 0
 	}
 
@@ -109,10 +138,15 @@ func (f *FenceItem) ContainsPoint(p Point) bool {
 // GetAltitudeLimit returns the maximum allowed altitude at a point.
 // Returns 0 if no limit, -1 if completely forbidden.
 func (f *FenceItem) GetAltitudeLimit(at Point) int32 {
+	return f.GetAltitudeLimitAt(at, time.Now())
+}
+
+// GetAltitudeLimitAt is like GetAltitudeLimit but evaluated at time t.
+func (f *FenceItem) GetAltitudeLimitAt(at Point, t time.Time) int32 {
 	if !f.ContainsPoint(at) {
 		return 0
 	}
-	if !f.IsActiveNow() {
+	if !f.IsActiveAt(t) {
 		return 0
 	}
 
@@ -130,10 +164,15 @@ func (f *FenceItem) GetAltitudeLimit(at Point) int32 {
 // GetSpeedLimit returns the maximum allowed speed at a point in m/s.
 // Returns 0 if no limit.
 func (f *FenceItem) GetSpeedLimit(at Point) int32 {
+	return f.GetSpeedLimitAt(at, time.Now())
+}
+
+// GetSpeedLimitAt is like GetSpeedLimit but evaluated at time t.
+func (f *FenceItem) GetSpeedLimitAt(at Point, t time.Time) int32 {
 	if !f.ContainsPoint(at) {
 		return 0
 	}
-	if !f.IsActiveNow() {
+	if !f.IsActiveAt(t) {
 		return 0
 	}
 
@@ -145,33 +184,33 @@ func (f *FenceItem) GetSpeedLimit(at Point) int32 {
 }
 
 // CheckFences checks multiple fences and returns the most restrictive result.
+// The location is not allowed if any no-fly fence applies, whatever the
+// priority of other fences; Restriction is the highest-priority one. Use
+// EvaluateFences to also combine altitude and speed limits.
 func CheckFences(fences []FenceItem, p Point) CheckResult {
-	var highestPriority *FenceItem
+	return CheckFencesAt(fences, p, time.Now())
+}
+
+// CheckFencesAt is like CheckFences but evaluates fences as of time t, e.g.
+// to plan a mission ahead of time or replay a past flight.
+func CheckFencesAt(fences []FenceItem, p Point, t time.Time) CheckResult {
+	var restriction *FenceItem
 	var matchingFences []FenceItem
 
 	for i := range fences {
 		f := &fences[i]
-		if f.ContainsPoint(p) && f.IsActiveNow() {
+		if f.ContainsPoint(p) && f.IsActiveAt(t) {
 			matchingFences = append(matchingFences, *f)
 
-			if highestPriority == nil || f.Priority > highestPriority.Priority {
-				highestPriority = f
+			if f.IsRestrictive() && (restriction == nil || f.Priority > restriction.Priority) {
+				restriction = f
 			}
 		}
 	}
 
-	result := CheckResult{
-		Allowed:       true,
+	return CheckResult{
+		Allowed:        restriction == nil,
+		Restriction:    restriction,
 		MatchingFences: matchingFences,
 	}
-
-	if highestPriority != nil {
-		if highestPriority.Type == FenceTypePermanentNoFly ||
-			highestPriority.Type == FenceTypeTempRestriction {
-			result.Allowed = false
-			result.Restriction = highestPriority
-		}
-	}
-
-	return result
 }
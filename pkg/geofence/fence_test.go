@@ -53,6 +53,8 @@ func TestNewPoint(t *testing.T) {
 		{"invalid latitude too low", -91, 0, true},
 		{"invalid longitude too high", 0, 181, true},
 		{"invalid longitude too low", 0, -181, true},
+		{"NaN latitude", math.NaN(), 0, true},
+		{"infinite longitude", 0, math.Inf(1), true},
 	}
 
 	for _, tt := range tests {
@@ -208,6 +210,101 @@ func TestGeometry_ContainsPoint_Polygon(t *testing.T) {
 	})
 }
 
+func TestGeometry_ContainsPoint_PolygonWithHole(t *testing.T) {
+	// Square ring with a smaller square carved out of the middle
+	donut := Geometry{
+		Polygon: []Point{
+			{Latitude: 39.0, Longitude: 116.0},
+			{Latitude: 39.0, Longitude: 117.0},
+			{Latitude: 40.0, Longitude: 117.0},
+			{Latitude: 40.0, Longitude: 116.0},
+		},
+		Holes: [][]Point{
+			{
+				{Latitude: 39.4, Longitude: 116.4},
+				{Latitude: 39.4, Longitude: 116.6},
+				{Latitude: 39.6, Longitude: 116.6},
+				{Latitude: 39.6, Longitude: 116.4},
+			},
+		},
+	}
+
+	tests := []struct {
+		name  string
+		point Point
+		want  bool
+	}{
+		{"inside ring", Point{Latitude: 39.2, Longitude: 116.2}, true},
+		{"inside hole", Point{Latitude: 39.5, Longitude: 116.5}, false},
+		{"outside outer ring", Point{Latitude: 40.5, Longitude: 116.5}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := donut.ContainsPoint(tt.point); got != tt.want {
+				t.Errorf("ContainsPoint() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeometry_ContainsPoint_MultiPolygon(t *testing.T) {
+	multi := Geometry{
+		MultiPolygon: []PolygonPart{
+			{
+				Outer: []Point{
+					{Latitude: 0, Longitude: 0},
+					{Latitude: 0, Longitude: 1},
+					{Latitude: 1, Longitude: 1},
+					{Latitude: 1, Longitude: 0},
+				},
+			},
+			{
+				Outer: []Point{
+					{Latitude: 10, Longitude: 10},
+					{Latitude: 10, Longitude: 12},
+					{Latitude: 12, Longitude: 12},
+					{Latitude: 12, Longitude: 10},
+				},
+				Holes: [][]Point{
+					{
+						{Latitude: 10.5, Longitude: 10.5},
+						{Latitude: 10.5, Longitude: 11.5},
+						{Latitude: 11.5, Longitude: 11.5},
+						{Latitude: 11.5, Longitude: 10.5},
+					},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name  string
+		point Point
+		want  bool
+	}{
+		{"first part", Point{Latitude: 0.5, Longitude: 0.5}, true},
+		{"second part", Point{Latitude: 10.2, Longitude: 10.2}, true},
+		{"hole of second part", Point{Latitude: 11, Longitude: 11}, false},
+		{"between parts", Point{Latitude: 5, Longitude: 5}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := multi.ContainsPoint(tt.point); got != tt.want {
+				t.Errorf("ContainsPoint() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	fence := FenceItem{ID: "multi", Geometry: multi}
+	bounds := fence.GetBounds()
+	want := BoundingBox{MinLat: 0, MinLon: 0, MaxLat: 12, MaxLon: 12}
+	if bounds != want {
+		t.Errorf("GetBounds() = %+v, want %+v", bounds, want)
+	}
+}
+
 func TestGeometry_ContainsPoint_Circle(t *testing.T) {
 	center := Point{Latitude: 39.9042, Longitude: 116.4074}
 	circle := Geometry{
@@ -371,6 +468,39 @@ func TestCheckFences(t *testing.T) {
 			t.Errorf("matching fences = %d, want 0", len(result.MatchingFences))
 		}
 	})
+
+	t.Run("no-fly not masked by higher priority limit", func(t *testing.T) {
+		limit := FenceItem{
+			ID:          "altitude-limit",
+			Type:        FenceTypeAltitudeLimit,
+			Priority:    200,
+			MaxAltitude: 120,
+			Geometry:    fences[0].Geometry,
+		}
+		p := Point{Latitude: 39.95, Longitude: 116.45}
+		result := CheckFences(append([]FenceItem{limit}, fences...), p)
+
+		if result.Allowed {
+			t.Error("should not be allowed in no-fly zone")
+		}
+		if result.Restriction == nil || result.Restriction.ID != "perm-no-fly" {
+			t.Errorf("restriction = %v, want perm-no-fly", result.Restriction)
+		}
+	})
+
+	t.Run("temp zone at other times", func(t *testing.T) {
+		p := Point{Latitude: 39.85, Longitude: 116.35}
+
+		if result := CheckFencesAt(fences, p, now.Add(-2*time.Hour)); !result.Allowed {
+			t.Error("should be allowed before the temp restriction starts")
+		}
+		if result := CheckFencesAt(fences, p, now.Add(2*time.Hour)); !result.Allowed {
+			t.Error("should be allowed after the temp restriction ends")
+		}
+		if result := CheckFencesAt(fences, p, now); result.Allowed {
+			t.Error("should not be allowed while the temp restriction is active")
+		}
+	})
 }
 
 func TestFenceItem_GetAltitudeLimit(t *testing.T) {
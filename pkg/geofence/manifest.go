@@ -1,17 +1,24 @@
 package geofence
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"slices"
+	"strings"
 )
 
 // MarshalBinary serializes the manifest to bytes for signing.
-// This excludes the Signature and KeyID fields since they are signature metadata.
+// This excludes the Signature, KeyID and Signatures fields since they are
+// signature metadata.
+// This is the FormatVersionJSON encoding; converter.ManifestSigningData
+// selects the encoding by the manifest's FormatVersion.
 func (m *Manifest) MarshalBinaryForSigning() ([]byte, error) {
 	copy := *m
 	copy.Signature = nil
 	copy.KeyID = "" // Also exclude KeyID as it's part of signature metadata
+	copy.Signatures = nil
 
 	data, err := json.Marshal(copy)
 	if err != nil {
@@ -26,6 +33,28 @@ func (m *Manifest) SetSignature(sig []byte, keyID string) {
 	m.KeyID = keyID
 }
 
+// AddSignature adds a co-signature by another key holder, replacing an
+// earlier one by the same key.
+func (m *Manifest) AddSignature(sig []byte, keyID string) {
+	for i := range m.Signatures {
+		if m.Signatures[i].KeyID == keyID {
+			m.Signatures[i].Signature = sig
+			return
+		}
+	}
+	m.Signatures = append(m.Signatures, ManifestSignature{Signature: sig, KeyID: keyID})
+}
+
+// AllSignatures returns the manifest's signature, if any, followed by its
+// co-signatures.
+func (m *Manifest) AllSignatures() []ManifestSignature {
+	sigs := make([]ManifestSignature, 0, 1+len(m.Signatures))
+	if len(m.Signature) > 0 {
+		sigs = append(sigs, ManifestSignature{Signature: m.Signature, KeyID: m.KeyID})
+	}
+	return append(sigs, m.Signatures...)
+}
+
 // VerifySignature verifies the manifest's signature.
 // This is a placeholder - actual verification is done by the crypto package.
 func (m *Manifest) VerifySignature(publicKey []byte) bool {
@@ -35,6 +64,9 @@ func (m *Manifest) VerifySignature(publicKey []byte) bool {
 
 // ComputeRootHash computes a hash from a collection of fences.
 // This is a simplified version - full Merkle tree implementation comes later.
+//
+// Deprecated: Manifests carry the root of merkle.NewTree, which hashes the
+// canonical encoding of each fence.
 func ComputeRootHash(fences []FenceItem) ([]byte, error) {
 	if len(fences) == 0 {
 		return []byte{}, nil
@@ -51,31 +83,15 @@ func ComputeRootHash(fences []FenceItem) ([]byte, error) {
 	return h.Sum(nil), nil
 }
 
-// hashFenceItem creates a hash of a fence item for the Merkle tree.
+// hashFenceItem creates a hash of a fence item from its signing data, so
+// that every field is covered at full precision.
 func hashFenceItem(f FenceItem) ([]byte, error) {
-	h := sha256.New()
-
-	// Hash all fields except signature
-	fmt.Fprintf(h, "%s|%d|%d|%d", f.ID, f.Type, f.StartTS, f.EndTS)
-	fmt.Fprintf(h, "|%d|%d|%d|%s|%s",
-		f.Priority, f.MaxAltitude, f.MaxSpeed, f.Name, f.Description)
-
-	// Hash geometry
-	if len(f.Geometry.Polygon) > 0 {
-		for _, p := range f.Geometry.Polygon {
-			fmt.Fprintf(h, "|p%f,%f", p.Latitude, p.Longitude)
-		}
-	}
-	if f.Geometry.CircleCenter != nil {
-		fmt.Fprintf(h, "|c%f,%f,%f", f.Geometry.CircleCenter.Latitude,
-			f.Geometry.CircleCenter.Longitude, f.Geometry.CircleRadius)
-	}
-	if f.Geometry.BBox != nil {
-		fmt.Fprintf(h, "|b%f,%f,%f,%f", f.Geometry.BBox.MinLat,
-			f.Geometry.BBox.MinLon, f.Geometry.BBox.MaxLat, f.Geometry.BBox.MaxLon)
+	data, err := f.MarshalBinaryForSigning()
+	if err != nil {
+		return nil, err
 	}
-
-	return h.Sum(nil), nil
+	h := sha256.Sum256(data)
+	return h[:], nil
 }
 
 // ApplyDelta applies a delta to a collection of fences.
@@ -116,7 +132,8 @@ func ApplyDelta(existing []FenceItem, delta FenceDelta) ([]FenceItem, error) {
 	return result, nil
 }
 
-// CreateDelta computes the delta between two fence collections.
+// CreateDelta computes the delta between two fence collections. Fences are
+// listed in ID order, so the same collections give the same delta.
 func CreateDelta(oldFences, newFences []FenceItem) FenceDelta {
 	oldMap := make(map[string]FenceItem)
 	newMap := make(map[string]FenceItem)
@@ -150,10 +167,24 @@ func CreateDelta(oldFences, newFences []FenceItem) FenceDelta {
 		}
 	}
 
+	byID := func(a, b FenceItem) int { return strings.Compare(a.ID, b.ID) }
+	slices.SortFunc(delta.Added, byID)
+	slices.SortFunc(delta.Updated, byID)
+	slices.Sort(delta.RemovedIDs)
 	return delta
 }
 
+// fencesEqual reports whether two fences are the same, including their
+// signatures, so that a re-signed fence is part of the delta.
 func fencesEqual(a, b FenceItem) bool {
+	if !bytes.Equal(a.Signature, b.Signature) || a.KeyID != b.KeyID {
+		return false
+	}
+	aGeom, errA := json.Marshal(a.Geometry)
+	bGeom, errB := json.Marshal(b.Geometry)
+	if errA != nil || errB != nil || !bytes.Equal(aGeom, bGeom) {
+		return false
+	}
 	return a.ID == b.ID &&
 		a.Type == b.Type &&
 		a.StartTS == b.StartTS &&
@@ -162,5 +193,15 @@ func fencesEqual(a, b FenceItem) bool {
 		a.MaxAltitude == b.MaxAltitude &&
 		a.MaxSpeed == b.MaxSpeed &&
 		a.Name == b.Name &&
-		a.Description == b.Description
+		a.Description == b.Description &&
+		altitudeBandsEqual(a.Altitude, b.Altitude) &&
+		a.Schedule.Equal(b.Schedule) &&
+		a.Conditions.Equal(b.Conditions)
+}
+
+func altitudeBandsEqual(a, b *AltitudeBand) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
 }
@@ -139,6 +139,36 @@ func TestManifest_SetSignature(t *testing.T) {
 	}
 }
 
+func TestManifest_AddSignature(t *testing.T) {
+	manifest := sampleManifest()
+	before, err := manifest.MarshalBinaryForSigning()
+	if err != nil {
+		t.Fatalf("MarshalBinaryForSigning failed: %v", err)
+	}
+
+	manifest.SetSignature([]byte{1}, "publisher")
+	manifest.AddSignature([]byte{2}, "authority-a")
+	manifest.AddSignature([]byte{3}, "authority-b")
+	manifest.AddSignature([]byte{4}, "authority-a")
+
+	if len(manifest.Signatures) != 2 || manifest.Signatures[0].Signature[0] != 4 {
+		t.Errorf("Signatures = %+v, want authority-a replaced in place", manifest.Signatures)
+	}
+
+	all := manifest.AllSignatures()
+	if len(all) != 3 || all[0].KeyID != "publisher" {
+		t.Errorf("AllSignatures() = %+v, want the signature followed by both co-signatures", all)
+	}
+
+	after, err := manifest.MarshalBinaryForSigning()
+	if err != nil {
+		t.Fatalf("MarshalBinaryForSigning failed: %v", err)
+	}
+	if string(before) != string(after) {
+		t.Error("signing data should not include signatures")
+	}
+}
+
 func TestComputeRootHash(t *testing.T) {
 	t.Run("empty fences", func(t *testing.T) {
 		hash, err := ComputeRootHash([]FenceItem{})
@@ -178,6 +208,107 @@ func TestComputeRootHash(t *testing.T) {
 			t.Error("same fences should produce same hash")
 		}
 	})
+
+	t.Run("sub-microdegree change changes hash", func(t *testing.T) {
+		fence := temporaryFence()
+		before, err := ComputeRootHash([]FenceItem{fence})
+		if err != nil {
+			t.Fatalf("ComputeRootHash failed: %v", err)
+		}
+
+		fence.Geometry.Polygon[0].Latitude += 1e-9
+		after, err := ComputeRootHash([]FenceItem{fence})
+		if err != nil {
+			t.Fatalf("ComputeRootHash failed: %v", err)
+		}
+
+		if string(before) == string(after) {
+			t.Error("moving a vertex by 1e-9 degrees should change the hash")
+		}
+	})
+
+	t.Run("holes change hash", func(t *testing.T) {
+		fence := temporaryFence()
+		withoutHole, err := ComputeRootHash([]FenceItem{fence})
+		if err != nil {
+			t.Fatalf("ComputeRootHash failed: %v", err)
+		}
+
+		fence.Geometry.Holes = [][]Point{{
+			{Latitude: 39.906, Longitude: 116.409},
+			{Latitude: 39.908, Longitude: 116.409},
+			{Latitude: 39.908, Longitude: 116.411},
+		}}
+		withHole, err := ComputeRootHash([]FenceItem{fence})
+		if err != nil {
+			t.Fatalf("ComputeRootHash failed: %v", err)
+		}
+
+		if string(withoutHole) == string(withHole) {
+			t.Error("adding a hole should change the hash")
+		}
+	})
+
+	t.Run("edge type changes hash", func(t *testing.T) {
+		fence := temporaryFence()
+		rhumb, err := ComputeRootHash([]FenceItem{fence})
+		if err != nil {
+			t.Fatalf("ComputeRootHash failed: %v", err)
+		}
+
+		fence.Geometry.Edges = EdgeTypeGreatCircle
+		greatCircle, err := ComputeRootHash([]FenceItem{fence})
+		if err != nil {
+			t.Fatalf("ComputeRootHash failed: %v", err)
+		}
+
+		if string(rhumb) == string(greatCircle) {
+			t.Error("changing the edge type should change the hash")
+		}
+	})
+
+	t.Run("schedule changes hash", func(t *testing.T) {
+		fence := temporaryFence()
+		fence.Schedule = &Schedule{Rules: []WeeklyRule{{Days: []time.Weekday{time.Saturday}, StartMinute: 840, EndMinute: 1080}}}
+		saturday, err := ComputeRootHash([]FenceItem{fence})
+		if err != nil {
+			t.Fatalf("ComputeRootHash failed: %v", err)
+		}
+
+		fence.Schedule.Rules[0].Days = []time.Weekday{time.Sunday}
+		sunday, err := ComputeRootHash([]FenceItem{fence})
+		if err != nil {
+			t.Fatalf("ComputeRootHash failed: %v", err)
+		}
+
+		if string(saturday) == string(sunday) {
+			t.Error("changing the scheduled day should change the hash")
+		}
+	})
+
+	t.Run("conditions change hash", func(t *testing.T) {
+		fence := temporaryFence()
+		unconditional, err := ComputeRootHash([]FenceItem{fence})
+		if err != nil {
+			t.Fatalf("ComputeRootHash failed: %v", err)
+		}
+
+		fence.Conditions = &Conditions{ExemptionTokens: []string{"a", "b"}}
+		twoTokens, err := ComputeRootHash([]FenceItem{fence})
+		if err != nil {
+			t.Fatalf("ComputeRootHash failed: %v", err)
+		}
+
+		fence.Conditions.ExemptionTokens = []string{"a|b"}
+		oneToken, err := ComputeRootHash([]FenceItem{fence})
+		if err != nil {
+			t.Fatalf("ComputeRootHash failed: %v", err)
+		}
+
+		if string(unconditional) == string(twoTokens) || string(twoTokens) == string(oneToken) {
+			t.Error("changing the conditions should change the hash")
+		}
+	})
 }
 
 func TestApplyDelta(t *testing.T) {
@@ -298,6 +429,24 @@ func TestCreateDelta(t *testing.T) {
 	}
 }
 
+func TestCreateDelta_SignaturesAndOrder(t *testing.T) {
+	a, b, c := permanentNoFlyZone(), temporaryFence(), altitudeLimitFence()
+	a.ID, b.ID, c.ID = "a", "b", "c"
+	oldFences := []FenceItem{a}
+
+	// A re-signed fence is updated even though its content is the same
+	resigned := a
+	resigned.SetSignature([]byte("new signature"), "new key")
+	delta := CreateDelta(oldFences, []FenceItem{c, resigned, b})
+
+	if len(delta.Updated) != 1 || delta.Updated[0].KeyID != "new key" {
+		t.Errorf("updated = %+v, want the re-signed fence", delta.Updated)
+	}
+	if len(delta.Added) != 2 || delta.Added[0].ID != "b" || delta.Added[1].ID != "c" {
+		t.Errorf("added = %+v, want b and c in ID order", delta.Added)
+	}
+}
+
 func TestUpdaterConfig_Validate(t *testing.T) {
 	t.Run("valid config", func(t *testing.T) {
 		cfg := &UpdaterConfig{
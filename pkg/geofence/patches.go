@@ -0,0 +1,141 @@
+package geofence
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// PatchIndexFile is the name of the patch index publishers write next to
+// the manifest.
+const PatchIndexFile = "patches.json"
+
+// PatchIndexSigningPrefix starts the signing data of every patch index,
+// separating its signatures from others made with the same key.
+const PatchIndexSigningPrefix = "GUL-PATCHINDEX-V1\n"
+
+// PatchInfo describes a delta from one version to a later one.
+type PatchInfo struct {
+	FromVersion uint64 `json:"from_version"`
+	ToVersion   uint64 `json:"to_version"`
+	URL         string `json:"url"`
+	Size        uint64 `json:"size"`
+	Hash        []byte `json:"hash"` // SHA-256 of the delta file
+	// RootHash is the Merkle root of the fences at ToVersion, computed in
+	// FormatVersion, to verify the result of applying the delta
+	RootHash      []byte `json:"root_hash"`
+	FormatVersion uint32 `json:"format_version,omitempty"`
+	// Compressed copies of the delta file
+	Encodings []FileEncoding `json:"encodings,omitempty"`
+	// Binary is a binary diff between the fences of the two versions, an
+	// alternative to the delta file that is smaller when fences change
+	// only slightly
+	Binary *BinaryPatch `json:"binary,omitempty"`
+}
+
+// BinaryPatch describes a binary diff file, see package binarydiff. Unlike
+// a delta file it applies only to the complete fences of FromVersion, and
+// is not signed: the result is verified against RootHash.
+type BinaryPatch struct {
+	URL       string         `json:"url"`
+	Size      uint64         `json:"size"`
+	Hash      []byte         `json:"hash"` // SHA-256 of the diff file
+	Encodings []FileEncoding `json:"encodings,omitempty"`
+}
+
+// PatchIndex lists the deltas a publisher keeps available, so that clients
+// several versions behind can catch up through a chain of deltas instead of
+// a full snapshot. It is signed by the publisher.
+type PatchIndex struct {
+	Version   uint64      `json:"version"` // Latest version, matching the manifest
+	Timestamp int64       `json:"timestamp"`
+	Patches   []PatchInfo `json:"patches"`
+	Signature []byte      `json:"signature"`
+	KeyID     string      `json:"key_id"`
+}
+
+// MarshalBinaryForSigning returns the bytes the index's signature is
+// computed over: PatchIndexSigningPrefix followed by the index as JSON,
+// without the Signature and KeyID fields.
+func (idx *PatchIndex) MarshalBinaryForSigning() ([]byte, error) {
+	copy := *idx
+	copy.Signature = nil
+	copy.KeyID = ""
+
+	data, err := json.Marshal(copy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal patch index: %w", err)
+	}
+	return append([]byte(PatchIndexSigningPrefix), data...), nil
+}
+
+// SetSignature sets the signature on the index.
+func (idx *PatchIndex) SetSignature(sig []byte, keyID string) {
+	idx.Signature = sig
+	idx.KeyID = keyID
+}
+
+// CheapestChain returns the chain of patches from version from to version
+// to with the fewest bytes to download, and its total size. It returns nil
+// if the patches do not connect the two versions.
+func CheapestChain(patches []PatchInfo, from, to uint64) ([]PatchInfo, uint64) {
+	if from >= to {
+		return nil, 0
+	}
+
+	// Patches only go forward, so visiting them by starting version
+	// settles the cost of each version before it is extended
+	sorted := make([]PatchInfo, 0, len(patches))
+	for _, p := range patches {
+		if p.FromVersion >= from && p.ToVersion <= to && p.FromVersion < p.ToVersion {
+			sorted = append(sorted, p)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].FromVersion < sorted[j].FromVersion })
+
+	cost := map[uint64]uint64{from: 0}
+	via := make(map[uint64]int) // Index in sorted of the patch reaching a version
+	for i, p := range sorted {
+		base, ok := cost[p.FromVersion]
+		if !ok {
+			continue
+		}
+		if c, seen := cost[p.ToVersion]; !seen || base+p.Size < c {
+			cost[p.ToVersion] = base + p.Size
+			via[p.ToVersion] = i
+		}
+	}
+
+	total, ok := cost[to]
+	if !ok {
+		return nil, 0
+	}
+	var chain []PatchInfo
+	for v := to; v != from; {
+		p := sorted[via[v]]
+		chain = append([]PatchInfo{p}, chain...)
+		v = p.FromVersion
+	}
+	return chain, total
+}
+
+// FenceDeltaFile is a signed, fence-level delta from one version to a later
+// one. Its fences are applied one by one, so a client updates only the
+// fences that changed. The root hashes tie it to the fences it applies to
+// and the fences it produces.
+type FenceDeltaFile struct {
+	FromVersion   uint64
+	ToVersion     uint64
+	FromRootHash  []byte
+	ToRootHash    []byte
+	FormatVersion uint32 // Of the root hashes and fence signatures
+	Delta         FenceDelta
+	Signature     []byte
+	KeyID         string
+}
+
+// SetSignature sets the signature on the delta.
+func (d *FenceDeltaFile) SetSignature(sig []byte, keyID string) {
+	d.Signature = sig
+	d.KeyID = keyID
+}
@@ -0,0 +1,83 @@
+package geofence
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCheapestChain(t *testing.T) {
+	patch := func(from, to, size uint64) PatchInfo {
+		return PatchInfo{FromVersion: from, ToVersion: to, Size: size}
+	}
+	patches := []PatchInfo{
+		patch(3, 4, 10),
+		patch(1, 2, 10),
+		patch(2, 3, 10),
+		patch(1, 3, 15), // Skip delta, cheaper than 1->2->3
+		patch(2, 4, 50), // Skip delta, dearer than 2->3->4
+	}
+
+	tests := []struct {
+		name     string
+		from, to uint64
+		want     [][2]uint64
+		wantSize uint64
+	}{
+		{"single hop", 3, 4, [][2]uint64{{3, 4}}, 10},
+		{"skip delta", 1, 3, [][2]uint64{{1, 3}}, 15},
+		{"skip then sequential", 1, 4, [][2]uint64{{1, 3}, {3, 4}}, 25},
+		{"sequential over skip", 2, 4, [][2]uint64{{2, 3}, {3, 4}}, 20},
+		{"not connected", 0, 4, nil, 0},
+		{"up to date", 4, 4, nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain, size := CheapestChain(patches, tt.from, tt.to)
+			var got [][2]uint64
+			for _, p := range chain {
+				got = append(got, [2]uint64{p.FromVersion, p.ToVersion})
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("chain = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("chain = %v, want %v", got, tt.want)
+				}
+			}
+			if size != tt.wantSize {
+				t.Errorf("size = %d, want %d", size, tt.wantSize)
+			}
+		})
+	}
+}
+
+func TestPatchIndex_MarshalBinaryForSigning(t *testing.T) {
+	idx := &PatchIndex{Version: 2, Patches: []PatchInfo{{FromVersion: 1, ToVersion: 2, URL: "/patches/v1_to_v2.bin"}}}
+	unsigned, err := idx.MarshalBinaryForSigning()
+	if err != nil {
+		t.Fatalf("MarshalBinaryForSigning failed: %v", err)
+	}
+	if !bytes.HasPrefix(unsigned, []byte(PatchIndexSigningPrefix)) {
+		t.Errorf("signing data does not start with %q", PatchIndexSigningPrefix)
+	}
+
+	idx.SetSignature([]byte("signature"), "key")
+	signed, err := idx.MarshalBinaryForSigning()
+	if err != nil {
+		t.Fatalf("MarshalBinaryForSigning failed: %v", err)
+	}
+	if !bytes.Equal(signed, unsigned) {
+		t.Error("signing data depends on the signature")
+	}
+
+	idx.Patches[0].URL = "/patches/other.bin"
+	changed, err := idx.MarshalBinaryForSigning()
+	if err != nil {
+		t.Fatalf("MarshalBinaryForSigning failed: %v", err)
+	}
+	if bytes.Equal(changed, unsigned) {
+		t.Error("signing data does not cover the patches")
+	}
+}
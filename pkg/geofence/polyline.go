@@ -0,0 +1,108 @@
+package geofence
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// PolylinePrecision is the number of decimal digits kept by EncodePolyline.
+// Seven digits resolve about one centimeter, so encoding is lossless for
+// coordinates entered with typical survey precision.
+const PolylinePrecision = 7
+
+var polylineFactor = math.Pow10(PolylinePrecision)
+
+// EncodePolyline encodes a sequence of points using the Polyline Algorithm:
+// each coordinate is stored as a variable-length delta from the previous
+// point, which keeps long paths with closely spaced vertices small.
+func EncodePolyline(points []Point) string {
+	var sb strings.Builder
+	var prevLat, prevLon int64
+	for _, p := range points {
+		lat := int64(math.Round(p.Latitude * polylineFactor))
+		lon := int64(math.Round(p.Longitude * polylineFactor))
+		writePolylineValue(&sb, lat-prevLat)
+		writePolylineValue(&sb, lon-prevLon)
+		prevLat, prevLon = lat, lon
+	}
+	return sb.String()
+}
+
+// DecodePolyline decodes a string produced by EncodePolyline.
+func DecodePolyline(s string) ([]Point, error) {
+	var points []Point
+	var lat, lon int64
+	for i := 0; i < len(s); {
+		dLat, n, err := readPolylineValue(s[i:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid latitude at offset %d: %w", i, err)
+		}
+		i += n
+		dLon, n, err := readPolylineValue(s[i:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid longitude at offset %d: %w", i, err)
+		}
+		i += n
+		lat += dLat
+		lon += dLon
+		points = append(points, Point{
+			Latitude:  float64(lat) / polylineFactor,
+			Longitude: float64(lon) / polylineFactor,
+		})
+	}
+	return points, nil
+}
+
+// QuantizePolyline rounds points to the precision kept by EncodePolyline, so
+// that a path compares equal to itself after an encode/decode round trip.
+func QuantizePolyline(points []Point) []Point {
+	if points == nil {
+		return nil
+	}
+	out := make([]Point, len(points))
+	for i, p := range points {
+		out[i] = Point{
+			Latitude:  math.Round(p.Latitude*polylineFactor) / polylineFactor,
+			Longitude: math.Round(p.Longitude*polylineFactor) / polylineFactor,
+		}
+	}
+	return out
+}
+
+func writePolylineValue(sb *strings.Builder, v int64) {
+	u := uint64(v) << 1
+	if v < 0 {
+		u = ^u
+	}
+	for u >= 0x20 {
+		sb.WriteByte(byte(0x20|(u&0x1f)) + 63)
+		u >>= 5
+	}
+	sb.WriteByte(byte(u) + 63)
+}
+
+func readPolylineValue(s string) (int64, int, error) {
+	var u uint64
+	var shift uint
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 63 || c > 63+0x3f {
+			return 0, 0, fmt.Errorf("unexpected character %q", c)
+		}
+		if shift > 60 {
+			return 0, 0, fmt.Errorf("value overflow")
+		}
+		b := uint64(c - 63)
+		u |= (b & 0x1f) << shift
+		shift += 5
+		if b < 0x20 {
+			v := int64(u >> 1)
+			if u&1 != 0 {
+				v = ^v
+			}
+			return v, i + 1, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("truncated value")
+}
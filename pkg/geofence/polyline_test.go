@@ -0,0 +1,101 @@
+package geofence
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWritePolylineValue(t *testing.T) {
+	// Reference value from the Polyline Algorithm documentation:
+	// -179.9832104 at five digits of precision
+	var sb strings.Builder
+	writePolylineValue(&sb, -17998321)
+	if got := sb.String(); got != "`~oia@" {
+		t.Errorf("writePolylineValue() = %q, want %q", got, "`~oia@")
+	}
+
+	v, n, err := readPolylineValue("`~oia@")
+	if err != nil {
+		t.Fatalf("readPolylineValue failed: %v", err)
+	}
+	if v != -17998321 || n != 6 {
+		t.Errorf("readPolylineValue() = %d, %d, want -17998321, 6", v, n)
+	}
+}
+
+func TestPolylineRoundTrip(t *testing.T) {
+	points := []Point{
+		{Latitude: 38.5, Longitude: -120.2},
+		{Latitude: 40.7, Longitude: -120.95},
+		{Latitude: 43.252, Longitude: -126.453},
+		{Latitude: -89.9999999, Longitude: 179.9999999},
+		{Latitude: 0, Longitude: 0},
+	}
+
+	encoded := EncodePolyline(points)
+	decoded, err := DecodePolyline(encoded)
+	if err != nil {
+		t.Fatalf("DecodePolyline failed: %v", err)
+	}
+	if len(decoded) != len(points) {
+		t.Fatalf("len(decoded) = %d, want %d", len(decoded), len(points))
+	}
+	for i := range points {
+		if decoded[i] != points[i] {
+			t.Errorf("decoded[%d] = %v, want %v", i, decoded[i], points[i])
+		}
+	}
+}
+
+func TestPolylineCompression(t *testing.T) {
+	// A dense path along a road: small deltas should take a few bytes each
+	var points []Point
+	for i := 0; i < 100; i++ {
+		points = append(points, Point{Latitude: 39.9 + float64(i)*0.0001, Longitude: 116.4 + float64(i)*0.0001})
+	}
+	encoded := EncodePolyline(points)
+	if len(encoded) >= len(points)*16 {
+		t.Errorf("encoded size = %d bytes, want less than raw %d bytes", len(encoded), len(points)*16)
+	}
+}
+
+func TestDecodePolyline_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"truncated value", "_p~iF~ps|"},
+		{"missing longitude", "_p~iF"},
+		{"invalid character", "_p~iF ps|U"},
+		{"overflow", strings.Repeat("~", 20) + "?"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodePolyline(tt.input); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestQuantizePolyline(t *testing.T) {
+	points := []Point{{Latitude: 39.123456789, Longitude: 116.987654321}}
+	q := QuantizePolyline(points)
+	want := Point{Latitude: 39.1234568, Longitude: 116.9876543}
+	if q[0] != want {
+		t.Errorf("QuantizePolyline() = %v, want %v", q[0], want)
+	}
+
+	decoded, err := DecodePolyline(EncodePolyline(points))
+	if err != nil {
+		t.Fatalf("DecodePolyline failed: %v", err)
+	}
+	if decoded[0] != q[0] {
+		t.Errorf("decoded = %v, want quantized %v", decoded[0], q[0])
+	}
+
+	if QuantizePolyline(nil) != nil {
+		t.Error("QuantizePolyline(nil) should be nil")
+	}
+}
@@ -0,0 +1,185 @@
+package geofence
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// DistanceToBoundary returns the great-circle distance in meters from a point
+// to the nearest edge of the geometry, whether the point is inside or not.
+// Returns +Inf for an empty geometry.
+func (g *Geometry) DistanceToBoundary(p Point) float64 {
+	if b := g.BBox; b != nil {
+		return distanceToBBoxEdge(p, b)
+	}
+	if len(g.Polygon) > 0 {
+		return distanceToRings(p, g.Polygon, g.Holes, g.Edges)
+	}
+	if len(g.MultiPolygon) > 0 {
+		best := math.Inf(1)
+		for _, part := range g.MultiPolygon {
+			best = math.Min(best, distanceToRings(p, part.Outer, part.Holes, g.Edges))
+		}
+		return best
+	}
+	if c := g.Corridor; c != nil {
+		if len(c.Path) == 0 {
+			return math.Inf(1)
+		}
+		return math.Abs(c.DistanceTo(p) - c.HalfWidth)
+	}
+	if g.CircleCenter != nil {
+		return math.Abs(haversineDistance(p, *g.CircleCenter) - g.CircleRadius)
+	}
+	return math.Inf(1)
+}
+
+// DistanceTo returns the distance in meters from a point to the fence:
+// 0 if the point is inside, otherwise the distance to its nearest edge.
+func (f *FenceItem) DistanceTo(p Point) float64 {
+	if f.ContainsPoint(p) {
+		return 0
+	}
+	return f.Geometry.DistanceToBoundary(p)
+}
+
+// IsRestrictive checks if the fence forbids flight inside its volume, as
+// opposed to only limiting altitude or speed.
+func (f *FenceItem) IsRestrictive() bool {
+	return f.Type == FenceTypePermanentNoFly || f.Type == FenceTypeTempRestriction
+}
+
+// distanceToRings returns the distance to the nearest edge of an outer ring
+// or any of its holes, following edges of the given type as pointInPolygon
+// does. Rings are implicitly closed.
+func distanceToRings(p Point, outer []Point, holes [][]Point, edges EdgeType) float64 {
+	best := distanceToRing(p, outer, edges)
+	for _, hole := range holes {
+		best = math.Min(best, distanceToRing(p, hole, edges))
+	}
+	return best
+}
+
+func distanceToRing(p Point, ring []Point, edges EdgeType) float64 {
+	best := math.Inf(1)
+	for i := range ring {
+		j := (i + 1) % len(ring)
+		if edges == EdgeTypeGreatCircle {
+			best = math.Min(best, distanceToSegment(p, ring[i], ring[j]))
+		} else {
+			best = math.Min(best, distanceToRhumbSegment(p, ring[i], ring[j], best))
+		}
+	}
+	return best
+}
+
+// distanceToBBoxEdge returns the distance to the nearest edge of a bounding
+// box. East and west edges are meridians and therefore great circles; north
+// and south edges are parallels, whose nearest point lies on the same
+// meridian as p when p is within the box's longitude range.
+func distanceToBBoxEdge(p Point, b *BoundingBox) float64 {
+	sw := Point{Latitude: b.MinLat, Longitude: b.MinLon}
+	nw := Point{Latitude: b.MaxLat, Longitude: b.MinLon}
+	se := Point{Latitude: b.MinLat, Longitude: b.MaxLon}
+	ne := Point{Latitude: b.MaxLat, Longitude: b.MaxLon}
+
+	best := math.Min(distanceToSegment(p, sw, nw), distanceToSegment(p, se, ne))
+	if lonInRange(p.Longitude, b.MinLon, b.MaxLon) {
+		dLat := math.Min(math.Abs(p.Latitude-b.MinLat), math.Abs(p.Latitude-b.MaxLat))
+		best = math.Min(best, degToRad(dLat)*earthRadiusMeters)
+	}
+	return best
+}
+
+// NearestFences returns the active fences within radiusMeters of a point,
+// nearest first. Fences containing the point have distance 0.
+func NearestFences(fences []FenceItem, p Point, radiusMeters float64) []FenceProximity {
+	return NearestFencesAt(fences, p, radiusMeters, time.Now())
+}
+
+// NearestFencesAt is like NearestFences but selects fences active at time t.
+func NearestFencesAt(fences []FenceItem, p Point, radiusMeters float64, t time.Time) []FenceProximity {
+	var result []FenceProximity
+	for i := range fences {
+		f := &fences[i]
+		if !f.IsActiveAt(t) {
+			continue
+		}
+		if d := f.DistanceTo(p); d <= radiusMeters {
+			result = append(result, FenceProximity{Fence: f, Distance: d})
+		}
+	}
+	SortByDistance(result)
+	return result
+}
+
+// SortByDistance sorts fences nearest first; ties go to the higher priority.
+func SortByDistance(ps []FenceProximity) {
+	sort.SliceStable(ps, func(i, j int) bool {
+		if ps[i].Distance != ps[j].Distance {
+			return ps[i].Distance < ps[j].Distance
+		}
+		return ps[i].Fence.Priority > ps[j].Fence.Priority
+	})
+}
+
+// ProjectState returns the vehicle state after flying with constant velocity
+// for the given duration along the great circle of its initial heading.
+func ProjectState(s VehicleState, d time.Duration) VehicleState {
+	secs := d.Seconds()
+	speed := math.Hypot(s.Velocity.North, s.Velocity.East)
+	bearing := math.Atan2(s.Velocity.East, s.Velocity.North)
+
+	next := s
+	next.Position = destinationPoint(s.Position, bearing, speed*secs)
+	next.Altitude.Meters += s.Velocity.Up * secs
+	if !s.Time.IsZero() {
+		next.Time = s.Time.Add(d)
+	}
+	return next
+}
+
+// PredictEntry projects the vehicle's current velocity up to horizon ahead
+// and returns the first fence violation it would run into, or nil if the
+// projected track stays clear. Violations already in progress at the
+// current position are not reported; use CheckFences3D for those.
+func PredictEntry(fences []FenceItem, s VehicleState, horizon time.Duration) *RouteViolation {
+	if s.Time.IsZero() {
+		s.Time = time.Now()
+	}
+	end := ProjectState(s, horizon)
+	route := []Waypoint{
+		{Point: s.Position, Altitude: s.Altitude, Time: s.Time},
+		{Point: end.Position, Altitude: end.Altitude, Time: end.Time},
+	}
+
+	var first *RouteViolation
+	for _, v := range CheckRoute(fences, route) {
+		if !v.EntryTime.After(s.Time) {
+			continue
+		}
+		if first == nil || v.EntryTime.Before(first.EntryTime) ||
+			(v.EntryTime.Equal(first.EntryTime) && v.Fence.Priority > first.Fence.Priority) {
+			first = &v
+		}
+	}
+	return first
+}
+
+// destinationPoint returns the point reached by travelling a distance in
+// meters from p along the great circle with the given initial bearing in
+// radians clockwise from north.
+func destinationPoint(p Point, bearing, distance float64) Point {
+	lat1 := degToRad(p.Latitude)
+	lon1 := degToRad(p.Longitude)
+	delta := distance / earthRadiusMeters
+
+	lat2 := math.Asin(math.Sin(lat1)*math.Cos(delta) +
+		math.Cos(lat1)*math.Sin(delta)*math.Cos(bearing))
+	lon2 := lon1 + math.Atan2(math.Sin(bearing)*math.Sin(delta)*math.Cos(lat1),
+		math.Cos(delta)-math.Sin(lat1)*math.Sin(lat2))
+
+	lon := math.Mod(lon2*180/math.Pi+540, 360) - 180
+	return Point{Latitude: lat2 * 180 / math.Pi, Longitude: lon}
+}
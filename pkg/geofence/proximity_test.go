@@ -0,0 +1,272 @@
+package geofence
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// metersPerDegree is the length of one degree of latitude on the sphere used
+// by haversineDistance.
+const metersPerDegree = earthRadiusMeters * math.Pi / 180
+
+func TestGeometry_DistanceToBoundary(t *testing.T) {
+	square := []Point{
+		{Latitude: 0, Longitude: 0},
+		{Latitude: 0, Longitude: 1},
+		{Latitude: 1, Longitude: 1},
+		{Latitude: 1, Longitude: 0},
+	}
+	wide := []Point{
+		{Latitude: 50, Longitude: 0},
+		{Latitude: 50, Longitude: 60},
+		{Latitude: 60, Longitude: 60},
+		{Latitude: 60, Longitude: 0},
+	}
+
+	tests := []struct {
+		name  string
+		geom  Geometry
+		point Point
+		want  float64
+	}{
+		{
+			name:  "polygon outside",
+			geom:  Geometry{Polygon: square},
+			point: Point{Latitude: -0.01, Longitude: 0.5},
+			want:  0.01 * metersPerDegree,
+		},
+		{
+			name:  "polygon inside",
+			geom:  Geometry{Polygon: square},
+			point: Point{Latitude: 0.5, Longitude: 0.98},
+			want:  0.02 * metersPerDegree * math.Cos(degToRad(0.5)),
+		},
+		{
+			name: "polygon near hole",
+			geom: Geometry{Polygon: square, Holes: [][]Point{{
+				{Latitude: 0.4, Longitude: 0.4},
+				{Latitude: 0.4, Longitude: 0.6},
+				{Latitude: 0.6, Longitude: 0.6},
+				{Latitude: 0.6, Longitude: 0.4},
+			}}},
+			point: Point{Latitude: 0.39, Longitude: 0.5},
+			want:  0.01 * metersPerDegree,
+		},
+		{
+			name: "multipolygon nearest part",
+			geom: Geometry{MultiPolygon: []PolygonPart{
+				{Outer: square},
+				{Outer: []Point{{Latitude: 5, Longitude: 5}, {Latitude: 5, Longitude: 6}, {Latitude: 6, Longitude: 6}}},
+			}},
+			point: Point{Latitude: 0.5, Longitude: -0.02},
+			want:  haversineDistance(Point{Latitude: 0.5, Longitude: -0.02}, Point{Latitude: 0.5, Longitude: 0}),
+		},
+		{
+			// The great circle between the corners bulges 3.4° north
+			name:  "rhumb edge along a parallel, inside",
+			geom:  Geometry{Polygon: wide},
+			point: Point{Latitude: 59.9, Longitude: 30},
+			want:  0.1 * metersPerDegree,
+		},
+		{
+			name:  "rhumb edge along a parallel, outside",
+			geom:  Geometry{Polygon: wide},
+			point: Point{Latitude: 60.1, Longitude: 30},
+			want:  0.1 * metersPerDegree,
+		},
+		{
+			// The rhumb diagonal crosses 30°E at 35.3°N, the great
+			// circle at 45°N
+			name:  "on a sloping rhumb edge",
+			geom:  Geometry{Polygon: []Point{{Latitude: 0, Longitude: 0}, {Latitude: 60, Longitude: 60}, {Latitude: 0, Longitude: 60}}},
+			point: Point{Latitude: math.Atan(math.Sinh(mercatorY(60)/2)) * 180 / math.Pi, Longitude: 30},
+			want:  0,
+		},
+		{
+			name:  "circle outside",
+			geom:  Geometry{CircleCenter: &Point{Latitude: 10, Longitude: 10}, CircleRadius: 1000},
+			point: Point{Latitude: 10 + 1500/metersPerDegree, Longitude: 10},
+			want:  500,
+		},
+		{
+			name:  "circle inside",
+			geom:  Geometry{CircleCenter: &Point{Latitude: 10, Longitude: 10}, CircleRadius: 1000},
+			point: Point{Latitude: 10 + 400/metersPerDegree, Longitude: 10},
+			want:  600,
+		},
+		{
+			name:  "bbox north of box",
+			geom:  Geometry{BBox: &BoundingBox{MinLat: 0, MinLon: 0, MaxLat: 1, MaxLon: 1}},
+			point: Point{Latitude: 1.01, Longitude: 0.5},
+			want:  0.01 * metersPerDegree,
+		},
+		{
+			name:  "bbox beyond corner",
+			geom:  Geometry{BBox: &BoundingBox{MinLat: 0, MinLon: 0, MaxLat: 1, MaxLon: 1}},
+			point: Point{Latitude: 1.01, Longitude: 1.01},
+			want:  haversineDistance(Point{Latitude: 1.01, Longitude: 1.01}, Point{Latitude: 1, Longitude: 1}),
+		},
+		{
+			name:  "corridor outside",
+			geom:  Geometry{Corridor: &Corridor{Path: []Point{{Latitude: 0, Longitude: 0}, {Latitude: 0, Longitude: 1}}, HalfWidth: 100}},
+			point: Point{Latitude: 250 / metersPerDegree, Longitude: 0.5},
+			want:  150,
+		},
+		{
+			name:  "empty geometry",
+			geom:  Geometry{},
+			point: Point{},
+			want:  math.Inf(1),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.geom.DistanceToBoundary(tt.point)
+			if math.IsInf(tt.want, 1) {
+				if !math.IsInf(got, 1) {
+					t.Errorf("DistanceToBoundary() = %f, want +Inf", got)
+				}
+				return
+			}
+			// Allow for the difference between great-circle and
+			// latitude/longitude edges over short distances
+			if math.Abs(got-tt.want) > 1 {
+				t.Errorf("DistanceToBoundary() = %f, want %f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFenceItem_DistanceTo(t *testing.T) {
+	fence := FenceItem{
+		Type: FenceTypePermanentNoFly,
+		Geometry: Geometry{
+			CircleCenter: &Point{Latitude: 10, Longitude: 10},
+			CircleRadius: 1000,
+		},
+	}
+
+	if d := fence.DistanceTo(Point{Latitude: 10, Longitude: 10}); d != 0 {
+		t.Errorf("DistanceTo(center) = %f, want 0", d)
+	}
+	p := Point{Latitude: 10 + 1150/metersPerDegree, Longitude: 10}
+	if d := fence.DistanceTo(p); math.Abs(d-150) > 0.01 {
+		t.Errorf("DistanceTo() = %f, want 150", d)
+	}
+}
+
+func TestNearestFences(t *testing.T) {
+	circle := func(id string, lat float64, priority uint32) FenceItem {
+		return FenceItem{
+			ID:       id,
+			Type:     FenceTypePermanentNoFly,
+			Priority: priority,
+			Geometry: Geometry{CircleCenter: &Point{Latitude: lat, Longitude: 0}, CircleRadius: 100},
+		}
+	}
+	expired := circle("expired", 0, 100)
+	expired.EndTS = 1
+
+	fences := []FenceItem{
+		circle("far", 2000/metersPerDegree, 10),
+		circle("near", 500/metersPerDegree, 10),
+		circle("out-of-range", 0.1, 10),
+		expired,
+		circle("near-high", 500/metersPerDegree, 90),
+	}
+
+	result := NearestFences(fences, Point{}, 5000)
+	var ids []string
+	for _, r := range result {
+		ids = append(ids, r.Fence.ID)
+	}
+	want := []string{"near-high", "near", "far"}
+	if len(ids) != len(want) {
+		t.Fatalf("NearestFences() = %v, want %v", ids, want)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Errorf("NearestFences()[%d] = %s, want %s", i, ids[i], want[i])
+		}
+	}
+	if math.Abs(result[1].Distance-400) > 0.01 {
+		t.Errorf("Distance = %f, want 400", result[1].Distance)
+	}
+}
+
+func TestProjectState(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	s := VehicleState{
+		Position: Point{Latitude: 0, Longitude: 0},
+		Altitude: Altitude{Meters: 100},
+		Velocity: Velocity{North: 0, East: 10, Up: 1},
+		Time:     start,
+	}
+
+	next := ProjectState(s, time.Minute)
+	if math.Abs(haversineDistance(s.Position, next.Position)-600) > 0.01 {
+		t.Errorf("travelled %f m, want 600", haversineDistance(s.Position, next.Position))
+	}
+	if math.Abs(next.Position.Latitude) > 1e-9 || next.Position.Longitude <= 0 {
+		t.Errorf("Position = %v, want due east", next.Position)
+	}
+	if next.Altitude.Meters != 160 {
+		t.Errorf("Altitude = %f, want 160", next.Altitude.Meters)
+	}
+	if !next.Time.Equal(start.Add(time.Minute)) {
+		t.Errorf("Time = %v, want %v", next.Time, start.Add(time.Minute))
+	}
+
+	// Crossing the antimeridian wraps the longitude
+	s.Position = Point{Latitude: 0, Longitude: 179.999}
+	next = ProjectState(s, time.Minute)
+	if next.Position.Longitude > -179 {
+		t.Errorf("Longitude = %f, want wrapped below -179", next.Position.Longitude)
+	}
+}
+
+func TestPredictEntry(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	zone := FenceItem{
+		ID:       "zone",
+		Type:     FenceTypePermanentNoFly,
+		Priority: 100,
+		Geometry: Geometry{
+			CircleCenter: &Point{Latitude: 0, Longitude: 1000 / metersPerDegree},
+			CircleRadius: 200,
+		},
+	}
+	fences := []FenceItem{zone}
+
+	// Flying east at 20 m/s: the boundary is 800 m away, 40 s ahead
+	s := VehicleState{Velocity: Velocity{East: 20}, Time: start}
+
+	v := PredictEntry(fences, s, time.Minute)
+	if v == nil {
+		t.Fatal("expected predicted entry")
+	}
+	if v.Fence.ID != "zone" {
+		t.Errorf("Fence = %s, want zone", v.Fence.ID)
+	}
+	if got := v.EntryTime.Sub(start); got < 39*time.Second || got > 41*time.Second {
+		t.Errorf("entry in %v, want about 40s", got)
+	}
+
+	if v := PredictEntry(fences, s, 30*time.Second); v != nil {
+		t.Errorf("expected no entry within 30s, got %+v", v)
+	}
+
+	s.Velocity = Velocity{East: -20}
+	if v := PredictEntry(fences, s, time.Minute); v != nil {
+		t.Errorf("expected no entry flying away, got %+v", v)
+	}
+
+	// Already inside: the current violation is not a predicted entry
+	s.Position = *zone.Geometry.CircleCenter
+	s.Velocity = Velocity{East: 1}
+	if v := PredictEntry(fences, s, time.Minute); v != nil {
+		t.Errorf("expected no entry from inside, got %+v", v)
+	}
+}
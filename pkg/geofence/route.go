@@ -0,0 +1,212 @@
+package geofence
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// CheckRoute checks a planned route against multiple fences.
+//
+// Each leg between consecutive waypoints is followed along the great circle,
+// with altitude and time interpolated linearly, and every fence is evaluated
+// at the time the vehicle is planned to be there. A leg violates a fence
+// where it passes through a no-fly volume, above an altitude limit, below an
+// altitude minimum, or through a speed limit zone faster than allowed. One
+// violation is returned per contiguous stretch, in route order.
+func CheckRoute(fences []FenceItem, route []Waypoint) []RouteViolation {
+	return CheckRouteAt(fences, route, time.Now())
+}
+
+// CheckRouteAt is like CheckRoute but legs whose waypoints have no time are
+// evaluated at now instead of the current time.
+func CheckRouteAt(fences []FenceItem, route []Waypoint, now time.Time) []RouteViolation {
+	var violations []RouteViolation
+
+	for leg := 0; leg+1 < len(route); leg++ {
+		l := newRouteLeg(route[leg], route[leg+1], now)
+		legBounds := (&Corridor{Path: []Point{l.from.Point, l.to.Point}}).Bounds()
+
+		for i := range fences {
+			f := &fences[i]
+			if fb := f.GetBounds(); !fb.Intersects(legBounds) {
+				continue
+			}
+			for _, v := range l.check(f) {
+				v.Leg = leg
+				violations = append(violations, v)
+			}
+		}
+	}
+
+	return violations
+}
+
+// routeLeg is a single leg of a route prepared for checking.
+type routeLeg struct {
+	from, to Waypoint
+	start    time.Time // Time at from, resolved against the current time
+	duration time.Duration
+	distance float64 // meters
+	speed    float64 // planned ground speed in m/s, 0 if unknown
+	arc      arc
+	moving   bool // Whether from and to differ, so that arc is set
+}
+
+func newRouteLeg(from, to Waypoint, now time.Time) routeLeg {
+	l := routeLeg{
+		from:     from,
+		to:       to,
+		start:    now,
+		distance: haversineDistance(from.Point, to.Point),
+	}
+	l.arc, l.moving = newArc(from.Point, to.Point)
+	if !from.Time.IsZero() && !to.Time.IsZero() {
+		l.start = from.Time
+		l.duration = to.Time.Sub(from.Time)
+		if l.duration > 0 {
+			l.speed = l.distance / l.duration.Seconds()
+		}
+	}
+	return l
+}
+
+// at returns the interpolated position, altitude and time at a fraction of
+// the leg.
+func (l *routeLeg) at(frac float64) (Point, Altitude, time.Time) {
+	p := interpolateGreatCircle(l.from.Point, l.to.Point, frac)
+	alt := Altitude{
+		Meters:    l.from.Altitude.Meters + (l.to.Altitude.Meters-l.from.Altitude.Meters)*frac,
+		Reference: l.from.Altitude.Reference,
+	}
+	t := l.start.Add(time.Duration(float64(l.duration) * frac))
+	return p, alt, t
+}
+
+// violation reports whether the leg violates a fence at a fraction of the leg.
+func (l *routeLeg) violation(f *FenceItem, frac float64) (ViolationReason, bool) {
+	p, alt, t := l.at(frac)
+	if !f.ContainsPoint(p) || !f.IsActiveAt(t) {
+		return "", false
+	}
+
+	switch f.Type {
+	case FenceTypePermanentNoFly, FenceTypeTempRestriction:
+		if f.ForbidsAltitude(alt) {
+			return ViolationNoFly, true
+		}
+	case FenceTypeAltitudeLimit:
+		if f.ForbidsAltitude(alt) {
+			return ViolationAboveCeiling, true
+		}
+	case FenceTypeAltitudeMinimum:
+		if f.ForbidsAltitude(alt) {
+			return ViolationBelowFloor, true
+		}
+	case FenceTypeSpeedLimit:
+		if f.MaxSpeed > 0 && l.speed > float64(f.MaxSpeed) && f.ContainsAltitude(alt) {
+			return ViolationSpeed, true
+		}
+	}
+	return "", false
+}
+
+// check returns the stretches of the leg violating a fence.
+//
+// The leg is split where it crosses the fence's boundary, its altitude
+// crosses one of the fence's limits, or the fence may become active or
+// inactive. Nothing changes within a piece, so each is evaluated once.
+func (l *routeLeg) check(f *FenceItem) []RouteViolation {
+	fracs := l.breakpoints(f)
+
+	var violations []RouteViolation
+	var current *RouteViolation
+	for i := 0; i+1 < len(fracs); i++ {
+		lo, hi := fracs[i], fracs[i+1]
+		if hi <= lo {
+			continue
+		}
+		reason, violated := l.violation(f, (lo+hi)/2)
+
+		switch {
+		case violated && current == nil:
+			current = &RouteViolation{Fence: *f, Reason: reason}
+			current.Entry, _, current.EntryTime = l.at(lo)
+		case !violated && current != nil:
+			current.Exit, _, current.ExitTime = l.at(lo)
+			violations = append(violations, *current)
+			current = nil
+		}
+	}
+
+	if current != nil {
+		current.Exit, _, current.ExitTime = l.at(1)
+		violations = append(violations, *current)
+	}
+
+	return violations
+}
+
+// breakpoints returns the fractions of the leg at which whether it violates
+// a fence may change, in ascending order from 0 to 1.
+func (l *routeLeg) breakpoints(f *FenceItem) []float64 {
+	fracs := []float64{0, 1}
+	if l.moving {
+		fracs = append(fracs, l.arc.crossings(&f.Geometry)...)
+	}
+
+	// Altitude limits, interpolated linearly along the leg
+	if climb := l.to.Altitude.Meters - l.from.Altitude.Meters; climb != 0 {
+		var limits []float64
+		if f.Altitude != nil {
+			limits = append(limits, f.Altitude.Floor, f.Altitude.Ceiling)
+		}
+		ceiling, _ := f.CeilingAltitude()
+		floor, _ := f.FloorAltitude()
+		limits = append(limits, ceiling, floor)
+		for _, limit := range limits {
+			if frac := (limit - l.from.Altitude.Meters) / climb; frac > 0 && frac < 1 {
+				fracs = append(fracs, frac)
+			}
+		}
+	}
+
+	// Activation, expiry and schedule windows
+	if l.duration > 0 {
+		end := l.start.Add(l.duration)
+		times := f.Schedule.transitions(l.start, end)
+		if f.StartTS != 0 {
+			times = append(times, time.Unix(f.StartTS, 0))
+		}
+		if f.EndTS > 0 {
+			times = append(times, time.Unix(f.EndTS+1, 0))
+		}
+		for _, t := range times {
+			if t.After(l.start) && t.Before(end) {
+				// Rounded up so that at() does not fall short of t
+				fracs = append(fracs, (float64(t.Sub(l.start))+0.5)/float64(l.duration))
+			}
+		}
+	}
+
+	sort.Float64s(fracs)
+	return fracs
+}
+
+// interpolateGreatCircle returns the point at a fraction of the great-circle
+// arc from a to b.
+func interpolateGreatCircle(a, b Point, frac float64) Point {
+	va, vb := toVec3(a), toVec3(b)
+	omega := math.Acos(math.Max(-1, math.Min(1, va.dot(vb))))
+	if omega < 1e-12 {
+		return a
+	}
+	sinOmega := math.Sin(omega)
+	wa := math.Sin((1-frac)*omega) / sinOmega
+	wb := math.Sin(frac*omega) / sinOmega
+	v := vec3{va.x*wa + vb.x*wb, va.y*wa + vb.y*wb, va.z*wa + vb.z*wb}
+	return Point{
+		Latitude:  v.latitude(),
+		Longitude: math.Atan2(v.y, v.x) * 180 / math.Pi,
+	}
+}
@@ -0,0 +1,254 @@
+package geofence
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCheckRoute(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+
+	// Square no-fly zone between 116.4 and 116.6 east
+	noFly := FenceItem{
+		ID:       "no-fly",
+		Type:     FenceTypePermanentNoFly,
+		Priority: 100,
+		Geometry: Geometry{
+			Polygon: []Point{
+				{Latitude: 39.4, Longitude: 116.4},
+				{Latitude: 39.4, Longitude: 116.6},
+				{Latitude: 39.6, Longitude: 116.6},
+				{Latitude: 39.6, Longitude: 116.4},
+			},
+		},
+	}
+
+	t.Run("crossing leg", func(t *testing.T) {
+		route := []Waypoint{
+			{Point: Point{Latitude: 39.5, Longitude: 116.2}, Time: start},
+			{Point: Point{Latitude: 39.5, Longitude: 116.3}, Time: start.Add(10 * time.Minute)},
+			{Point: Point{Latitude: 39.5, Longitude: 116.8}, Time: start.Add(60 * time.Minute)},
+		}
+
+		violations := CheckRoute([]FenceItem{noFly}, route)
+		if len(violations) != 1 {
+			t.Fatalf("got %d violations, want 1", len(violations))
+		}
+		v := violations[0]
+		if v.Leg != 1 {
+			t.Errorf("Leg = %d, want 1", v.Leg)
+		}
+		if v.Fence.ID != "no-fly" || v.Reason != ViolationNoFly {
+			t.Errorf("Fence = %s, Reason = %s, want no-fly, %s", v.Fence.ID, v.Reason, ViolationNoFly)
+		}
+		if math.Abs(v.Entry.Longitude-116.4) > 1e-4 {
+			t.Errorf("Entry = %v, want longitude 116.4", v.Entry)
+		}
+		if math.Abs(v.Exit.Longitude-116.6) > 1e-4 {
+			t.Errorf("Exit = %v, want longitude 116.6", v.Exit)
+		}
+		if !v.EntryTime.After(route[1].Time) || !v.ExitTime.Before(route[2].Time) ||
+			!v.EntryTime.Before(v.ExitTime) {
+			t.Errorf("EntryTime = %v, ExitTime = %v, want ordered within the leg", v.EntryTime, v.ExitTime)
+		}
+	})
+
+	t.Run("start inside", func(t *testing.T) {
+		route := []Waypoint{
+			{Point: Point{Latitude: 39.5, Longitude: 116.5}, Time: start},
+			{Point: Point{Latitude: 39.5, Longitude: 116.7}, Time: start.Add(time.Hour)},
+		}
+		violations := CheckRoute([]FenceItem{noFly}, route)
+		if len(violations) != 1 {
+			t.Fatalf("got %d violations, want 1", len(violations))
+		}
+		if violations[0].Entry != route[0].Point {
+			t.Errorf("Entry = %v, want route start %v", violations[0].Entry, route[0].Point)
+		}
+	})
+
+	t.Run("clear route", func(t *testing.T) {
+		route := []Waypoint{
+			{Point: Point{Latitude: 39.7, Longitude: 116.2}, Time: start},
+			{Point: Point{Latitude: 39.7, Longitude: 116.8}, Time: start.Add(time.Hour)},
+		}
+		if violations := CheckRoute([]FenceItem{noFly}, route); len(violations) != 0 {
+			t.Errorf("got %d violations, want 0", len(violations))
+		}
+	})
+
+	t.Run("fence inactive at planned time", func(t *testing.T) {
+		tfr := noFly
+		tfr.ID = "tfr"
+		tfr.Type = FenceTypeTempRestriction
+		tfr.StartTS = start.Add(2 * time.Hour).Unix()
+		tfr.EndTS = start.Add(3 * time.Hour).Unix()
+
+		early := []Waypoint{
+			{Point: Point{Latitude: 39.5, Longitude: 116.2}, Time: start},
+			{Point: Point{Latitude: 39.5, Longitude: 116.8}, Time: start.Add(time.Hour)},
+		}
+		if violations := CheckRoute([]FenceItem{tfr}, early); len(violations) != 0 {
+			t.Errorf("got %d violations before activation, want 0", len(violations))
+		}
+
+		// The restriction activates while the vehicle is inside the zone
+		late := []Waypoint{
+			{Point: Point{Latitude: 39.5, Longitude: 116.2}, Time: start.Add(time.Hour)},
+			{Point: Point{Latitude: 39.5, Longitude: 116.8}, Time: start.Add(3 * time.Hour)},
+		}
+		violations := CheckRoute([]FenceItem{tfr}, late)
+		if len(violations) != 1 {
+			t.Fatalf("got %d violations, want 1", len(violations))
+		}
+		if got := violations[0].EntryTime.Unix(); got < tfr.StartTS || got > tfr.StartTS+1 {
+			t.Errorf("EntryTime = %d, want activation time %d", got, tfr.StartTS)
+		}
+	})
+
+	t.Run("climb over band", func(t *testing.T) {
+		band := noFly
+		band.Altitude = &AltitudeBand{Ceiling: 120}
+		route := []Waypoint{
+			{Point: Point{Latitude: 39.5, Longitude: 116.2}, Altitude: Altitude{Meters: 150}, Time: start},
+			{Point: Point{Latitude: 39.5, Longitude: 116.8}, Altitude: Altitude{Meters: 150}, Time: start.Add(time.Hour)},
+		}
+		if violations := CheckRoute([]FenceItem{band}, route); len(violations) != 0 {
+			t.Errorf("got %d violations above band, want 0", len(violations))
+		}
+	})
+
+	t.Run("altitude and speed limits", func(t *testing.T) {
+		limit := noFly
+		limit.ID = "ceiling"
+		limit.Type = FenceTypeAltitudeLimit
+		limit.MaxAltitude = 100
+
+		speed := noFly
+		speed.ID = "speed"
+		speed.Type = FenceTypeSpeedLimit
+		speed.MaxSpeed = 10
+
+		// About 51 km in 10 minutes: 85 m/s at 150 m
+		route := []Waypoint{
+			{Point: Point{Latitude: 39.5, Longitude: 116.2}, Altitude: Altitude{Meters: 150}, Time: start},
+			{Point: Point{Latitude: 39.5, Longitude: 116.8}, Altitude: Altitude{Meters: 150}, Time: start.Add(10 * time.Minute)},
+		}
+		violations := CheckRoute([]FenceItem{limit, speed}, route)
+		if len(violations) != 2 {
+			t.Fatalf("got %d violations, want 2", len(violations))
+		}
+		if violations[0].Reason != ViolationAboveCeiling || violations[1].Reason != ViolationSpeed {
+			t.Errorf("Reasons = %s, %s, want %s, %s", violations[0].Reason, violations[1].Reason,
+				ViolationAboveCeiling, ViolationSpeed)
+		}
+	})
+
+	t.Run("thin corridor between samples", func(t *testing.T) {
+		// A 3 m wide powerline crossed at right angles
+		powerline := FenceItem{
+			ID:   "powerline",
+			Type: FenceTypeTempRestriction,
+			Geometry: Geometry{Corridor: &Corridor{
+				Path:      []Point{{Latitude: 39.0, Longitude: 116.5}, {Latitude: 40.0, Longitude: 116.5}},
+				HalfWidth: 1.5,
+			}},
+		}
+		route := []Waypoint{
+			{Point: Point{Latitude: 39.5, Longitude: 116.49}},
+			{Point: Point{Latitude: 39.5, Longitude: 116.5137}},
+		}
+		violations := CheckRoute([]FenceItem{powerline}, route)
+		if len(violations) != 1 {
+			t.Fatalf("got %d violations, want 1", len(violations))
+		}
+		width := haversineDistance(violations[0].Entry, violations[0].Exit)
+		if width < 2.5 || width > 3.5 {
+			t.Errorf("violation length = %.2f m, want about 3 m", width)
+		}
+	})
+
+	t.Run("clipped corner", func(t *testing.T) {
+		// A leg cutting about 2 m off the south-west corner, between
+		// samples 10 m apart
+		route := []Waypoint{
+			{Point: Point{Latitude: 39.399, Longitude: 116.40102}},
+			{Point: Point{Latitude: 39.401, Longitude: 116.39902}},
+		}
+		violations := CheckRoute([]FenceItem{noFly}, route)
+		if len(violations) != 1 {
+			t.Fatalf("got %d violations, want 1", len(violations))
+		}
+		if d := haversineDistance(violations[0].Entry, violations[0].Exit); d > 10 {
+			t.Errorf("violation length = %.2f m, want a few meters", d)
+		}
+	})
+
+	t.Run("schedule window", func(t *testing.T) {
+		// Restricted from 12:00 UTC on weekdays
+		scheduled := noFly
+		scheduled.Type = FenceTypeTempRestriction
+		scheduled.Schedule = &Schedule{Rules: []WeeklyRule{{
+			Days:        []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+			StartMinute: 12 * 60,
+			EndMinute:   18 * 60,
+		}}}
+		noon := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+		route := []Waypoint{
+			{Point: Point{Latitude: 39.5, Longitude: 116.2}, Time: noon.Add(-30 * time.Minute)},
+			{Point: Point{Latitude: 39.5, Longitude: 116.8}, Time: noon.Add(30 * time.Minute)},
+		}
+		violations := CheckRoute([]FenceItem{scheduled}, route)
+		if len(violations) != 1 {
+			t.Fatalf("got %d violations, want 1", len(violations))
+		}
+		if got := violations[0].EntryTime; got.Before(noon) || got.After(noon.Add(time.Second)) {
+			t.Errorf("EntryTime = %v, want %v", got, noon)
+		}
+	})
+}
+
+func BenchmarkCheckRoute(b *testing.B) {
+	// A 50 km leg through a grid of 200 small polygons
+	var fences []FenceItem
+	for i := 0; i < 200; i++ {
+		lon := 116.0 + float64(i%50)*0.012
+		lat := 39.49 + float64(i/50)*0.005
+		fences = append(fences, FenceItem{
+			ID:   fmt.Sprintf("fence-%d", i),
+			Type: FenceTypePermanentNoFly,
+			Geometry: Geometry{Polygon: []Point{
+				{Latitude: lat, Longitude: lon},
+				{Latitude: lat, Longitude: lon + 0.005},
+				{Latitude: lat + 0.004, Longitude: lon + 0.005},
+				{Latitude: lat + 0.004, Longitude: lon},
+			}},
+		})
+	}
+	route := []Waypoint{
+		{Point: Point{Latitude: 39.5, Longitude: 116.0}},
+		{Point: Point{Latitude: 39.5, Longitude: 116.59}},
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		CheckRoute(fences, route)
+	}
+}
+
+func TestInterpolateGreatCircle(t *testing.T) {
+	a := Point{Latitude: 0, Longitude: 179}
+	b := Point{Latitude: 0, Longitude: -179}
+
+	// The short way round crosses the antimeridian
+	mid := interpolateGreatCircle(a, b, 0.5)
+	if math.Abs(math.Abs(mid.Longitude)-180) > 1e-9 || math.Abs(mid.Latitude) > 1e-9 {
+		t.Errorf("midpoint = %v, want 0, 180", mid)
+	}
+
+	if p := interpolateGreatCircle(a, a, 0.5); p != a {
+		t.Errorf("interpolate between equal points = %v, want %v", p, a)
+	}
+}
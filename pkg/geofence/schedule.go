@@ -0,0 +1,144 @@
+package geofence
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	// Embed the IANA database so schedules resolve on devices without
+	// a system zoneinfo directory.
+	_ "time/tzdata"
+)
+
+const minutesPerDay = 24 * 60
+
+// locationCache holds time zones already loaded by Schedule.Location.
+var locationCache sync.Map // map[string]*time.Location
+
+// Location returns the schedule's time zone.
+func (s *Schedule) Location() (*time.Location, error) {
+	if s.TimeZone == "" {
+		return time.UTC, nil
+	}
+	if loc, ok := locationCache.Load(s.TimeZone); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q: %w", s.TimeZone, err)
+	}
+	locationCache.Store(s.TimeZone, loc)
+	return loc, nil
+}
+
+// ActiveAt checks if any rule of the schedule covers the given time.
+// A nil schedule or one without rules places no constraint. A schedule
+// whose time zone cannot be resolved is treated as always active, which
+// errs on the side of restriction.
+func (s *Schedule) ActiveAt(t time.Time) bool {
+	if s == nil || len(s.Rules) == 0 {
+		return true
+	}
+	loc, err := s.Location()
+	if err != nil {
+		return true
+	}
+
+	local := t.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	for _, r := range s.Rules {
+		if r.covers(local.Weekday(), minute) {
+			return true
+		}
+	}
+	return false
+}
+
+// transitions returns the times in (from, to) at which the schedule may
+// switch between active and inactive: the local start and end of each
+// rule's window on every day, and every change of the zone's UTC offset.
+func (s *Schedule) transitions(from, to time.Time) []time.Time {
+	if s == nil || len(s.Rules) == 0 {
+		return nil
+	}
+	loc, err := s.Location()
+	if err != nil {
+		return nil
+	}
+
+	var times []time.Time
+	add := func(t time.Time) {
+		if t.After(from) && t.Before(to) {
+			times = append(times, t)
+		}
+	}
+	y, m, d := from.In(loc).Date()
+	for ; time.Date(y, m, d, 0, 0, 0, 0, loc).Before(to); d++ {
+		for _, r := range s.Rules {
+			add(time.Date(y, m, d, 0, r.StartMinute, 0, 0, loc))
+			add(time.Date(y, m, d, 0, r.EndMinute, 0, 0, loc))
+		}
+	}
+	for t := from; ; {
+		_, end := t.In(loc).ZoneBounds()
+		if end.IsZero() || !end.Before(to) {
+			break
+		}
+		add(end)
+		t = end
+	}
+	return times
+}
+
+// covers checks if the rule covers a minute of a local weekday.
+func (r WeeklyRule) covers(day time.Weekday, minute int) bool {
+	start, end := r.StartMinute, r.EndMinute
+	if end > start {
+		return r.onDay(day) && minute >= start && minute < end
+	}
+
+	// Overnight window: the evening part belongs to the start day and the
+	// early morning part to the day after
+	yesterday := (day + 6) % 7
+	return (r.onDay(day) && minute >= start) || (r.onDay(yesterday) && minute < end)
+}
+
+func (r WeeklyRule) onDay(day time.Weekday) bool {
+	return len(r.Days) == 0 || slices.Contains(r.Days, day)
+}
+
+// Validate checks that the schedule's time zone exists and its rules are
+// within range.
+func (s *Schedule) Validate() error {
+	if _, err := s.Location(); err != nil {
+		return err
+	}
+	for i, r := range s.Rules {
+		if r.StartMinute < 0 || r.StartMinute >= minutesPerDay {
+			return fmt.Errorf("rule %d: start minute out of range: %d", i, r.StartMinute)
+		}
+		if r.EndMinute < 0 || r.EndMinute > minutesPerDay {
+			return fmt.Errorf("rule %d: end minute out of range: %d", i, r.EndMinute)
+		}
+		for _, d := range r.Days {
+			if d < time.Sunday || d > time.Saturday {
+				return fmt.Errorf("rule %d: invalid weekday: %d", i, d)
+			}
+		}
+	}
+	return nil
+}
+
+// Equal reports whether two schedules are identical. Nil schedules are
+// only equal to each other.
+func (s *Schedule) Equal(o *Schedule) bool {
+	if s == nil || o == nil {
+		return s == o
+	}
+	return s.TimeZone == o.TimeZone &&
+		slices.EqualFunc(s.Rules, o.Rules, func(a, b WeeklyRule) bool {
+			return a.StartMinute == b.StartMinute && a.EndMinute == b.EndMinute &&
+				slices.Equal(a.Days, b.Days)
+		})
+}
@@ -0,0 +1,158 @@
+package geofence
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedule_ActiveAt(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("LoadLocation failed: %v", err)
+	}
+
+	// Stadium: Saturdays 14:00-18:00 Berlin time
+	stadium := &Schedule{
+		TimeZone: "Europe/Berlin",
+		Rules:    []WeeklyRule{{Days: []time.Weekday{time.Saturday}, StartMinute: 14 * 60, EndMinute: 18 * 60}},
+	}
+	// Night curfew every day 22:00-06:00 UTC
+	curfew := &Schedule{Rules: []WeeklyRule{{StartMinute: 22 * 60, EndMinute: 6 * 60}}}
+	// Friday night only, running into Saturday
+	fridayNight := &Schedule{Rules: []WeeklyRule{{Days: []time.Weekday{time.Friday}, StartMinute: 22 * 60, EndMinute: 2 * 60}}}
+
+	tests := []struct {
+		name     string
+		schedule *Schedule
+		at       time.Time
+		want     bool
+	}{
+		{"nil schedule", nil, time.Now(), true},
+		{"no rules", &Schedule{TimeZone: "Asia/Shanghai"}, time.Now(), true},
+		{"saturday afternoon", stadium, time.Date(2024, 6, 15, 15, 0, 0, 0, berlin), true},
+		{"saturday window start", stadium, time.Date(2024, 6, 15, 14, 0, 0, 0, berlin), true},
+		{"saturday window end", stadium, time.Date(2024, 6, 15, 18, 0, 0, 0, berlin), false},
+		{"saturday morning", stadium, time.Date(2024, 6, 15, 10, 0, 0, 0, berlin), false},
+		{"sunday afternoon", stadium, time.Date(2024, 6, 16, 15, 0, 0, 0, berlin), false},
+		// 13:30 UTC is 15:30 in Berlin summer time
+		{"evaluated in local time", stadium, time.Date(2024, 6, 15, 13, 30, 0, 0, time.UTC), true},
+		{"curfew late evening", curfew, time.Date(2024, 6, 12, 23, 0, 0, 0, time.UTC), true},
+		{"curfew early morning", curfew, time.Date(2024, 6, 12, 5, 59, 0, 0, time.UTC), true},
+		{"curfew daytime", curfew, time.Date(2024, 6, 12, 12, 0, 0, 0, time.UTC), false},
+		{"friday night", fridayNight, time.Date(2024, 6, 14, 23, 0, 0, 0, time.UTC), true},
+		{"early saturday", fridayNight, time.Date(2024, 6, 15, 1, 0, 0, 0, time.UTC), true},
+		{"early friday", fridayNight, time.Date(2024, 6, 14, 1, 0, 0, 0, time.UTC), false},
+		{"unknown time zone", &Schedule{TimeZone: "Mars/Olympus", Rules: curfew.Rules}, time.Date(2024, 6, 12, 12, 0, 0, 0, time.UTC), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.schedule.ActiveAt(tt.at); got != tt.want {
+				t.Errorf("ActiveAt(%v) = %v, want %v", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchedule_DaylightSaving(t *testing.T) {
+	// A 09:00-10:00 New York rule follows local time across the DST change
+	s := &Schedule{TimeZone: "America/New_York", Rules: []WeeklyRule{{StartMinute: 9 * 60, EndMinute: 10 * 60}}}
+
+	winter := time.Date(2024, 1, 10, 14, 30, 0, 0, time.UTC) // 09:30 EST
+	summer := time.Date(2024, 7, 10, 13, 30, 0, 0, time.UTC) // 09:30 EDT
+	if !s.ActiveAt(winter) || !s.ActiveAt(summer) {
+		t.Errorf("ActiveAt(winter) = %v, ActiveAt(summer) = %v, want both true", s.ActiveAt(winter), s.ActiveAt(summer))
+	}
+	if s.ActiveAt(time.Date(2024, 7, 10, 14, 30, 0, 0, time.UTC)) {
+		t.Error("10:30 EDT should be outside the window")
+	}
+}
+
+func TestSchedule_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule Schedule
+		wantErr  bool
+	}{
+		{"valid", Schedule{TimeZone: "Europe/Berlin", Rules: []WeeklyRule{{Days: []time.Weekday{time.Monday}, StartMinute: 0, EndMinute: 1440}}}, false},
+		{"unknown zone", Schedule{TimeZone: "Nowhere/City"}, true},
+		{"start out of range", Schedule{Rules: []WeeklyRule{{StartMinute: 1440, EndMinute: 10}}}, true},
+		{"end out of range", Schedule{Rules: []WeeklyRule{{StartMinute: 0, EndMinute: 1441}}}, true},
+		{"negative start", Schedule{Rules: []WeeklyRule{{StartMinute: -1, EndMinute: 10}}}, true},
+		{"invalid weekday", Schedule{Rules: []WeeklyRule{{Days: []time.Weekday{7}, EndMinute: 10}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.schedule.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFenceItem_ScheduledRestriction(t *testing.T) {
+	// School zone on weekdays 07:30-16:00 UTC
+	fence := FenceItem{
+		ID:       "school",
+		Type:     FenceTypeTempRestriction,
+		Priority: 80,
+		Geometry: Geometry{CircleCenter: &Point{Latitude: 10, Longitude: 10}, CircleRadius: 500},
+		Schedule: &Schedule{Rules: []WeeklyRule{{
+			Days:        []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+			StartMinute: 7*60 + 30,
+			EndMinute:   16 * 60,
+		}}},
+	}
+
+	weekday := time.Date(2024, 6, 12, 9, 0, 0, 0, time.UTC)
+	weekend := time.Date(2024, 6, 15, 9, 0, 0, 0, time.UTC)
+	if !fence.IsActiveAt(weekday) {
+		t.Error("expected fence active on a weekday morning")
+	}
+	if fence.IsActiveAt(weekend) {
+		t.Error("expected fence inactive on the weekend")
+	}
+
+	// The absolute window still bounds the schedule
+	fence.EndTS = time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC).Unix()
+	if fence.IsActiveAt(weekday) {
+		t.Error("expected fence inactive after EndTS")
+	}
+}
+
+func TestCheckFences_Schedule(t *testing.T) {
+	now := time.Now().UTC()
+	minute := now.Hour()*60 + now.Minute()
+	today := now.Weekday()
+
+	circle := Geometry{CircleCenter: &Point{Latitude: 10, Longitude: 10}, CircleRadius: 500}
+	fences := []FenceItem{
+		{
+			ID:       "active-now",
+			Type:     FenceTypeTempRestriction,
+			Priority: 50,
+			Geometry: circle,
+			Schedule: &Schedule{Rules: []WeeklyRule{{Days: []time.Weekday{today}, StartMinute: minute, EndMinute: minute}}},
+		},
+		{
+			ID:       "other-day",
+			Type:     FenceTypePermanentNoFly,
+			Priority: 100,
+			Geometry: circle,
+			Schedule: &Schedule{Rules: []WeeklyRule{{Days: []time.Weekday{(today + 3) % 7}, StartMinute: 0, EndMinute: 1440}}},
+		},
+	}
+
+	result := CheckFences(fences, Point{Latitude: 10, Longitude: 10})
+	if len(result.MatchingFences) != 1 || result.MatchingFences[0].ID != "active-now" {
+		t.Errorf("MatchingFences = %v, want only active-now", result.MatchingFences)
+	}
+	if result.Restriction == nil || result.Restriction.ID != "active-now" {
+		t.Errorf("Restriction = %v, want active-now", result.Restriction)
+	}
+	if level := fences[1].RestrictionLevel(Point{Latitude: 10, Longitude: 10}); level != 0 {
+		t.Errorf("RestrictionLevel() = %d, want 0 outside schedule", level)
+	}
+}
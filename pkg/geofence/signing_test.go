@@ -0,0 +1,85 @@
+package geofence
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFenceItem_MarshalBinaryForSigning(t *testing.T) {
+	fence := FenceItem{
+		ID:       "stadium",
+		Type:     FenceTypeTempRestriction,
+		Priority: 50,
+		Geometry: Geometry{
+			CircleCenter: &Point{Latitude: 30.5, Longitude: 110.5},
+			CircleRadius: 1000,
+		},
+		Name: "Stadium",
+	}
+
+	data, err := fence.MarshalBinaryForSigning()
+	if err != nil {
+		t.Fatalf("MarshalBinaryForSigning failed: %v", err)
+	}
+	want := FenceSigningPrefix + `{"id":"stadium","type":1,"geometry":{"circle_center":{"lat":30.5,"lon":110.5},"circle_radius_m":1000},` +
+		`"start_ts":0,"end_ts":0,"priority":50,"max_alt_m":0,"max_speed_mps":0,"name":"Stadium","description":""}`
+	if string(data) != want {
+		t.Errorf("MarshalBinaryForSigning() = %s, want %s", data, want)
+	}
+
+	fence.SetSignature([]byte("signature"), "key")
+	signed, err := fence.MarshalBinaryForSigning()
+	if err != nil {
+		t.Fatalf("MarshalBinaryForSigning failed: %v", err)
+	}
+	if !bytes.Equal(data, signed) {
+		t.Error("signing data should not depend on the signature")
+	}
+
+	tests := []struct {
+		name   string
+		modify func(f *FenceItem)
+	}{
+		{"geometry", func(f *FenceItem) { f.Geometry.CircleRadius++ }},
+		{"window", func(f *FenceItem) { f.EndTS = 1 }},
+		{"altitude band", func(f *FenceItem) { f.Altitude = &AltitudeBand{Ceiling: 120} }},
+		{"schedule", func(f *FenceItem) { f.Schedule = &Schedule{Rules: []WeeklyRule{{StartMinute: 60}}} }},
+		{"conditions", func(f *FenceItem) { f.Conditions = &Conditions{MinMassKg: 25} }},
+		{"description", func(f *FenceItem) { f.Description = "moved" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed := fence
+			tt.modify(&changed)
+			got, err := changed.MarshalBinaryForSigning()
+			if err != nil {
+				t.Fatalf("MarshalBinaryForSigning failed: %v", err)
+			}
+			if bytes.Equal(got, data) {
+				t.Errorf("signing data should cover the %s", tt.name)
+			}
+		})
+	}
+}
+
+func TestFenceItem_MarshalBinaryForSigning_EmptySchedule(t *testing.T) {
+	a := FenceItem{ID: "a", Schedule: &Schedule{}}
+	b := FenceItem{ID: "a", Schedule: &Schedule{Rules: []WeeklyRule{}}}
+
+	dataA, err := a.MarshalBinaryForSigning()
+	if err != nil {
+		t.Fatalf("MarshalBinaryForSigning failed: %v", err)
+	}
+	dataB, err := b.MarshalBinaryForSigning()
+	if err != nil {
+		t.Fatalf("MarshalBinaryForSigning failed: %v", err)
+	}
+	if !bytes.Equal(dataA, dataB) {
+		t.Errorf("nil and empty rule lists should sign alike: %s vs %s", dataA, dataB)
+	}
+	if !strings.Contains(string(dataA), `"rules":[]`) {
+		t.Errorf("MarshalBinaryForSigning() = %s, want empty rule list", dataA)
+	}
+}
@@ -45,10 +45,11 @@ type Point struct {
 
 // NewPoint creates a new Point with validation.
 func NewPoint(lat, lon float64) (Point, error) {
-	if lat < -90 || lat > 90 {
+	// Negated comparisons so that NaN is rejected as well
+	if !(lat >= -90 && lat <= 90) {
 		return Point{}, fmt.Errorf("latitude out of range: %f", lat)
 	}
-	if lon < -180 || lon > 180 {
+	if !(lon >= -180 && lon <= 180) {
 		return Point{}, fmt.Errorf("longitude out of range: %f", lon)
 	}
 	return Point{Latitude: lat, Longitude: lon}, nil
@@ -56,18 +57,64 @@ func NewPoint(lat, lon float64) (Point, error) {
 
 // Geometry defines the spatial shape of a fence.
 type Geometry struct {
-	// Polygon vertices (if shape is a polygon)
+	// Polygon vertices (if shape is a polygon), i.e. the outer ring
 	Polygon []Point `json:"polygon,omitempty"`
 
+	// Interior rings cut out of Polygon (e.g. a permitted corridor)
+	Holes [][]Point `json:"holes,omitempty"`
+
+	// Disjoint polygon parts (if shape is a multi-polygon)
+	MultiPolygon []PolygonPart `json:"multi_polygon,omitempty"`
+
 	// Circle center and radius (if shape is a circle)
 	CircleCenter *Point  `json:"circle_center,omitempty"`
 	CircleRadius float64 `json:"circle_radius_m,omitempty"` // meters
 
 	// Bounding box (if shape is a rectangle)
 	BBox *BoundingBox `json:"bbox,omitempty"`
+
+	// Buffered polyline (if shape is a corridor along a route)
+	Corridor *Corridor `json:"corridor,omitempty"`
+
+	// How polygon and multi-polygon edges run between their vertices
+	Edges EdgeType `json:"edges,omitempty"`
+}
+
+// EdgeType defines the path a polygon edge takes between two vertices.
+type EdgeType int32
+
+const (
+	EdgeTypeRhumb       EdgeType = 0 // Constant bearing, a straight line on a Mercator chart
+	EdgeTypeGreatCircle EdgeType = 1 // Shortest path on the sphere
+)
+
+// String returns a human-readable representation of the edge type.
+func (e EdgeType) String() string {
+	switch e {
+	case EdgeTypeRhumb:
+		return "RHUMB"
+	case EdgeTypeGreatCircle:
+		return "GREAT_CIRCLE"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// PolygonPart is a single polygon of a multi-polygon geometry.
+type PolygonPart struct {
+	Outer []Point   `json:"outer"`           // Outer ring
+	Holes [][]Point `json:"holes,omitempty"` // Interior rings
+}
+
+// Corridor is the area within a fixed distance of a polyline, such as a
+// motorcade route or a powerline with a safety buffer.
+type Corridor struct {
+	Path      []Point `json:"path"`         // Center line vertices
+	HalfWidth float64 `json:"half_width_m"` // Buffer on each side of the path in meters
 }
 
-// BoundingBox represents a rectangular area.
+// BoundingBox represents a rectangular area. A box with MinLon > MaxLon
+// crosses the antimeridian, spanning from MinLon east to MaxLon.
 type BoundingBox struct {
 	MinLat float64 `json:"min_lat"`
 	MinLon float64 `json:"min_lon"`
@@ -78,7 +125,244 @@ type BoundingBox struct {
 // Contains checks if a point is within the bounding box.
 func (b *BoundingBox) Contains(p Point) bool {
 	return p.Latitude >= b.MinLat && p.Latitude <= b.MaxLat &&
-		p.Longitude >= b.MinLon && p.Longitude <= b.MaxLon
+		lonInRange(p.Longitude, b.MinLon, b.MaxLon)
+}
+
+// Intersects checks if two bounding boxes overlap.
+func (b *BoundingBox) Intersects(o BoundingBox) bool {
+	if b.MinLat > o.MaxLat || b.MaxLat < o.MinLat {
+		return false
+	}
+	for _, p := range b.Split() {
+		for _, q := range o.Split() {
+			if p.MinLon <= q.MaxLon && p.MaxLon >= q.MinLon {
+				return true
+			}
+			// Both touch the antimeridian from opposite sides
+			if (p.MaxLon == 180 && q.MinLon == -180) || (p.MinLon == -180 && q.MaxLon == 180) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CrossesAntimeridian checks if the box wraps across the ±180° meridian.
+func (b *BoundingBox) CrossesAntimeridian() bool {
+	return b.MinLon > b.MaxLon
+}
+
+// Split returns the box as one box if it does not cross the antimeridian,
+// or as its parts east and west of the antimeridian if it does, for use
+// with indexes that cannot represent wrapping ranges.
+func (b *BoundingBox) Split() []BoundingBox {
+	if !b.CrossesAntimeridian() {
+		return []BoundingBox{*b}
+	}
+	east, west := *b, *b
+	east.MaxLon = 180
+	west.MinLon = -180
+	return []BoundingBox{east, west}
+}
+
+// AltitudeReference defines the vertical datum an altitude is measured from.
+type AltitudeReference int32
+
+const (
+	AltitudeReferenceAGL   AltitudeReference = 0 // Above ground level
+	AltitudeReferenceMSL   AltitudeReference = 1 // Above mean sea level
+	AltitudeReferenceWGS84 AltitudeReference = 2 // Above the WGS84 ellipsoid
+)
+
+// String returns a human-readable representation of the altitude reference.
+func (r AltitudeReference) String() string {
+	switch r {
+	case AltitudeReferenceAGL:
+		return "AGL"
+	case AltitudeReferenceMSL:
+		return "MSL"
+	case AltitudeReferenceWGS84:
+		return "WGS84"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// AltitudeBand is the vertical extent of a fence volume.
+type AltitudeBand struct {
+	Floor     float64           `json:"floor_m"`   // Lower limit in meters
+	Ceiling   float64           `json:"ceiling_m"` // Upper limit in meters, 0 = no limit
+	Reference AltitudeReference `json:"reference"` // Datum for Floor and Ceiling
+}
+
+// Altitude is a vehicle height in a given vertical reference.
+type Altitude struct {
+	Meters    float64           `json:"meters"`
+	Reference AltitudeReference `json:"reference"`
+}
+
+// Schedule restricts a fence to recurring windows of local time, such as a
+// stadium every Saturday afternoon or school zones on weekdays.
+type Schedule struct {
+	TimeZone string       `json:"tz,omitempty"` // IANA name, e.g. "Europe/Berlin"; empty = UTC
+	Rules    []WeeklyRule `json:"rules"`        // The fence is active while any rule matches
+}
+
+// WeeklyRule is a daily window of local time on selected weekdays.
+// A window whose end is not after its start runs past midnight into the
+// following day, e.g. 22:00 to 06:00; equal start and end cover 24 hours.
+type WeeklyRule struct {
+	Days        []time.Weekday `json:"days,omitempty"` // Days the window starts on, empty = every day
+	StartMinute int            `json:"start_min"`      // Minutes after local midnight, 0-1439
+	EndMinute   int            `json:"end_min"`        // Minutes after local midnight, 0-1440
+}
+
+// AircraftCategory defines the kind of aircraft.
+type AircraftCategory int32
+
+const (
+	AircraftCategoryUnknown        AircraftCategory = 0
+	AircraftCategoryMultirotor     AircraftCategory = 1 // Multicopter
+	AircraftCategoryFixedWing      AircraftCategory = 2 // Aeroplane
+	AircraftCategoryHelicopter     AircraftCategory = 3 // Single main rotor
+	AircraftCategoryHybridVTOL     AircraftCategory = 4 // Vertical take-off, wing-borne cruise
+	AircraftCategoryLighterThanAir AircraftCategory = 5 // Balloon or airship
+)
+
+// String returns a human-readable representation of the aircraft category.
+func (c AircraftCategory) String() string {
+	switch c {
+	case AircraftCategoryMultirotor:
+		return "MULTIROTOR"
+	case AircraftCategoryFixedWing:
+		return "FIXED_WING"
+	case AircraftCategoryHelicopter:
+		return "HELICOPTER"
+	case AircraftCategoryHybridVTOL:
+		return "HYBRID_VTOL"
+	case AircraftCategoryLighterThanAir:
+		return "LIGHTER_THAN_AIR"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// OperationType defines the purpose of a flight.
+type OperationType int32
+
+const (
+	OperationTypeUnknown      OperationType = 0
+	OperationTypeRecreational OperationType = 1 // Hobby and leisure flights
+	OperationTypeCommercial   OperationType = 2 // Paid work such as surveys, inspection or delivery
+	OperationTypeEmergency    OperationType = 3 // Police, fire, rescue and medical services
+	OperationTypeGovernment   OperationType = 4 // State flights other than emergency services
+)
+
+// String returns a human-readable representation of the operation type.
+func (o OperationType) String() string {
+	switch o {
+	case OperationTypeRecreational:
+		return "RECREATIONAL"
+	case OperationTypeCommercial:
+		return "COMMERCIAL"
+	case OperationTypeEmergency:
+		return "EMERGENCY"
+	case OperationTypeGovernment:
+		return "GOVERNMENT"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Conditions restrict a fence to certain aircraft and operations, such as
+// "no recreational flights", "exempt: emergency services" or "applies to
+// aircraft above 25 kg". A fence applies to an aircraft only if it meets
+// every condition and holds no exemption.
+type Conditions struct {
+	Categories       []AircraftCategory `json:"categories,omitempty"`        // Applies to these categories only, empty = all
+	Operations       []OperationType    `json:"operations,omitempty"`        // Applies to these operations only, empty = all
+	ExemptOperations []OperationType    `json:"exempt_operations,omitempty"` // Operations the fence never applies to
+	MinMassKg        float64            `json:"min_mass_kg,omitempty"`       // Applies above this takeoff mass only, 0 = no lower bound
+	MaxMassKg        float64            `json:"max_mass_kg,omitempty"`       // Applies up to this takeoff mass only, 0 = no upper bound
+	ExemptionTokens  []string           `json:"exemption_tokens,omitempty"`  // Aircraft holding any of these tokens are exempt
+}
+
+// Aircraft is the profile of the aircraft and operation fences are checked
+// for. Zero fields are unknown.
+type Aircraft struct {
+	Category  AircraftCategory `json:"category"`
+	Operation OperationType    `json:"operation"`
+	MassKg    float64          `json:"mass_kg"`          // Takeoff mass, 0 = unknown
+	Tokens    []string         `json:"tokens,omitempty"` // Exemption tokens held by the operator
+	Serial    string           `json:"serial,omitempty"` // Device serial number unlock tokens are bound to
+}
+
+// UnlockToken authorizes one device to fly inside specific restricted
+// fences during a time window, such as a waiver granted to an enterprise
+// operator. It is signed by the publisher and only honored once its
+// signature has been verified.
+type UnlockToken struct {
+	ID        string       `json:"id"`
+	FenceIDs  []string     `json:"fence_ids,omitempty"` // Fences unlocked, empty = every fence within Area
+	Area      *BoundingBox `json:"area,omitempty"`      // Locations the token is valid at, nil = anywhere
+	NotBefore int64        `json:"not_before"`          // Unix timestamp in seconds
+	NotAfter  int64        `json:"not_after"`           // Unix timestamp in seconds
+	DeviceID  string       `json:"device_id"`           // Serial number of the authorized device
+	Holder    string       `json:"holder,omitempty"`    // Operator the waiver was granted to
+	IssuedAt  int64        `json:"issued_at"`           // Unix timestamp in seconds
+
+	// Signature fields
+	Signature []byte `json:"signature"` // Ed25519 signature
+	KeyID     string `json:"key_id"`    // Public key ID
+}
+
+// Waypoint is a planned vehicle position in space and time.
+type Waypoint struct {
+	Point    Point     `json:"point"`
+	Altitude Altitude  `json:"altitude"`
+	Time     time.Time `json:"time"` // Planned arrival, zero = evaluate at the current time
+}
+
+// ViolationReason describes why a route violates a fence.
+type ViolationReason string
+
+const (
+	ViolationNoFly        ViolationReason = "no_fly"        // Inside a no-fly volume
+	ViolationAboveCeiling ViolationReason = "above_ceiling" // Above an altitude limit
+	ViolationBelowFloor   ViolationReason = "below_floor"   // Below an altitude minimum
+	ViolationSpeed        ViolationReason = "speed"         // Planned ground speed exceeds a speed limit
+)
+
+// RouteViolation is a stretch of a route leg that violates a fence.
+type RouteViolation struct {
+	Leg       int             `json:"leg"` // Leg from route[Leg] to route[Leg+1]
+	Fence     FenceItem       `json:"fence"`
+	Reason    ViolationReason `json:"reason"`
+	Entry     Point           `json:"entry"`
+	Exit      Point           `json:"exit"`
+	EntryTime time.Time       `json:"entry_time"`
+	ExitTime  time.Time       `json:"exit_time"`
+}
+
+// Velocity is a vehicle velocity vector in meters per second.
+type Velocity struct {
+	North float64 `json:"north_mps"`
+	East  float64 `json:"east_mps"`
+	Up    float64 `json:"up_mps"`
+}
+
+// VehicleState is the current position and motion of a vehicle.
+type VehicleState struct {
+	Position Point     `json:"position"`
+	Altitude Altitude  `json:"altitude"`
+	Velocity Velocity  `json:"velocity"`
+	Time     time.Time `json:"time"` // Zero = the current time
+}
+
+// FenceProximity is a fence and its distance from a point.
+type FenceProximity struct {
+	Fence    *FenceItem `json:"fence"`
+	Distance float64    `json:"distance_m"` // 0 if the point is inside the fence
 }
 
 // FenceItem represents a single geofence restriction.
@@ -92,6 +376,9 @@ type FenceItem struct {
 	Priority    uint32    `json:"priority"`     // Higher = more important
 	MaxAltitude uint32    `json:"max_alt_m"`    // Max altitude in meters, 0 = no limit
 	MaxSpeed    uint32    `json:"max_speed_mps"` // Max speed in m/s, 0 = no limit
+	Altitude    *AltitudeBand `json:"altitude,omitempty"` // Vertical extent, nil = surface to unlimited
+	Schedule    *Schedule `json:"schedule,omitempty"` // Recurring windows within StartTS/EndTS, nil = always
+	Conditions  *Conditions `json:"conditions,omitempty"` // Aircraft and operations the fence applies to, nil = all
 	Name        string    `json:"name"`
 	Description string    `json:"description"`
 
@@ -100,7 +387,8 @@ type FenceItem struct {
 	KeyID     string `json:"key_id"`    // Public key ID
 }
 
-// IsActiveAt checks if the fence is active at the given time.
+// IsActiveAt checks if the fence is active at the given time: within its
+// StartTS/EndTS window and, if it has a schedule, within a scheduled window.
 func (f *FenceItem) IsActiveAt(t time.Time) bool {
 	ts := t.Unix()
 	if ts < f.StartTS {
@@ -109,7 +397,7 @@ func (f *FenceItem) IsActiveAt(t time.Time) bool {
 	if f.EndTS > 0 && ts > f.EndTS {
 		return false
 	}
-	return true
+	return f.Schedule.ActiveAt(t)
 }
 
 // IsActiveNow checks if the fence is currently active.
@@ -117,26 +405,30 @@ func (f *FenceItem) IsActiveNow() bool {
 	return f.IsActiveAt(time.Now())
 }
 
-// GetBounds returns the bounding box of this fence's geometry.
+// GetBounds returns the bounding box of this fence's geometry. The box
+// crosses the antimeridian if the geometry does, and covers all longitudes
+// if the geometry encloses a pole.
 func (f *FenceItem) GetBounds() BoundingBox {
 	if b := f.Geometry.BBox; b != nil {
 		return *b
 	}
 	if len(f.Geometry.Polygon) > 0 {
-		return boundsFromPoints(f.Geometry.Polygon)
-	}
-	if f.Geometry.CircleCenter != nil {
-		// Approximate bounds from circle
-		const approxLatDeg = 111000 // meters per degree latitude
-		latDelta := (f.Geometry.CircleRadius / approxLatDeg)
-		lonDelta := (f.Geometry.CircleRadius / approxLatDeg) /
-			cosDegrees(f.Geometry.CircleCenter.Latitude)
-		return BoundingBox{
-			MinLat: f.Geometry.CircleCenter.Latitude - latDelta,
-			MaxLat: f.Geometry.CircleCenter.Latitude + latDelta,
-			MinLon: f.Geometry.CircleCenter.Longitude - lonDelta,
-			MaxLon: f.Geometry.CircleCenter.Longitude + lonDelta,
+		// Holes lie inside the outer ring and never widen the bounds
+		return ringBounds(f.Geometry.Polygon, f.Geometry.Edges)
+	}
+	if len(f.Geometry.MultiPolygon) > 0 {
+		parts := make([]BoundingBox, len(f.Geometry.MultiPolygon))
+		for i, part := range f.Geometry.MultiPolygon {
+			parts[i] = ringBounds(part.Outer, f.Geometry.Edges)
 		}
+		return unionBounds(parts)
+	}
+	if f.Geometry.Corridor != nil {
+		return f.Geometry.Corridor.Bounds()
+	}
+	if c := f.Geometry.CircleCenter; c != nil {
+		// A circle is a corridor around a single point
+		return (&Corridor{Path: []Point{*c}, HalfWidth: f.Geometry.CircleRadius}).Bounds()
 	}
 	return BoundingBox{}
 }
@@ -168,15 +460,6 @@ func boundsFromPoints(points []Point) BoundingBox {
 	return b
 }
 
-func cosDegrees(deg float64) float64 {
-	// Approximate cos in degrees
-	const radPerDeg = 0.017453292519943295
-	rad := deg * radPerDeg
-	// Small angle approximation with correction
-	x := rad * rad
-	return 1 - x/2 + x*x/24
-}
-
 // FenceCollection represents a batch of fence items.
 type FenceCollection struct {
 	Items     []FenceItem `json:"items"`
@@ -191,6 +474,24 @@ type FenceDelta struct {
 	Updated    []FenceItem `json:"updated"`
 }
 
+// Format versions select the byte encoding fence and manifest signatures and
+// Merkle tree leaves are computed over. A manifest names the version used for
+// itself and the fences it publishes, so clients verify data of either
+// version while publishers move to the newer one.
+const (
+	// FormatVersionJSON is the encoding of MarshalBinaryForSigning, and JSON
+	// of the fence without its signature for Merkle leaves. It is implied
+	// by a FormatVersion of 0, as written by publishers predating versions.
+	FormatVersionJSON uint32 = 1
+
+	// FormatVersionProto is the deterministic protobuf encoding of the
+	// message with signature and key ID cleared.
+	FormatVersionProto uint32 = 2
+
+	// CurrentFormatVersion is the version new data is published in.
+	CurrentFormatVersion = FormatVersionProto
+)
+
 // Manifest represents the current state of the geofence database.
 type Manifest struct {
 	Version        uint64 `json:"version"`
@@ -204,8 +505,31 @@ type Manifest struct {
 	SnapshotHash   []byte `json:"snapshot_hash"`
 	MinClientV     uint32 `json:"min_client_version"`
 	Message        string `json:"message"`
+	FormatVersion  uint32 `json:"format_version,omitempty"` // Encoding signatures and Merkle leaves use, 0 = FormatVersionJSON
 	Signature      []byte `json:"signature"`
 	KeyID          string `json:"key_id"`
+	// Co-signatures by additional key holders, over the same data as Signature
+	Signatures []ManifestSignature `json:"signatures,omitempty"`
+	// Compressed copies of the snapshot and delta; the sizes and hashes
+	// above are those of the uncompressed files
+	SnapshotEncodings []FileEncoding `json:"snapshot_encodings,omitempty"`
+	DeltaEncodings    []FileEncoding `json:"delta_encodings,omitempty"`
+}
+
+// FileEncoding is a compressed copy of a published file, which clients may
+// download instead of the file.
+type FileEncoding struct {
+	Codec string `json:"codec"` // "gzip" or "zstd"
+	URL   string `json:"url"`
+	Size  uint64 `json:"size"`
+	Hash  []byte `json:"hash"` // SHA-256 of the compressed file
+}
+
+// ManifestSignature is a signature of a manifest and the ID of the key that
+// made it.
+type ManifestSignature struct {
+	Signature []byte `json:"signature"`
+	KeyID     string `json:"key_id"`
 }
 
 // CheckResult represents the result of checking if a location is allowed.
@@ -215,6 +539,37 @@ type CheckResult struct {
 	MatchingFences []FenceItem `json:"matching_fences"`
 }
 
+// ConstraintReason is the way a fence constrains flight at a location.
+type ConstraintReason string
+
+const (
+	ConstraintNoFly      ConstraintReason = "no_fly"      // Flight is forbidden
+	ConstraintCeiling    ConstraintReason = "ceiling"     // Flight is limited to below an altitude
+	ConstraintFloor      ConstraintReason = "floor"       // Flight is limited to above an altitude
+	ConstraintSpeedLimit ConstraintReason = "speed_limit" // Ground speed is limited
+)
+
+// Constraint is a fence that contributes to an Evaluation.
+type Constraint struct {
+	Fence      FenceItem        `json:"fence"`
+	Reason     ConstraintReason `json:"reason"`
+	Binding    bool             `json:"binding"`               // The fence sets the effective limit or forbids flight
+	UnlockedBy string           `json:"unlocked_by,omitempty"` // ID of the unlock token lifting the fence
+}
+
+// Evaluation is the combined effect of every fence that applies at a
+// location. See EvaluateFences for how conflicting fences are resolved.
+type Evaluation struct {
+	Allowed     bool           `json:"allowed"`               // False if flight is forbidden at every altitude
+	Authorized  bool           `json:"authorized,omitempty"`  // An unlock token lifted at least one fence
+	Restriction *FenceItem     `json:"restriction,omitempty"` // Highest-priority fence forbidding flight
+	Ceilings    []Altitude     `json:"ceilings,omitempty"`    // Effective ceiling per altitude reference
+	Floors      []Altitude     `json:"floors,omitempty"`      // Effective floor per altitude reference
+	Prohibited  []AltitudeBand `json:"prohibited,omitempty"`  // Bands in which banded no-fly fences forbid flight
+	SpeedLimit  uint32         `json:"max_speed_mps"`         // Effective speed limit in m/s, 0 = no limit
+	Constraints []Constraint   `json:"constraints"`           // Contributing fences, highest priority first
+}
+
 // UpdaterConfig is the configuration for the geofence updater.
 type UpdaterConfig struct {
 	ManifestURL string `json:"manifest_url"`
@@ -0,0 +1,150 @@
+package geofence
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// MarshalBinaryForSigning serializes the token to bytes for signing,
+// excluding the Signature and KeyID fields.
+func (t *UnlockToken) MarshalBinaryForSigning() ([]byte, error) {
+	copy := *t
+	copy.Signature = nil
+	copy.KeyID = ""
+
+	data, err := json.Marshal(copy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal unlock token: %w", err)
+	}
+	return data, nil
+}
+
+// SetSignature sets the signature on the token.
+func (t *UnlockToken) SetSignature(sig []byte, keyID string) {
+	t.Signature = sig
+	t.KeyID = keyID
+}
+
+// Validate checks that the token is bound to a device, has a time window
+// and is limited to specific fences or an area.
+func (t *UnlockToken) Validate() error {
+	if t.ID == "" {
+		return fmt.Errorf("missing token ID")
+	}
+	if t.DeviceID == "" {
+		return fmt.Errorf("token is not bound to a device")
+	}
+	if len(t.FenceIDs) == 0 && t.Area == nil {
+		return fmt.Errorf("token names neither fences nor an area")
+	}
+	if t.NotBefore < 0 {
+		return fmt.Errorf("negative start time: %d", t.NotBefore)
+	}
+	if t.NotAfter <= t.NotBefore {
+		return fmt.Errorf("end time %d is not after start time %d", t.NotAfter, t.NotBefore)
+	}
+	return nil
+}
+
+// Unlocks checks if the token lifts a fence for the device with the given
+// serial number at a location and time. It does not verify the signature.
+func (t *UnlockToken) Unlocks(f *FenceItem, p Point, serial string, at time.Time) bool {
+	if serial == "" || t.DeviceID != serial {
+		return false
+	}
+	if ts := at.Unix(); ts < t.NotBefore || ts > t.NotAfter {
+		return false
+	}
+	if len(t.FenceIDs) > 0 && !slices.Contains(t.FenceIDs, f.ID) {
+		return false
+	}
+	if t.Area != nil && !t.Area.Contains(p) {
+		return false
+	}
+	return true
+}
+
+// UnlocksRoute checks if the token lifts a fence for the device with the
+// given serial number along a whole route: every leg lies within the
+// token's area, and every waypoint's planned time, or now for waypoints
+// without one, lies within its time window. It does not verify the
+// signature.
+func (t *UnlockToken) UnlocksRoute(f *FenceItem, route []Waypoint, serial string, now time.Time) bool {
+	if len(route) == 0 || !t.Unlocks(f, route[0].Point, serial, waypointTime(route[0], now)) {
+		return false
+	}
+	for _, wp := range route[1:] {
+		if !t.Unlocks(f, wp.Point, serial, waypointTime(wp, now)) {
+			return false
+		}
+	}
+	if t.Area == nil {
+		return true
+	}
+	path := make([]Point, len(route))
+	for i, wp := range route {
+		path[i] = wp.Point
+	}
+	// Legs follow great circles, which may leave the area between waypoints
+	return containsBox(t.Area, (&Corridor{Path: path}).Bounds())
+}
+
+func waypointTime(wp Waypoint, now time.Time) time.Time {
+	if wp.Time.IsZero() {
+		return now
+	}
+	return wp.Time
+}
+
+// containsBox checks if box o lies entirely within box b. Either may cross
+// the antimeridian.
+func containsBox(b *BoundingBox, o BoundingBox) bool {
+	if o.MinLat < b.MinLat || o.MaxLat > b.MaxLat {
+		return false
+	}
+	span := func(minLon, maxLon float64) float64 {
+		if minLon > maxLon {
+			return maxLon - minLon + 360
+		}
+		return maxLon - minLon
+	}
+	offset := o.MinLon - b.MinLon
+	if offset < 0 {
+		offset += 360
+	}
+	return offset+span(o.MinLon, o.MaxLon) <= span(b.MinLon, b.MaxLon)
+}
+
+// EvaluateFencesWithTokens is like EvaluateFencesAt but lifts the fences
+// unlocked by any of the tokens for the device with the given serial
+// number. Unlocked fences are listed in Constraints with UnlockedBy set,
+// but neither forbid flight nor limit it, and Authorized is set. The
+// tokens' signatures must have been verified by the caller.
+func EvaluateFencesWithTokens(fences []FenceItem, p Point, t time.Time, tokens []UnlockToken, serial string) Evaluation {
+	return evaluateFences(fences, p, t, func(f *FenceItem) string {
+		for i := range tokens {
+			if tokens[i].Unlocks(f, p, serial, t) {
+				return tokens[i].ID
+			}
+		}
+		return ""
+	})
+}
+
+// constraintReason returns the way a fence constrains flight, and false if
+// it sets no limit.
+func constraintReason(f *FenceItem) (ConstraintReason, bool) {
+	switch f.Type {
+	case FenceTypePermanentNoFly, FenceTypeTempRestriction:
+		return ConstraintNoFly, true
+	case FenceTypeAltitudeLimit:
+		return ConstraintCeiling, ceilingOf(f) > 0
+	case FenceTypeAltitudeMinimum:
+		return ConstraintFloor, floorOf(f) > 0
+	case FenceTypeSpeedLimit:
+		return ConstraintSpeedLimit, f.MaxSpeed > 0
+	}
+	return "", false
+}
@@ -0,0 +1,194 @@
+package geofence
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func testUnlockToken() UnlockToken {
+	return UnlockToken{
+		ID:        "waiver-1",
+		FenceIDs:  []string{"stadium"},
+		NotBefore: 1700000000,
+		NotAfter:  1700086400,
+		DeviceID:  "SN-1234",
+		Holder:    "Acme Inspections",
+	}
+}
+
+func TestUnlockToken_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(tok *UnlockToken)
+		wantErr bool
+	}{
+		{"valid", func(tok *UnlockToken) {}, false},
+		{"area only", func(tok *UnlockToken) {
+			tok.FenceIDs = nil
+			tok.Area = &BoundingBox{MinLat: 0, MinLon: 0, MaxLat: 1, MaxLon: 1}
+		}, false},
+		{"missing id", func(tok *UnlockToken) { tok.ID = "" }, true},
+		{"no device", func(tok *UnlockToken) { tok.DeviceID = "" }, true},
+		{"no fences or area", func(tok *UnlockToken) { tok.FenceIDs = nil }, true},
+		{"end before start", func(tok *UnlockToken) { tok.NotAfter = tok.NotBefore }, true},
+		{"negative start", func(tok *UnlockToken) { tok.NotBefore = -1 }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tok := testUnlockToken()
+			tt.modify(&tok)
+			err := tok.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUnlockToken_Unlocks(t *testing.T) {
+	stadium := &FenceItem{ID: "stadium", Type: FenceTypeTempRestriction}
+	airport := &FenceItem{ID: "airport", Type: FenceTypePermanentNoFly}
+	inside := Point{Latitude: 0.5, Longitude: 0.5}
+	outside := Point{Latitude: 2, Longitude: 2}
+	during := time.Unix(1700040000, 0)
+
+	byArea := testUnlockToken()
+	byArea.FenceIDs = nil
+	byArea.Area = &BoundingBox{MinLat: 0, MinLon: 0, MaxLat: 1, MaxLon: 1}
+
+	tests := []struct {
+		name   string
+		token  UnlockToken
+		fence  *FenceItem
+		point  Point
+		serial string
+		at     time.Time
+		want   bool
+	}{
+		{"listed fence", testUnlockToken(), stadium, inside, "SN-1234", during, true},
+		{"other fence", testUnlockToken(), airport, inside, "SN-1234", during, false},
+		{"other device", testUnlockToken(), stadium, inside, "SN-9999", during, false},
+		{"no serial", testUnlockToken(), stadium, inside, "", during, false},
+		{"before window", testUnlockToken(), stadium, inside, "SN-1234", time.Unix(1699999999, 0), false},
+		{"after window", testUnlockToken(), stadium, inside, "SN-1234", time.Unix(1700086401, 0), false},
+		{"any fence in area", byArea, airport, inside, "SN-1234", during, true},
+		{"outside area", byArea, airport, outside, "SN-1234", during, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.token.Unlocks(tt.fence, tt.point, tt.serial, tt.at); got != tt.want {
+				t.Errorf("Unlocks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnlockToken_UnlocksRoute(t *testing.T) {
+	stadium := &FenceItem{ID: "stadium", Type: FenceTypeTempRestriction}
+	now := time.Unix(1700040000, 0)
+
+	byArea := testUnlockToken()
+	byArea.Area = &BoundingBox{MinLat: 0, MinLon: 0, MaxLat: 1, MaxLon: 1}
+	acrossAntimeridian := testUnlockToken()
+	acrossAntimeridian.Area = &BoundingBox{MinLat: 0, MinLon: 179, MaxLat: 1, MaxLon: -179}
+
+	route := func(points ...Point) []Waypoint {
+		wps := make([]Waypoint, len(points))
+		for i, p := range points {
+			wps[i] = Waypoint{Point: p}
+		}
+		return wps
+	}
+
+	late := route(Point{Latitude: 0.5, Longitude: 0.2}, Point{Latitude: 0.5, Longitude: 0.8})
+	late[1].Time = time.Unix(1700086401, 0)
+
+	tests := []struct {
+		name  string
+		token UnlockToken
+		route []Waypoint
+		want  bool
+	}{
+		{"anywhere", testUnlockToken(), route(Point{Latitude: 10, Longitude: 10}, Point{Latitude: 20, Longitude: 20}), true},
+		{"within area", byArea, route(Point{Latitude: 0.5, Longitude: 0.2}, Point{Latitude: 0.5, Longitude: 0.8}), true},
+		{"leaves area", byArea, route(Point{Latitude: 0.5, Longitude: 0.2}, Point{Latitude: 0.5, Longitude: 1.5}), false},
+		{"across antimeridian", acrossAntimeridian, route(Point{Latitude: 0.5, Longitude: 179.5}, Point{Latitude: 0.5, Longitude: -179.5}), true},
+		{"arrives after window", byArea, late, false},
+		{"empty route", testUnlockToken(), nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.token.UnlocksRoute(stadium, tt.route, "SN-1234", now); got != tt.want {
+				t.Errorf("UnlocksRoute() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnlockToken_MarshalBinaryForSigning(t *testing.T) {
+	tok := testUnlockToken()
+	unsigned, err := tok.MarshalBinaryForSigning()
+	if err != nil {
+		t.Fatalf("MarshalBinaryForSigning failed: %v", err)
+	}
+
+	tok.SetSignature([]byte("signature"), "key")
+	signed, err := tok.MarshalBinaryForSigning()
+	if err != nil {
+		t.Fatalf("MarshalBinaryForSigning failed: %v", err)
+	}
+	if !bytes.Equal(unsigned, signed) {
+		t.Error("signing data should not depend on the signature")
+	}
+
+	tok.NotAfter++
+	extended, err := tok.MarshalBinaryForSigning()
+	if err != nil {
+		t.Fatalf("MarshalBinaryForSigning failed: %v", err)
+	}
+	if bytes.Equal(signed, extended) {
+		t.Error("signing data should cover the time window")
+	}
+}
+
+func TestEvaluateFencesWithTokens(t *testing.T) {
+	square := Geometry{BBox: &BoundingBox{MinLat: 0, MinLon: 0, MaxLat: 1, MaxLon: 1}}
+	fences := []FenceItem{
+		{ID: "stadium", Type: FenceTypeTempRestriction, Geometry: square, Priority: 50},
+		{ID: "ceiling", Type: FenceTypeAltitudeLimit, Geometry: square, MaxAltitude: 120},
+	}
+	p := Point{Latitude: 0.5, Longitude: 0.5}
+	at := time.Unix(1700040000, 0)
+	tokens := []UnlockToken{testUnlockToken()}
+
+	t.Run("authorized device", func(t *testing.T) {
+		ev := EvaluateFencesWithTokens(fences, p, at, tokens, "SN-1234")
+		if !ev.Allowed || !ev.Authorized || ev.Restriction != nil {
+			t.Errorf("Allowed = %v, Authorized = %v, Restriction = %v, want authorized", ev.Allowed, ev.Authorized, ev.Restriction)
+		}
+		if ceiling, ok := ev.Ceiling(AltitudeReferenceAGL); !ok || ceiling != 120 {
+			t.Errorf("Ceiling = %v, %v, want 120 from the fence not unlocked", ceiling, ok)
+		}
+
+		var unlocked *Constraint
+		for i := range ev.Constraints {
+			if ev.Constraints[i].Fence.ID == "stadium" {
+				unlocked = &ev.Constraints[i]
+			}
+		}
+		if unlocked == nil || unlocked.UnlockedBy != "waiver-1" || unlocked.Binding || unlocked.Reason != ConstraintNoFly {
+			t.Errorf("stadium constraint = %+v, want unlocked by waiver-1", unlocked)
+		}
+	})
+
+	t.Run("other device", func(t *testing.T) {
+		ev := EvaluateFencesWithTokens(fences, p, at, tokens, "SN-9999")
+		if ev.Allowed || ev.Authorized {
+			t.Errorf("Allowed = %v, Authorized = %v, want forbidden", ev.Allowed, ev.Authorized)
+		}
+	})
+}
@@ -0,0 +1,481 @@
+// Package geojson converts geofences to and from GeoJSON (RFC 7946), so that
+// restrictions can be authored in GIS tools and exchanged with authorities.
+//
+// Each fence maps to a Feature. The Feature id is the fence ID and the fence
+// attributes are carried in its properties. Geometries map as follows:
+//
+//   - Polygon: the first ring is the outer boundary, further rings are holes.
+//   - MultiPolygon: one part per polygon.
+//   - Point with a "radius_m" property: a circle.
+//   - LineString with a "half_width_m" property: a corridor.
+//
+// Exported rings are closed and follow the right-hand rule, so a clockwise
+// ring comes back reversed. Bounding box fences are exported as rectangular
+// Polygons. Signatures are not exported; imported fences have to be signed
+// before publishing.
+package geojson
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/iannil/geofence-updater-lite/pkg/geofence"
+)
+
+// GeoJSON object and geometry types.
+const (
+	TypeFeatureCollection = "FeatureCollection"
+	TypeFeature           = "Feature"
+	TypePoint             = "Point"
+	TypeLineString        = "LineString"
+	TypePolygon           = "Polygon"
+	TypeMultiPolygon      = "MultiPolygon"
+)
+
+// FeatureCollection is a GeoJSON FeatureCollection object.
+type FeatureCollection struct {
+	Type     string     `json:"type"`
+	Features []*Feature `json:"features"`
+}
+
+// Feature is a GeoJSON Feature object describing a single fence.
+type Feature struct {
+	Type       string     `json:"type"`
+	ID         any        `json:"id,omitempty"` // String or number
+	Geometry   *Geometry  `json:"geometry"`
+	Properties Properties `json:"properties"`
+}
+
+// Geometry is a GeoJSON geometry object. Coordinates are decoded according
+// to Type.
+type Geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// Properties are the fence attributes carried in a Feature.
+type Properties struct {
+	Type        string                 `json:"type"` // Fence type name, e.g. "PERMANENT_NO_FLY"
+	Name        string                 `json:"name,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	StartTS     int64                  `json:"start_ts,omitempty"`
+	EndTS       int64                  `json:"end_ts,omitempty"`
+	Priority    uint32                 `json:"priority,omitempty"`
+	MaxAltitude uint32                 `json:"max_alt_m,omitempty"`
+	MaxSpeed    uint32                 `json:"max_speed_mps,omitempty"`
+	Altitude    *geofence.AltitudeBand `json:"altitude,omitempty"`
+	Schedule    *geofence.Schedule     `json:"schedule,omitempty"`
+	Conditions  *geofence.Conditions   `json:"conditions,omitempty"`
+	Radius      float64                `json:"radius_m,omitempty"`     // Circle radius of a Point
+	HalfWidth   float64                `json:"half_width_m,omitempty"` // Corridor half-width of a LineString
+	Edges       string                 `json:"edges,omitempty"`        // Polygon edge type name, "RHUMB" if empty
+}
+
+// Decode parses a GeoJSON FeatureCollection or single Feature into fences.
+func Decode(data []byte) ([]geofence.FenceItem, error) {
+	var head struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return nil, fmt.Errorf("failed to parse GeoJSON: %w", err)
+	}
+
+	switch head.Type {
+	case TypeFeatureCollection:
+		var fc FeatureCollection
+		if err := json.Unmarshal(data, &fc); err != nil {
+			return nil, fmt.Errorf("failed to parse feature collection: %w", err)
+		}
+		return fc.Fences()
+	case TypeFeature:
+		var f Feature
+		if err := json.Unmarshal(data, &f); err != nil {
+			return nil, fmt.Errorf("failed to parse feature: %w", err)
+		}
+		fence, err := f.Fence()
+		if err != nil {
+			return nil, err
+		}
+		return []geofence.FenceItem{fence}, nil
+	default:
+		return nil, fmt.Errorf("unsupported GeoJSON type: %q", head.Type)
+	}
+}
+
+// Encode writes fences as a GeoJSON FeatureCollection.
+func Encode(fences []geofence.FenceItem) ([]byte, error) {
+	fc, err := FromFences(fences)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(fc)
+}
+
+// FromFences converts fences to a FeatureCollection.
+func FromFences(fences []geofence.FenceItem) (*FeatureCollection, error) {
+	fc := &FeatureCollection{
+		Type:     TypeFeatureCollection,
+		Features: make([]*Feature, 0, len(fences)),
+	}
+	for i := range fences {
+		f, err := FeatureFromFence(&fences[i])
+		if err != nil {
+			return nil, err
+		}
+		fc.Features = append(fc.Features, f)
+	}
+	return fc, nil
+}
+
+// Fences converts every feature of the collection to a fence.
+func (fc *FeatureCollection) Fences() ([]geofence.FenceItem, error) {
+	fences := make([]geofence.FenceItem, 0, len(fc.Features))
+	for i, f := range fc.Features {
+		if f == nil {
+			return nil, fmt.Errorf("feature %d: null feature", i)
+		}
+		fence, err := f.Fence()
+		if err != nil {
+			return nil, fmt.Errorf("feature %d: %w", i, err)
+		}
+		fences = append(fences, fence)
+	}
+	return fences, nil
+}
+
+// FeatureFromFence converts a fence to a Feature.
+func FeatureFromFence(fence *geofence.FenceItem) (*Feature, error) {
+	f := &Feature{
+		Type: TypeFeature,
+		ID:   fence.ID,
+		Properties: Properties{
+			Type:        fence.Type.String(),
+			Name:        fence.Name,
+			Description: fence.Description,
+			StartTS:     fence.StartTS,
+			EndTS:       fence.EndTS,
+			Priority:    fence.Priority,
+			MaxAltitude: fence.MaxAltitude,
+			MaxSpeed:    fence.MaxSpeed,
+			Altitude:    fence.Altitude,
+			Schedule:    fence.Schedule,
+			Conditions:  fence.Conditions,
+		},
+	}
+
+	var coords any
+	g := &fence.Geometry
+	switch {
+	case g.BBox != nil:
+		f.Geometry = &Geometry{Type: TypePolygon}
+		coords = [][][]float64{encodeRing(bboxRing(g.BBox), true)}
+	case len(g.Polygon) > 0:
+		f.Geometry = &Geometry{Type: TypePolygon}
+		coords = encodePolygon(g.Polygon, g.Holes)
+		f.Properties.Edges = edgeTypeName(g.Edges)
+	case len(g.MultiPolygon) > 0:
+		f.Geometry = &Geometry{Type: TypeMultiPolygon}
+		parts := make([][][][]float64, len(g.MultiPolygon))
+		for i, part := range g.MultiPolygon {
+			parts[i] = encodePolygon(part.Outer, part.Holes)
+		}
+		coords = parts
+		f.Properties.Edges = edgeTypeName(g.Edges)
+	case g.Corridor != nil:
+		f.Geometry = &Geometry{Type: TypeLineString}
+		line := make([][]float64, len(g.Corridor.Path))
+		for i, p := range g.Corridor.Path {
+			line[i] = encodePosition(p)
+		}
+		coords = line
+		f.Properties.HalfWidth = g.Corridor.HalfWidth
+	case g.CircleCenter != nil:
+		f.Geometry = &Geometry{Type: TypePoint}
+		coords = encodePosition(*g.CircleCenter)
+		f.Properties.Radius = g.CircleRadius
+	default:
+		return nil, fmt.Errorf("fence %s has no geometry", fence.ID)
+	}
+
+	data, err := json.Marshal(coords)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal coordinates: %w", err)
+	}
+	f.Geometry.Coordinates = data
+	return f, nil
+}
+
+// Fence converts the feature to a fence. The fence is not signed.
+func (f *Feature) Fence() (geofence.FenceItem, error) {
+	var fence geofence.FenceItem
+
+	if f.Type != TypeFeature {
+		return fence, fmt.Errorf("unsupported GeoJSON type: %q", f.Type)
+	}
+
+	id, err := featureID(f.ID)
+	if err != nil {
+		return fence, err
+	}
+
+	fenceType, err := parseFenceType(f.Properties.Type)
+	if err != nil {
+		return fence, fmt.Errorf("fence %s: %w", id, err)
+	}
+
+	p := f.Properties
+	fence = geofence.FenceItem{
+		ID:          id,
+		Type:        fenceType,
+		StartTS:     p.StartTS,
+		EndTS:       p.EndTS,
+		Priority:    p.Priority,
+		MaxAltitude: p.MaxAltitude,
+		MaxSpeed:    p.MaxSpeed,
+		Altitude:    p.Altitude,
+		Schedule:    p.Schedule,
+		Conditions:  p.Conditions,
+		Name:        p.Name,
+		Description: p.Description,
+	}
+
+	if fence.Geometry, err = f.decodeGeometry(); err != nil {
+		return geofence.FenceItem{}, fmt.Errorf("fence %s: %w", id, err)
+	}
+	if fence.Geometry.Edges, err = parseEdgeType(p.Edges); err != nil {
+		return geofence.FenceItem{}, fmt.Errorf("fence %s: %w", id, err)
+	}
+	return fence, nil
+}
+
+// decodeGeometry converts the feature geometry to a fence geometry.
+func (f *Feature) decodeGeometry() (geofence.Geometry, error) {
+	var g geofence.Geometry
+	if f.Geometry == nil {
+		return g, fmt.Errorf("missing geometry")
+	}
+
+	switch f.Geometry.Type {
+	case TypePolygon:
+		var rings [][][]float64
+		if err := json.Unmarshal(f.Geometry.Coordinates, &rings); err != nil {
+			return g, fmt.Errorf("invalid polygon coordinates: %w", err)
+		}
+		outer, holes, err := decodePolygon(rings)
+		if err != nil {
+			return g, err
+		}
+		g.Polygon, g.Holes = outer, holes
+
+	case TypeMultiPolygon:
+		var polygons [][][][]float64
+		if err := json.Unmarshal(f.Geometry.Coordinates, &polygons); err != nil {
+			return g, fmt.Errorf("invalid multi-polygon coordinates: %w", err)
+		}
+		if len(polygons) == 0 {
+			return g, fmt.Errorf("multi-polygon has no polygons")
+		}
+		for i, rings := range polygons {
+			outer, holes, err := decodePolygon(rings)
+			if err != nil {
+				return g, fmt.Errorf("polygon %d: %w", i, err)
+			}
+			g.MultiPolygon = append(g.MultiPolygon, geofence.PolygonPart{Outer: outer, Holes: holes})
+		}
+
+	case TypePoint:
+		var pos []float64
+		if err := json.Unmarshal(f.Geometry.Coordinates, &pos); err != nil {
+			return g, fmt.Errorf("invalid point coordinates: %w", err)
+		}
+		if f.Properties.Radius <= 0 {
+			return g, fmt.Errorf("point geometry needs a positive radius_m property")
+		}
+		center, err := decodePosition(pos)
+		if err != nil {
+			return g, err
+		}
+		g.CircleCenter = &center
+		g.CircleRadius = f.Properties.Radius
+
+	case TypeLineString:
+		var line [][]float64
+		if err := json.Unmarshal(f.Geometry.Coordinates, &line); err != nil {
+			return g, fmt.Errorf("invalid line string coordinates: %w", err)
+		}
+		if f.Properties.HalfWidth <= 0 {
+			return g, fmt.Errorf("line string geometry needs a positive half_width_m property")
+		}
+		if len(line) < 2 {
+			return g, fmt.Errorf("line string needs at least 2 positions, got %d", len(line))
+		}
+		path := make([]geofence.Point, len(line))
+		for i, pos := range line {
+			p, err := decodePosition(pos)
+			if err != nil {
+				return g, err
+			}
+			path[i] = p
+		}
+		g.Corridor = &geofence.Corridor{Path: path, HalfWidth: f.Properties.HalfWidth}
+
+	default:
+		return g, fmt.Errorf("unsupported geometry type: %q", f.Geometry.Type)
+	}
+
+	return g, nil
+}
+
+// featureID returns the fence ID of a Feature id member.
+func featureID(id any) (string, error) {
+	switch v := id.(type) {
+	case string:
+		if v == "" {
+			return "", fmt.Errorf("feature has an empty id")
+		}
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case nil:
+		return "", fmt.Errorf("feature has no id")
+	default:
+		return "", fmt.Errorf("unsupported feature id: %v", id)
+	}
+}
+
+// parseFenceType returns the fence type with the given name.
+func parseFenceType(name string) (geofence.FenceType, error) {
+	for t := geofence.FenceTypeTempRestriction; t <= geofence.FenceTypeSpeedLimit; t++ {
+		if t.String() == name {
+			return t, nil
+		}
+	}
+	return geofence.FenceTypeUnknown, fmt.Errorf("unknown fence type: %q", name)
+}
+
+// parseEdgeType returns the edge type with the given name; an empty name is
+// a rhumb line.
+func parseEdgeType(name string) (geofence.EdgeType, error) {
+	switch name {
+	case "", geofence.EdgeTypeRhumb.String():
+		return geofence.EdgeTypeRhumb, nil
+	case geofence.EdgeTypeGreatCircle.String():
+		return geofence.EdgeTypeGreatCircle, nil
+	}
+	return geofence.EdgeTypeRhumb, fmt.Errorf("unknown edge type: %q", name)
+}
+
+// edgeTypeName returns the edges property of an edge type, omitted for the
+// default rhumb lines.
+func edgeTypeName(e geofence.EdgeType) string {
+	if e == geofence.EdgeTypeRhumb {
+		return ""
+	}
+	return e.String()
+}
+
+// decodePolygon converts GeoJSON polygon rings to an outer ring and holes.
+func decodePolygon(rings [][][]float64) ([]geofence.Point, [][]geofence.Point, error) {
+	if len(rings) == 0 {
+		return nil, nil, fmt.Errorf("polygon has no rings")
+	}
+	outer, err := decodeRing(rings[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("outer ring: %w", err)
+	}
+	var holes [][]geofence.Point
+	for i, ring := range rings[1:] {
+		hole, err := decodeRing(ring)
+		if err != nil {
+			return nil, nil, fmt.Errorf("hole %d: %w", i, err)
+		}
+		holes = append(holes, hole)
+	}
+	return outer, holes, nil
+}
+
+// decodeRing converts a closed GeoJSON linear ring to the implicitly closed
+// rings used by fences.
+func decodeRing(ring [][]float64) ([]geofence.Point, error) {
+	if len(ring) < 4 {
+		return nil, fmt.Errorf("linear ring needs at least 4 positions, got %d", len(ring))
+	}
+	points := make([]geofence.Point, len(ring))
+	for i, pos := range ring {
+		p, err := decodePosition(pos)
+		if err != nil {
+			return nil, err
+		}
+		points[i] = p
+	}
+	if points[0] != points[len(points)-1] {
+		return nil, fmt.Errorf("linear ring is not closed")
+	}
+	return points[:len(points)-1], nil
+}
+
+// decodePosition converts a [longitude, latitude(, altitude)] position.
+func decodePosition(pos []float64) (geofence.Point, error) {
+	if len(pos) < 2 {
+		return geofence.Point{}, fmt.Errorf("position needs at least 2 values, got %d", len(pos))
+	}
+	return geofence.NewPoint(pos[1], pos[0])
+}
+
+// encodePolygon converts an outer ring and holes to GeoJSON polygon rings.
+func encodePolygon(outer []geofence.Point, holes [][]geofence.Point) [][][]float64 {
+	rings := make([][][]float64, 0, 1+len(holes))
+	rings = append(rings, encodeRing(outer, true))
+	for _, hole := range holes {
+		rings = append(rings, encodeRing(hole, false))
+	}
+	return rings
+}
+
+// bboxRing returns the ring of a bounding box. Ring edges take the short way
+// around in longitude, so boxes spanning 180° or more get extra vertices
+// along their north and south edges.
+func bboxRing(b *geofence.BoundingBox) []geofence.Point {
+	span := b.MaxLon - b.MinLon
+	if b.CrossesAntimeridian() {
+		span += 360
+	}
+	steps := 1
+	if span >= 180 {
+		steps = 3
+	}
+
+	ring := make([]geofence.Point, 0, 2*steps+2)
+	for i := 0; i <= steps; i++ {
+		lon := geofence.NormalizeLongitude(b.MinLon + span*float64(i)/float64(steps))
+		switch i {
+		case 0:
+			lon = b.MinLon
+		case steps:
+			lon = b.MaxLon
+		}
+		ring = append(ring, geofence.Point{Latitude: b.MinLat, Longitude: lon})
+	}
+	for i := steps; i >= 0; i-- {
+		ring = append(ring, geofence.Point{Latitude: b.MaxLat, Longitude: ring[i].Longitude})
+	}
+	return ring
+}
+
+// encodeRing closes a ring and orients it by the right-hand rule of RFC 7946:
+// counterclockwise for outer rings, clockwise for holes.
+func encodeRing(ring []geofence.Point, outer bool) [][]float64 {
+	coords := make([][]float64, 0, len(ring)+1)
+	for _, p := range geofence.OrientRing(ring, outer) {
+		coords = append(coords, encodePosition(p))
+	}
+	if len(coords) > 0 {
+		coords = append(coords, coords[0])
+	}
+	return coords
+}
+
+func encodePosition(p geofence.Point) []float64 {
+	return []float64{p.Longitude, p.Latitude}
+}
@@ -0,0 +1,343 @@
+package geojson
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iannil/geofence-updater-lite/pkg/geofence"
+)
+
+const qgisExport = `{
+  "type": "FeatureCollection",
+  "name": "restrictions",
+  "crs": { "type": "name", "properties": { "name": "urn:ogc:def:crs:OGC:1.3:CRS84" } },
+  "features": [
+    {
+      "type": "Feature",
+      "id": "airport-001",
+      "properties": { "type": "PERMANENT_NO_FLY", "name": "Airport", "priority": 100 },
+      "geometry": {
+        "type": "Polygon",
+        "coordinates": [
+          [[116.4, 39.9], [116.5, 39.9], [116.5, 40.0], [116.4, 40.0], [116.4, 39.9]],
+          [[116.44, 39.94], [116.44, 39.96], [116.46, 39.96], [116.46, 39.94], [116.44, 39.94]]
+        ]
+      }
+    },
+    {
+      "type": "Feature",
+      "id": 42,
+      "properties": { "type": "TEMP_RESTRICTION", "radius_m": 500, "start_ts": 1700000000, "end_ts": 1700086400 },
+      "geometry": { "type": "Point", "coordinates": [121.47, 31.23, 12.5] }
+    },
+    {
+      "type": "Feature",
+      "id": "islands",
+      "properties": { "type": "ALTITUDE_LIMIT", "max_alt_m": 60 },
+      "geometry": {
+        "type": "MultiPolygon",
+        "coordinates": [
+          [[[0, 0], [1, 0], [1, 1], [0, 0]]],
+          [[[2, 0], [3, 0], [3, 1], [2, 0]]]
+        ]
+      }
+    }
+  ]
+}`
+
+func TestDecode(t *testing.T) {
+	fences, err := Decode([]byte(qgisExport))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if len(fences) != 3 {
+		t.Fatalf("fences = %d, want 3", len(fences))
+	}
+
+	airport := fences[0]
+	if airport.ID != "airport-001" || airport.Type != geofence.FenceTypePermanentNoFly || airport.Priority != 100 {
+		t.Errorf("airport = %+v", airport)
+	}
+	if len(airport.Geometry.Polygon) != 4 {
+		t.Errorf("outer ring = %d points, want 4 (closing point dropped)", len(airport.Geometry.Polygon))
+	}
+	if len(airport.Geometry.Holes) != 1 || len(airport.Geometry.Holes[0]) != 4 {
+		t.Errorf("holes = %v, want one ring of 4 points", airport.Geometry.Holes)
+	}
+	if want := (geofence.Point{Latitude: 39.9, Longitude: 116.4}); airport.Geometry.Polygon[0] != want {
+		t.Errorf("first vertex = %v, want %v", airport.Geometry.Polygon[0], want)
+	}
+
+	circle := fences[1]
+	if circle.ID != "42" {
+		t.Errorf("numeric id = %q, want \"42\"", circle.ID)
+	}
+	if circle.Geometry.CircleCenter == nil || circle.Geometry.CircleRadius != 500 {
+		t.Errorf("circle geometry = %+v", circle.Geometry)
+	}
+	if circle.StartTS != 1700000000 || circle.EndTS != 1700086400 {
+		t.Errorf("time window = %d-%d", circle.StartTS, circle.EndTS)
+	}
+
+	islands := fences[2]
+	if len(islands.Geometry.MultiPolygon) != 2 || islands.MaxAltitude != 60 {
+		t.Errorf("islands = %+v", islands)
+	}
+}
+
+func TestDecode_SingleFeature(t *testing.T) {
+	data := `{
+		"type": "Feature",
+		"id": "route-1",
+		"properties": { "type": "TEMP_RESTRICTION", "half_width_m": 50 },
+		"geometry": { "type": "LineString", "coordinates": [[116.3, 39.9], [116.4, 39.95]] }
+	}`
+
+	fences, err := Decode([]byte(data))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if len(fences) != 1 {
+		t.Fatalf("fences = %d, want 1", len(fences))
+	}
+	c := fences[0].Geometry.Corridor
+	if c == nil || len(c.Path) != 2 || c.HalfWidth != 50 {
+		t.Errorf("corridor = %+v", c)
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	feature := func(id, props, geometry string) string {
+		return `{"type": "Feature", "id": ` + id + `, "properties": ` + props + `, "geometry": ` + geometry + `}`
+	}
+	square := `{"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 1], [0, 1], [0, 0]]]}`
+	noFly := `{"type": "PERMANENT_NO_FLY"}`
+
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"not json", `{`, "failed to parse GeoJSON"},
+		{"geometry only", square, "unsupported GeoJSON type"},
+		{"missing id", feature(`null`, noFly, square), "no id"},
+		{"unknown fence type", feature(`"a"`, `{"type": "NO_DRONES"}`, square), "unknown fence type"},
+		{"unknown edge type", feature(`"a"`, `{"type": "PERMANENT_NO_FLY", "edges": "GEODESIC"}`, square), "unknown edge type"},
+		{"missing geometry", feature(`"a"`, noFly, `null`), "missing geometry"},
+		{"unclosed ring", feature(`"a"`, noFly, `{"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 1], [0, 1]]]}`), "not closed"},
+		{"short ring", feature(`"a"`, noFly, `{"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [0, 0]]]}`), "at least 4 positions"},
+		{"latitude out of range", feature(`"a"`, noFly, `{"type": "Polygon", "coordinates": [[[0, 0], [0, 91], [1, 1], [0, 0]]]}`), "latitude out of range"},
+		{"point without radius", feature(`"a"`, noFly, `{"type": "Point", "coordinates": [0, 0]}`), "radius_m"},
+		{"line without width", feature(`"a"`, noFly, `{"type": "LineString", "coordinates": [[0, 0], [1, 1]]}`), "half_width_m"},
+		{"unsupported geometry", feature(`"a"`, noFly, `{"type": "MultiPoint", "coordinates": [[0, 0]]}`), "unsupported geometry type"},
+		{"bad feature in collection", `{"type": "FeatureCollection", "features": [` + feature(`"a"`, noFly, square) + `, ` + feature(`"b"`, noFly, `null`) + `]}`, "feature 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode([]byte(tt.data))
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeDecode_RoundTrip(t *testing.T) {
+	fences := []geofence.FenceItem{
+		{
+			ID:          "polygon",
+			Type:        geofence.FenceTypePermanentNoFly,
+			Priority:    100,
+			Name:        "Airport",
+			Description: "Permanent restriction",
+			Altitude:    &geofence.AltitudeBand{Floor: 0, Ceiling: 300, Reference: geofence.AltitudeReferenceMSL},
+			Geometry: geofence.Geometry{
+				Polygon: []geofence.Point{
+					{Latitude: 39.9, Longitude: 116.4},
+					{Latitude: 39.9, Longitude: 116.5},
+					{Latitude: 40.0, Longitude: 116.5},
+					{Latitude: 40.0, Longitude: 116.4},
+				},
+				Holes: [][]geofence.Point{{
+					{Latitude: 39.94, Longitude: 116.44},
+					{Latitude: 39.96, Longitude: 116.44},
+					{Latitude: 39.96, Longitude: 116.46},
+					{Latitude: 39.94, Longitude: 116.46},
+				}},
+			},
+		},
+		{
+			ID:          "multi",
+			Type:        geofence.FenceTypeAltitudeLimit,
+			Priority:    30,
+			MaxAltitude: 60,
+			Geometry: geofence.Geometry{
+				MultiPolygon: []geofence.PolygonPart{
+					{Outer: []geofence.Point{{Latitude: 0, Longitude: 0}, {Latitude: 0, Longitude: 1}, {Latitude: 1, Longitude: 1}}},
+					{Outer: []geofence.Point{{Latitude: 0, Longitude: 2}, {Latitude: 0, Longitude: 3}, {Latitude: 1, Longitude: 3}}},
+				},
+				Edges: geofence.EdgeTypeGreatCircle,
+			},
+		},
+		{
+			ID:       "circle",
+			Type:     geofence.FenceTypeTempRestriction,
+			StartTS:  1700000000,
+			EndTS:    1700086400,
+			Priority: 50,
+			Schedule: &geofence.Schedule{
+				TimeZone: "Asia/Shanghai",
+				Rules:    []geofence.WeeklyRule{{Days: []time.Weekday{time.Saturday}, StartMinute: 840, EndMinute: 1080}},
+			},
+			Geometry: geofence.Geometry{
+				CircleCenter: &geofence.Point{Latitude: 31.23, Longitude: 121.47},
+				CircleRadius: 500,
+			},
+		},
+		{
+			ID:       "corridor",
+			Type:     geofence.FenceTypeSpeedLimit,
+			MaxSpeed: 10,
+			Geometry: geofence.Geometry{
+				Corridor: &geofence.Corridor{
+					Path:      []geofence.Point{{Latitude: 39.9, Longitude: 116.3}, {Latitude: 39.95, Longitude: 116.4}},
+					HalfWidth: 50,
+				},
+			},
+		},
+	}
+
+	data, err := Encode(fences)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	decoded, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(decoded, fences) {
+		t.Errorf("round trip mismatch:\n got  %+v\n want %+v", decoded, fences)
+	}
+}
+
+func TestEncode_RingOrientation(t *testing.T) {
+	// Clockwise outer ring and counterclockwise hole
+	fence := geofence.FenceItem{
+		ID:   "cw",
+		Type: geofence.FenceTypePermanentNoFly,
+		Geometry: geofence.Geometry{
+			Polygon: []geofence.Point{
+				{Latitude: 0, Longitude: 0},
+				{Latitude: 10, Longitude: 0},
+				{Latitude: 10, Longitude: 10},
+				{Latitude: 0, Longitude: 10},
+			},
+			Holes: [][]geofence.Point{{
+				{Latitude: 4, Longitude: 4},
+				{Latitude: 4, Longitude: 6},
+				{Latitude: 6, Longitude: 6},
+				{Latitude: 6, Longitude: 4},
+			}},
+		},
+	}
+
+	f, err := FeatureFromFence(&fence)
+	if err != nil {
+		t.Fatalf("FeatureFromFence failed: %v", err)
+	}
+
+	var rings [][][]float64
+	if err := json.Unmarshal(f.Geometry.Coordinates, &rings); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	for i, ring := range rings {
+		if first, last := ring[0], ring[len(ring)-1]; first[0] != last[0] || first[1] != last[1] {
+			t.Errorf("ring %d is not closed", i)
+		}
+		if ring[0][0] != [][]float64{{0, 0}, {4, 4}}[i][0] {
+			t.Errorf("ring %d starts at %v, want the original first vertex", i, ring[0])
+		}
+		var area float64
+		for j := 0; j+1 < len(ring); j++ {
+			area += ring[j][0]*ring[j+1][1] - ring[j+1][0]*ring[j][1]
+		}
+		if outer := i == 0; (area > 0) != outer {
+			t.Errorf("ring %d has signed area %v, want counterclockwise outer and clockwise holes", i, area)
+		}
+	}
+}
+
+func TestEncode_BBox(t *testing.T) {
+	tests := []struct {
+		name    string
+		bbox    geofence.BoundingBox
+		inside  []geofence.Point
+		outside []geofence.Point
+	}{
+		{
+			name:    "small box",
+			bbox:    geofence.BoundingBox{MinLat: 1, MinLon: 2, MaxLat: 3, MaxLon: 4},
+			inside:  []geofence.Point{{Latitude: 2, Longitude: 3}},
+			outside: []geofence.Point{{Latitude: 0, Longitude: 3}},
+		},
+		{
+			name:    "across antimeridian",
+			bbox:    geofence.BoundingBox{MinLat: -10, MinLon: 170, MaxLat: 10, MaxLon: -170},
+			inside:  []geofence.Point{{Latitude: 0, Longitude: 175}, {Latitude: 0, Longitude: -175}},
+			outside: []geofence.Point{{Latitude: 0, Longitude: 0}},
+		},
+		{
+			name:    "wider than 180 degrees",
+			bbox:    geofence.BoundingBox{MinLat: -10, MinLon: -100, MaxLat: 10, MaxLon: 100},
+			inside:  []geofence.Point{{Latitude: 0, Longitude: 0}, {Latitude: 0, Longitude: 90}},
+			outside: []geofence.Point{{Latitude: 0, Longitude: 180}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fence := geofence.FenceItem{
+				ID:       "box",
+				Type:     geofence.FenceTypeTempRestriction,
+				Geometry: geofence.Geometry{BBox: &tt.bbox},
+			}
+
+			data, err := Encode([]geofence.FenceItem{fence})
+			if err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+			decoded, err := Decode(data)
+			if err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+
+			for _, p := range tt.inside {
+				if !decoded[0].ContainsPoint(p) {
+					t.Errorf("exported polygon %v does not contain %v", decoded[0].Geometry.Polygon, p)
+				}
+			}
+			for _, p := range tt.outside {
+				if decoded[0].ContainsPoint(p) {
+					t.Errorf("exported polygon %v contains %v", decoded[0].Geometry.Polygon, p)
+				}
+			}
+		})
+	}
+}
+
+func TestEncode_NoGeometry(t *testing.T) {
+	_, err := Encode([]geofence.FenceItem{{ID: "empty", Type: geofence.FenceTypeTempRestriction}})
+	if err == nil {
+		t.Error("expected error for fence without geometry")
+	}
+}
@@ -70,8 +70,19 @@ type Tree struct {
 	mu     sync.RWMutex
 }
 
-// NewTree creates a new Merkle tree from a slice of fence items.
+// NewTree creates a new Merkle tree from a slice of fence items, with leaves
+// hashed in geofence.CurrentFormatVersion.
 func NewTree(fences []geofence.FenceItem) (*Tree, error) {
+	return NewTreeFormat(fences, geofence.CurrentFormatVersion)
+}
+
+// NewTreeFormat creates a new Merkle tree from a slice of fence items, with
+// leaves hashed in the given format version, as named by a manifest.
+func NewTreeFormat(fences []geofence.FenceItem, version uint32) (*Tree, error) {
+	if err := converter.CheckFormatVersion(version); err != nil {
+		return nil, err
+	}
+
 	t := &Tree{
 		leaves: make(map[string]*Node),
 	}
@@ -95,15 +106,10 @@ func NewTree(fences []geofence.FenceItem) (*Tree, error) {
 			return nil, fmt.Errorf("failed to marshal fence %s: %w", fence.ID, err)
 		}
 
-		// Remove signature for hashing (signature is over different data)
-		fenceCopy := fence
-		fenceCopy.Signature = nil
-		dataForHash, err := json.Marshal(fenceCopy)
+		h, err := leafHash(fence, version)
 		if err != nil {
-			return nil, fmt.Errorf("failed to marshal fence for hashing %s: %w", fence.ID, err)
+			return nil, err
 		}
-
-		h := sha256.Sum256(dataForHash)
 		node := &Node{
 			Hash:     h,
 			Leaf:     true,
@@ -118,6 +124,35 @@ func NewTree(fences []geofence.FenceItem) (*Tree, error) {
 	return t, nil
 }
 
+// LeafHash returns the hash of a fence's leaf in trees of the given format
+// version. Fences with the same leaf hash differ at most in their signature.
+func LeafHash(fence geofence.FenceItem, version uint32) (Hash, error) {
+	if err := converter.CheckFormatVersion(version); err != nil {
+		return Hash{}, err
+	}
+	return leafHash(fence, version)
+}
+
+// leafHash hashes a fence for a leaf node. Signatures are left out since
+// they are over different data: FormatVersionJSON hashes the JSON of the
+// fence without its signature, FormatVersionProto its canonical encoding.
+func leafHash(fence geofence.FenceItem, version uint32) (Hash, error) {
+	if version < geofence.FormatVersionProto {
+		fence.Signature = nil
+		data, err := json.Marshal(fence)
+		if err != nil {
+			return Hash{}, fmt.Errorf("failed to marshal fence for hashing %s: %w", fence.ID, err)
+		}
+		return sha256.Sum256(data), nil
+	}
+
+	data, err := converter.MarshalCanonicalFence(&fence)
+	if err != nil {
+		return Hash{}, err
+	}
+	return sha256.Sum256(data), nil
+}
+
 // buildTree recursively builds the Merkle tree from leaf nodes.
 func (t *Tree) buildTree(nodes []*Node) *Node {
 	if len(nodes) == 0 {
@@ -239,22 +274,20 @@ func (t *Tree) findParentRecursive(node, child *Node) *Node {
 	return t.findParentRecursive(node.Right, child)
 }
 
-// VerifyProof verifies a Merkle proof for a given fence ID and root hash.
+// VerifyProof verifies a Merkle proof for a given fence ID and root hash, for
+// a tree built by NewTree.
 func VerifyProof(fenceID string, fenceData []byte, proof [][]byte, rootHash Hash) (bool, error) {
 	// Hash the fence data (without signature)
 	var fence geofence.FenceItem
 	if err := json.Unmarshal(fenceData, &fence); err != nil {
 		return false, fmt.Errorf("failed to unmarshal fence: %w", err)
 	}
-	fence.Signature = nil
-	data, err := json.Marshal(fence)
-	if err != nil {
-		return false, fmt.Errorf("failed to marshal fence: %w", err)
-	}
 
 	// Hash the leaf
-	h := sha256.Sum256(data)
-	currentHash := h
+	currentHash, err := leafHash(fence, geofence.CurrentFormatVersion)
+	if err != nil {
+		return false, err
+	}
 
 	// Verify proof path
 	for _, siblingHash := range proof {
@@ -313,6 +346,13 @@ func fencesEqual(a, b geofence.FenceItem) bool {
 		a.KeyID != b.KeyID {
 		return false
 	}
+	if (a.Altitude == nil) != (b.Altitude == nil) ||
+		(a.Altitude != nil && *a.Altitude != *b.Altitude) {
+		return false
+	}
+	if !a.Schedule.Equal(b.Schedule) || !a.Conditions.Equal(b.Conditions) {
+		return false
+	}
 	// Compare geometry
 	aGeom, _ := json.Marshal(a.Geometry)
 	bGeom, _ := json.Marshal(b.Geometry)
@@ -350,6 +390,30 @@ func ApplyDelta(existingFences []geofence.FenceItem, deltaData []byte) ([]geofen
 	return geofence.ApplyDelta(existingFences, delta)
 }
 
+// NewFenceDeltaFile computes the fence-level delta from oldFences, the
+// fences of version from, to newFences, those of version to, along with the
+// root hashes of both in the given format version. The file is not signed.
+func NewFenceDeltaFile(oldFences, newFences []geofence.FenceItem, from, to uint64, formatVersion uint32) (*geofence.FenceDeltaFile, error) {
+	oldTree, err := NewTreeFormat(oldFences, formatVersion)
+	if err != nil {
+		return nil, err
+	}
+	newTree, err := NewTreeFormat(newFences, formatVersion)
+	if err != nil {
+		return nil, err
+	}
+	fromRoot, toRoot := oldTree.RootHash(), newTree.RootHash()
+
+	return &geofence.FenceDeltaFile{
+		FromVersion:   from,
+		ToVersion:     to,
+		FromRootHash:  fromRoot[:],
+		ToRootHash:    toRoot[:],
+		FormatVersion: formatVersion,
+		Delta:         geofence.CreateDelta(oldFences, newFences),
+	}, nil
+}
+
 // VersionInfo contains version metadata for the delta.
 type VersionInfo struct {
 	Version     uint64
@@ -386,7 +450,10 @@ func LoadSnapshot(data []byte) ([]geofence.FenceItem, error) {
 		return nil, fmt.Errorf("failed to unmarshal snapshot: %w", err)
 	}
 
-	collection := converter.FenceCollectionFromProto(&pbCollection)
+	collection, err := converter.FenceCollectionFromProto(&pbCollection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert snapshot: %w", err)
+	}
 	if collection == nil {
 		return nil, fmt.Errorf("failed to convert protobuf collection")
 	}
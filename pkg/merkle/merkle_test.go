@@ -96,6 +96,56 @@ func TestRootHash_Deterministic(t *testing.T) {
 	}
 }
 
+func TestNewTreeFormat(t *testing.T) {
+	fences := []geofence.FenceItem{
+		{
+			ID:   "fmt-001",
+			Type: geofence.FenceTypePermanentNoFly,
+			Geometry: geofence.Geometry{
+				CircleCenter: &geofence.Point{Latitude: 39.9, Longitude: 116.4},
+				CircleRadius: 500,
+			},
+			KeyID:     "key",
+			Signature: []byte("sig"),
+		},
+	}
+
+	current, err := NewTree(fences)
+	if err != nil {
+		t.Fatalf("NewTree failed: %v", err)
+	}
+	canonical, err := NewTreeFormat(fences, geofence.FormatVersionProto)
+	if err != nil {
+		t.Fatalf("NewTreeFormat failed: %v", err)
+	}
+	legacy, err := NewTreeFormat(fences, 0)
+	if err != nil {
+		t.Fatalf("NewTreeFormat failed: %v", err)
+	}
+
+	if current.RootHash() != canonical.RootHash() {
+		t.Error("NewTree should hash leaves in the current format version")
+	}
+	if legacy.RootHash() == canonical.RootHash() {
+		t.Error("format versions should hash leaves differently")
+	}
+
+	// Signature metadata is not part of the canonical leaf
+	fences[0].KeyID = "other"
+	fences[0].Signature = []byte("other")
+	resigned, err := NewTree(fences)
+	if err != nil {
+		t.Fatalf("NewTree failed: %v", err)
+	}
+	if resigned.RootHash() != current.RootHash() {
+		t.Error("root hash should not depend on signatures")
+	}
+
+	if _, err := NewTreeFormat(fences, geofence.CurrentFormatVersion+1); err == nil {
+		t.Error("expected error for an unsupported format version")
+	}
+}
+
 func TestGetProof(t *testing.T) {
 	now := time.Now()
 	fences := []geofence.FenceItem{
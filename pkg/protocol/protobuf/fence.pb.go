@@ -88,6 +88,507 @@ func (FenceType) EnumDescriptor() ([]byte, []int) {
 	return file_pkg_protocol_protobuf_fence_proto_rawDescGZIP(), []int{0}
 }
 
+// AltitudeReference defines the vertical datum an altitude is measured from
+type AltitudeReference int32
+
+const (
+	// Above ground level
+	AltitudeReference_ALTITUDE_REFERENCE_AGL AltitudeReference = 0
+	// Above mean sea level
+	AltitudeReference_ALTITUDE_REFERENCE_MSL AltitudeReference = 1
+	// Above the WGS84 ellipsoid
+	AltitudeReference_ALTITUDE_REFERENCE_WGS84 AltitudeReference = 2
+)
+
+// Enum value maps for AltitudeReference.
+var (
+	AltitudeReference_name = map[int32]string{
+		0: "ALTITUDE_REFERENCE_AGL",
+		1: "ALTITUDE_REFERENCE_MSL",
+		2: "ALTITUDE_REFERENCE_WGS84",
+	}
+	AltitudeReference_value = map[string]int32{
+		"ALTITUDE_REFERENCE_AGL":   0,
+		"ALTITUDE_REFERENCE_MSL":   1,
+		"ALTITUDE_REFERENCE_WGS84": 2,
+	}
+)
+
+func (x AltitudeReference) Enum() *AltitudeReference {
+	p := new(AltitudeReference)
+	*p = x
+	return p
+}
+
+func (x AltitudeReference) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (AltitudeReference) Descriptor() protoreflect.EnumDescriptor {
+	return file_pkg_protocol_protobuf_fence_proto_enumTypes[1].Descriptor()
+}
+
+func (AltitudeReference) Type() protoreflect.EnumType {
+	return &file_pkg_protocol_protobuf_fence_proto_enumTypes[1]
+}
+
+func (x AltitudeReference) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use AltitudeReference.Descriptor instead.
+func (AltitudeReference) EnumDescriptor() ([]byte, []int) {
+	return file_pkg_protocol_protobuf_fence_proto_rawDescGZIP(), []int{1}
+}
+
+// AircraftCategory defines the kind of aircraft
+type AircraftCategory int32
+
+const (
+	AircraftCategory_AIRCRAFT_CATEGORY_UNKNOWN AircraftCategory = 0
+	// Multicopter
+	AircraftCategory_AIRCRAFT_CATEGORY_MULTIROTOR AircraftCategory = 1
+	// Aeroplane
+	AircraftCategory_AIRCRAFT_CATEGORY_FIXED_WING AircraftCategory = 2
+	// Single main rotor
+	AircraftCategory_AIRCRAFT_CATEGORY_HELICOPTER AircraftCategory = 3
+	// Vertical take-off, wing-borne cruise
+	AircraftCategory_AIRCRAFT_CATEGORY_HYBRID_VTOL AircraftCategory = 4
+	// Balloon or airship
+	AircraftCategory_AIRCRAFT_CATEGORY_LIGHTER_THAN_AIR AircraftCategory = 5
+)
+
+// Enum value maps for AircraftCategory.
+var (
+	AircraftCategory_name = map[int32]string{
+		0: "AIRCRAFT_CATEGORY_UNKNOWN",
+		1: "AIRCRAFT_CATEGORY_MULTIROTOR",
+		2: "AIRCRAFT_CATEGORY_FIXED_WING",
+		3: "AIRCRAFT_CATEGORY_HELICOPTER",
+		4: "AIRCRAFT_CATEGORY_HYBRID_VTOL",
+		5: "AIRCRAFT_CATEGORY_LIGHTER_THAN_AIR",
+	}
+	AircraftCategory_value = map[string]int32{
+		"AIRCRAFT_CATEGORY_UNKNOWN":          0,
+		"AIRCRAFT_CATEGORY_MULTIROTOR":       1,
+		"AIRCRAFT_CATEGORY_FIXED_WING":       2,
+		"AIRCRAFT_CATEGORY_HELICOPTER":       3,
+		"AIRCRAFT_CATEGORY_HYBRID_VTOL":      4,
+		"AIRCRAFT_CATEGORY_LIGHTER_THAN_AIR": 5,
+	}
+)
+
+func (x AircraftCategory) Enum() *AircraftCategory {
+	p := new(AircraftCategory)
+	*p = x
+	return p
+}
+
+func (x AircraftCategory) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (AircraftCategory) Descriptor() protoreflect.EnumDescriptor {
+	return file_pkg_protocol_protobuf_fence_proto_enumTypes[2].Descriptor()
+}
+
+func (AircraftCategory) Type() protoreflect.EnumType {
+	return &file_pkg_protocol_protobuf_fence_proto_enumTypes[2]
+}
+
+func (x AircraftCategory) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use AircraftCategory.Descriptor instead.
+func (AircraftCategory) EnumDescriptor() ([]byte, []int) {
+	return file_pkg_protocol_protobuf_fence_proto_rawDescGZIP(), []int{2}
+}
+
+// OperationType defines the purpose of a flight
+type OperationType int32
+
+const (
+	OperationType_OPERATION_TYPE_UNKNOWN OperationType = 0
+	// Hobby and leisure flights
+	OperationType_OPERATION_TYPE_RECREATIONAL OperationType = 1
+	// Paid work such as surveys, inspection or delivery
+	OperationType_OPERATION_TYPE_COMMERCIAL OperationType = 2
+	// Police, fire, rescue and medical services
+	OperationType_OPERATION_TYPE_EMERGENCY OperationType = 3
+	// State flights other than emergency services
+	OperationType_OPERATION_TYPE_GOVERNMENT OperationType = 4
+)
+
+// Enum value maps for OperationType.
+var (
+	OperationType_name = map[int32]string{
+		0: "OPERATION_TYPE_UNKNOWN",
+		1: "OPERATION_TYPE_RECREATIONAL",
+		2: "OPERATION_TYPE_COMMERCIAL",
+		3: "OPERATION_TYPE_EMERGENCY",
+		4: "OPERATION_TYPE_GOVERNMENT",
+	}
+	OperationType_value = map[string]int32{
+		"OPERATION_TYPE_UNKNOWN":      0,
+		"OPERATION_TYPE_RECREATIONAL": 1,
+		"OPERATION_TYPE_COMMERCIAL":   2,
+		"OPERATION_TYPE_EMERGENCY":    3,
+		"OPERATION_TYPE_GOVERNMENT":   4,
+	}
+)
+
+func (x OperationType) Enum() *OperationType {
+	p := new(OperationType)
+	*p = x
+	return p
+}
+
+func (x OperationType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (OperationType) Descriptor() protoreflect.EnumDescriptor {
+	return file_pkg_protocol_protobuf_fence_proto_enumTypes[3].Descriptor()
+}
+
+func (OperationType) Type() protoreflect.EnumType {
+	return &file_pkg_protocol_protobuf_fence_proto_enumTypes[3]
+}
+
+func (x OperationType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use OperationType.Descriptor instead.
+func (OperationType) EnumDescriptor() ([]byte, []int) {
+	return file_pkg_protocol_protobuf_fence_proto_rawDescGZIP(), []int{3}
+}
+
+// EdgeType defines the path a polygon edge takes between two vertices
+type EdgeType int32
+
+const (
+	// Constant bearing, a straight line on a Mercator chart
+	EdgeType_EDGE_TYPE_RHUMB EdgeType = 0
+	// Shortest path on the sphere
+	EdgeType_EDGE_TYPE_GREAT_CIRCLE EdgeType = 1
+)
+
+// Enum value maps for EdgeType.
+var (
+	EdgeType_name = map[int32]string{
+		0: "EDGE_TYPE_RHUMB",
+		1: "EDGE_TYPE_GREAT_CIRCLE",
+	}
+	EdgeType_value = map[string]int32{
+		"EDGE_TYPE_RHUMB":        0,
+		"EDGE_TYPE_GREAT_CIRCLE": 1,
+	}
+)
+
+func (x EdgeType) Enum() *EdgeType {
+	p := new(EdgeType)
+	*p = x
+	return p
+}
+
+func (x EdgeType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (EdgeType) Descriptor() protoreflect.EnumDescriptor {
+	return file_pkg_protocol_protobuf_fence_proto_enumTypes[4].Descriptor()
+}
+
+func (EdgeType) Type() protoreflect.EnumType {
+	return &file_pkg_protocol_protobuf_fence_proto_enumTypes[4]
+}
+
+func (x EdgeType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use EdgeType.Descriptor instead.
+func (EdgeType) EnumDescriptor() ([]byte, []int) {
+	return file_pkg_protocol_protobuf_fence_proto_rawDescGZIP(), []int{4}
+}
+
+// AltitudeBand defines the vertical extent of a fence volume
+type AltitudeBand struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Lower limit in meters
+	FloorMeters float64 `protobuf:"fixed64,1,opt,name=floor_meters,json=floorMeters,proto3" json:"floor_meters,omitempty"`
+	// Upper limit in meters (0 = no limit)
+	CeilingMeters float64 `protobuf:"fixed64,2,opt,name=ceiling_meters,json=ceilingMeters,proto3" json:"ceiling_meters,omitempty"`
+	// Datum for floor and ceiling
+	Reference     AltitudeReference `protobuf:"varint,3,opt,name=reference,proto3,enum=gul.protocol.v1.AltitudeReference" json:"reference,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AltitudeBand) Reset() {
+	*x = AltitudeBand{}
+	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AltitudeBand) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AltitudeBand) ProtoMessage() {}
+
+func (x *AltitudeBand) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AltitudeBand.ProtoReflect.Descriptor instead.
+func (*AltitudeBand) Descriptor() ([]byte, []int) {
+	return file_pkg_protocol_protobuf_fence_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *AltitudeBand) GetFloorMeters() float64 {
+	if x != nil {
+		return x.FloorMeters
+	}
+	return 0
+}
+
+func (x *AltitudeBand) GetCeilingMeters() float64 {
+	if x != nil {
+		return x.CeilingMeters
+	}
+	return 0
+}
+
+func (x *AltitudeBand) GetReference() AltitudeReference {
+	if x != nil {
+		return x.Reference
+	}
+	return AltitudeReference_ALTITUDE_REFERENCE_AGL
+}
+
+// Schedule restricts a fence to recurring windows of local time
+type Schedule struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// IANA time zone name, e.g. "Europe/Berlin" (empty = UTC)
+	TimeZone string `protobuf:"bytes,1,opt,name=time_zone,json=timeZone,proto3" json:"time_zone,omitempty"`
+	// The fence is active while any rule matches
+	Rules         []*WeeklyRule `protobuf:"bytes,2,rep,name=rules,proto3" json:"rules,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Schedule) Reset() {
+	*x = Schedule{}
+	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Schedule) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Schedule) ProtoMessage() {}
+
+func (x *Schedule) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Schedule.ProtoReflect.Descriptor instead.
+func (*Schedule) Descriptor() ([]byte, []int) {
+	return file_pkg_protocol_protobuf_fence_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Schedule) GetTimeZone() string {
+	if x != nil {
+		return x.TimeZone
+	}
+	return ""
+}
+
+func (x *Schedule) GetRules() []*WeeklyRule {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
+// WeeklyRule is a daily window of local time on selected weekdays
+// A window whose end is not after its start runs past midnight
+type WeeklyRule struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Days the window starts on, 0 = Sunday to 6 = Saturday (empty = every day)
+	Days []uint32 `protobuf:"varint,1,rep,packed,name=days,proto3" json:"days,omitempty"`
+	// Minutes after local midnight, 0-1439
+	StartMinute uint32 `protobuf:"varint,2,opt,name=start_minute,json=startMinute,proto3" json:"start_minute,omitempty"`
+	// Minutes after local midnight, 0-1440
+	EndMinute     uint32 `protobuf:"varint,3,opt,name=end_minute,json=endMinute,proto3" json:"end_minute,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WeeklyRule) Reset() {
+	*x = WeeklyRule{}
+	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WeeklyRule) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WeeklyRule) ProtoMessage() {}
+
+func (x *WeeklyRule) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WeeklyRule.ProtoReflect.Descriptor instead.
+func (*WeeklyRule) Descriptor() ([]byte, []int) {
+	return file_pkg_protocol_protobuf_fence_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *WeeklyRule) GetDays() []uint32 {
+	if x != nil {
+		return x.Days
+	}
+	return nil
+}
+
+func (x *WeeklyRule) GetStartMinute() uint32 {
+	if x != nil {
+		return x.StartMinute
+	}
+	return 0
+}
+
+func (x *WeeklyRule) GetEndMinute() uint32 {
+	if x != nil {
+		return x.EndMinute
+	}
+	return 0
+}
+
+// Conditions restrict a fence to certain aircraft and operations
+type Conditions struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Applies to these categories only (empty = all)
+	Categories []AircraftCategory `protobuf:"varint,1,rep,packed,name=categories,proto3,enum=gul.protocol.v1.AircraftCategory" json:"categories,omitempty"`
+	// Applies to these operations only (empty = all)
+	Operations []OperationType `protobuf:"varint,2,rep,packed,name=operations,proto3,enum=gul.protocol.v1.OperationType" json:"operations,omitempty"`
+	// Operations the fence never applies to
+	ExemptOperations []OperationType `protobuf:"varint,3,rep,packed,name=exempt_operations,json=exemptOperations,proto3,enum=gul.protocol.v1.OperationType" json:"exempt_operations,omitempty"`
+	// Applies above this takeoff mass in kg only (0 = no lower bound)
+	MinMassKg float64 `protobuf:"fixed64,4,opt,name=min_mass_kg,json=minMassKg,proto3" json:"min_mass_kg,omitempty"`
+	// Applies up to this takeoff mass in kg only (0 = no upper bound)
+	MaxMassKg float64 `protobuf:"fixed64,5,opt,name=max_mass_kg,json=maxMassKg,proto3" json:"max_mass_kg,omitempty"`
+	// Aircraft holding any of these tokens are exempt
+	ExemptionTokens []string `protobuf:"bytes,6,rep,name=exemption_tokens,json=exemptionTokens,proto3" json:"exemption_tokens,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Conditions) Reset() {
+	*x = Conditions{}
+	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Conditions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Conditions) ProtoMessage() {}
+
+func (x *Conditions) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Conditions.ProtoReflect.Descriptor instead.
+func (*Conditions) Descriptor() ([]byte, []int) {
+	return file_pkg_protocol_protobuf_fence_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Conditions) GetCategories() []AircraftCategory {
+	if x != nil {
+		return x.Categories
+	}
+	return nil
+}
+
+func (x *Conditions) GetOperations() []OperationType {
+	if x != nil {
+		return x.Operations
+	}
+	return nil
+}
+
+func (x *Conditions) GetExemptOperations() []OperationType {
+	if x != nil {
+		return x.ExemptOperations
+	}
+	return nil
+}
+
+func (x *Conditions) GetMinMassKg() float64 {
+	if x != nil {
+		return x.MinMassKg
+	}
+	return 0
+}
+
+func (x *Conditions) GetMaxMassKg() float64 {
+	if x != nil {
+		return x.MaxMassKg
+	}
+	return 0
+}
+
+func (x *Conditions) GetExemptionTokens() []string {
+	if x != nil {
+		return x.ExemptionTokens
+	}
+	return nil
+}
+
 // Geometry defines the spatial shape of the fence
 type Geometry struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
@@ -96,14 +597,18 @@ type Geometry struct {
 	//	*Geometry_Polygon
 	//	*Geometry_Circle
 	//	*Geometry_Bbox
-	Shape         isGeometry_Shape `protobuf_oneof:"shape"`
+	//	*Geometry_MultiPolygon
+	//	*Geometry_Corridor
+	Shape isGeometry_Shape `protobuf_oneof:"shape"`
+	// How polygon and multi-polygon edges run between their vertices
+	EdgeType      EdgeType `protobuf:"varint,6,opt,name=edge_type,json=edgeType,proto3,enum=gul.protocol.v1.EdgeType" json:"edge_type,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Geometry) Reset() {
 	*x = Geometry{}
-	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[0]
+	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -115,7 +620,7 @@ func (x *Geometry) String() string {
 func (*Geometry) ProtoMessage() {}
 
 func (x *Geometry) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[0]
+	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -128,7 +633,7 @@ func (x *Geometry) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Geometry.ProtoReflect.Descriptor instead.
 func (*Geometry) Descriptor() ([]byte, []int) {
-	return file_pkg_protocol_protobuf_fence_proto_rawDescGZIP(), []int{0}
+	return file_pkg_protocol_protobuf_fence_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *Geometry) GetShape() isGeometry_Shape {
@@ -165,6 +670,31 @@ func (x *Geometry) GetBbox() *BoundingBox {
 	return nil
 }
 
+func (x *Geometry) GetMultiPolygon() *MultiPolygon {
+	if x != nil {
+		if x, ok := x.Shape.(*Geometry_MultiPolygon); ok {
+			return x.MultiPolygon
+		}
+	}
+	return nil
+}
+
+func (x *Geometry) GetCorridor() *Corridor {
+	if x != nil {
+		if x, ok := x.Shape.(*Geometry_Corridor); ok {
+			return x.Corridor
+		}
+	}
+	return nil
+}
+
+func (x *Geometry) GetEdgeType() EdgeType {
+	if x != nil {
+		return x.EdgeType
+	}
+	return EdgeType_EDGE_TYPE_RHUMB
+}
+
 type isGeometry_Shape interface {
 	isGeometry_Shape()
 }
@@ -184,25 +714,42 @@ type Geometry_Bbox struct {
 	Bbox *BoundingBox `protobuf:"bytes,3,opt,name=bbox,proto3,oneof"`
 }
 
+type Geometry_MultiPolygon struct {
+	// Several disjoint polygons, each with optional holes
+	MultiPolygon *MultiPolygon `protobuf:"bytes,4,opt,name=multi_polygon,json=multiPolygon,proto3,oneof"`
+}
+
+type Geometry_Corridor struct {
+	// Buffered polyline, e.g. a route with a safety margin
+	Corridor *Corridor `protobuf:"bytes,5,opt,name=corridor,proto3,oneof"`
+}
+
 func (*Geometry_Polygon) isGeometry_Shape() {}
 
 func (*Geometry_Circle) isGeometry_Shape() {}
 
 func (*Geometry_Bbox) isGeometry_Shape() {}
 
+func (*Geometry_MultiPolygon) isGeometry_Shape() {}
+
+func (*Geometry_Corridor) isGeometry_Shape() {}
+
 // Polygon represents a closed shape defined by vertices
 type Polygon struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// Coordinates in [latitude, longitude] pairs
 	// Lat/Lon in degrees (WGS84)
-	Coordinates   []*Point `protobuf:"bytes,1,rep,name=coordinates,proto3" json:"coordinates,omitempty"`
+	// This is the outer ring of the polygon
+	Coordinates []*Point `protobuf:"bytes,1,rep,name=coordinates,proto3" json:"coordinates,omitempty"`
+	// Interior rings excluded from the polygon area
+	Holes         []*Ring `protobuf:"bytes,2,rep,name=holes,proto3" json:"holes,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Polygon) Reset() {
 	*x = Polygon{}
-	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[1]
+	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -214,7 +761,7 @@ func (x *Polygon) String() string {
 func (*Polygon) ProtoMessage() {}
 
 func (x *Polygon) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[1]
+	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -227,7 +774,7 @@ func (x *Polygon) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Polygon.ProtoReflect.Descriptor instead.
 func (*Polygon) Descriptor() ([]byte, []int) {
-	return file_pkg_protocol_protobuf_fence_proto_rawDescGZIP(), []int{1}
+	return file_pkg_protocol_protobuf_fence_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *Polygon) GetCoordinates() []*Point {
@@ -237,6 +784,159 @@ func (x *Polygon) GetCoordinates() []*Point {
 	return nil
 }
 
+func (x *Polygon) GetHoles() []*Ring {
+	if x != nil {
+		return x.Holes
+	}
+	return nil
+}
+
+// Ring represents a closed sequence of vertices
+type Ring struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Coordinates   []*Point               `protobuf:"bytes,1,rep,name=coordinates,proto3" json:"coordinates,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Ring) Reset() {
+	*x = Ring{}
+	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Ring) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Ring) ProtoMessage() {}
+
+func (x *Ring) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Ring.ProtoReflect.Descriptor instead.
+func (*Ring) Descriptor() ([]byte, []int) {
+	return file_pkg_protocol_protobuf_fence_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Ring) GetCoordinates() []*Point {
+	if x != nil {
+		return x.Coordinates
+	}
+	return nil
+}
+
+// MultiPolygon represents a shape made of several disjoint polygons
+type MultiPolygon struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Polygons      []*Polygon             `protobuf:"bytes,1,rep,name=polygons,proto3" json:"polygons,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MultiPolygon) Reset() {
+	*x = MultiPolygon{}
+	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MultiPolygon) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MultiPolygon) ProtoMessage() {}
+
+func (x *MultiPolygon) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MultiPolygon.ProtoReflect.Descriptor instead.
+func (*MultiPolygon) Descriptor() ([]byte, []int) {
+	return file_pkg_protocol_protobuf_fence_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *MultiPolygon) GetPolygons() []*Polygon {
+	if x != nil {
+		return x.Polygons
+	}
+	return nil
+}
+
+// Corridor represents the area within a distance of a polyline
+type Corridor struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Center line encoded with the Polyline Algorithm at 1e-7 degree
+	// precision (latitude first, each coordinate delta-encoded)
+	EncodedPath string `protobuf:"bytes,1,opt,name=encoded_path,json=encodedPath,proto3" json:"encoded_path,omitempty"`
+	// Buffer on each side of the center line in meters
+	HalfWidthMeters float64 `protobuf:"fixed64,2,opt,name=half_width_meters,json=halfWidthMeters,proto3" json:"half_width_meters,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Corridor) Reset() {
+	*x = Corridor{}
+	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Corridor) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Corridor) ProtoMessage() {}
+
+func (x *Corridor) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Corridor.ProtoReflect.Descriptor instead.
+func (*Corridor) Descriptor() ([]byte, []int) {
+	return file_pkg_protocol_protobuf_fence_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Corridor) GetEncodedPath() string {
+	if x != nil {
+		return x.EncodedPath
+	}
+	return ""
+}
+
+func (x *Corridor) GetHalfWidthMeters() float64 {
+	if x != nil {
+		return x.HalfWidthMeters
+	}
+	return 0
+}
+
 // Circle represents a circular geofence
 type Circle struct {
 	state  protoimpl.MessageState `protogen:"open.v1"`
@@ -249,7 +949,7 @@ type Circle struct {
 
 func (x *Circle) Reset() {
 	*x = Circle{}
-	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[2]
+	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -261,7 +961,7 @@ func (x *Circle) String() string {
 func (*Circle) ProtoMessage() {}
 
 func (x *Circle) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[2]
+	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -274,7 +974,7 @@ func (x *Circle) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Circle.ProtoReflect.Descriptor instead.
 func (*Circle) Descriptor() ([]byte, []int) {
-	return file_pkg_protocol_protobuf_fence_proto_rawDescGZIP(), []int{2}
+	return file_pkg_protocol_protobuf_fence_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *Circle) GetCenter() *Point {
@@ -302,7 +1002,7 @@ type Point struct {
 
 func (x *Point) Reset() {
 	*x = Point{}
-	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[3]
+	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -314,7 +1014,7 @@ func (x *Point) String() string {
 func (*Point) ProtoMessage() {}
 
 func (x *Point) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[3]
+	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -327,7 +1027,7 @@ func (x *Point) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Point.ProtoReflect.Descriptor instead.
 func (*Point) Descriptor() ([]byte, []int) {
-	return file_pkg_protocol_protobuf_fence_proto_rawDescGZIP(), []int{3}
+	return file_pkg_protocol_protobuf_fence_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *Point) GetLatitude() float64 {
@@ -357,7 +1057,7 @@ type BoundingBox struct {
 
 func (x *BoundingBox) Reset() {
 	*x = BoundingBox{}
-	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[4]
+	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[11]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -369,7 +1069,7 @@ func (x *BoundingBox) String() string {
 func (*BoundingBox) ProtoMessage() {}
 
 func (x *BoundingBox) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[4]
+	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[11]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -382,7 +1082,7 @@ func (x *BoundingBox) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use BoundingBox.ProtoReflect.Descriptor instead.
 func (*BoundingBox) Descriptor() ([]byte, []int) {
-	return file_pkg_protocol_protobuf_fence_proto_rawDescGZIP(), []int{4}
+	return file_pkg_protocol_protobuf_fence_proto_rawDescGZIP(), []int{11}
 }
 
 func (x *BoundingBox) GetMinLat() float64 {
@@ -437,19 +1137,25 @@ type FenceItem struct {
 	// Human-readable description
 	Name        string `protobuf:"bytes,9,opt,name=name,proto3" json:"name,omitempty"`
 	Description string `protobuf:"bytes,10,opt,name=description,proto3" json:"description,omitempty"`
-	// Ed25519 signature of all above fields
-	// The signature is computed over the serialized protobuf message
-	// without this signature field included
+	// Ed25519 signature of all other fields except key_id
+	// From format version 2 on, it is computed over the deterministic
+	// protobuf encoding of this message with signature and key_id cleared
 	Signature []byte `protobuf:"bytes,11,opt,name=signature,proto3" json:"signature,omitempty"`
 	// Public key ID that can verify this signature
-	KeyId         string `protobuf:"bytes,12,opt,name=key_id,json=keyId,proto3" json:"key_id,omitempty"`
+	KeyId string `protobuf:"bytes,12,opt,name=key_id,json=keyId,proto3" json:"key_id,omitempty"`
+	// Vertical extent of the fence (unset = surface to unlimited)
+	AltitudeBand *AltitudeBand `protobuf:"bytes,13,opt,name=altitude_band,json=altitudeBand,proto3" json:"altitude_band,omitempty"`
+	// Recurring activity windows within start_ts/end_ts (unset = always)
+	Schedule *Schedule `protobuf:"bytes,14,opt,name=schedule,proto3" json:"schedule,omitempty"`
+	// Aircraft and operations the fence applies to (unset = all)
+	Conditions    *Conditions `protobuf:"bytes,15,opt,name=conditions,proto3" json:"conditions,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *FenceItem) Reset() {
 	*x = FenceItem{}
-	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[5]
+	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[12]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -461,7 +1167,7 @@ func (x *FenceItem) String() string {
 func (*FenceItem) ProtoMessage() {}
 
 func (x *FenceItem) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[5]
+	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[12]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -474,7 +1180,7 @@ func (x *FenceItem) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use FenceItem.ProtoReflect.Descriptor instead.
 func (*FenceItem) Descriptor() ([]byte, []int) {
-	return file_pkg_protocol_protobuf_fence_proto_rawDescGZIP(), []int{5}
+	return file_pkg_protocol_protobuf_fence_proto_rawDescGZIP(), []int{12}
 }
 
 func (x *FenceItem) GetId() string {
@@ -561,6 +1267,27 @@ func (x *FenceItem) GetKeyId() string {
 	return ""
 }
 
+func (x *FenceItem) GetAltitudeBand() *AltitudeBand {
+	if x != nil {
+		return x.AltitudeBand
+	}
+	return nil
+}
+
+func (x *FenceItem) GetSchedule() *Schedule {
+	if x != nil {
+		return x.Schedule
+	}
+	return nil
+}
+
+func (x *FenceItem) GetConditions() *Conditions {
+	if x != nil {
+		return x.Conditions
+	}
+	return nil
+}
+
 // FenceCollection represents a batch of fence items
 // Used for snapshot files and delta updates
 type FenceCollection struct {
@@ -575,7 +1302,7 @@ type FenceCollection struct {
 
 func (x *FenceCollection) Reset() {
 	*x = FenceCollection{}
-	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[6]
+	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[13]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -587,7 +1314,7 @@ func (x *FenceCollection) String() string {
 func (*FenceCollection) ProtoMessage() {}
 
 func (x *FenceCollection) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[6]
+	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[13]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -600,7 +1327,7 @@ func (x *FenceCollection) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use FenceCollection.ProtoReflect.Descriptor instead.
 func (*FenceCollection) Descriptor() ([]byte, []int) {
-	return file_pkg_protocol_protobuf_fence_proto_rawDescGZIP(), []int{6}
+	return file_pkg_protocol_protobuf_fence_proto_rawDescGZIP(), []int{13}
 }
 
 func (x *FenceCollection) GetItems() []*FenceItem {
@@ -639,7 +1366,7 @@ type FenceDelta struct {
 
 func (x *FenceDelta) Reset() {
 	*x = FenceDelta{}
-	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[7]
+	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[14]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -651,7 +1378,7 @@ func (x *FenceDelta) String() string {
 func (*FenceDelta) ProtoMessage() {}
 
 func (x *FenceDelta) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[7]
+	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[14]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -664,7 +1391,7 @@ func (x *FenceDelta) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use FenceDelta.ProtoReflect.Descriptor instead.
 func (*FenceDelta) Descriptor() ([]byte, []int) {
-	return file_pkg_protocol_protobuf_fence_proto_rawDescGZIP(), []int{7}
+	return file_pkg_protocol_protobuf_fence_proto_rawDescGZIP(), []int{14}
 }
 
 func (x *FenceDelta) GetAdded() []*FenceItem {
@@ -709,7 +1436,7 @@ type DeltaFile struct {
 
 func (x *DeltaFile) Reset() {
 	*x = DeltaFile{}
-	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[8]
+	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[15]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -721,7 +1448,7 @@ func (x *DeltaFile) String() string {
 func (*DeltaFile) ProtoMessage() {}
 
 func (x *DeltaFile) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[8]
+	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[15]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -734,7 +1461,7 @@ func (x *DeltaFile) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DeltaFile.ProtoReflect.Descriptor instead.
 func (*DeltaFile) Descriptor() ([]byte, []int) {
-	return file_pkg_protocol_protobuf_fence_proto_rawDescGZIP(), []int{8}
+	return file_pkg_protocol_protobuf_fence_proto_rawDescGZIP(), []int{15}
 }
 
 func (x *DeltaFile) GetFromVersion() uint64 {
@@ -779,18 +1506,165 @@ func (x *DeltaFile) GetDiffHash() []byte {
 	return nil
 }
 
+// FenceDeltaFile is a signed, fence-level delta from one version to a
+// later one, applied fence by fence rather than to the serialized collection
+type FenceDeltaFile struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Source version
+	FromVersion uint64 `protobuf:"varint,1,opt,name=from_version,json=fromVersion,proto3" json:"from_version,omitempty"`
+	// Target version
+	ToVersion uint64 `protobuf:"varint,2,opt,name=to_version,json=toVersion,proto3" json:"to_version,omitempty"`
+	// Merkle root hashes of the fences at from_version and to_version,
+	// computed in format_version
+	FromRootHash []byte `protobuf:"bytes,3,opt,name=from_root_hash,json=fromRootHash,proto3" json:"from_root_hash,omitempty"`
+	ToRootHash   []byte `protobuf:"bytes,4,opt,name=to_root_hash,json=toRootHash,proto3" json:"to_root_hash,omitempty"`
+	// Encoding the root hashes and fence signatures are computed over
+	FormatVersion uint32 `protobuf:"varint,5,opt,name=format_version,json=formatVersion,proto3" json:"format_version,omitempty"`
+	// Changes from from_version to to_version
+	Delta *FenceDelta `protobuf:"bytes,6,opt,name=delta,proto3" json:"delta,omitempty"`
+	// Ed25519 signature over the deterministic encoding of this message with
+	// signature and key_id cleared
+	Signature []byte `protobuf:"bytes,7,opt,name=signature,proto3" json:"signature,omitempty"`
+	// Public key ID that can verify this signature
+	KeyId         string `protobuf:"bytes,8,opt,name=key_id,json=keyId,proto3" json:"key_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FenceDeltaFile) Reset() {
+	*x = FenceDeltaFile{}
+	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FenceDeltaFile) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FenceDeltaFile) ProtoMessage() {}
+
+func (x *FenceDeltaFile) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_protocol_protobuf_fence_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FenceDeltaFile.ProtoReflect.Descriptor instead.
+func (*FenceDeltaFile) Descriptor() ([]byte, []int) {
+	return file_pkg_protocol_protobuf_fence_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *FenceDeltaFile) GetFromVersion() uint64 {
+	if x != nil {
+		return x.FromVersion
+	}
+	return 0
+}
+
+func (x *FenceDeltaFile) GetToVersion() uint64 {
+	if x != nil {
+		return x.ToVersion
+	}
+	return 0
+}
+
+func (x *FenceDeltaFile) GetFromRootHash() []byte {
+	if x != nil {
+		return x.FromRootHash
+	}
+	return nil
+}
+
+func (x *FenceDeltaFile) GetToRootHash() []byte {
+	if x != nil {
+		return x.ToRootHash
+	}
+	return nil
+}
+
+func (x *FenceDeltaFile) GetFormatVersion() uint32 {
+	if x != nil {
+		return x.FormatVersion
+	}
+	return 0
+}
+
+func (x *FenceDeltaFile) GetDelta() *FenceDelta {
+	if x != nil {
+		return x.Delta
+	}
+	return nil
+}
+
+func (x *FenceDeltaFile) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+func (x *FenceDeltaFile) GetKeyId() string {
+	if x != nil {
+		return x.KeyId
+	}
+	return ""
+}
+
 var File_pkg_protocol_protobuf_fence_proto protoreflect.FileDescriptor
 
 const file_pkg_protocol_protobuf_fence_proto_rawDesc = "" +
 	"\n" +
-	"!pkg/protocol/protobuf/fence.proto\x12\x0fgul.protocol.v1\"\xb0\x01\n" +
+	"!pkg/protocol/protobuf/fence.proto\x12\x0fgul.protocol.v1\"\x9a\x01\n" +
+	"\fAltitudeBand\x12!\n" +
+	"\ffloor_meters\x18\x01 \x01(\x01R\vfloorMeters\x12%\n" +
+	"\x0eceiling_meters\x18\x02 \x01(\x01R\rceilingMeters\x12@\n" +
+	"\treference\x18\x03 \x01(\x0e2\".gul.protocol.v1.AltitudeReferenceR\treference\"Z\n" +
+	"\bSchedule\x12\x1b\n" +
+	"\ttime_zone\x18\x01 \x01(\tR\btimeZone\x121\n" +
+	"\x05rules\x18\x02 \x03(\v2\x1b.gul.protocol.v1.WeeklyRuleR\x05rules\"b\n" +
+	"\n" +
+	"WeeklyRule\x12\x12\n" +
+	"\x04days\x18\x01 \x03(\rR\x04days\x12!\n" +
+	"\fstart_minute\x18\x02 \x01(\rR\vstartMinute\x12\x1d\n" +
+	"\n" +
+	"end_minute\x18\x03 \x01(\rR\tendMinute\"\xc7\x02\n" +
+	"\n" +
+	"Conditions\x12A\n" +
+	"\n" +
+	"categories\x18\x01 \x03(\x0e2!.gul.protocol.v1.AircraftCategoryR\n" +
+	"categories\x12>\n" +
+	"\n" +
+	"operations\x18\x02 \x03(\x0e2\x1e.gul.protocol.v1.OperationTypeR\n" +
+	"operations\x12K\n" +
+	"\x11exempt_operations\x18\x03 \x03(\x0e2\x1e.gul.protocol.v1.OperationTypeR\x10exemptOperations\x12\x1e\n" +
+	"\vmin_mass_kg\x18\x04 \x01(\x01R\tminMassKg\x12\x1e\n" +
+	"\vmax_mass_kg\x18\x05 \x01(\x01R\tmaxMassKg\x12)\n" +
+	"\x10exemption_tokens\x18\x06 \x03(\tR\x0fexemptionTokens\"\xe7\x02\n" +
 	"\bGeometry\x124\n" +
 	"\apolygon\x18\x01 \x01(\v2\x18.gul.protocol.v1.PolygonH\x00R\apolygon\x121\n" +
 	"\x06circle\x18\x02 \x01(\v2\x17.gul.protocol.v1.CircleH\x00R\x06circle\x122\n" +
-	"\x04bbox\x18\x03 \x01(\v2\x1c.gul.protocol.v1.BoundingBoxH\x00R\x04bboxB\a\n" +
-	"\x05shape\"C\n" +
+	"\x04bbox\x18\x03 \x01(\v2\x1c.gul.protocol.v1.BoundingBoxH\x00R\x04bbox\x12D\n" +
+	"\rmulti_polygon\x18\x04 \x01(\v2\x1d.gul.protocol.v1.MultiPolygonH\x00R\fmultiPolygon\x127\n" +
+	"\bcorridor\x18\x05 \x01(\v2\x19.gul.protocol.v1.CorridorH\x00R\bcorridor\x126\n" +
+	"\tedge_type\x18\x06 \x01(\x0e2\x19.gul.protocol.v1.EdgeTypeR\bedgeTypeB\a\n" +
+	"\x05shape\"p\n" +
 	"\aPolygon\x128\n" +
-	"\vcoordinates\x18\x01 \x03(\v2\x16.gul.protocol.v1.PointR\vcoordinates\"]\n" +
+	"\vcoordinates\x18\x01 \x03(\v2\x16.gul.protocol.v1.PointR\vcoordinates\x12+\n" +
+	"\x05holes\x18\x02 \x03(\v2\x15.gul.protocol.v1.RingR\x05holes\"@\n" +
+	"\x04Ring\x128\n" +
+	"\vcoordinates\x18\x01 \x03(\v2\x16.gul.protocol.v1.PointR\vcoordinates\"D\n" +
+	"\fMultiPolygon\x124\n" +
+	"\bpolygons\x18\x01 \x03(\v2\x18.gul.protocol.v1.PolygonR\bpolygons\"Y\n" +
+	"\bCorridor\x12!\n" +
+	"\fencoded_path\x18\x01 \x01(\tR\vencodedPath\x12*\n" +
+	"\x11half_width_meters\x18\x02 \x01(\x01R\x0fhalfWidthMeters\"]\n" +
 	"\x06Circle\x12.\n" +
 	"\x06center\x18\x01 \x01(\v2\x16.gul.protocol.v1.PointR\x06center\x12#\n" +
 	"\rradius_meters\x18\x02 \x01(\x01R\fradiusMeters\"A\n" +
@@ -801,7 +1675,7 @@ const file_pkg_protocol_protobuf_fence_proto_rawDesc = "" +
 	"\amin_lat\x18\x01 \x01(\x01R\x06minLat\x12\x17\n" +
 	"\amin_lon\x18\x02 \x01(\x01R\x06minLon\x12\x17\n" +
 	"\amax_lat\x18\x03 \x01(\x01R\x06maxLat\x12\x17\n" +
-	"\amax_lon\x18\x04 \x01(\x01R\x06maxLon\"\x8f\x03\n" +
+	"\amax_lon\x18\x04 \x01(\x01R\x06maxLon\"\xc7\x04\n" +
 	"\tFenceItem\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12.\n" +
 	"\x04type\x18\x02 \x01(\x0e2\x1a.gul.protocol.v1.FenceTypeR\x04type\x125\n" +
@@ -815,7 +1689,12 @@ const file_pkg_protocol_protobuf_fence_proto_rawDesc = "" +
 	"\vdescription\x18\n" +
 	" \x01(\tR\vdescription\x12\x1c\n" +
 	"\tsignature\x18\v \x01(\fR\tsignature\x12\x15\n" +
-	"\x06key_id\x18\f \x01(\tR\x05keyId\"|\n" +
+	"\x06key_id\x18\f \x01(\tR\x05keyId\x12B\n" +
+	"\raltitude_band\x18\r \x01(\v2\x1d.gul.protocol.v1.AltitudeBandR\faltitudeBand\x125\n" +
+	"\bschedule\x18\x0e \x01(\v2\x19.gul.protocol.v1.ScheduleR\bschedule\x12;\n" +
+	"\n" +
+	"conditions\x18\x0f \x01(\v2\x1b.gul.protocol.v1.ConditionsR\n" +
+	"conditions\"|\n" +
 	"\x0fFenceCollection\x120\n" +
 	"\x05items\x18\x01 \x03(\v2\x1a.gul.protocol.v1.FenceItemR\x05items\x12\x1d\n" +
 	"\n" +
@@ -834,14 +1713,45 @@ const file_pkg_protocol_protobuf_fence_proto_rawDesc = "" +
 	"\tfrom_size\x18\x03 \x01(\x03R\bfromSize\x12\x17\n" +
 	"\ato_size\x18\x04 \x01(\x03R\x06toSize\x12\x1b\n" +
 	"\tdiff_data\x18\x05 \x01(\fR\bdiffData\x12\x1b\n" +
-	"\tdiff_hash\x18\x06 \x01(\fR\bdiffHash*\xc1\x01\n" +
+	"\tdiff_hash\x18\x06 \x01(\fR\bdiffHash\"\xa9\x02\n" +
+	"\x0eFenceDeltaFile\x12!\n" +
+	"\ffrom_version\x18\x01 \x01(\x04R\vfromVersion\x12\x1d\n" +
+	"\n" +
+	"to_version\x18\x02 \x01(\x04R\ttoVersion\x12$\n" +
+	"\x0efrom_root_hash\x18\x03 \x01(\fR\ffromRootHash\x12 \n" +
+	"\fto_root_hash\x18\x04 \x01(\fR\n" +
+	"toRootHash\x12%\n" +
+	"\x0eformat_version\x18\x05 \x01(\rR\rformatVersion\x121\n" +
+	"\x05delta\x18\x06 \x01(\v2\x1b.gul.protocol.v1.FenceDeltaR\x05delta\x12\x1c\n" +
+	"\tsignature\x18\a \x01(\fR\tsignature\x12\x15\n" +
+	"\x06key_id\x18\b \x01(\tR\x05keyId*\xc1\x01\n" +
 	"\tFenceType\x12\x16\n" +
 	"\x12FENCE_TYPE_UNKNOWN\x10\x00\x12\x1f\n" +
 	"\x1bFENCE_TYPE_TEMP_RESTRICTION\x10\x01\x12\x1f\n" +
 	"\x1bFENCE_TYPE_PERMANENT_NO_FLY\x10\x02\x12\x1d\n" +
 	"\x19FENCE_TYPE_ALTITUDE_LIMIT\x10\x03\x12\x1f\n" +
 	"\x1bFENCE_TYPE_ALTITUDE_MINIMUM\x10\x04\x12\x1a\n" +
-	"\x16FENCE_TYPE_SPEED_LIMIT\x10\x05B?Z=github.com/iannil/geofence-updater-lite/pkg/protocol/protobufb\x06proto3"
+	"\x16FENCE_TYPE_SPEED_LIMIT\x10\x05*i\n" +
+	"\x11AltitudeReference\x12\x1a\n" +
+	"\x16ALTITUDE_REFERENCE_AGL\x10\x00\x12\x1a\n" +
+	"\x16ALTITUDE_REFERENCE_MSL\x10\x01\x12\x1c\n" +
+	"\x18ALTITUDE_REFERENCE_WGS84\x10\x02*\xe2\x01\n" +
+	"\x10AircraftCategory\x12\x1d\n" +
+	"\x19AIRCRAFT_CATEGORY_UNKNOWN\x10\x00\x12 \n" +
+	"\x1cAIRCRAFT_CATEGORY_MULTIROTOR\x10\x01\x12 \n" +
+	"\x1cAIRCRAFT_CATEGORY_FIXED_WING\x10\x02\x12 \n" +
+	"\x1cAIRCRAFT_CATEGORY_HELICOPTER\x10\x03\x12!\n" +
+	"\x1dAIRCRAFT_CATEGORY_HYBRID_VTOL\x10\x04\x12&\n" +
+	"\"AIRCRAFT_CATEGORY_LIGHTER_THAN_AIR\x10\x05*\xa8\x01\n" +
+	"\rOperationType\x12\x1a\n" +
+	"\x16OPERATION_TYPE_UNKNOWN\x10\x00\x12\x1f\n" +
+	"\x1bOPERATION_TYPE_RECREATIONAL\x10\x01\x12\x1d\n" +
+	"\x19OPERATION_TYPE_COMMERCIAL\x10\x02\x12\x1c\n" +
+	"\x18OPERATION_TYPE_EMERGENCY\x10\x03\x12\x1d\n" +
+	"\x19OPERATION_TYPE_GOVERNMENT\x10\x04*;\n" +
+	"\bEdgeType\x12\x13\n" +
+	"\x0fEDGE_TYPE_RHUMB\x10\x00\x12\x1a\n" +
+	"\x16EDGE_TYPE_GREAT_CIRCLE\x10\x01B?Z=github.com/iannil/geofence-updater-lite/pkg/protocol/protobufb\x06proto3"
 
 var (
 	file_pkg_protocol_protobuf_fence_proto_rawDescOnce sync.Once
@@ -855,36 +1765,63 @@ func file_pkg_protocol_protobuf_fence_proto_rawDescGZIP() []byte {
 	return file_pkg_protocol_protobuf_fence_proto_rawDescData
 }
 
-var file_pkg_protocol_protobuf_fence_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_pkg_protocol_protobuf_fence_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_pkg_protocol_protobuf_fence_proto_enumTypes = make([]protoimpl.EnumInfo, 5)
+var file_pkg_protocol_protobuf_fence_proto_msgTypes = make([]protoimpl.MessageInfo, 17)
 var file_pkg_protocol_protobuf_fence_proto_goTypes = []any{
 	(FenceType)(0),          // 0: gul.protocol.v1.FenceType
-	(*Geometry)(nil),        // 1: gul.protocol.v1.Geometry
-	(*Polygon)(nil),         // 2: gul.protocol.v1.Polygon
-	(*Circle)(nil),          // 3: gul.protocol.v1.Circle
-	(*Point)(nil),           // 4: gul.protocol.v1.Point
-	(*BoundingBox)(nil),     // 5: gul.protocol.v1.BoundingBox
-	(*FenceItem)(nil),       // 6: gul.protocol.v1.FenceItem
-	(*FenceCollection)(nil), // 7: gul.protocol.v1.FenceCollection
-	(*FenceDelta)(nil),      // 8: gul.protocol.v1.FenceDelta
-	(*DeltaFile)(nil),       // 9: gul.protocol.v1.DeltaFile
+	(AltitudeReference)(0),  // 1: gul.protocol.v1.AltitudeReference
+	(AircraftCategory)(0),   // 2: gul.protocol.v1.AircraftCategory
+	(OperationType)(0),      // 3: gul.protocol.v1.OperationType
+	(EdgeType)(0),           // 4: gul.protocol.v1.EdgeType
+	(*AltitudeBand)(nil),    // 5: gul.protocol.v1.AltitudeBand
+	(*Schedule)(nil),        // 6: gul.protocol.v1.Schedule
+	(*WeeklyRule)(nil),      // 7: gul.protocol.v1.WeeklyRule
+	(*Conditions)(nil),      // 8: gul.protocol.v1.Conditions
+	(*Geometry)(nil),        // 9: gul.protocol.v1.Geometry
+	(*Polygon)(nil),         // 10: gul.protocol.v1.Polygon
+	(*Ring)(nil),            // 11: gul.protocol.v1.Ring
+	(*MultiPolygon)(nil),    // 12: gul.protocol.v1.MultiPolygon
+	(*Corridor)(nil),        // 13: gul.protocol.v1.Corridor
+	(*Circle)(nil),          // 14: gul.protocol.v1.Circle
+	(*Point)(nil),           // 15: gul.protocol.v1.Point
+	(*BoundingBox)(nil),     // 16: gul.protocol.v1.BoundingBox
+	(*FenceItem)(nil),       // 17: gul.protocol.v1.FenceItem
+	(*FenceCollection)(nil), // 18: gul.protocol.v1.FenceCollection
+	(*FenceDelta)(nil),      // 19: gul.protocol.v1.FenceDelta
+	(*DeltaFile)(nil),       // 20: gul.protocol.v1.DeltaFile
+	(*FenceDeltaFile)(nil),  // 21: gul.protocol.v1.FenceDeltaFile
 }
 var file_pkg_protocol_protobuf_fence_proto_depIdxs = []int32{
-	2,  // 0: gul.protocol.v1.Geometry.polygon:type_name -> gul.protocol.v1.Polygon
-	3,  // 1: gul.protocol.v1.Geometry.circle:type_name -> gul.protocol.v1.Circle
-	5,  // 2: gul.protocol.v1.Geometry.bbox:type_name -> gul.protocol.v1.BoundingBox
-	4,  // 3: gul.protocol.v1.Polygon.coordinates:type_name -> gul.protocol.v1.Point
-	4,  // 4: gul.protocol.v1.Circle.center:type_name -> gul.protocol.v1.Point
-	0,  // 5: gul.protocol.v1.FenceItem.type:type_name -> gul.protocol.v1.FenceType
-	1,  // 6: gul.protocol.v1.FenceItem.geometry:type_name -> gul.protocol.v1.Geometry
-	6,  // 7: gul.protocol.v1.FenceCollection.items:type_name -> gul.protocol.v1.FenceItem
-	6,  // 8: gul.protocol.v1.FenceDelta.added:type_name -> gul.protocol.v1.FenceItem
-	6,  // 9: gul.protocol.v1.FenceDelta.updated:type_name -> gul.protocol.v1.FenceItem
-	10, // [10:10] is the sub-list for method output_type
-	10, // [10:10] is the sub-list for method input_type
-	10, // [10:10] is the sub-list for extension type_name
-	10, // [10:10] is the sub-list for extension extendee
-	0,  // [0:10] is the sub-list for field type_name
+	1,  // 0: gul.protocol.v1.AltitudeBand.reference:type_name -> gul.protocol.v1.AltitudeReference
+	7,  // 1: gul.protocol.v1.Schedule.rules:type_name -> gul.protocol.v1.WeeklyRule
+	2,  // 2: gul.protocol.v1.Conditions.categories:type_name -> gul.protocol.v1.AircraftCategory
+	3,  // 3: gul.protocol.v1.Conditions.operations:type_name -> gul.protocol.v1.OperationType
+	3,  // 4: gul.protocol.v1.Conditions.exempt_operations:type_name -> gul.protocol.v1.OperationType
+	10, // 5: gul.protocol.v1.Geometry.polygon:type_name -> gul.protocol.v1.Polygon
+	14, // 6: gul.protocol.v1.Geometry.circle:type_name -> gul.protocol.v1.Circle
+	16, // 7: gul.protocol.v1.Geometry.bbox:type_name -> gul.protocol.v1.BoundingBox
+	12, // 8: gul.protocol.v1.Geometry.multi_polygon:type_name -> gul.protocol.v1.MultiPolygon
+	13, // 9: gul.protocol.v1.Geometry.corridor:type_name -> gul.protocol.v1.Corridor
+	4,  // 10: gul.protocol.v1.Geometry.edge_type:type_name -> gul.protocol.v1.EdgeType
+	15, // 11: gul.protocol.v1.Polygon.coordinates:type_name -> gul.protocol.v1.Point
+	11, // 12: gul.protocol.v1.Polygon.holes:type_name -> gul.protocol.v1.Ring
+	15, // 13: gul.protocol.v1.Ring.coordinates:type_name -> gul.protocol.v1.Point
+	10, // 14: gul.protocol.v1.MultiPolygon.polygons:type_name -> gul.protocol.v1.Polygon
+	15, // 15: gul.protocol.v1.Circle.center:type_name -> gul.protocol.v1.Point
+	0,  // 16: gul.protocol.v1.FenceItem.type:type_name -> gul.protocol.v1.FenceType
+	9,  // 17: gul.protocol.v1.FenceItem.geometry:type_name -> gul.protocol.v1.Geometry
+	5,  // 18: gul.protocol.v1.FenceItem.altitude_band:type_name -> gul.protocol.v1.AltitudeBand
+	6,  // 19: gul.protocol.v1.FenceItem.schedule:type_name -> gul.protocol.v1.Schedule
+	8,  // 20: gul.protocol.v1.FenceItem.conditions:type_name -> gul.protocol.v1.Conditions
+	17, // 21: gul.protocol.v1.FenceCollection.items:type_name -> gul.protocol.v1.FenceItem
+	17, // 22: gul.protocol.v1.FenceDelta.added:type_name -> gul.protocol.v1.FenceItem
+	17, // 23: gul.protocol.v1.FenceDelta.updated:type_name -> gul.protocol.v1.FenceItem
+	19, // 24: gul.protocol.v1.FenceDeltaFile.delta:type_name -> gul.protocol.v1.FenceDelta
+	25, // [25:25] is the sub-list for method output_type
+	25, // [25:25] is the sub-list for method input_type
+	25, // [25:25] is the sub-list for extension type_name
+	25, // [25:25] is the sub-list for extension extendee
+	0,  // [0:25] is the sub-list for field type_name
 }
 
 func init() { file_pkg_protocol_protobuf_fence_proto_init() }
@@ -892,18 +1829,20 @@ func file_pkg_protocol_protobuf_fence_proto_init() {
 	if File_pkg_protocol_protobuf_fence_proto != nil {
 		return
 	}
-	file_pkg_protocol_protobuf_fence_proto_msgTypes[0].OneofWrappers = []any{
+	file_pkg_protocol_protobuf_fence_proto_msgTypes[4].OneofWrappers = []any{
 		(*Geometry_Polygon)(nil),
 		(*Geometry_Circle)(nil),
 		(*Geometry_Bbox)(nil),
+		(*Geometry_MultiPolygon)(nil),
+		(*Geometry_Corridor)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pkg_protocol_protobuf_fence_proto_rawDesc), len(file_pkg_protocol_protobuf_fence_proto_rawDesc)),
-			NumEnums:      1,
-			NumMessages:   9,
+			NumEnums:      5,
+			NumMessages:   17,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -54,12 +54,25 @@ type Manifest struct {
 	// Human-readable message about this update
 	Message string `protobuf:"bytes,11,opt,name=message,proto3" json:"message,omitempty"`
 	// Signature of the manifest (Ed25519)
-	// Computed over all fields except this signature field
+	// Computed over all fields except this signature field and key_id,
+	// in the encoding selected by format_version
 	Signature []byte `protobuf:"bytes,12,opt,name=signature,proto3" json:"signature,omitempty"`
 	// Public key ID that can verify this signature
-	KeyId         string `protobuf:"bytes,13,opt,name=key_id,json=keyId,proto3" json:"key_id,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	KeyId string `protobuf:"bytes,13,opt,name=key_id,json=keyId,proto3" json:"key_id,omitempty"`
+	// Encoding the signatures and Merkle leaves of this version are computed
+	// over: 0 or 1 = JSON, 2 = deterministic protobuf
+	FormatVersion uint32 `protobuf:"varint,14,opt,name=format_version,json=formatVersion,proto3" json:"format_version,omitempty"`
+	// Co-signatures by additional key holders, over the same data as
+	// signature. Not covered by any signature
+	Signatures []*ManifestSignature `protobuf:"bytes,15,rep,name=signatures,proto3" json:"signatures,omitempty"`
+	// Compressed copies of the snapshot; snapshot_size and snapshot_hash are
+	// those of the uncompressed file
+	SnapshotEncodings []*FileEncoding `protobuf:"bytes,16,rep,name=snapshot_encodings,json=snapshotEncodings,proto3" json:"snapshot_encodings,omitempty"`
+	// Compressed copies of the delta; delta_size and delta_hash are those of
+	// the uncompressed file
+	DeltaEncodings []*FileEncoding `protobuf:"bytes,17,rep,name=delta_encodings,json=deltaEncodings,proto3" json:"delta_encodings,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
 }
 
 func (x *Manifest) Reset() {
@@ -183,6 +196,161 @@ func (x *Manifest) GetKeyId() string {
 	return ""
 }
 
+func (x *Manifest) GetFormatVersion() uint32 {
+	if x != nil {
+		return x.FormatVersion
+	}
+	return 0
+}
+
+func (x *Manifest) GetSignatures() []*ManifestSignature {
+	if x != nil {
+		return x.Signatures
+	}
+	return nil
+}
+
+func (x *Manifest) GetSnapshotEncodings() []*FileEncoding {
+	if x != nil {
+		return x.SnapshotEncodings
+	}
+	return nil
+}
+
+func (x *Manifest) GetDeltaEncodings() []*FileEncoding {
+	if x != nil {
+		return x.DeltaEncodings
+	}
+	return nil
+}
+
+// FileEncoding is a compressed copy of a published file
+type FileEncoding struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Compression codec: "gzip" or "zstd"
+	Codec string `protobuf:"bytes,1,opt,name=codec,proto3" json:"codec,omitempty"`
+	// URL path of the compressed file, relative to the CDN base URL
+	Url string `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
+	// Size of the compressed file in bytes
+	Size uint64 `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
+	// Hash of the compressed file (SHA-256) for download verification
+	Hash          []byte `protobuf:"bytes,4,opt,name=hash,proto3" json:"hash,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileEncoding) Reset() {
+	*x = FileEncoding{}
+	mi := &file_pkg_protocol_protobuf_manifest_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileEncoding) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileEncoding) ProtoMessage() {}
+
+func (x *FileEncoding) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_protocol_protobuf_manifest_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileEncoding.ProtoReflect.Descriptor instead.
+func (*FileEncoding) Descriptor() ([]byte, []int) {
+	return file_pkg_protocol_protobuf_manifest_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *FileEncoding) GetCodec() string {
+	if x != nil {
+		return x.Codec
+	}
+	return ""
+}
+
+func (x *FileEncoding) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *FileEncoding) GetSize() uint64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *FileEncoding) GetHash() []byte {
+	if x != nil {
+		return x.Hash
+	}
+	return nil
+}
+
+// ManifestSignature is one Ed25519 signature of a manifest
+type ManifestSignature struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Signature []byte                 `protobuf:"bytes,1,opt,name=signature,proto3" json:"signature,omitempty"`
+	// Public key ID that can verify this signature
+	KeyId         string `protobuf:"bytes,2,opt,name=key_id,json=keyId,proto3" json:"key_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ManifestSignature) Reset() {
+	*x = ManifestSignature{}
+	mi := &file_pkg_protocol_protobuf_manifest_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ManifestSignature) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ManifestSignature) ProtoMessage() {}
+
+func (x *ManifestSignature) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_protocol_protobuf_manifest_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ManifestSignature.ProtoReflect.Descriptor instead.
+func (*ManifestSignature) Descriptor() ([]byte, []int) {
+	return file_pkg_protocol_protobuf_manifest_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ManifestSignature) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+func (x *ManifestSignature) GetKeyId() string {
+	if x != nil {
+		return x.KeyId
+	}
+	return ""
+}
+
 // ManifestRequest is used for querying specific manifest versions
 type ManifestRequest struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
@@ -196,7 +364,7 @@ type ManifestRequest struct {
 
 func (x *ManifestRequest) Reset() {
 	*x = ManifestRequest{}
-	mi := &file_pkg_protocol_protobuf_manifest_proto_msgTypes[1]
+	mi := &file_pkg_protocol_protobuf_manifest_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -208,7 +376,7 @@ func (x *ManifestRequest) String() string {
 func (*ManifestRequest) ProtoMessage() {}
 
 func (x *ManifestRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_protocol_protobuf_manifest_proto_msgTypes[1]
+	mi := &file_pkg_protocol_protobuf_manifest_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -221,7 +389,7 @@ func (x *ManifestRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ManifestRequest.ProtoReflect.Descriptor instead.
 func (*ManifestRequest) Descriptor() ([]byte, []int) {
-	return file_pkg_protocol_protobuf_manifest_proto_rawDescGZIP(), []int{1}
+	return file_pkg_protocol_protobuf_manifest_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *ManifestRequest) GetVersion() uint64 {
@@ -252,7 +420,7 @@ type ManifestResponse struct {
 
 func (x *ManifestResponse) Reset() {
 	*x = ManifestResponse{}
-	mi := &file_pkg_protocol_protobuf_manifest_proto_msgTypes[2]
+	mi := &file_pkg_protocol_protobuf_manifest_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -264,7 +432,7 @@ func (x *ManifestResponse) String() string {
 func (*ManifestResponse) ProtoMessage() {}
 
 func (x *ManifestResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_protocol_protobuf_manifest_proto_msgTypes[2]
+	mi := &file_pkg_protocol_protobuf_manifest_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -277,7 +445,7 @@ func (x *ManifestResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ManifestResponse.ProtoReflect.Descriptor instead.
 func (*ManifestResponse) Descriptor() ([]byte, []int) {
-	return file_pkg_protocol_protobuf_manifest_proto_rawDescGZIP(), []int{2}
+	return file_pkg_protocol_protobuf_manifest_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *ManifestResponse) GetManifest() *Manifest {
@@ -305,7 +473,7 @@ var File_pkg_protocol_protobuf_manifest_proto protoreflect.FileDescriptor
 
 const file_pkg_protocol_protobuf_manifest_proto_rawDesc = "" +
 	"\n" +
-	"$pkg/protocol/protobuf/manifest.proto\x12\x0fgul.protocol.v1\"\xa4\x03\n" +
+	"$pkg/protocol/protobuf/manifest.proto\x12\x0fgul.protocol.v1\"\xa5\x05\n" +
 	"\bManifest\x12\x18\n" +
 	"\aversion\x18\x01 \x01(\x04R\aversion\x12\x1c\n" +
 	"\ttimestamp\x18\x02 \x01(\x03R\ttimestamp\x12\x1b\n" +
@@ -322,7 +490,21 @@ const file_pkg_protocol_protobuf_manifest_proto_rawDesc = "" +
 	" \x01(\rR\x10minClientVersion\x12\x18\n" +
 	"\amessage\x18\v \x01(\tR\amessage\x12\x1c\n" +
 	"\tsignature\x18\f \x01(\fR\tsignature\x12\x15\n" +
-	"\x06key_id\x18\r \x01(\tR\x05keyId\"R\n" +
+	"\x06key_id\x18\r \x01(\tR\x05keyId\x12%\n" +
+	"\x0eformat_version\x18\x0e \x01(\rR\rformatVersion\x12B\n" +
+	"\n" +
+	"signatures\x18\x0f \x03(\v2\".gul.protocol.v1.ManifestSignatureR\n" +
+	"signatures\x12L\n" +
+	"\x12snapshot_encodings\x18\x10 \x03(\v2\x1d.gul.protocol.v1.FileEncodingR\x11snapshotEncodings\x12F\n" +
+	"\x0fdelta_encodings\x18\x11 \x03(\v2\x1d.gul.protocol.v1.FileEncodingR\x0edeltaEncodings\"^\n" +
+	"\fFileEncoding\x12\x14\n" +
+	"\x05codec\x18\x01 \x01(\tR\x05codec\x12\x10\n" +
+	"\x03url\x18\x02 \x01(\tR\x03url\x12\x12\n" +
+	"\x04size\x18\x03 \x01(\x04R\x04size\x12\x12\n" +
+	"\x04hash\x18\x04 \x01(\fR\x04hash\"H\n" +
+	"\x11ManifestSignature\x12\x1c\n" +
+	"\tsignature\x18\x01 \x01(\fR\tsignature\x12\x15\n" +
+	"\x06key_id\x18\x02 \x01(\tR\x05keyId\"R\n" +
 	"\x0fManifestRequest\x12\x18\n" +
 	"\aversion\x18\x01 \x01(\x04R\aversion\x12%\n" +
 	"\x0eclient_version\x18\x02 \x01(\rR\rclientVersion\"\x94\x01\n" +
@@ -344,19 +526,24 @@ func file_pkg_protocol_protobuf_manifest_proto_rawDescGZIP() []byte {
 	return file_pkg_protocol_protobuf_manifest_proto_rawDescData
 }
 
-var file_pkg_protocol_protobuf_manifest_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_pkg_protocol_protobuf_manifest_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
 var file_pkg_protocol_protobuf_manifest_proto_goTypes = []any{
-	(*Manifest)(nil),         // 0: gul.protocol.v1.Manifest
-	(*ManifestRequest)(nil),  // 1: gul.protocol.v1.ManifestRequest
-	(*ManifestResponse)(nil), // 2: gul.protocol.v1.ManifestResponse
+	(*Manifest)(nil),          // 0: gul.protocol.v1.Manifest
+	(*FileEncoding)(nil),      // 1: gul.protocol.v1.FileEncoding
+	(*ManifestSignature)(nil), // 2: gul.protocol.v1.ManifestSignature
+	(*ManifestRequest)(nil),   // 3: gul.protocol.v1.ManifestRequest
+	(*ManifestResponse)(nil),  // 4: gul.protocol.v1.ManifestResponse
 }
 var file_pkg_protocol_protobuf_manifest_proto_depIdxs = []int32{
-	0, // 0: gul.protocol.v1.ManifestResponse.manifest:type_name -> gul.protocol.v1.Manifest
-	1, // [1:1] is the sub-list for method output_type
-	1, // [1:1] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	2, // 0: gul.protocol.v1.Manifest.signatures:type_name -> gul.protocol.v1.ManifestSignature
+	1, // 1: gul.protocol.v1.Manifest.snapshot_encodings:type_name -> gul.protocol.v1.FileEncoding
+	1, // 2: gul.protocol.v1.Manifest.delta_encodings:type_name -> gul.protocol.v1.FileEncoding
+	0, // 3: gul.protocol.v1.ManifestResponse.manifest:type_name -> gul.protocol.v1.Manifest
+	4, // [4:4] is the sub-list for method output_type
+	4, // [4:4] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	4, // [4:4] is the sub-list for extension extendee
+	0, // [0:4] is the sub-list for field type_name
 }
 
 func init() { file_pkg_protocol_protobuf_manifest_proto_init() }
@@ -370,7 +557,7 @@ func file_pkg_protocol_protobuf_manifest_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pkg_protocol_protobuf_manifest_proto_rawDesc), len(file_pkg_protocol_protobuf_manifest_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   3,
+			NumMessages:   5,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -0,0 +1,161 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/iannil/geofence-updater-lite/pkg/config"
+	"github.com/iannil/geofence-updater-lite/pkg/converter"
+	"github.com/iannil/geofence-updater-lite/pkg/crypto"
+	"github.com/iannil/geofence-updater-lite/pkg/geofence"
+	"github.com/iannil/geofence-updater-lite/pkg/storage"
+)
+
+// PendingManifestFile is the name of the manifest Publish writes to the
+// output directory when it needs more signatures before release.
+const PendingManifestFile = "manifest.pending.json"
+
+// PendingPatchIndexFile is the name of the patch index written alongside
+// PendingManifestFile, moved into place by ReleaseManifest.
+const PendingPatchIndexFile = "patches.pending.json"
+
+// CosignManifest adds a co-signature with the key in cfg to the manifest
+// file at path, after verifying the signatures already on it against the
+// key and cfg.TrustedKeys. It needs no database, so additional key holders
+// can sign offline. Signing again with the same key replaces the earlier
+// co-signature.
+func CosignManifest(cfg *config.PublisherConfig, path string) (*geofence.Manifest, error) {
+	signer, err := signerFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer closeSigner(cfg, signer)
+
+	manifest, err := readManifest(path)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.KeyID == signer.KeyID() {
+		return nil, fmt.Errorf("manifest is already signed by key %s", signer.KeyID())
+	}
+
+	manifestData, err := converter.ManifestSigningData(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest for signing: %w", err)
+	}
+	if _, err := verifyManifestSignatures(cfg, signer, manifest, manifestData); err != nil {
+		return nil, err
+	}
+	signature, err := signer.Sign(manifestData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign manifest: %w", err)
+	}
+	manifest.AddSignature(signature, signer.KeyID())
+
+	if err := writeManifest(manifest, path); err != nil {
+		return nil, fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+// ReleaseManifest moves the pending manifest and patch index in the output
+// directory into place, once the manifest is signed by at least
+// cfg.RequiredSignatures distinct keys, and records the released version
+// in the database. Every signature must verify against the publisher's key
+// or cfg.TrustedKeys. It returns the manifest path written.
+func ReleaseManifest(ctx context.Context, cfg *config.PublisherConfig) (string, error) {
+	pendingPath := filepath.Join(cfg.OutputDir, PendingManifestFile)
+	manifest, err := readManifest(pendingPath)
+	if err != nil {
+		return "", err
+	}
+
+	manifestData, err := converter.ManifestSigningData(manifest)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal manifest for signing: %w", err)
+	}
+	signer, err := signerFromConfig(cfg)
+	if err != nil {
+		return "", err
+	}
+	defer closeSigner(cfg, signer)
+
+	signers, err := verifyManifestSignatures(cfg, signer, manifest, manifestData)
+	if err != nil {
+		return "", err
+	}
+	if signers < cfg.RequiredSignatures {
+		return "", fmt.Errorf("manifest version %d has %d of %d required signatures", manifest.Version, signers, cfg.RequiredSignatures)
+	}
+
+	store, err := storage.Open(ctx, &storage.Config{Path: storePath(cfg)})
+	if err != nil {
+		return "", fmt.Errorf("failed to open storage: %w", err)
+	}
+	defer store.Close()
+
+	// The stored version is already the pending one if an earlier release
+	// failed after updating the database
+	current, err := store.GetVersion(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get current version: %w", err)
+	}
+	if manifest.Version != current+1 && manifest.Version != current {
+		return "", fmt.Errorf("pending manifest version %d does not follow published version %d", manifest.Version, current)
+	}
+	if err := updateStorage(ctx, store, manifest); err != nil {
+		return "", fmt.Errorf("failed to update storage: %w", err)
+	}
+
+	indexPath := filepath.Join(cfg.OutputDir, geofence.PatchIndexFile)
+	err = os.Rename(filepath.Join(cfg.OutputDir, PendingPatchIndexFile), indexPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("failed to release patch index: %w", err)
+	}
+
+	manifestPath := filepath.Join(cfg.OutputDir, "manifest.json")
+	if err := os.Rename(pendingPath, manifestPath); err != nil {
+		return "", fmt.Errorf("failed to release manifest: %w", err)
+	}
+	return manifestPath, nil
+}
+
+// verifyManifestSignatures verifies every signature on a manifest over its
+// signing data against the signer's key and cfg.TrustedKeys, and returns
+// the number of distinct keys that signed it.
+func verifyManifestSignatures(cfg *config.PublisherConfig, signer crypto.Signer, manifest *geofence.Manifest, manifestData []byte) (int, error) {
+	keys := append([]crypto.TrustedKey{{PublicKeyHex: crypto.MarshalPublicKeyHex(signer.PublicKey())}}, cfg.TrustedKeys...)
+	keyring, err := crypto.NewKeyring(keys...)
+	if err != nil {
+		return 0, fmt.Errorf("invalid trusted key: %w", err)
+	}
+
+	now := time.Now()
+	signers := make(map[string]bool)
+	for _, sig := range manifest.AllSignatures() {
+		if err := keyring.Verify(manifestData, sig.Signature, sig.KeyID, crypto.RoleSigning, now); err != nil {
+			return 0, fmt.Errorf("manifest version %d signature by key %q: %w", manifest.Version, sig.KeyID, err)
+		}
+		signers[sig.KeyID] = true
+	}
+	return len(signers), nil
+}
+
+// readManifest reads a manifest from a JSON file.
+func readManifest(path string) (*geofence.Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	var manifest geofence.Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	return &manifest, nil
+}
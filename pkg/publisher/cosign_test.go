@@ -0,0 +1,251 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/iannil/geofence-updater-lite/pkg/config"
+	"github.com/iannil/geofence-updater-lite/pkg/converter"
+	"github.com/iannil/geofence-updater-lite/pkg/crypto"
+	"github.com/iannil/geofence-updater-lite/pkg/geofence"
+)
+
+func TestCosignAndRelease(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig(t)
+	cfg.RequiredSignatures = 2
+
+	pub, err := NewPublisher(ctx, cfg)
+	if err != nil {
+		t.Fatalf("NewPublisher failed: %v", err)
+	}
+	defer pub.Close()
+
+	fences := []geofence.FenceItem{{
+		ID:   "no-fly-001",
+		Type: geofence.FenceTypePermanentNoFly,
+		Geometry: geofence.Geometry{
+			CircleCenter: &geofence.Point{Latitude: 39.9, Longitude: 116.4},
+			CircleRadius: 500,
+		},
+	}}
+	result, err := pub.Publish(ctx, fences)
+	if err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if !result.Pending || filepath.Base(result.ManifestPath) != PendingManifestFile {
+		t.Fatalf("ManifestPath = %s, Pending = %v, want a pending manifest", result.ManifestPath, result.Pending)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.OutputDir, "manifest.json")); !os.IsNotExist(err) {
+		t.Error("manifest.json should not be written before release")
+	}
+
+	if _, err := ReleaseManifest(ctx, cfg); err == nil {
+		t.Error("expected error releasing a manifest with too few signatures")
+	}
+
+	// The publisher's own key cannot count twice
+	if _, err := CosignManifest(cfg, result.ManifestPath); err == nil {
+		t.Error("expected error co-signing with the publisher's key")
+	}
+
+	cosigner, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair failed: %v", err)
+	}
+	publisherKey := publisherTrustedKey(t, cfg)
+	cosignerKey := crypto.TrustedKey{PublicKeyHex: crypto.MarshalPublicKeyHex(cosigner.PublicKey)}
+
+	// The publisher's signature is checked before co-signing
+	cosignerCfg := &config.PublisherConfig{PrivateKeyHex: crypto.MarshalPrivateKeyHex(cosigner.PrivateKey)}
+	if _, err := CosignManifest(cosignerCfg, result.ManifestPath); !errors.Is(err, crypto.ErrUnknownKey) {
+		t.Errorf("CosignManifest() error = %v, want %v without the publisher's key", err, crypto.ErrUnknownKey)
+	}
+	cosignerCfg.TrustedKeys = []crypto.TrustedKey{publisherKey}
+	manifest, err := CosignManifest(cosignerCfg, result.ManifestPath)
+	if err != nil {
+		t.Fatalf("CosignManifest failed: %v", err)
+	}
+	if len(manifest.Signatures) != 1 || manifest.Signatures[0].KeyID != cosigner.KeyID {
+		t.Errorf("Signatures = %+v, want one by %s", manifest.Signatures, cosigner.KeyID)
+	}
+
+	// Co-signatures count only once verified against a trusted key
+	if _, err := ReleaseManifest(ctx, cfg); !errors.Is(err, crypto.ErrUnknownKey) {
+		t.Errorf("ReleaseManifest() error = %v, want %v without the co-signer's key", err, crypto.ErrUnknownKey)
+	}
+	cfg.TrustedKeys = []crypto.TrustedKey{cosignerKey}
+
+	// A forged co-signature fails both co-signing and release
+	forged := *manifest
+	forged.Signatures = []geofence.ManifestSignature{{
+		KeyID:     cosigner.KeyID,
+		Signature: append([]byte{manifest.Signatures[0].Signature[0] ^ 0xff}, manifest.Signatures[0].Signature[1:]...),
+	}}
+	if err := writeManifest(&forged, result.ManifestPath); err != nil {
+		t.Fatalf("writeManifest failed: %v", err)
+	}
+	third, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair failed: %v", err)
+	}
+	thirdCfg := &config.PublisherConfig{
+		PrivateKeyHex: crypto.MarshalPrivateKeyHex(third.PrivateKey),
+		TrustedKeys:   []crypto.TrustedKey{publisherKey, cosignerKey},
+	}
+	if _, err := CosignManifest(thirdCfg, result.ManifestPath); !errors.Is(err, crypto.ErrInvalidSignature) {
+		t.Errorf("CosignManifest() error = %v, want %v for a forged co-signature", err, crypto.ErrInvalidSignature)
+	}
+	if _, err := ReleaseManifest(ctx, cfg); !errors.Is(err, crypto.ErrInvalidSignature) {
+		t.Errorf("ReleaseManifest() error = %v, want %v for a forged co-signature", err, crypto.ErrInvalidSignature)
+	}
+	if err := writeManifest(manifest, result.ManifestPath); err != nil {
+		t.Fatalf("writeManifest failed: %v", err)
+	}
+
+	path, err := ReleaseManifest(ctx, cfg)
+	if err != nil {
+		t.Fatalf("ReleaseManifest failed: %v", err)
+	}
+	released, err := readManifest(path)
+	if err != nil {
+		t.Fatalf("readManifest failed: %v", err)
+	}
+
+	// Both signatures verify for a client requiring two of them
+	keyring, err := crypto.NewKeyring(publisherKey, cosignerKey)
+	if err != nil {
+		t.Fatalf("NewKeyring failed: %v", err)
+	}
+	data, err := converter.ManifestSigningData(released)
+	if err != nil {
+		t.Fatalf("ManifestSigningData failed: %v", err)
+	}
+	var sigs []crypto.KeySignature
+	for _, sig := range released.AllSignatures() {
+		sigs = append(sigs, crypto.KeySignature{KeyID: sig.KeyID, Signature: sig.Signature})
+	}
+	if err := keyring.VerifyThreshold(data, sigs, 2, crypto.RoleSigning, time.Now()); err != nil {
+		t.Errorf("released manifest does not verify: %v", err)
+	}
+}
+
+// publisherTrustedKey returns the public key of the publisher in cfg.
+func publisherTrustedKey(t *testing.T, cfg *config.PublisherConfig) crypto.TrustedKey {
+	t.Helper()
+	privateKey, err := crypto.UnmarshalPrivateKeyHex(cfg.PrivateKeyHex)
+	if err != nil {
+		t.Fatalf("UnmarshalPrivateKeyHex failed: %v", err)
+	}
+	return crypto.TrustedKey{PublicKeyHex: crypto.MarshalPublicKeyHex(privateKey[32:])}
+}
+
+func TestPendingVersionHeldUntilRelease(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig(t)
+
+	pub, err := NewPublisher(ctx, cfg)
+	if err != nil {
+		t.Fatalf("NewPublisher failed: %v", err)
+	}
+	defer pub.Close()
+
+	fences := []geofence.FenceItem{{
+		ID:   "no-fly-001",
+		Type: geofence.FenceTypePermanentNoFly,
+		Geometry: geofence.Geometry{
+			CircleCenter: &geofence.Point{Latitude: 39.9, Longitude: 116.4},
+			CircleRadius: 500,
+		},
+	}}
+	if _, err := pub.Publish(ctx, fences); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	indexVersion := func(name string) uint64 {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(cfg.OutputDir, name))
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", name, err)
+		}
+		var index geofence.PatchIndex
+		if err := json.Unmarshal(data, &index); err != nil {
+			t.Fatalf("failed to parse %s: %v", name, err)
+		}
+		return index.Version
+	}
+	storedVersion := func() uint64 {
+		t.Helper()
+		v, err := pub.GetCurrentVersion(ctx)
+		if err != nil {
+			t.Fatalf("GetCurrentVersion failed: %v", err)
+		}
+		return v
+	}
+
+	// Publishing twice before release replaces the pending version, with
+	// deltas from the released one
+	cfg.RequiredSignatures = 2
+	for i := 0; i < 2; i++ {
+		fences[0].Geometry.CircleRadius += 100
+		result, err := pub.Publish(ctx, fences)
+		if err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+		if result.Version != 2 || result.PreviousVersion != 1 {
+			t.Errorf("Version = %d, PreviousVersion = %d, want 2 and 1", result.Version, result.PreviousVersion)
+		}
+		if result.DeltaPath != "/patches/v1_to_v2.bin" {
+			t.Errorf("DeltaPath = %s, want the delta from version 1", result.DeltaPath)
+		}
+		if v := indexVersion(geofence.PatchIndexFile); v != 1 {
+			t.Errorf("released patch index version = %d, want 1", v)
+		}
+		if v := indexVersion(PendingPatchIndexFile); v != 2 {
+			t.Errorf("pending patch index version = %d, want 2", v)
+		}
+		if v := storedVersion(); v != 1 {
+			t.Errorf("stored version = %d, want 1 before release", v)
+		}
+	}
+
+	cosigner, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair failed: %v", err)
+	}
+	cosignerCfg := &config.PublisherConfig{
+		PrivateKeyHex: crypto.MarshalPrivateKeyHex(cosigner.PrivateKey),
+		TrustedKeys:   []crypto.TrustedKey{publisherTrustedKey(t, cfg)},
+	}
+	cfg.TrustedKeys = []crypto.TrustedKey{{PublicKeyHex: crypto.MarshalPublicKeyHex(cosigner.PublicKey)}}
+	if _, err := CosignManifest(cosignerCfg, filepath.Join(cfg.OutputDir, PendingManifestFile)); err != nil {
+		t.Fatalf("CosignManifest failed: %v", err)
+	}
+	if _, err := ReleaseManifest(ctx, cfg); err != nil {
+		t.Fatalf("ReleaseManifest failed: %v", err)
+	}
+
+	if v := indexVersion(geofence.PatchIndexFile); v != 2 {
+		t.Errorf("released patch index version = %d, want 2", v)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.OutputDir, PendingPatchIndexFile)); !os.IsNotExist(err) {
+		t.Error("pending patch index should be moved on release")
+	}
+	if v := storedVersion(); v != 2 {
+		t.Errorf("stored version = %d, want 2 after release", v)
+	}
+
+	// The next version follows the released one
+	result, err := pub.Publish(ctx, fences)
+	if err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if result.Version != 3 {
+		t.Errorf("Version = %d, want 3", result.Version)
+	}
+}
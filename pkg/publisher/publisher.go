@@ -3,26 +3,33 @@
 package publisher
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"time"
 
 	"github.com/iannil/geofence-updater-lite/pkg/binarydiff"
+	"github.com/iannil/geofence-updater-lite/pkg/compression"
 	"github.com/iannil/geofence-updater-lite/pkg/config"
+	"github.com/iannil/geofence-updater-lite/pkg/converter"
 	"github.com/iannil/geofence-updater-lite/pkg/crypto"
 	"github.com/iannil/geofence-updater-lite/pkg/geofence"
 	"github.com/iannil/geofence-updater-lite/pkg/merkle"
 	"github.com/iannil/geofence-updater-lite/pkg/storage"
+	"github.com/iannil/geofence-updater-lite/pkg/validation"
 )
 
 // Publisher handles publishing of geofence updates.
 type Publisher struct {
 	store      *storage.SQLiteStore
 	cfg        *config.PublisherConfig
-	keyPair    *crypto.KeyPair
+	signer     crypto.Signer
 	currentVer uint64
 }
 
@@ -32,37 +39,21 @@ func NewPublisher(ctx context.Context, cfg *config.PublisherConfig) (*Publisher,
 		return nil, fmt.Errorf("invalid config: %w", err)
 	}
 
-	// Derive key pair from private key
-	privateKey, err := crypto.UnmarshalPrivateKeyHex(cfg.PrivateKeyHex)
+	signer, err := signerFromConfig(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("invalid private key: %w", err)
-	}
-	// Ed25519 private key (64 bytes) contains public key in last 32 bytes
-	publicKey := privateKey[32:]
-	keyPair, err := crypto.DeriveKeyPair(publicKey, privateKey)
-	if err != nil {
-		return nil, fmt.Errorf("failed to derive key pair: %w", err)
-	}
-
-	// Override key ID if provided
-	if cfg.KeyID != "" {
-		keyPair.KeyID = cfg.KeyID
+		return nil, err
 	}
 
 	// Ensure output directory exists
 	if err := os.MkdirAll(cfg.OutputDir, 0755); err != nil {
+		closeSigner(cfg, signer)
 		return nil, fmt.Errorf("failed to create output directory: %w", err)
 	}
 
-	// Determine store path (use default if not specified)
-	storePath := "./geofence.db"
-	if cfg.OutputDir != "" {
-		storePath = filepath.Join(cfg.OutputDir, "geofence.db")
-	}
-
 	// Open storage
-	store, err := storage.Open(ctx, &storage.Config{Path: storePath})
+	store, err := storage.Open(ctx, &storage.Config{Path: storePath(cfg)})
 	if err != nil {
+		closeSigner(cfg, signer)
 		return nil, fmt.Errorf("failed to open storage: %w", err)
 	}
 
@@ -75,11 +66,91 @@ func NewPublisher(ctx context.Context, cfg *config.PublisherConfig) (*Publisher,
 	return &Publisher{
 		store:      store,
 		cfg:        cfg,
-		keyPair:    keyPair,
+		signer:     signer,
 		currentVer: currentVer,
 	}, nil
 }
 
+// storePath returns the path of the publisher database, in the output
+// directory if one is configured.
+func storePath(cfg *config.PublisherConfig) string {
+	if cfg.OutputDir != "" {
+		return filepath.Join(cfg.OutputDir, "geofence.db")
+	}
+	return "./geofence.db"
+}
+
+// signerFromConfig returns the configured signer, connects to the external
+// signing service, or derives the publisher's key pair from its private
+// key, decrypting the private key file with the configured passphrase.
+func signerFromConfig(cfg *config.PublisherConfig) (crypto.Signer, error) {
+	switch {
+	case cfg.Signer != nil:
+		return cfg.Signer, nil
+	case cfg.SignerSocket != "":
+		return crypto.DialSigner(cfg.SignerSocket, cfg.KeyID)
+	case len(cfg.SignerCommand) > 0:
+		return crypto.StartSigner(cfg.KeyID, cfg.SignerCommand[0], cfg.SignerCommand[1:]...)
+	}
+
+	var keyPair *crypto.KeyPair
+	if cfg.PrivateKeyFile != "" {
+		var err error
+		if keyPair, err = decryptKeyFile(cfg); err != nil {
+			return nil, err
+		}
+	} else {
+		privateKey, err := crypto.UnmarshalPrivateKeyHex(cfg.PrivateKeyHex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid private key: %w", err)
+		}
+		// Ed25519 private key (64 bytes) contains public key in last 32 bytes
+		publicKey := privateKey[32:]
+		keyPair, err = crypto.DeriveKeyPair(publicKey, privateKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive key pair: %w", err)
+		}
+	}
+
+	// Override key ID if provided
+	if cfg.KeyID != "" {
+		keyPair.KeyID = cfg.KeyID
+	}
+	return crypto.NewMemorySigner(keyPair), nil
+}
+
+// closeSigner closes a signer created by signerFromConfig. Signers passed
+// in the config belong to the caller.
+func closeSigner(cfg *config.PublisherConfig, signer crypto.Signer) error {
+	if closer, ok := signer.(io.Closer); ok && cfg.Signer == nil {
+		return closer.Close()
+	}
+	return nil
+}
+
+// decryptKeyFile reads the encrypted private key file and decrypts it in
+// memory with the passphrase from cfg.Passphrase.
+func decryptKeyFile(cfg *config.PublisherConfig) (*crypto.KeyPair, error) {
+	encrypted, err := crypto.ReadEncryptedKeyFile(cfg.PrivateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Passphrase == nil {
+		return nil, fmt.Errorf("no passphrase for private key file %s", cfg.PrivateKeyFile)
+	}
+	passphrase, err := cfg.Passphrase()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	defer clear(passphrase)
+
+	keyPair, err := encrypted.Decrypt(passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt private key file %s: %w", cfg.PrivateKeyFile, err)
+	}
+	return keyPair, nil
+}
+
 // PublishResult contains the result of a publish operation.
 type PublishResult struct {
 	Version         uint64
@@ -91,21 +162,32 @@ type PublishResult struct {
 	DeltaSize       int64
 	SnapshotSize    int64
 	PublishTime     time.Time
+	// Pending is set if the manifest was written to PendingManifestFile to
+	// be co-signed before release
+	Pending bool
 }
 
 // Publish creates and publishes a new version with the given fences.
 func (p *Publisher) Publish(ctx context.Context, fences []geofence.FenceItem) (*PublishResult, error) {
 	startTime := time.Now()
 
-	// Get old fences for delta calculation (ignore error - empty slice if none exist)
-	oldFences, err := p.getCurrentFences(ctx)
-	if err != nil {
-		oldFences = nil // Will skip delta generation
+	// Pick up versions released since the publisher was opened
+	if v, err := p.store.GetVersion(ctx); err == nil {
+		p.currentVer = v
 	}
 
-	// Increment version
+	// Increment version. Pending versions are not stored until released,
+	// so publishing again before then replaces the pending version.
 	newVersion := p.currentVer + 1
 
+	// Refuse to sign invalid fences
+	for i := range fences {
+		validation.Normalize(&fences[i])
+	}
+	if err := validation.ValidateFences(fences).Err(); err != nil {
+		return nil, fmt.Errorf("invalid fences: %w", err)
+	}
+
 	// Sign each fence
 	for i := range fences {
 		if err := p.signFence(&fences[i]); err != nil {
@@ -114,7 +196,7 @@ func (p *Publisher) Publish(ctx context.Context, fences []geofence.FenceItem) (*
 	}
 
 	// Build Merkle tree
-	tree, err := merkle.NewTree(fences)
+	tree, err := merkle.NewTreeFormat(fences, p.formatVersion())
 	if err != nil {
 		return nil, fmt.Errorf("failed to build Merkle tree: %w", err)
 	}
@@ -131,21 +213,42 @@ func (p *Publisher) Publish(ctx context.Context, fences []geofence.FenceItem) (*
 	// Compute snapshot hash
 	snapshotHash := crypto.ComputeSHA256(snapshotData)
 
-	// Create delta if there's a previous version
-	var deltaData []byte
+	snapshotURL := fmt.Sprintf("/snapshots/v%d.bin", newVersion)
+	snapshotPath := filepath.Join(p.cfg.OutputDir, fmt.Sprintf("v%d.bin", newVersion))
+	snapshotEncodings, err := p.writeEncodings(snapshotPath, snapshotURL, snapshotData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write compressed snapshot: %w", err)
+	}
+
+	// Create deltas from the previous version and, for clients far
+	// behind, from an earlier one
+	var patches []geofence.PatchInfo
 	var deltaSize int64
 	var deltaPath string
 	var deltaHash []byte
+	var deltaEncodings []geofence.FileEncoding
 
-	if newVersion > 1 && len(oldFences) > 0 {
-		delta, err := binarydiff.Diff(oldFences, fences)
-		if err == nil {
-			delta.FromVersion = p.currentVer
-			delta.ToVersion = newVersion
-			deltaData = delta.DiffData
-			deltaSize = int64(len(deltaData))
-			deltaHash = delta.DiffHash
-			deltaPath = fmt.Sprintf("/patches/v%d_to_v%d.bin", p.currentVer, newVersion)
+	fromVersions := []uint64{p.currentVer}
+	if skip := uint64(p.cfg.SkipDeltaInterval); skip > 1 && newVersion > skip {
+		fromVersions = append(fromVersions, newVersion-skip)
+	}
+	for _, fromVer := range fromVersions {
+		if fromVer == 0 {
+			continue
+		}
+		patch, err := p.writeDelta(fromVer, newVersion, fences)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue // No snapshot to diff against; clients use the snapshot
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create delta from version %d: %w", fromVer, err)
+		}
+		patches = append(patches, *patch)
+		if fromVer == p.currentVer {
+			deltaPath = patch.URL
+			deltaSize = int64(patch.Size)
+			deltaHash = patch.Hash
+			deltaEncodings = patch.Encodings
 		}
 	}
 
@@ -154,75 +257,248 @@ func (p *Publisher) Publish(ctx context.Context, fences []geofence.FenceItem) (*
 		Version:      newVersion,
 		Timestamp:    time.Now().Unix(),
 		RootHash:     rootHash[:],
-		SnapshotURL:  fmt.Sprintf("/snapshots/v%d.bin", newVersion),
+		SnapshotURL:  snapshotURL,
 		SnapshotSize: uint64(snapshotSize),
 		SnapshotHash: snapshotHash,
 		Message:      fmt.Sprintf("Version %d - %d fences", newVersion, len(fences)),
+
+		SnapshotEncodings: snapshotEncodings,
+	}
+	if p.formatVersion() != geofence.FormatVersionJSON {
+		// Left unset for JSON so that older clients verify the same bytes
+		manifest.FormatVersion = p.formatVersion()
 	}
 
 	if deltaPath != "" {
 		manifest.DeltaURL = deltaPath
 		manifest.DeltaSize = uint64(deltaSize)
 		manifest.DeltaHash = deltaHash
+		manifest.DeltaEncodings = deltaEncodings
 	}
 
 	// Sign manifest
-	manifestData, err := manifest.MarshalBinaryForSigning()
+	manifestData, err := converter.ManifestSigningData(manifest)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal manifest for signing: %w", err)
 	}
-	signature, err := p.keyPair.Sign(manifestData)
+	signature, err := p.signer.Sign(manifestData)
 	if err != nil {
 		return nil, fmt.Errorf("failed to sign manifest: %w", err)
 	}
-	manifest.SetSignature(signature, p.keyPair.KeyID)
+	manifest.SetSignature(signature, p.signer.KeyID())
 
 	// Write files
-	snapshotPath := filepath.Join(p.cfg.OutputDir, fmt.Sprintf("v%d.bin", newVersion))
 	if err := os.WriteFile(snapshotPath, snapshotData, 0644); err != nil {
 		return nil, fmt.Errorf("failed to write snapshot: %w", err)
 	}
 
+	// Versions needing more signatures are held back until released: the
+	// manifest and patch index go to pending files, and the stored version
+	// is left for ReleaseManifest to update
+	pending := p.cfg.RequiredSignatures > 1
 	manifestPath := filepath.Join(p.cfg.OutputDir, "manifest.json")
-	if err := p.writeManifest(manifest, manifestPath); err != nil {
-		return nil, fmt.Errorf("failed to write manifest: %w", err)
-	}
-
-	// Write delta if exists
-	if len(deltaData) > 0 {
-		deltaFullPath := filepath.Join(p.cfg.OutputDir, deltaPath[1:]) // Remove leading /
-		deltaDir := filepath.Dir(deltaFullPath)
-		if err := os.MkdirAll(deltaDir, 0755); err != nil {
-			return nil, fmt.Errorf("failed to create delta directory: %w", err)
-		}
-		if err := os.WriteFile(deltaFullPath, deltaData, 0644); err != nil {
-			return nil, fmt.Errorf("failed to write delta: %w", err)
+	indexPath := filepath.Join(p.cfg.OutputDir, geofence.PatchIndexFile)
+	if pending {
+		manifestPath = filepath.Join(p.cfg.OutputDir, PendingManifestFile)
+		indexPath = filepath.Join(p.cfg.OutputDir, PendingPatchIndexFile)
+	} else {
+		// Update storage with the new manifest before advertising the
+		// version, so a failure leaves the published files at the old one
+		if err := updateStorage(ctx, p.store, manifest); err != nil {
+			return nil, fmt.Errorf("failed to update storage: %w", err)
 		}
 	}
 
-	// Update storage with new fences and manifest
-	if err := p.updateStorage(ctx, fences, manifest); err != nil {
-		return nil, fmt.Errorf("failed to update storage: %w", err)
+	if err := p.writePatchIndex(indexPath, newVersion, patches); err != nil {
+		return nil, fmt.Errorf("failed to write patch index: %w", err)
+	}
+	if err := writeManifest(manifest, manifestPath); err != nil {
+		return nil, fmt.Errorf("failed to write manifest: %w", err)
 	}
 
-	// Update current version
-	p.currentVer = newVersion
+	previousVer := p.currentVer
+	if !pending {
+		p.currentVer = newVersion
+	}
 
 	return &PublishResult{
 		Version:         newVersion,
 		ManifestPath:    manifestPath,
 		SnapshotPath:    snapshotPath,
 		DeltaPath:       deltaPath,
-		PreviousVersion: p.currentVer - 1,
+		PreviousVersion: previousVer,
 		FencesCount:     len(fences),
 		DeltaSize:       deltaSize,
 		SnapshotSize:    snapshotSize,
 		PublishTime:     startTime,
+		Pending:         pending,
+	}, nil
+}
+
+// writeDelta writes the signed fence delta and the binary diff from the
+// published version fromVer to the fences of version toVer to the patches
+// directory and returns their description for the patch index. The base is
+// read from fromVer's snapshot, since fences may have changed in the
+// database since it was published.
+func (p *Publisher) writeDelta(fromVer, toVer uint64, fences []geofence.FenceItem) (*geofence.PatchInfo, error) {
+	snapshotData, err := os.ReadFile(filepath.Join(p.cfg.OutputDir, fmt.Sprintf("v%d.bin", fromVer)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	oldFences, err := merkle.LoadSnapshot(snapshotData)
+	if err != nil {
+		return nil, err
+	}
+
+	delta, err := merkle.NewFenceDeltaFile(oldFences, fences, fromVer, toVer, p.formatVersion())
+	if err != nil {
+		return nil, err
+	}
+	signingData, err := converter.FenceDeltaSigningData(delta)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := p.signer.Sign(signingData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign delta: %w", err)
+	}
+	delta.SetSignature(signature, p.signer.KeyID())
+	data, err := converter.MarshalFenceDeltaFile(delta)
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("/patches/v%d_to_v%d.bin", fromVer, toVer)
+	deltaFullPath := filepath.Join(p.cfg.OutputDir, url[1:]) // Remove leading /
+	if err := os.MkdirAll(filepath.Dir(deltaFullPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create delta directory: %w", err)
+	}
+	if err := os.WriteFile(deltaFullPath, data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write delta: %w", err)
+	}
+	encodings, err := p.writeEncodings(deltaFullPath, url, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write compressed delta: %w", err)
+	}
+
+	binary, err := p.writeBinaryDelta(oldFences, fences, fromVer, toVer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write binary delta: %w", err)
+	}
+
+	return &geofence.PatchInfo{
+		FromVersion:   fromVer,
+		ToVersion:     toVer,
+		URL:           url,
+		Size:          uint64(len(data)),
+		Hash:          crypto.ComputeSHA256(data),
+		RootHash:      delta.ToRootHash,
+		FormatVersion: delta.FormatVersion,
+		Encodings:     encodings,
+		Binary:        binary,
+	}, nil
+}
+
+// writeBinaryDelta writes the binary diff from the fences of version
+// fromVer to those of version toVer next to their fence delta.
+func (p *Publisher) writeBinaryDelta(oldFences, newFences []geofence.FenceItem, fromVer, toVer uint64) (*geofence.BinaryPatch, error) {
+	var buf bytes.Buffer
+	if err := binarydiff.WriteDeltaFile(oldFences, newFences, fromVer, toVer, &buf); err != nil {
+		return nil, err
+	}
+	data := buf.Bytes()
+
+	url := fmt.Sprintf("/patches/v%d_to_v%d.bindiff", fromVer, toVer)
+	path := filepath.Join(p.cfg.OutputDir, url[1:])
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return nil, err
+	}
+	encodings, err := p.writeEncodings(path, url, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &geofence.BinaryPatch{
+		URL:       url,
+		Size:      uint64(len(data)),
+		Hash:      crypto.ComputeSHA256(data),
+		Encodings: encodings,
 	}, nil
 }
 
+// writeEncodings writes a copy of data, the file at path published at url,
+// compressed with each codec in Compression next to it, and returns their
+// descriptions.
+func (p *Publisher) writeEncodings(path, url string, data []byte) ([]geofence.FileEncoding, error) {
+	var encodings []geofence.FileEncoding
+	for _, codec := range p.cfg.Compression {
+		compressed, err := compression.Compress(codec, data)
+		if err != nil {
+			return nil, err
+		}
+		ext := compression.Extension(codec)
+		if err := os.WriteFile(path+ext, compressed, 0644); err != nil {
+			return nil, err
+		}
+		encodings = append(encodings, geofence.FileEncoding{
+			Codec: codec,
+			URL:   url + ext,
+			Size:  uint64(len(compressed)),
+			Hash:  crypto.ComputeSHA256(compressed),
+		})
+	}
+	return encodings, nil
+}
+
+// writePatchIndex adds the patches to version newVersion to the released
+// patch index in the output directory, drops those from versions more than
+// PatchHistory behind, and writes it signed to path.
+func (p *Publisher) writePatchIndex(path string, newVersion uint64, patches []geofence.PatchInfo) error {
+	var oldest uint64
+	if history := uint64(p.cfg.PatchHistory); newVersion > history {
+		oldest = newVersion - history
+	}
+	index := &geofence.PatchIndex{Version: newVersion, Timestamp: time.Now().Unix()}
+	data, err := os.ReadFile(filepath.Join(p.cfg.OutputDir, geofence.PatchIndexFile))
+	switch {
+	case err == nil:
+		var prev geofence.PatchIndex
+		if err := json.Unmarshal(data, &prev); err != nil {
+			return fmt.Errorf("failed to parse previous patch index: %w", err)
+		}
+		for _, patch := range prev.Patches {
+			if patch.FromVersion >= oldest && patch.ToVersion < newVersion {
+				index.Patches = append(index.Patches, patch)
+			}
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("failed to read previous patch index: %w", err)
+	}
+	index.Patches = append(index.Patches, patches...)
+
+	indexData, err := index.MarshalBinaryForSigning()
+	if err != nil {
+		return err
+	}
+	signature, err := p.signer.Sign(indexData)
+	if err != nil {
+		return fmt.Errorf("failed to sign patch index: %w", err)
+	}
+	index.SetSignature(signature, p.signer.KeyID())
+
+	data, err = json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal patch index: %w", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
 // SignAndAdd signs and adds a single fence to the database.
 func (p *Publisher) SignAndAdd(ctx context.Context, fence *geofence.FenceItem) error {
+	if err := validateFence(fence); err != nil {
+		return err
+	}
+
 	// Sign the fence
 	if err := p.signFence(fence); err != nil {
 		return err
@@ -238,6 +514,10 @@ func (p *Publisher) SignAndAdd(ctx context.Context, fence *geofence.FenceItem) e
 
 // SignAndUpdate signs and updates a fence in the database.
 func (p *Publisher) SignAndUpdate(ctx context.Context, fence *geofence.FenceItem) error {
+	if err := validateFence(fence); err != nil {
+		return err
+	}
+
 	// Sign the fence
 	if err := p.signFence(fence); err != nil {
 		return err
@@ -251,70 +531,133 @@ func (p *Publisher) SignAndUpdate(ctx context.Context, fence *geofence.FenceItem
 	return nil
 }
 
+// validateFence normalizes a fence and checks that it may be signed.
+func validateFence(fence *geofence.FenceItem) error {
+	validation.Normalize(fence)
+	if err := validation.ValidateFence(fence).Err(); err != nil {
+		return fmt.Errorf("invalid fence: %w", err)
+	}
+	return nil
+}
+
+// formatVersion returns the format version fences and manifests are signed
+// and hashed in.
+func (p *Publisher) formatVersion() uint32 {
+	if p.cfg.FormatVersion == 0 {
+		return geofence.CurrentFormatVersion
+	}
+	return p.cfg.FormatVersion
+}
+
 // signFence signs a fence item with the publisher's key.
 func (p *Publisher) signFence(fence *geofence.FenceItem) error {
-	// Create fence data for signing (without signature field)
-	fenceData, err := json.Marshal(struct {
-		ID          string
-		Type        geofence.FenceType
-		Geometry    geofence.Geometry
-		StartTS     int64
-		EndTS       int64
-		Priority    uint32
-		MaxAltitude uint32
-		MaxSpeed    uint32
-		Name        string
-		Description string
-	}{
-		ID:          fence.ID,
-		Type:        fence.Type,
-		Geometry:    fence.Geometry,
-		StartTS:     fence.StartTS,
-		EndTS:       fence.EndTS,
-		Priority:    fence.Priority,
-		MaxAltitude: fence.MaxAltitude,
-		MaxSpeed:    fence.MaxSpeed,
-		Name:        fence.Name,
-		Description: fence.Description,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to marshal fence: %w", err)
+	// Corridor paths are distributed polyline-encoded; sign exactly the
+	// coordinates clients will decode so hashes match on both ends.
+	if c := fence.Geometry.Corridor; c != nil {
+		c.Path = geofence.QuantizePolyline(c.Path)
+	}
+
+	// Canonical signing data, shared with clients verifying the fence
+	fenceData, err := converter.FenceSigningData(fence, p.formatVersion())
+	if err != nil {
+		return err
 	}
 
 	// Sign the fence data
-	signature, err := p.keyPair.Sign(fenceData)
+	signature, err := p.signer.Sign(fenceData)
 	if err != nil {
 		return fmt.Errorf("failed to sign fence: %w", err)
 	}
-	fence.Signature = signature
-	fence.KeyID = p.keyPair.KeyID
+	fence.SetSignature(signature, p.signer.KeyID())
 
 	return nil
 }
 
-// getCurrentFences retrieves all current fences from storage.
-func (p *Publisher) getCurrentFences(ctx context.Context) ([]geofence.FenceItem, error) {
-	fencePtrs, err := p.store.ListFences(ctx)
+// IssueUnlock signs an unlock token authorizing a device to fly inside
+// restricted fences. Every fence the token names must exist in the
+// database. IssuedAt is set to the current time if it is zero.
+func (p *Publisher) IssueUnlock(ctx context.Context, token *geofence.UnlockToken) error {
+	if err := token.Validate(); err != nil {
+		return fmt.Errorf("invalid unlock token: %w", err)
+	}
+	for _, id := range token.FenceIDs {
+		if _, err := p.store.GetFence(ctx, id); err != nil {
+			return fmt.Errorf("failed to get fence %s: %w", id, err)
+		}
+	}
+	if token.IssuedAt == 0 {
+		token.IssuedAt = time.Now().Unix()
+	}
+
+	tokenData, err := token.MarshalBinaryForSigning()
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("failed to marshal unlock token for signing: %w", err)
 	}
+	signature, err := p.signer.Sign(tokenData)
+	if err != nil {
+		return fmt.Errorf("failed to sign unlock token: %w", err)
+	}
+	token.SetSignature(signature, p.signer.KeyID())
+
+	return nil
+}
+
+// AnnounceKeyRotation signs a key rotation announcement with the
+// publisher's key and writes it to keys.json in the output directory, where
+// clients fetch it. Sequence is set to one more than that of the
+// announcement already there if it is zero, and Timestamp to the current
+// time. Clients only accept it if the publisher's key is trusted for
+// rotation. It returns the path written.
+func (p *Publisher) AnnounceKeyRotation(rot *crypto.KeyRotation) (string, error) {
+	for i := range rot.Add {
+		if err := rot.Add[i].Validate(); err != nil {
+			return "", fmt.Errorf("invalid key: %w", err)
+		}
+	}
+
+	path := filepath.Join(p.cfg.OutputDir, "keys.json")
+	if rot.Sequence == 0 {
+		rot.Sequence = 1
+		if data, err := os.ReadFile(path); err == nil {
+			var previous crypto.KeyRotation
+			if err := json.Unmarshal(data, &previous); err != nil {
+				return "", fmt.Errorf("failed to parse previous key rotation: %w", err)
+			}
+			rot.Sequence = previous.Sequence + 1
+		}
+	}
+	rot.Timestamp = time.Now().Unix()
+
+	rotData, err := rot.MarshalBinaryForSigning()
+	if err != nil {
+		return "", err
+	}
+	signature, err := p.signer.Sign(rotData)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign key rotation: %w", err)
+	}
+	rot.SetSignature(signature, p.signer.KeyID())
 
-	fences := make([]geofence.FenceItem, len(fencePtrs))
-	for i, f := range fencePtrs {
-		fences[i] = *f
+	data, err := json.MarshalIndent(rot, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal key rotation: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write key rotation: %w", err)
 	}
-	return fences, nil
+	return path, nil
 }
 
-// updateStorage updates the storage with new fences and manifest.
-func (p *Publisher) updateStorage(ctx context.Context, fences []geofence.FenceItem, manifest *geofence.Manifest) error {
+// updateStorage records a published manifest and its version in the
+// store.
+func updateStorage(ctx context.Context, store *storage.SQLiteStore, manifest *geofence.Manifest) error {
 	// Store manifest
-	if err := p.store.SetManifest(ctx, manifest); err != nil {
+	if err := store.SetManifest(ctx, manifest); err != nil {
 		return fmt.Errorf("failed to store manifest: %w", err)
 	}
 
 	// Update version
-	if err := p.store.SetVersion(ctx, manifest.Version); err != nil {
+	if err := store.SetVersion(ctx, manifest.Version); err != nil {
 		return fmt.Errorf("failed to set version: %w", err)
 	}
 
@@ -322,7 +665,7 @@ func (p *Publisher) updateStorage(ctx context.Context, fences []geofence.FenceIt
 }
 
 // writeManifest writes a manifest to a JSON file.
-func (p *Publisher) writeManifest(manifest *geofence.Manifest, path string) error {
+func writeManifest(manifest *geofence.Manifest, path string) error {
 	data, err := json.MarshalIndent(manifest, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal manifest: %w", err)
@@ -352,16 +695,12 @@ func (p *Publisher) DeleteFence(ctx context.Context, id string) error {
 
 // Close closes the publisher and releases resources.
 func (p *Publisher) Close() error {
-	return p.store.Close()
+	return errors.Join(p.store.Close(), closeSigner(p.cfg, p.signer))
 }
 
 // Initialize creates a new empty database.
 func Initialize(ctx context.Context, cfg *config.PublisherConfig) error {
-	// Determine store path
-	storePath := "./geofence.db"
-	if cfg.OutputDir != "" {
-		storePath = filepath.Join(cfg.OutputDir, "geofence.db")
-	}
+	storePath := storePath(cfg)
 
 	// Remove existing database if it exists
 	if _, err := os.Stat(storePath); err == nil {
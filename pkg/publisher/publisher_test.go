@@ -1,16 +1,25 @@
 package publisher
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 	"time"
 
+	"github.com/iannil/geofence-updater-lite/internal/testutil"
+	"github.com/iannil/geofence-updater-lite/pkg/binarydiff"
+	"github.com/iannil/geofence-updater-lite/pkg/compression"
 	"github.com/iannil/geofence-updater-lite/pkg/config"
+	"github.com/iannil/geofence-updater-lite/pkg/converter"
 	"github.com/iannil/geofence-updater-lite/pkg/crypto"
 	"github.com/iannil/geofence-updater-lite/pkg/geofence"
+	"github.com/iannil/geofence-updater-lite/pkg/merkle"
 )
 
 func testConfig(t *testing.T) *config.PublisherConfig {
@@ -39,8 +48,8 @@ func TestNewPublisher(t *testing.T) {
 	}
 	defer pub.Close()
 
-	if pub.keyPair == nil {
-		t.Error("keyPair should not be nil")
+	if pub.signer == nil {
+		t.Error("signer should not be nil")
 	}
 	if pub.store == nil {
 		t.Error("store should not be nil")
@@ -60,6 +69,99 @@ func TestNewPublisher_InvalidPrivateKey(t *testing.T) {
 	}
 }
 
+func TestNewPublisher_EncryptedKeyFile(t *testing.T) {
+	ctx := context.Background()
+	kp, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair failed: %v", err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "private.key.enc")
+	if _, err := crypto.WriteEncryptedKeyFile(keyFile, kp.PrivateKey, []byte("passphrase")); err != nil {
+		t.Fatalf("WriteEncryptedKeyFile failed: %v", err)
+	}
+
+	cfg := &config.PublisherConfig{
+		PrivateKeyFile: keyFile,
+		OutputDir:      t.TempDir(),
+		CDNBaseURL:     "https://cdn.example.com/geofence",
+	}
+	if _, err := NewPublisher(ctx, cfg); err == nil {
+		t.Error("expected error without a passphrase")
+	}
+
+	cfg.Passphrase = func() ([]byte, error) { return []byte("wrong"), nil }
+	if _, err := NewPublisher(ctx, cfg); !errors.Is(err, crypto.ErrWrongPassphrase) {
+		t.Errorf("NewPublisher() error = %v, want %v", err, crypto.ErrWrongPassphrase)
+	}
+
+	cfg.Passphrase = func() ([]byte, error) { return []byte("passphrase"), nil }
+	pub, err := NewPublisher(ctx, cfg)
+	if err != nil {
+		t.Fatalf("NewPublisher failed: %v", err)
+	}
+	defer pub.Close()
+
+	if pub.signer.KeyID() != kp.KeyID {
+		t.Errorf("KeyID = %s, want %s", pub.signer.KeyID(), kp.KeyID)
+	}
+}
+
+func TestNewPublisher_Signer(t *testing.T) {
+	ctx := context.Background()
+	signer := testutil.NewSigner()
+	cfg := &config.PublisherConfig{
+		Signer:     signer,
+		OutputDir:  t.TempDir(),
+		CDNBaseURL: "https://cdn.example.com/geofence",
+	}
+
+	pub, err := NewPublisher(ctx, cfg)
+	if err != nil {
+		t.Fatalf("NewPublisher failed: %v", err)
+	}
+	defer pub.Close()
+
+	fence := &geofence.FenceItem{
+		ID:   "fence-001",
+		Type: geofence.FenceTypePermanentNoFly,
+		Geometry: geofence.Geometry{
+			CircleCenter: &geofence.Point{Latitude: 39.9, Longitude: 116.4},
+			CircleRadius: 500,
+		},
+	}
+	if err := pub.SignAndAdd(ctx, fence); err != nil {
+		t.Fatalf("SignAndAdd failed: %v", err)
+	}
+	if fence.KeyID != signer.KeyID() {
+		t.Errorf("fence KeyID = %s, want %s", fence.KeyID, signer.KeyID())
+	}
+
+	result, err := pub.Publish(ctx, []geofence.FenceItem{*fence})
+	if err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	manifest, err := readManifest(result.ManifestPath)
+	if err != nil {
+		t.Fatalf("readManifest failed: %v", err)
+	}
+	manifestData, err := converter.ManifestSigningData(manifest)
+	if err != nil {
+		t.Fatalf("ManifestSigningData failed: %v", err)
+	}
+	if !crypto.Verify(signer.PublicKey(), manifestData, manifest.Signature) {
+		t.Error("manifest signature does not verify with the signer's key")
+	}
+	if !slices.ContainsFunc(signer.Messages(), func(m []byte) bool { return bytes.Equal(m, manifestData) }) {
+		t.Error("the manifest should be signed by the signer")
+	}
+
+	// A failing signer fails the publish
+	signer.Err = errors.New("signing service unavailable")
+	if _, err := pub.Publish(ctx, []geofence.FenceItem{*fence}); err == nil {
+		t.Error("expected error when the signer fails")
+	}
+}
+
 func TestPublish_SingleVersion(t *testing.T) {
 	ctx := context.Background()
 	cfg := testConfig(t)
@@ -114,6 +216,67 @@ func TestPublish_SingleVersion(t *testing.T) {
 	}
 }
 
+func TestPublish_CorridorRootHash(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig(t)
+
+	pub, err := NewPublisher(ctx, cfg)
+	if err != nil {
+		t.Fatalf("NewPublisher failed: %v", err)
+	}
+	defer pub.Close()
+
+	// Coordinates finer than the polyline precision must not break
+	// root hash verification on the client
+	fences := []geofence.FenceItem{
+		{
+			ID:       "route-001",
+			Type:     geofence.FenceTypeTempRestriction,
+			Priority: 80,
+			Geometry: geofence.Geometry{
+				Corridor: &geofence.Corridor{
+					Path: []geofence.Point{
+						{Latitude: 39.123456789, Longitude: 116.123456789},
+						{Latitude: 39.223456789, Longitude: 116.323456789},
+					},
+					HalfWidth: 500,
+				},
+			},
+		},
+	}
+
+	result, err := pub.Publish(ctx, fences)
+	if err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	manifestData, err := os.ReadFile(result.ManifestPath)
+	if err != nil {
+		t.Fatalf("failed to read manifest: %v", err)
+	}
+	var manifest geofence.Manifest
+	if err := json.Unmarshal(manifestData, &manifest); err != nil {
+		t.Fatalf("failed to parse manifest: %v", err)
+	}
+
+	snapshotData, err := os.ReadFile(result.SnapshotPath)
+	if err != nil {
+		t.Fatalf("failed to read snapshot: %v", err)
+	}
+	loaded, err := merkle.LoadSnapshot(snapshotData)
+	if err != nil {
+		t.Fatalf("LoadSnapshot failed: %v", err)
+	}
+	tree, err := merkle.NewTree(loaded)
+	if err != nil {
+		t.Fatalf("NewTree failed: %v", err)
+	}
+	rootHash := tree.RootHash()
+	if !bytes.Equal(rootHash[:], manifest.RootHash) {
+		t.Error("root hash of decoded snapshot does not match manifest")
+	}
+}
+
 func TestPublish_MultipleVersions(t *testing.T) {
 	ctx := context.Background()
 	cfg := testConfig(t)
@@ -183,6 +346,215 @@ func TestPublish_MultipleVersions(t *testing.T) {
 	}
 }
 
+func TestPublish_PatchIndex(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig(t)
+	cfg.PatchHistory = 2
+	cfg.SkipDeltaInterval = 2
+
+	pub, err := NewPublisher(ctx, cfg)
+	if err != nil {
+		t.Fatalf("NewPublisher failed: %v", err)
+	}
+	defer pub.Close()
+
+	var fences []geofence.FenceItem
+	for i := 1; i <= 4; i++ {
+		fences = append(fences, geofence.FenceItem{
+			ID:       fmt.Sprintf("fence-%03d", i),
+			Type:     geofence.FenceTypePermanentNoFly,
+			Geometry: geofence.Geometry{BBox: &geofence.BoundingBox{MinLat: float64(i), MinLon: 110, MaxLat: float64(i) + 0.5, MaxLon: 111}},
+		})
+		if _, err := pub.Publish(ctx, fences); err != nil {
+			t.Fatalf("Publish %d failed: %v", i, err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(cfg.OutputDir, geofence.PatchIndexFile))
+	if err != nil {
+		t.Fatalf("failed to read patch index: %v", err)
+	}
+	var index geofence.PatchIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		t.Fatalf("failed to parse patch index: %v", err)
+	}
+	if index.Version != 4 {
+		t.Errorf("Version = %d, want 4", index.Version)
+	}
+
+	privateKey, err := crypto.UnmarshalPrivateKeyHex(cfg.PrivateKeyHex)
+	if err != nil {
+		t.Fatalf("UnmarshalPrivateKeyHex failed: %v", err)
+	}
+	kp, err := crypto.DeriveKeyPair(nil, privateKey)
+	if err != nil {
+		t.Fatalf("DeriveKeyPair failed: %v", err)
+	}
+
+	// Deltas from versions more than two behind are dropped; the rest are
+	// signed fence deltas producing the fences the index names
+	var got [][2]uint64
+	for _, patch := range index.Patches {
+		got = append(got, [2]uint64{patch.FromVersion, patch.ToVersion})
+		deltaData, err := os.ReadFile(filepath.Join(cfg.OutputDir, patch.URL))
+		if err != nil {
+			t.Errorf("failed to read delta %s: %v", patch.URL, err)
+			continue
+		}
+		if !bytes.Equal(crypto.ComputeSHA256(deltaData), patch.Hash) {
+			t.Errorf("hash of delta %s does not match the index", patch.URL)
+		}
+		delta, err := converter.UnmarshalFenceDeltaFile(deltaData)
+		if err != nil {
+			t.Errorf("failed to parse delta %s: %v", patch.URL, err)
+			continue
+		}
+		deltaSigningData, err := converter.FenceDeltaSigningData(delta)
+		if err != nil {
+			t.Fatalf("FenceDeltaSigningData failed: %v", err)
+		}
+		if delta.KeyID != kp.KeyID || !crypto.Verify(kp.PublicKey, deltaSigningData, delta.Signature) {
+			t.Errorf("signature of delta %s does not verify", patch.URL)
+		}
+		if !bytes.Equal(delta.ToRootHash, patch.RootHash) || len(delta.Delta.Added) != int(patch.ToVersion-patch.FromVersion) {
+			t.Errorf("delta %s does not lead to version %d", patch.URL, patch.ToVersion)
+		}
+
+		// The binary diff rebuilds the same fences from the snapshot of
+		// the starting version
+		if patch.Binary == nil {
+			t.Errorf("delta %s has no binary diff", patch.URL)
+			continue
+		}
+		binaryData, err := os.ReadFile(filepath.Join(cfg.OutputDir, patch.Binary.URL))
+		if err != nil {
+			t.Errorf("failed to read binary diff %s: %v", patch.Binary.URL, err)
+			continue
+		}
+		if !bytes.Equal(crypto.ComputeSHA256(binaryData), patch.Binary.Hash) {
+			t.Errorf("hash of binary diff %s does not match the index", patch.Binary.URL)
+		}
+		binaryDelta, err := binarydiff.ReadDeltaFile(bytes.NewReader(binaryData), patch.ToVersion)
+		if err != nil {
+			t.Fatalf("ReadDeltaFile failed: %v", err)
+		}
+		snapshotData, err := os.ReadFile(filepath.Join(cfg.OutputDir, fmt.Sprintf("v%d.bin", patch.FromVersion)))
+		if err != nil {
+			t.Fatalf("failed to read snapshot: %v", err)
+		}
+		oldFences, err := merkle.LoadSnapshot(snapshotData)
+		if err != nil {
+			t.Fatalf("LoadSnapshot failed: %v", err)
+		}
+		patched, err := binarydiff.PatchFences(oldFences, binaryDelta)
+		if err != nil {
+			t.Fatalf("PatchFences failed: %v", err)
+		}
+		tree, err := merkle.NewTreeFormat(patched, patch.FormatVersion)
+		if err != nil {
+			t.Fatalf("NewTreeFormat failed: %v", err)
+		}
+		if root := tree.RootHash(); !bytes.Equal(root[:], patch.RootHash) {
+			t.Errorf("binary diff %s does not lead to version %d", patch.Binary.URL, patch.ToVersion)
+		}
+	}
+	want := [][2]uint64{{2, 3}, {3, 4}, {2, 4}}
+	if !slices.Equal(got, want) {
+		t.Errorf("patches = %v, want %v", got, want)
+	}
+
+	signingData, err := index.MarshalBinaryForSigning()
+	if err != nil {
+		t.Fatalf("MarshalBinaryForSigning failed: %v", err)
+	}
+	if index.KeyID != kp.KeyID || !crypto.Verify(kp.PublicKey, signingData, index.Signature) {
+		t.Error("patch index signature does not verify")
+	}
+}
+
+func TestPublish_Failures(t *testing.T) {
+	ctx := context.Background()
+	fences := []geofence.FenceItem{{
+		ID:       "fence-001",
+		Type:     geofence.FenceTypePermanentNoFly,
+		Geometry: geofence.Geometry{BBox: &geofence.BoundingBox{MinLat: 1, MinLon: 110, MaxLat: 1.5, MaxLon: 111}},
+	}}
+
+	// published returns a publisher that has published version 1
+	published := func(t *testing.T) (*Publisher, *config.PublisherConfig) {
+		cfg := testConfig(t)
+		pub, err := NewPublisher(ctx, cfg)
+		if err != nil {
+			t.Fatalf("NewPublisher failed: %v", err)
+		}
+		t.Cleanup(func() { pub.Close() })
+		if _, err := pub.Publish(ctx, fences); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+		return pub, cfg
+	}
+	// publishedVersions returns the versions of the manifest and patch index
+	publishedVersions := func(t *testing.T, cfg *config.PublisherConfig) (uint64, uint64) {
+		var manifest geofence.Manifest
+		var index geofence.PatchIndex
+		for name, v := range map[string]any{"manifest.json": &manifest, geofence.PatchIndexFile: &index} {
+			data, err := os.ReadFile(filepath.Join(cfg.OutputDir, name))
+			if err != nil {
+				t.Fatalf("failed to read %s: %v", name, err)
+			}
+			if err := json.Unmarshal(data, v); err != nil {
+				t.Fatalf("failed to parse %s: %v", name, err)
+			}
+		}
+		return manifest.Version, index.Version
+	}
+
+	t.Run("missing previous snapshot", func(t *testing.T) {
+		pub, cfg := published(t)
+		if err := os.Remove(filepath.Join(cfg.OutputDir, "v1.bin")); err != nil {
+			t.Fatal(err)
+		}
+		result, err := pub.Publish(ctx, fences)
+		if err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+		if result.DeltaPath != "" {
+			t.Errorf("DeltaPath = %s, want none without a previous snapshot", result.DeltaPath)
+		}
+	})
+
+	t.Run("corrupt previous snapshot", func(t *testing.T) {
+		pub, cfg := published(t)
+		if err := os.WriteFile(filepath.Join(cfg.OutputDir, "v1.bin"), []byte("corrupt"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := pub.Publish(ctx, fences); err == nil {
+			t.Error("expected error for a corrupt previous snapshot")
+		}
+	})
+
+	t.Run("corrupt patch index", func(t *testing.T) {
+		pub, cfg := published(t)
+		if err := os.WriteFile(filepath.Join(cfg.OutputDir, geofence.PatchIndexFile), []byte("{"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := pub.Publish(ctx, fences); err == nil {
+			t.Error("expected error for a corrupt patch index")
+		}
+	})
+
+	t.Run("storage failure", func(t *testing.T) {
+		pub, cfg := published(t)
+		pub.store.Close()
+		if _, err := pub.Publish(ctx, fences); err == nil {
+			t.Fatal("expected error with the store closed")
+		}
+		if manifestVer, indexVer := publishedVersions(t, cfg); manifestVer != 1 || indexVer != 1 {
+			t.Errorf("manifest and patch index at versions %d and %d, want both left at 1", manifestVer, indexVer)
+		}
+	})
+}
+
 func TestPublish_VerifyManifestContent(t *testing.T) {
 	ctx := context.Background()
 	cfg := testConfig(t)
@@ -239,6 +611,92 @@ func TestPublish_VerifyManifestContent(t *testing.T) {
 	}
 }
 
+func TestPublish_Compression(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig(t)
+	cfg.Compression = []string{compression.Zstd, compression.Gzip}
+
+	pub, err := NewPublisher(ctx, cfg)
+	if err != nil {
+		t.Fatalf("NewPublisher failed: %v", err)
+	}
+	defer pub.Close()
+
+	var fences []geofence.FenceItem
+	for i := 1; i <= 2; i++ {
+		for j := 0; j < 20; j++ {
+			fences = append(fences, geofence.FenceItem{
+				ID:       fmt.Sprintf("fence-%d-%02d", i, j),
+				Type:     geofence.FenceTypePermanentNoFly,
+				Geometry: geofence.Geometry{BBox: &geofence.BoundingBox{MinLat: float64(j), MinLon: 110, MaxLat: float64(j) + 0.5, MaxLon: 111}},
+			})
+		}
+		if _, err := pub.Publish(ctx, fences); err != nil {
+			t.Fatalf("Publish %d failed: %v", i, err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(cfg.OutputDir, "manifest.json"))
+	if err != nil {
+		t.Fatalf("failed to read manifest: %v", err)
+	}
+	var manifest geofence.Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("failed to unmarshal manifest: %v", err)
+	}
+
+	// Each compressed copy matches its description and holds the file
+	checkEncodings := func(name, path string, hash []byte, encodings []geofence.FileEncoding) {
+		t.Helper()
+		if len(encodings) != 2 || encodings[0].Codec != compression.Zstd || encodings[1].Codec != compression.Gzip {
+			t.Fatalf("%s encodings = %+v, want zstd and gzip", name, encodings)
+		}
+		for _, enc := range encodings {
+			compressed, err := os.ReadFile(path + compression.Extension(enc.Codec))
+			if err != nil {
+				t.Fatalf("failed to read %s copy of %s: %v", enc.Codec, name, err)
+			}
+			if uint64(len(compressed)) != enc.Size || !bytes.Equal(crypto.ComputeSHA256(compressed), enc.Hash) {
+				t.Errorf("%s copy of %s does not match its size and hash", enc.Codec, name)
+			}
+			contents, err := compression.Decompress(enc.Codec, compressed, 1<<20)
+			if err != nil {
+				t.Fatalf("Decompress failed: %v", err)
+			}
+			if !bytes.Equal(crypto.ComputeSHA256(contents), hash) {
+				t.Errorf("%s copy of %s does not decompress to the file", enc.Codec, name)
+			}
+		}
+	}
+	checkEncodings("snapshot", filepath.Join(cfg.OutputDir, "v2.bin"), manifest.SnapshotHash, manifest.SnapshotEncodings)
+	checkEncodings("delta", filepath.Join(cfg.OutputDir, manifest.DeltaURL), manifest.DeltaHash, manifest.DeltaEncodings)
+
+	// The copies are covered by the manifest signature
+	privateKey, err := crypto.UnmarshalPrivateKeyHex(cfg.PrivateKeyHex)
+	if err != nil {
+		t.Fatalf("UnmarshalPrivateKeyHex failed: %v", err)
+	}
+	kp, err := crypto.DeriveKeyPair(nil, privateKey)
+	if err != nil {
+		t.Fatalf("DeriveKeyPair failed: %v", err)
+	}
+	signingData, err := converter.ManifestSigningData(&manifest)
+	if err != nil {
+		t.Fatalf("ManifestSigningData failed: %v", err)
+	}
+	if !crypto.Verify(kp.PublicKey, signingData, manifest.Signature) {
+		t.Error("manifest signature does not verify")
+	}
+	manifest.SnapshotEncodings[0].URL = "/elsewhere.zst"
+	signingData, err = converter.ManifestSigningData(&manifest)
+	if err != nil {
+		t.Fatalf("ManifestSigningData failed: %v", err)
+	}
+	if crypto.Verify(kp.PublicKey, signingData, manifest.Signature) {
+		t.Error("manifest signature does not cover the compressed copies")
+	}
+}
+
 func TestSignAndAdd(t *testing.T) {
 	ctx := context.Background()
 	cfg := testConfig(t)
@@ -287,6 +745,222 @@ func TestSignAndAdd(t *testing.T) {
 	if retrieved.Name != fence.Name {
 		t.Errorf("Name = %s, want %s", retrieved.Name, fence.Name)
 	}
+
+	// The stored fence must still verify against the publisher key
+	privateKey, err := crypto.UnmarshalPrivateKeyHex(cfg.PrivateKeyHex)
+	if err != nil {
+		t.Fatalf("UnmarshalPrivateKeyHex failed: %v", err)
+	}
+	keyring, err := crypto.NewKeyring(crypto.TrustedKey{PublicKeyHex: crypto.MarshalPublicKeyHex(privateKey[32:])})
+	if err != nil {
+		t.Fatalf("NewKeyring failed: %v", err)
+	}
+	signed := converter.SignableFence{Fence: retrieved, FormatVersion: geofence.CurrentFormatVersion}
+	if err := keyring.VerifySigned(signed, retrieved.Signature, retrieved.KeyID, crypto.RoleSigning, time.Now()); err != nil {
+		t.Errorf("stored fence signature does not verify: %v", err)
+	}
+}
+
+func TestIssueUnlock(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig(t)
+
+	pub, err := NewPublisher(ctx, cfg)
+	if err != nil {
+		t.Fatalf("NewPublisher failed: %v", err)
+	}
+	defer pub.Close()
+
+	fence := &geofence.FenceItem{
+		ID:   "stadium",
+		Type: geofence.FenceTypeTempRestriction,
+		Geometry: geofence.Geometry{
+			CircleCenter: &geofence.Point{Latitude: 0.5, Longitude: 0.5},
+			CircleRadius: 500,
+		},
+	}
+	if err := pub.SignAndAdd(ctx, fence); err != nil {
+		t.Fatalf("SignAndAdd failed: %v", err)
+	}
+
+	token := &geofence.UnlockToken{
+		ID:        "waiver-1",
+		FenceIDs:  []string{"stadium"},
+		NotBefore: time.Now().Unix(),
+		NotAfter:  time.Now().Add(24 * time.Hour).Unix(),
+		DeviceID:  "SN-1234",
+	}
+	if err := pub.IssueUnlock(ctx, token); err != nil {
+		t.Fatalf("IssueUnlock failed: %v", err)
+	}
+	if token.IssuedAt == 0 {
+		t.Error("IssuedAt should be set")
+	}
+	if token.KeyID != cfg.KeyID {
+		t.Errorf("KeyID = %s, want %s", token.KeyID, cfg.KeyID)
+	}
+
+	data, err := token.MarshalBinaryForSigning()
+	if err != nil {
+		t.Fatalf("MarshalBinaryForSigning failed: %v", err)
+	}
+	privateKey, err := crypto.UnmarshalPrivateKeyHex(cfg.PrivateKeyHex)
+	if err != nil {
+		t.Fatalf("UnmarshalPrivateKeyHex failed: %v", err)
+	}
+	if !crypto.Verify(privateKey[32:], data, token.Signature) {
+		t.Error("unlock token signature does not verify")
+	}
+
+	t.Run("unknown fence", func(t *testing.T) {
+		unknown := *token
+		unknown.FenceIDs = []string{"no-such-fence"}
+		if err := pub.IssueUnlock(ctx, &unknown); err == nil {
+			t.Error("expected error for unknown fence")
+		}
+	})
+
+	t.Run("unbound token", func(t *testing.T) {
+		unbound := *token
+		unbound.DeviceID = ""
+		if err := pub.IssueUnlock(ctx, &unbound); err == nil {
+			t.Error("expected error for token without a device")
+		}
+	})
+}
+
+func TestAnnounceKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig(t)
+
+	pub, err := NewPublisher(ctx, cfg)
+	if err != nil {
+		t.Fatalf("NewPublisher failed: %v", err)
+	}
+	defer pub.Close()
+
+	privateKey, err := crypto.UnmarshalPrivateKeyHex(cfg.PrivateKeyHex)
+	if err != nil {
+		t.Fatalf("UnmarshalPrivateKeyHex failed: %v", err)
+	}
+	keyring, err := crypto.NewKeyring(crypto.TrustedKey{
+		PublicKeyHex: crypto.MarshalPublicKeyHex(privateKey[32:]),
+		Roles:        []crypto.KeyRole{crypto.RoleRotation},
+	})
+	if err != nil {
+		t.Fatalf("NewKeyring failed: %v", err)
+	}
+
+	next, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair failed: %v", err)
+	}
+
+	for _, wantSeq := range []uint64{1, 2} {
+		rot := &crypto.KeyRotation{
+			Add: []crypto.TrustedKey{{PublicKeyHex: crypto.MarshalPublicKeyHex(next.PublicKey)}},
+		}
+		path, err := pub.AnnounceKeyRotation(rot)
+		if err != nil {
+			t.Fatalf("AnnounceKeyRotation failed: %v", err)
+		}
+		if path != filepath.Join(cfg.OutputDir, "keys.json") {
+			t.Errorf("path = %s, want keys.json in the output directory", path)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("ReadFile failed: %v", err)
+		}
+		var written crypto.KeyRotation
+		if err := json.Unmarshal(data, &written); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		if written.Sequence != wantSeq {
+			t.Errorf("Sequence = %d, want %d", written.Sequence, wantSeq)
+		}
+		if err := keyring.ApplyRotation(&written, time.Now()); err != nil {
+			t.Errorf("written key rotation does not apply: %v", err)
+		}
+	}
+
+	t.Run("invalid key", func(t *testing.T) {
+		rot := &crypto.KeyRotation{Add: []crypto.TrustedKey{{PublicKeyHex: "00"}}}
+		if _, err := pub.AnnounceKeyRotation(rot); err == nil {
+			t.Error("expected error for an invalid key")
+		}
+	})
+}
+
+func TestSignAndAdd_InvalidFence(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig(t)
+
+	pub, err := NewPublisher(ctx, cfg)
+	if err != nil {
+		t.Fatalf("NewPublisher failed: %v", err)
+	}
+	defer pub.Close()
+
+	fence := &geofence.FenceItem{
+		ID:       "bad-circle",
+		Type:     geofence.FenceTypeTempRestriction,
+		Priority: 50,
+		Geometry: geofence.Geometry{
+			CircleCenter: &geofence.Point{Latitude: 39.9, Longitude: 116.4},
+			CircleRadius: -100,
+		},
+	}
+
+	if err := pub.SignAndAdd(ctx, fence); err == nil {
+		t.Fatal("expected error for negative radius")
+	}
+	if len(fence.Signature) != 0 {
+		t.Error("invalid fence should not be signed")
+	}
+	if _, err := pub.GetFence(ctx, "bad-circle"); err == nil {
+		t.Error("invalid fence should not be stored")
+	}
+}
+
+func TestPublish_InvalidFences(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig(t)
+
+	pub, err := NewPublisher(ctx, cfg)
+	if err != nil {
+		t.Fatalf("NewPublisher failed: %v", err)
+	}
+	defer pub.Close()
+
+	fence := geofence.FenceItem{
+		ID:       "dup",
+		Type:     geofence.FenceTypePermanentNoFly,
+		Priority: 100,
+		Geometry: geofence.Geometry{
+			Polygon: []geofence.Point{
+				{Latitude: 0, Longitude: 0},
+				{Latitude: 0, Longitude: 1},
+				{Latitude: 1, Longitude: 1},
+			},
+		},
+	}
+
+	_, err = pub.Publish(ctx, []geofence.FenceItem{fence, fence})
+	if err == nil {
+		t.Fatal("expected error for duplicate fence IDs")
+	}
+
+	ver, err := pub.GetCurrentVersion(ctx)
+	if err != nil {
+		t.Fatalf("GetCurrentVersion failed: %v", err)
+	}
+	if ver != 0 {
+		t.Errorf("version = %d, want 0 after rejected publish", ver)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.OutputDir, "manifest.json")); !os.IsNotExist(err) {
+		t.Errorf("manifest should not be written, stat error = %v", err)
+	}
 }
 
 func TestSignAndUpdate(t *testing.T) {
@@ -11,6 +11,7 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/iannil/geofence-updater-lite/pkg/geofence"
 	_ "modernc.org/sqlite"
@@ -30,6 +31,7 @@ type Store interface {
 
 	// Spatial query
 	QueryAtPoint(ctx context.Context, lat, lon float64) ([]*geofence.FenceItem, error)
+	QueryAtPointAt(ctx context.Context, lat, lon float64, t time.Time) ([]*geofence.FenceItem, error)
 	QueryInBounds(ctx context.Context, bounds *geofence.BoundingBox) ([]*geofence.FenceItem, error)
 
 	// Manifest operations
@@ -45,7 +47,9 @@ type Store interface {
 	Close() error
 }
 
-// Tx represents a transaction.
+// Tx represents a transaction. Its changes become visible, all together,
+// when it is committed, and are discarded if it is rolled back or the
+// process stops before.
 type Tx struct {
 	tx *sql.Tx
 }
@@ -60,6 +64,42 @@ func (t *Tx) Rollback() error {
 	return t.tx.Rollback()
 }
 
+// AddFence adds a new fence in the transaction.
+func (t *Tx) AddFence(ctx context.Context, fence *geofence.FenceItem) error {
+	return addFence(ctx, t.tx, fence)
+}
+
+// UpdateFence updates an existing fence in the transaction.
+func (t *Tx) UpdateFence(ctx context.Context, fence *geofence.FenceItem) error {
+	return updateFence(ctx, t.tx, fence)
+}
+
+// DeleteFence removes a fence in the transaction.
+func (t *Tx) DeleteFence(ctx context.Context, id string) error {
+	return deleteFence(ctx, t.tx, id)
+}
+
+// ListFences returns all fences as seen by the transaction.
+func (t *Tx) ListFences(ctx context.Context) ([]*geofence.FenceItem, error) {
+	return listFences(ctx, t.tx)
+}
+
+// SetManifest stores a manifest in the transaction.
+func (t *Tx) SetManifest(ctx context.Context, manifest *geofence.Manifest) error {
+	return setManifest(ctx, t.tx, manifest)
+}
+
+// SetVersion stores the current version in the transaction.
+func (t *Tx) SetVersion(ctx context.Context, version uint64) error {
+	return setVersion(ctx, t.tx, version)
+}
+
+// queryer is implemented by *sql.DB and *sql.Tx.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
 // SQLiteStore implements Store using SQLite with R-Tree extension.
 type SQLiteStore struct {
 	db   *sql.DB
@@ -129,6 +169,11 @@ func (s *SQLiteStore) init(ctx context.Context) error {
 			signature BLOB,
 			key_id TEXT,
 			geometry_json TEXT NOT NULL,
+			alt_floor REAL,
+			alt_ceiling REAL,
+			alt_ref INTEGER,
+			schedule_json TEXT,
+			conditions_json TEXT,
 			created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now')),
 			updated_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now'))
 		);
@@ -137,6 +182,11 @@ func (s *SQLiteStore) init(ctx context.Context) error {
 		return fmt.Errorf("failed to create fences table: %w", err)
 	}
 
+	// Bring databases created by older versions up to the current schema
+	if err := s.migrateFenceColumns(ctx); err != nil {
+		return err
+	}
+
 	// Create R-Tree virtual table for spatial indexing
 	_, err = s.db.ExecContext(ctx, `
 		CREATE VIRTUAL TABLE IF NOT EXISTS fence_index USING rtree(
@@ -175,11 +225,160 @@ func (s *SQLiteStore) init(ctx context.Context) error {
 	return nil
 }
 
+// fenceColumnMigrations lists columns added to the fences table after its
+// initial release, in the order they were introduced.
+var fenceColumnMigrations = []struct {
+	name string
+	def  string
+}{
+	{"alt_floor", "REAL"},
+	{"alt_ceiling", "REAL"},
+	{"alt_ref", "INTEGER"},
+	{"schedule_json", "TEXT"},
+	{"conditions_json", "TEXT"},
+}
+
+// migrateFenceColumns adds any missing columns to an existing fences table.
+func (s *SQLiteStore) migrateFenceColumns(ctx context.Context) error {
+	rows, err := s.db.QueryContext(ctx, "PRAGMA table_info(fences)")
+	if err != nil {
+		return fmt.Errorf("failed to read fences schema: %w", err)
+	}
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan fences schema: %w", err)
+		}
+		existing[name] = true
+	}
+	if err := rows.Close(); err != nil {
+		return fmt.Errorf("failed to read fences schema: %w", err)
+	}
+
+	for _, col := range fenceColumnMigrations {
+		if existing[col.name] {
+			continue
+		}
+		_, err := s.db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE fences ADD COLUMN %s %s", col.name, col.def))
+		if err != nil {
+			return fmt.Errorf("failed to add column %s: %w", col.name, err)
+		}
+	}
+
+	return nil
+}
+
+// fenceColumns is the column list read by every fence query, in the order
+// expected by scanFence.
+const fenceColumns = `id, type, start_ts, end_ts, priority, max_altitude, max_speed,
+	name, description, signature, key_id, geometry_json, alt_floor, alt_ceiling, alt_ref,
+	schedule_json, conditions_json`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanFence reads a fence selected with fenceColumns.
+func scanFence(row rowScanner) (*geofence.FenceItem, error) {
+	var fence geofence.FenceItem
+	var geomJSON string
+	var altFloor, altCeiling sql.NullFloat64
+	var altRef sql.NullInt32
+	var scheduleJSON, conditionsJSON sql.NullString
+
+	err := row.Scan(
+		&fence.ID, &fence.Type, &fence.StartTS, &fence.EndTS, &fence.Priority,
+		&fence.MaxAltitude, &fence.MaxSpeed, &fence.Name, &fence.Description,
+		&fence.Signature, &fence.KeyID, &geomJSON, &altFloor, &altCeiling, &altRef,
+		&scheduleJSON, &conditionsJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(geomJSON), &fence.Geometry); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal geometry: %w", err)
+	}
+
+	if altFloor.Valid || altCeiling.Valid {
+		fence.Altitude = &geofence.AltitudeBand{
+			Floor:     altFloor.Float64,
+			Ceiling:   altCeiling.Float64,
+			Reference: geofence.AltitudeReference(altRef.Int32),
+		}
+	}
+
+	if scheduleJSON.Valid {
+		fence.Schedule = &geofence.Schedule{}
+		if err := json.Unmarshal([]byte(scheduleJSON.String), fence.Schedule); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal schedule: %w", err)
+		}
+	}
+
+	if conditionsJSON.Valid {
+		fence.Conditions = &geofence.Conditions{}
+		if err := json.Unmarshal([]byte(conditionsJSON.String), fence.Conditions); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal conditions: %w", err)
+		}
+	}
+
+	return &fence, nil
+}
+
+// altitudeArgs returns the altitude column values for a fence, using NULL
+// when the fence has no altitude band.
+func altitudeArgs(fence *geofence.FenceItem) (floor, ceiling, ref any) {
+	if fence.Altitude == nil {
+		return nil, nil, nil
+	}
+	return fence.Altitude.Floor, fence.Altitude.Ceiling, int32(fence.Altitude.Reference)
+}
+
+// scheduleArg returns the schedule column value for a fence, using NULL
+// when the fence has no schedule.
+func scheduleArg(fence *geofence.FenceItem) (any, error) {
+	if fence.Schedule == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(fence.Schedule)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal schedule: %w", err)
+	}
+	return string(data), nil
+}
+
+// conditionsArg returns the conditions column value for a fence, using NULL
+// when the fence applies to every aircraft.
+func conditionsArg(fence *geofence.FenceItem) (any, error) {
+	if fence.Conditions == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(fence.Conditions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal conditions: %w", err)
+	}
+	return string(data), nil
+}
+
 // AddFence adds a new fence to the store.
 func (s *SQLiteStore) AddFence(ctx context.Context, fence *geofence.FenceItem) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	return s.inTx(ctx, func(tx *sql.Tx) error { return addFence(ctx, tx, fence) })
+}
+
+// addFence inserts a fence and its R-Tree entries in tx.
+func addFence(ctx context.Context, tx *sql.Tx, fence *geofence.FenceItem) error {
 	// Serialize geometry to JSON
 	geomJSON, err := json.Marshal(fence.Geometry)
 	if err != nil {
@@ -188,28 +387,26 @@ func (s *SQLiteStore) AddFence(ctx context.Context, fence *geofence.FenceItem) e
 
 	// Calculate bounds for R-Tree
 	bounds := fence.GetBounds()
-
-	// Use transaction for atomicity
-	tx, err := s.db.BeginTx(ctx, nil)
+	altFloor, altCeiling, altRef := altitudeArgs(fence)
+	schedule, err := scheduleArg(fence)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return err
+	}
+	conditions, err := conditionsArg(fence)
+	if err != nil {
+		return err
 	}
-
-	committed := false
-	defer func() {
-		if !committed {
-			tx.Rollback()
-		}
-	}()
 
 	// Insert fence and get the rowid
 	result, err := tx.ExecContext(ctx, `
 		INSERT INTO fences (id, type, start_ts, end_ts, priority, max_altitude, max_speed,
-			name, description, signature, key_id, geometry_json)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			name, description, signature, key_id, geometry_json, alt_floor, alt_ceiling, alt_ref,
+			schedule_json, conditions_json)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`, fence.ID, int(fence.Type), fence.StartTS, fence.EndTS, fence.Priority,
 		fence.MaxAltitude, fence.MaxSpeed, fence.Name, fence.Description,
-		fence.Signature, fence.KeyID, string(geomJSON))
+		fence.Signature, fence.KeyID, string(geomJSON), altFloor, altCeiling, altRef,
+		schedule, conditions)
 	if err != nil {
 		return fmt.Errorf("failed to insert fence: %w", err)
 	}
@@ -221,20 +418,55 @@ func (s *SQLiteStore) AddFence(ctx context.Context, fence *geofence.FenceItem) e
 	}
 
 	// Add to R-Tree using rowid (same transaction)
-	_, err = tx.ExecContext(ctx, `
-		INSERT INTO fence_index (rowid, minX, maxX, minY, maxY)
-		VALUES (?, ?, ?, ?, ?)
-	`, rowID, bounds.MinLon, bounds.MaxLon, bounds.MinLat, bounds.MaxLat)
-	if err != nil {
-		return fmt.Errorf("failed to insert into rtree: %w", err)
+	return indexFence(ctx, tx, rowID, bounds)
+}
+
+// indexFence adds the R-Tree entries of a fence. The R-Tree cannot represent
+// a box crossing the antimeridian, so such a fence gets one entry for each
+// side: the eastern one under the fence's rowid and the western one under
+// its negation, which is never a fence rowid.
+func indexFence(ctx context.Context, tx *sql.Tx, rowID int64, bounds geofence.BoundingBox) error {
+	for i, b := range bounds.Split() {
+		id := rowID
+		if i > 0 {
+			id = -rowID
+		}
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO fence_index (rowid, minX, maxX, minY, maxY)
+			VALUES (?, ?, ?, ?, ?)
+		`, id, b.MinLon, b.MaxLon, b.MinLat, b.MaxLat)
+		if err != nil {
+			return fmt.Errorf("failed to insert into rtree: %w", err)
+		}
 	}
+	return nil
+}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+// indexQuery returns the R-Tree condition and arguments matching entries
+// that intersect a bounding box. Boxes crossing the antimeridian are split,
+// and a box touching it also matches entries touching it from the other
+// side, as -180 and 180 are the same meridian.
+func indexQuery(bounds geofence.BoundingBox) (string, []any) {
+	parts := bounds.Split()
+	for _, b := range parts {
+		switch {
+		case b.MaxLon == 180:
+			parts = append(parts, geofence.BoundingBox{MinLat: b.MinLat, MinLon: -180, MaxLat: b.MaxLat, MaxLon: -180})
+		case b.MinLon == -180:
+			parts = append(parts, geofence.BoundingBox{MinLat: b.MinLat, MinLon: 180, MaxLat: b.MaxLat, MaxLon: 180})
+		}
 	}
-	committed = true
 
-	return nil
+	var where string
+	var args []any
+	for i, b := range parts {
+		if i > 0 {
+			where += " OR "
+		}
+		where += "(maxX >= ? AND minX <= ? AND maxY >= ? AND minY <= ?)"
+		args = append(args, b.MinLon, b.MaxLon, b.MinLat, b.MaxLat)
+	}
+	return where, args
 }
 
 // GetFence retrieves a fence by ID.
@@ -242,17 +474,8 @@ func (s *SQLiteStore) GetFence(ctx context.Context, id string) (*geofence.FenceI
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	var fence geofence.FenceItem
-	var geomJSON string
-
-	err := s.db.QueryRowContext(ctx, `
-		SELECT id, type, start_ts, end_ts, priority, max_altitude, max_speed,
-			name, description, signature, key_id, geometry_json
-		FROM fences WHERE id = ?
-	`, id).Scan(
-		&fence.ID, &fence.Type, &fence.StartTS, &fence.EndTS, &fence.Priority,
-		&fence.MaxAltitude, &fence.MaxSpeed, &fence.Name, &fence.Description,
-		&fence.Signature, &fence.KeyID, &geomJSON)
+	fence, err := scanFence(s.db.QueryRowContext(ctx,
+		"SELECT "+fenceColumns+" FROM fences WHERE id = ?", id))
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("fence not found: %s", id)
 	}
@@ -260,11 +483,7 @@ func (s *SQLiteStore) GetFence(ctx context.Context, id string) (*geofence.FenceI
 		return nil, fmt.Errorf("failed to query fence: %w", err)
 	}
 
-	if err := json.Unmarshal([]byte(geomJSON), &fence.Geometry); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal geometry: %w", err)
-	}
-
-	return &fence, nil
+	return fence, nil
 }
 
 // UpdateFence updates an existing fence.
@@ -272,6 +491,11 @@ func (s *SQLiteStore) UpdateFence(ctx context.Context, fence *geofence.FenceItem
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	return s.inTx(ctx, func(tx *sql.Tx) error { return updateFence(ctx, tx, fence) })
+}
+
+// updateFence updates a fence and its R-Tree entries in tx.
+func updateFence(ctx context.Context, tx *sql.Tx, fence *geofence.FenceItem) error {
 	// Serialize geometry
 	geomJSON, err := json.Marshal(fence.Geometry)
 	if err != nil {
@@ -280,29 +504,28 @@ func (s *SQLiteStore) UpdateFence(ctx context.Context, fence *geofence.FenceItem
 
 	// Calculate bounds
 	bounds := fence.GetBounds()
-
-	// Use transaction for atomicity
-	tx, err := s.db.BeginTx(ctx, nil)
+	altFloor, altCeiling, altRef := altitudeArgs(fence)
+	schedule, err := scheduleArg(fence)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return err
+	}
+	conditions, err := conditionsArg(fence)
+	if err != nil {
+		return err
 	}
-
-	committed := false
-	defer func() {
-		if !committed {
-			tx.Rollback()
-		}
-	}()
 
 	// Update fence
 	result, err := tx.ExecContext(ctx, `
 		UPDATE fences SET type = ?, start_ts = ?, end_ts = ?, priority = ?,
 			max_altitude = ?, max_speed = ?, name = ?, description = ?,
-			signature = ?, key_id = ?, geometry_json = ?, updated_at = strftime('%s', 'now')
+			signature = ?, key_id = ?, geometry_json = ?,
+			alt_floor = ?, alt_ceiling = ?, alt_ref = ?, schedule_json = ?,
+			conditions_json = ?, updated_at = strftime('%s', 'now')
 		WHERE id = ?
 	`, int(fence.Type), fence.StartTS, fence.EndTS, fence.Priority,
 		fence.MaxAltitude, fence.MaxSpeed, fence.Name, fence.Description,
-		fence.Signature, fence.KeyID, string(geomJSON), fence.ID)
+		fence.Signature, fence.KeyID, string(geomJSON), altFloor, altCeiling, altRef,
+		schedule, conditions, fence.ID)
 	if err != nil {
 		return fmt.Errorf("failed to update fence: %w", err)
 	}
@@ -315,20 +538,16 @@ func (s *SQLiteStore) UpdateFence(ctx context.Context, fence *geofence.FenceItem
 		return ErrFenceNotFound
 	}
 
-	// Update R-Tree using rowid (same transaction)
-	_, err = tx.ExecContext(ctx, `
-		UPDATE fence_index SET minX = ?, maxX = ?, minY = ?, maxY = ? WHERE rowid = (SELECT rowid FROM fences WHERE id = ?)
-	`, bounds.MinLon, bounds.MaxLon, bounds.MinLat, bounds.MaxLat, fence.ID)
-	if err != nil {
-		return fmt.Errorf("failed to update rtree: %w", err)
+	// Replace the R-Tree entries using rowid (same transaction), as the
+	// fence may cross the antimeridian before or after the update
+	var rowID int64
+	if err := tx.QueryRowContext(ctx, "SELECT rowid FROM fences WHERE id = ?", fence.ID).Scan(&rowID); err != nil {
+		return fmt.Errorf("failed to get rowid: %w", err)
 	}
-
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+	if _, err := tx.ExecContext(ctx, "DELETE FROM fence_index WHERE rowid IN (?, ?)", rowID, -rowID); err != nil {
+		return fmt.Errorf("failed to update rtree: %w", err)
 	}
-	committed = true
-
-	return nil
+	return indexFence(ctx, tx, rowID, bounds)
 }
 
 // DeleteFence removes a fence from the store.
@@ -336,22 +555,14 @@ func (s *SQLiteStore) DeleteFence(ctx context.Context, id string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Use transaction for atomicity
-	tx, err := s.db.BeginTx(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
-	}
-
-	committed := false
-	defer func() {
-		if !committed {
-			tx.Rollback()
-		}
-	}()
+	return s.inTx(ctx, func(tx *sql.Tx) error { return deleteFence(ctx, tx, id) })
+}
 
+// deleteFence removes a fence and its R-Tree entries in tx.
+func deleteFence(ctx context.Context, tx *sql.Tx, id string) error {
 	// Get rowid first
 	var rowID int64
-	err = tx.QueryRowContext(ctx, "SELECT rowid FROM fences WHERE id = ?", id).Scan(&rowID)
+	err := tx.QueryRowContext(ctx, "SELECT rowid FROM fences WHERE id = ?", id).Scan(&rowID)
 	if err == sql.ErrNoRows {
 		return ErrFenceNotFound
 	}
@@ -374,16 +585,11 @@ func (s *SQLiteStore) DeleteFence(ctx context.Context, id string) error {
 	}
 
 	// Delete from R-Tree (same transaction)
-	_, err = tx.ExecContext(ctx, "DELETE FROM fence_index WHERE rowid = ?", rowID)
+	_, err = tx.ExecContext(ctx, "DELETE FROM fence_index WHERE rowid IN (?, ?)", rowID, -rowID)
 	if err != nil {
 		return fmt.Errorf("failed to delete from rtree: %w", err)
 	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
-	committed = true
-
 	return nil
 }
 
@@ -392,11 +598,13 @@ func (s *SQLiteStore) ListFences(ctx context.Context) ([]*geofence.FenceItem, er
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	rows, err := s.db.QueryContext(ctx, `
-		SELECT id, type, start_ts, end_ts, priority, max_altitude, max_speed,
-			name, description, signature, key_id, geometry_json
-		FROM fences ORDER BY priority DESC
-	`)
+	return listFences(ctx, s.db)
+}
+
+// listFences returns all fences, ordered by priority.
+func listFences(ctx context.Context, q queryer) ([]*geofence.FenceItem, error) {
+	rows, err := q.QueryContext(ctx,
+		"SELECT "+fenceColumns+" FROM fences ORDER BY priority DESC")
 	if err != nil {
 		return nil, fmt.Errorf("failed to query fences: %w", err)
 	}
@@ -404,42 +612,37 @@ func (s *SQLiteStore) ListFences(ctx context.Context) ([]*geofence.FenceItem, er
 
 	var fences []*geofence.FenceItem
 	for rows.Next() {
-		var fence geofence.FenceItem
-		var geomJSON string
-
-		err := rows.Scan(
-			&fence.ID, &fence.Type, &fence.StartTS, &fence.EndTS, &fence.Priority,
-			&fence.MaxAltitude, &fence.MaxSpeed, &fence.Name, &fence.Description,
-			&fence.Signature, &fence.KeyID, &geomJSON)
+		fence, err := scanFence(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan fence: %w", err)
 		}
 
-		if err := json.Unmarshal([]byte(geomJSON), &fence.Geometry); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal geometry: %w", err)
-		}
-
-		fences = append(fences, &fence)
+		fences = append(fences, fence)
 	}
 
 	return fences, rows.Err()
 }
 
-// QueryAtPoint finds all fences containing the given point.
+// QueryAtPoint finds all currently active fences containing the given point.
 func (s *SQLiteStore) QueryAtPoint(ctx context.Context, lat, lon float64) ([]*geofence.FenceItem, error) {
+	return s.QueryAtPointAt(ctx, lat, lon, time.Now())
+}
+
+// QueryAtPointAt finds all fences containing the given point that are active
+// at time t.
+func (s *SQLiteStore) QueryAtPointAt(ctx context.Context, lat, lon float64, t time.Time) ([]*geofence.FenceItem, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	// Use R-Tree to find candidate fences
 	// Note: R-Tree gives us bounding box matches, we still need to check exact geometry
+	where, args := indexQuery(geofence.BoundingBox{MinLat: lat, MinLon: lon, MaxLat: lat, MaxLon: lon})
 	rows, err := s.db.QueryContext(ctx, `
-		SELECT f.id, f.type, f.start_ts, f.end_ts, f.priority, f.max_altitude, f.max_speed,
-			f.name, f.description, f.signature, f.key_id, f.geometry_json
+		SELECT `+fenceColumns+`
 		FROM fences f
-		INNER JOIN fence_index idx ON f.rowid = idx.rowid
-		WHERE idx.minX <= ? AND idx.maxX >= ? AND idx.minY <= ? AND idx.maxY >= ?
+		WHERE f.rowid IN (SELECT abs(rowid) FROM fence_index WHERE `+where+`)
 		ORDER BY f.priority DESC
-	`, lon, lon, lat, lat)
+	`, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query rtree: %w", err)
 	}
@@ -449,43 +652,33 @@ func (s *SQLiteStore) QueryAtPoint(ctx context.Context, lat, lon float64) ([]*ge
 	point := geofence.Point{Latitude: lat, Longitude: lon}
 
 	for rows.Next() {
-		var fence geofence.FenceItem
-		var geomJSON string
-
-		err := rows.Scan(
-			&fence.ID, &fence.Type, &fence.StartTS, &fence.EndTS, &fence.Priority,
-			&fence.MaxAltitude, &fence.MaxSpeed, &fence.Name, &fence.Description,
-			&fence.Signature, &fence.KeyID, &geomJSON)
+		fence, err := scanFence(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan fence: %w", err)
 		}
 
-		if err := json.Unmarshal([]byte(geomJSON), &fence.Geometry); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal geometry: %w", err)
-		}
-
 		// Check exact geometry match
-		if fence.ContainsPoint(point) && fence.IsActiveNow() {
-			fences = append(fences, &fence)
+		if fence.ContainsPoint(point) && fence.IsActiveAt(t) {
+			fences = append(fences, fence)
 		}
 	}
 
 	return fences, rows.Err()
 }
 
-// QueryInBounds finds all fences intersecting the given bounding box.
+// QueryInBounds finds all fences whose bounds intersect the given bounding
+// box, which may cross the antimeridian.
 func (s *SQLiteStore) QueryInBounds(ctx context.Context, bounds *geofence.BoundingBox) ([]*geofence.FenceItem, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	where, args := indexQuery(*bounds)
 	rows, err := s.db.QueryContext(ctx, `
-		SELECT f.id, f.type, f.start_ts, f.end_ts, f.priority, f.max_altitude, f.max_speed,
-			f.name, f.description, f.signature, f.key_id, f.geometry_json
+		SELECT `+fenceColumns+`
 		FROM fences f
-		INNER JOIN fence_index idx ON f.rowid = idx.rowid
-		WHERE idx.maxX >= ? AND idx.minX <= ? AND idx.maxY >= ? AND idx.minY <= ?
+		WHERE f.rowid IN (SELECT abs(rowid) FROM fence_index WHERE `+where+`)
 		ORDER BY f.priority DESC
-	`, bounds.MinLon, bounds.MaxLon, bounds.MaxLat, bounds.MinLat)
+	`, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query rtree: %w", err)
 	}
@@ -493,27 +686,46 @@ func (s *SQLiteStore) QueryInBounds(ctx context.Context, bounds *geofence.Boundi
 
 	var fences []*geofence.FenceItem
 	for rows.Next() {
-		var fence geofence.FenceItem
-		var geomJSON string
-
-		err := rows.Scan(
-			&fence.ID, &fence.Type, &fence.StartTS, &fence.EndTS, &fence.Priority,
-			&fence.MaxAltitude, &fence.MaxSpeed, &fence.Name, &fence.Description,
-			&fence.Signature, &fence.KeyID, &geomJSON)
+		fence, err := scanFence(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan fence: %w", err)
 		}
 
-		if err := json.Unmarshal([]byte(geomJSON), &fence.Geometry); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal geometry: %w", err)
-		}
-
-		fences = append(fences, &fence)
+		fences = append(fences, fence)
 	}
 
 	return fences, rows.Err()
 }
 
+// QueryNearestRestrictive finds the active no-fly fences within radiusMeters
+// of a point, nearest first. Fences containing the point have distance 0.
+func (s *SQLiteStore) QueryNearestRestrictive(ctx context.Context, lat, lon, radiusMeters float64) ([]geofence.FenceProximity, error) {
+	return s.QueryNearestRestrictiveAt(ctx, lat, lon, radiusMeters, time.Now())
+}
+
+// QueryNearestRestrictiveAt is like QueryNearestRestrictive but selects
+// fences active at time t.
+func (s *SQLiteStore) QueryNearestRestrictiveAt(ctx context.Context, lat, lon, radiusMeters float64, t time.Time) ([]geofence.FenceProximity, error) {
+	point := geofence.Point{Latitude: lat, Longitude: lon}
+
+	// Every fence within the radius has R-Tree bounds intersecting the
+	// search circle's bounds
+	search := (&geofence.Corridor{Path: []geofence.Point{point}, HalfWidth: radiusMeters}).Bounds()
+	candidates, err := s.QueryInBounds(ctx, &search)
+	if err != nil {
+		return nil, err
+	}
+
+	var restrictive []geofence.FenceItem
+	for _, f := range candidates {
+		if f.IsRestrictive() {
+			restrictive = append(restrictive, *f)
+		}
+	}
+
+	return geofence.NearestFencesAt(restrictive, point, radiusMeters, t), nil
+}
+
 // GetManifest retrieves the stored manifest.
 func (s *SQLiteStore) GetManifest(ctx context.Context) (*geofence.Manifest, error) {
 	s.mu.RLock()
@@ -541,12 +753,17 @@ func (s *SQLiteStore) SetManifest(ctx context.Context, manifest *geofence.Manife
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	return setManifest(ctx, s.db, manifest)
+}
+
+// setManifest stores a manifest.
+func setManifest(ctx context.Context, q queryer, manifest *geofence.Manifest) error {
 	data, err := json.Marshal(manifest)
 	if err != nil {
 		return fmt.Errorf("failed to marshal manifest: %w", err)
 	}
 
-	_, err = s.db.ExecContext(ctx, `
+	_, err = q.ExecContext(ctx, `
 		INSERT OR REPLACE INTO metadata (key, value, updated_at)
 		VALUES ('manifest', ?, strftime('%s', 'now'))
 	`, data)
@@ -579,7 +796,12 @@ func (s *SQLiteStore) SetVersion(ctx context.Context, version uint64) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	_, err := s.db.ExecContext(ctx, `
+	return setVersion(ctx, s.db, version)
+}
+
+// setVersion stores the current version.
+func setVersion(ctx context.Context, q queryer, version uint64) error {
+	_, err := q.ExecContext(ctx, `
 		INSERT OR REPLACE INTO metadata (key, value, updated_at)
 		VALUES ('version', ?, strftime('%s', 'now'))
 	`, version)
@@ -599,6 +821,22 @@ func (s *SQLiteStore) BeginTx(ctx context.Context) (*Tx, error) {
 	return &Tx{tx: tx}, nil
 }
 
+// inTx runs fn in a transaction, committing it if fn succeeds.
+func (s *SQLiteStore) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
 // Close closes the database connection.
 func (s *SQLiteStore) Close() error {
 	s.mu.Lock()
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"database/sql"
 	"path/filepath"
 	"testing"
 	"time"
@@ -306,6 +307,556 @@ func TestQueryAtPoint(t *testing.T) {
 	}
 }
 
+func TestQueryAtPointAt(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(ctx, &Config{Path: tempDB(t)})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	start := time.Date(2026, 7, 1, 8, 0, 0, 0, time.UTC)
+	fence := &geofence.FenceItem{
+		ID:       "event-window",
+		Type:     geofence.FenceTypeTempRestriction,
+		StartTS:  start.Unix(),
+		EndTS:    start.Add(4 * time.Hour).Unix(),
+		Priority: 50,
+		Geometry: geofence.Geometry{
+			Polygon: []geofence.Point{
+				{Latitude: 39.9, Longitude: 116.4},
+				{Latitude: 39.9, Longitude: 116.5},
+				{Latitude: 40.0, Longitude: 116.5},
+				{Latitude: 40.0, Longitude: 116.4},
+			},
+		},
+	}
+	if err := store.AddFence(ctx, fence); err != nil {
+		t.Fatalf("AddFence failed: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want int
+	}{
+		{"before window", start.Add(-time.Minute), 0},
+		{"during window", start.Add(2 * time.Hour), 1},
+		{"after window", start.Add(5 * time.Hour), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := store.QueryAtPointAt(ctx, 39.95, 116.45, tt.at)
+			if err != nil {
+				t.Fatalf("QueryAtPointAt failed: %v", err)
+			}
+			if len(results) != tt.want {
+				t.Errorf("QueryAtPointAt returned %d results, want %d", len(results), tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryAtPoint_PolygonWithHoles(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(ctx, &Config{Path: tempDB(t)})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	fences := []*geofence.FenceItem{
+		{
+			ID:       "airport-donut",
+			Type:     geofence.FenceTypePermanentNoFly,
+			Priority: 100,
+			Geometry: geofence.Geometry{
+				Polygon: []geofence.Point{
+					{Latitude: 39.0, Longitude: 116.0},
+					{Latitude: 39.0, Longitude: 117.0},
+					{Latitude: 40.0, Longitude: 117.0},
+					{Latitude: 40.0, Longitude: 116.0},
+				},
+				Holes: [][]geofence.Point{
+					{
+						{Latitude: 39.4, Longitude: 116.4},
+						{Latitude: 39.4, Longitude: 116.6},
+						{Latitude: 39.6, Longitude: 116.6},
+						{Latitude: 39.6, Longitude: 116.4},
+					},
+				},
+			},
+		},
+		{
+			ID:       "islands",
+			Type:     geofence.FenceTypeTempRestriction,
+			Priority: 50,
+			Geometry: geofence.Geometry{
+				MultiPolygon: []geofence.PolygonPart{
+					{Outer: []geofence.Point{
+						{Latitude: 10, Longitude: 10},
+						{Latitude: 10, Longitude: 11},
+						{Latitude: 11, Longitude: 11},
+						{Latitude: 11, Longitude: 10},
+					}},
+					{Outer: []geofence.Point{
+						{Latitude: 20, Longitude: 20},
+						{Latitude: 20, Longitude: 21},
+						{Latitude: 21, Longitude: 21},
+						{Latitude: 21, Longitude: 20},
+					}},
+				},
+			},
+		},
+	}
+	for _, f := range fences {
+		if err := store.AddFence(ctx, f); err != nil {
+			t.Fatalf("AddFence failed: %v", err)
+		}
+	}
+
+	// Geometry must survive the geometry_json round trip
+	retrieved, err := store.GetFence(ctx, "airport-donut")
+	if err != nil {
+		t.Fatalf("GetFence failed: %v", err)
+	}
+	if len(retrieved.Geometry.Holes) != 1 || len(retrieved.Geometry.Holes[0]) != 4 {
+		t.Errorf("Holes = %v, want one ring of 4 points", retrieved.Geometry.Holes)
+	}
+
+	tests := []struct {
+		name     string
+		lat, lon float64
+		want     int
+	}{
+		{"inside ring", 39.2, 116.2, 1},
+		{"inside hole", 39.5, 116.5, 0},
+		{"first island", 10.5, 10.5, 1},
+		{"second island", 20.5, 20.5, 1},
+		{"between islands", 15, 15, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := store.QueryAtPoint(ctx, tt.lat, tt.lon)
+			if err != nil {
+				t.Fatalf("QueryAtPoint failed: %v", err)
+			}
+			if len(results) != tt.want {
+				t.Errorf("QueryAtPoint returned %d results, want %d", len(results), tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryAtPoint_Corridor(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(ctx, &Config{Path: tempDB(t)})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	// Motorcade route with a 500 m buffer
+	fence := &geofence.FenceItem{
+		ID:       "motorcade",
+		Type:     geofence.FenceTypeTempRestriction,
+		Priority: 80,
+		Geometry: geofence.Geometry{
+			Corridor: &geofence.Corridor{
+				Path: []geofence.Point{
+					{Latitude: 39.90, Longitude: 116.39},
+					{Latitude: 39.90, Longitude: 116.45},
+					{Latitude: 39.95, Longitude: 116.45},
+				},
+				HalfWidth: 500,
+			},
+		},
+	}
+	if err := store.AddFence(ctx, fence); err != nil {
+		t.Fatalf("AddFence failed: %v", err)
+	}
+
+	retrieved, err := store.GetFence(ctx, fence.ID)
+	if err != nil {
+		t.Fatalf("GetFence failed: %v", err)
+	}
+	if c := retrieved.Geometry.Corridor; c == nil || len(c.Path) != 3 || c.HalfWidth != 500 {
+		t.Errorf("Corridor = %+v, want 3-point path with 500 m half-width", c)
+	}
+
+	tests := []struct {
+		name     string
+		lat, lon float64
+		want     int
+	}{
+		{"on route", 39.90, 116.42, 1},
+		{"within buffer", 39.903, 116.42, 1},
+		// Inside the R-Tree box but outside the buffer
+		{"inside the bend", 39.92, 116.42, 0},
+		{"beyond buffer", 39.90, 116.46, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := store.QueryAtPoint(ctx, tt.lat, tt.lon)
+			if err != nil {
+				t.Fatalf("QueryAtPoint failed: %v", err)
+			}
+			if len(results) != tt.want {
+				t.Errorf("QueryAtPoint returned %d results, want %d", len(results), tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryAtPoint_Antimeridian(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(ctx, &Config{Path: tempDB(t)})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	pacific := &geofence.FenceItem{
+		ID:       "pacific",
+		Type:     geofence.FenceTypePermanentNoFly,
+		Priority: 50,
+		Geometry: geofence.Geometry{
+			Polygon: []geofence.Point{
+				{Latitude: -10, Longitude: 170},
+				{Latitude: -10, Longitude: -170},
+				{Latitude: 10, Longitude: -170},
+				{Latitude: 10, Longitude: 170},
+			},
+		},
+	}
+	antarctic := &geofence.FenceItem{
+		ID:       "antarctic",
+		Type:     geofence.FenceTypePermanentNoFly,
+		Priority: 50,
+		Geometry: geofence.Geometry{
+			Polygon: []geofence.Point{
+				{Latitude: -70, Longitude: 0},
+				{Latitude: -70, Longitude: 90},
+				{Latitude: -70, Longitude: 180},
+				{Latitude: -70, Longitude: -90},
+			},
+		},
+	}
+	for _, f := range []*geofence.FenceItem{pacific, antarctic} {
+		if err := store.AddFence(ctx, f); err != nil {
+			t.Fatalf("AddFence failed: %v", err)
+		}
+	}
+
+	var entries int
+	if err := store.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM fence_index").Scan(&entries); err != nil {
+		t.Fatalf("count failed: %v", err)
+	}
+	if entries != 3 {
+		t.Errorf("fence_index has %d entries, want 3", entries)
+	}
+
+	points := []struct {
+		name     string
+		lat, lon float64
+		want     string
+	}{
+		{"east of antimeridian", 0, 175, "pacific"},
+		{"west of antimeridian", 0, -175, "pacific"},
+		{"on antimeridian", 0, 180, "pacific"},
+		{"on antimeridian as -180", 0, -180, "pacific"},
+		{"prime meridian", 0, 0, ""},
+		{"south pole", -90, 0, "antarctic"},
+		{"inside polar ring", -80, 45, "antarctic"},
+		{"outside polar ring", -60, 45, ""},
+	}
+	for _, tt := range points {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := store.QueryAtPoint(ctx, tt.lat, tt.lon)
+			if err != nil {
+				t.Fatalf("QueryAtPoint failed: %v", err)
+			}
+			var got string
+			if len(results) > 1 {
+				t.Fatalf("QueryAtPoint returned %d results, want at most 1", len(results))
+			}
+			if len(results) == 1 {
+				got = results[0].ID
+			}
+			if got != tt.want {
+				t.Errorf("QueryAtPoint(%v, %v) = %q, want %q", tt.lat, tt.lon, got, tt.want)
+			}
+		})
+	}
+
+	bounds := []struct {
+		name   string
+		bounds geofence.BoundingBox
+		want   int
+	}{
+		{"west side only", geofence.BoundingBox{MinLat: -1, MinLon: -175, MaxLat: 1, MaxLon: -172}, 1},
+		{"wrapping query box", geofence.BoundingBox{MinLat: -1, MinLon: 179, MaxLat: 1, MaxLon: -179}, 1},
+		{"polar", geofence.BoundingBox{MinLat: -85, MinLon: 10, MaxLat: -80, MaxLon: 20}, 1},
+		{"atlantic", geofence.BoundingBox{MinLat: -1, MinLon: -30, MaxLat: 1, MaxLon: -20}, 0},
+	}
+	for _, tt := range bounds {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := store.QueryInBounds(ctx, &tt.bounds)
+			if err != nil {
+				t.Fatalf("QueryInBounds failed: %v", err)
+			}
+			if len(results) != tt.want {
+				t.Errorf("QueryInBounds returned %d results, want %d", len(results), tt.want)
+			}
+		})
+	}
+
+	// Moving the fence off the antimeridian leaves a single index entry,
+	// and deleting it removes all of them
+	pacific.Geometry.Polygon = []geofence.Point{
+		{Latitude: -10, Longitude: 150},
+		{Latitude: -10, Longitude: 160},
+		{Latitude: 10, Longitude: 160},
+		{Latitude: 10, Longitude: 150},
+	}
+	if err := store.UpdateFence(ctx, pacific); err != nil {
+		t.Fatalf("UpdateFence failed: %v", err)
+	}
+	if results, err := store.QueryAtPoint(ctx, 0, -175); err != nil || len(results) != 0 {
+		t.Errorf("QueryAtPoint after update = %d results, %v, want none", len(results), err)
+	}
+	if results, err := store.QueryAtPoint(ctx, 0, 155); err != nil || len(results) != 1 {
+		t.Errorf("QueryAtPoint after update = %d results, %v, want 1", len(results), err)
+	}
+
+	if err := store.DeleteFence(ctx, "antarctic"); err != nil {
+		t.Fatalf("DeleteFence failed: %v", err)
+	}
+	if err := store.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM fence_index").Scan(&entries); err != nil {
+		t.Fatalf("count failed: %v", err)
+	}
+	if entries != 1 {
+		t.Errorf("fence_index has %d entries after update and delete, want 1", entries)
+	}
+}
+
+func TestAltitudeBandStorage(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(ctx, &Config{Path: tempDB(t)})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	fence := &geofence.FenceItem{
+		ID:   "band-001",
+		Type: geofence.FenceTypeTempRestriction,
+		Geometry: geofence.Geometry{
+			Polygon: []geofence.Point{
+				{Latitude: 39.0, Longitude: 116.0},
+				{Latitude: 39.0, Longitude: 117.0},
+				{Latitude: 40.0, Longitude: 117.0},
+			},
+		},
+		Altitude: &geofence.AltitudeBand{Floor: 120, Ceiling: 1500, Reference: geofence.AltitudeReferenceMSL},
+	}
+	if err := store.AddFence(ctx, fence); err != nil {
+		t.Fatalf("AddFence failed: %v", err)
+	}
+
+	retrieved, err := store.GetFence(ctx, fence.ID)
+	if err != nil {
+		t.Fatalf("GetFence failed: %v", err)
+	}
+	if retrieved.Altitude == nil || *retrieved.Altitude != *fence.Altitude {
+		t.Errorf("Altitude = %+v, want %+v", retrieved.Altitude, fence.Altitude)
+	}
+
+	// Clearing the band must round-trip as nil
+	fence.Altitude = nil
+	if err := store.UpdateFence(ctx, fence); err != nil {
+		t.Fatalf("UpdateFence failed: %v", err)
+	}
+	retrieved, err = store.GetFence(ctx, fence.ID)
+	if err != nil {
+		t.Fatalf("GetFence failed: %v", err)
+	}
+	if retrieved.Altitude != nil {
+		t.Errorf("Altitude = %+v, want nil", retrieved.Altitude)
+	}
+}
+
+func TestScheduleStorage(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(ctx, &Config{Path: tempDB(t)})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	now := time.Now().UTC()
+	today := now.Weekday()
+	square := geofence.Geometry{
+		Polygon: []geofence.Point{
+			{Latitude: 39.0, Longitude: 116.0},
+			{Latitude: 39.0, Longitude: 117.0},
+			{Latitude: 40.0, Longitude: 117.0},
+			{Latitude: 40.0, Longitude: 116.0},
+		},
+	}
+	fences := []*geofence.FenceItem{
+		{
+			ID:       "today",
+			Type:     geofence.FenceTypeTempRestriction,
+			Geometry: square,
+			Schedule: &geofence.Schedule{Rules: []geofence.WeeklyRule{
+				{Days: []time.Weekday{today, (today + 1) % 7}, StartMinute: 0, EndMinute: 1440},
+			}},
+		},
+		{
+			ID:       "other-day",
+			Type:     geofence.FenceTypeTempRestriction,
+			Geometry: square,
+			Schedule: &geofence.Schedule{TimeZone: "UTC", Rules: []geofence.WeeklyRule{
+				{Days: []time.Weekday{(today + 3) % 7}, StartMinute: 0, EndMinute: 1440},
+			}},
+		},
+	}
+	for _, f := range fences {
+		if err := store.AddFence(ctx, f); err != nil {
+			t.Fatalf("AddFence failed: %v", err)
+		}
+	}
+
+	retrieved, err := store.GetFence(ctx, "other-day")
+	if err != nil {
+		t.Fatalf("GetFence failed: %v", err)
+	}
+	if !retrieved.Schedule.Equal(fences[1].Schedule) {
+		t.Errorf("Schedule = %+v, want %+v", retrieved.Schedule, fences[1].Schedule)
+	}
+
+	results, err := store.QueryAtPoint(ctx, 39.5, 116.5)
+	if err != nil {
+		t.Fatalf("QueryAtPoint failed: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != "today" {
+		t.Errorf("QueryAtPoint returned %d results, want only today", len(results))
+	}
+
+	// Removing the schedule makes the fence permanently active
+	fences[1].Schedule = nil
+	if err := store.UpdateFence(ctx, fences[1]); err != nil {
+		t.Fatalf("UpdateFence failed: %v", err)
+	}
+	results, err = store.QueryAtPoint(ctx, 39.5, 116.5)
+	if err != nil {
+		t.Fatalf("QueryAtPoint failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("QueryAtPoint returned %d results, want 2", len(results))
+	}
+}
+
+func TestConditionsStorage(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(ctx, &Config{Path: tempDB(t)})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	fence := &geofence.FenceItem{
+		ID:   "heavy-only",
+		Type: geofence.FenceTypeTempRestriction,
+		Geometry: geofence.Geometry{
+			CircleCenter: &geofence.Point{Latitude: 39.5, Longitude: 116.5},
+			CircleRadius: 1000,
+		},
+		Conditions: &geofence.Conditions{
+			Operations:      []geofence.OperationType{geofence.OperationTypeCommercial},
+			MinMassKg:       25,
+			ExemptionTokens: []string{"utility-inspection"},
+		},
+	}
+	if err := store.AddFence(ctx, fence); err != nil {
+		t.Fatalf("AddFence failed: %v", err)
+	}
+
+	retrieved, err := store.GetFence(ctx, fence.ID)
+	if err != nil {
+		t.Fatalf("GetFence failed: %v", err)
+	}
+	if !retrieved.Conditions.Equal(fence.Conditions) {
+		t.Errorf("Conditions = %+v, want %+v", retrieved.Conditions, fence.Conditions)
+	}
+
+	fence.Conditions = nil
+	if err := store.UpdateFence(ctx, fence); err != nil {
+		t.Fatalf("UpdateFence failed: %v", err)
+	}
+	retrieved, err = store.GetFence(ctx, fence.ID)
+	if err != nil {
+		t.Fatalf("GetFence failed: %v", err)
+	}
+	if retrieved.Conditions != nil {
+		t.Errorf("Conditions = %+v, want nil", retrieved.Conditions)
+	}
+}
+
+func TestOpen_MigratesOldSchema(t *testing.T) {
+	ctx := context.Background()
+	path := tempDB(t)
+
+	// Create a fences table as written by the first release
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("sql.Open failed: %v", err)
+	}
+	_, err = db.ExecContext(ctx, `
+		CREATE TABLE fences (
+			rowid INTEGER PRIMARY KEY AUTOINCREMENT,
+			id TEXT UNIQUE NOT NULL,
+			type INTEGER NOT NULL,
+			start_ts INTEGER NOT NULL DEFAULT 0,
+			end_ts INTEGER NOT NULL DEFAULT 0,
+			priority INTEGER NOT NULL DEFAULT 0,
+			max_altitude INTEGER NOT NULL DEFAULT 0,
+			max_speed INTEGER NOT NULL DEFAULT 0,
+			name TEXT,
+			description TEXT,
+			signature BLOB,
+			key_id TEXT,
+			geometry_json TEXT NOT NULL,
+			created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now')),
+			updated_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now'))
+		);
+		INSERT INTO fences (id, type, name, description, key_id, geometry_json)
+		VALUES ('old-001', 2, '', '', '', '{}');
+	`)
+	db.Close()
+	if err != nil {
+		t.Fatalf("failed to create old schema: %v", err)
+	}
+
+	store, err := Open(ctx, &Config{Path: path})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	fence, err := store.GetFence(ctx, "old-001")
+	if err != nil {
+		t.Fatalf("GetFence failed: %v", err)
+	}
+	if fence.Altitude != nil {
+		t.Errorf("Altitude = %+v, want nil for migrated row", fence.Altitude)
+	}
+}
+
 func TestQueryInBounds(t *testing.T) {
 	ctx := context.Background()
 	store, err := Open(ctx, &Config{Path: tempDB(t)})
@@ -382,6 +933,84 @@ func TestQueryInBounds(t *testing.T) {
 	if !found {
 		t.Error("fence-north not found in QueryInBounds results")
 	}
+
+	// A box partially overlapping both fences must return both, and a box
+	// strictly inside one fence must return it
+	tests := []struct {
+		name   string
+		bounds geofence.BoundingBox
+		want   int
+	}{
+		{"overlapping both", geofence.BoundingBox{MinLat: 38.5, MaxLat: 40.5, MinLon: 116.2, MaxLon: 116.4}, 2},
+		{"inside north", geofence.BoundingBox{MinLat: 40.4, MaxLat: 40.6, MinLon: 116.4, MaxLon: 116.6}, 1},
+		{"disjoint", geofence.BoundingBox{MinLat: 10, MaxLat: 11, MinLon: 10, MaxLon: 11}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := store.QueryInBounds(ctx, &tt.bounds)
+			if err != nil {
+				t.Fatalf("QueryInBounds failed: %v", err)
+			}
+			if len(results) != tt.want {
+				t.Errorf("QueryInBounds returned %d results, want %d", len(results), tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryNearestRestrictive(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(ctx, &Config{Path: tempDB(t)})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	// Circles centered due north of the query point; 0.001 degrees is
+	// about 111 m
+	circle := func(id string, fenceType geofence.FenceType, lat float64) *geofence.FenceItem {
+		return &geofence.FenceItem{
+			ID:   id,
+			Type: fenceType,
+			Geometry: geofence.Geometry{
+				CircleCenter: &geofence.Point{Latitude: lat, Longitude: 116.0},
+				CircleRadius: 100,
+			},
+		}
+	}
+	fences := []*geofence.FenceItem{
+		circle("nofly-near", geofence.FenceTypePermanentNoFly, 39.003),
+		circle("tfr-far", geofence.FenceTypeTempRestriction, 39.010),
+		circle("speed-near", geofence.FenceTypeSpeedLimit, 39.002),
+		circle("nofly-outside", geofence.FenceTypePermanentNoFly, 39.100),
+	}
+	for _, f := range fences {
+		if err := store.AddFence(ctx, f); err != nil {
+			t.Fatalf("AddFence failed: %v", err)
+		}
+	}
+
+	results, err := store.QueryNearestRestrictive(ctx, 39.0, 116.0, 2000)
+	if err != nil {
+		t.Fatalf("QueryNearestRestrictive failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	if results[0].Fence.ID != "nofly-near" || results[1].Fence.ID != "tfr-far" {
+		t.Errorf("results = %s, %s, want nofly-near, tfr-far", results[0].Fence.ID, results[1].Fence.ID)
+	}
+	if d := results[0].Distance; d < 230 || d > 240 {
+		t.Errorf("Distance = %f, want about 233 m", d)
+	}
+
+	results, err = store.QueryNearestRestrictive(ctx, 39.0, 116.0, 100)
+	if err != nil {
+		t.Fatalf("QueryNearestRestrictive failed: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("got %d results within 100 m, want 0", len(results))
+	}
 }
 
 func TestManifestStorage(t *testing.T) {
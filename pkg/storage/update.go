@@ -0,0 +1,258 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/iannil/geofence-updater-lite/pkg/geofence"
+	"github.com/iannil/geofence-updater-lite/pkg/merkle"
+)
+
+// ErrRootHashMismatch is returned by ApplyUpdate when the fences it would
+// commit do not match the manifest's Merkle root.
+var ErrRootHashMismatch = errors.New("root hash mismatch")
+
+// Update is a version of the fence set to replace the stored one with,
+// either as the complete set of fences or as deltas from the stored set.
+type Update struct {
+	// Fences is the complete set of fences of the version. It is ignored
+	// if Deltas is set.
+	Fences []geofence.FenceItem
+
+	// Deltas, if set, are applied to the stored fences in order instead:
+	// each added, updated and removed fence is a single store operation.
+	// Removing or updating a fence that is not stored fails the update, as
+	// the deltas were then computed from a different set of fences.
+	Deltas []geofence.FenceDelta
+
+	// Withheld lists IDs of fences in Fences, or added or updated by
+	// Deltas, not to store, such as fences that failed signature
+	// verification. They are removed from the store but still count toward
+	// the root hash.
+	Withheld []string
+
+	// Manifest describes the version; its root hash is verified and it is
+	// stored along with its version number.
+	Manifest *geofence.Manifest
+}
+
+// UpdateStats counts the fences changed by ApplyUpdate.
+type UpdateStats struct {
+	Added   int
+	Updated int
+	Removed int
+}
+
+// ApplyUpdate replaces the stored fences, manifest and version with those of
+// an update in a single transaction: fences not in the update are deleted,
+// new ones added and changed ones updated, or the update's deltas are
+// applied to the stored fences. Before committing, the fences as
+// stored, together with the withheld ones, are checked against the
+// manifest's root hash. Nothing is changed if any step fails, including if
+// the process stops before the commit.
+func (s *SQLiteStore) ApplyUpdate(ctx context.Context, update *Update) (*UpdateStats, error) {
+	if update.Manifest == nil {
+		return nil, fmt.Errorf("update has no manifest")
+	}
+	formatVersion := update.Manifest.FormatVersion
+
+	withheld := make(map[string]bool, len(update.Withheld))
+	for _, id := range update.Withheld {
+		withheld[id] = true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := &UpdateStats{}
+	err := s.inTx(ctx, func(sqlTx *sql.Tx) error {
+		tx := &Tx{tx: sqlTx}
+
+		if update.Deltas != nil {
+			withheldFences, err := applyDeltas(ctx, tx, update.Deltas, withheld, stats)
+			if err != nil {
+				return err
+			}
+			return commitManifest(ctx, tx, update.Manifest, withheldFences)
+		}
+
+		current, err := tx.ListFences(ctx)
+		if err != nil {
+			return err
+		}
+		stored := make(map[string]*geofence.FenceItem, len(current))
+		for _, f := range current {
+			stored[f.ID] = f
+		}
+
+		keep := make(map[string]bool, len(update.Fences))
+		for i := range update.Fences {
+			f := &update.Fences[i]
+			if withheld[f.ID] {
+				continue
+			}
+			keep[f.ID] = true
+
+			old, ok := stored[f.ID]
+			if !ok {
+				if err := tx.AddFence(ctx, f); err != nil {
+					return fmt.Errorf("failed to add fence %s: %w", f.ID, err)
+				}
+				stats.Added++
+				continue
+			}
+			same, err := sameFence(*old, *f, formatVersion)
+			if err != nil {
+				return err
+			}
+			if same {
+				continue
+			}
+			if err := tx.UpdateFence(ctx, f); err != nil {
+				return fmt.Errorf("failed to update fence %s: %w", f.ID, err)
+			}
+			stats.Updated++
+		}
+
+		for id := range stored {
+			if keep[id] {
+				continue
+			}
+			if err := tx.DeleteFence(ctx, id); err != nil {
+				return fmt.Errorf("failed to delete fence %s: %w", id, err)
+			}
+			stats.Removed++
+		}
+
+		var withheldFences []geofence.FenceItem
+		for _, f := range update.Fences {
+			if withheld[f.ID] {
+				withheldFences = append(withheldFences, f)
+			}
+		}
+		return commitManifest(ctx, tx, update.Manifest, withheldFences)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// applyDeltas applies deltas to the fences stored in tx and returns the
+// withheld fences the deltas leave in the fence set.
+func applyDeltas(ctx context.Context, tx *Tx, deltas []geofence.FenceDelta, withheld map[string]bool, stats *UpdateStats) ([]geofence.FenceItem, error) {
+	withheldFences := make(map[string]geofence.FenceItem)
+	for _, delta := range deltas {
+		for _, id := range delta.RemovedIDs {
+			if _, ok := withheldFences[id]; ok {
+				delete(withheldFences, id)
+				continue
+			}
+			if err := tx.DeleteFence(ctx, id); err != nil {
+				return nil, fmt.Errorf("failed to delete fence %s: %w", id, err)
+			}
+			stats.Removed++
+		}
+		for i := range delta.Added {
+			f := &delta.Added[i]
+			if withheld[f.ID] {
+				withheldFences[f.ID] = *f
+				continue
+			}
+			if err := tx.AddFence(ctx, f); err != nil {
+				return nil, fmt.Errorf("failed to add fence %s: %w", f.ID, err)
+			}
+			stats.Added++
+		}
+		for i := range delta.Updated {
+			f := &delta.Updated[i]
+			if withheld[f.ID] {
+				if _, ok := withheldFences[f.ID]; !ok {
+					if err := tx.DeleteFence(ctx, f.ID); err != nil {
+						return nil, fmt.Errorf("failed to delete fence %s: %w", f.ID, err)
+					}
+					stats.Removed++
+				}
+				withheldFences[f.ID] = *f
+				continue
+			}
+			if _, ok := withheldFences[f.ID]; ok {
+				delete(withheldFences, f.ID)
+				if err := tx.AddFence(ctx, f); err != nil {
+					return nil, fmt.Errorf("failed to add fence %s: %w", f.ID, err)
+				}
+				stats.Added++
+				continue
+			}
+			if err := tx.UpdateFence(ctx, f); err != nil {
+				return nil, fmt.Errorf("failed to update fence %s: %w", f.ID, err)
+			}
+			stats.Updated++
+		}
+	}
+
+	fences := make([]geofence.FenceItem, 0, len(withheldFences))
+	for _, f := range withheldFences {
+		fences = append(fences, f)
+	}
+	return fences, nil
+}
+
+// commitManifest stores the manifest and its version in tx, then verifies
+// the stored fences against it.
+func commitManifest(ctx context.Context, tx *Tx, manifest *geofence.Manifest, withheldFences []geofence.FenceItem) error {
+	if err := tx.SetManifest(ctx, manifest); err != nil {
+		return err
+	}
+	if err := tx.SetVersion(ctx, manifest.Version); err != nil {
+		return err
+	}
+	return verifyStored(ctx, tx, manifest, withheldFences)
+}
+
+// sameFence reports whether a stored fence needs no update to match f.
+func sameFence(stored, f geofence.FenceItem, formatVersion uint32) (bool, error) {
+	if !bytes.Equal(stored.Signature, f.Signature) || stored.KeyID != f.KeyID {
+		return false, nil
+	}
+	a, err := merkle.LeafHash(stored, formatVersion)
+	if err != nil {
+		return false, err
+	}
+	b, err := merkle.LeafHash(f, formatVersion)
+	if err != nil {
+		return false, err
+	}
+	return a == b, nil
+}
+
+// verifyStored checks the fences stored in tx, together with the withheld
+// fences, against the manifest's root hash.
+func verifyStored(ctx context.Context, tx *Tx, manifest *geofence.Manifest, withheldFences []geofence.FenceItem) error {
+	if len(manifest.RootHash) == 0 {
+		return nil
+	}
+
+	current, err := tx.ListFences(ctx)
+	if err != nil {
+		return err
+	}
+	fences := make([]geofence.FenceItem, 0, len(current)+len(withheldFences))
+	for _, f := range current {
+		fences = append(fences, *f)
+	}
+	fences = append(fences, withheldFences...)
+
+	tree, err := merkle.NewTreeFormat(fences, manifest.FormatVersion)
+	if err != nil {
+		return fmt.Errorf("failed to build Merkle tree: %w", err)
+	}
+	rootHash := tree.RootHash()
+	if !bytes.Equal(rootHash[:], manifest.RootHash) {
+		return ErrRootHashMismatch
+	}
+	return nil
+}
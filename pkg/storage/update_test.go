@@ -0,0 +1,244 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/iannil/geofence-updater-lite/pkg/geofence"
+	"github.com/iannil/geofence-updater-lite/pkg/merkle"
+)
+
+func testFence(id string, lat float64) geofence.FenceItem {
+	return geofence.FenceItem{
+		ID:       id,
+		Type:     geofence.FenceTypePermanentNoFly,
+		Geometry: geofence.Geometry{BBox: &geofence.BoundingBox{MinLat: lat, MinLon: 110, MaxLat: lat + 0.5, MaxLon: 111}},
+	}
+}
+
+func updateManifest(t *testing.T, version uint64, fences []geofence.FenceItem) *geofence.Manifest {
+	t.Helper()
+	tree, err := merkle.NewTree(fences)
+	if err != nil {
+		t.Fatalf("NewTree failed: %v", err)
+	}
+	rootHash := tree.RootHash()
+	return &geofence.Manifest{Version: version, RootHash: rootHash[:], FormatVersion: geofence.CurrentFormatVersion}
+}
+
+func storedIDs(t *testing.T, store *SQLiteStore) []string {
+	t.Helper()
+	fences, err := store.ListFences(context.Background())
+	if err != nil {
+		t.Fatalf("ListFences failed: %v", err)
+	}
+	var ids []string
+	for _, f := range fences {
+		ids = append(ids, f.ID)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func TestApplyUpdate(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(ctx, &Config{Path: tempDB(t)})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	v1 := []geofence.FenceItem{testFence("a", 30), testFence("b", 31)}
+	stats, err := store.ApplyUpdate(ctx, &Update{Fences: v1, Manifest: updateManifest(t, 1, v1)})
+	if err != nil {
+		t.Fatalf("ApplyUpdate failed: %v", err)
+	}
+	if *stats != (UpdateStats{Added: 2}) {
+		t.Errorf("stats = %+v, want 2 added", *stats)
+	}
+
+	// b is unchanged, a is removed, c is added and d withheld
+	v2 := []geofence.FenceItem{testFence("b", 31), testFence("c", 32), testFence("d", 33)}
+	stats, err = store.ApplyUpdate(ctx, &Update{Fences: v2, Withheld: []string{"d"}, Manifest: updateManifest(t, 2, v2)})
+	if err != nil {
+		t.Fatalf("ApplyUpdate failed: %v", err)
+	}
+	if *stats != (UpdateStats{Added: 1, Removed: 1}) {
+		t.Errorf("stats = %+v, want 1 added and 1 removed", *stats)
+	}
+	if ids := storedIDs(t, store); !slices.Equal(ids, []string{"b", "c"}) {
+		t.Errorf("stored fences = %v, want [b c]", ids)
+	}
+
+	v3 := []geofence.FenceItem{testFence("b", 31.2), testFence("c", 32)}
+	stats, err = store.ApplyUpdate(ctx, &Update{Fences: v3, Manifest: updateManifest(t, 3, v3)})
+	if err != nil {
+		t.Fatalf("ApplyUpdate failed: %v", err)
+	}
+	if *stats != (UpdateStats{Updated: 1}) {
+		t.Errorf("stats = %+v, want 1 updated", *stats)
+	}
+
+	version, err := store.GetVersion(ctx)
+	if err != nil {
+		t.Fatalf("GetVersion failed: %v", err)
+	}
+	manifest, err := store.GetManifest(ctx)
+	if err != nil {
+		t.Fatalf("GetManifest failed: %v", err)
+	}
+	if version != 3 || manifest == nil || manifest.Version != 3 {
+		t.Errorf("stored version = %d, manifest = %+v, want version 3", version, manifest)
+	}
+}
+
+func TestApplyUpdate_RootHashMismatch(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(ctx, &Config{Path: tempDB(t)})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	v1 := []geofence.FenceItem{testFence("a", 30), testFence("b", 31)}
+	if _, err := store.ApplyUpdate(ctx, &Update{Fences: v1, Manifest: updateManifest(t, 1, v1)}); err != nil {
+		t.Fatalf("ApplyUpdate failed: %v", err)
+	}
+
+	// The manifest names a different set of fences; nothing is changed
+	v2 := []geofence.FenceItem{testFence("b", 31.2), testFence("c", 32)}
+	manifest := updateManifest(t, 2, []geofence.FenceItem{testFence("c", 32)})
+	if _, err := store.ApplyUpdate(ctx, &Update{Fences: v2, Manifest: manifest}); !errors.Is(err, ErrRootHashMismatch) {
+		t.Fatalf("ApplyUpdate() error = %v, want %v", err, ErrRootHashMismatch)
+	}
+
+	if ids := storedIDs(t, store); !slices.Equal(ids, []string{"a", "b"}) {
+		t.Errorf("stored fences = %v, want [a b]", ids)
+	}
+	b, err := store.GetFence(ctx, "b")
+	if err != nil {
+		t.Fatalf("GetFence failed: %v", err)
+	}
+	if b.Geometry.BBox.MinLat != 31 {
+		t.Errorf("fence b was updated by a rejected update")
+	}
+	version, err := store.GetVersion(ctx)
+	if err != nil {
+		t.Fatalf("GetVersion failed: %v", err)
+	}
+	if version != 1 {
+		t.Errorf("version = %d, want 1", version)
+	}
+
+	// The spatial index is rolled back with the fences
+	results, err := store.QueryAtPoint(ctx, 32.2, 110.5)
+	if err != nil {
+		t.Fatalf("QueryAtPoint failed: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("QueryAtPoint found %d fences from a rejected update", len(results))
+	}
+}
+
+func TestApplyUpdate_Deltas(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(ctx, &Config{Path: tempDB(t)})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	v1 := []geofence.FenceItem{testFence("a", 30), testFence("b", 31), testFence("c", 32)}
+	if _, err := store.ApplyUpdate(ctx, &Update{Fences: v1, Manifest: updateManifest(t, 1, v1)}); err != nil {
+		t.Fatalf("ApplyUpdate failed: %v", err)
+	}
+
+	// Two hops: a is removed, b updated twice, d added and e added withheld
+	v2 := []geofence.FenceItem{testFence("b", 31.2), testFence("c", 32), testFence("d", 33)}
+	v3 := []geofence.FenceItem{testFence("b", 31.4), testFence("c", 32), testFence("d", 33), testFence("e", 34)}
+	deltas := []geofence.FenceDelta{geofence.CreateDelta(v1, v2), geofence.CreateDelta(v2, v3)}
+	stats, err := store.ApplyUpdate(ctx, &Update{Deltas: deltas, Withheld: []string{"e"}, Manifest: updateManifest(t, 3, v3)})
+	if err != nil {
+		t.Fatalf("ApplyUpdate failed: %v", err)
+	}
+	if *stats != (UpdateStats{Added: 1, Updated: 2, Removed: 1}) {
+		t.Errorf("stats = %+v, want 1 added, 2 updated and 1 removed", *stats)
+	}
+	if ids := storedIDs(t, store); !slices.Equal(ids, []string{"b", "c", "d"}) {
+		t.Errorf("stored fences = %v, want [b c d]", ids)
+	}
+	b, err := store.GetFence(ctx, "b")
+	if err != nil {
+		t.Fatalf("GetFence failed: %v", err)
+	}
+	if b.Geometry.BBox.MinLat != 31.4 {
+		t.Errorf("fence b MinLat = %v, want 31.4", b.Geometry.BBox.MinLat)
+	}
+
+	// A delta from a different base fails and changes nothing
+	stale := geofence.FenceDelta{RemovedIDs: []string{"a"}}
+	v4 := []geofence.FenceItem{testFence("b", 31.4), testFence("c", 32), testFence("d", 33)}
+	_, err = store.ApplyUpdate(ctx, &Update{Deltas: []geofence.FenceDelta{stale}, Manifest: updateManifest(t, 4, v4)})
+	if !errors.Is(err, ErrFenceNotFound) {
+		t.Fatalf("ApplyUpdate() error = %v, want %v", err, ErrFenceNotFound)
+	}
+	version, err := store.GetVersion(ctx)
+	if err != nil {
+		t.Fatalf("GetVersion failed: %v", err)
+	}
+	if version != 3 {
+		t.Errorf("version = %d, want 3", version)
+	}
+}
+
+func TestTx_Rollback(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(ctx, &Config{Path: tempDB(t)})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	a := testFence("a", 30)
+	if err := store.AddFence(ctx, &a); err != nil {
+		t.Fatalf("AddFence failed: %v", err)
+	}
+
+	tx, err := store.BeginTx(ctx)
+	if err != nil {
+		t.Fatalf("BeginTx failed: %v", err)
+	}
+	b := testFence("b", 31)
+	if err := tx.AddFence(ctx, &b); err != nil {
+		t.Fatalf("Tx.AddFence failed: %v", err)
+	}
+	if err := tx.DeleteFence(ctx, "a"); err != nil {
+		t.Fatalf("Tx.DeleteFence failed: %v", err)
+	}
+	if err := tx.SetVersion(ctx, 7); err != nil {
+		t.Fatalf("Tx.SetVersion failed: %v", err)
+	}
+	inTx, err := tx.ListFences(ctx)
+	if err != nil {
+		t.Fatalf("Tx.ListFences failed: %v", err)
+	}
+	if len(inTx) != 1 || inTx[0].ID != "b" {
+		t.Errorf("Tx.ListFences() = %d fences, want only b", len(inTx))
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+
+	if ids := storedIDs(t, store); !slices.Equal(ids, []string{"a"}) {
+		t.Errorf("stored fences = %v, want [a]", ids)
+	}
+	version, err := store.GetVersion(ctx)
+	if err != nil {
+		t.Fatalf("GetVersion failed: %v", err)
+	}
+	if version != 0 {
+		t.Errorf("version = %d, want 0 after rollback", version)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/iannil/geofence-updater-lite/pkg/binarydiff"
 	"github.com/iannil/geofence-updater-lite/pkg/client"
 	"github.com/iannil/geofence-updater-lite/pkg/config"
+	"github.com/iannil/geofence-updater-lite/pkg/converter"
 	"github.com/iannil/geofence-updater-lite/pkg/crypto"
 	"github.com/iannil/geofence-updater-lite/pkg/geofence"
 	"github.com/iannil/geofence-updater-lite/pkg/merkle"
@@ -25,10 +26,15 @@ type Syncer struct {
 	client       *client.Client
 	store        *storage.SQLiteStore
 	cfg          *config.ClientConfig
+	keyring      *crypto.Keyring // nil if signature verification is disabled
+	clock        atomic.Value    // geofence.Clock for fence checks
+	aircraft     atomic.Pointer[geofence.Aircraft]
 	currentVer   atomic.Uint64
-	mu           sync.RWMutex // protects lastCheck and lastSyncTime
+	mu           sync.RWMutex // protects lastCheck, lastSyncTime, unlocks and quarantined
 	lastCheck    time.Time
 	lastSyncTime time.Time
+	unlocks      []geofence.UnlockToken
+	quarantined  []string
 }
 
 // NewSyncer creates a new geofence syncer.
@@ -43,6 +49,12 @@ func NewSyncer(ctx context.Context, cfg *config.ClientConfig) (*Syncer, error) {
 		return nil, fmt.Errorf("failed to create HTTP client: %w", err)
 	}
 
+	// Fence signatures are checked against the same keys as manifests
+	var keyring *crypto.Keyring
+	if !cfg.InsecureSkipVerify {
+		keyring = httpClient.Keyring()
+	}
+
 	// Open storage
 	store, err := storage.Open(ctx, &storage.Config{Path: cfg.StorePath})
 	if err != nil {
@@ -59,13 +71,114 @@ func NewSyncer(ctx context.Context, cfg *config.ClientConfig) (*Syncer, error) {
 		client:    httpClient,
 		store:     store,
 		cfg:       cfg,
+		keyring:   keyring,
 		lastCheck: time.Time{},
 	}
 	s.currentVer.Store(currentVer)
+	s.SetClock(geofence.SystemClock{})
 
 	return s, nil
 }
 
+// SetClock sets the clock used to decide which fences are active in Check,
+// Check3D, CheckRoute, NearbyRestrictions and PredictEntry. Use a
+// geofence.FixedClock to evaluate them as of another instant. Defaults to
+// the system clock.
+func (s *Syncer) SetClock(clock geofence.Clock) {
+	s.clock.Store(&clock)
+}
+
+// now returns the current time of the syncer's clock.
+func (s *Syncer) now() time.Time {
+	return (*s.clock.Load().(*geofence.Clock)).Now()
+}
+
+// SetAircraft sets the profile of the aircraft fences are checked for in
+// Check, Check3D, CheckRoute, NearbyRestrictions and PredictEntry. Fences
+// whose conditions exclude the aircraft are left out. Defaults to nil, for
+// which every fence applies.
+func (s *Syncer) SetAircraft(a *geofence.Aircraft) {
+	s.aircraft.Store(a)
+}
+
+// SetUnlockTokens sets the unlock tokens honored by Check, Check3D,
+// CheckRoute and PredictEntry and their variants, replacing any set before.
+// Every token's signature is verified against the trusted keyring; if any
+// fails, none are set. A token only lifts a fence for the device whose
+// serial number matches the aircraft profile's Serial, within its time
+// window and area; CheckRoute and PredictEntry only lift a fence if a token
+// covers the whole route. Lifted fences are reported as restricted but
+// authorized: the Evaluation lists them with UnlockedBy set and has
+// Authorized set, but they do not forbid flight. The other checks leave them
+// out.
+func (s *Syncer) SetUnlockTokens(tokens []geofence.UnlockToken) error {
+	for i := range tokens {
+		if err := s.client.VerifyUnlockToken(&tokens[i]); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unlocks = append([]geofence.UnlockToken(nil), tokens...)
+	return nil
+}
+
+// ApplyKeyRotation verifies a key rotation announcement delivered out of
+// band, such as by a ground station, and applies it to the trusted keys.
+// Announcements published next to the manifest are picked up by Sync.
+func (s *Syncer) ApplyKeyRotation(rot *crypto.KeyRotation) error {
+	return s.client.ApplyKeyRotation(rot)
+}
+
+// unlockTokens returns the tokens set with SetUnlockTokens.
+func (s *Syncer) unlockTokens() []geofence.UnlockToken {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.unlocks
+}
+
+// serialOf returns the serial number of an aircraft, or "" if it is nil.
+func serialOf(a *geofence.Aircraft) string {
+	if a == nil {
+		return ""
+	}
+	return a.Serial
+}
+
+// withoutUnlocked returns the fences that none of the tokens lift, as
+// decided by unlocks.
+func withoutUnlocked(fences []geofence.FenceItem, tokens []geofence.UnlockToken, unlocks func(*geofence.UnlockToken, *geofence.FenceItem) bool) []geofence.FenceItem {
+	if len(tokens) == 0 {
+		return fences
+	}
+	kept := fences[:0]
+	for i := range fences {
+		lifted := false
+		for j := range tokens {
+			if unlocks(&tokens[j], &fences[i]) {
+				lifted = true
+				break
+			}
+		}
+		if !lifted {
+			kept = append(kept, fences[i])
+		}
+	}
+	return kept
+}
+
+// applicable returns the stored fences that apply to the aircraft.
+func applicable(results []*geofence.FenceItem, a *geofence.Aircraft) []geofence.FenceItem {
+	fences := make([]geofence.FenceItem, 0, len(results))
+	for _, f := range results {
+		if f.AppliesTo(a) {
+			fences = append(fences, *f)
+		}
+	}
+	return fences
+}
+
 // SyncResult contains the result of a sync operation.
 type SyncResult struct {
 	UpToDate      bool
@@ -75,8 +188,13 @@ type SyncResult struct {
 	FencesRemoved int
 	FencesUpdated int
 	BytesDownload int
-	Duration      time.Duration
-	Error         error
+	// Number of deltas applied, or 0 if the snapshot was used
+	DeltaHops int
+	// IDs of fences left out because their signature did not verify, with
+	// QuarantineInvalidFences set
+	FencesQuarantined []string
+	Duration          time.Duration
+	Error             error
 }
 
 // CheckForUpdates checks if there's a new version available without downloading.
@@ -119,27 +237,58 @@ func (s *Syncer) Sync(ctx context.Context) *SyncResult {
 	// Need to update
 	log.Printf("[Sync] New version available: %d -> %d", currentVer, manifest.Version)
 
-	// Decide whether to use delta or snapshot
-	useDelta := (manifest.Version-currentVer) == 1 && manifest.DeltaURL != ""
+	// Pick up key rotations, such as revocations, before verifying fences
+	if !s.cfg.InsecureSkipVerify {
+		if err := s.client.RefreshKeys(ctx); err != nil {
+			log.Printf("[Sync] Failed to refresh trusted keys: %v", err)
+		}
+	}
+
+	// Decide whether to use deltas or the snapshot. A delta patches an
+	// earlier version, which is incomplete locally while fences are quarantined.
+	var chain []geofence.PatchInfo
+	var binary bool
+	if len(s.QuarantinedFences()) == 0 {
+		chain, binary = s.planDeltas(ctx, manifest, currentVer)
+	}
 
+	var quarantined []string
+	useDelta := len(chain) > 0
 	if useDelta {
-		log.Printf("[Sync] Using delta update from %s", manifest.DeltaURL)
-		err = s.applyDelta(ctx, manifest)
-	} else {
+		if binary {
+			log.Printf("[Sync] Using %d binary delta(s) from version %d", len(chain), currentVer)
+			quarantined, err = s.syncBinaryDeltas(ctx, chain, manifest, result)
+		} else {
+			log.Printf("[Sync] Using %d delta update(s) from version %d", len(chain), currentVer)
+			quarantined, err = s.syncDeltas(ctx, chain, manifest, result)
+		}
+		if err != nil {
+			log.Printf("[Sync] Delta update failed, falling back to snapshot: %v", err)
+			useDelta = false
+		} else {
+			result.DeltaHops = len(chain)
+		}
+	}
+	if !useDelta {
 		log.Printf("[Sync] Using snapshot from %s", manifest.SnapshotURL)
-		err = s.applySnapshot(ctx, manifest)
+		var fences []geofence.FenceItem
+		fences, err = s.loadSnapshot(ctx, manifest)
+		if err == nil {
+			quarantined, err = s.installFences(ctx, fences, manifest, result)
+		}
 	}
-
 	if err != nil {
 		result.Error = fmt.Errorf("failed to apply update: %w", err)
 		return result
 	}
+	result.FencesQuarantined = quarantined
 
 	// Update current version atomically
 	s.currentVer.Store(manifest.Version)
 
 	s.mu.Lock()
 	s.lastSyncTime = time.Now()
+	s.quarantined = quarantined
 	s.mu.Unlock()
 
 	result.Duration = time.Since(start)
@@ -149,140 +298,364 @@ func (s *Syncer) Sync(ctx context.Context) *SyncResult {
 	return result
 }
 
-// applyDelta applies a delta update to the local fence database.
-func (s *Syncer) applyDelta(ctx context.Context, manifest *geofence.Manifest) error {
-	// Fetch delta data
-	deltaData, err := s.client.FetchDelta(ctx, manifest.DeltaURL)
+// planDeltas returns the chain of deltas with the fewest bytes to download
+// from version from to the manifest's version, and whether to download
+// their binary diffs instead of their delta files. It returns nil if there
+// is no chain or the snapshot is smaller. Deltas come from the manifest and
+// the publisher's patch index, which alone lists binary diffs.
+func (s *Syncer) planDeltas(ctx context.Context, manifest *geofence.Manifest, from uint64) ([]geofence.PatchInfo, bool) {
+	var patches []geofence.PatchInfo
+	if manifest.DeltaURL != "" {
+		patches = append(patches, geofence.PatchInfo{
+			FromVersion:   manifest.Version - 1,
+			ToVersion:     manifest.Version,
+			URL:           manifest.DeltaURL,
+			Size:          manifest.DeltaSize,
+			Hash:          manifest.DeltaHash,
+			RootHash:      manifest.RootHash,
+			FormatVersion: manifest.FormatVersion,
+			Encodings:     manifest.DeltaEncodings,
+		})
+	}
+	index, err := s.client.FetchPatchIndex(ctx)
+	switch {
+	case err != nil:
+		log.Printf("[Sync] Failed to fetch patch index: %v", err)
+	case index == nil:
+	case index.Version != manifest.Version:
+		log.Printf("[Sync] Ignoring patch index for version %d", index.Version)
+	default:
+		patches = append(patches, index.Patches...)
+	}
+
+	// Compare the bytes downloaded, which for compressed copies are not
+	// the file sizes. Binary diffs are applied differently, so they only
+	// form chains of their own.
+	byURL := make(map[string]geofence.PatchInfo, len(patches))
+	priced := make([]geofence.PatchInfo, 0, len(patches))
+	var binaryPriced []geofence.PatchInfo
+	for _, patch := range patches {
+		if known, ok := byURL[patch.URL]; !ok || known.Binary == nil {
+			byURL[patch.URL] = patch
+		}
+		p := patch
+		p.Size = s.client.TransferSize(patchFile(patch))
+		priced = append(priced, p)
+		if patch.Binary != nil {
+			p.Size = s.client.TransferSize(binaryFile(patch))
+			binaryPriced = append(binaryPriced, p)
+		}
+	}
+	chain, size := geofence.CheapestChain(priced, from, manifest.Version)
+	binaryChain, binarySize := geofence.CheapestChain(binaryPriced, from, manifest.Version)
+	binary := binaryChain != nil && (chain == nil || binarySize < size)
+	if binary {
+		chain, size = binaryChain, binarySize
+	}
+	if chain == nil {
+		return nil, false
+	}
+	snapshotSize := s.client.TransferSize(snapshotFile(manifest))
+	if snapshotSize > 0 && size >= snapshotSize {
+		log.Printf("[Sync] Deltas (%d bytes) are no smaller than the snapshot (%d bytes)", size, snapshotSize)
+		return nil, false
+	}
+	for i := range chain {
+		chain[i] = byURL[chain[i].URL]
+	}
+	return chain, binary
+}
+
+// snapshotFile describes the snapshot of the manifest's version.
+func snapshotFile(manifest *geofence.Manifest) client.File {
+	return client.File{
+		URL:       manifest.SnapshotURL,
+		Size:      manifest.SnapshotSize,
+		Hash:      manifest.SnapshotHash,
+		Encodings: manifest.SnapshotEncodings,
+	}
+}
+
+// patchFile describes the delta file of a patch.
+func patchFile(patch geofence.PatchInfo) client.File {
+	return client.File{URL: patch.URL, Size: patch.Size, Hash: patch.Hash, Encodings: patch.Encodings}
+}
+
+// binaryFile describes the binary diff file of a patch.
+func binaryFile(patch geofence.PatchInfo) client.File {
+	b := patch.Binary
+	return client.File{URL: b.URL, Size: b.Size, Hash: b.Hash, Encodings: b.Encodings}
+}
+
+// syncDeltas fetches a chain of deltas and applies them to the stored
+// fences in one transaction, verified against the manifest's root hash. It
+// records the changes in result and returns the IDs of the fences
+// quarantined.
+func (s *Syncer) syncDeltas(ctx context.Context, chain []geofence.PatchInfo, manifest *geofence.Manifest, result *SyncResult) ([]string, error) {
+	files, err := s.loadDeltas(ctx, chain)
 	if err != nil {
-		return fmt.Errorf("failed to fetch delta: %w", err)
+		return nil, err
 	}
+	return s.installDeltas(ctx, files, manifest, result)
+}
 
-	// Verify delta hash
-	if len(manifest.DeltaHash) > 0 {
-		if !crypto.VerifyHash(deltaData, manifest.DeltaHash) {
-			return fmt.Errorf("delta hash verification failed")
+// loadDeltas fetches and verifies the deltas of a chain, checking that each
+// applies to the fences the previous one produces.
+func (s *Syncer) loadDeltas(ctx context.Context, chain []geofence.PatchInfo) ([]*geofence.FenceDeltaFile, error) {
+	// The first delta must apply to the stored fences; the store's own
+	// check when installing catches a mismatch in any other format version
+	var prevRoot []byte
+	stored, err := s.store.GetManifest(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stored manifest: %w", err)
+	}
+	if stored != nil && len(chain) > 0 && stored.FormatVersion == chain[0].FormatVersion {
+		prevRoot = stored.RootHash
+	}
+
+	files := make([]*geofence.FenceDeltaFile, 0, len(chain))
+	for _, patch := range chain {
+		d, err := s.loadDelta(ctx, patch)
+		if err != nil {
+			return nil, fmt.Errorf("delta v%d to v%d: %w", patch.FromVersion, patch.ToVersion, err)
+		}
+		if len(prevRoot) > 0 && !bytes.Equal(d.FromRootHash, prevRoot) {
+			return nil, fmt.Errorf("delta v%d to v%d does not apply to the fences of version %d", patch.FromVersion, patch.ToVersion, patch.FromVersion)
 		}
+		prevRoot = d.ToRootHash
+		files = append(files, d)
 	}
+	return files, nil
+}
 
-	// Get current fences
-	oldFences, err := s.getCurrentFences(ctx)
+// loadDelta fetches a delta and verifies its hash, signature and versions.
+func (s *Syncer) loadDelta(ctx context.Context, patch geofence.PatchInfo) (*geofence.FenceDeltaFile, error) {
+	// Fetch delta data, verifying its hash
+	deltaData, err := s.client.FetchFile(ctx, patchFile(patch), "delta")
 	if err != nil {
-		return fmt.Errorf("failed to get current fences: %w", err)
+		return nil, fmt.Errorf("failed to fetch delta: %w", err)
 	}
 
 	// Parse delta file
-	delta, err := binarydiff.ReadDelta(bytes.NewReader(deltaData))
+	d, err := converter.UnmarshalFenceDeltaFile(deltaData)
 	if err != nil {
-		return fmt.Errorf("failed to parse delta: %w", err)
+		return nil, fmt.Errorf("failed to parse delta: %w", err)
+	}
+
+	// Verify delta signature
+	if s.keyring == nil {
+		log.Printf("[SECURITY WARNING] Skipping signature verification for delta v%d to v%d", d.FromVersion, d.ToVersion)
+	} else {
+		signed := converter.SignableFenceDelta{File: d}
+		if err := s.keyring.VerifySigned(signed, d.Signature, d.KeyID, crypto.RoleSigning, time.Now()); err != nil {
+			return nil, fmt.Errorf("delta signature verification failed: %w", err)
+		}
 	}
 
-	// Fill version info
-	delta.FromVersion = s.currentVer.Load()
-	delta.ToVersion = manifest.Version
+	if d.FromVersion != patch.FromVersion || d.ToVersion != patch.ToVersion {
+		return nil, fmt.Errorf("delta is from version %d to %d", d.FromVersion, d.ToVersion)
+	}
+	if len(patch.RootHash) > 0 && (d.FormatVersion != patch.FormatVersion || !bytes.Equal(d.ToRootHash, patch.RootHash)) {
+		return nil, fmt.Errorf("delta root hash does not match")
+	}
+	return d, nil
+}
 
-	// Apply patch
-	newFences, err := binarydiff.PatchFences(oldFences, delta)
+// installDeltas verifies the signature of every fence the deltas add or
+// update and applies the deltas to the stored fences, manifest and version
+// in one transaction, withholding quarantined fences. It records the
+// changes in result and returns the IDs of the fences quarantined.
+func (s *Syncer) installDeltas(ctx context.Context, files []*geofence.FenceDeltaFile, manifest *geofence.Manifest, result *SyncResult) ([]string, error) {
+	// Only the final version of each changed fence needs verifying
+	changed := make(map[string]geofence.FenceItem)
+	deltas := make([]geofence.FenceDelta, 0, len(files))
+	for _, d := range files {
+		for _, id := range d.Delta.RemovedIDs {
+			delete(changed, id)
+		}
+		for _, f := range d.Delta.Added {
+			changed[f.ID] = f
+		}
+		for _, f := range d.Delta.Updated {
+			changed[f.ID] = f
+		}
+		deltas = append(deltas, d.Delta)
+	}
+	fences := make([]geofence.FenceItem, 0, len(changed))
+	for _, f := range changed {
+		fences = append(fences, f)
+	}
+
+	quarantined, err := s.verifyFences(fences, manifest.FormatVersion)
 	if err != nil {
-		return fmt.Errorf("failed to apply patch: %w", err)
+		return nil, err
 	}
 
-	// Update storage
-	if err := s.updateStorage(ctx, newFences, manifest); err != nil {
-		return fmt.Errorf("failed to update storage: %w", err)
+	stats, err := s.store.ApplyUpdate(ctx, &storage.Update{
+		Deltas:   deltas,
+		Withheld: quarantined,
+		Manifest: manifest,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update storage: %w", err)
 	}
+	result.FencesAdded = stats.Added
+	result.FencesUpdated = stats.Updated
+	result.FencesRemoved = stats.Removed
 
-	return nil
+	return quarantined, nil
 }
 
-// applySnapshot applies a full snapshot update to the local fence database.
-func (s *Syncer) applySnapshot(ctx context.Context, manifest *geofence.Manifest) error {
-	// Fetch snapshot data
-	snapshotData, err := s.client.FetchSnapshot(ctx, manifest.SnapshotURL)
+// syncBinaryDeltas fetches the binary diffs of a chain and applies them to
+// the stored fences, verified against each patch's and the manifest's root
+// hash, then installs the result as installFences does. It records the
+// changes in result and returns the IDs of the fences quarantined.
+func (s *Syncer) syncBinaryDeltas(ctx context.Context, chain []geofence.PatchInfo, manifest *geofence.Manifest, result *SyncResult) ([]string, error) {
+	fences, err := s.getCurrentFences(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to fetch snapshot: %w", err)
+		return nil, fmt.Errorf("failed to get stored fences: %w", err)
 	}
-
-	// Verify snapshot hash
-	if len(manifest.SnapshotHash) > 0 {
-		if !crypto.VerifyHash(snapshotData, manifest.SnapshotHash) {
-			return fmt.Errorf("snapshot hash verification failed")
+	for _, patch := range chain {
+		fences, err = s.applyBinaryDelta(ctx, fences, patch)
+		if err != nil {
+			return nil, fmt.Errorf("binary delta v%d to v%d: %w", patch.FromVersion, patch.ToVersion, err)
 		}
 	}
+	if err := verifyRootHash(fences, manifest.RootHash, manifest.FormatVersion); err != nil {
+		return nil, err
+	}
+	return s.installFences(ctx, fences, manifest, result)
+}
+
+// applyBinaryDelta fetches the binary diff of a patch and applies it to the
+// fences of the patch's starting version.
+func (s *Syncer) applyBinaryDelta(ctx context.Context, fences []geofence.FenceItem, patch geofence.PatchInfo) ([]geofence.FenceItem, error) {
+	// Fetch diff data, verifying its hash
+	data, err := s.client.FetchFile(ctx, binaryFile(patch), "binary delta")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch binary delta: %w", err)
+	}
+
+	delta, err := binarydiff.ReadDeltaFile(bytes.NewReader(data), patch.ToVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse binary delta: %w", err)
+	}
+	if delta.FromVersion != patch.FromVersion {
+		return nil, fmt.Errorf("binary delta is from version %d", delta.FromVersion)
+	}
+
+	patched, err := binarydiff.PatchFences(fences, delta)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyRootHash(patched, patch.RootHash, patch.FormatVersion); err != nil {
+		return nil, err
+	}
+	return patched, nil
+}
+
+// loadSnapshot fetches and verifies the full snapshot of the manifest's
+// version and returns its fences.
+func (s *Syncer) loadSnapshot(ctx context.Context, manifest *geofence.Manifest) ([]geofence.FenceItem, error) {
+	// Fetch snapshot data, verifying its hash
+	snapshotData, err := s.client.FetchFile(ctx, snapshotFile(manifest), "snapshot")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch snapshot: %w", err)
+	}
 
 	// Load snapshot
 	fences, err := merkle.LoadSnapshot(snapshotData)
 	if err != nil {
-		return fmt.Errorf("failed to load snapshot: %w", err)
+		return nil, fmt.Errorf("failed to load snapshot: %w", err)
 	}
 
 	// Verify Merkle root hash
-	if len(manifest.RootHash) > 0 {
-		tree, err := merkle.NewTree(fences)
-		if err != nil {
-			return fmt.Errorf("failed to build Merkle tree: %w", err)
-		}
-		rootHash := tree.RootHash()
-		if !bytes.Equal(rootHash[:], manifest.RootHash) {
-			return fmt.Errorf("root hash verification failed")
-		}
+	if err := verifyRootHash(fences, manifest.RootHash, manifest.FormatVersion); err != nil {
+		return nil, err
 	}
+	return fences, nil
+}
 
-	// Update storage
-	if err := s.updateStorage(ctx, fences, manifest); err != nil {
-		return fmt.Errorf("failed to update storage: %w", err)
+// verifyRootHash checks that the fences match a Merkle root computed in the
+// given format version. An empty root is not checked.
+func verifyRootHash(fences []geofence.FenceItem, rootHash []byte, formatVersion uint32) error {
+	if len(rootHash) == 0 {
+		return nil
+	}
+	tree, err := merkle.NewTreeFormat(fences, formatVersion)
+	if err != nil {
+		return fmt.Errorf("failed to build Merkle tree: %w", err)
+	}
+	computed := tree.RootHash()
+	if !bytes.Equal(computed[:], rootHash) {
+		return fmt.Errorf("root hash verification failed")
 	}
-
 	return nil
 }
 
-// getCurrentFences retrieves all current fences from storage.
-func (s *Syncer) getCurrentFences(ctx context.Context) ([]geofence.FenceItem, error) {
-	fencePtrs, err := s.store.ListFences(ctx)
+// installFences verifies the signature of every fence and replaces the
+// stored fences, manifest and version with the verified fences and the
+// manifest in one transaction, removing fences no longer published and
+// quarantined ones. It records the changes in result and returns the IDs
+// of the fences quarantined.
+func (s *Syncer) installFences(ctx context.Context, fences []geofence.FenceItem, manifest *geofence.Manifest, result *SyncResult) ([]string, error) {
+	quarantined, err := s.verifyFences(fences, manifest.FormatVersion)
 	if err != nil {
 		return nil, err
 	}
 
-	fences := make([]geofence.FenceItem, len(fencePtrs))
-	for i, f := range fencePtrs {
-		fences[i] = *f
+	stats, err := s.store.ApplyUpdate(ctx, &storage.Update{
+		Fences:   fences,
+		Withheld: quarantined,
+		Manifest: manifest,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update storage: %w", err)
 	}
-	return fences, nil
+	result.FencesAdded = stats.Added
+	result.FencesUpdated = stats.Updated
+	result.FencesRemoved = stats.Removed
+
+	return quarantined, nil
 }
 
-// updateStorage updates the storage with new fences and manifest.
-// Note: Each storage operation (UpdateFence, AddFence, etc.) manages its own
-// transaction internally. A full transactional batch update would require
-// extending the Tx type to expose all operations.
-func (s *Syncer) updateStorage(ctx context.Context, fences []geofence.FenceItem, manifest *geofence.Manifest) error {
-	// Update or add each fence
-	for _, f := range fences {
-		// Try to update first
-		err := s.store.UpdateFence(ctx, &f)
-		if err != nil {
-			// Check if it's specifically a "not found" error
-			if err == storage.ErrFenceNotFound {
-				// Fence doesn't exist, add it
-				if err := s.store.AddFence(ctx, &f); err != nil {
-					return fmt.Errorf("failed to add fence %s: %w", f.ID, err)
-				}
-			} else {
-				// Other error (database issue, constraint violation, etc.)
-				return fmt.Errorf("failed to update fence %s: %w", f.ID, err)
+// verifyFences checks every fence's signature, computed in the given format
+// version, against the trusted key its key ID names, which must be valid
+// for signing now. A fence that fails is an error, unless
+// QuarantineInvalidFences is set: then its ID is returned.
+func (s *Syncer) verifyFences(fences []geofence.FenceItem, formatVersion uint32) ([]string, error) {
+	if s.keyring == nil {
+		log.Printf("[SECURITY WARNING] Skipping signature verification for %d fences", len(fences))
+		return nil, nil
+	}
+
+	var quarantined []string
+	for i := range fences {
+		f := &fences[i]
+		signed := converter.SignableFence{Fence: f, FormatVersion: formatVersion}
+		if err := s.keyring.VerifySigned(signed, f.Signature, f.KeyID, crypto.RoleSigning, time.Now()); err != nil {
+			if !s.cfg.QuarantineInvalidFences {
+				return nil, fmt.Errorf("fence %s failed verification: %w", f.ID, err)
 			}
+			log.Printf("[Sync] Quarantining fence %s: %v", f.ID, err)
+			quarantined = append(quarantined, f.ID)
 		}
 	}
+	return quarantined, nil
+}
 
-	// Store manifest
-	if err := s.store.SetManifest(ctx, manifest); err != nil {
-		return fmt.Errorf("failed to store manifest: %w", err)
+// getCurrentFences retrieves all current fences from storage.
+func (s *Syncer) getCurrentFences(ctx context.Context) ([]geofence.FenceItem, error) {
+	fencePtrs, err := s.store.ListFences(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	// Update version
-	if err := s.store.SetVersion(ctx, manifest.Version); err != nil {
-		return fmt.Errorf("failed to set version: %w", err)
+	fences := make([]geofence.FenceItem, len(fencePtrs))
+	for i, f := range fencePtrs {
+		fences[i] = *f
 	}
-
-	return nil
+	return fences, nil
 }
 
 // StartAutoSync starts automatic synchronization in the background.
@@ -325,31 +698,137 @@ func (s *Syncer) GetFences(ctx context.Context) ([]geofence.FenceItem, error) {
 	return s.getCurrentFences(ctx)
 }
 
-// Check checks if a location is allowed for flight.
-func (s *Syncer) Check(ctx context.Context, lat, lon float64) (bool, *geofence.FenceItem, error) {
-	results, err := s.store.QueryAtPoint(ctx, lat, lon)
+// Check evaluates every fence that applies at a location and to the
+// aircraft set with SetAircraft, and returns their combined effect: whether
+// flight is allowed, the effective ceiling, floor and speed limit, and the
+// contributing fences. Conflicting fences are resolved as documented on
+// geofence.EvaluateFences.
+func (s *Syncer) Check(ctx context.Context, lat, lon float64) (*geofence.Evaluation, error) {
+	return s.CheckAt(ctx, lat, lon, s.now())
+}
+
+// CheckAt is like Check but evaluates fences as of time t.
+func (s *Syncer) CheckAt(ctx context.Context, lat, lon float64, t time.Time) (*geofence.Evaluation, error) {
+	return s.evaluate(ctx, lat, lon, s.aircraft.Load(), t)
+}
+
+// CheckFor is like Check but only considers the fences that apply to the
+// given aircraft instead of the one set with SetAircraft.
+func (s *Syncer) CheckFor(ctx context.Context, lat, lon float64, aircraft *geofence.Aircraft) (*geofence.Evaluation, error) {
+	return s.evaluate(ctx, lat, lon, aircraft, s.now())
+}
+
+func (s *Syncer) evaluate(ctx context.Context, lat, lon float64, aircraft *geofence.Aircraft, t time.Time) (*geofence.Evaluation, error) {
+	results, err := s.store.QueryAtPointAt(ctx, lat, lon, t)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	fences := applicable(results, aircraft)
+	ev := geofence.EvaluateFencesWithTokens(fences, geofence.Point{Latitude: lat, Longitude: lon}, t, s.unlockTokens(), serialOf(aircraft))
+	return &ev, nil
+}
+
+// Check3D checks if a location at the given altitude is allowed for flight.
+// Fences are evaluated as volumes using geofence.CheckFences3D, so flying
+// above a no-fly band's ceiling is allowed while exceeding an altitude limit
+// is not. The returned fence is the highest-priority violated restriction.
+func (s *Syncer) Check3D(ctx context.Context, lat, lon float64, alt geofence.Altitude) (bool, *geofence.FenceItem, error) {
+	return s.Check3DAt(ctx, lat, lon, alt, s.now())
+}
+
+// Check3DAt checks if a location at the given altitude is allowed for
+// flight at time t.
+func (s *Syncer) Check3DAt(ctx context.Context, lat, lon float64, alt geofence.Altitude, t time.Time) (bool, *geofence.FenceItem, error) {
+	results, err := s.store.QueryAtPointAt(ctx, lat, lon, t)
 	if err != nil {
 		return false, nil, fmt.Errorf("query failed: %w", err)
 	}
 
-	if len(results) == 0 {
-		return true, nil, nil
+	aircraft := s.aircraft.Load()
+	p := geofence.Point{Latitude: lat, Longitude: lon}
+	fences := withoutUnlocked(applicable(results, aircraft), s.unlockTokens(), func(tok *geofence.UnlockToken, f *geofence.FenceItem) bool {
+		return tok.Unlocks(f, p, serialOf(aircraft), t)
+	})
+
+	result := geofence.CheckFences3DAt(fences, p, alt, t)
+	return result.Allowed, result.Restriction, nil
+}
+
+// CheckRoute checks a planned route of 4D waypoints and returns every
+// stretch of a leg that violates a fence. Candidate fences are looked up
+// by the route's bounding box, then evaluated with geofence.CheckRouteAt at
+// the planned time of each position. An empty result means the route is
+// clear.
+func (s *Syncer) CheckRoute(ctx context.Context, route []geofence.Waypoint) ([]geofence.RouteViolation, error) {
+	if len(route) == 0 {
+		return nil, nil
+	}
+
+	path := make([]geofence.Point, len(route))
+	for i, wp := range route {
+		path[i] = wp.Point
+	}
+	bounds := (&geofence.Corridor{Path: path}).Bounds()
+
+	results, err := s.store.QueryInBounds(ctx, &bounds)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	aircraft := s.aircraft.Load()
+	now := s.now()
+	fences := withoutUnlocked(applicable(results, aircraft), s.unlockTokens(), func(tok *geofence.UnlockToken, f *geofence.FenceItem) bool {
+		return tok.UnlocksRoute(f, route, serialOf(aircraft), now)
+	})
+
+	return geofence.CheckRouteAt(fences, route, now), nil
+}
+
+// NearbyRestrictions returns the active no-fly fences within radiusMeters of
+// a location, nearest first, for proximity warnings such as "150 m from a
+// no-fly zone". A fence containing the location has distance 0.
+func (s *Syncer) NearbyRestrictions(ctx context.Context, lat, lon, radiusMeters float64) ([]geofence.FenceProximity, error) {
+	results, err := s.store.QueryNearestRestrictiveAt(ctx, lat, lon, radiusMeters, s.now())
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
 	}
 
-	// Return the highest priority restriction
-	highestPriority := results[0]
-	for i := 1; i < len(results); i++ {
-		if results[i].Priority > highestPriority.Priority {
-			highestPriority = results[i]
+	aircraft := s.aircraft.Load()
+	nearby := results[:0]
+	for _, r := range results {
+		if r.Fence.AppliesTo(aircraft) {
+			nearby = append(nearby, r)
 		}
 	}
+	return nearby, nil
+}
 
-	switch highestPriority.Type {
-	case geofence.FenceTypePermanentNoFly, geofence.FenceTypeTempRestriction:
-		return false, highestPriority, nil
-	default:
-		return true, highestPriority, nil
+// PredictEntry projects the vehicle's velocity up to horizon ahead and
+// returns the first fence violation on the projected track, or nil if it
+// stays clear.
+func (s *Syncer) PredictEntry(ctx context.Context, state geofence.VehicleState, horizon time.Duration) (*geofence.RouteViolation, error) {
+	if state.Time.IsZero() {
+		state.Time = s.now()
+	}
+	end := geofence.ProjectState(state, horizon)
+	bounds := (&geofence.Corridor{Path: []geofence.Point{state.Position, end.Position}}).Bounds()
+
+	results, err := s.store.QueryInBounds(ctx, &bounds)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	aircraft := s.aircraft.Load()
+	track := []geofence.Waypoint{
+		{Point: state.Position, Altitude: state.Altitude, Time: state.Time},
+		{Point: end.Position, Altitude: end.Altitude, Time: end.Time},
 	}
+	fences := withoutUnlocked(applicable(results, aircraft), s.unlockTokens(), func(tok *geofence.UnlockToken, f *geofence.FenceItem) bool {
+		return tok.UnlocksRoute(f, track, serialOf(aircraft), state.Time)
+	})
+
+	return geofence.PredictEntry(fences, state, horizon), nil
 }
 
 // GetCurrentVersion returns the current version number.
@@ -371,6 +850,15 @@ func (s *Syncer) GetLastSyncTime() time.Time {
 	return s.lastSyncTime
 }
 
+// QuarantinedFences returns the IDs of the fences left out of the last
+// sync because their signature did not verify. It is only ever non-empty
+// with QuarantineInvalidFences set.
+func (s *Syncer) QuarantinedFences() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]string(nil), s.quarantined...)
+}
+
 // Close closes the syncer and releases resources.
 func (s *Syncer) Close() error {
 	return s.store.Close()
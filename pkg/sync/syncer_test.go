@@ -1,15 +1,23 @@
 package sync
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"path/filepath"
+	"reflect"
+	"slices"
+	gosync "sync"
 	"testing"
 	"time"
 
+	"github.com/iannil/geofence-updater-lite/pkg/binarydiff"
 	"github.com/iannil/geofence-updater-lite/pkg/config"
+	"github.com/iannil/geofence-updater-lite/pkg/converter"
 	"github.com/iannil/geofence-updater-lite/pkg/crypto"
 	"github.com/iannil/geofence-updater-lite/pkg/geofence"
 	"github.com/iannil/geofence-updater-lite/pkg/merkle"
@@ -168,11 +176,12 @@ func TestSync_Snapshot(t *testing.T) {
 	rootHash := tree.RootHash()
 
 	manifest := &geofence.Manifest{
-		Version:      1,
-		Timestamp:    time.Now().Unix(),
-		SnapshotURL:  "/snapshot.bin",
-		RootHash:     rootHash[:],
-		SnapshotHash: crypto.ComputeSHA256(snapshotData),
+		Version:       1,
+		Timestamp:     time.Now().Unix(),
+		SnapshotURL:   "/snapshot.bin",
+		RootHash:      rootHash[:],
+		SnapshotHash:  crypto.ComputeSHA256(snapshotData),
+		FormatVersion: geofence.CurrentFormatVersion,
 	}
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -228,6 +237,133 @@ func TestSync_ManifestError(t *testing.T) {
 	}
 }
 
+func TestSync_FenceSignatures(t *testing.T) {
+	kp, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair failed: %v", err)
+	}
+	other, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair failed: %v", err)
+	}
+
+	sign := func(f *geofence.FenceItem, key *crypto.KeyPair) {
+		data, err := converter.FenceSigningData(f, geofence.CurrentFormatVersion)
+		if err != nil {
+			t.Fatalf("FenceSigningData failed: %v", err)
+		}
+		sig, err := key.Sign(data)
+		if err != nil {
+			t.Fatalf("Sign failed: %v", err)
+		}
+		f.SetSignature(sig, key.KeyID)
+	}
+	square := geofence.Geometry{BBox: &geofence.BoundingBox{MinLat: 30, MinLon: 110, MaxLat: 31, MaxLon: 111}}
+
+	valid := geofence.FenceItem{ID: "valid", Type: geofence.FenceTypePermanentNoFly, Geometry: square}
+	sign(&valid, kp)
+	tampered := geofence.FenceItem{ID: "tampered", Type: geofence.FenceTypeAltitudeLimit, Geometry: square, MaxAltitude: 120}
+	sign(&tampered, kp)
+	tampered.MaxAltitude = 500
+	untrusted := geofence.FenceItem{ID: "untrusted", Type: geofence.FenceTypeSpeedLimit, Geometry: square, MaxSpeed: 10}
+	sign(&untrusted, other)
+	unsigned := geofence.FenceItem{ID: "unsigned", Type: geofence.FenceTypeTempRestriction, Geometry: square}
+
+	fences := []geofence.FenceItem{valid, tampered, untrusted, unsigned}
+	snapshotData, _, err := merkle.CreateSnapshot(fences)
+	if err != nil {
+		t.Fatalf("CreateSnapshot failed: %v", err)
+	}
+	tree, err := merkle.NewTree(fences)
+	if err != nil {
+		t.Fatalf("NewTree failed: %v", err)
+	}
+	rootHash := tree.RootHash()
+
+	manifest := &geofence.Manifest{
+		Version:       1,
+		Timestamp:     time.Now().Unix(),
+		SnapshotURL:   "/snapshot.bin",
+		RootHash:      rootHash[:],
+		SnapshotHash:  crypto.ComputeSHA256(snapshotData),
+		FormatVersion: geofence.CurrentFormatVersion,
+	}
+	manifestData, err := converter.ManifestSigningData(manifest)
+	if err != nil {
+		t.Fatalf("ManifestSigningData failed: %v", err)
+	}
+	sig, err := kp.Sign(manifestData)
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	manifest.SetSignature(sig, kp.KeyID)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/manifest.json" {
+			json.NewEncoder(w).Encode(manifest)
+		} else if r.URL.Path == "/snapshot.bin" {
+			w.Write(snapshotData)
+		}
+	}))
+	defer server.Close()
+
+	newSyncer := func(quarantine bool) *Syncer {
+		cfg := testSyncerConfig(t, server.URL)
+		cfg.InsecureSkipVerify = false
+		cfg.PublicKeyHex = crypto.MarshalPublicKeyHex(kp.PublicKey)
+		cfg.QuarantineInvalidFences = quarantine
+
+		syncer, err := NewSyncer(context.Background(), cfg)
+		if err != nil {
+			t.Fatalf("NewSyncer failed: %v", err)
+		}
+		t.Cleanup(func() { syncer.Close() })
+		return syncer
+	}
+	ctx := context.Background()
+
+	t.Run("reject", func(t *testing.T) {
+		syncer := newSyncer(false)
+		result := syncer.Sync(ctx)
+		if result.Error == nil {
+			t.Fatal("expected Sync to reject fences with invalid signatures")
+		}
+		if syncer.GetCurrentVersion() != 0 {
+			t.Errorf("GetCurrentVersion() = %d, want 0", syncer.GetCurrentVersion())
+		}
+		stored, err := syncer.GetFences(ctx)
+		if err != nil {
+			t.Fatalf("GetFences failed: %v", err)
+		}
+		if len(stored) != 0 {
+			t.Errorf("stored %d fences, want none", len(stored))
+		}
+	})
+
+	t.Run("quarantine", func(t *testing.T) {
+		syncer := newSyncer(true)
+		result := syncer.Sync(ctx)
+		if result.Error != nil {
+			t.Fatalf("Sync failed: %v", result.Error)
+		}
+		want := []string{"tampered", "untrusted", "unsigned"}
+		if !reflect.DeepEqual(result.FencesQuarantined, want) {
+			t.Errorf("FencesQuarantined = %v, want %v", result.FencesQuarantined, want)
+		}
+		if got := syncer.QuarantinedFences(); !reflect.DeepEqual(got, want) {
+			t.Errorf("QuarantinedFences() = %v, want %v", got, want)
+		}
+
+		stored, err := syncer.GetFences(ctx)
+		if err != nil {
+			t.Fatalf("GetFences failed: %v", err)
+		}
+		if len(stored) != 1 || stored[0].ID != "valid" {
+			t.Errorf("stored fences = %+v, want only the valid fence", stored)
+		}
+	})
+}
+
 func TestGetCurrentVersion(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(&geofence.Manifest{Version: 1})
@@ -413,30 +549,264 @@ func TestCheck(t *testing.T) {
 	defer syncer.Close()
 
 	// Check inside no-fly zone
-	allowed, restriction, err := syncer.Check(ctx, 39.5, 116.5)
+	ev, err := syncer.Check(ctx, 39.5, 116.5)
 	if err != nil {
 		t.Fatalf("Check failed: %v", err)
 	}
-	if allowed {
+	if ev.Allowed {
 		t.Error("expected not allowed inside no-fly zone")
 	}
-	if restriction == nil {
+	if ev.Restriction == nil {
 		t.Error("expected restriction to be returned")
 	}
 
 	// Check outside fence
-	allowed, restriction, err = syncer.Check(ctx, 0, 0)
+	ev, err = syncer.Check(ctx, 0, 0)
 	if err != nil {
 		t.Fatalf("Check failed: %v", err)
 	}
-	if !allowed {
+	if !ev.Allowed {
 		t.Error("expected allowed outside fence")
 	}
-	if restriction != nil {
+	if ev.Restriction != nil {
 		t.Error("expected no restriction outside fence")
 	}
 }
 
+func TestCheck3D(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&geofence.Manifest{Version: 0})
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	cfg := testSyncerConfig(t, server.URL)
+
+	syncer, err := NewSyncer(ctx, cfg)
+	if err != nil {
+		t.Fatalf("NewSyncer failed: %v", err)
+	}
+	defer syncer.Close()
+
+	square := geofence.Geometry{
+		Polygon: []geofence.Point{
+			{Latitude: 39.0, Longitude: 116.0},
+			{Latitude: 39.0, Longitude: 117.0},
+			{Latitude: 40.0, Longitude: 117.0},
+			{Latitude: 40.0, Longitude: 116.0},
+		},
+	}
+	fences := []*geofence.FenceItem{
+		{
+			ID:       "tfr-band",
+			Type:     geofence.FenceTypeTempRestriction,
+			Priority: 80,
+			Geometry: square,
+			Altitude: &geofence.AltitudeBand{Floor: 0, Ceiling: 122},
+		},
+		{
+			ID:          "ceiling",
+			Type:        geofence.FenceTypeAltitudeLimit,
+			Priority:    50,
+			MaxAltitude: 300,
+			Geometry:    square,
+		},
+	}
+	for _, f := range fences {
+		if err := syncer.store.AddFence(ctx, f); err != nil {
+			t.Fatalf("AddFence failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		alt     geofence.Altitude
+		allowed bool
+		fenceID string
+	}{
+		{"inside band", geofence.Altitude{Meters: 50}, false, "tfr-band"},
+		{"above band ceiling", geofence.Altitude{Meters: 200}, true, ""},
+		{"above altitude limit", geofence.Altitude{Meters: 350}, false, "ceiling"},
+		{"different reference", geofence.Altitude{Meters: 200, Reference: geofence.AltitudeReferenceMSL}, false, "tfr-band"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, restriction, err := syncer.Check3D(ctx, 39.5, 116.5, tt.alt)
+			if err != nil {
+				t.Fatalf("Check3D failed: %v", err)
+			}
+			if allowed != tt.allowed {
+				t.Errorf("allowed = %v, want %v", allowed, tt.allowed)
+			}
+			gotID := ""
+			if restriction != nil {
+				gotID = restriction.ID
+			}
+			if gotID != tt.fenceID {
+				t.Errorf("restriction = %q, want %q", gotID, tt.fenceID)
+			}
+		})
+	}
+
+	// Without an altitude, Check reports the band and leaves room above it
+	ev, err := syncer.Check(ctx, 39.5, 116.5)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if !ev.Allowed || ev.Restriction != nil {
+		t.Errorf("Allowed = %v, Restriction = %v, want flight above the band allowed", ev.Allowed, ev.Restriction)
+	}
+	for _, tt := range tests {
+		if got := ev.AllowsAltitude(tt.alt); got != tt.allowed {
+			t.Errorf("AllowsAltitude(%v) = %v, want %v as Check3D", tt.alt, got, tt.allowed)
+		}
+	}
+}
+
+func TestCheckRoute(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&geofence.Manifest{Version: 0})
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	cfg := testSyncerConfig(t, server.URL)
+
+	syncer, err := NewSyncer(ctx, cfg)
+	if err != nil {
+		t.Fatalf("NewSyncer failed: %v", err)
+	}
+	defer syncer.Close()
+
+	fences := []*geofence.FenceItem{
+		{
+			ID:       "airport",
+			Type:     geofence.FenceTypePermanentNoFly,
+			Priority: 100,
+			Geometry: geofence.Geometry{
+				Polygon: []geofence.Point{
+					{Latitude: 39.4, Longitude: 116.4},
+					{Latitude: 39.4, Longitude: 116.6},
+					{Latitude: 39.6, Longitude: 116.6},
+					{Latitude: 39.6, Longitude: 116.4},
+				},
+			},
+		},
+		{
+			// Far from the route, must not be reported
+			ID:       "elsewhere",
+			Type:     geofence.FenceTypePermanentNoFly,
+			Priority: 100,
+			Geometry: geofence.Geometry{
+				Polygon: []geofence.Point{
+					{Latitude: 10, Longitude: 10},
+					{Latitude: 10, Longitude: 11},
+					{Latitude: 11, Longitude: 11},
+				},
+			},
+		},
+	}
+	for _, f := range fences {
+		if err := syncer.store.AddFence(ctx, f); err != nil {
+			t.Fatalf("AddFence failed: %v", err)
+		}
+	}
+
+	start := time.Now()
+	route := []geofence.Waypoint{
+		{Point: geofence.Point{Latitude: 39.7, Longitude: 116.2}, Time: start},
+		{Point: geofence.Point{Latitude: 39.5, Longitude: 116.2}, Time: start.Add(5 * time.Minute)},
+		{Point: geofence.Point{Latitude: 39.5, Longitude: 116.8}, Time: start.Add(30 * time.Minute)},
+	}
+
+	violations, err := syncer.CheckRoute(ctx, route)
+	if err != nil {
+		t.Fatalf("CheckRoute failed: %v", err)
+	}
+	if len(violations) != 1 {
+		t.Fatalf("got %d violations, want 1", len(violations))
+	}
+	if v := violations[0]; v.Leg != 1 || v.Fence.ID != "airport" {
+		t.Errorf("violation = leg %d fence %s, want leg 1 fence airport", v.Leg, v.Fence.ID)
+	}
+
+	clear := []geofence.Waypoint{
+		{Point: geofence.Point{Latitude: 39.7, Longitude: 116.2}, Time: start},
+		{Point: geofence.Point{Latitude: 39.7, Longitude: 116.8}, Time: start.Add(30 * time.Minute)},
+	}
+	violations, err = syncer.CheckRoute(ctx, clear)
+	if err != nil {
+		t.Fatalf("CheckRoute failed: %v", err)
+	}
+	if len(violations) != 0 {
+		t.Errorf("got %d violations for clear route, want 0", len(violations))
+	}
+}
+
+func TestProximityWarnings(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&geofence.Manifest{Version: 0})
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	cfg := testSyncerConfig(t, server.URL)
+
+	syncer, err := NewSyncer(ctx, cfg)
+	if err != nil {
+		t.Fatalf("NewSyncer failed: %v", err)
+	}
+	defer syncer.Close()
+
+	// No-fly square starting about 1.1 km east of the vehicle
+	fence := &geofence.FenceItem{
+		ID:       "no-fly",
+		Type:     geofence.FenceTypePermanentNoFly,
+		Priority: 100,
+		Geometry: geofence.Geometry{
+			Polygon: []geofence.Point{
+				{Latitude: -0.01, Longitude: 0.01},
+				{Latitude: -0.01, Longitude: 0.02},
+				{Latitude: 0.01, Longitude: 0.02},
+				{Latitude: 0.01, Longitude: 0.01},
+			},
+		},
+	}
+	if err := syncer.store.AddFence(ctx, fence); err != nil {
+		t.Fatalf("AddFence failed: %v", err)
+	}
+
+	nearby, err := syncer.NearbyRestrictions(ctx, 0, 0, 2000)
+	if err != nil {
+		t.Fatalf("NearbyRestrictions failed: %v", err)
+	}
+	if len(nearby) != 1 || nearby[0].Fence.ID != "no-fly" {
+		t.Fatalf("NearbyRestrictions = %+v, want no-fly", nearby)
+	}
+	if d := nearby[0].Distance; d < 1100 || d > 1120 {
+		t.Errorf("Distance = %f, want about 1112 m", d)
+	}
+
+	// 50 m/s east reaches the boundary in about 22 s
+	state := geofence.VehicleState{Velocity: geofence.Velocity{East: 50}}
+	v, err := syncer.PredictEntry(ctx, state, 30*time.Second)
+	if err != nil {
+		t.Fatalf("PredictEntry failed: %v", err)
+	}
+	if v == nil || v.Fence.ID != "no-fly" {
+		t.Fatalf("PredictEntry = %+v, want entry into no-fly", v)
+	}
+
+	v, err = syncer.PredictEntry(ctx, state, 10*time.Second)
+	if err != nil {
+		t.Fatalf("PredictEntry failed: %v", err)
+	}
+	if v != nil {
+		t.Errorf("PredictEntry = %+v, want nil within 10s", v)
+	}
+}
+
 func TestCheck_TempRestriction(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(&geofence.Manifest{Version: 0})
@@ -487,21 +857,388 @@ func TestCheck_TempRestriction(t *testing.T) {
 	defer syncer.Close()
 
 	// Check inside temp restriction
-	allowed, restriction, err := syncer.Check(ctx, 30.5, 110.5)
+	ev, err := syncer.Check(ctx, 30.5, 110.5)
 	if err != nil {
 		t.Fatalf("Check failed: %v", err)
 	}
-	if allowed {
+	if ev.Allowed {
 		t.Error("expected not allowed inside temp restriction")
 	}
-	if restriction == nil {
+	if ev.Restriction == nil {
 		t.Error("expected restriction to be returned")
 	}
-	if restriction.Type != geofence.FenceTypeTempRestriction {
-		t.Errorf("Type = %d, want %d", restriction.Type, geofence.FenceTypeTempRestriction)
+	if ev.Restriction.Type != geofence.FenceTypeTempRestriction {
+		t.Errorf("Type = %d, want %d", ev.Restriction.Type, geofence.FenceTypeTempRestriction)
 	}
 }
 
+func TestCheck_CombinedConstraints(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&geofence.Manifest{Version: 0})
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	syncer, err := NewSyncer(ctx, testSyncerConfig(t, server.URL))
+	if err != nil {
+		t.Fatalf("NewSyncer failed: %v", err)
+	}
+	defer syncer.Close()
+
+	square := geofence.Geometry{
+		Polygon: []geofence.Point{
+			{Latitude: 30.0, Longitude: 110.0},
+			{Latitude: 30.0, Longitude: 111.0},
+			{Latitude: 31.0, Longitude: 111.0},
+			{Latitude: 31.0, Longitude: 110.0},
+		},
+	}
+	fences := []*geofence.FenceItem{
+		{ID: "no-fly", Type: geofence.FenceTypePermanentNoFly, Priority: 10, Geometry: square},
+		{ID: "ceiling", Type: geofence.FenceTypeAltitudeLimit, Priority: 90, MaxAltitude: 120, Geometry: square},
+		{ID: "speed", Type: geofence.FenceTypeSpeedLimit, Priority: 50, MaxSpeed: 15, Geometry: square},
+	}
+	for _, f := range fences {
+		if err := syncer.store.AddFence(ctx, f); err != nil {
+			t.Fatalf("AddFence failed: %v", err)
+		}
+	}
+
+	ev, err := syncer.Check(ctx, 30.5, 110.5)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if ev.Allowed {
+		t.Error("expected not allowed: the higher priority ceiling must not mask the no-fly zone")
+	}
+	if ev.Restriction == nil || ev.Restriction.ID != "no-fly" {
+		t.Errorf("restriction = %v, want no-fly", ev.Restriction)
+	}
+	if ceiling, ok := ev.Ceiling(geofence.AltitudeReferenceAGL); !ok || ceiling != 120 {
+		t.Errorf("ceiling = %v, %v, want 120", ceiling, ok)
+	}
+	if ev.SpeedLimit != 15 {
+		t.Errorf("SpeedLimit = %d, want 15", ev.SpeedLimit)
+	}
+	if len(ev.Constraints) != 3 {
+		t.Errorf("constraints = %d, want 3", len(ev.Constraints))
+	}
+}
+
+func TestCheckAt(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&geofence.Manifest{Version: 0})
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	syncer, err := NewSyncer(ctx, testSyncerConfig(t, server.URL))
+	if err != nil {
+		t.Fatalf("NewSyncer failed: %v", err)
+	}
+	defer syncer.Close()
+
+	start := time.Date(2026, 7, 1, 8, 0, 0, 0, time.UTC)
+	fence := &geofence.FenceItem{
+		ID:       "event-window",
+		Type:     geofence.FenceTypeTempRestriction,
+		StartTS:  start.Unix(),
+		EndTS:    start.Add(4 * time.Hour).Unix(),
+		Priority: 50,
+		Geometry: geofence.Geometry{
+			Polygon: []geofence.Point{
+				{Latitude: 30.0, Longitude: 110.0},
+				{Latitude: 30.0, Longitude: 111.0},
+				{Latitude: 31.0, Longitude: 111.0},
+				{Latitude: 31.0, Longitude: 110.0},
+			},
+		},
+	}
+	if err := syncer.store.AddFence(ctx, fence); err != nil {
+		t.Fatalf("AddFence failed: %v", err)
+	}
+
+	t.Run("explicit time", func(t *testing.T) {
+		ev, err := syncer.CheckAt(ctx, 30.5, 110.5, start.Add(time.Hour))
+		if err != nil {
+			t.Fatalf("CheckAt failed: %v", err)
+		}
+		if ev.Allowed {
+			t.Error("expected not allowed during the restriction window")
+		}
+
+		ev, err = syncer.CheckAt(ctx, 30.5, 110.5, start.Add(-time.Hour))
+		if err != nil {
+			t.Fatalf("CheckAt failed: %v", err)
+		}
+		if !ev.Allowed {
+			t.Error("expected allowed before the restriction window")
+		}
+	})
+
+	t.Run("injected clock", func(t *testing.T) {
+		syncer.SetClock(geofence.FixedClock(start.Add(time.Hour)))
+		defer syncer.SetClock(geofence.SystemClock{})
+
+		ev, err := syncer.Check(ctx, 30.5, 110.5)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		if ev.Allowed {
+			t.Error("expected not allowed at the injected time")
+		}
+		if ev.Restriction == nil || ev.Restriction.ID != "event-window" {
+			t.Errorf("restriction = %v, want event-window", ev.Restriction)
+		}
+
+		route := []geofence.Waypoint{
+			{Point: geofence.Point{Latitude: 30.5, Longitude: 109.5}},
+			{Point: geofence.Point{Latitude: 30.5, Longitude: 111.5}},
+		}
+		violations, err := syncer.CheckRoute(ctx, route)
+		if err != nil {
+			t.Fatalf("CheckRoute failed: %v", err)
+		}
+		if len(violations) != 1 {
+			t.Errorf("CheckRoute returned %d violations, want 1", len(violations))
+		}
+	})
+
+	t.Run("system clock", func(t *testing.T) {
+		ev, err := syncer.Check(ctx, 30.5, 110.5)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		if !ev.Allowed {
+			t.Error("expected allowed now, after the restriction window")
+		}
+	})
+}
+
+func TestCheck_AircraftConditions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&geofence.Manifest{Version: 0})
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	syncer, err := NewSyncer(ctx, testSyncerConfig(t, server.URL))
+	if err != nil {
+		t.Fatalf("NewSyncer failed: %v", err)
+	}
+	defer syncer.Close()
+
+	fence := &geofence.FenceItem{
+		ID:       "no-recreational",
+		Type:     geofence.FenceTypeTempRestriction,
+		Priority: 50,
+		Geometry: geofence.Geometry{
+			CircleCenter: &geofence.Point{Latitude: 30.5, Longitude: 110.5},
+			CircleRadius: 1000,
+		},
+		Conditions: &geofence.Conditions{
+			Operations:       []geofence.OperationType{geofence.OperationTypeRecreational, geofence.OperationTypeCommercial},
+			ExemptOperations: []geofence.OperationType{geofence.OperationTypeEmergency},
+		},
+	}
+	if err := syncer.store.AddFence(ctx, fence); err != nil {
+		t.Fatalf("AddFence failed: %v", err)
+	}
+
+	recreational := &geofence.Aircraft{Operation: geofence.OperationTypeRecreational}
+	emergency := &geofence.Aircraft{Operation: geofence.OperationTypeEmergency}
+
+	t.Run("no profile", func(t *testing.T) {
+		ev, err := syncer.Check(ctx, 30.5, 110.5)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		if ev.Allowed {
+			t.Error("expected every fence to apply without an aircraft profile")
+		}
+	})
+
+	t.Run("per check", func(t *testing.T) {
+		ev, err := syncer.CheckFor(ctx, 30.5, 110.5, emergency)
+		if err != nil {
+			t.Fatalf("CheckFor failed: %v", err)
+		}
+		if !ev.Allowed {
+			t.Error("expected emergency flight to be exempt")
+		}
+
+		ev, err = syncer.CheckFor(ctx, 30.5, 110.5, recreational)
+		if err != nil {
+			t.Fatalf("CheckFor failed: %v", err)
+		}
+		if ev.Allowed {
+			t.Error("expected recreational flight to be restricted")
+		}
+	})
+
+	t.Run("syncer profile", func(t *testing.T) {
+		syncer.SetAircraft(emergency)
+		defer syncer.SetAircraft(nil)
+
+		ev, err := syncer.Check(ctx, 30.5, 110.5)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		if !ev.Allowed {
+			t.Error("expected emergency flight to be exempt")
+		}
+
+		nearby, err := syncer.NearbyRestrictions(ctx, 30.5, 110.5, 5000)
+		if err != nil {
+			t.Fatalf("NearbyRestrictions failed: %v", err)
+		}
+		if len(nearby) != 0 {
+			t.Errorf("NearbyRestrictions returned %d fences, want 0", len(nearby))
+		}
+
+		route := []geofence.Waypoint{
+			{Point: geofence.Point{Latitude: 30.5, Longitude: 110.4}},
+			{Point: geofence.Point{Latitude: 30.5, Longitude: 110.6}},
+		}
+		violations, err := syncer.CheckRoute(ctx, route)
+		if err != nil {
+			t.Fatalf("CheckRoute failed: %v", err)
+		}
+		if len(violations) != 0 {
+			t.Errorf("CheckRoute returned %d violations, want 0", len(violations))
+		}
+	})
+}
+
+func TestCheck_UnlockTokens(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&geofence.Manifest{Version: 0})
+	}))
+	defer server.Close()
+
+	kp, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair failed: %v", err)
+	}
+	cfg := testSyncerConfig(t, server.URL)
+	cfg.PublicKeyHex = crypto.MarshalPublicKeyHex(kp.PublicKey)
+
+	ctx := context.Background()
+	syncer, err := NewSyncer(ctx, cfg)
+	if err != nil {
+		t.Fatalf("NewSyncer failed: %v", err)
+	}
+	defer syncer.Close()
+
+	fence := &geofence.FenceItem{
+		ID:       "stadium",
+		Type:     geofence.FenceTypeTempRestriction,
+		Priority: 50,
+		Geometry: geofence.Geometry{
+			CircleCenter: &geofence.Point{Latitude: 30.5, Longitude: 110.5},
+			CircleRadius: 1000,
+		},
+	}
+	if err := syncer.store.AddFence(ctx, fence); err != nil {
+		t.Fatalf("AddFence failed: %v", err)
+	}
+
+	now := time.Now()
+	token := geofence.UnlockToken{
+		ID:        "waiver-1",
+		FenceIDs:  []string{"stadium"},
+		NotBefore: now.Add(-time.Hour).Unix(),
+		NotAfter:  now.Add(time.Hour).Unix(),
+		DeviceID:  "SN-1234",
+	}
+	data, err := token.MarshalBinaryForSigning()
+	if err != nil {
+		t.Fatalf("MarshalBinaryForSigning failed: %v", err)
+	}
+	sig, err := kp.Sign(data)
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	token.SetSignature(sig, kp.KeyID)
+
+	forged := token
+	forged.ID = "waiver-2"
+	if err := syncer.SetUnlockTokens([]geofence.UnlockToken{token, forged}); err == nil {
+		t.Fatal("expected SetUnlockTokens to reject a token with an invalid signature")
+	}
+	if err := syncer.SetUnlockTokens([]geofence.UnlockToken{token}); err != nil {
+		t.Fatalf("SetUnlockTokens failed: %v", err)
+	}
+
+	ev, err := syncer.CheckFor(ctx, 30.5, 110.5, &geofence.Aircraft{Serial: "SN-1234"})
+	if err != nil {
+		t.Fatalf("CheckFor failed: %v", err)
+	}
+	if !ev.Allowed || !ev.Authorized {
+		t.Errorf("Allowed = %v, Authorized = %v, want restricted but authorized", ev.Allowed, ev.Authorized)
+	}
+	if len(ev.Constraints) != 1 || ev.Constraints[0].UnlockedBy != "waiver-1" {
+		t.Errorf("Constraints = %+v, want stadium unlocked by waiver-1", ev.Constraints)
+	}
+
+	ev, err = syncer.CheckFor(ctx, 30.5, 110.5, &geofence.Aircraft{Serial: "SN-9999"})
+	if err != nil {
+		t.Fatalf("CheckFor failed: %v", err)
+	}
+	if ev.Allowed {
+		t.Error("expected a token bound to another device not to unlock the fence")
+	}
+
+	syncer.SetAircraft(&geofence.Aircraft{Serial: "SN-1234"})
+	defer syncer.SetAircraft(nil)
+	ev, err = syncer.Check(ctx, 30.5, 110.5)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if !ev.Allowed {
+		t.Error("expected the syncer's aircraft to be authorized")
+	}
+
+	alt := geofence.Altitude{Meters: 50, Reference: geofence.AltitudeReferenceAGL}
+	route := []geofence.Waypoint{
+		{Point: geofence.Point{Latitude: 30.5, Longitude: 110.48}, Altitude: alt},
+		{Point: geofence.Point{Latitude: 30.5, Longitude: 110.52}, Altitude: alt},
+	}
+	state := geofence.VehicleState{
+		Position: route[0].Point,
+		Altitude: alt,
+		Velocity: geofence.Velocity{East: 50},
+	}
+	checkAll := func(wantClear bool) {
+		t.Helper()
+		allowed, _, err := syncer.Check3D(ctx, 30.5, 110.5, alt)
+		if err != nil {
+			t.Fatalf("Check3D failed: %v", err)
+		}
+		if allowed != wantClear {
+			t.Errorf("Check3D allowed = %v, want %v", allowed, wantClear)
+		}
+		violations, err := syncer.CheckRoute(ctx, route)
+		if err != nil {
+			t.Fatalf("CheckRoute failed: %v", err)
+		}
+		if (len(violations) == 0) != wantClear {
+			t.Errorf("CheckRoute returned %d violations, want clear = %v", len(violations), wantClear)
+		}
+		v, err := syncer.PredictEntry(ctx, state, time.Minute)
+		if err != nil {
+			t.Fatalf("PredictEntry failed: %v", err)
+		}
+		if (v == nil) != wantClear {
+			t.Errorf("PredictEntry = %+v, want clear = %v", v, wantClear)
+		}
+	}
+	checkAll(true)
+
+	syncer.SetAircraft(&geofence.Aircraft{Serial: "SN-9999"})
+	checkAll(false)
+}
+
 func TestStartAutoSync(t *testing.T) {
 	manifest := &geofence.Manifest{
 		Version:   0,
@@ -596,3 +1333,552 @@ func TestSyncResult_Fields(t *testing.T) {
 		t.Errorf("CurrentVer = %d, want 2", result.CurrentVer)
 	}
 }
+
+func TestSync_DeltaChain(t *testing.T) {
+	square := func(id string, lat float64) geofence.FenceItem {
+		return geofence.FenceItem{
+			ID:       id,
+			Type:     geofence.FenceTypePermanentNoFly,
+			Geometry: geofence.Geometry{BBox: &geofence.BoundingBox{MinLat: lat, MinLon: 110, MaxLat: lat + 0.5, MaxLon: 111}},
+		}
+	}
+	versions := map[uint64][]geofence.FenceItem{
+		1: {square("a", 30), square("b", 31)},
+		2: {square("a", 30), square("b", 31), square("c", 32)},
+		3: {square("b", 31.2), square("c", 32)},
+	}
+	rootHash := func(fences []geofence.FenceItem) []byte {
+		tree, err := merkle.NewTree(fences)
+		if err != nil {
+			t.Fatalf("NewTree failed: %v", err)
+		}
+		h := tree.RootHash()
+		return h[:]
+	}
+
+	files := make(map[string][]byte)
+	var patches []geofence.PatchInfo
+	for v := uint64(2); v <= 3; v++ {
+		delta, err := merkle.NewFenceDeltaFile(versions[v-1], versions[v], v-1, v, geofence.CurrentFormatVersion)
+		if err != nil {
+			t.Fatalf("NewFenceDeltaFile failed: %v", err)
+		}
+		data, err := converter.MarshalFenceDeltaFile(delta)
+		if err != nil {
+			t.Fatalf("MarshalFenceDeltaFile failed: %v", err)
+		}
+		url := fmt.Sprintf("/patches/v%d_to_v%d.bin", v-1, v)
+		files[url] = data
+		patches = append(patches, geofence.PatchInfo{
+			FromVersion:   v - 1,
+			ToVersion:     v,
+			URL:           url,
+			Size:          uint64(len(data)),
+			Hash:          crypto.ComputeSHA256(data),
+			RootHash:      rootHash(versions[v]),
+			FormatVersion: geofence.CurrentFormatVersion,
+		})
+	}
+	for v := uint64(1); v <= 3; v++ {
+		snapshotData, _, err := merkle.CreateSnapshot(versions[v])
+		if err != nil {
+			t.Fatalf("CreateSnapshot failed: %v", err)
+		}
+		files[fmt.Sprintf("/v%d.bin", v)] = snapshotData
+	}
+	manifestFor := func(v uint64) *geofence.Manifest {
+		m := &geofence.Manifest{
+			Version:       v,
+			Timestamp:     time.Now().Unix(),
+			SnapshotURL:   fmt.Sprintf("/v%d.bin", v),
+			SnapshotHash:  crypto.ComputeSHA256(files[fmt.Sprintf("/v%d.bin", v)]),
+			RootHash:      rootHash(versions[v]),
+			FormatVersion: geofence.CurrentFormatVersion,
+		}
+		if v > 1 {
+			m.DeltaURL = patches[v-2].URL
+			m.DeltaHash = patches[v-2].Hash
+			m.DeltaSize = patches[v-2].Size
+		}
+		return m
+	}
+
+	var mu gosync.Mutex
+	manifest := manifestFor(1)
+	var index *geofence.PatchIndex
+	var fetched []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		fetched = append(fetched, r.URL.Path)
+		switch {
+		case r.URL.Path == "/manifest.json":
+			json.NewEncoder(w).Encode(manifest)
+		case r.URL.Path == "/"+geofence.PatchIndexFile && index != nil:
+			json.NewEncoder(w).Encode(index)
+		case files[r.URL.Path] != nil:
+			w.Write(files[r.URL.Path])
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	syncer, err := NewSyncer(ctx, testSyncerConfig(t, server.URL))
+	if err != nil {
+		t.Fatalf("NewSyncer failed: %v", err)
+	}
+	defer syncer.Close()
+
+	if result := syncer.Sync(ctx); result.Error != nil {
+		t.Fatalf("Sync to version 1 failed: %v", result.Error)
+	}
+
+	// Two versions behind, the client catches up through both deltas
+	mu.Lock()
+	manifest = manifestFor(3)
+	index = &geofence.PatchIndex{Version: 3, Patches: patches}
+	fetched = nil
+	mu.Unlock()
+
+	result := syncer.Sync(ctx)
+	if result.Error != nil {
+		t.Fatalf("Sync to version 3 failed: %v", result.Error)
+	}
+	if result.DeltaHops != 2 {
+		t.Errorf("DeltaHops = %d, want 2", result.DeltaHops)
+	}
+	if slices.Contains(fetched, "/v3.bin") {
+		t.Error("snapshot fetched although deltas were available")
+	}
+	stored, err := syncer.GetFences(ctx)
+	if err != nil {
+		t.Fatalf("GetFences failed: %v", err)
+	}
+	byID := make(map[string]geofence.FenceItem)
+	for _, f := range stored {
+		byID[f.ID] = f
+	}
+	if _, ok := byID["a"]; ok {
+		t.Error("fence a removed in version 3 is still stored")
+	}
+	if _, ok := byID["c"]; !ok {
+		t.Error("fence c added in version 2 was not stored")
+	}
+	if b, ok := byID["b"]; !ok || b.Geometry.BBox.MinLat != 31.2 {
+		t.Errorf("fence b = %+v, want the version 3 update", b)
+	}
+	if result.FencesAdded != 1 || result.FencesUpdated != 1 || result.FencesRemoved != 1 {
+		t.Errorf("added, updated, removed = %d, %d, %d, want 1, 1, 1",
+			result.FencesAdded, result.FencesUpdated, result.FencesRemoved)
+	}
+}
+
+func TestSync_DeltaChainFallback(t *testing.T) {
+	fences := []geofence.FenceItem{{
+		ID:       "a",
+		Type:     geofence.FenceTypePermanentNoFly,
+		Geometry: geofence.Geometry{BBox: &geofence.BoundingBox{MinLat: 30, MinLon: 110, MaxLat: 31, MaxLon: 111}},
+	}}
+	snapshotData, _, err := merkle.CreateSnapshot(fences)
+	if err != nil {
+		t.Fatalf("CreateSnapshot failed: %v", err)
+	}
+	tree, err := merkle.NewTree(fences)
+	if err != nil {
+		t.Fatalf("NewTree failed: %v", err)
+	}
+	rootHash := tree.RootHash()
+
+	// The index offers a chain from version 0, but its delta is corrupt
+	manifest := &geofence.Manifest{
+		Version:       2,
+		Timestamp:     time.Now().Unix(),
+		SnapshotURL:   "/v2.bin",
+		SnapshotHash:  crypto.ComputeSHA256(snapshotData),
+		RootHash:      rootHash[:],
+		FormatVersion: geofence.CurrentFormatVersion,
+	}
+	index := &geofence.PatchIndex{Version: 2, Patches: []geofence.PatchInfo{
+		{FromVersion: 0, ToVersion: 2, URL: "/patches/v0_to_v2.bin", Size: 1, Hash: crypto.ComputeSHA256([]byte("delta"))},
+	}}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/manifest.json":
+			json.NewEncoder(w).Encode(manifest)
+		case "/" + geofence.PatchIndexFile:
+			json.NewEncoder(w).Encode(index)
+		case "/patches/v0_to_v2.bin":
+			w.Write([]byte("corrupt"))
+		case "/v2.bin":
+			w.Write(snapshotData)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	syncer, err := NewSyncer(ctx, testSyncerConfig(t, server.URL))
+	if err != nil {
+		t.Fatalf("NewSyncer failed: %v", err)
+	}
+	defer syncer.Close()
+
+	result := syncer.Sync(ctx)
+	if result.Error != nil {
+		t.Fatalf("Sync failed: %v", result.Error)
+	}
+	if result.DeltaHops != 0 {
+		t.Errorf("DeltaHops = %d, want 0 after falling back to the snapshot", result.DeltaHops)
+	}
+	if syncer.GetCurrentVersion() != 2 {
+		t.Errorf("GetCurrentVersion() = %d, want 2", syncer.GetCurrentVersion())
+	}
+}
+
+func TestSync_BinaryDelta(t *testing.T) {
+	kp, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair failed: %v", err)
+	}
+	signed := func(f geofence.FenceItem) geofence.FenceItem {
+		data, err := converter.FenceSigningData(&f, geofence.CurrentFormatVersion)
+		if err != nil {
+			t.Fatalf("FenceSigningData failed: %v", err)
+		}
+		sig, err := kp.Sign(data)
+		if err != nil {
+			t.Fatalf("Sign failed: %v", err)
+		}
+		f.SetSignature(sig, kp.KeyID)
+		return f
+	}
+
+	// A detailed polygon of which version 2 moves one vertex: the fence
+	// delta carries the whole fence, the binary diff little more than the
+	// changed bytes
+	var ring []geofence.Point
+	for i := 0; i < 200; i++ {
+		angle := 2 * math.Pi * float64(i) / 200
+		ring = append(ring, geofence.Point{Latitude: 30 + 0.1*math.Sin(angle), Longitude: 110 + 0.1*math.Cos(angle)})
+	}
+	moved := slices.Clone(ring)
+	moved[100].Latitude += 0.01
+	polygon := func(points []geofence.Point) geofence.FenceItem {
+		return signed(geofence.FenceItem{
+			ID:       "a",
+			Type:     geofence.FenceTypePermanentNoFly,
+			Name:     "Detailed polygon",
+			Priority: 10,
+			Geometry: geofence.Geometry{Polygon: points},
+		})
+	}
+	square := signed(geofence.FenceItem{
+		ID:       "b",
+		Type:     geofence.FenceTypePermanentNoFly,
+		Geometry: geofence.Geometry{BBox: &geofence.BoundingBox{MinLat: 31, MinLon: 110, MaxLat: 31.5, MaxLon: 111}},
+	})
+	versions := map[uint64][]geofence.FenceItem{
+		1: {polygon(ring), square},
+		2: {polygon(moved), square},
+	}
+
+	files := make(map[string][]byte)
+	roots := make(map[uint64][]byte)
+	for v := uint64(1); v <= 2; v++ {
+		snapshotData, _, err := merkle.CreateSnapshot(versions[v])
+		if err != nil {
+			t.Fatalf("CreateSnapshot failed: %v", err)
+		}
+		files[fmt.Sprintf("/v%d.bin", v)] = snapshotData
+		tree, err := merkle.NewTree(versions[v])
+		if err != nil {
+			t.Fatalf("NewTree failed: %v", err)
+		}
+		root := tree.RootHash()
+		roots[v] = root[:]
+	}
+	delta, err := merkle.NewFenceDeltaFile(versions[1], versions[2], 1, 2, geofence.CurrentFormatVersion)
+	if err != nil {
+		t.Fatalf("NewFenceDeltaFile failed: %v", err)
+	}
+	deltaData, err := converter.MarshalFenceDeltaFile(delta)
+	if err != nil {
+		t.Fatalf("MarshalFenceDeltaFile failed: %v", err)
+	}
+	files["/patches/v1_to_v2.bin"] = deltaData
+
+	manifestFor := func(v uint64) *geofence.Manifest {
+		m := &geofence.Manifest{
+			Version:       v,
+			Timestamp:     time.Now().Unix(),
+			SnapshotURL:   fmt.Sprintf("/v%d.bin", v),
+			SnapshotHash:  crypto.ComputeSHA256(files[fmt.Sprintf("/v%d.bin", v)]),
+			RootHash:      roots[v],
+			FormatVersion: geofence.CurrentFormatVersion,
+		}
+		if v == 2 {
+			m.DeltaURL = "/patches/v1_to_v2.bin"
+			m.DeltaSize = uint64(len(deltaData))
+			m.DeltaHash = crypto.ComputeSHA256(deltaData)
+		}
+		return m
+	}
+
+	tests := []struct {
+		name     string
+		base     []geofence.FenceItem // Fences the binary diff is computed from
+		wantHops int
+		wantURL  string
+	}{
+		{"applies", versions[1], 1, "/patches/v1_to_v2.bindiff"},
+		{"different base", versions[1][:1], 0, "/v2.bin"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := binarydiff.WriteDeltaFile(tt.base, versions[2], 1, 2, &buf); err != nil {
+				t.Fatalf("WriteDeltaFile failed: %v", err)
+			}
+			binaryData := buf.Bytes()
+			if len(binaryData) >= len(deltaData) {
+				t.Fatalf("binary diff is %d bytes, fence delta %d", len(binaryData), len(deltaData))
+			}
+			index := &geofence.PatchIndex{Version: 2, Patches: []geofence.PatchInfo{{
+				FromVersion:   1,
+				ToVersion:     2,
+				URL:           "/patches/v1_to_v2.bin",
+				Size:          uint64(len(deltaData)),
+				Hash:          crypto.ComputeSHA256(deltaData),
+				RootHash:      roots[2],
+				FormatVersion: geofence.CurrentFormatVersion,
+				Binary: &geofence.BinaryPatch{
+					URL:  "/patches/v1_to_v2.bindiff",
+					Size: uint64(len(binaryData)),
+					Hash: crypto.ComputeSHA256(binaryData),
+				},
+			}}}
+
+			var mu gosync.Mutex
+			manifest := manifestFor(1)
+			var fetched []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				fetched = append(fetched, r.URL.Path)
+				switch {
+				case r.URL.Path == "/manifest.json":
+					json.NewEncoder(w).Encode(manifest)
+				case r.URL.Path == "/"+geofence.PatchIndexFile && manifest.Version == 2:
+					json.NewEncoder(w).Encode(index)
+				case r.URL.Path == "/patches/v1_to_v2.bindiff":
+					w.Write(binaryData)
+				case files[r.URL.Path] != nil:
+					w.Write(files[r.URL.Path])
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer server.Close()
+
+			cfg := testSyncerConfig(t, server.URL)
+			ctx := context.Background()
+			syncer, err := NewSyncer(ctx, cfg)
+			if err != nil {
+				t.Fatalf("NewSyncer failed: %v", err)
+			}
+			defer syncer.Close()
+
+			if result := syncer.Sync(ctx); result.Error != nil {
+				t.Fatalf("Sync to version 1 failed: %v", result.Error)
+			}
+			mu.Lock()
+			manifest = manifestFor(2)
+			fetched = nil
+			mu.Unlock()
+
+			result := syncer.Sync(ctx)
+			if result.Error != nil {
+				t.Fatalf("Sync to version 2 failed: %v", result.Error)
+			}
+			if result.DeltaHops != tt.wantHops {
+				t.Errorf("DeltaHops = %d, want %d", result.DeltaHops, tt.wantHops)
+			}
+			if !slices.Contains(fetched, tt.wantURL) {
+				t.Errorf("fetched %v, want %s", fetched, tt.wantURL)
+			}
+			if slices.Contains(fetched, "/patches/v1_to_v2.bin") {
+				t.Error("fence delta fetched although the binary diff is smaller")
+			}
+			if result.FencesUpdated != 1 {
+				t.Errorf("FencesUpdated = %d, want 1", result.FencesUpdated)
+			}
+			a, err := syncer.store.GetFence(ctx, "a")
+			if err != nil {
+				t.Fatalf("GetFence failed: %v", err)
+			}
+			if !reflect.DeepEqual(a.Geometry.Polygon, moved) {
+				t.Error("fence a does not have the version 2 polygon")
+			}
+		})
+	}
+}
+
+func TestSync_DeltaSignature(t *testing.T) {
+	kp, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair failed: %v", err)
+	}
+	other, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair failed: %v", err)
+	}
+
+	square := func(id string, lat float64) geofence.FenceItem {
+		f := geofence.FenceItem{
+			ID:       id,
+			Type:     geofence.FenceTypePermanentNoFly,
+			Geometry: geofence.Geometry{BBox: &geofence.BoundingBox{MinLat: lat, MinLon: 110, MaxLat: lat + 0.5, MaxLon: 111}},
+		}
+		data, err := converter.FenceSigningData(&f, geofence.CurrentFormatVersion)
+		if err != nil {
+			t.Fatalf("FenceSigningData failed: %v", err)
+		}
+		sig, err := kp.Sign(data)
+		if err != nil {
+			t.Fatalf("Sign failed: %v", err)
+		}
+		f.SetSignature(sig, kp.KeyID)
+		return f
+	}
+	versions := map[uint64][]geofence.FenceItem{
+		1: {square("a", 30)},
+		2: {square("a", 30), square("b", 31)},
+	}
+
+	files := make(map[string][]byte)
+	for v := uint64(1); v <= 2; v++ {
+		snapshotData, _, err := merkle.CreateSnapshot(versions[v])
+		if err != nil {
+			t.Fatalf("CreateSnapshot failed: %v", err)
+		}
+		files[fmt.Sprintf("/v%d.bin", v)] = snapshotData
+	}
+	signedDelta := func(key *crypto.KeyPair) []byte {
+		delta, err := merkle.NewFenceDeltaFile(versions[1], versions[2], 1, 2, geofence.CurrentFormatVersion)
+		if err != nil {
+			t.Fatalf("NewFenceDeltaFile failed: %v", err)
+		}
+		data, err := converter.FenceDeltaSigningData(delta)
+		if err != nil {
+			t.Fatalf("FenceDeltaSigningData failed: %v", err)
+		}
+		sig, err := key.Sign(data)
+		if err != nil {
+			t.Fatalf("Sign failed: %v", err)
+		}
+		delta.SetSignature(sig, key.KeyID)
+		data, err = converter.MarshalFenceDeltaFile(delta)
+		if err != nil {
+			t.Fatalf("MarshalFenceDeltaFile failed: %v", err)
+		}
+		return data
+	}
+	manifestFor := func(v uint64, deltaData []byte) *geofence.Manifest {
+		tree, err := merkle.NewTree(versions[v])
+		if err != nil {
+			t.Fatalf("NewTree failed: %v", err)
+		}
+		rootHash := tree.RootHash()
+		m := &geofence.Manifest{
+			Version:       v,
+			Timestamp:     time.Now().Unix(),
+			SnapshotURL:   fmt.Sprintf("/v%d.bin", v),
+			SnapshotHash:  crypto.ComputeSHA256(files[fmt.Sprintf("/v%d.bin", v)]),
+			RootHash:      rootHash[:],
+			FormatVersion: geofence.CurrentFormatVersion,
+		}
+		if deltaData != nil {
+			m.DeltaURL = "/patches/v1_to_v2.bin"
+			m.DeltaSize = uint64(len(deltaData))
+			m.DeltaHash = crypto.ComputeSHA256(deltaData)
+		}
+		data, err := converter.ManifestSigningData(m)
+		if err != nil {
+			t.Fatalf("ManifestSigningData failed: %v", err)
+		}
+		sig, err := kp.Sign(data)
+		if err != nil {
+			t.Fatalf("Sign failed: %v", err)
+		}
+		m.SetSignature(sig, kp.KeyID)
+		return m
+	}
+
+	tests := []struct {
+		name     string
+		key      *crypto.KeyPair
+		wantHops int
+	}{
+		{"trusted", kp, 1},
+		{"untrusted", other, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deltaData := signedDelta(tt.key)
+			var mu gosync.Mutex
+			manifest := manifestFor(1, nil)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				switch {
+				case r.URL.Path == "/manifest.json":
+					json.NewEncoder(w).Encode(manifest)
+				case r.URL.Path == "/patches/v1_to_v2.bin":
+					w.Write(deltaData)
+				case files[r.URL.Path] != nil:
+					w.Write(files[r.URL.Path])
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer server.Close()
+
+			cfg := testSyncerConfig(t, server.URL)
+			cfg.InsecureSkipVerify = false
+			cfg.PublicKeyHex = crypto.MarshalPublicKeyHex(kp.PublicKey)
+			ctx := context.Background()
+			syncer, err := NewSyncer(ctx, cfg)
+			if err != nil {
+				t.Fatalf("NewSyncer failed: %v", err)
+			}
+			defer syncer.Close()
+
+			if result := syncer.Sync(ctx); result.Error != nil {
+				t.Fatalf("Sync to version 1 failed: %v", result.Error)
+			}
+			mu.Lock()
+			manifest = manifestFor(2, deltaData)
+			mu.Unlock()
+
+			result := syncer.Sync(ctx)
+			if result.Error != nil {
+				t.Fatalf("Sync to version 2 failed: %v", result.Error)
+			}
+			if result.DeltaHops != tt.wantHops {
+				t.Errorf("DeltaHops = %d, want %d", result.DeltaHops, tt.wantHops)
+			}
+			if result.FencesAdded != 1 {
+				t.Errorf("FencesAdded = %d, want 1", result.FencesAdded)
+			}
+			stored, err := syncer.GetFences(ctx)
+			if err != nil {
+				t.Fatalf("GetFences failed: %v", err)
+			}
+			if len(stored) != 2 {
+				t.Errorf("stored %d fences, want 2", len(stored))
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package validation
+
+import "github.com/iannil/geofence-updater-lite/pkg/geofence"
+
+// Normalize rewrites a fence's rings into canonical form: consecutive
+// duplicate vertices and an explicit closing vertex are removed, outer rings
+// are oriented counterclockwise and holes clockwise, each keeping its first
+// vertex. Containment is unchanged; only the encoding of the geometry is,
+// so equal shapes sign and hash equally. Degenerate rings are left as they
+// are for ValidateFence to report.
+func Normalize(f *geofence.FenceItem) {
+	g := &f.Geometry
+	g.Polygon = normalizeRing(g.Polygon, true)
+	for i := range g.Holes {
+		g.Holes[i] = normalizeRing(g.Holes[i], false)
+	}
+	for i := range g.MultiPolygon {
+		part := &g.MultiPolygon[i]
+		part.Outer = normalizeRing(part.Outer, true)
+		for j := range part.Holes {
+			part.Holes[j] = normalizeRing(part.Holes[j], false)
+		}
+	}
+}
+
+func normalizeRing(ring []geofence.Point, outer bool) []geofence.Point {
+	distinct := distinctVertices(ring)
+	if len(distinct) < 3 {
+		return ring
+	}
+	return geofence.OrientRing(distinct, outer)
+}
+
+// distinctVertices returns a copy of a ring without consecutive duplicate
+// vertices, including a last vertex that repeats the first.
+func distinctVertices(ring []geofence.Point) []geofence.Point {
+	out := make([]geofence.Point, 0, len(ring))
+	for _, p := range ring {
+		if len(out) == 0 || out[len(out)-1] != p {
+			out = append(out, p)
+		}
+	}
+	for len(out) > 1 && out[0] == out[len(out)-1] {
+		out = out[:len(out)-1]
+	}
+	return out
+}
+
+// unwrapRing returns a ring in unwrapped longitudes so that planar checks
+// work across the antimeridian, and whether it encloses a pole. A ring
+// around a pole is closed along the pole into a band spanning 360°, which
+// has no meaningful orientation.
+func unwrapRing(ring []geofence.Point) ([]geofence.Point, bool) {
+	u := geofence.UnwrapLongitudes(ring)
+	pole, ok := geofence.EnclosedPole(ring)
+	if !ok {
+		return u, false
+	}
+	first, last := u[0], u[len(u)-1]
+	end := last.Longitude + geofence.NormalizeLongitude(first.Longitude-last.Longitude)
+	return append(u,
+		geofence.Point{Latitude: first.Latitude, Longitude: end},
+		geofence.Point{Latitude: pole, Longitude: end},
+		geofence.Point{Latitude: pole, Longitude: first.Longitude},
+	), true
+}
+
+// selfIntersection finds two edges of a ring that intersect other than at
+// the vertex shared by neighboring edges. Edge i runs from vertex i to the
+// next vertex. Coordinates are treated as planar, like the point-in-polygon
+// test.
+func selfIntersection(ring []geofence.Point) (int, int, bool) {
+	n := len(ring)
+	for i := 0; i < n; i++ {
+		a, b := ring[i], ring[(i+1)%n]
+		for j := i + 1; j < n; j++ {
+			c, d := ring[j], ring[(j+1)%n]
+			switch {
+			case j == i+1:
+				// Neighbors share b == c; they only overlap if the ring
+				// doubles back on itself
+				if folds(a, b, d) {
+					return i, j, true
+				}
+			case i == 0 && j == n-1:
+				// Neighbors share a == d
+				if folds(b, a, c) {
+					return i, j, true
+				}
+			default:
+				if segmentsIntersect(a, b, c, d) {
+					return i, j, true
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// ringsCross reports whether any edge of one ring intersects an edge of the
+// other. Longitudes are unwrapped, and the rings are compared as they are and
+// shifted by 360° either way, so crossings at the antimeridian are found.
+func ringsCross(r1, r2 []geofence.Point) bool {
+	p1, p2 := closedPath(r1), closedPath(r2)
+	for _, shift := range []float64{0, -360, 360} {
+		for i := 0; i+1 < len(p1); i++ {
+			for j := 0; j+1 < len(p2); j++ {
+				c := geofence.Point{Latitude: p2[j].Latitude, Longitude: p2[j].Longitude + shift}
+				d := geofence.Point{Latitude: p2[j+1].Latitude, Longitude: p2[j+1].Longitude + shift}
+				if segmentsIntersect(p1[i], p1[i+1], c, d) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// closedPath returns the edges of a ring as a path in unwrapped longitudes
+// that repeats the first vertex at the end, 360° away from the start if
+// the ring winds around a pole.
+func closedPath(ring []geofence.Point) []geofence.Point {
+	return geofence.UnwrapLongitudes(append(ring[:len(ring):len(ring)], ring[0]))
+}
+
+// folds reports whether the path a-b-c turns back onto itself at b.
+func folds(a, b, c geofence.Point) bool {
+	if orientation(a, b, c) != 0 {
+		return false
+	}
+	dot := (b.Longitude-a.Longitude)*(c.Longitude-b.Longitude) + (b.Latitude-a.Latitude)*(c.Latitude-b.Latitude)
+	return dot < 0
+}
+
+// segmentsIntersect reports whether segments a-b and c-d share any point.
+func segmentsIntersect(a, b, c, d geofence.Point) bool {
+	d1 := orientation(c, d, a)
+	d2 := orientation(c, d, b)
+	d3 := orientation(a, b, c)
+	d4 := orientation(a, b, d)
+
+	if ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) &&
+		((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0)) {
+		return true
+	}
+	return (d1 == 0 && onSegment(c, d, a)) ||
+		(d2 == 0 && onSegment(c, d, b)) ||
+		(d3 == 0 && onSegment(a, b, c)) ||
+		(d4 == 0 && onSegment(a, b, d))
+}
+
+// orientation returns the cross product of a-b and a-c: positive if c lies
+// to the left of a-b, negative if to the right and 0 if collinear.
+func orientation(a, b, c geofence.Point) float64 {
+	return (b.Longitude-a.Longitude)*(c.Latitude-a.Latitude) -
+		(b.Latitude-a.Latitude)*(c.Longitude-a.Longitude)
+}
+
+// onSegment reports whether p, collinear with a-b, lies on the segment.
+func onSegment(a, b, p geofence.Point) bool {
+	return p.Longitude >= min(a.Longitude, b.Longitude) && p.Longitude <= max(a.Longitude, b.Longitude) &&
+		p.Latitude >= min(a.Latitude, b.Latitude) && p.Latitude <= max(a.Latitude, b.Latitude)
+}
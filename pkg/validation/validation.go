@@ -0,0 +1,390 @@
+// Package validation checks geofences for geometric and semantic errors
+// before they are signed and published, and normalizes their geometry.
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/iannil/geofence-updater-lite/pkg/geofence"
+)
+
+// Radius and half-width bounds for circles and corridors in meters.
+const (
+	MinRadius = 1.0
+	MaxRadius = 1_000_000.0
+)
+
+// Severity is the severity of a validation issue.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"   // The fence must not be published
+	SeverityWarning Severity = "warning" // The fence is usable but probably not as intended
+)
+
+// Issue is a single problem found in a fence.
+type Issue struct {
+	FenceID  string   `json:"fence_id"`
+	Field    string   `json:"field"` // JSON path of the offending field, e.g. "geometry.polygon"
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+// Error implements the error interface.
+func (i Issue) Error() string {
+	return fmt.Sprintf("fence %q: %s: %s", i.FenceID, i.Field, i.Message)
+}
+
+// Report is the result of validating one or more fences.
+type Report struct {
+	Fences int     `json:"fences"` // Number of fences validated
+	Issues []Issue `json:"issues"`
+}
+
+// HasErrors reports whether the report contains any error.
+func (r *Report) HasErrors() bool {
+	for _, i := range r.Issues {
+		if i.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Errors returns the issues with error severity.
+func (r *Report) Errors() []Issue {
+	return r.filter(SeverityError)
+}
+
+// Warnings returns the issues with warning severity.
+func (r *Report) Warnings() []Issue {
+	return r.filter(SeverityWarning)
+}
+
+func (r *Report) filter(s Severity) []Issue {
+	var issues []Issue
+	for _, i := range r.Issues {
+		if i.Severity == s {
+			issues = append(issues, i)
+		}
+	}
+	return issues
+}
+
+// Err returns the errors of the report joined into a single error, or nil
+// if there are none. Warnings are not included.
+func (r *Report) Err() error {
+	var errs []error
+	for _, i := range r.Errors() {
+		errs = append(errs, i)
+	}
+	return errors.Join(errs...)
+}
+
+// ValidateFences validates a set of fences that are published together.
+// In addition to the checks of ValidateFence, fence IDs must be unique.
+func ValidateFences(fences []geofence.FenceItem) *Report {
+	r := &Report{Fences: len(fences)}
+	seen := make(map[string]bool, len(fences))
+	for i := range fences {
+		f := &fences[i]
+		r.Issues = append(r.Issues, ValidateFence(f).Issues...)
+		if f.ID != "" && seen[f.ID] {
+			r.Issues = append(r.Issues, Issue{FenceID: f.ID, Field: "id", Severity: SeverityError, Message: "duplicate fence ID"})
+		}
+		seen[f.ID] = true
+	}
+	return r
+}
+
+// ValidateFence validates a single fence.
+func ValidateFence(f *geofence.FenceItem) *Report {
+	v := &validator{fence: f}
+	v.validate()
+	return &Report{Fences: 1, Issues: v.issues}
+}
+
+// validator collects the issues of a single fence.
+type validator struct {
+	fence  *geofence.FenceItem
+	issues []Issue
+}
+
+func (v *validator) errorf(field, format string, args ...any) {
+	v.issues = append(v.issues, Issue{FenceID: v.fence.ID, Field: field, Severity: SeverityError, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) warnf(field, format string, args ...any) {
+	v.issues = append(v.issues, Issue{FenceID: v.fence.ID, Field: field, Severity: SeverityWarning, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) validate() {
+	f := v.fence
+	if f.ID == "" {
+		v.errorf("id", "missing fence ID")
+	}
+	v.validateTimeWindow()
+	v.validateType()
+	v.validateAltitude()
+	v.validateConditions()
+	v.validateGeometry()
+}
+
+func (v *validator) validateTimeWindow() {
+	f := v.fence
+	if f.StartTS < 0 {
+		v.errorf("start_ts", "negative start time: %d", f.StartTS)
+	}
+	if f.EndTS < 0 {
+		v.errorf("end_ts", "negative end time: %d", f.EndTS)
+	}
+	if f.EndTS > 0 && f.EndTS <= f.StartTS {
+		v.errorf("end_ts", "end time %d is not after start time %d", f.EndTS, f.StartTS)
+	}
+	if s := f.Schedule; s != nil {
+		if err := s.Validate(); err != nil {
+			v.errorf("schedule", "%v", err)
+		}
+		if len(s.Rules) == 0 {
+			v.warnf("schedule", "schedule has no rules, the fence is always active")
+		}
+	}
+}
+
+// validateConditions checks the aircraft and operations a fence applies to.
+func (v *validator) validateConditions() {
+	c := v.fence.Conditions
+	if c == nil {
+		return
+	}
+	if err := c.Validate(); err != nil {
+		v.errorf("conditions", "%v", err)
+	}
+	for _, op := range c.Operations {
+		if slices.Contains(c.ExemptOperations, op) {
+			v.warnf("conditions.exempt_operations", "operation %s is both restricted and exempt", op)
+		}
+	}
+}
+
+// validateType checks that the fields a fence type relies on are set and
+// warns about fields the type ignores.
+func (v *validator) validateType() {
+	f := v.fence
+	switch f.Type {
+	case geofence.FenceTypeTempRestriction, geofence.FenceTypePermanentNoFly:
+	case geofence.FenceTypeAltitudeLimit:
+		if ceiling, _ := f.CeilingAltitude(); ceiling == 0 {
+			v.errorf("max_alt_m", "ALTITUDE_LIMIT fence without a ceiling")
+		}
+	case geofence.FenceTypeAltitudeMinimum:
+		if floor, _ := f.FloorAltitude(); floor == 0 {
+			v.errorf("altitude.floor_m", "ALTITUDE_MINIMUM fence without a floor")
+		}
+	case geofence.FenceTypeSpeedLimit:
+		if f.MaxSpeed == 0 {
+			v.errorf("max_speed_mps", "SPEED_LIMIT fence without a speed limit")
+		}
+	default:
+		v.errorf("type", "unknown fence type: %d", f.Type)
+		return
+	}
+
+	if f.MaxAltitude != 0 && f.Type != geofence.FenceTypeAltitudeLimit {
+		v.warnf("max_alt_m", "ignored for %s fences", f.Type)
+	}
+	if f.MaxSpeed != 0 && f.Type != geofence.FenceTypeSpeedLimit {
+		v.warnf("max_speed_mps", "ignored for %s fences", f.Type)
+	}
+}
+
+func (v *validator) validateAltitude() {
+	b := v.fence.Altitude
+	if b == nil {
+		return
+	}
+	if !isFinite(b.Floor) || !isFinite(b.Ceiling) {
+		v.errorf("altitude", "altitude band is not finite")
+		return
+	}
+	if b.Reference.String() == "UNKNOWN" {
+		v.errorf("altitude.reference", "unknown altitude reference: %d", b.Reference)
+	}
+	if b.Ceiling < 0 {
+		v.errorf("altitude.ceiling_m", "negative ceiling: %g", b.Ceiling)
+	} else if b.Ceiling > 0 && b.Ceiling <= b.Floor {
+		v.errorf("altitude", "ceiling %g is not above floor %g", b.Ceiling, b.Floor)
+	}
+	if ceiling := float64(v.fence.MaxAltitude); ceiling > 0 && b.Ceiling > 0 && ceiling != b.Ceiling {
+		v.warnf("max_alt_m", "ignored in favor of the altitude band ceiling")
+	}
+}
+
+func (v *validator) validateGeometry() {
+	g := &v.fence.Geometry
+
+	shapes := 0
+	for _, set := range []bool{len(g.Polygon) > 0, len(g.MultiPolygon) > 0, g.CircleCenter != nil, g.BBox != nil, g.Corridor != nil} {
+		if set {
+			shapes++
+		}
+	}
+	switch {
+	case shapes == 0:
+		v.errorf("geometry", "no shape")
+		return
+	case shapes > 1:
+		v.errorf("geometry", "more than one shape")
+		return
+	}
+	if len(g.Holes) > 0 && len(g.Polygon) == 0 {
+		v.errorf("geometry.holes", "holes without a polygon")
+	}
+	if g.Edges.String() == "UNKNOWN" {
+		v.errorf("geometry.edges", "unknown edge type: %d", g.Edges)
+	} else if g.Edges != geofence.EdgeTypeRhumb && len(g.Polygon) == 0 && len(g.MultiPolygon) == 0 {
+		v.warnf("geometry.edges", "ignored for shapes other than polygons")
+	}
+
+	switch {
+	case len(g.Polygon) > 0:
+		v.validatePolygon("geometry", g.Polygon, g.Holes)
+	case len(g.MultiPolygon) > 0:
+		for i, part := range g.MultiPolygon {
+			v.validatePolygon(fmt.Sprintf("geometry.multi_polygon[%d]", i), part.Outer, part.Holes)
+		}
+	case g.CircleCenter != nil:
+		v.validatePoint("geometry.circle_center", *g.CircleCenter)
+		v.validateDistance("geometry.circle_radius_m", g.CircleRadius)
+	case g.BBox != nil:
+		v.validateBBox(g.BBox)
+	case g.Corridor != nil:
+		c := g.Corridor
+		if len(c.Path) < 2 {
+			v.errorf("geometry.corridor.path", "corridor path needs at least 2 points, got %d", len(c.Path))
+		}
+		for i, p := range c.Path {
+			v.validatePoint(fmt.Sprintf("geometry.corridor.path[%d]", i), p)
+		}
+		v.validateDistance("geometry.corridor.half_width_m", c.HalfWidth)
+	}
+}
+
+func (v *validator) validateBBox(b *geofence.BoundingBox) {
+	minOK := v.validatePoint("geometry.bbox", geofence.Point{Latitude: b.MinLat, Longitude: b.MinLon})
+	maxOK := v.validatePoint("geometry.bbox", geofence.Point{Latitude: b.MaxLat, Longitude: b.MaxLon})
+	if !minOK || !maxOK {
+		return
+	}
+	if b.MinLat >= b.MaxLat {
+		v.errorf("geometry.bbox", "inverted or empty latitude range: %g to %g", b.MinLat, b.MaxLat)
+	}
+	// MinLon > MaxLon is a box crossing the antimeridian
+	if b.MinLon == b.MaxLon {
+		v.errorf("geometry.bbox", "empty longitude range: %g to %g", b.MinLon, b.MaxLon)
+	}
+}
+
+func (v *validator) validateDistance(field string, d float64) {
+	if !(d >= MinRadius && d <= MaxRadius) {
+		v.errorf(field, "must be between %g and %g meters, got %g", MinRadius, MaxRadius, d)
+	}
+}
+
+// validatePoint checks the coordinate ranges of a point and reports whether
+// it is valid.
+func (v *validator) validatePoint(field string, p geofence.Point) bool {
+	if _, err := geofence.NewPoint(p.Latitude, p.Longitude); err != nil {
+		v.errorf(field, "%v", err)
+		return false
+	}
+	return true
+}
+
+// validatePolygon checks an outer ring and its holes.
+func (v *validator) validatePolygon(field string, outer []geofence.Point, holes [][]geofence.Point) {
+	outerField := field + ".polygon"
+	holesField := field + ".holes"
+	if field != "geometry" {
+		outerField = field + ".outer"
+	}
+
+	if !v.validateRing(outerField, outer, true) {
+		return
+	}
+	outerRing := distinctVertices(outer)
+
+	valid := make([][]geofence.Point, 0, len(holes))
+	for i, hole := range holes {
+		holeField := fmt.Sprintf("%s[%d]", holesField, i)
+		if !v.validateRing(holeField, hole, false) {
+			continue
+		}
+		ring := distinctVertices(hole)
+		if ringsCross(outerRing, ring) {
+			v.errorf(holeField, "hole crosses the outer ring")
+			continue
+		}
+		if !(&geofence.Geometry{Polygon: outer}).ContainsPoint(ring[0]) {
+			v.errorf(holeField, "hole lies outside the outer ring")
+			continue
+		}
+		for j, other := range valid {
+			if ringsCross(other, ring) {
+				v.errorf(holeField, "hole crosses hole %d", j)
+			}
+		}
+		valid = append(valid, ring)
+	}
+}
+
+// validateRing checks a single ring and reports whether it is usable for
+// further checks. Rings are implicitly closed; a repeated first vertex and
+// consecutive duplicates are tolerated with a warning, as Normalize removes
+// them.
+func (v *validator) validateRing(field string, ring []geofence.Point, outer bool) bool {
+	for i, p := range ring {
+		if !v.validatePoint(fmt.Sprintf("%s[%d]", field, i), p) {
+			return false
+		}
+	}
+
+	distinct := distinctVertices(ring)
+	if len(distinct) < 3 {
+		v.errorf(field, "ring needs at least 3 distinct vertices, got %d", len(distinct))
+		return false
+	}
+	if len(ring) > 1 && ring[0] == ring[len(ring)-1] {
+		v.warnf(field, "ring repeats its first vertex; rings are closed implicitly")
+	} else if len(distinct) != len(ring) {
+		v.warnf(field, "ring has consecutive duplicate vertices")
+	}
+
+	// Planar checks run in unwrapped longitudes; a ring around a pole is
+	// closed along the pole and has no orientation to check
+	planar, pole := unwrapRing(distinct)
+	if i, j, ok := selfIntersection(planar); ok {
+		v.errorf(field, "ring intersects itself at edges %d and %d", i, j)
+		return false
+	}
+	orientation := geofence.RingOrientation(distinct)
+	if orientation == 0 && !pole {
+		v.errorf(field, "ring has zero area")
+		return false
+	}
+	if orientation != 0 && (orientation > 0) != outer {
+		want := "counterclockwise"
+		if !outer {
+			want = "clockwise"
+		}
+		v.warnf(field, "ring should be %s", want)
+	}
+	return true
+}
+
+func isFinite(x float64) bool {
+	return !math.IsNaN(x) && !math.IsInf(x, 0)
+}
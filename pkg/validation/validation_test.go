@@ -0,0 +1,385 @@
+package validation
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iannil/geofence-updater-lite/pkg/geofence"
+)
+
+func validFence() geofence.FenceItem {
+	return geofence.FenceItem{
+		ID:       "valid",
+		Type:     geofence.FenceTypeTempRestriction,
+		StartTS:  1700000000,
+		EndTS:    1700086400,
+		Priority: 50,
+		Geometry: geofence.Geometry{
+			Polygon: []geofence.Point{
+				{Latitude: 39.9, Longitude: 116.4},
+				{Latitude: 39.9, Longitude: 116.5},
+				{Latitude: 40.0, Longitude: 116.5},
+				{Latitude: 40.0, Longitude: 116.4},
+			},
+		},
+	}
+}
+
+func TestValidateFence_Valid(t *testing.T) {
+	fences := map[string]func(f *geofence.FenceItem){
+		"polygon": func(f *geofence.FenceItem) {},
+		"polygon with hole": func(f *geofence.FenceItem) {
+			f.Geometry.Holes = [][]geofence.Point{{
+				{Latitude: 39.94, Longitude: 116.44},
+				{Latitude: 39.96, Longitude: 116.44},
+				{Latitude: 39.96, Longitude: 116.46},
+				{Latitude: 39.94, Longitude: 116.46},
+			}}
+		},
+		"circle": func(f *geofence.FenceItem) {
+			f.Geometry = geofence.Geometry{CircleCenter: &geofence.Point{Latitude: 31.23, Longitude: 121.47}, CircleRadius: 500}
+		},
+		"bbox": func(f *geofence.FenceItem) {
+			f.Geometry = geofence.Geometry{BBox: &geofence.BoundingBox{MinLat: 1, MinLon: 2, MaxLat: 3, MaxLon: 4}}
+		},
+		"corridor": func(f *geofence.FenceItem) {
+			f.Geometry = geofence.Geometry{Corridor: &geofence.Corridor{
+				Path:      []geofence.Point{{Latitude: 39.9, Longitude: 116.3}, {Latitude: 39.95, Longitude: 116.4}},
+				HalfWidth: 50,
+			}}
+		},
+		"altitude limit": func(f *geofence.FenceItem) {
+			f.Type = geofence.FenceTypeAltitudeLimit
+			f.MaxAltitude = 120
+		},
+		"altitude minimum": func(f *geofence.FenceItem) {
+			f.Type = geofence.FenceTypeAltitudeMinimum
+			f.Altitude = &geofence.AltitudeBand{Floor: 30}
+		},
+		"speed limit": func(f *geofence.FenceItem) {
+			f.Type = geofence.FenceTypeSpeedLimit
+			f.MaxSpeed = 15
+		},
+		"no expiry": func(f *geofence.FenceItem) {
+			f.EndTS = 0
+		},
+		"polygon across antimeridian with hole": func(f *geofence.FenceItem) {
+			f.Geometry = geofence.Geometry{
+				Polygon: []geofence.Point{
+					{Latitude: -10, Longitude: 170},
+					{Latitude: -10, Longitude: -170},
+					{Latitude: 10, Longitude: -170},
+					{Latitude: 10, Longitude: 170},
+				},
+				Holes: [][]geofence.Point{{
+					{Latitude: -1, Longitude: 179},
+					{Latitude: 1, Longitude: 179},
+					{Latitude: 1, Longitude: -179},
+					{Latitude: -1, Longitude: -179},
+				}},
+			}
+		},
+		"ring around south pole": func(f *geofence.FenceItem) {
+			f.Geometry = geofence.Geometry{
+				Polygon: []geofence.Point{
+					{Latitude: -70, Longitude: 0},
+					{Latitude: -70, Longitude: 90},
+					{Latitude: -70, Longitude: 180},
+					{Latitude: -70, Longitude: -90},
+				},
+				Holes: [][]geofence.Point{{
+					{Latitude: -80, Longitude: -5},
+					{Latitude: -80, Longitude: 5},
+					{Latitude: -81, Longitude: 5},
+					{Latitude: -81, Longitude: -5},
+				}},
+			}
+		},
+		"great-circle polygon": func(f *geofence.FenceItem) {
+			f.Geometry.Edges = geofence.EdgeTypeGreatCircle
+		},
+		"bbox across antimeridian": func(f *geofence.FenceItem) {
+			f.Geometry = geofence.Geometry{BBox: &geofence.BoundingBox{MinLat: -10, MinLon: 170, MaxLat: 10, MaxLon: -170}}
+		},
+	}
+
+	for name, modify := range fences {
+		t.Run(name, func(t *testing.T) {
+			f := validFence()
+			modify(&f)
+			report := ValidateFence(&f)
+			if len(report.Issues) != 0 {
+				t.Errorf("Issues = %v, want none", report.Issues)
+			}
+			if report.Err() != nil {
+				t.Errorf("Err() = %v, want nil", report.Err())
+			}
+		})
+	}
+}
+
+func TestValidateFence_Issues(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(f *geofence.FenceItem)
+		field    string
+		severity Severity
+		message  string
+	}{
+		{"missing id", func(f *geofence.FenceItem) { f.ID = "" }, "id", SeverityError, "missing"},
+		{"end before start", func(f *geofence.FenceItem) { f.EndTS = f.StartTS - 1 }, "end_ts", SeverityError, "not after start"},
+		{"negative start", func(f *geofence.FenceItem) { f.StartTS = -1; f.EndTS = 0 }, "start_ts", SeverityError, "negative"},
+		{"unknown type", func(f *geofence.FenceItem) { f.Type = 42 }, "type", SeverityError, "unknown fence type"},
+		{"altitude limit without ceiling", func(f *geofence.FenceItem) { f.Type = geofence.FenceTypeAltitudeLimit }, "max_alt_m", SeverityError, "without a ceiling"},
+		{"altitude minimum without floor", func(f *geofence.FenceItem) { f.Type = geofence.FenceTypeAltitudeMinimum }, "altitude.floor_m", SeverityError, "without a floor"},
+		{"speed limit without speed", func(f *geofence.FenceItem) { f.Type = geofence.FenceTypeSpeedLimit }, "max_speed_mps", SeverityError, "without a speed limit"},
+		{"ignored speed", func(f *geofence.FenceItem) { f.MaxSpeed = 10 }, "max_speed_mps", SeverityWarning, "ignored"},
+		{"inverted altitude band", func(f *geofence.FenceItem) {
+			f.Altitude = &geofence.AltitudeBand{Floor: 100, Ceiling: 50}
+		}, "altitude", SeverityError, "not above floor"},
+		{"invalid schedule", func(f *geofence.FenceItem) {
+			f.Schedule = &geofence.Schedule{Rules: []geofence.WeeklyRule{{Days: []time.Weekday{time.Monday}, StartMinute: 2000}}}
+		}, "schedule", SeverityError, "start minute"},
+		{"empty schedule", func(f *geofence.FenceItem) { f.Schedule = &geofence.Schedule{} }, "schedule", SeverityWarning, "no rules"},
+		{"unknown operation", func(f *geofence.FenceItem) {
+			f.Conditions = &geofence.Conditions{Operations: []geofence.OperationType{42}}
+		}, "conditions", SeverityError, "unknown operation type"},
+		{"empty mass range", func(f *geofence.FenceItem) {
+			f.Conditions = &geofence.Conditions{MinMassKg: 25, MaxMassKg: 4}
+		}, "conditions", SeverityError, "not above minimum mass"},
+		{"restricted and exempt operation", func(f *geofence.FenceItem) {
+			f.Conditions = &geofence.Conditions{
+				Operations:       []geofence.OperationType{geofence.OperationTypeEmergency},
+				ExemptOperations: []geofence.OperationType{geofence.OperationTypeEmergency},
+			}
+		}, "conditions.exempt_operations", SeverityWarning, "both restricted and exempt"},
+		{"no shape", func(f *geofence.FenceItem) { f.Geometry = geofence.Geometry{} }, "geometry", SeverityError, "no shape"},
+		{"two shapes", func(f *geofence.FenceItem) {
+			f.Geometry.CircleCenter = &geofence.Point{Latitude: 39.95, Longitude: 116.45}
+			f.Geometry.CircleRadius = 100
+		}, "geometry", SeverityError, "more than one shape"},
+		{"two point polygon", func(f *geofence.FenceItem) {
+			f.Geometry.Polygon = f.Geometry.Polygon[:2]
+		}, "geometry.polygon", SeverityError, "at least 3 distinct vertices"},
+		{"latitude out of range", func(f *geofence.FenceItem) {
+			f.Geometry.Polygon[2].Latitude = 91
+		}, "geometry.polygon[2]", SeverityError, "latitude out of range"},
+		{"NaN longitude", func(f *geofence.FenceItem) {
+			f.Geometry.Polygon[1].Longitude = math.NaN()
+		}, "geometry.polygon[1]", SeverityError, "longitude out of range"},
+		{"self-intersecting polygon", func(f *geofence.FenceItem) {
+			p := f.Geometry.Polygon
+			p[1], p[2] = p[2], p[1]
+		}, "geometry.polygon", SeverityError, "intersects itself"},
+		{"collinear polygon", func(f *geofence.FenceItem) {
+			f.Geometry.Polygon = []geofence.Point{{Latitude: 0, Longitude: 0}, {Latitude: 1, Longitude: 1}, {Latitude: 2, Longitude: 2}}
+		}, "geometry.polygon", SeverityError, "intersects itself"},
+		{"closed ring", func(f *geofence.FenceItem) {
+			f.Geometry.Polygon = append(f.Geometry.Polygon, f.Geometry.Polygon[0])
+		}, "geometry.polygon", SeverityWarning, "repeats its first vertex"},
+		{"clockwise outer ring", func(f *geofence.FenceItem) {
+			p := f.Geometry.Polygon
+			p[1], p[3] = p[3], p[1]
+		}, "geometry.polygon", SeverityWarning, "counterclockwise"},
+		{"counterclockwise hole", func(f *geofence.FenceItem) {
+			f.Geometry.Holes = [][]geofence.Point{{
+				{Latitude: 39.94, Longitude: 116.44},
+				{Latitude: 39.94, Longitude: 116.46},
+				{Latitude: 39.96, Longitude: 116.46},
+				{Latitude: 39.96, Longitude: 116.44},
+			}}
+		}, "geometry.holes[0]", SeverityWarning, "clockwise"},
+		{"hole outside polygon", func(f *geofence.FenceItem) {
+			f.Geometry.Holes = [][]geofence.Point{{
+				{Latitude: 10, Longitude: 10},
+				{Latitude: 11, Longitude: 10},
+				{Latitude: 11, Longitude: 11},
+			}}
+		}, "geometry.holes[0]", SeverityError, "outside the outer ring"},
+		{"hole crossing polygon", func(f *geofence.FenceItem) {
+			f.Geometry.Holes = [][]geofence.Point{{
+				{Latitude: 39.95, Longitude: 116.45},
+				{Latitude: 40.05, Longitude: 116.45},
+				{Latitude: 40.05, Longitude: 116.46},
+			}}
+		}, "geometry.holes[0]", SeverityError, "crosses the outer ring"},
+		{"holes without polygon", func(f *geofence.FenceItem) {
+			f.Geometry = geofence.Geometry{
+				CircleCenter: &geofence.Point{Latitude: 39.95, Longitude: 116.45},
+				CircleRadius: 100,
+				Holes:        [][]geofence.Point{{{Latitude: 0, Longitude: 0}}},
+			}
+		}, "geometry.holes", SeverityError, "without a polygon"},
+		{"zero radius", func(f *geofence.FenceItem) {
+			f.Geometry = geofence.Geometry{CircleCenter: &geofence.Point{Latitude: 39.95, Longitude: 116.45}}
+		}, "geometry.circle_radius_m", SeverityError, "between"},
+		{"negative radius", func(f *geofence.FenceItem) {
+			f.Geometry = geofence.Geometry{CircleCenter: &geofence.Point{Latitude: 39.95, Longitude: 116.45}, CircleRadius: -5}
+		}, "geometry.circle_radius_m", SeverityError, "between"},
+		{"huge radius", func(f *geofence.FenceItem) {
+			f.Geometry = geofence.Geometry{CircleCenter: &geofence.Point{Latitude: 39.95, Longitude: 116.45}, CircleRadius: 2e7}
+		}, "geometry.circle_radius_m", SeverityError, "between"},
+		{"inverted bbox", func(f *geofence.FenceItem) {
+			f.Geometry = geofence.Geometry{BBox: &geofence.BoundingBox{MinLat: 3, MinLon: 2, MaxLat: 1, MaxLon: 4}}
+		}, "geometry.bbox", SeverityError, "inverted"},
+		{"unknown edge type", func(f *geofence.FenceItem) { f.Geometry.Edges = 7 }, "geometry.edges", SeverityError, "unknown edge type"},
+		{"edge type of a circle", func(f *geofence.FenceItem) {
+			f.Geometry = geofence.Geometry{CircleCenter: &geofence.Point{Latitude: 39.95, Longitude: 116.45}, CircleRadius: 100, Edges: geofence.EdgeTypeGreatCircle}
+		}, "geometry.edges", SeverityWarning, "ignored"},
+		{"empty bbox", func(f *geofence.FenceItem) {
+			f.Geometry = geofence.Geometry{BBox: &geofence.BoundingBox{MinLat: 1, MinLon: 2, MaxLat: 3, MaxLon: 2}}
+		}, "geometry.bbox", SeverityError, "empty longitude range"},
+		{"self-intersecting across antimeridian", func(f *geofence.FenceItem) {
+			f.Geometry.Polygon = []geofence.Point{
+				{Latitude: -10, Longitude: 170},
+				{Latitude: 10, Longitude: -170},
+				{Latitude: -10, Longitude: -170},
+				{Latitude: 10, Longitude: 170},
+			}
+		}, "geometry.polygon", SeverityError, "intersects itself"},
+		{"single point corridor", func(f *geofence.FenceItem) {
+			f.Geometry = geofence.Geometry{Corridor: &geofence.Corridor{Path: []geofence.Point{{Latitude: 1, Longitude: 1}}, HalfWidth: 10}}
+		}, "geometry.corridor.path", SeverityError, "at least 2 points"},
+		{"multi-polygon part", func(f *geofence.FenceItem) {
+			f.Geometry = geofence.Geometry{MultiPolygon: []geofence.PolygonPart{
+				{Outer: f.Geometry.Polygon},
+				{Outer: []geofence.Point{{Latitude: 0, Longitude: 0}, {Latitude: 0, Longitude: 1}}},
+			}}
+		}, "geometry.multi_polygon[1].outer", SeverityError, "at least 3 distinct vertices"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := validFence()
+			tt.modify(&f)
+			report := ValidateFence(&f)
+
+			var found bool
+			for _, issue := range report.Issues {
+				if issue.Field == tt.field && issue.Severity == tt.severity && strings.Contains(issue.Message, tt.message) {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("Issues = %v, want %s on %s containing %q", report.Issues, tt.severity, tt.field, tt.message)
+			}
+			if got := report.HasErrors(); got != (tt.severity == SeverityError) {
+				t.Errorf("HasErrors() = %v, want %v", got, tt.severity == SeverityError)
+			}
+		})
+	}
+}
+
+func TestValidateFences_DuplicateIDs(t *testing.T) {
+	a, b, c := validFence(), validFence(), validFence()
+	c.ID = "other"
+
+	report := ValidateFences([]geofence.FenceItem{a, b, c})
+	if report.Fences != 3 {
+		t.Errorf("Fences = %d, want 3", report.Fences)
+	}
+	errs := report.Errors()
+	if len(errs) != 1 || errs[0].Field != "id" || errs[0].FenceID != "valid" {
+		t.Errorf("Errors() = %v, want one duplicate ID error", errs)
+	}
+	if err := report.Err(); err == nil || !strings.Contains(err.Error(), "duplicate fence ID") {
+		t.Errorf("Err() = %v, want duplicate fence ID", err)
+	}
+}
+
+func TestReport_ErrExcludesWarnings(t *testing.T) {
+	f := validFence()
+	f.MaxSpeed = 10
+
+	report := ValidateFence(&f)
+	if len(report.Warnings()) != 1 {
+		t.Errorf("Warnings() = %v, want 1", report.Warnings())
+	}
+	if err := report.Err(); err != nil {
+		t.Errorf("Err() = %v, want nil for warnings only", err)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	f := validFence()
+	outer := f.Geometry.Polygon
+	// Clockwise, explicitly closed, with a repeated vertex
+	f.Geometry.Polygon = []geofence.Point{outer[0], outer[3], outer[3], outer[2], outer[1], outer[0]}
+	// Counterclockwise hole
+	f.Geometry.Holes = [][]geofence.Point{{
+		{Latitude: 39.94, Longitude: 116.44},
+		{Latitude: 39.94, Longitude: 116.46},
+		{Latitude: 39.96, Longitude: 116.46},
+		{Latitude: 39.96, Longitude: 116.44},
+	}}
+
+	before := ValidateFence(&f)
+	if len(before.Warnings()) == 0 {
+		t.Fatal("expected warnings before normalization")
+	}
+
+	Normalize(&f)
+
+	if report := ValidateFence(&f); len(report.Issues) != 0 {
+		t.Errorf("Issues after Normalize = %v, want none", report.Issues)
+	}
+	if len(f.Geometry.Polygon) != 4 {
+		t.Errorf("outer ring = %d vertices, want 4", len(f.Geometry.Polygon))
+	}
+	if f.Geometry.Polygon[0] != outer[0] {
+		t.Errorf("first vertex = %v, want %v", f.Geometry.Polygon[0], outer[0])
+	}
+	if f.Geometry.Holes[0][0] != (geofence.Point{Latitude: 39.94, Longitude: 116.44}) {
+		t.Errorf("hole first vertex = %v, want it kept", f.Geometry.Holes[0][0])
+	}
+
+	// Containment is unchanged
+	for _, p := range []geofence.Point{{Latitude: 39.92, Longitude: 116.42}, {Latitude: 39.95, Longitude: 116.45}, {Latitude: 0, Longitude: 0}} {
+		want := p.Latitude == 39.92
+		if got := f.ContainsPoint(p); got != want {
+			t.Errorf("ContainsPoint(%v) = %v, want %v", p, got, want)
+		}
+	}
+}
+
+func TestNormalize_Antimeridian(t *testing.T) {
+	f := validFence()
+	// Clockwise across the antimeridian; planar coordinates would call it
+	// counterclockwise
+	f.Geometry.Polygon = []geofence.Point{
+		{Latitude: -10, Longitude: 170},
+		{Latitude: 10, Longitude: 170},
+		{Latitude: 10, Longitude: -170},
+		{Latitude: -10, Longitude: -170},
+	}
+	if report := ValidateFence(&f); len(report.Warnings()) != 1 {
+		t.Fatalf("Warnings = %v, want the orientation warning", report.Warnings())
+	}
+
+	Normalize(&f)
+
+	if report := ValidateFence(&f); len(report.Issues) != 0 {
+		t.Errorf("Issues after Normalize = %v, want none", report.Issues)
+	}
+	if got := f.Geometry.Polygon[1]; got != (geofence.Point{Latitude: -10, Longitude: -170}) {
+		t.Errorf("second vertex = %v, want the ring reversed", got)
+	}
+	if !f.ContainsPoint(geofence.Point{Latitude: 0, Longitude: 180}) {
+		t.Error("expected containment to be unchanged")
+	}
+}
+
+func TestNormalize_Idempotent(t *testing.T) {
+	f := validFence()
+	Normalize(&f)
+	once := append([]geofence.Point(nil), f.Geometry.Polygon...)
+	Normalize(&f)
+
+	for i := range once {
+		if f.Geometry.Polygon[i] != once[i] {
+			t.Fatalf("second Normalize changed the ring: %v -> %v", once, f.Geometry.Polygon)
+		}
+	}
+}
@@ -5,13 +5,15 @@ package version
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"sync"
 	"time"
 
-	"github.com/iannil/geofence-updater-lite/pkg/binarydiff"
+	"github.com/iannil/geofence-updater-lite/pkg/converter"
 	"github.com/iannil/geofence-updater-lite/pkg/crypto"
 	"github.com/iannil/geofence-updater-lite/pkg/geofence"
 	"github.com/iannil/geofence-updater-lite/pkg/merkle"
@@ -21,7 +23,8 @@ import (
 // Manager handles version management for geofence updates.
 type Manager struct {
 	store          *storage.SQLiteStore
-	keyPair        *crypto.KeyPair
+	signer         crypto.Signer
+	clock          geofence.Clock
 	currentVersion uint64
 	mu             sync.RWMutex
 	baseDir        string
@@ -31,9 +34,11 @@ type Manager struct {
 type Config struct {
 	StorePath   string        // Path to the SQLite database
 	PrivateKey  []byte        // Ed25519 private key for signing
+	Signer      crypto.Signer // Signs instead of PrivateKey if set
 	KeyID       string        // Key ID for the signature
 	OutputDir   string        // Directory for output files
 	CDNBaseURL  string        // Base URL for CDN uploads
+	Clock       geofence.Clock // Clock for fence checks (default: system clock)
 }
 
 // NewManager creates a new version manager.
@@ -49,17 +54,25 @@ func NewManager(ctx context.Context, cfg *Config) (*Manager, error) {
 	}
 
 	// Load key pair
-	keyPair, err := crypto.DeriveKeyPair(nil, cfg.PrivateKey)
-	if err != nil {
-		store.Close()
-		return nil, fmt.Errorf("invalid key pair: %w", err)
+	signer := cfg.Signer
+	if signer == nil {
+		keyPair, err := crypto.DeriveKeyPair(nil, cfg.PrivateKey)
+		if err != nil {
+			store.Close()
+			return nil, fmt.Errorf("invalid key pair: %w", err)
+		}
+		signer = crypto.NewMemorySigner(keyPair)
 	}
 
 	mgr := &Manager{
 		store:     store,
-		keyPair:   keyPair,
+		signer:    signer,
+		clock:     cfg.Clock,
 		baseDir:   cfg.OutputDir,
 	}
+	if mgr.clock == nil {
+		mgr.clock = geofence.SystemClock{}
+	}
 
 	// Load current version
 	version, err := store.GetVersion(ctx)
@@ -114,18 +127,34 @@ func (m *Manager) PublishNewVersion(ctx context.Context, fences []geofence.Fence
 		SnapshotURL: fmt.Sprintf("/snapshots/v%d.bin", newVersion),
 		SnapshotSize: uint64(snapshotSize),
 		Message:     fmt.Sprintf("Version %d - %d fences", newVersion, len(fences)),
+		FormatVersion: geofence.CurrentFormatVersion,
+	}
+
+	// If there's a previous version, write the delta from it
+	deltaPath, err := m.writeDelta(newVersion-1, newVersion, fences)
+	if err != nil {
+		return nil, err
+	}
+	if deltaPath != "" {
+		deltaData, err := os.ReadFile(filepath.Join(m.baseDir, deltaPath))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read delta: %w", err)
+		}
+		manifest.DeltaURL = deltaPath
+		manifest.DeltaSize = uint64(len(deltaData))
+		manifest.DeltaHash = crypto.ComputeSHA256(deltaData)
 	}
 
 	// Sign manifest
-	manifestData, err := manifest.MarshalBinaryForSigning()
+	manifestData, err := converter.ManifestSigningData(manifest)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal manifest for signing: %w", err)
 	}
-	signature, err := m.keyPair.Sign(manifestData)
+	signature, err := m.signer.Sign(manifestData)
 	if err != nil {
 		return nil, fmt.Errorf("failed to sign manifest: %w", err)
 	}
-	manifest.SetSignature(signature, m.keyPair.KeyID)
+	manifest.SetSignature(signature, m.signer.KeyID())
 
 	// Save manifest to storage
 	if err := m.store.SetManifest(ctx, manifest); err != nil {
@@ -149,45 +178,6 @@ func (m *Manager) PublishNewVersion(ctx context.Context, fences []geofence.Fence
 		return nil, fmt.Errorf("failed to write manifest: %w", err)
 	}
 
-	// If there's a previous version, compute delta
-	var deltaPath string
-	if newVersion > 1 {
-		// Get old fences from storage
-		oldFencePtrs, err := m.store.ListFences(ctx)
-		if err == nil && len(oldFencePtrs) > 0 {
-			// Convert pointers to values
-			oldFences := make([]geofence.FenceItem, len(oldFencePtrs))
-			for i, f := range oldFencePtrs {
-				oldFences[i] = *f
-			}
-
-			// Compute delta
-			delta, err := binarydiff.Diff(oldFences, fences)
-			if err == nil {
-				deltaData := delta.DiffData
-				deltaPath = fmt.Sprintf("/patches/v%d_to_v%d.bin", newVersion-1, newVersion)
-
-				// Write delta file
-				deltaFullPath := filepath.Join(m.baseDir, deltaPath)
-				if err := os.WriteFile(deltaFullPath, deltaData, 0644); err != nil {
-					return nil, fmt.Errorf("failed to write delta: %w", err)
-				}
-
-				// Update manifest with delta info
-				manifest.DeltaURL = deltaPath
-				manifest.DeltaSize = uint64(delta.ToSize)
-
-				// Use delta hash
-				manifest.DeltaHash = delta.DiffHash
-
-				// Update manifest file
-				if err := writeManifest(manifest, manifestPath); err != nil {
-					return nil, fmt.Errorf("failed to update manifest with delta info: %w", err)
-				}
-			}
-		}
-	}
-
 	return &PublishResult{
 		Version:     newVersion,
 		Manifest:    manifest,
@@ -196,6 +186,51 @@ func (m *Manager) PublishNewVersion(ctx context.Context, fences []geofence.Fence
 	}, nil
 }
 
+// writeDelta writes the signed fence delta from the snapshot of version
+// fromVer to fences and returns its path relative to the output directory,
+// or "" if there is no such snapshot.
+func (m *Manager) writeDelta(fromVer, toVer uint64, fences []geofence.FenceItem) (string, error) {
+	snapshotData, err := os.ReadFile(filepath.Join(m.baseDir, fmt.Sprintf("v%d.bin", fromVer)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read snapshot of version %d: %w", fromVer, err)
+	}
+	oldFences, err := merkle.LoadSnapshot(snapshotData)
+	if err != nil {
+		return "", fmt.Errorf("failed to load snapshot of version %d: %w", fromVer, err)
+	}
+
+	delta, err := merkle.NewFenceDeltaFile(oldFences, fences, fromVer, toVer, geofence.CurrentFormatVersion)
+	if err != nil {
+		return "", fmt.Errorf("failed to compute delta: %w", err)
+	}
+	signingData, err := converter.FenceDeltaSigningData(delta)
+	if err != nil {
+		return "", err
+	}
+	signature, err := m.signer.Sign(signingData)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign delta: %w", err)
+	}
+	delta.SetSignature(signature, m.signer.KeyID())
+	data, err := converter.MarshalFenceDeltaFile(delta)
+	if err != nil {
+		return "", err
+	}
+
+	deltaPath := fmt.Sprintf("/patches/v%d_to_v%d.bin", fromVer, toVer)
+	deltaFullPath := filepath.Join(m.baseDir, deltaPath)
+	if err := os.MkdirAll(filepath.Dir(deltaFullPath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create delta directory: %w", err)
+	}
+	if err := os.WriteFile(deltaFullPath, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write delta: %w", err)
+	}
+	return deltaPath, nil
+}
+
 // PublishResult contains the results of a publish operation.
 type PublishResult struct {
 	Version     uint64
@@ -248,40 +283,49 @@ func (m *Manager) RemoveFence(ctx context.Context, id string) error {
 	return m.store.DeleteFence(ctx, id)
 }
 
-// QueryAtPoint checks if a location is allowed for flight.
+// QueryAtPoint checks if a location is allowed for flight. It returns the
+// fence forbidding flight or, if flight is allowed, the highest-priority
+// fence limiting it.
 func (m *Manager) QueryAtPoint(ctx context.Context, lat, lon float64) (bool, *geofence.FenceItem, error) {
-	results, err := m.store.QueryAtPoint(ctx, lat, lon)
+	return m.QueryAtPointAt(ctx, lat, lon, m.clock.Now())
+}
+
+// QueryAtPointAt is like QueryAtPoint but evaluates fences as of time t.
+func (m *Manager) QueryAtPointAt(ctx context.Context, lat, lon float64, t time.Time) (bool, *geofence.FenceItem, error) {
+	ev, err := m.CheckAt(ctx, lat, lon, t)
 	if err != nil {
-		return false, nil, fmt.Errorf("query failed: %w", err)
+		return false, nil, err
 	}
 
-	if len(results) == 0 {
-		return true, nil, nil
+	if ev.Restriction != nil {
+		return ev.Allowed, ev.Restriction, nil
+	}
+	if len(ev.Constraints) > 0 {
+		return ev.Allowed, &ev.Constraints[0].Fence, nil
 	}
+	return ev.Allowed, nil, nil
+}
 
-	// Return the highest priority restriction
-	highestPriority := results[0]
-	for i := 1; i < len(results); i++ {
-		if results[i].Priority > highestPriority.Priority {
-			highestPriority = results[i]
-		}
+// Check evaluates every fence that applies at a location and returns their
+// combined effect, as documented on geofence.EvaluateFences.
+func (m *Manager) Check(ctx context.Context, lat, lon float64) (*geofence.Evaluation, error) {
+	return m.CheckAt(ctx, lat, lon, m.clock.Now())
+}
+
+// CheckAt is like Check but evaluates fences as of time t.
+func (m *Manager) CheckAt(ctx context.Context, lat, lon float64, t time.Time) (*geofence.Evaluation, error) {
+	results, err := m.store.QueryAtPointAt(ctx, lat, lon, t)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
 	}
 
-	switch highestPriority.Type {
-	case geofence.FenceTypePermanentNoFly, geofence.FenceTypeTempRestriction:
-		return false, highestPriority, nil
-	case geofence.FenceTypeAltitudeLimit:
-		return true, highestPriority, nil
-	case geofence.FenceTypeSpeedLimit:
-		return true, highestPriority, nil
-	default:
-		return true, nil, nil
+	fences := make([]geofence.FenceItem, len(results))
+	for i, f := range results {
+		fences[i] = *f
 	}
-}
 
-// Check is a convenience method for QueryAtPoint.
-func (m *Manager) Check(ctx context.Context, lat, lon float64) (bool, *geofence.FenceItem, error) {
-	return m.QueryAtPoint(ctx, lat, lon)
+	ev := geofence.EvaluateFencesAt(fences, geofence.Point{Latitude: lat, Longitude: lon}, t)
+	return &ev, nil
 }
 
 // GetFence retrieves a fence by ID.
@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/iannil/geofence-updater-lite/internal/testutil"
 	"github.com/iannil/geofence-updater-lite/pkg/crypto"
 	"github.com/iannil/geofence-updater-lite/pkg/geofence"
 )
@@ -41,8 +42,35 @@ func TestNewManager(t *testing.T) {
 	if mgr.store == nil {
 		t.Error("store should not be nil")
 	}
-	if mgr.keyPair == nil {
-		t.Error("keyPair should not be nil")
+	if mgr.signer == nil {
+		t.Error("signer should not be nil")
+	}
+}
+
+func TestNewManager_Signer(t *testing.T) {
+	ctx := context.Background()
+	signer := testutil.NewSigner()
+	dir := t.TempDir()
+
+	mgr, err := NewManager(ctx, &Config{
+		StorePath: filepath.Join(dir, "test.db"),
+		Signer:    signer,
+		OutputDir: dir,
+	})
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	defer mgr.Close()
+
+	result, err := mgr.PublishNewVersion(ctx, []geofence.FenceItem{testutil.CircleFence()})
+	if err != nil {
+		t.Fatalf("PublishNewVersion failed: %v", err)
+	}
+	if result.Manifest.KeyID != signer.KeyID() {
+		t.Errorf("manifest KeyID = %s, want %s", result.Manifest.KeyID, signer.KeyID())
+	}
+	if len(signer.Messages()) != 1 {
+		t.Errorf("signer signed %d messages, want 1", len(signer.Messages()))
 	}
 }
 
@@ -203,6 +231,59 @@ func TestPublishNewVersion_Multiple(t *testing.T) {
 	}
 }
 
+func TestPublishNewVersion_PreviousSnapshot(t *testing.T) {
+	ctx := context.Background()
+	fences := []geofence.FenceItem{testutil.CircleFence()}
+
+	tests := []struct {
+		name      string
+		prepare   func(path string) error
+		wantErr   bool
+		wantDelta bool
+	}{
+		{
+			name:      "present",
+			prepare:   func(string) error { return nil },
+			wantDelta: true,
+		},
+		{
+			name:    "missing",
+			prepare: os.Remove,
+		},
+		{
+			name:    "corrupt",
+			prepare: func(path string) error { return os.WriteFile(path, []byte("corrupt"), 0644) },
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mgr, err := NewManager(ctx, testManagerConfig(t))
+			if err != nil {
+				t.Fatalf("NewManager failed: %v", err)
+			}
+			defer mgr.Close()
+
+			first, err := mgr.PublishNewVersion(ctx, fences)
+			if err != nil {
+				t.Fatalf("PublishNewVersion failed: %v", err)
+			}
+			if err := tt.prepare(first.SnapshotPath); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := mgr.PublishNewVersion(ctx, fences)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PublishNewVersion error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (result.DeltaPath != "") != tt.wantDelta {
+				t.Errorf("DeltaPath = %q, want delta %v", result.DeltaPath, tt.wantDelta)
+			}
+		})
+	}
+}
+
 func TestUpdateFence(t *testing.T) {
 	ctx := context.Background()
 	cfg := testManagerConfig(t)
@@ -392,19 +473,68 @@ func TestCheck(t *testing.T) {
 		t.Fatalf("UpdateFence failed: %v", err)
 	}
 
-	// Check should be equivalent to QueryAtPoint
-	allowed, restriction, err := mgr.Check(ctx, 38.5, 115.5)
+	ev, err := mgr.Check(ctx, 38.5, 115.5)
 	if err != nil {
 		t.Fatalf("Check failed: %v", err)
 	}
-	if allowed {
+	if ev.Allowed {
 		t.Error("expected not allowed inside temp restriction")
 	}
-	if restriction == nil {
+	if ev.Restriction == nil {
 		t.Error("expected restriction to be returned")
 	}
 }
 
+func TestCheckAt(t *testing.T) {
+	ctx := context.Background()
+	cfg := testManagerConfig(t)
+
+	start := time.Date(2026, 7, 1, 8, 0, 0, 0, time.UTC)
+	cfg.Clock = geofence.FixedClock(start.Add(time.Hour))
+
+	mgr, err := NewManager(ctx, cfg)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	defer mgr.Close()
+
+	fence := &geofence.FenceItem{
+		ID:       "event-window",
+		Type:     geofence.FenceTypeTempRestriction,
+		StartTS:  start.Unix(),
+		EndTS:    start.Add(4 * time.Hour).Unix(),
+		Priority: 50,
+		Geometry: geofence.Geometry{
+			Polygon: []geofence.Point{
+				{Latitude: 38.0, Longitude: 115.0},
+				{Latitude: 38.0, Longitude: 116.0},
+				{Latitude: 39.0, Longitude: 116.0},
+				{Latitude: 39.0, Longitude: 115.0},
+			},
+		},
+	}
+	if err := mgr.UpdateFence(ctx, fence); err != nil {
+		t.Fatalf("UpdateFence failed: %v", err)
+	}
+
+	// Check uses the configured clock
+	ev, err := mgr.Check(ctx, 38.5, 115.5)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if ev.Allowed {
+		t.Error("expected not allowed at the configured time")
+	}
+
+	ev, err = mgr.CheckAt(ctx, 38.5, 115.5, start.Add(5*time.Hour))
+	if err != nil {
+		t.Fatalf("CheckAt failed: %v", err)
+	}
+	if !ev.Allowed {
+		t.Error("expected allowed after the restriction window")
+	}
+}
+
 func TestQueryAtPoint_AltitudeLimit(t *testing.T) {
 	ctx := context.Background()
 	cfg := testManagerConfig(t)